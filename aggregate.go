@@ -0,0 +1,171 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"slices"
+)
+
+// Encode returns the AGGREGATOR attribute data for a.
+func (a AggregatorPathAttr) Encode() []byte {
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 8), a.AS)
+	ip := a.IP.As4()
+	return append(b, ip[:]...)
+}
+
+// AggregateContributor is a route that may contribute to an aggregate.
+type AggregateContributor struct {
+	Prefix          netip.Prefix
+	ASPath          ASPath
+	Origin          OriginPathAttr
+	AtomicAggregate bool
+}
+
+// AggregateRoute is a route originated by an Aggregate.
+type AggregateRoute struct {
+	Prefix          netip.Prefix
+	ASPath          ASPath
+	Origin          OriginPathAttr
+	AtomicAggregate bool
+	Aggregator      AggregatorPathAttr
+}
+
+// Aggregate originates a summary prefix from the more specific routes it
+// covers. corebgp does not maintain a RIB, Aggregate is applied by Plugins to
+// the routes they hold.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.2.2.2
+type Aggregate struct {
+	// Prefix is the summary prefix.
+	Prefix netip.Prefix
+	// SummaryOnly suppresses the advertisement of contributing routes, see
+	// Suppressed.
+	SummaryOnly bool
+	// ASSet includes the AS numbers of contributing routes that are not part
+	// of their common leading AS_SEQUENCE as an AS_SET. Otherwise they are
+	// omitted and ATOMIC_AGGREGATE is set. Note that RFC6472 recommends
+	// against the use of AS_SET.
+	ASSet bool
+	// LocalAS and RouterID are carried in the AGGREGATOR attribute.
+	LocalAS  uint32
+	RouterID netip.Addr
+}
+
+// Contributes returns true if prefix is more specific than, and covered by,
+// the aggregate's Prefix.
+func (a Aggregate) Contributes(prefix netip.Prefix) bool {
+	return prefix.Bits() > a.Prefix.Bits() &&
+		a.Prefix.Overlaps(prefix) &&
+		prefix.Addr().Is4() == a.Prefix.Addr().Is4()
+}
+
+// Suppressed returns true if a route for prefix should not be advertised
+// because it contributes to a SummaryOnly aggregate. Suppression only applies
+// while the aggregate is active, i.e. Route returns true.
+func (a Aggregate) Suppressed(prefix netip.Prefix) bool {
+	return a.SummaryOnly && a.Contributes(prefix)
+}
+
+// Route returns the aggregate route for routes and true if at least one of
+// routes contributes to the aggregate. Routes that do not contribute are
+// ignored.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.2.2.2
+//
+//	ORIGIN attribute: If at least one route among routes that are
+//	aggregated has ORIGIN with the value INCOMPLETE, then the aggregated
+//	route MUST have the ORIGIN attribute with the value INCOMPLETE.
+//	Otherwise, if at least one route among routes that are aggregated has
+//	ORIGIN with the value EGP, then the aggregated route MUST have the
+//	ORIGIN attribute with the value EGP.  In all other cases, the value of
+//	the ORIGIN attribute of the aggregated route is IGP.
+//
+//	ATOMIC_AGGREGATE: If at least one of the routes to be aggregated has
+//	ATOMIC_AGGREGATE path attribute, then the aggregated route SHALL have
+//	this attribute as well.
+func (a Aggregate) Route(routes []AggregateContributor) (AggregateRoute,
+	bool) {
+	r := AggregateRoute{
+		Prefix: a.Prefix.Masked(),
+		Origin: OriginIGP,
+		Aggregator: AggregatorPathAttr{
+			AS: a.LocalAS,
+			IP: a.RouterID,
+		},
+	}
+	paths := make([]ASPath, 0, len(routes))
+	for _, route := range routes {
+		if !a.Contributes(route.Prefix) {
+			continue
+		}
+		r.Origin = max(r.Origin, route.Origin)
+		r.AtomicAggregate = r.AtomicAggregate || route.AtomicAggregate
+		paths = append(paths, route.ASPath)
+	}
+	if len(paths) == 0 {
+		return AggregateRoute{}, false
+	}
+	path, rest := aggregateASPaths(paths)
+	if len(rest) > 0 {
+		if a.ASSet {
+			path = append(path, ASPathSegment{
+				Type: ASPathSegmentTypeSet,
+				ASNs: rest,
+			})
+		} else {
+			// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.4
+			// If the local system aggregates routes and the resulting
+			// AS_PATH does not contain all the ASes of the aggregated
+			// routes, the aggregated route is marked with ATOMIC_AGGREGATE.
+			r.AtomicAggregate = true
+		}
+	}
+	r.ASPath = path
+	return r, true
+}
+
+// aggregateASPaths returns the leading AS_SEQUENCE common to paths and the
+// sorted, unique AS numbers of paths that are not a part of it.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#appendix-F.6
+func aggregateASPaths(paths []ASPath) (ASPath, []uint32) {
+	leading := func(p ASPath) []uint32 {
+		if len(p) == 0 || p[0].Type != ASPathSegmentTypeSequence {
+			return nil
+		}
+		return p[0].ASNs
+	}
+	common := leading(paths[0])
+	for _, p := range paths[1:] {
+		seq := leading(p)
+		n := 0
+		for n < len(common) && n < len(seq) && common[n] == seq[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	rest := make([]uint32, 0)
+	for _, p := range paths {
+		for i, seg := range p {
+			asns := seg.ASNs
+			if i == 0 && seg.Type == ASPathSegmentTypeSequence {
+				asns = asns[len(common):]
+			}
+			for _, asn := range asns {
+				if !slices.Contains(common, asn) {
+					rest = append(rest, asn)
+				}
+			}
+		}
+	}
+	slices.Sort(rest)
+	rest = slices.Compact(rest)
+	var path ASPath
+	if len(common) > 0 {
+		path = ASPath{{
+			Type: ASPathSegmentTypeSequence,
+			ASNs: append([]uint32{}, common...),
+		}}
+	}
+	return path, rest
+}
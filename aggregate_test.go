@@ -0,0 +1,70 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	agg := Aggregate{
+		Prefix:      netip.MustParsePrefix("10.0.0.0/8"),
+		SummaryOnly: true,
+		LocalAS:     65000,
+		RouterID:    netip.MustParseAddr("192.0.2.1"),
+	}
+	routes := []AggregateContributor{
+		{
+			Prefix: netip.MustParsePrefix("10.1.0.0/16"),
+			ASPath: ASPath{}.Prepend(65001, 65002),
+			Origin: OriginIGP,
+		},
+		{
+			Prefix: netip.MustParsePrefix("10.2.0.0/16"),
+			ASPath: ASPath{}.Prepend(65001, 65003),
+			Origin: OriginEGP,
+		},
+		{
+			// not a contributor
+			Prefix: netip.MustParsePrefix("192.168.0.0/16"),
+			ASPath: ASPath{}.Prepend(65004),
+			Origin: OriginIncomplete,
+		},
+	}
+
+	assert.True(t, agg.Suppressed(routes[0].Prefix))
+	assert.False(t, agg.Suppressed(routes[2].Prefix))
+	assert.False(t, agg.Suppressed(agg.Prefix))
+
+	r, ok := agg.Route(routes)
+	assert.True(t, ok)
+	assert.Equal(t, AggregateRoute{
+		Prefix:          agg.Prefix,
+		ASPath:          ASPath{}.Prepend(65001),
+		Origin:          OriginEGP,
+		AtomicAggregate: true,
+		Aggregator:      AggregatorPathAttr{AS: 65000, IP: agg.RouterID},
+	}, r)
+	assert.Equal(t, []byte{0, 0, 0xfd, 0xe8, 192, 0, 2, 1},
+		r.Aggregator.Encode())
+
+	agg.ASSet = true
+	r, ok = agg.Route(routes)
+	assert.True(t, ok)
+	assert.False(t, r.AtomicAggregate)
+	assert.Equal(t, ASPath{
+		{Type: ASPathSegmentTypeSequence, ASNs: []uint32{65001}},
+		{Type: ASPathSegmentTypeSet, ASNs: []uint32{65002, 65003}},
+	}, r.ASPath)
+
+	// identical paths are preserved, ATOMIC_AGGREGATE is inherited
+	routes[1].ASPath = routes[0].ASPath
+	routes[1].AtomicAggregate = true
+	r, _ = agg.Route(routes[:2])
+	assert.Equal(t, routes[0].ASPath, r.ASPath)
+	assert.True(t, r.AtomicAggregate)
+
+	_, ok = agg.Route(routes[2:])
+	assert.False(t, ok)
+}
@@ -0,0 +1,66 @@
+package corebgp
+
+import (
+	"encoding/binary"
+)
+
+// AIGPPathAttr is the accumulated IGP metric carried in the AIGP TLV of an
+// AIGP attribute.
+//
+// https://www.rfc-editor.org/rfc/rfc7311#section-3
+type AIGPPathAttr uint64
+
+const (
+	aigpTLVType = 1
+	aigpTLVLen  = 11
+)
+
+// Decode decodes the AIGP attribute data in b. TLVs other than the AIGP TLV
+// are ignored, as are AIGP TLVs following the first. A malformed attribute is
+// discarded as per RFC7311 section 3.
+func (a *AIGPPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	err := flags.Validate(PATH_ATTR_AIGP, b, true, false)
+	if err != nil {
+		return err
+	}
+	malformed := &AttrDiscardUpdateErr{
+		Code: PATH_ATTR_AIGP,
+		Notification: &Notification{
+			Code:    NOTIF_CODE_UPDATE_MESSAGE_ERR,
+			Subcode: NOTIF_SUBCODE_OPTIONAL_ATTR_ERR,
+			Data:    notifDataForAttrBasedErr(PATH_ATTR_AIGP, b),
+		},
+	}
+	found := false
+	for len(b) > 0 {
+		// the TLV length includes the type and length fields
+		if len(b) < 3 {
+			return malformed
+		}
+		l := int(binary.BigEndian.Uint16(b[1:]))
+		if l < 3 || l > len(b) {
+			return malformed
+		}
+		if b[0] == aigpTLVType && !found {
+			if l != aigpTLVLen {
+				return malformed
+			}
+			*a = AIGPPathAttr(binary.BigEndian.Uint64(b[3:]))
+			found = true
+		}
+		b = b[l:]
+	}
+	if !found {
+		return malformed
+	}
+	return nil
+}
+
+// Encode returns the AIGP attribute data for a, consisting of an AIGP TLV.
+// The attribute is optional and non-transitive, see AppendPathAttr.
+func (a AIGPPathAttr) Encode() []byte {
+	b := make([]byte, 0, aigpTLVLen)
+	b = append(b, aigpTLVType)
+	b = binary.BigEndian.AppendUint16(b, aigpTLVLen)
+	return binary.BigEndian.AppendUint64(b, uint64(a))
+}
@@ -0,0 +1,35 @@
+package corebgp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIGPPathAttr(t *testing.T) {
+	flags := PathAttrFlags(0x80)
+	b := AIGPPathAttr(1000).Encode()
+	assert.Equal(t, []byte{1, 0, 11, 0, 0, 0, 0, 0, 0, 0x03, 0xe8}, b)
+
+	var a AIGPPathAttr
+	assert.NoError(t, a.Decode(flags, b))
+	assert.Equal(t, AIGPPathAttr(1000), a)
+
+	// unknown TLVs are ignored
+	assert.NoError(t, a.Decode(flags, append([]byte{2, 0, 4, 0}, b...)))
+	assert.Equal(t, AIGPPathAttr(1000), a)
+
+	for _, b := range [][]byte{
+		nil,
+		b[:10],
+		{1, 0, 4, 0},
+		{2, 0, 3},
+	} {
+		var adErr *AttrDiscardUpdateErr
+		assert.True(t, errors.As(a.Decode(flags, b), &adErr), "%x", b)
+	}
+
+	var twErr *TreatAsWithdrawUpdateErr
+	assert.True(t, errors.As(a.Decode(flags|0x40, b), &twErr))
+}
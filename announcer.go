@@ -0,0 +1,392 @@
+package corebgp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckInterval is the interval at which Announcement.Check is
+// called if Announcement.Interval is zero.
+const DefaultHealthCheckInterval = time.Second * 5
+
+// Announcement is a route originated by an Announcer while it is healthy,
+// e.g. the anycast prefix of a service announced by each of its instances.
+type Announcement struct {
+	Prefix netip.Prefix
+	// NextHop is the next hop of the route. For an IPv4 prefix it is encoded
+	// in a NEXT_HOP path attribute, and for an IPv6 prefix in an
+	// MP_REACH_NLRI path attribute.
+	NextHop netip.Addr
+	// Attrs are the encoded path attributes of the route excluding NEXT_HOP
+	// and MP_REACH_NLRI, e.g. ORIGIN, AS_PATH, and COMMUNITIES.
+	Attrs []byte
+	// Peers are the RemoteAddress of the peers the route is announced to. A
+	// nil Peers announces the route to all peers.
+	Peers []netip.Addr
+
+	// Check, if non-nil, is called every Interval to determine the health of
+	// the route, an error indicating it is unhealthy. ctx is done when the
+	// Announcement is removed.
+	Check func(ctx context.Context) error
+	// Interval is the interval at which Check is called. It defaults to
+	// DefaultHealthCheckInterval.
+	Interval time.Duration
+	// Health, if non-nil, receives the health of the route in place of
+	// Check. Closing it marks the route unhealthy. If both Check and Health
+	// are nil the route is always healthy.
+	Health <-chan bool
+
+	// Rise is the number of consecutive healthy results required before the
+	// route is announced. It defaults to 1.
+	Rise int
+	// Fall is the number of consecutive unhealthy results required before
+	// the route is withdrawn. It defaults to 1.
+	Fall int
+	// HoldDown is the minimum duration the route remains withdrawn once
+	// withdrawn, damping a flapping health check.
+	HoldDown time.Duration
+}
+
+func (a *Announcement) validate() error {
+	if !a.Prefix.IsValid() {
+		return errors.New("invalid prefix")
+	}
+	if !a.NextHop.IsValid() || a.NextHop.Is4() != a.Prefix.Addr().Is4() {
+		return errors.New("next hop must be of the prefix's address family")
+	}
+	if a.Check != nil && a.Health != nil {
+		return errors.New("only one of Check and Health may be set")
+	}
+	if a.Interval < 0 || a.Rise < 0 || a.Fall < 0 || a.HoldDown < 0 {
+		return errors.New("interval, rise, fall, and hold down must be >= 0")
+	}
+	return nil
+}
+
+func (a *Announcement) toPeer(peer PeerConfig) bool {
+	return a.Peers == nil || slices.Contains(a.Peers, peer.RemoteAddress)
+}
+
+// announcement is the state of an Announcement added to an Announcer.
+type announcement struct {
+	Announcement
+	cancel context.CancelFunc
+	done   chan struct{}
+	// announced is protected by the Announcer's mu
+	announced bool
+}
+
+// Announcer is a Plugin that announces and withdraws routes to its peers
+// according to the results of health checks, e.g. to implement anycast load
+// balancing. It is typically layered with an application's Plugin via
+// NewPluginChain. It does not return any capabilities, the application is
+// responsible for advertising the Multiprotocol Extensions Capability for
+// IPv6 unicast if IPv6 routes are announced.
+//
+// A route is only announced to a peer if its address family was negotiated
+// for the session. If ADD-PATH was negotiated for sending, the route is sent
+// with a Path Identifier of 1. The negotiated parameters are obtained via
+// SessionWriter. If the UpdateMessageWriter provided to OnEstablished does
+// not implement it, IPv4 and IPv6 unicast are assumed to be negotiated
+// without ADD-PATH.
+type Announcer struct {
+	mu            sync.Mutex
+	announcements map[netip.Prefix]*announcement
+	writers       map[netip.Addr]announcerPeer
+	closed        bool
+}
+
+type announcerPeer struct {
+	config PeerConfig
+	writer UpdateMessageWriter
+}
+
+// NewAnnouncer returns an Announcer with no Announcements.
+func NewAnnouncer() *Announcer {
+	return &Announcer{
+		announcements: make(map[netip.Prefix]*announcement),
+		writers:       make(map[netip.Addr]announcerPeer),
+	}
+}
+
+// Add adds a, starting its health checks. The route is announced once a is
+// healthy. An error is returned if a is invalid or its prefix was already
+// added.
+func (a *Announcer) Add(ann Announcement) error {
+	if err := ann.validate(); err != nil {
+		return err
+	}
+	ann.Prefix = ann.Prefix.Masked()
+	ann.Peers = slices.Clone(ann.Peers)
+	ann.Attrs = slices.Clone(ann.Attrs)
+	if ann.Interval == 0 {
+		ann.Interval = DefaultHealthCheckInterval
+	}
+	ann.Rise = max(ann.Rise, 1)
+	ann.Fall = max(ann.Fall, 1)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("announcer closed")
+	}
+	if _, exists := a.announcements[ann.Prefix]; exists {
+		return fmt.Errorf("prefix %s already added", ann.Prefix)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &announcement{
+		Announcement: ann,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	a.announcements[ann.Prefix] = s
+	go a.run(ctx, s)
+	return nil
+}
+
+// Remove stops the health checks of the Announcement for prefix, withdrawing
+// its route. It returns false if prefix was not added.
+func (a *Announcer) Remove(prefix netip.Prefix) bool {
+	a.mu.Lock()
+	s, ok := a.announcements[prefix.Masked()]
+	if ok {
+		delete(a.announcements, s.Prefix)
+	}
+	a.mu.Unlock()
+	if !ok {
+		return false
+	}
+	a.stop(s)
+	return true
+}
+
+// Announced returns true if the route for prefix is announced.
+func (a *Announcer) Announced(prefix netip.Prefix) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.announcements[prefix.Masked()]
+	return ok && s.announced
+}
+
+// Close removes all Announcements, withdrawing their routes.
+func (a *Announcer) Close() {
+	a.mu.Lock()
+	a.closed = true
+	removed := make([]*announcement, 0, len(a.announcements))
+	for p, s := range a.announcements {
+		removed = append(removed, s)
+		delete(a.announcements, p)
+	}
+	a.mu.Unlock()
+	for _, s := range removed {
+		a.stop(s)
+	}
+}
+
+func (a *Announcer) stop(s *announcement) {
+	s.cancel()
+	<-s.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.setAnnouncedLocked(s, false)
+}
+
+// run performs the health checks of s until ctx is done.
+func (a *Announcer) run(ctx context.Context, s *announcement) {
+	defer close(s.done)
+	var (
+		tickCh     <-chan time.Time
+		holdDownCh <-chan time.Time
+		healthCh   = s.Health
+		rise, fall int
+		// holdDownUntil is the time before which the route may not be
+		// announced
+		holdDownUntil time.Time
+	)
+	if s.Check != nil {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+	var holdDown *time.Timer
+	defer func() {
+		if holdDown != nil {
+			holdDown.Stop()
+		}
+	}()
+	// evaluate announces or withdraws the route according to the latest
+	// results
+	evaluate := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case !s.announced && rise >= s.Rise:
+			if wait := time.Until(holdDownUntil); wait > 0 {
+				if holdDown == nil {
+					holdDown = time.NewTimer(wait)
+				} else {
+					holdDown.Reset(wait)
+				}
+				holdDownCh = holdDown.C
+				return
+			}
+			a.setAnnouncedLocked(s, true)
+		case s.announced && fall >= s.Fall:
+			a.setAnnouncedLocked(s, false)
+			holdDownUntil = time.Now().Add(s.HoldDown)
+		}
+	}
+	report := func(healthy bool) {
+		if healthy {
+			rise, fall = rise+1, 0
+		} else {
+			rise, fall = 0, fall+1
+		}
+		evaluate()
+	}
+	check := func() {
+		report(s.Check(ctx) == nil)
+	}
+	switch {
+	case s.Check != nil:
+		check()
+	case s.Health == nil:
+		report(true)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tickCh:
+			check()
+		case healthy, ok := <-healthCh:
+			if !ok {
+				healthCh = nil
+			}
+			report(healthy && ok)
+		case <-holdDownCh:
+			holdDownCh = nil
+			evaluate()
+		}
+	}
+}
+
+// setAnnouncedLocked announces or withdraws the route of s to all peers. It
+// must be called with a.mu held.
+func (a *Announcer) setAnnouncedLocked(s *announcement, announced bool) {
+	if s.announced == announced {
+		return
+	}
+	s.announced = announced
+	for _, p := range a.writers {
+		a.writeLocked(p, s)
+	}
+}
+
+// writeLocked writes the route of s to p, an UPDATE message announcing it if
+// s is announced and one withdrawing it otherwise. It must be called with
+// a.mu held.
+func (a *Announcer) writeLocked(p announcerPeer, s *announcement) {
+	if !s.toPeer(p.config) {
+		return
+	}
+	sw, isSessionWriter := p.writer.(SessionWriter)
+	var addPath bool
+	if isSessionWriter {
+		info := sw.SessionInfo()
+		afi := uint16(AFI_IPV4)
+		if s.Prefix.Addr().Is6() {
+			afi = AFI_IPV6
+		}
+		if !slices.Contains(info.Families,
+			MPExtensions{AFI: afi, SAFI: SAFI_UNICAST}) {
+			return
+		}
+		addPath = slices.ContainsFunc(info.AddPath, func(t AddPathTuple) bool {
+			return t.AFI == afi && t.SAFI == SAFI_UNICAST && t.Tx
+		})
+	}
+	b := encodeAnnouncement(&s.Announcement, s.announced, addPath)
+	if p.writer.WriteUpdate(b) == nil && isSessionWriter {
+		sw.Flush() // nolint: errcheck
+	}
+}
+
+// encodeAnnouncement returns an UPDATE message announcing the route of a, or
+// withdrawing it if announce is false.
+func encodeAnnouncement(a *Announcement, announce, addPath bool) []byte {
+	var nlri []byte
+	if addPath {
+		nlri = binary.BigEndian.AppendUint32(nlri, 1)
+	}
+	nlri = append(nlri, byte(a.Prefix.Bits()))
+	nlri = append(nlri,
+		a.Prefix.Addr().AsSlice()[:(a.Prefix.Bits()+7)/8]...)
+
+	var withdrawn, attrs, reach []byte
+	switch {
+	case a.Prefix.Addr().Is4() && announce:
+		attrs = append(attrs, a.Attrs...)
+		attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_NEXT_HOP,
+			a.NextHop.AsSlice())
+		reach = nlri
+	case a.Prefix.Addr().Is4():
+		withdrawn = nlri
+	case announce:
+		data := binary.BigEndian.AppendUint16(nil, AFI_IPV6)
+		data = append(data, SAFI_UNICAST, 16)
+		data = append(data, a.NextHop.AsSlice()...)
+		data = append(data, 0)
+		data = append(data, nlri...)
+		attrs = AppendPathAttr(attrs, 0x80, PATH_ATTR_MP_REACH_NLRI, data)
+		attrs = append(attrs, a.Attrs...)
+	default:
+		data := binary.BigEndian.AppendUint16(nil, AFI_IPV6)
+		data = append(data, SAFI_UNICAST)
+		data = append(data, nlri...)
+		attrs = AppendPathAttr(attrs, 0x80, PATH_ATTR_MP_UNREACH_NLRI, data)
+	}
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(withdrawn)))
+	b = append(b, withdrawn...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	b = append(b, attrs...)
+	return append(b, reach...)
+}
+
+func (a *Announcer) GetCapabilities(PeerConfig) []Capability {
+	return nil
+}
+
+func (a *Announcer) OnOpenMessage(PeerConfig, netip.Addr,
+	[]Capability) *Notification {
+	return nil
+}
+
+// OnEstablished announces the announced routes to peer.
+func (a *Announcer) OnEstablished(peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := announcerPeer{config: peer, writer: writer}
+	a.writers[peer.RemoteAddress] = p
+	for _, s := range a.announcements {
+		if s.announced {
+			a.writeLocked(p, s)
+		}
+	}
+	return nil
+}
+
+func (a *Announcer) OnClose(peer PeerConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.writers, peer.RemoteAddress)
+}
@@ -0,0 +1,159 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUpdateWriter struct {
+	SessionWriter
+	info    SessionInfo
+	updates chan []byte
+}
+
+func (w *testUpdateWriter) WriteUpdate(b []byte) error {
+	w.updates <- b
+	return nil
+}
+
+func (w *testUpdateWriter) Flush() error {
+	return nil
+}
+
+func (w *testUpdateWriter) SessionInfo() SessionInfo {
+	return w.info
+}
+
+func TestEncodeAnnouncement(t *testing.T) {
+	origin := AppendPathAttr(nil, 0x40, PATH_ATTR_ORIGIN, []byte{0})
+	v4 := &Announcement{
+		Prefix:  netip.MustParsePrefix("192.0.2.0/24"),
+		NextHop: netip.MustParseAddr("198.51.100.1"),
+		Attrs:   origin,
+	}
+	assert.Equal(t, []byte{
+		0, 0, // withdrawn routes length
+		0, 11, // total path attribute length
+		0x40, PATH_ATTR_ORIGIN, 1, 0,
+		0x40, PATH_ATTR_NEXT_HOP, 4, 198, 51, 100, 1,
+		24, 192, 0, 2,
+	}, encodeAnnouncement(v4, true, false))
+	assert.Equal(t, []byte{
+		0, 8, // withdrawn routes length
+		0, 0, 0, 1, 24, 192, 0, 2,
+		0, 0, // total path attribute length
+	}, encodeAnnouncement(v4, false, true))
+
+	v6 := &Announcement{
+		Prefix:  netip.MustParsePrefix("2001:db8::/32"),
+		NextHop: netip.MustParseAddr("2001:db8::1"),
+		Attrs:   origin,
+	}
+	b := encodeAnnouncement(v6, true, false)
+	x, err := NewUpdateIndex(b)
+	if assert.NoError(t, err) {
+		assert.True(t, x.Has(PATH_ATTR_ORIGIN))
+		flags, data, ok := x.Attr(PATH_ATTR_MP_REACH_NLRI)
+		assert.True(t, ok)
+		assert.True(t, flags.Optional())
+		want := []byte{0, 2, 1, 16}
+		want = append(want, v6.NextHop.AsSlice()...)
+		want = append(want, 0, 32, 0x20, 0x01, 0x0d, 0xb8)
+		assert.Equal(t, want, data)
+		assert.Empty(t, x.NLRI())
+	}
+	assert.Equal(t, []byte{
+		0, 0, // withdrawn routes length
+		0, 11, // total path attribute length
+		0x80, PATH_ATTR_MP_UNREACH_NLRI, 8, 0, 2, 1, 32, 0x20, 0x01, 0x0d, 0xb8,
+	}, encodeAnnouncement(v6, false, false))
+}
+
+func TestAnnouncer(t *testing.T) {
+	a := NewAnnouncer()
+	defer a.Close()
+	v4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	peerA := PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.1")}
+	peerB := PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.2")}
+	peerC := PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.3")}
+	peerD := PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.4")}
+	newWriter := func(families ...MPExtensions) *testUpdateWriter {
+		return &testUpdateWriter{
+			info:    SessionInfo{Families: families},
+			updates: make(chan []byte, 16),
+		}
+	}
+	wA, wB, wC, wD := newWriter(v4), newWriter(v4), newWriter(), newWriter()
+	a.OnEstablished(peerA, wA)
+	a.OnEstablished(peerB, wB)
+	a.OnEstablished(peerC, wC)
+	// a writer not implementing SessionWriter is assumed to have negotiated
+	// IPv4 unicast
+	a.OnEstablished(peerD, struct{ UpdateMessageWriter }{wD})
+
+	prefix := netip.MustParsePrefix("192.0.2.0/24")
+	health := make(chan bool)
+	const holdDown = time.Millisecond * 200
+	err := a.Add(Announcement{
+		Prefix:  prefix,
+		NextHop: netip.MustParseAddr("192.0.2.10"),
+		Peers: []netip.Addr{peerA.RemoteAddress, peerC.RemoteAddress,
+			peerD.RemoteAddress},
+		Health:   health,
+		Fall:     2,
+		HoldDown: holdDown,
+	})
+	assert.NoError(t, err)
+	assert.Error(t, a.Add(Announcement{
+		Prefix:  prefix,
+		NextHop: netip.MustParseAddr("192.0.2.10"),
+	}))
+
+	next := func(w *testUpdateWriter) []byte {
+		select {
+		case b := <-w.updates:
+			return b
+		case <-time.After(time.Second * 2):
+			t.Fatal("timed out waiting for update")
+			return nil
+		}
+	}
+	isWithdraw := func(b []byte) bool {
+		x, err := NewUpdateIndex(b)
+		return err == nil && len(x.Withdrawn()) > 0
+	}
+
+	health <- true
+	assert.False(t, isWithdraw(next(wA)))
+	assert.False(t, isWithdraw(next(wD)))
+	assert.True(t, a.Announced(prefix))
+	a.OnClose(peerD)
+
+	// a peer established later receives the announced route
+	a.OnClose(peerA)
+	wA = newWriter(v4)
+	a.OnEstablished(peerA, wA)
+	assert.False(t, isWithdraw(next(wA)))
+
+	health <- false
+	health <- false
+	assert.True(t, isWithdraw(next(wA)))
+	assert.False(t, a.Announced(prefix))
+
+	// the route is announced again once the hold down elapses
+	start := time.Now()
+	health <- true
+	assert.False(t, isWithdraw(next(wA)))
+	assert.GreaterOrEqual(t, time.Since(start), holdDown/2)
+
+	assert.True(t, a.Remove(prefix))
+	assert.True(t, isWithdraw(next(wA)))
+	assert.False(t, a.Remove(prefix))
+
+	// peerB is not selected and peerC did not negotiate IPv4 unicast
+	assert.Empty(t, wB.updates)
+	assert.Empty(t, wC.updates)
+}
@@ -0,0 +1,177 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// arenaDecoded is decoded into by the UpdateDecoder returned by
+// newArenaTestDecoder, allocating from arena, which may be nil.
+type arenaDecoded struct {
+	arena       *DecodeArena
+	withdrawn   []netip.Prefix
+	origin      OriginPathAttr
+	asPath      ASPathAttr
+	nextHop     NextHopPathAttr
+	med         MEDPathAttr
+	communities CommunitiesPathAttr
+	large       LargeCommunitiesPathAttr
+	nlri        []netip.Prefix
+}
+
+func (u *arenaDecoded) DecodeArena() *DecodeArena {
+	return u.arena
+}
+
+func newArenaTestDecoder() *UpdateDecoder[*arenaDecoded] {
+	return NewUpdateDecoder[*arenaDecoded](
+		NewWithdrawnRoutesDecodeFn(func(u *arenaDecoded,
+			p []netip.Prefix) error {
+			u.withdrawn = p
+			return nil
+		}),
+		func(u *arenaDecoded, code uint8, flags PathAttrFlags,
+			b []byte) error {
+			switch code {
+			case PATH_ATTR_ORIGIN:
+				return u.origin.Decode(flags, b)
+			case PATH_ATTR_AS_PATH:
+				return u.asPath.DecodeIn(u.arena, flags, b)
+			case PATH_ATTR_NEXT_HOP:
+				return u.nextHop.Decode(flags, b)
+			case PATH_ATTR_MED:
+				return u.med.Decode(flags, b)
+			case PATH_ATTR_COMMUNITY:
+				return u.communities.DecodeIn(u.arena, flags, b)
+			case PATH_ATTR_LARGE_COMMUNITY:
+				return u.large.DecodeIn(u.arena, flags, b)
+			}
+			return nil
+		},
+		NewNLRIDecodeFn(func(u *arenaDecoded, p []netip.Prefix) error {
+			u.nlri = p
+			return nil
+		}),
+	)
+}
+
+func TestDecodeArena(t *testing.T) {
+	d := newArenaTestDecoder()
+	update := newIndexTestUpdate(20, 100)
+	heap := &arenaDecoded{}
+	if !assert.NoError(t, d.Decode(heap, update)) {
+		return
+	}
+	a := NewDecodeArena()
+	got := &arenaDecoded{arena: a}
+	if !assert.NoError(t, d.Decode(got, update)) {
+		return
+	}
+	got.arena = nil
+	assert.Equal(t, heap, got)
+	assert.Len(t, got.nlri, 100)
+	assert.Equal(t, 100, cap(got.nlri))
+
+	// appending to a slice allocated from the arena must not overwrite
+	// subsequent allocations
+	seq := append(got.asPath.ASSequence, 1)
+	assert.Equal(t, heap.communities, got.communities)
+	assert.Equal(t, uint32(1), seq[4])
+
+	// clones survive Reset and reuse of the arena
+	cloned := got.communities.Clone()
+	asPath := got.asPath.Clone()
+	a.Reset()
+	reused := &arenaDecoded{arena: a}
+	if !assert.NoError(t, d.Decode(reused, newIndexTestUpdate(1, 1))) {
+		return
+	}
+	assert.Equal(t, heap.communities, cloned)
+	assert.Equal(t, heap.asPath, asPath)
+	assert.Equal(t, &got.nlri[0], &reused.nlri[0], "memory is reused")
+
+	// allocations exceeding a chunk
+	nhs, err := a.DecodeMPReachIPv6NextHops(make([]byte, 32))
+	assert.NoError(t, err)
+	assert.Len(t, nhs, 2)
+	prefixes, err := a.DecodeMPIPv6Prefixes(make([]byte, minArenaChunk+1))
+	assert.NoError(t, err)
+	assert.Len(t, prefixes, minArenaChunk+1)
+	_, err = a.DecodeMPIPv6Prefixes([]byte{129})
+	assert.Error(t, err)
+	addPath, err := a.DecodeMPIPv6AddPathPrefixes([]byte{0, 0, 0, 1, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []AddPathPrefix{{
+		Prefix: netip.PrefixFrom(netip.IPv6Unspecified(), 0),
+		ID:     1,
+	}}, addPath)
+
+	var nilArena *DecodeArena
+	nilArena.Reset()
+	prefixes, err = nilArena.DecodeMPIPv6Prefixes([]byte{0})
+	assert.NoError(t, err)
+	assert.Len(t, prefixes, 1)
+}
+
+func TestNewArenaUpdateHandler(t *testing.T) {
+	d := newArenaTestDecoder()
+	var arena *DecodeArena
+	var decoded *arenaDecoded
+	h := NewArenaUpdateHandler(func(peer PeerConfig, updateMessage []byte,
+		a *DecodeArena) *Notification {
+		arena = a
+		decoded = &arenaDecoded{arena: a}
+		err := d.Decode(decoded, updateMessage)
+		assert.NoError(t, err)
+		return nil
+	})
+	assert.Nil(t, h(PeerConfig{}, newIndexTestUpdate(2, 2)))
+	if assert.NotNil(t, arena) {
+		assert.Empty(t, arena.prefixes, "arena is reset")
+	}
+	assert.Len(t, decoded.nlri, 2)
+}
+
+func BenchmarkDecodeArena(b *testing.B) {
+	update := newIndexTestUpdate(20, 250)
+	d := newArenaTestDecoder()
+	b.Run("heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var u arenaDecoded
+			err := d.Decode(&u, update)
+			if err != nil || len(u.nlri) != 250 {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("arena", func(b *testing.B) {
+		a := NewDecodeArena()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			u := arenaDecoded{arena: a}
+			err := d.Decode(&u, update)
+			if err != nil || len(u.nlri) != 250 {
+				b.Fatal(err)
+			}
+			a.Reset()
+		}
+	})
+	b.Run("arena handler", func(b *testing.B) {
+		h := NewArenaUpdateHandler(func(peer PeerConfig, updateMessage []byte,
+			a *DecodeArena) *Notification {
+			u := arenaDecoded{arena: a}
+			err := d.Decode(&u, updateMessage)
+			if err != nil || len(u.nlri) != 250 {
+				b.Fatal(err)
+			}
+			return nil
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h(PeerConfig{}, update)
+		}
+	})
+}
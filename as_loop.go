@@ -0,0 +1,122 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// DetectASLoop returns true if path contains asn more than allowASIn times.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.2
+// If the AS_PATH attribute of a BGP route contains an AS loop, the BGP route
+// should be excluded from the Phase 2 decision function.  AS loop detection
+// is done by scanning the full AS_PATH (as specified in the AS_PATH
+// attribute), and checking that the autonomous system number of the local
+// system does not appear in the AS path.
+func DetectASLoop(path ASPath, asn uint32, allowASIn int) bool {
+	return path.Count(asn) > allowASIn
+}
+
+// NewASLoopHandler returns an UpdateMessageHandler that detects AS loops in
+// UPDATE messages before passing them to handler. An UPDATE message whose
+// AS_PATH contains the LocalAS of the peer more than allowASIn times is
+// handled using the approach of "treat-as-withdraw": it is rewritten such
+// that its NLRI, including that of any MP_REACH_NLRI attribute, is withdrawn,
+// and all other path attributes are removed. UPDATE messages that cannot be
+// parsed are passed to handler unmodified.
+func NewASLoopHandler(allowASIn int,
+	handler UpdateMessageHandler) UpdateMessageHandler {
+	return func(peer PeerConfig, updateMessage []byte) *Notification {
+		withdraw, loop, err := asLoopWithdrawal(updateMessage, peer.LocalAS,
+			allowASIn)
+		if err != nil || !loop {
+			return handler(peer, updateMessage)
+		}
+		ReleaseUpdateBuffer(updateMessage)
+		if withdraw == nil {
+			return nil
+		}
+		return handler(peer, withdraw)
+	}
+}
+
+// asLoopWithdrawal returns true if the AS_PATH of the UPDATE message b
+// contains asn more than allowASIn times, along with a message withdrawing
+// the NLRI of b. The returned message is nil if b contains no NLRI.
+func asLoopWithdrawal(b []byte, asn uint32,
+	allowASIn int) ([]byte, bool, error) {
+	malformed := errors.New("malformed update message")
+	if len(b) < 4 {
+		return nil, false, malformed
+	}
+	wrl := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+wrl+2 {
+		return nil, false, malformed
+	}
+	withdrawn := b[2 : 2+wrl]
+	tpal := int(binary.BigEndian.Uint16(b[2+wrl:]))
+	if len(b) < 4+wrl+tpal {
+		return nil, false, malformed
+	}
+	attrs, nlri := b[4+wrl:4+wrl+tpal], b[4+wrl+tpal:]
+
+	var (
+		loop    bool
+		unreach []byte
+	)
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return nil, false, malformed
+		}
+		flags, code := PathAttrFlags(attrs[0]), attrs[1]
+		var attrLen int
+		if flags.ExtendedLen() {
+			if len(attrs) < 4 {
+				return nil, false, malformed
+			}
+			attrLen = int(binary.BigEndian.Uint16(attrs[2:]))
+			attrs = attrs[4:]
+		} else {
+			attrLen = int(attrs[2])
+			attrs = attrs[3:]
+		}
+		if len(attrs) < attrLen {
+			return nil, false, malformed
+		}
+		data := attrs[:attrLen]
+		attrs = attrs[attrLen:]
+		switch code {
+		case PATH_ATTR_AS_PATH:
+			path, err := DecodeASPath(data)
+			if err != nil {
+				return nil, false, err
+			}
+			loop = DetectASLoop(path, asn, allowASIn)
+		case PATH_ATTR_MP_UNREACH_NLRI:
+			unreach = AppendPathAttr(unreach, 0x80,
+				PATH_ATTR_MP_UNREACH_NLRI, data)
+		case PATH_ATTR_MP_REACH_NLRI:
+			// AFI (2), SAFI (1), next hop length (1), next hop, reserved (1)
+			if len(data) < 5 || len(data) < 5+int(data[3]) {
+				return nil, false, malformed
+			}
+			withdraw := make([]byte, 0, len(data))
+			withdraw = append(withdraw, data[:3]...)
+			withdraw = append(withdraw, data[5+int(data[3]):]...)
+			unreach = AppendPathAttr(unreach, 0x80,
+				PATH_ATTR_MP_UNREACH_NLRI, withdraw)
+		}
+	}
+	if !loop {
+		return nil, false, nil
+	}
+	if len(withdrawn) == 0 && len(nlri) == 0 && len(unreach) == 0 {
+		return nil, true, nil
+	}
+	out := make([]byte, 0, 4+len(withdrawn)+len(nlri)+len(unreach))
+	out = binary.BigEndian.AppendUint16(out, uint16(len(withdrawn)+len(nlri)))
+	out = append(out, withdrawn...)
+	out = append(out, nlri...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(unreach)))
+	return append(out, unreach...), true, nil
+}
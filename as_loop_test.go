@@ -0,0 +1,66 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewASLoopHandler(t *testing.T) {
+	asPath := ASPath{{Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{65001, 65000, 65002}}}.Encode()
+	mpReach := []byte{
+		0, 2, 1, // AFI IPv6, SAFI unicast
+		16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0,                          // reserved
+		32, 0x20, 0x01, 0x0d, 0xb8, // 2001:db8::/32
+	}
+	var attrs []byte
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_ORIGIN, []byte{0})
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_AS_PATH, asPath)
+	attrs = AppendPathAttr(attrs, 0x80, PATH_ATTR_MP_REACH_NLRI, mpReach)
+	update := []byte{0, 2, 8, 10} // withdrawn 10.0.0.0/8
+	update = append(update, byte(len(attrs)>>8), byte(len(attrs)))
+	update = append(update, attrs...)
+	update = append(update, 16, 192, 168) // 192.168.0.0/16
+
+	var got []byte
+	handler := func(peer PeerConfig, b []byte) *Notification {
+		got = b
+		return nil
+	}
+	peer := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.1"),
+		LocalAS:       65000,
+		RemoteAS:      65001,
+	}
+
+	// allowas-in permits a single occurrence of the local AS
+	n := NewASLoopHandler(1, handler)(peer, update)
+	assert.Nil(t, n)
+	assert.Equal(t, update, got)
+
+	n = NewASLoopHandler(0, handler)(peer, update)
+	assert.Nil(t, n)
+	want := []byte{0, 5, 8, 10, 16, 192, 168}
+	want = append(want, 0, 11, 0x80, PATH_ATTR_MP_UNREACH_NLRI, 8,
+		0, 2, 1, 32, 0x20, 0x01, 0x0d, 0xb8)
+	assert.Equal(t, want, got)
+	families, err := UpdateFamilies(got)
+	assert.NoError(t, err)
+	assert.Len(t, families, 2)
+
+	// malformed messages are passed through
+	got = nil
+	NewASLoopHandler(0, handler)(peer, []byte{0})
+	assert.Equal(t, []byte{0}, got)
+}
+
+func TestDetectASLoop(t *testing.T) {
+	path := ASPath{}.Prepend(65000, 65001, 65000)
+	assert.True(t, DetectASLoop(path, 65000, 0))
+	assert.True(t, DetectASLoop(path, 65000, 1))
+	assert.False(t, DetectASLoop(path, 65000, 2))
+	assert.False(t, DetectASLoop(path, 65002, 0))
+}
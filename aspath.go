@@ -0,0 +1,179 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// AS_PATH path segment types.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.3
+// https://www.rfc-editor.org/rfc/rfc5065#section-3
+const (
+	ASPathSegmentTypeSet            uint8 = 1
+	ASPathSegmentTypeSequence       uint8 = 2
+	ASPathSegmentTypeConfedSequence uint8 = 3
+	ASPathSegmentTypeConfedSet      uint8 = 4
+)
+
+const maxASPathSegmentLen = 255
+
+// ASPathSegment is a path segment of an AS_PATH attribute.
+type ASPathSegment struct {
+	Type uint8
+	ASNs []uint32
+}
+
+// ASPath is an AS_PATH attribute with four-octet AS numbers, preserving the
+// order and type of its path segments.
+type ASPath []ASPathSegment
+
+// DecodeASPath decodes the AS_PATH attribute data in b, which must contain
+// four-octet AS numbers.
+func DecodeASPath(b []byte) (ASPath, error) {
+	path := make(ASPath, 0, 1)
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errors.New("malformed AS_PATH segment header")
+		}
+		segType, segLen := b[0], int(b[1])
+		if segType < ASPathSegmentTypeSet ||
+			segType > ASPathSegmentTypeConfedSet {
+			return nil, errors.New("unrecognized AS_PATH segment type")
+		}
+		if segLen == 0 {
+			return nil, errors.New("zero length AS_PATH segment")
+		}
+		b = b[2:]
+		if len(b) < segLen*4 {
+			return nil, errors.New("AS_PATH segment overrun")
+		}
+		seg := ASPathSegment{Type: segType, ASNs: make([]uint32, segLen)}
+		for i := range seg.ASNs {
+			seg.ASNs[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+		path = append(path, seg)
+		b = b[segLen*4:]
+	}
+	return path, nil
+}
+
+// Encode returns the AS_PATH attribute data for a. Segments containing more
+// than 255 AS numbers are split.
+func (a ASPath) Encode() []byte {
+	b := make([]byte, 0)
+	for _, seg := range a {
+		asns := seg.ASNs
+		for len(asns) > 0 {
+			n := min(len(asns), maxASPathSegmentLen)
+			b = append(b, seg.Type, uint8(n))
+			for _, asn := range asns[:n] {
+				b = binary.BigEndian.AppendUint32(b, asn)
+			}
+			asns = asns[n:]
+		}
+	}
+	return b
+}
+
+// Prepend returns a copy of a with asns prepended, in order, as an
+// AS_SEQUENCE.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-5.1.2
+// if the first path segment of the AS_PATH is of type AS_SEQUENCE, the local
+// system prepends its own AS number as the last element of the sequence (put
+// it in the leftmost position with respect to the position of octets in the
+// protocol message).  If the act of prepending will cause an overflow in the
+// AS_PATH segment (i.e., more than 255 ASes), it SHOULD prepend a new segment
+// of type AS_SEQUENCE and prepend its own AS number to this new segment.
+//
+// if the first path segment of the AS_PATH is of type AS_SET, the local
+// system prepends a new path segment of type AS_SEQUENCE to the AS_PATH,
+// including its own AS number in that segment.
+func (a ASPath) Prepend(asns ...uint32) ASPath {
+	return a.prepend(ASPathSegmentTypeSequence, asns)
+}
+
+// PrependConfed returns a copy of a with asns prepended, in order, as an
+// AS_CONFED_SEQUENCE, see Confederation.
+func (a ASPath) PrependConfed(asns ...uint32) ASPath {
+	return a.prepend(ASPathSegmentTypeConfedSequence, asns)
+}
+
+// RemoveConfed returns a copy of a without AS_CONFED_SEQUENCE and
+// AS_CONFED_SET segments.
+func (a ASPath) RemoveConfed() ASPath {
+	out := make(ASPath, 0, len(a))
+	for _, seg := range a {
+		if seg.Type != ASPathSegmentTypeConfedSequence &&
+			seg.Type != ASPathSegmentTypeConfedSet {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+func (a ASPath) prepend(segType uint8, asns []uint32) ASPath {
+	out := make(ASPath, 0, len(a)+1)
+	if len(a) > 0 && a[0].Type == segType &&
+		len(a[0].ASNs)+len(asns) <= maxASPathSegmentLen {
+		first := ASPathSegment{
+			Type: segType,
+			ASNs: make([]uint32, 0, len(asns)+len(a[0].ASNs)),
+		}
+		first.ASNs = append(first.ASNs, asns...)
+		first.ASNs = append(first.ASNs, a[0].ASNs...)
+		out = append(out, first)
+		return append(out, a[1:]...)
+	}
+	if len(asns) > 0 {
+		out = append(out, ASPathSegment{
+			Type: segType,
+			ASNs: append([]uint32{}, asns...),
+		})
+	}
+	return append(out, a...)
+}
+
+// Count returns the number of times asn occurs in a.
+func (a ASPath) Count(asn uint32) int {
+	n := 0
+	for _, seg := range a {
+		for _, v := range seg.ASNs {
+			if v == asn {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Len returns the path length of a for the purpose of route selection.
+// AS_CONFED_SEQUENCE and AS_CONFED_SET segments are not counted, per RFC5065.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.2.2
+// Note that when counting this number, an AS_SET counts as 1, no matter how
+// many ASes are in the set.
+func (a ASPath) Len() int {
+	n := 0
+	for _, seg := range a {
+		switch seg.Type {
+		case ASPathSegmentTypeSequence:
+			n += len(seg.ASNs)
+		case ASPathSegmentTypeSet:
+			n++
+		}
+	}
+	return n
+}
+
+// Origin returns the origin AS of a, i.e. the last AS number of its final
+// AS_SEQUENCE segment, and true if it can be determined. The origin cannot be
+// determined if a is empty or ends with an AS_SET.
+func (a ASPath) Origin() (uint32, bool) {
+	if len(a) == 0 || a[len(a)-1].Type != ASPathSegmentTypeSequence {
+		return 0, false
+	}
+	asns := a[len(a)-1].ASNs
+	return asns[len(asns)-1], true
+}
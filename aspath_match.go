@@ -0,0 +1,156 @@
+package corebgp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// asPathDelimiter is the regular expression an underscore in an ASPathMatcher
+// expression is translated to.
+const asPathDelimiter = `(?:^|[ {}(),\[\]]|$)`
+
+// ASPathMatcher matches an ASPath against a compiled Cisco-style AS path
+// regular expression, e.g. ^64512_ for paths received from AS 64512, or
+// _64496$ for paths originated by AS 64496. Matching takes time linear in the
+// length of the path.
+//
+// The expression is matched against the textual form of the path, see
+// ASPathString, using the syntax of the regexp package, with an underscore
+// matching a delimiter between AS numbers or the start or end of the path.
+type ASPathMatcher struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+// CompileASPathMatcher compiles expr into an ASPathMatcher.
+func CompileASPathMatcher(expr string) (*ASPathMatcher, error) {
+	re, err := regexp.Compile(strings.ReplaceAll(expr, "_", asPathDelimiter))
+	if err != nil {
+		return nil, err
+	}
+	return &ASPathMatcher{
+		expr: expr,
+		re:   re,
+	}, nil
+}
+
+// MustCompileASPathMatcher is like CompileASPathMatcher but panics if expr
+// cannot be compiled.
+func MustCompileASPathMatcher(expr string) *ASPathMatcher {
+	m, err := CompileASPathMatcher(expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Match returns true if path matches m.
+func (m *ASPathMatcher) Match(path ASPath) bool {
+	return m.re.MatchString(ASPathString(path))
+}
+
+// String returns the expression m was compiled from.
+func (m *ASPathMatcher) String() string {
+	return m.expr
+}
+
+// ASPathString returns the textual form of path that ASPathMatchers are
+// matched against. AS numbers are space-separated and in asplain notation,
+// AS_SET segments are enclosed in braces, AS_CONFED_SEQUENCE segments in
+// parentheses, and AS_CONFED_SET segments in brackets, with members separated
+// by commas, e.g. "64512 (64513 64514) 64496 {64497,64498}".
+func ASPathString(path ASPath) string {
+	var b strings.Builder
+	for i, seg := range path {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		start, sep, end := "", " ", ""
+		switch seg.Type {
+		case ASPathSegmentTypeSet:
+			start, sep, end = "{", ",", "}"
+		case ASPathSegmentTypeConfedSequence:
+			start, end = "(", ")"
+		case ASPathSegmentTypeConfedSet:
+			start, sep, end = "[", ",", "]"
+		}
+		b.WriteString(start)
+		for j, asn := range seg.ASNs {
+			if j > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(strconv.FormatUint(uint64(asn), 10))
+		}
+		b.WriteString(end)
+	}
+	return b.String()
+}
+
+// ASPathSet is a set of ASPathMatchers, e.g. an AS path access list generated
+// from IRR data. A path matches the set if it matches any of its members.
+type ASPathSet []*ASPathMatcher
+
+// Match returns true if path matches a member of s.
+func (s ASPathSet) Match(path ASPath) bool {
+	if len(s) == 0 {
+		return false
+	}
+	str := ASPathString(path)
+	for _, m := range s {
+		if m.re.MatchString(str) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseASPathSet parses an ASPathSet from r, which contains one expression
+// per line, see ASPathMatcher. Blank lines and comments, i.e. lines beginning
+// with # or !, are ignored, as are the leading "ip as-path access-list NAME
+// permit" words of Cisco-style AS path access list entries and the "no ip
+// as-path access-list" statements preceding them, e.g. as output by bgpq4 -f. Access list entries with a deny action result in an error. Errors
+// include the offending line number.
+func ParseASPathSet(r io.Reader) (ASPathSet, error) {
+	s := make(ASPathSet, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' || text[0] == '!' ||
+			strings.HasPrefix(text, "no ip as-path ") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) >= 2 && fields[0] == "ip" &&
+			fields[1] == "as-path" {
+			if len(fields) < 6 || fields[2] != "access-list" {
+				return nil, fmt.Errorf("line %d: malformed access list entry",
+					line)
+			}
+			switch fields[4] {
+			case "permit":
+			case "deny":
+				return nil, fmt.Errorf("line %d: deny entries are not supported",
+					line)
+			default:
+				return nil, fmt.Errorf("line %d: unexpected %q", line,
+					fields[4])
+			}
+			text = strings.Join(fields[5:], " ")
+		}
+		m, err := CompileASPathMatcher(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		s = append(s, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,77 @@
+package corebgp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASPathString(t *testing.T) {
+	assert.Equal(t, "64512 (64513 64514) 64496 {64497,64498} [64499,64500]",
+		ASPathString(ASPath{
+			{Type: ASPathSegmentTypeSequence, ASNs: []uint32{64512}},
+			{Type: ASPathSegmentTypeConfedSequence, ASNs: []uint32{64513, 64514}},
+			{Type: ASPathSegmentTypeSequence, ASNs: []uint32{64496}},
+			{Type: ASPathSegmentTypeSet, ASNs: []uint32{64497, 64498}},
+			{Type: ASPathSegmentTypeConfedSet, ASNs: []uint32{64499, 64500}},
+		}))
+	assert.Equal(t, "", ASPathString(nil))
+}
+
+func TestASPathMatcher(t *testing.T) {
+	seq := func(asns ...uint32) ASPath {
+		return ASPath{{Type: ASPathSegmentTypeSequence, ASNs: asns}}
+	}
+	for _, tt := range []struct {
+		expr string
+		path ASPath
+		want bool
+	}{
+		{"^64512_", seq(64512, 64496), true},
+		{"^64512_", seq(645120, 64496), false},
+		{"_64496$", seq(64512, 64496), true},
+		{"_64496$", seq(64512, 164496), false},
+		{"_64496_", seq(64512, 64496, 64497), true},
+		{"^$", nil, true},
+		{"^$", seq(64512), false},
+		{"^64512(_[0-9]+)*_(64496|64497)$", seq(64512, 64499, 64497), true},
+		{"^64512(_[0-9]+)*_(64496|64497)$", seq(64512, 64499), false},
+		{"_64497_", ASPath{
+			{Type: ASPathSegmentTypeSequence, ASNs: []uint32{64512}},
+			{Type: ASPathSegmentTypeSet, ASNs: []uint32{64496, 64497}},
+		}, true},
+	} {
+		m := MustCompileASPathMatcher(tt.expr)
+		assert.Equal(t, tt.want, m.Match(tt.path), "%s %s", tt.expr,
+			ASPathString(tt.path))
+		assert.Equal(t, tt.expr, m.String())
+	}
+	_, err := CompileASPathMatcher("(")
+	assert.Error(t, err)
+}
+
+func TestParseASPathSet(t *testing.T) {
+	s, err := ParseASPathSet(strings.NewReader(`no ip as-path access-list NN
+ip as-path access-list NN permit ^64512(_64512)*$
+ip as-path access-list NN permit ^64512(_[0-9]+)*_(64496|64497)$
+! comment
+_64499$
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, s, 3)
+	seq := func(asns ...uint32) ASPath {
+		return ASPath{{Type: ASPathSegmentTypeSequence, ASNs: asns}}
+	}
+	assert.True(t, s.Match(seq(64512, 64512)))
+	assert.True(t, s.Match(seq(64512, 64496)))
+	assert.True(t, s.Match(seq(64511, 64499)))
+	assert.False(t, s.Match(seq(64511, 64496)))
+	assert.False(t, ASPathSet{}.Match(seq(64512)))
+
+	_, err = ParseASPathSet(strings.NewReader(
+		"ip as-path access-list NN deny _64496_\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
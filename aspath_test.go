@@ -0,0 +1,60 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASPath(t *testing.T) {
+	b := []byte{
+		2, 2, 0, 0, 0, 1, 0, 0, 0, 2, // AS_SEQUENCE 1 2
+		1, 2, 0, 0, 0, 3, 0, 0, 0, 4, // AS_SET {3 4}
+	}
+	path, err := DecodeASPath(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ASPath{
+		{Type: ASPathSegmentTypeSequence, ASNs: []uint32{1, 2}},
+		{Type: ASPathSegmentTypeSet, ASNs: []uint32{3, 4}},
+	}, path)
+	assert.Equal(t, b, path.Encode())
+	assert.Equal(t, 3, path.Len())
+	_, ok := path.Origin()
+	assert.False(t, ok)
+
+	prepended := path.Prepend(5)
+	assert.Equal(t, []uint32{5, 1, 2}, prepended[0].ASNs)
+	assert.Equal(t, []uint32{1, 2}, path[0].ASNs)
+	assert.Equal(t, 1, prepended.Count(5))
+
+	// a new segment is prepended to an AS_SET and on overflow
+	assert.Len(t, path[1:].Prepend(5), 2)
+	long := ASPath{{Type: ASPathSegmentTypeSequence,
+		ASNs: make([]uint32, maxASPathSegmentLen)}}
+	assert.Len(t, long.Prepend(5), 2)
+
+	origin, ok := ASPath{}.Prepend(1, 2).Origin()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), origin)
+
+	_, err = DecodeASPath([]byte{2, 0})
+	assert.Error(t, err)
+	_, err = DecodeASPath([]byte{2, 1, 0, 0})
+	assert.Error(t, err)
+	_, err = DecodeASPath([]byte{5, 1, 0, 0, 0, 1})
+	assert.Error(t, err)
+}
+
+func TestLocalAS(t *testing.T) {
+	path := ASPath{}.Prepend(64500)
+	l := LocalAS{GlobalAS: 65000, LocalAS: 65001}
+	assert.Equal(t, ASPath{}.Prepend(65001, 64500), l.Inbound(path))
+	assert.Equal(t, ASPath{}.Prepend(65001, 65000, 64500), l.Outbound(path))
+
+	l.NoPrepend = true
+	l.ReplaceAS = true
+	assert.Equal(t, path, l.Inbound(path))
+	assert.Equal(t, ASPath{}.Prepend(65001, 64500), l.Outbound(path))
+}
@@ -0,0 +1,347 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// BGPsec is the value of a BGPsec Capability. A BGPsec speaker advertises
+// one capability per AFI and direction.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-2.1
+type BGPsec struct {
+	// Version is the version of BGPsec, currently 0.
+	Version uint8
+	// Send is true if the speaker is capable of sending BGPsec update
+	// messages, and false if it is capable of receiving them.
+	Send bool
+	AFI  uint16
+}
+
+// Decode decodes the BGPsec Capability value in b.
+func (c *BGPsec) Decode(b []byte) error {
+	if len(b) != 3 {
+		return &Notification{
+			Code: NOTIF_CODE_OPEN_MESSAGE_ERR,
+		}
+	}
+	c.Version = b[0] >> 4
+	c.Send = b[0]&0x08 != 0
+	c.AFI = binary.BigEndian.Uint16(b[1:])
+	return nil
+}
+
+// Encode encodes c as a BGPsec Capability value.
+func (c *BGPsec) Encode() []byte {
+	b := []byte{c.Version << 4, 0, 0}
+	if c.Send {
+		b[0] |= 0x08
+	}
+	binary.BigEndian.PutUint16(b[1:], c.AFI)
+	return b
+}
+
+// NewBGPsecCapability returns a BGPsec Capability for the provided BGPsec.
+func NewBGPsecCapability(c BGPsec) Capability {
+	return Capability{
+		Code:  CAP_BGPSEC,
+		Value: c.Encode(),
+	}
+}
+
+// SecurePathSegment is a Secure_Path Segment of a BGPsec_PATH attribute.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-3.1
+type SecurePathSegment struct {
+	// PCount is the number of repetitions of AS, 1 unless the AS prepends
+	// itself, or 0 for a transparent route server.
+	PCount uint8
+	// ConfedSegment is true if the segment was added by an AS that is a
+	// member of the confederation the update is propagated within.
+	ConfedSegment bool
+	AS            uint32
+}
+
+const (
+	securePathSegmentLen = 6
+	bgpsecSKILen         = 20
+)
+
+func (s SecurePathSegment) appendTo(b []byte) []byte {
+	var flags uint8
+	if s.ConfedSegment {
+		flags |= 0x80
+	}
+	b = append(b, s.PCount, flags)
+	return binary.BigEndian.AppendUint32(b, s.AS)
+}
+
+// SignatureSegment is a Signature Segment of a BGPsec_PATH attribute.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-3.2
+type SignatureSegment struct {
+	// SKI is the Subject Key Identifier of the router certificate whose key
+	// produced Signature.
+	SKI       [bgpsecSKILen]byte
+	Signature []byte
+}
+
+func (s SignatureSegment) appendTo(b []byte) []byte {
+	b = append(b, s.SKI[:]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s.Signature)))
+	return append(b, s.Signature...)
+}
+
+// SignatureBlock is a Signature_Block of a BGPsec_PATH attribute, containing
+// one SignatureSegment per SecurePathSegment, all produced with the same
+// algorithm suite.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-3.2
+type SignatureBlock struct {
+	AlgorithmSuite uint8
+	Segments       []SignatureSegment
+}
+
+// BGPsecPathAttr is a BGPsec_PATH attribute. SecurePath and the Segments of
+// each of SignatureBlocks are ordered from the most recently added to the
+// first, i.e. the origin AS is last.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-3
+type BGPsecPathAttr struct {
+	SecurePath      []SecurePathSegment
+	SignatureBlocks []SignatureBlock
+}
+
+func bgpsecMalformedErr(b []byte) error {
+	// syntactic errors in the BGPsec_PATH attribute are handled using the
+	// "treat-as-withdraw" approach, see RFC8205 section 5.2
+	return &TreatAsWithdrawUpdateErr{
+		Code: PATH_ATTR_BGPSEC_PATH,
+		Notification: &Notification{
+			Code:    NOTIF_CODE_UPDATE_MESSAGE_ERR,
+			Subcode: NOTIF_SUBCODE_OPTIONAL_ATTR_ERR,
+			Data:    notifDataForAttrBasedErr(PATH_ATTR_BGPSEC_PATH, b),
+		},
+	}
+}
+
+// Decode decodes the BGPsec_PATH attribute data in b.
+func (p *BGPsecPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	err := flags.Validate(PATH_ATTR_BGPSEC_PATH, b, true, false)
+	if err != nil {
+		return err
+	}
+	attr := b
+	if len(b) < 2 {
+		return bgpsecMalformedErr(attr)
+	}
+	// the Secure_Path Length includes the length field itself
+	l := int(binary.BigEndian.Uint16(b))
+	if l < 2+securePathSegmentLen || l > len(b) ||
+		(l-2)%securePathSegmentLen != 0 {
+		return bgpsecMalformedErr(attr)
+	}
+	path := make([]SecurePathSegment, 0, (l-2)/securePathSegmentLen)
+	for s := b[2:l]; len(s) > 0; s = s[securePathSegmentLen:] {
+		path = append(path, SecurePathSegment{
+			PCount:        s[0],
+			ConfedSegment: s[1]&0x80 != 0,
+			AS:            binary.BigEndian.Uint32(s[2:]),
+		})
+	}
+	b = b[l:]
+	blocks := make([]SignatureBlock, 0, 1)
+	for len(b) > 0 {
+		if len(b) < 3 || len(blocks) == 2 {
+			return bgpsecMalformedErr(attr)
+		}
+		// the Signature_Block Length includes the length field itself
+		l = int(binary.BigEndian.Uint16(b))
+		if l < 3 || l > len(b) {
+			return bgpsecMalformedErr(attr)
+		}
+		block := SignatureBlock{
+			AlgorithmSuite: b[2],
+			Segments:       make([]SignatureSegment, 0, len(path)),
+		}
+		for s := b[3:l]; len(s) > 0; {
+			if len(s) < bgpsecSKILen+2 {
+				return bgpsecMalformedErr(attr)
+			}
+			var seg SignatureSegment
+			copy(seg.SKI[:], s)
+			sigLen := int(binary.BigEndian.Uint16(s[bgpsecSKILen:]))
+			s = s[bgpsecSKILen+2:]
+			if sigLen == 0 || sigLen > len(s) {
+				return bgpsecMalformedErr(attr)
+			}
+			seg.Signature = s[:sigLen]
+			s = s[sigLen:]
+			block.Segments = append(block.Segments, seg)
+		}
+		// each Signature_Block must contain one Signature Segment per
+		// Secure_Path Segment, and two Signature_Blocks must not use the same
+		// algorithm suite
+		if len(block.Segments) != len(path) ||
+			(len(blocks) == 1 &&
+				blocks[0].AlgorithmSuite == block.AlgorithmSuite) {
+			return bgpsecMalformedErr(attr)
+		}
+		blocks = append(blocks, block)
+		b = b[l:]
+	}
+	if len(blocks) == 0 {
+		return bgpsecMalformedErr(attr)
+	}
+	p.SecurePath = path
+	p.SignatureBlocks = blocks
+	return nil
+}
+
+// Encode returns the BGPsec_PATH attribute data for p. The attribute is
+// optional and non-transitive, see AppendPathAttr.
+func (p *BGPsecPathAttr) Encode() []byte {
+	b := binary.BigEndian.AppendUint16(nil,
+		uint16(2+len(p.SecurePath)*securePathSegmentLen))
+	for _, s := range p.SecurePath {
+		b = s.appendTo(b)
+	}
+	for _, block := range p.SignatureBlocks {
+		off := len(b)
+		b = append(b, 0, 0, block.AlgorithmSuite)
+		for _, s := range block.Segments {
+			b = s.appendTo(b)
+		}
+		binary.BigEndian.PutUint16(b[off:], uint16(len(b)-off))
+	}
+	return b
+}
+
+// BGPsecAlgorithmSuite1 is the algorithm suite identifier of ECDSA P-256 with
+// SHA-256.
+//
+// https://www.rfc-editor.org/rfc/rfc8208#section-2
+const BGPsecAlgorithmSuite1 uint8 = 1
+
+// BGPsecSigner is a signing backend for BGPsec, e.g. an HSM holding the
+// private key of a router certificate.
+type BGPsecSigner interface {
+	// Sign signs data with the algorithm suite identified by suite, returning
+	// the signature along with the SKI of the router certificate whose key
+	// produced it.
+	Sign(suite uint8, data []byte) (SignatureSegment, error)
+}
+
+// BGPsecVerifier is a validation backend for BGPsec, typically backed by the
+// router keys published in the RPKI.
+type BGPsecVerifier interface {
+	// Verify returns nil if signature is a valid signature of data by the
+	// router key identified by ski with the algorithm suite identified by
+	// suite. It returns ErrBGPsecUnsupportedSuite if suite is not supported.
+	Verify(suite uint8, ski [20]byte, data, signature []byte) error
+}
+
+// ErrBGPsecUnsupportedSuite may be returned by a BGPsecVerifier for an
+// algorithm suite it does not support.
+var ErrBGPsecUnsupportedSuite = errors.New("unsupported BGPsec algorithm suite")
+
+// bgpsecSignedData returns the data signed by the i'th Signature Segment of
+// block, which is the signature of the AS in the i'th SecurePathSegment
+// towards targetAS.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-4.2
+func bgpsecSignedData(path []SecurePathSegment, block SignatureBlock, i int,
+	targetAS uint32, safi uint8, prefix netip.Prefix) []byte {
+	b := binary.BigEndian.AppendUint32(nil, targetAS)
+	for j := i; j < len(path)-1; j++ {
+		b = block.Segments[j+1].appendTo(b)
+		b = path[j].appendTo(b)
+	}
+	b = path[len(path)-1].appendTo(b)
+	afi := AFI_IPV4
+	if prefix.Addr().Is6() {
+		afi = AFI_IPV6
+	}
+	b = append(b, block.AlgorithmSuite)
+	b = binary.BigEndian.AppendUint16(b, afi)
+	b = append(b, safi, uint8(prefix.Bits()))
+	return append(b, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+}
+
+// Sign adds seg, the Secure_Path Segment of the local AS, to p along with a
+// signature by signer towards targetAS, the AS of the peer the update is to be
+// sent to, in each of p's SignatureBlocks. prefix and safi are the single
+// prefix of the update and its SAFI. To originate a path p must have no
+// SecurePath and one or two SignatureBlocks without Segments, identifying the
+// algorithm suites to sign with.
+//
+// https://www.rfc-editor.org/rfc/rfc8205#section-4.2
+func (p *BGPsecPathAttr) Sign(signer BGPsecSigner, seg SecurePathSegment,
+	targetAS uint32, safi uint8, prefix netip.Prefix) error {
+	if len(p.SignatureBlocks) == 0 {
+		return errors.New("no signature blocks")
+	}
+	path := append([]SecurePathSegment{seg}, p.SecurePath...)
+	blocks := make([]SignatureBlock, 0, len(p.SignatureBlocks))
+	for _, block := range p.SignatureBlocks {
+		if len(block.Segments) != len(p.SecurePath) {
+			return errors.New("signature block length mismatch")
+		}
+		block.Segments = append([]SignatureSegment{{}}, block.Segments...)
+		sig, err := signer.Sign(block.AlgorithmSuite, bgpsecSignedData(path,
+			block, 0, targetAS, safi, prefix))
+		if err != nil {
+			return err
+		}
+		block.Segments[0] = sig
+		blocks = append(blocks, block)
+	}
+	p.SecurePath = path
+	p.SignatureBlocks = blocks
+	return nil
+}
+
+// Verify verifies the signatures of p using verifier. localAS is the AS of
+// the receiving speaker, the target of the most recent signature. prefix and
+// safi are the single prefix of the update and its SAFI.
+//
+// As per RFC8205 section 5.2 p is valid if all the signatures of one of its
+// SignatureBlocks with a supported algorithm suite are valid.
+// ErrBGPsecUnsupportedSuite is returned if no SignatureBlock has a supported
+// algorithm suite.
+func (p *BGPsecPathAttr) Verify(verifier BGPsecVerifier, localAS uint32,
+	safi uint8, prefix netip.Prefix) error {
+	err := ErrBGPsecUnsupportedSuite
+	for _, block := range p.SignatureBlocks {
+		if len(block.Segments) != len(p.SecurePath) {
+			return errors.New("signature block length mismatch")
+		}
+		blockErr := p.verifyBlock(verifier, block, localAS, safi, prefix)
+		if blockErr == nil {
+			return nil
+		}
+		if !errors.Is(blockErr, ErrBGPsecUnsupportedSuite) {
+			err = blockErr
+		}
+	}
+	return err
+}
+
+func (p *BGPsecPathAttr) verifyBlock(verifier BGPsecVerifier,
+	block SignatureBlock, localAS uint32, safi uint8,
+	prefix netip.Prefix) error {
+	targetAS := localAS
+	for i, seg := range block.Segments {
+		err := verifier.Verify(block.AlgorithmSuite, seg.SKI,
+			bgpsecSignedData(p.SecurePath, block, i, targetAS, safi, prefix),
+			seg.Signature)
+		if err != nil {
+			return fmt.Errorf("signature of AS %d: %w", p.SecurePath[i].AS,
+				err)
+		}
+		targetAS = p.SecurePath[i].AS
+	}
+	return nil
+}
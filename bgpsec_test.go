@@ -0,0 +1,143 @@
+package corebgp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBGPsecCapability(t *testing.T) {
+	c := BGPsec{Send: true, AFI: AFI_IPV6}
+	capability := NewBGPsecCapability(c)
+	assert.Equal(t, []byte{0x08, 0, 2}, capability.Value)
+	var got BGPsec
+	assert.NoError(t, got.Decode(capability.Value))
+	assert.Equal(t, c, got)
+	assert.Error(t, got.Decode([]byte{0, 0}))
+}
+
+// ecdsaBGPsec implements BGPsecSigner and BGPsecVerifier for algorithm suite
+// 1 with a key per AS.
+type ecdsaBGPsec struct {
+	keys map[[20]byte]*ecdsa.PrivateKey
+	// signer is the SKI used by Sign
+	signer [20]byte
+}
+
+func (e *ecdsaBGPsec) Sign(suite uint8, data []byte) (SignatureSegment,
+	error) {
+	if suite != BGPsecAlgorithmSuite1 {
+		return SignatureSegment{}, ErrBGPsecUnsupportedSuite
+	}
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, e.keys[e.signer], digest[:])
+	return SignatureSegment{SKI: e.signer, Signature: sig}, err
+}
+
+func (e *ecdsaBGPsec) Verify(suite uint8, ski [20]byte, data,
+	signature []byte) error {
+	if suite != BGPsecAlgorithmSuite1 {
+		return ErrBGPsecUnsupportedSuite
+	}
+	key, ok := e.keys[ski]
+	if !ok {
+		return errors.New("unknown SKI")
+	}
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func TestBGPsecPathAttr(t *testing.T) {
+	backend := &ecdsaBGPsec{keys: make(map[[20]byte]*ecdsa.PrivateKey)}
+	for i := 1; i <= 3; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if !assert.NoError(t, err) {
+			return
+		}
+		backend.keys[[20]byte{byte(i)}] = key
+	}
+	prefix := netip.MustParsePrefix("192.0.2.0/24")
+
+	// AS 64501 originates towards AS 64502, which propagates to AS 64503
+	p := &BGPsecPathAttr{
+		SignatureBlocks: []SignatureBlock{{
+			AlgorithmSuite: BGPsecAlgorithmSuite1,
+		}},
+	}
+	for i, target := range []uint32{64502, 64503} {
+		backend.signer = [20]byte{byte(i + 1)}
+		err := p.Sign(backend, SecurePathSegment{
+			PCount: 1,
+			AS:     uint32(64501 + i),
+		}, target, SAFI_UNICAST, prefix)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	assert.Equal(t, []SecurePathSegment{{PCount: 1, AS: 64502},
+		{PCount: 1, AS: 64501}}, p.SecurePath)
+	assert.NoError(t, p.Verify(backend, 64503, SAFI_UNICAST, prefix))
+	assert.Error(t, p.Verify(backend, 64504, SAFI_UNICAST, prefix))
+	assert.Error(t, p.Verify(backend, 64503, SAFI_UNICAST,
+		netip.MustParsePrefix("192.0.2.0/25")))
+
+	var decoded BGPsecPathAttr
+	flags := PathAttrFlags(0x80 | 0x10)
+	if assert.NoError(t, decoded.Decode(flags, p.Encode())) {
+		assert.Equal(t, *p, decoded)
+		assert.NoError(t, decoded.Verify(backend, 64503, SAFI_UNICAST,
+			prefix))
+	}
+
+	// tampering with the path invalidates it
+	decoded.SecurePath[1].AS = 64510
+	assert.Error(t, decoded.Verify(backend, 64503, SAFI_UNICAST, prefix))
+
+	unsupported := BGPsecPathAttr{
+		SecurePath: p.SecurePath,
+		SignatureBlocks: []SignatureBlock{{
+			AlgorithmSuite: 2,
+			Segments:       p.SignatureBlocks[0].Segments,
+		}},
+	}
+	assert.ErrorIs(t, unsupported.Verify(backend, 64503, SAFI_UNICAST,
+		prefix), ErrBGPsecUnsupportedSuite)
+}
+
+func TestBGPsecPathAttr_DecodeMalformed(t *testing.T) {
+	valid := (&BGPsecPathAttr{
+		SecurePath: []SecurePathSegment{{PCount: 1, AS: 64501}},
+		SignatureBlocks: []SignatureBlock{{
+			AlgorithmSuite: 1,
+			Segments:       []SignatureSegment{{Signature: []byte{1}}},
+		}},
+	}).Encode()
+	flags := PathAttrFlags(0x80)
+	var p BGPsecPathAttr
+	assert.NoError(t, p.Decode(flags, valid))
+
+	twoBlocks := append(append([]byte{}, valid...), valid[8:]...)
+	for _, b := range [][]byte{
+		nil,
+		valid[:8],
+		valid[:len(valid)-1],
+		// duplicate algorithm suite
+		twoBlocks,
+	} {
+		err := p.Decode(flags, b)
+		var twErr *TreatAsWithdrawUpdateErr
+		assert.True(t, errors.As(err, &twErr), "%x", b)
+	}
+	// transitive flag
+	var twErr *TreatAsWithdrawUpdateErr
+	assert.True(t, errors.As(p.Decode(flags|0x40, valid), &twErr))
+}
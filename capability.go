@@ -0,0 +1,263 @@
+package corebgp
+
+import "encoding/binary"
+
+// CapabilityPolicy is evaluated against the capabilities contained in an OPEN
+// message received from a peer. local contains the capabilities that were
+// advertised to the peer, including the implicit four-octet AS number space
+// capability, and remote contains the capabilities received from the peer.
+//
+// A CapabilityPolicy is fired after corebgp has validated the OPEN message and
+// before Plugin.OnOpenMessage. Returning a non-nil Notification will cause it
+// to be sent to the peer and the FSM will transition to the Idle state.
+// Returning nil allows negotiation to continue, in which case the Plugin may
+// gracefully downgrade to the capabilities supported by both sides.
+type CapabilityPolicy func(peer PeerConfig, local, remote []Capability) *Notification
+
+// RequireCapabilities returns a CapabilityPolicy that requires the remote peer
+// to advertise every capability in required. A required Capability with a nil
+// Value is satisfied by any remote capability with the same Code, otherwise
+// the remote peer must advertise an equal Capability. If any required
+// capabilities are missing an Unsupported Capability Notification listing them
+// is returned.
+func RequireCapabilities(required ...Capability) CapabilityPolicy {
+	return func(peer PeerConfig, local, remote []Capability) *Notification {
+		missing := make([]Capability, 0)
+		for _, r := range required {
+			found := false
+			for _, c := range remote {
+				if (r.Value == nil && r.Code == c.Code) || r.Equal(c) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, r)
+			}
+		}
+		if len(missing) > 0 {
+			return NewUnsupportedCapabilityNotification(missing...)
+		}
+		return nil
+	}
+}
+
+// NewUnsupportedCapabilityNotification returns a Notification with the Error
+// Subcode Unsupported Capability, listing caps in the Data field.
+//
+// https://www.rfc-editor.org/rfc/rfc5492.html#section-5
+// The Data field in the NOTIFICATION message MUST list the set of
+// capabilities that causes the speaker to send the message.  Each such
+// capability is encoded in the same way as it would be encoded in the OPEN
+// message.
+func NewUnsupportedCapabilityNotification(caps ...Capability) *Notification {
+	data := make([]byte, 0)
+	for _, c := range caps {
+		data = append(data, c.encode()...)
+	}
+	return newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR,
+		NOTIF_SUBCODE_UNSUPPORTED_CAPABILITY, data)
+}
+
+// MPExtensions is the value of a Multiprotocol Extensions Capability.
+//
+// https://www.rfc-editor.org/rfc/rfc4760#section-8
+type MPExtensions struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+// Decode decodes the Multiprotocol Extensions Capability value in b.
+func (m *MPExtensions) Decode(b []byte) error {
+	if len(b) != 4 {
+		return &Notification{
+			Code: NOTIF_CODE_OPEN_MESSAGE_ERR,
+		}
+	}
+	m.AFI = binary.BigEndian.Uint16(b)
+	m.SAFI = b[3]
+	return nil
+}
+
+// Encode encodes m as a Multiprotocol Extensions Capability value.
+func (m *MPExtensions) Encode() []byte {
+	return NewMPExtensionsCapability(m.AFI, m.SAFI).Value
+}
+
+// GracefulRestartFamily is an AFI/SAFI tuple contained in a Graceful Restart
+// Capability.
+type GracefulRestartFamily struct {
+	AFI  uint16
+	SAFI uint8
+	// ForwardingPreserved is true if forwarding state has been preserved for
+	// the AFI/SAFI across the previous restart.
+	ForwardingPreserved bool
+}
+
+// GracefulRestart is the value of a Graceful Restart Capability.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-3
+type GracefulRestart struct {
+	// Restarting is true if the Restart State (R) bit is set.
+	Restarting bool
+	// Notification is true if the Graceful Notification (N) bit is set.
+	//
+	// https://www.rfc-editor.org/rfc/rfc8538#section-2
+	Notification bool
+	// RestartTime is the estimated time in seconds it will take for the BGP
+	// session to be re-established after a restart. It is a 12 bit value.
+	RestartTime uint16
+	Families    []GracefulRestartFamily
+}
+
+// Decode decodes the Graceful Restart Capability value in b.
+func (g *GracefulRestart) Decode(b []byte) error {
+	if len(b) < 2 || (len(b)-2)%4 != 0 {
+		return &Notification{
+			Code: NOTIF_CODE_OPEN_MESSAGE_ERR,
+		}
+	}
+	g.Restarting = b[0]&0x80 != 0
+	g.Notification = b[0]&0x40 != 0
+	g.RestartTime = binary.BigEndian.Uint16(b) & 0x0FFF
+	b = b[2:]
+	g.Families = make([]GracefulRestartFamily, 0, len(b)/4)
+	for len(b) > 0 {
+		g.Families = append(g.Families, GracefulRestartFamily{
+			AFI:                 binary.BigEndian.Uint16(b),
+			SAFI:                b[2],
+			ForwardingPreserved: b[3]&0x80 != 0,
+		})
+		b = b[4:]
+	}
+	return nil
+}
+
+// Encode encodes g as a Graceful Restart Capability value.
+func (g *GracefulRestart) Encode() []byte {
+	b := make([]byte, 2, 2+4*len(g.Families))
+	binary.BigEndian.PutUint16(b, g.RestartTime&0x0FFF)
+	if g.Restarting {
+		b[0] |= 0x80
+	}
+	if g.Notification {
+		b[0] |= 0x40
+	}
+	for _, f := range g.Families {
+		fb := make([]byte, 4)
+		binary.BigEndian.PutUint16(fb, f.AFI)
+		fb[2] = f.SAFI
+		if f.ForwardingPreserved {
+			fb[3] = 0x80
+		}
+		b = append(b, fb...)
+	}
+	return b
+}
+
+// NewGracefulRestartCapability returns a Graceful Restart Capability for the
+// provided GracefulRestart.
+func NewGracefulRestartCapability(g GracefulRestart) Capability {
+	return Capability{
+		Code:  CAP_GRACEFUL_RESTART,
+		Value: g.Encode(),
+	}
+}
+
+// Role is the value of a BGP Role Capability.
+//
+// https://www.rfc-editor.org/rfc/rfc9234#section-4.1
+type Role uint8
+
+const (
+	RoleProvider Role = 0
+	RoleRS       Role = 1
+	RoleRSClient Role = 2
+	RoleCustomer Role = 3
+	RolePeer     Role = 4
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleProvider:
+		return "provider"
+	case RoleRS:
+		return "rs"
+	case RoleRSClient:
+		return "rs-client"
+	case RoleCustomer:
+		return "customer"
+	case RolePeer:
+		return "peer"
+	default:
+		return "unknown"
+	}
+}
+
+// Decode decodes the BGP Role Capability value in b.
+func (r *Role) Decode(b []byte) error {
+	// https://www.rfc-editor.org/rfc/rfc9234#section-4.2
+	// If the BGP Role Capability is received with a length other than 1, the
+	// capability MUST be treated as malformed.
+	if len(b) != 1 {
+		return &Notification{
+			Code: NOTIF_CODE_OPEN_MESSAGE_ERR,
+		}
+	}
+	*r = Role(b[0])
+	return nil
+}
+
+// NewRoleCapability returns a BGP Role Capability for the provided Role.
+func NewRoleCapability(r Role) Capability {
+	return Capability{
+		Code:  CAP_ROLE,
+		Value: []byte{uint8(r)},
+	}
+}
+
+// ExtendedNextHop is a tuple contained in an Extended Next Hop Encoding
+// Capability, indicating that NLRI of NLRIAFI/NLRISAFI may be advertised with
+// a next hop of NextHopAFI.
+//
+// https://www.rfc-editor.org/rfc/rfc8950#section-3
+type ExtendedNextHop struct {
+	NLRIAFI    uint16
+	NLRISAFI   uint16
+	NextHopAFI uint16
+}
+
+// DecodeExtendedNextHops decodes the Extended Next Hop Encoding Capability
+// value in b.
+func DecodeExtendedNextHops(b []byte) ([]ExtendedNextHop, error) {
+	if len(b) == 0 || len(b)%6 != 0 {
+		return nil, &Notification{
+			Code: NOTIF_CODE_OPEN_MESSAGE_ERR,
+		}
+	}
+	tuples := make([]ExtendedNextHop, 0, len(b)/6)
+	for len(b) > 0 {
+		tuples = append(tuples, ExtendedNextHop{
+			NLRIAFI:    binary.BigEndian.Uint16(b),
+			NLRISAFI:   binary.BigEndian.Uint16(b[2:]),
+			NextHopAFI: binary.BigEndian.Uint16(b[4:]),
+		})
+		b = b[6:]
+	}
+	return tuples, nil
+}
+
+// NewExtendedNextHopCapability returns an Extended Next Hop Encoding
+// Capability for the provided tuples.
+func NewExtendedNextHopCapability(tuples []ExtendedNextHop) Capability {
+	value := make([]byte, 6*len(tuples))
+	for i, t := range tuples {
+		binary.BigEndian.PutUint16(value[i*6:], t.NLRIAFI)
+		binary.BigEndian.PutUint16(value[i*6+2:], t.NLRISAFI)
+		binary.BigEndian.PutUint16(value[i*6+4:], t.NextHopAFI)
+	}
+	return Capability{
+		Code:  CAP_EXTENDED_NEXT_HOP_ENCODING,
+		Value: value,
+	}
+}
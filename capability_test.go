@@ -0,0 +1,138 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireCapabilities(t *testing.T) {
+	mpIPv4 := NewMPExtensionsCapability(AFI_IPV4, SAFI_UNICAST)
+	mpIPv6 := NewMPExtensionsCapability(AFI_IPV6, SAFI_UNICAST)
+	tests := []struct {
+		name     string
+		required []Capability
+		remote   []Capability
+		want     *Notification
+	}{
+		{
+			name:     "all present",
+			required: []Capability{mpIPv4, {Code: CAP_ROUTE_REFRESH}},
+			remote:   []Capability{{Code: CAP_ROUTE_REFRESH}, mpIPv4, mpIPv6},
+			want:     nil,
+		},
+		{
+			name:     "nil value matches any value",
+			required: []Capability{{Code: CAP_MP_EXTENSIONS}},
+			remote:   []Capability{mpIPv6},
+			want:     nil,
+		},
+		{
+			name:     "missing value",
+			required: []Capability{mpIPv4, mpIPv6},
+			remote:   []Capability{mpIPv4},
+			want: &Notification{
+				Code:    NOTIF_CODE_OPEN_MESSAGE_ERR,
+				Subcode: NOTIF_SUBCODE_UNSUPPORTED_CAPABILITY,
+				Data:    []byte{CAP_MP_EXTENSIONS, 4, 0, 2, 0, 1},
+			},
+		},
+		{
+			name:     "missing code",
+			required: []Capability{{Code: CAP_ROUTE_REFRESH}, {Code: CAP_ADD_PATH}},
+			remote:   []Capability{mpIPv4},
+			want: &Notification{
+				Code:    NOTIF_CODE_OPEN_MESSAGE_ERR,
+				Subcode: NOTIF_SUBCODE_UNSUPPORTED_CAPABILITY,
+				Data:    []byte{CAP_ROUTE_REFRESH, 0, CAP_ADD_PATH, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RequireCapabilities(tt.required...)
+			assert.Equal(t, tt.want, p(PeerConfig{}, nil, tt.remote))
+		})
+	}
+}
+
+func TestGracefulRestart_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    GracefulRestart
+		wantErr bool
+	}{
+		{
+			name: "restarting with families",
+			b: []byte{
+				0xC0, 0x78, // R + N bits, restart time 120
+				0x00, 0x01, 0x01, 0x80, // ipv4 unicast, forwarding preserved
+				0x00, 0x02, 0x01, 0x00, // ipv6 unicast
+			},
+			want: GracefulRestart{
+				Restarting:   true,
+				Notification: true,
+				RestartTime:  120,
+				Families: []GracefulRestartFamily{
+					{AFI: AFI_IPV4, SAFI: SAFI_UNICAST, ForwardingPreserved: true},
+					{AFI: AFI_IPV6, SAFI: SAFI_UNICAST},
+				},
+			},
+		},
+		{
+			name: "no families",
+			b:    []byte{0x0F, 0xFF},
+			want: GracefulRestart{
+				RestartTime: 4095,
+				Families:    []GracefulRestartFamily{},
+			},
+		},
+		{
+			name:    "truncated family",
+			b:       []byte{0x00, 0x78, 0x00, 0x01, 0x01},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got GracefulRestart
+			err := got.Decode(tt.b)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.b, got.Encode())
+		})
+	}
+}
+
+func TestMPExtensions_Decode(t *testing.T) {
+	var m MPExtensions
+	assert.NoError(t, m.Decode([]byte{0x00, 0x02, 0x00, 0x01}))
+	assert.Equal(t, MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}, m)
+	assert.Equal(t, []byte{0x00, 0x02, 0x00, 0x01}, m.Encode())
+	assert.Error(t, m.Decode([]byte{0x00, 0x02, 0x00}))
+}
+
+func TestRole_Decode(t *testing.T) {
+	var r Role
+	assert.NoError(t, r.Decode(NewRoleCapability(RoleCustomer).Value))
+	assert.Equal(t, RoleCustomer, r)
+	assert.Error(t, r.Decode([]byte{0x03, 0x00}))
+}
+
+func TestDecodeExtendedNextHops(t *testing.T) {
+	want := []ExtendedNextHop{
+		{NLRIAFI: AFI_IPV4, NLRISAFI: uint16(SAFI_UNICAST), NextHopAFI: AFI_IPV6},
+		{NLRIAFI: AFI_IPV4, NLRISAFI: uint16(SAFI_MPLS_LABELED_VPN_ADDR), NextHopAFI: AFI_IPV6},
+	}
+	c := NewExtendedNextHopCapability(want)
+	got, err := DecodeExtendedNextHops(c.Value)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	_, err = DecodeExtendedNextHops(c.Value[:5])
+	assert.Error(t, err)
+}
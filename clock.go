@@ -0,0 +1,48 @@
+package corebgp
+
+import "time"
+
+// Clock is the source of time for a peer's FSM timers, e.g. the hold,
+// keepalive, connect retry, and idle hold timers. The default Clock is backed
+// by the time package. An alternative Clock may be provided via WithClock in
+// order to advance time deterministically in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a new Timer that will send the current time on its
+	// channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock. Its semantics match those of a
+// *time.Timer prior to Go 1.23, i.e. its channel has a buffer of one and Stop
+// does not drain it.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing. It returns true if the call stops
+	// the timer, false if the timer has already expired or been stopped.
+	Stop() bool
+	// Reset changes the timer to expire after duration d. It returns true if
+	// the timer had been active, false if the timer had expired or been
+	// stopped.
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time {
+	return r.Timer.C
+}
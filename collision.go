@@ -0,0 +1,125 @@
+package corebgp
+
+import "net/netip"
+
+// ConnDirection is the direction of a connection relative to the local
+// speaker.
+type ConnDirection uint8
+
+const (
+	// ConnDirectionOutbound is a connection initiated by the local speaker.
+	ConnDirectionOutbound ConnDirection = ConnDirection(out)
+	// ConnDirectionInbound is a connection initiated by the remote peer.
+	ConnDirectionInbound ConnDirection = ConnDirection(in)
+)
+
+func (c ConnDirection) String() string {
+	return direction(int(c))
+}
+
+// Collision describes a connection collision, which occurs when a peer has both
+// an inbound and outbound connection, one of which has reached the OpenConfirm
+// state while the other is in the OpenConfirm or Established state.
+//
+// https://tools.ietf.org/html/rfc4271#section-6.8
+type Collision struct {
+	// LocalRouterID and RemoteRouterID are the BGP identifiers of the
+	// collision.
+	LocalRouterID  netip.Addr
+	RemoteRouterID netip.Addr
+
+	// Existing is the direction of the connection that was present prior to
+	// the collision, i.e. the connection that did not just transition to
+	// OpenConfirm.
+	Existing ConnDirection
+
+	// ExistingEstablished is true if the Existing connection is in the
+	// Established state.
+	ExistingEstablished bool
+
+	// Default is the direction of the connection that corebgp keeps per RFC
+	// 4271 and RFC 6286. An Established connection is always preferred,
+	// otherwise the connection initiated by the speaker with the higher BGP
+	// identifier, or AS number if BGP identifiers are equal, is kept.
+	Default ConnDirection
+}
+
+// CollisionResolver returns the direction of the connection to keep when a
+// connection collision occurs. The other connection is closed with a Cease
+// Notification. Returning c.Default preserves the standard behavior, while
+// returning c.Existing or ConnDirectionInbound may be used to implement
+// "prefer existing" or "prefer incoming" policies for peers that misbehave
+// during collision resolution.
+type CollisionResolver func(peer PeerConfig, c Collision) ConnDirection
+
+// CollisionObserver is called with the outcome of a connection collision, kept
+// being the direction of the connection that was kept.
+type CollisionObserver func(peer PeerConfig, c Collision, kept ConnDirection)
+
+// WithCollisionResolver returns a PeerOption that sets a CollisionResolver for
+// a peer, overriding the default connection collision resolution.
+func WithCollisionResolver(r CollisionResolver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.collisionResolver = r
+	})
+}
+
+// WithCollisionObserver returns a PeerOption that sets a CollisionObserver for
+// a peer.
+func WithCollisionObserver(fn CollisionObserver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.collisionObserver = fn
+	})
+}
+
+// resolveCollision returns the index of the FSM to keep when FSM i transitions
+// to OpenConfirm while the other FSM is in OpenConfirm or Established.
+func (p *peer) resolveCollision(i int, existingEstablished bool) int {
+	remoteID := p.fsms[i].remoteID
+	c := Collision{
+		LocalRouterID:       addrFromRouterID(p.id),
+		RemoteRouterID:      addrFromRouterID(remoteID),
+		Existing:            ConnDirection(other(i)),
+		ExistingEstablished: existingEstablished,
+	}
+	if existingEstablished {
+		/*
+			Unless allowed via configuration, a connection collision with an
+			existing BGP connection that is in the Established state causes
+			closing of the newly created connection.
+		*/
+		c.Default = ConnDirection(other(i))
+	} else {
+		// https://github.com/BIRD/bird/blob/v2.0.2/proto/bgp/packets.c#L666
+		/*
+			Description of collision detection rules in RFC 4271 is confusing and
+			contradictory, but it is essentially:
+
+				1. Router with higher ID is dominant
+				2. If both have the same ID, router with higher ASN is dominant [RFC6286]
+				3. When both connections are in OpenConfirm state, one initiated by
+				 the dominant router is kept.
+		*/
+		localID := p.id
+		dominant := localID > remoteID ||
+			(localID == remoteID) && (p.config.LocalAS > p.config.RemoteAS)
+		if dominant && i == out {
+			c.Default = ConnDirectionOutbound
+		} else {
+			c.Default = ConnDirection(other(i))
+		}
+	}
+	kept := c.Default
+	if r := p.options().collisionResolver; r != nil {
+		kept = r(p.config, c)
+		if kept != ConnDirectionOutbound && kept != ConnDirectionInbound {
+			kept = c.Default
+		}
+	}
+	p.logf("connection collision, keeping %s connection (default %s)",
+		kept, c.Default)
+	if fn := p.options().collisionObserver; fn != nil {
+		fn(p.config, c, kept)
+	}
+	return int(kept)
+}
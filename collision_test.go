@@ -0,0 +1,60 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeer_resolveCollision(t *testing.T) {
+	config := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	tests := []struct {
+		name                string
+		localID             uint32
+		remoteID            uint32
+		i                   int
+		existingEstablished bool
+		resolver            CollisionResolver
+		want                int
+	}{
+		{"existing established", 2, 1, out, true, nil, in},
+		{"dominant outbound", 2, 1, out, false, nil, out},
+		{"dominant inbound", 2, 1, in, false, nil, out},
+		{"not dominant", 1, 2, out, false, nil, in},
+		{"equal ID lower AS", 1, 1, out, false, nil, in},
+		{"prefer existing", 2, 1, out, false,
+			func(_ PeerConfig, c Collision) ConnDirection {
+				return c.Existing
+			}, in},
+		{"prefer incoming over established", 2, 1, in, true,
+			func(_ PeerConfig, _ Collision) ConnDirection {
+				return ConnDirectionInbound
+			}, in},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultPeerOptions()
+			o.collisionResolver = tt.resolver
+			var observed *Collision
+			o.collisionObserver = func(_ PeerConfig, c Collision,
+				kept ConnDirection) {
+				observed = &c
+				assert.Equal(t, ConnDirection(tt.want), kept)
+			}
+			p := newPeer(config, tt.localID, nil, o)
+			p.fsms[tt.i] = &fsm{remoteID: tt.remoteID}
+			got := p.resolveCollision(tt.i, tt.existingEstablished)
+			assert.Equal(t, tt.want, got)
+			if assert.NotNil(t, observed) {
+				assert.Equal(t, ConnDirection(other(tt.i)), observed.Existing)
+				assert.Equal(t, tt.existingEstablished,
+					observed.ExistingEstablished)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package corebgp
+
+import "net/netip"
+
+// Confederation describes the local system's membership of a BGP
+// confederation. corebgp does not modify the AS_PATH of UPDATE messages,
+// Confederation is applied by Plugins when processing received routes and
+// when building routes to advertise.
+//
+// https://www.rfc-editor.org/rfc/rfc5065
+type Confederation struct {
+	// ID is the Confederation Identifier, the AS presented to peers outside
+	// of the confederation.
+	ID uint32
+	// MemberAS is the Member-AS of the local system.
+	MemberAS uint32
+	// Members are the Member-ASes of the confederation. MemberAS is
+	// implicitly a member.
+	Members []uint32
+}
+
+// IsMember returns true if asn is a Member-AS of the confederation.
+func (c Confederation) IsMember(asn uint32) bool {
+	if asn == c.MemberAS {
+		return true
+	}
+	for _, m := range c.Members {
+		if m == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalAS returns the AS to be presented to a peer in remoteAS, i.e. the
+// local AS of its PeerConfig and OPEN messages. This is MemberAS for peers
+// within the confederation and ID for peers outside of it.
+func (c Confederation) LocalAS(remoteAS uint32) uint32 {
+	if c.IsMember(remoteAS) {
+		return c.MemberAS
+	}
+	return c.ID
+}
+
+// PeerConfig returns the PeerConfig for a peer at remoteAddress in remoteAS,
+// with LocalAS set per c.LocalAS.
+func (c Confederation) PeerConfig(remoteAddress netip.Addr,
+	remoteAS uint32) PeerConfig {
+	return PeerConfig{
+		RemoteAddress: remoteAddress,
+		LocalAS:       c.LocalAS(remoteAS),
+		RemoteAS:      remoteAS,
+	}
+}
+
+// Outbound returns the AS_PATH for a route with path advertised to a peer in
+// remoteAS:
+//
+//   - for peers in MemberAS (confederation-internal iBGP) path is unchanged
+//   - for peers in a neighboring Member-AS MemberAS is prepended as an
+//     AS_CONFED_SEQUENCE
+//   - for peers outside of the confederation all AS_CONFED_SEQUENCE and
+//     AS_CONFED_SET segments are removed and ID is prepended as an
+//     AS_SEQUENCE
+func (c Confederation) Outbound(path ASPath, remoteAS uint32) ASPath {
+	switch {
+	case remoteAS == c.MemberAS:
+		return path
+	case c.IsMember(remoteAS):
+		return path.PrependConfed(c.MemberAS)
+	default:
+		return path.RemoveConfed().Prepend(c.ID)
+	}
+}
+
+// Loop returns true if path, received from a peer, contains a loop with
+// respect to the confederation, i.e. MemberAS appears within an
+// AS_CONFED_SEQUENCE or AS_CONFED_SET segment, or ID appears within an
+// AS_SEQUENCE or AS_SET segment.
+func (c Confederation) Loop(path ASPath) bool {
+	for _, seg := range path {
+		want := c.ID
+		if seg.Type == ASPathSegmentTypeConfedSequence ||
+			seg.Type == ASPathSegmentTypeConfedSet {
+			want = c.MemberAS
+		}
+		for _, asn := range seg.ASNs {
+			if asn == want {
+				return true
+			}
+		}
+	}
+	return false
+}
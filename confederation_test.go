@@ -0,0 +1,34 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfederation(t *testing.T) {
+	c := Confederation{
+		ID:       64500,
+		MemberAS: 65001,
+		Members:  []uint32{65002},
+	}
+	assert.Equal(t, uint32(65001), c.LocalAS(65001))
+	assert.Equal(t, uint32(65001), c.LocalAS(65002))
+	assert.Equal(t, uint32(64500), c.LocalAS(64496))
+
+	path := ASPath{}.Prepend(64496)
+	assert.Equal(t, path, c.Outbound(path, 65001))
+
+	intra := c.Outbound(path, 65002)
+	assert.Equal(t, ASPath{
+		{Type: ASPathSegmentTypeConfedSequence, ASNs: []uint32{65001}},
+		{Type: ASPathSegmentTypeSequence, ASNs: []uint32{64496}},
+	}, intra)
+	assert.Equal(t, 1, intra.Len())
+
+	assert.Equal(t, ASPath{}.Prepend(64500, 64496), c.Outbound(intra, 64497))
+
+	assert.True(t, c.Loop(intra))
+	assert.True(t, c.Loop(ASPath{}.Prepend(64500)))
+	assert.False(t, c.Loop(ASPath{}.PrependConfed(65002).Prepend(65001)))
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Changes describes the changes made by Applier.Apply.
+type Changes struct {
+	// Added contains the addresses of peers that were added.
+	Added []netip.Addr
+	// Deleted contains the addresses of peers that were deleted.
+	Deleted []netip.Addr
+	// Updated contains the corebgp.PeerUpdateAction taken for each peer
+	// whose configuration changed, by address.
+	Updated map[netip.Addr]corebgp.PeerUpdateAction
+}
+
+// Applier applies Configs to a corebgp.Server. It tracks the peers it has
+// applied so that subsequent calls to Apply only add, update, or delete the
+// peers that have changed. Peers added to the Server by other means are left
+// untouched.
+type Applier struct {
+	server    *corebgp.Server
+	newPlugin func(Peer) corebgp.Plugin
+
+	mu       sync.Mutex
+	routerID netip.Addr
+	applied  map[netip.Addr]Peer
+}
+
+// NewApplier returns a new Applier for server. newPlugin is called with the
+// resolved configuration of a peer, including settings inherited from
+// Config.Global, when the peer is added.
+func NewApplier(server *corebgp.Server,
+	newPlugin func(Peer) corebgp.Plugin) *Applier {
+	return &Applier{
+		server:    server,
+		newPlugin: newPlugin,
+		applied:   make(map[netip.Addr]Peer),
+	}
+}
+
+// Apply applies c to the Server. Peers absent from c that were previously
+// applied are deleted, new peers are added, and peers whose configuration
+// changed are updated via corebgp.Server.UpdatePeer. A peer whose Families
+// changed is deleted and added again so that its Plugin is recreated. The
+// RouterID must not change between calls as it is fixed for the lifetime of a
+// Server. Listeners are not handled by Apply, see Listen.
+//
+// If an error occurs the returned Changes describe the changes made prior to
+// the error.
+func (a *Applier) Apply(c *Config) (Changes, error) {
+	changes := Changes{
+		Added:   make([]netip.Addr, 0),
+		Deleted: make([]netip.Addr, 0),
+		Updated: make(map[netip.Addr]corebgp.PeerUpdateAction),
+	}
+	err := c.Validate()
+	if err != nil {
+		return changes, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.routerID.IsValid() && a.routerID != c.RouterID {
+		return changes, errors.New("router_id cannot be changed")
+	}
+	a.routerID = c.RouterID
+
+	// replaced contains peers deleted in order to be added again
+	replaced := make(map[netip.Addr]bool)
+	desired := make(map[netip.Addr]Peer, len(c.Peers))
+	for _, p := range c.Peers {
+		desired[p.RemoteAddress] = c.resolve(p)
+	}
+
+	for _, addr := range sortedAddrs(a.applied) {
+		want, ok := desired[addr]
+		if ok && reflect.DeepEqual(want.Families, a.applied[addr].Families) {
+			continue
+		}
+		err = a.server.DeletePeer(addr)
+		if err != nil && !errors.Is(err, corebgp.ErrPeerNotExist) {
+			return changes, fmt.Errorf("error deleting peer %s: %w", addr, err)
+		}
+		delete(a.applied, addr)
+		if ok {
+			replaced[addr] = true
+		} else {
+			changes.Deleted = append(changes.Deleted, addr)
+		}
+	}
+
+	for _, addr := range sortedAddrs(desired) {
+		p := desired[addr]
+		existing, ok := a.applied[addr]
+		switch {
+		case !ok:
+			err = a.server.AddPeer(p.peerConfig(), a.newPlugin(p),
+				p.options()...)
+			if err != nil {
+				return changes, fmt.Errorf("error adding peer %s: %w", addr, err)
+			}
+			if replaced[addr] {
+				changes.Updated[addr] = corebgp.PeerUpdateReset
+			} else {
+				changes.Added = append(changes.Added, addr)
+			}
+		case !reflect.DeepEqual(existing, p):
+			action, err := a.server.UpdatePeer(p.peerConfig(), p.options()...)
+			if err != nil {
+				return changes, fmt.Errorf("error updating peer %s: %w", addr,
+					err)
+			}
+			changes.Updated[addr] = action
+		}
+		a.applied[addr] = p
+	}
+	return changes, nil
+}
+
+func sortedAddrs(m map[netip.Addr]Peer) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(m))
+	for addr := range m {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Less(addrs[j])
+	})
+	return addrs
+}
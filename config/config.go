@@ -0,0 +1,265 @@
+// Package config provides declarative configuration of a corebgp.Server. A
+// JSON document describing the Server's listeners, global settings, and peers
+// is loaded with Load, and applied to a Server with an Applier, which may be
+// used to reload the configuration by applying only what has changed.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Duration is a time.Duration encoded in JSON as a string parsable by
+// time.ParseDuration, e.g. "5s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config is the configuration of a corebgp.Server.
+type Config struct {
+	// RouterID is the BGP identifier of the Server.
+	RouterID netip.Addr `json:"router_id"`
+
+	// Listeners are the TCP addresses to listen on in host:port form, see
+	// Listen.
+	Listeners []string `json:"listeners,omitempty"`
+
+	// Global contains the default settings for all peers.
+	Global PeerSettings `json:"global"`
+
+	// Peers are the peers of the Server.
+	Peers []Peer `json:"peers,omitempty"`
+}
+
+// PeerSettings are the settings of a peer that may be set globally. Unset
+// fields use the corebgp defaults.
+type PeerSettings struct {
+	// LocalAS is the local autonomous system number.
+	LocalAS uint32 `json:"local_as,omitempty"`
+
+	// HoldTime is the hold time in seconds, see corebgp.WithHoldTime.
+	HoldTime *uint16 `json:"hold_time,omitempty"`
+
+	// IdleHoldTime is the idle hold time, see corebgp.WithIdleHoldTime.
+	IdleHoldTime *Duration `json:"idle_hold_time,omitempty"`
+
+	// ConnectRetryTime is the connect retry time, see
+	// corebgp.WithConnectRetryTime.
+	ConnectRetryTime *Duration `json:"connect_retry_time,omitempty"`
+
+	// Port is the TCP port of the peer, see corebgp.WithPort.
+	Port int `json:"port,omitempty"`
+
+	// Passive disables outbound connections, see corebgp.WithPassive. A Peer
+	// may set it to false to override a Global value of true.
+	Passive *bool `json:"passive,omitempty"`
+
+	// Multihop is the IP TTL of the peer's connections, see
+	// corebgp.WithMultihop.
+	Multihop *uint8 `json:"multihop,omitempty"`
+
+	// DSCP is the IP DSCP of the peer's connections, see corebgp.WithDSCP.
+	DSCP *uint8 `json:"dscp,omitempty"`
+
+	// Families are the AFI/SAFI tuples to be advertised to the peer. They
+	// are made available to the Plugin via the Peer passed to NewPlugin.
+	Families []Family `json:"families,omitempty"`
+}
+
+// Family is an AFI/SAFI tuple.
+type Family struct {
+	AFI  uint16 `json:"afi"`
+	SAFI uint8  `json:"safi"`
+}
+
+// Peer is the configuration of a peer. Unset fields of the embedded
+// PeerSettings inherit from Config.Global.
+type Peer struct {
+	// RemoteAddress is the remote address of the peer.
+	RemoteAddress netip.Addr `json:"remote_address"`
+
+	// RemoteAS is the remote autonomous system number.
+	RemoteAS uint32 `json:"remote_as"`
+
+	// LocalAddress is the optional local address of the peer, see
+	// corebgp.WithLocalAddress.
+	LocalAddress netip.Addr `json:"local_address,omitempty"`
+
+	// TCPMD5Key is the optional TCP MD5 key of the peer, see
+	// corebgp.WithTCPMD5Key.
+	TCPMD5Key string `json:"tcp_md5_key,omitempty"`
+
+	PeerSettings
+}
+
+// Load decodes and validates a Config from the JSON document in r. Unknown
+// fields are rejected.
+func Load(r io.Reader) (*Config, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	c := &Config{}
+	err := d.Decode(c)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+	err = c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadFile loads a Config from the JSON document at path, see Load.
+func LoadFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Validate validates the Config. Validation of peer settings beyond those
+// checked here is performed by the corebgp.Server when the Config is applied.
+func (c *Config) Validate() error {
+	if !c.RouterID.Is4() {
+		return errors.New("router_id must be an IPv4 address")
+	}
+	seen := make(map[netip.Addr]bool)
+	for _, p := range c.Peers {
+		if !p.RemoteAddress.IsValid() {
+			return errors.New("peer remote_address must be set")
+		}
+		if seen[p.RemoteAddress] {
+			return fmt.Errorf("duplicate peer: %s", p.RemoteAddress)
+		}
+		seen[p.RemoteAddress] = true
+		if c.resolve(p).LocalAS == 0 {
+			return fmt.Errorf("peer %s: local_as must be set", p.RemoteAddress)
+		}
+	}
+	return nil
+}
+
+// resolve returns p with unset PeerSettings inherited from c.Global.
+func (c *Config) resolve(p Peer) Peer {
+	g := c.Global
+	if p.LocalAS == 0 {
+		p.LocalAS = g.LocalAS
+	}
+	if p.HoldTime == nil {
+		p.HoldTime = g.HoldTime
+	}
+	if p.IdleHoldTime == nil {
+		p.IdleHoldTime = g.IdleHoldTime
+	}
+	if p.ConnectRetryTime == nil {
+		p.ConnectRetryTime = g.ConnectRetryTime
+	}
+	if p.Port == 0 {
+		p.Port = g.Port
+	}
+	if p.Passive == nil {
+		p.Passive = g.Passive
+	}
+	if p.Multihop == nil {
+		p.Multihop = g.Multihop
+	}
+	if p.DSCP == nil {
+		p.DSCP = g.DSCP
+	}
+	if p.Families == nil {
+		p.Families = g.Families
+	}
+	return p
+}
+
+// NewServer returns a new corebgp.Server for c.
+func NewServer(c *Config) (*corebgp.Server, error) {
+	return corebgp.NewServer(c.RouterID)
+}
+
+// Listen returns TCP listeners for c.Listeners, suitable for passing to
+// corebgp.Server.Serve.
+func Listen(c *Config) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(c.Listeners))
+	for _, addr := range c.Listeners {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, lis)
+	}
+	return listeners, nil
+}
+
+// peerConfig returns the corebgp.PeerConfig for a resolved Peer.
+func (p Peer) peerConfig() corebgp.PeerConfig {
+	return corebgp.PeerConfig{
+		RemoteAddress: p.RemoteAddress,
+		LocalAS:       p.LocalAS,
+		RemoteAS:      p.RemoteAS,
+	}
+}
+
+// options returns the corebgp.PeerOptions for a resolved Peer.
+func (p Peer) options() []corebgp.PeerOption {
+	opts := make([]corebgp.PeerOption, 0)
+	if p.HoldTime != nil {
+		opts = append(opts, corebgp.WithHoldTime(*p.HoldTime))
+	}
+	if p.IdleHoldTime != nil {
+		opts = append(opts,
+			corebgp.WithIdleHoldTime(time.Duration(*p.IdleHoldTime)))
+	}
+	if p.ConnectRetryTime != nil {
+		opts = append(opts,
+			corebgp.WithConnectRetryTime(time.Duration(*p.ConnectRetryTime)))
+	}
+	if p.Port != 0 {
+		opts = append(opts, corebgp.WithPort(p.Port))
+	}
+	if p.Passive != nil && *p.Passive {
+		opts = append(opts, corebgp.WithPassive())
+	}
+	if p.Multihop != nil {
+		opts = append(opts, corebgp.WithMultihop(*p.Multihop))
+	}
+	if p.DSCP != nil {
+		opts = append(opts, corebgp.WithDSCP(*p.DSCP))
+	}
+	if p.LocalAddress.IsValid() {
+		opts = append(opts, corebgp.WithLocalAddress(p.LocalAddress))
+	}
+	if len(p.TCPMD5Key) > 0 {
+		opts = append(opts, corebgp.WithTCPMD5Key(p.TCPMD5Key))
+	}
+	return opts
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{
+	"router_id": "192.0.2.1",
+	"listeners": ["127.0.0.1:0"],
+	"global": {
+		"local_as": 64512,
+		"hold_time": 30,
+		"idle_hold_time": "10s",
+		"families": [{"afi": 1, "safi": 1}]
+	},
+	"peers": [
+		{
+			"remote_address": "192.0.2.2",
+			"remote_as": 64513,
+			"passive": true,
+			"dscp": 48
+		},
+		{
+			"remote_address": "2001:db8::2",
+			"remote_as": 64514,
+			"local_as": 64515,
+			"multihop": 2,
+			"connect_retry_time": "1s",
+			"families": [{"afi": 2, "safi": 1}]
+		}
+	]
+}`
+
+func TestLoad(t *testing.T) {
+	c, err := Load(strings.NewReader(testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), c.RouterID)
+	assert.Len(t, c.Peers, 2)
+
+	p := c.resolve(c.Peers[0])
+	assert.Equal(t, uint32(64512), p.LocalAS)
+	assert.Equal(t, uint16(30), *p.HoldTime)
+	assert.Equal(t, Duration(time.Second*10), *p.IdleHoldTime)
+	assert.True(t, *p.Passive)
+	assert.Equal(t, corebgp.DSCPCS6, *p.DSCP)
+	assert.Equal(t, []Family{{AFI: 1, SAFI: 1}}, p.Families)
+
+	p = c.resolve(c.Peers[1])
+	assert.Equal(t, uint32(64515), p.LocalAS)
+	assert.Equal(t, Duration(time.Second), *p.ConnectRetryTime)
+	assert.Nil(t, p.DSCP)
+	assert.Equal(t, uint8(2), *p.Multihop)
+	assert.Equal(t, []Family{{AFI: 2, SAFI: 1}}, p.Families)
+
+	assert.Nil(t, p.Passive)
+
+	for _, bad := range []string{
+		`{"router_id": "192.0.2.1", "unknown": true}`,
+		`{"router_id": "2001:db8::1"}`,
+		`{"router_id": "192.0.2.1", "peers": [{"remote_address": "192.0.2.2", "remote_as": 1}]}`,
+		`{"router_id": "192.0.2.1", "global": {"idle_hold_time": "bogus"}}`,
+	} {
+		_, err = Load(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLoadPassiveOverride(t *testing.T) {
+	c, err := Load(strings.NewReader(`{
+		"router_id": "192.0.2.1",
+		"global": {"local_as": 64512, "passive": true},
+		"peers": [
+			{"remote_address": "192.0.2.2", "remote_as": 64513},
+			{"remote_address": "192.0.2.3", "remote_as": 64513, "passive": false}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := c.resolve(c.Peers[0])
+	assert.True(t, *p.Passive)
+	assert.Len(t, p.options(), 1)
+	p = c.resolve(c.Peers[1])
+	assert.False(t, *p.Passive)
+	assert.Empty(t, p.options())
+}
+
+type nopPlugin struct{}
+
+func (nopPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (nopPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (nopPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return nil
+}
+
+func (nopPlugin) OnClose(corebgp.PeerConfig) {}
+
+func TestApplier(t *testing.T) {
+	c, err := Load(strings.NewReader(testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := NewServer(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	pluginCalls := 0
+	a := NewApplier(s, func(Peer) corebgp.Plugin {
+		pluginCalls++
+		return nopPlugin{}
+	})
+
+	v4, v6 := netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("2001:db8::2")
+	changes, err := a.Apply(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{v4, v6}, changes.Added)
+	assert.Len(t, s.ListPeers(), 2)
+
+	// no changes
+	changes, err = a.Apply(c)
+	assert.NoError(t, err)
+	assert.Empty(t, changes.Added)
+	assert.Empty(t, changes.Deleted)
+	assert.Empty(t, changes.Updated)
+
+	// update one peer, replace the other due to changed families, add a new
+	// peer
+	c.Peers[0].RemoteAS = 64520
+	c.Peers[1].Families = nil
+	v4New := netip.MustParseAddr("192.0.2.3")
+	c.Peers = append(c.Peers, Peer{RemoteAddress: v4New, RemoteAS: 64521})
+	changes, err = a.Apply(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{v4New}, changes.Added)
+	assert.Equal(t, map[netip.Addr]corebgp.PeerUpdateAction{
+		v4: corebgp.PeerUpdateInPlace,
+		v6: corebgp.PeerUpdateReset,
+	}, changes.Updated)
+	got, err := s.GetPeer(v4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(64520), got.RemoteAS)
+	assert.Equal(t, 4, pluginCalls)
+
+	// delete
+	c.Peers = c.Peers[:1]
+	changes, err = a.Apply(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{v4New, v6}, changes.Deleted)
+	assert.Len(t, s.ListPeers(), 1)
+
+	c.RouterID = netip.MustParseAddr("192.0.2.100")
+	_, err = a.Apply(c)
+	assert.Error(t, err)
+}
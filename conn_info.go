@@ -0,0 +1,120 @@
+package corebgp
+
+import (
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ConnInfo describes the transport connection of an established session, see
+// Server.GetConnInfo.
+type ConnInfo struct {
+	// LocalAddress and RemoteAddress are the addresses of the connection. They
+	// are the zero value if the connection's addresses are not IP addresses.
+	LocalAddress  netip.AddrPort
+	RemoteAddress netip.AddrPort
+
+	// Inbound is true if the connection was accepted from the peer, false if
+	// it was dialed.
+	Inbound bool
+
+	// MD5 is true if the connection is protected by TCP MD5 signatures, see
+	// WithTCPMD5Key.
+	MD5 bool
+
+	// TTL is the IP TTL (IPv4) or unicast hop limit (IPv6) of outgoing
+	// packets. MinTTL is the minimum TTL (IPv4) or hop count (IPv6) required
+	// of incoming packets, 0 if unset. Both are 0 if unavailable.
+	TTL    int
+	MinTTL int
+
+	// TCPInfo contains socket statistics of the connection. It is nil if they
+	// are unavailable, which is the case outside of Linux.
+	TCPInfo *TCPInfo
+}
+
+// TCPInfo contains a subset of the socket statistics reported by TCP_INFO on
+// Linux.
+type TCPInfo struct {
+	// State is the TCP state as defined by the kernel, e.g. 1 for
+	// ESTABLISHED.
+	State uint8
+	// RTT and RTTVar are the smoothed round trip time and its variance.
+	RTT    time.Duration
+	RTTVar time.Duration
+	// RTO is the retransmission timeout.
+	RTO time.Duration
+	// SndMSS and RcvMSS are the send and receive maximum segment sizes.
+	SndMSS uint32
+	RcvMSS uint32
+	// PMTU is the path MTU.
+	PMTU uint32
+	// SndCwnd is the send congestion window in segments.
+	SndCwnd uint32
+	// Unacked is the number of segments sent but not yet acknowledged.
+	Unacked uint32
+	// Lost is the number of segments presumed lost.
+	Lost uint32
+	// Retransmits is the number of consecutive retransmissions of the oldest
+	// unacknowledged segment, TotalRetrans the number of retransmitted
+	// segments over the lifetime of the connection.
+	Retransmits  uint8
+	TotalRetrans uint32
+	// LastDataSent and LastDataRecv are the time since data was last sent
+	// and received.
+	LastDataSent time.Duration
+	LastDataRecv time.Duration
+	// NotSentBytes is the amount of data written to the socket that has not
+	// yet been sent.
+	NotSentBytes uint32
+	// BytesAcked is the number of bytes acknowledged by the peer over the
+	// lifetime of the connection.
+	BytesAcked uint64
+}
+
+// GetConnInfo returns the ConnInfo for the provided peer's established
+// session, or an error if the peer does not exist or is not in the
+// established state. Socket level fields are read from the connection at the
+// time of the call and are left as the zero value where unavailable.
+func (s *Server) GetConnInfo(ip netip.Addr) (ConnInfo, error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return ConnInfo{}, ErrPeerNotExist
+	}
+	w := p.getSessionWriter()
+	if w == nil {
+		return ConnInfo{}, ErrPeerNotEstablished
+	}
+	info := w.connInfo
+	readSockInfo(w.conn, &info)
+	return info, nil
+}
+
+// newConnInfo returns a ConnInfo for conn with the fields known at
+// establishment set.
+func newConnInfo(conn net.Conn, inbound, md5 bool) ConnInfo {
+	info := ConnInfo{
+		Inbound: inbound,
+		MD5:     md5,
+	}
+	info.LocalAddress, _ = netip.ParseAddrPort(conn.LocalAddr().String())
+	info.RemoteAddress, _ = netip.ParseAddrPort(conn.RemoteAddr().String())
+	return info
+}
+
+// readSockInfo populates the socket level fields of info from conn, if it is
+// a syscall.Conn. Errors are ignored as the fields are informational.
+func readSockInfo(conn net.Conn, info *ConnInfo) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return
+	}
+	rc.Control(func(fd uintptr) { // nolint: errcheck
+		sockInfo(int(fd), info)
+	})
+}
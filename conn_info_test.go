@@ -0,0 +1,38 @@
+package corebgp_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetConnInfo(t *testing.T) {
+	t.Parallel()
+	servers, _ := newKeepaliveTestServers(t, 90)
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	_, err := servers[0].GetConnInfo(netip.MustParseAddr("192.0.2.3"))
+	assert.ErrorIs(t, err, corebgp.ErrPeerNotExist)
+
+	var infoA, infoB corebgp.ConnInfo
+	assert.Eventually(t, func() bool {
+		var errA, errB error
+		infoA, errA = servers[0].GetConnInfo(addrB)
+		infoB, errB = servers[1].GetConnInfo(addrA)
+		return errA == nil && errB == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	// the server for addrB is passive
+	assert.False(t, infoA.Inbound)
+	assert.True(t, infoB.Inbound)
+	assert.Equal(t, addrA, infoA.LocalAddress.Addr())
+	assert.Equal(t, addrB, infoA.RemoteAddress.Addr())
+	assert.Equal(t, infoA.LocalAddress, infoB.RemoteAddress)
+	assert.False(t, infoA.MD5)
+	// in-memory connections have no socket
+	assert.Nil(t, infoA.TCPInfo)
+}
@@ -0,0 +1,127 @@
+package corebgp
+
+import (
+	"context"
+	"net/netip"
+)
+
+// ContextPlugin is a BGP peer plugin whose callbacks are passed a
+// context.Context tied to the lifecycle of the peer, allowing plugin code
+// performing I/O to respect cancellation. A ContextPlugin is converted to a
+// Plugin with NewContextPlugin. Its methods are otherwise equivalent to those
+// of Plugin.
+type ContextPlugin interface {
+	// GetCapabilities is passed a context that is canceled when the peer is
+	// removed from the Server, or the Server is closed.
+	GetCapabilities(ctx context.Context, peer PeerConfig) []Capability
+
+	// OnOpenMessage is passed a context that is canceled when the peer is
+	// removed from the Server, or the Server is closed.
+	OnOpenMessage(ctx context.Context, peer PeerConfig, routerID netip.Addr,
+		capabilities []Capability) *Notification
+
+	// OnEstablished is passed a context that is additionally canceled when
+	// the FSM transitions out of the Established state. The same context is
+	// passed to the returned ContextUpdateMessageHandler.
+	OnEstablished(ctx context.Context, peer PeerConfig,
+		writer UpdateMessageWriter) ContextUpdateMessageHandler
+
+	// OnClose is fired when a peer's FSM transitions out of the Established
+	// state, at which point the context passed to OnEstablished is canceled.
+	OnClose(peer PeerConfig)
+}
+
+// ContextUpdateMessageHandler is the UpdateMessageHandler of a ContextPlugin.
+type ContextUpdateMessageHandler func(ctx context.Context, peer PeerConfig,
+	updateMessage []byte) *Notification
+
+// NewContextPlugin returns a Plugin for p. The contexts tied to the lifecycle
+// of the peer are passed to p when the returned Plugin is passed to
+// Server.AddPeer, either directly or as part of a PluginChain. If its methods
+// are called directly context.Background() is used.
+func NewContextPlugin(p ContextPlugin) Plugin {
+	return &contextPlugin{p: p}
+}
+
+// contextAwarePlugin is implemented by Plugins that accept a context.Context
+// from the FSM.
+type contextAwarePlugin interface {
+	getCapabilities(ctx context.Context, peer PeerConfig) []Capability
+	onOpenMessage(ctx context.Context, peer PeerConfig, routerID netip.Addr,
+		capabilities []Capability) *Notification
+	onEstablished(ctx context.Context, peer PeerConfig,
+		writer UpdateMessageWriter) UpdateMessageHandler
+}
+
+type contextPlugin struct {
+	p ContextPlugin
+}
+
+func (c *contextPlugin) GetCapabilities(peer PeerConfig) []Capability {
+	return c.getCapabilities(context.Background(), peer)
+}
+
+func (c *contextPlugin) OnOpenMessage(peer PeerConfig, routerID netip.Addr,
+	capabilities []Capability) *Notification {
+	return c.onOpenMessage(context.Background(), peer, routerID, capabilities)
+}
+
+func (c *contextPlugin) OnEstablished(peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	return c.onEstablished(context.Background(), peer, writer)
+}
+
+func (c *contextPlugin) OnClose(peer PeerConfig) {
+	c.p.OnClose(peer)
+}
+
+func (c *contextPlugin) getCapabilities(ctx context.Context,
+	peer PeerConfig) []Capability {
+	return c.p.GetCapabilities(ctx, peer)
+}
+
+func (c *contextPlugin) onOpenMessage(ctx context.Context, peer PeerConfig,
+	routerID netip.Addr, capabilities []Capability) *Notification {
+	return c.p.OnOpenMessage(ctx, peer, routerID, capabilities)
+}
+
+func (c *contextPlugin) onEstablished(ctx context.Context, peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	h := c.p.OnEstablished(ctx, peer, writer)
+	if h == nil {
+		return nil
+	}
+	return func(peer PeerConfig, updateMessage []byte) *Notification {
+		return h(ctx, peer, updateMessage)
+	}
+}
+
+// pluginGetCapabilities calls GetCapabilities of plugin, passing ctx if it is
+// a contextAwarePlugin.
+func pluginGetCapabilities(ctx context.Context, plugin Plugin,
+	peer PeerConfig) []Capability {
+	if c, ok := plugin.(contextAwarePlugin); ok {
+		return c.getCapabilities(ctx, peer)
+	}
+	return plugin.GetCapabilities(peer)
+}
+
+// pluginOnOpenMessage calls OnOpenMessage of plugin, passing ctx if it is a
+// contextAwarePlugin.
+func pluginOnOpenMessage(ctx context.Context, plugin Plugin, peer PeerConfig,
+	routerID netip.Addr, capabilities []Capability) *Notification {
+	if c, ok := plugin.(contextAwarePlugin); ok {
+		return c.onOpenMessage(ctx, peer, routerID, capabilities)
+	}
+	return plugin.OnOpenMessage(peer, routerID, capabilities)
+}
+
+// pluginOnEstablished calls OnEstablished of plugin, passing ctx if it is a
+// contextAwarePlugin.
+func pluginOnEstablished(ctx context.Context, plugin Plugin, peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	if c, ok := plugin.(contextAwarePlugin); ok {
+		return c.onEstablished(ctx, peer, writer)
+	}
+	return plugin.OnEstablished(peer, writer)
+}
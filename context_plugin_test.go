@@ -0,0 +1,109 @@
+package corebgp_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type contextTestPlugin struct {
+	mu          sync.Mutex
+	capsCtx     context.Context
+	sessionCtxs []context.Context
+}
+
+func (c *contextTestPlugin) GetCapabilities(ctx context.Context,
+	_ corebgp.PeerConfig) []corebgp.Capability {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capsCtx = ctx
+	return nil
+}
+
+func (c *contextTestPlugin) OnOpenMessage(context.Context, corebgp.PeerConfig,
+	netip.Addr, []corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (c *contextTestPlugin) OnEstablished(ctx context.Context,
+	_ corebgp.PeerConfig,
+	_ corebgp.UpdateMessageWriter) corebgp.ContextUpdateMessageHandler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessionCtxs = append(c.sessionCtxs, ctx)
+	return nil
+}
+
+func (c *contextTestPlugin) OnClose(corebgp.PeerConfig) {}
+
+func (c *contextTestPlugin) contexts() (context.Context, []context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capsCtx, append([]context.Context{}, c.sessionCtxs...)
+}
+
+func TestNewContextPlugin(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	ctxPlugin := &contextTestPlugin{}
+	servers := make([]*corebgp.Server, 0, 2)
+	for _, s := range []struct {
+		local, remote netip.Addr
+		plugin        corebgp.Plugin
+		opts          []corebgp.PeerOption
+	}{
+		{addrA, addrB, corebgp.NewPluginChain(
+			corebgp.NewContextPlugin(ctxPlugin)), nil},
+		{addrB, addrA, &livenessTestPlugin{},
+			[]corebgp.PeerOption{corebgp.WithPassive()}},
+	} {
+		server, err := corebgp.NewServer(s.local)
+		if !assert.NoError(t, err) {
+			return
+		}
+		err = server.AddPeer(corebgp.PeerConfig{
+			RemoteAddress: s.remote,
+			LocalAS:       64512,
+			RemoteAS:      64512,
+		}, s.plugin, append(s.opts, corebgp.WithLocalAddress(s.local),
+			corebgp.WithIdleHoldTime(time.Millisecond*100),
+			corebgp.WithDialer(n.Dialer(s.local)))...)
+		if !assert.NoError(t, err) {
+			return
+		}
+		l, err := n.Listen(s.local)
+		if !assert.NoError(t, err) {
+			return
+		}
+		go server.Serve([]net.Listener{l})
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+	}
+
+	assert.Eventually(t, func() bool {
+		_, sessions := ctxPlugin.contexts()
+		return len(sessions) == 1
+	}, time.Second*5, time.Millisecond*10)
+	capsCtx, sessions := ctxPlugin.contexts()
+	assert.NoError(t, capsCtx.Err())
+	assert.NoError(t, sessions[0].Err())
+
+	// the session context is canceled when the session closes
+	assert.NoError(t, servers[1].DeletePeer(addrA))
+	assert.Eventually(t, func() bool {
+		return sessions[0].Err() != nil
+	}, time.Second*5, time.Millisecond*10)
+	assert.NoError(t, capsCtx.Err())
+
+	// the peer context is canceled when the peer is deleted
+	assert.NoError(t, servers[0].DeletePeer(addrB))
+	assert.ErrorIs(t, capsCtx.Err(), context.Canceled)
+}
@@ -0,0 +1,436 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: corebgp.proto
+
+package apipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	CoreBGP_AddPeer_FullMethodName      = "/corebgpapi.v1.CoreBGP/AddPeer"
+	CoreBGP_DeletePeer_FullMethodName   = "/corebgpapi.v1.CoreBGP/DeletePeer"
+	CoreBGP_EnablePeer_FullMethodName   = "/corebgpapi.v1.CoreBGP/EnablePeer"
+	CoreBGP_DisablePeer_FullMethodName  = "/corebgpapi.v1.CoreBGP/DisablePeer"
+	CoreBGP_ListPeers_FullMethodName    = "/corebgpapi.v1.CoreBGP/ListPeers"
+	CoreBGP_GetPeer_FullMethodName      = "/corebgpapi.v1.CoreBGP/GetPeer"
+	CoreBGP_GetPeerStats_FullMethodName = "/corebgpapi.v1.CoreBGP/GetPeerStats"
+	CoreBGP_WatchEvents_FullMethodName  = "/corebgpapi.v1.CoreBGP/WatchEvents"
+)
+
+// CoreBGPClient is the client API for CoreBGP service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CoreBGP manages the peers of a corebgp Server.
+type CoreBGPClient interface {
+	// AddPeer adds a peer to the Server. The peer is handled by the Plugin
+	// returned by the service's plugin factory.
+	AddPeer(ctx context.Context, in *AddPeerRequest, opts ...grpc.CallOption) (*AddPeerResponse, error)
+	// DeletePeer deletes a peer from the Server.
+	DeletePeer(ctx context.Context, in *DeletePeerRequest, opts ...grpc.CallOption) (*DeletePeerResponse, error)
+	// EnablePeer enables a peer previously disabled with DisablePeer.
+	EnablePeer(ctx context.Context, in *EnablePeerRequest, opts ...grpc.CallOption) (*EnablePeerResponse, error)
+	// DisablePeer closes the session of a peer, if any, with an Administrative
+	// Shutdown Cease Notification, and removes it from the Server until it is
+	// enabled with EnablePeer.
+	DisablePeer(ctx context.Context, in *DisablePeerRequest, opts ...grpc.CallOption) (*DisablePeerResponse, error)
+	// ListPeers returns the state of all peers, ordered by remote address.
+	ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error)
+	// GetPeer returns the state of a peer.
+	GetPeer(ctx context.Context, in *GetPeerRequest, opts ...grpc.CallOption) (*GetPeerResponse, error)
+	// GetPeerStats returns the statistics of a peer.
+	GetPeerStats(ctx context.Context, in *GetPeerStatsRequest, opts ...grpc.CallOption) (*GetPeerStatsResponse, error)
+	// WatchEvents streams the FSM events, and optionally the UPDATE messages,
+	// of peers managed by the service. Response headers are sent once the
+	// watch is established, events occurring from then on are streamed. The
+	// stream fails with RESOURCE_EXHAUSTED if the client falls behind.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (CoreBGP_WatchEventsClient, error)
+}
+
+type coreBGPClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoreBGPClient(cc grpc.ClientConnInterface) CoreBGPClient {
+	return &coreBGPClient{cc}
+}
+
+func (c *coreBGPClient) AddPeer(ctx context.Context, in *AddPeerRequest, opts ...grpc.CallOption) (*AddPeerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddPeerResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_AddPeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) DeletePeer(ctx context.Context, in *DeletePeerRequest, opts ...grpc.CallOption) (*DeletePeerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePeerResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_DeletePeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) EnablePeer(ctx context.Context, in *EnablePeerRequest, opts ...grpc.CallOption) (*EnablePeerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnablePeerResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_EnablePeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) DisablePeer(ctx context.Context, in *DisablePeerRequest, opts ...grpc.CallOption) (*DisablePeerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisablePeerResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_DisablePeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPeersResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_ListPeers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) GetPeer(ctx context.Context, in *GetPeerRequest, opts ...grpc.CallOption) (*GetPeerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPeerResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_GetPeer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) GetPeerStats(ctx context.Context, in *GetPeerStatsRequest, opts ...grpc.CallOption) (*GetPeerStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPeerStatsResponse)
+	err := c.cc.Invoke(ctx, CoreBGP_GetPeerStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreBGPClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (CoreBGP_WatchEventsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CoreBGP_ServiceDesc.Streams[0], CoreBGP_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &coreBGPWatchEventsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CoreBGP_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type coreBGPWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *coreBGPWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CoreBGPServer is the server API for CoreBGP service.
+// All implementations must embed UnimplementedCoreBGPServer
+// for forward compatibility
+//
+// CoreBGP manages the peers of a corebgp Server.
+type CoreBGPServer interface {
+	// AddPeer adds a peer to the Server. The peer is handled by the Plugin
+	// returned by the service's plugin factory.
+	AddPeer(context.Context, *AddPeerRequest) (*AddPeerResponse, error)
+	// DeletePeer deletes a peer from the Server.
+	DeletePeer(context.Context, *DeletePeerRequest) (*DeletePeerResponse, error)
+	// EnablePeer enables a peer previously disabled with DisablePeer.
+	EnablePeer(context.Context, *EnablePeerRequest) (*EnablePeerResponse, error)
+	// DisablePeer closes the session of a peer, if any, with an Administrative
+	// Shutdown Cease Notification, and removes it from the Server until it is
+	// enabled with EnablePeer.
+	DisablePeer(context.Context, *DisablePeerRequest) (*DisablePeerResponse, error)
+	// ListPeers returns the state of all peers, ordered by remote address.
+	ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error)
+	// GetPeer returns the state of a peer.
+	GetPeer(context.Context, *GetPeerRequest) (*GetPeerResponse, error)
+	// GetPeerStats returns the statistics of a peer.
+	GetPeerStats(context.Context, *GetPeerStatsRequest) (*GetPeerStatsResponse, error)
+	// WatchEvents streams the FSM events, and optionally the UPDATE messages,
+	// of peers managed by the service. Response headers are sent once the
+	// watch is established, events occurring from then on are streamed. The
+	// stream fails with RESOURCE_EXHAUSTED if the client falls behind.
+	WatchEvents(*WatchEventsRequest, CoreBGP_WatchEventsServer) error
+	mustEmbedUnimplementedCoreBGPServer()
+}
+
+// UnimplementedCoreBGPServer must be embedded to have forward compatible implementations.
+type UnimplementedCoreBGPServer struct {
+}
+
+func (UnimplementedCoreBGPServer) AddPeer(context.Context, *AddPeerRequest) (*AddPeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPeer not implemented")
+}
+func (UnimplementedCoreBGPServer) DeletePeer(context.Context, *DeletePeerRequest) (*DeletePeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePeer not implemented")
+}
+func (UnimplementedCoreBGPServer) EnablePeer(context.Context, *EnablePeerRequest) (*EnablePeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnablePeer not implemented")
+}
+func (UnimplementedCoreBGPServer) DisablePeer(context.Context, *DisablePeerRequest) (*DisablePeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisablePeer not implemented")
+}
+func (UnimplementedCoreBGPServer) ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPeers not implemented")
+}
+func (UnimplementedCoreBGPServer) GetPeer(context.Context, *GetPeerRequest) (*GetPeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeer not implemented")
+}
+func (UnimplementedCoreBGPServer) GetPeerStats(context.Context, *GetPeerStatsRequest) (*GetPeerStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeerStats not implemented")
+}
+func (UnimplementedCoreBGPServer) WatchEvents(*WatchEventsRequest, CoreBGP_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedCoreBGPServer) mustEmbedUnimplementedCoreBGPServer() {}
+
+// UnsafeCoreBGPServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoreBGPServer will
+// result in compilation errors.
+type UnsafeCoreBGPServer interface {
+	mustEmbedUnimplementedCoreBGPServer()
+}
+
+func RegisterCoreBGPServer(s grpc.ServiceRegistrar, srv CoreBGPServer) {
+	s.RegisterService(&CoreBGP_ServiceDesc, srv)
+}
+
+func _CoreBGP_AddPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).AddPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_AddPeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).AddPeer(ctx, req.(*AddPeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_DeletePeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).DeletePeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_DeletePeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).DeletePeer(ctx, req.(*DeletePeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_EnablePeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnablePeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).EnablePeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_EnablePeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).EnablePeer(ctx, req.(*EnablePeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_DisablePeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisablePeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).DisablePeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_DisablePeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).DisablePeer(ctx, req.(*DisablePeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_ListPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).ListPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_ListPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).ListPeers(ctx, req.(*ListPeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_GetPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).GetPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_GetPeer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).GetPeer(ctx, req.(*GetPeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_GetPeerStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPeerStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreBGPServer).GetPeerStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoreBGP_GetPeerStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreBGPServer).GetPeerStats(ctx, req.(*GetPeerStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoreBGP_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CoreBGPServer).WatchEvents(m, &coreBGPWatchEventsServer{ServerStream: stream})
+}
+
+type CoreBGP_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type coreBGPWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *coreBGPWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CoreBGP_ServiceDesc is the grpc.ServiceDesc for CoreBGP service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CoreBGP_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "corebgpapi.v1.CoreBGP",
+	HandlerType: (*CoreBGPServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddPeer",
+			Handler:    _CoreBGP_AddPeer_Handler,
+		},
+		{
+			MethodName: "DeletePeer",
+			Handler:    _CoreBGP_DeletePeer_Handler,
+		},
+		{
+			MethodName: "EnablePeer",
+			Handler:    _CoreBGP_EnablePeer_Handler,
+		},
+		{
+			MethodName: "DisablePeer",
+			Handler:    _CoreBGP_DisablePeer_Handler,
+		},
+		{
+			MethodName: "ListPeers",
+			Handler:    _CoreBGP_ListPeers_Handler,
+		},
+		{
+			MethodName: "GetPeer",
+			Handler:    _CoreBGP_GetPeer_Handler,
+		},
+		{
+			MethodName: "GetPeerStats",
+			Handler:    _CoreBGP_GetPeerStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _CoreBGP_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "corebgp.proto",
+}
@@ -0,0 +1,5 @@
+// Package apipb contains the protobuf messages and gRPC service definitions
+// of the corebgp management API, see package corebgpapi.
+package apipb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative corebgp.proto
@@ -0,0 +1,277 @@
+// Command corebgpcli inspects and operates a corebgp-based daemon via its
+// management API, see package corebgpapi.
+//
+// Usage:
+//
+//	corebgpcli [-addr host:port] <command> [arguments]
+//
+// The commands are:
+//
+//	peers                        list peers
+//	show <address>               show the session and statistics of a peer
+//	shut <address> [message]     disable a peer, sending message as the
+//	                             Shutdown Communication
+//	no-shut <address>            enable a peer
+//	tail [-updates] [address]    stream the events of all peers, or of a
+//	                             single peer, optionally including UPDATE
+//	                             messages
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgpapi/apipb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	addr    = flag.String("addr", "localhost:50051", "management API address")
+	timeout = flag.Duration("timeout", time.Second*10,
+		"timeout of commands other than tail")
+)
+
+const usage = `usage: corebgpcli [flags] <command> [arguments]
+
+commands:
+  peers                        list peers
+  show <address>               show the session and statistics of a peer
+  shut <address> [message]     disable a peer
+  no-shut <address>            enable a peer
+  tail [-updates] [address]    stream events, optionally including UPDATEs
+
+flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cc, err := grpc.NewClient(*addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("error creating client: %v", err)
+	}
+	defer cc.Close()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt,
+		syscall.SIGTERM)
+	defer cancel()
+	err = run(ctx, apipb.NewCoreBGPClient(cc), flag.Args(), os.Stdout)
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
+
+var errUsage = errors.New("invalid usage")
+
+// run executes the command in args, writing its output to w.
+func run(ctx context.Context, client apipb.CoreBGPClient, args []string,
+	w io.Writer) error {
+	if args[0] != "tail" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	switch args[0] {
+	case "peers":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return listPeers(ctx, client, w)
+	case "show":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return showPeer(ctx, client, args[1], w)
+	case "shut":
+		if len(args) < 2 {
+			return errUsage
+		}
+		_, err := client.DisablePeer(ctx, &apipb.DisablePeerRequest{
+			Address:       args[1],
+			Communication: strings.Join(args[2:], " "),
+		})
+		return err
+	case "no-shut":
+		if len(args) != 2 {
+			return errUsage
+		}
+		_, err := client.EnablePeer(ctx, &apipb.EnablePeerRequest{
+			Address: args[1],
+		})
+		return err
+	case "tail":
+		fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		updates := fs.Bool("updates", false, "include UPDATE messages")
+		if fs.Parse(args[1:]) != nil || fs.NArg() > 1 {
+			return errUsage
+		}
+		return tail(ctx, client, fs.Arg(0), *updates, w)
+	}
+	return errUsage
+}
+
+// uptime returns the time elapsed since t, or "-" if t is unset.
+func uptime(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return "-"
+	}
+	return time.Since(t.AsTime()).Truncate(time.Second).String()
+}
+
+func listPeers(ctx context.Context, client apipb.CoreBGPClient,
+	w io.Writer) error {
+	resp, err := client.ListPeers(ctx, &apipb.ListPeersRequest{})
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tAS\tSTATE\tUPTIME\tMANAGED")
+	for _, p := range resp.GetPeers() {
+		c := p.GetConfig()
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%t\n", c.GetRemoteAddress(),
+			c.GetRemoteAs(), p.GetState(),
+			uptime(p.GetSession().GetEstablished()), p.GetManaged())
+	}
+	return tw.Flush()
+}
+
+func familyString(afi, safi uint32) string {
+	return fmt.Sprintf("%d/%d", afi, safi)
+}
+
+func capabilitiesString(caps []*apipb.Capability) string {
+	s := make([]string, 0, len(caps))
+	for _, c := range caps {
+		s = append(s, fmt.Sprintf("%d", c.GetCode()))
+	}
+	return strings.Join(s, " ")
+}
+
+func showPeer(ctx context.Context, client apipb.CoreBGPClient,
+	address string, w io.Writer) error {
+	resp, err := client.GetPeer(ctx, &apipb.GetPeerRequest{Address: address})
+	if err != nil {
+		return err
+	}
+	p := resp.GetPeer()
+	c := p.GetConfig()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Remote address:\t%s\n", c.GetRemoteAddress())
+	fmt.Fprintf(tw, "Local AS:\t%d\n", c.GetLocalAs())
+	fmt.Fprintf(tw, "Remote AS:\t%d\n", c.GetRemoteAs())
+	fmt.Fprintf(tw, "State:\t%s\n", p.GetState())
+	fmt.Fprintf(tw, "Managed:\t%t\n", p.GetManaged())
+	fmt.Fprintf(tw, "Enabled:\t%t\n", p.GetEnabled())
+	if s := p.GetSession(); s != nil {
+		fmt.Fprintf(tw, "Uptime:\t%s\n", uptime(s.GetEstablished()))
+		fmt.Fprintf(tw, "Local:\t%s (router ID %s)\n", s.GetLocalAddress(),
+			s.GetLocalRouterId())
+		fmt.Fprintf(tw, "Remote:\t%s (router ID %s)\n", s.GetRemoteAddress(),
+			s.GetRemoteRouterId())
+		fmt.Fprintf(tw, "Hold time:\t%s\n",
+			time.Duration(s.GetHoldTimeMs())*time.Millisecond)
+		fmt.Fprintf(tw, "Keepalive interval:\t%s\n",
+			time.Duration(s.GetKeepaliveIntervalMs())*time.Millisecond)
+		families := make([]string, 0, len(s.GetFamilies()))
+		for _, f := range s.GetFamilies() {
+			families = append(families,
+				familyString(f.GetAfi(), f.GetSafi()))
+		}
+		fmt.Fprintf(tw, "Families:\t%s\n", strings.Join(families, " "))
+		fmt.Fprintf(tw, "Local capabilities:\t%s\n",
+			capabilitiesString(s.GetLocalCapabilities()))
+		fmt.Fprintf(tw, "Remote capabilities:\t%s\n",
+			capabilitiesString(s.GetRemoteCapabilities()))
+	}
+	if p.GetEnabled() {
+		stats, err := client.GetPeerStats(ctx,
+			&apipb.GetPeerStatsRequest{Address: address})
+		if err != nil {
+			return err
+		}
+		st := stats.GetStats()
+		fmt.Fprintf(tw, "Sessions:\t%d\n", st.GetSessions())
+		fmt.Fprintf(tw, "Notifications sent:\t%d\n", st.GetNotificationsSent())
+		fmt.Fprintf(tw, "Notifications received:\t%d\n",
+			st.GetNotificationsReceived())
+		for _, pc := range st.GetPrefixes() {
+			f := pc.GetFamily()
+			fmt.Fprintf(tw, "Prefixes %s:\taccepted %d rejected %d withdrawn %d\n",
+				familyString(f.GetAfi(), f.GetSafi()), pc.GetAccepted(),
+				pc.GetRejected(), pc.GetWithdrawn())
+		}
+	}
+	return tw.Flush()
+}
+
+// eventString returns a single line describing e.
+func eventString(e *apipb.Event) string {
+	var desc string
+	switch ev := e.GetEvent().(type) {
+	case *apipb.Event_OpenReceived:
+		desc = fmt.Sprintf("open received router-id=%s capabilities=[%s]",
+			ev.OpenReceived.GetRouterId(),
+			capabilitiesString(ev.OpenReceived.GetCapabilities()))
+	case *apipb.Event_Established:
+		desc = fmt.Sprintf("established remote=%s",
+			ev.Established.GetSession().GetRemoteAddress())
+	case *apipb.Event_Closed:
+		desc = "closed"
+	case *apipb.Event_Update:
+		m := ev.Update.GetMessage()
+		if f, ok := corebgp.IsEndOfRIB(m); ok {
+			desc = fmt.Sprintf("update end-of-rib %s",
+				familyString(uint32(f.AFI), uint32(f.SAFI)))
+		} else {
+			desc = fmt.Sprintf("update %s", hex.EncodeToString(m))
+		}
+	default:
+		desc = "unknown"
+	}
+	return fmt.Sprintf("%s %s %s", e.GetTime().AsTime().Format(time.RFC3339),
+		e.GetAddress(), desc)
+}
+
+func tail(ctx context.Context, client apipb.CoreBGPClient, address string,
+	updates bool, w io.Writer) error {
+	stream, err := client.WatchEvents(ctx, &apipb.WatchEventsRequest{
+		Address: address,
+		Updates: updates,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, eventString(e))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgpapi"
+	"github.com/jwhited/corebgp/corebgpapi/apipb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type testPlugin struct{}
+
+func (t *testPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (t *testPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (t *testPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return nil
+}
+
+func (t *testPlugin) OnClose(corebgp.PeerConfig) {}
+
+func TestRun(t *testing.T) {
+	s, err := corebgp.NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	svc := corebgpapi.NewService(s, nil)
+	err = svc.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, &testPlugin{}, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lis := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer()
+	svc.Register(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context,
+			_ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cc.Close()
+	client := apipb.NewCoreBGPClient(cc)
+
+	ctx := context.Background()
+	var out bytes.Buffer
+	assert.NoError(t, run(ctx, client, []string{"peers"}, &out))
+	assert.Equal(t, "ADDRESS    AS     STATE     UPTIME  MANAGED\n"+
+		"192.0.2.2  64513  disabled  -       true\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, run(ctx, client, []string{"show", "192.0.2.2"}, &out))
+	assert.Regexp(t, `Remote AS:\s+64513\n`, out.String())
+	assert.Regexp(t, `Enabled:\s+true\n`, out.String())
+	assert.Regexp(t, `Sessions:\s+0\n`, out.String())
+
+	assert.NoError(t, run(ctx, client,
+		[]string{"shut", "192.0.2.2", "planned", "maintenance"}, &out))
+	out.Reset()
+	assert.NoError(t, run(ctx, client, []string{"show", "192.0.2.2"}, &out))
+	assert.Regexp(t, `State:\s+admin-down\n`, out.String())
+	assert.Regexp(t, `Enabled:\s+false\n`, out.String())
+	assert.NotContains(t, out.String(), "Sessions:")
+
+	assert.NoError(t, run(ctx, client, []string{"no-shut", "192.0.2.2"}, &out))
+	assert.Error(t, run(ctx, client, []string{"shut", "invalid"}, &out))
+
+	for _, args := range [][]string{
+		{"unknown"},
+		{"peers", "extra"},
+		{"show"},
+		{"no-shut"},
+		{"tail", "-unknown"},
+		{"tail", "192.0.2.2", "extra"},
+	} {
+		assert.ErrorIs(t, run(ctx, client, args, &out), errUsage, args)
+	}
+}
+
+func TestEventString(t *testing.T) {
+	ts := timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	for _, tc := range []struct {
+		event *apipb.Event
+		want  string
+	}{
+		{
+			event: &apipb.Event{Event: &apipb.Event_OpenReceived{
+				OpenReceived: &apipb.OpenReceived{
+					RouterId: "192.0.2.2",
+					Capabilities: []*apipb.Capability{
+						{Code: 1}, {Code: 65},
+					},
+				},
+			}},
+			want: "open received router-id=192.0.2.2 capabilities=[1 65]",
+		},
+		{
+			event: &apipb.Event{Event: &apipb.Event_Established{
+				Established: &apipb.Established{Session: &apipb.Session{
+					RemoteAddress: "192.0.2.2:179",
+				}},
+			}},
+			want: "established remote=192.0.2.2:179",
+		},
+		{
+			event: &apipb.Event{Event: &apipb.Event_Closed{
+				Closed: &apipb.Closed{},
+			}},
+			want: "closed",
+		},
+		{
+			event: &apipb.Event{Event: &apipb.Event_Update{
+				Update: &apipb.Update{Message: []byte{0, 0, 0, 0}},
+			}},
+			want: "update end-of-rib 1/1",
+		},
+		{
+			event: &apipb.Event{Event: &apipb.Event_Update{
+				Update: &apipb.Update{Message: []byte{0, 0, 0, 1, 2}},
+			}},
+			want: "update 0000000102",
+		},
+	} {
+		tc.event.Address = "192.0.2.2"
+		tc.event.Time = ts
+		assert.Equal(t, "2024-01-02T03:04:05Z 192.0.2.2 "+tc.want,
+			eventString(tc.event))
+	}
+}
@@ -0,0 +1,368 @@
+package corebgpapi
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgpapi/apipb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// disabledState is the state reported for disabled peers. It is distinct
+// from the "disabled" state of a peer whose FSMs are not running, e.g. as the
+// Server is not serving.
+const disabledState = "admin-down"
+
+// grpcServer implements apipb.CoreBGPServer for a Service.
+type grpcServer struct {
+	apipb.UnimplementedCoreBGPServer
+	s *Service
+}
+
+// toStatus converts errors returned by the Service and corebgp.Server to gRPC
+// status errors.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, corebgp.ErrPeerNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, corebgp.ErrPeerAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrPeerNotManaged):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+func parseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, status.Errorf(codes.InvalidArgument,
+			"invalid address: %v", err)
+	}
+	return addr, nil
+}
+
+// peerOptions returns the PeerOptions derived from c.
+func peerOptions(c *apipb.PeerConfig) ([]corebgp.PeerOption, error) {
+	opts := make([]corebgp.PeerOption, 0)
+	if len(c.GetLocalAddress()) > 0 {
+		addr, err := parseAddr(c.GetLocalAddress())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, corebgp.WithLocalAddress(addr))
+	}
+	if c.GetPassive() {
+		opts = append(opts, corebgp.WithPassive())
+	}
+	if c.HoldTime != nil {
+		if c.GetHoldTime() > 0xffff {
+			return nil, status.Error(codes.InvalidArgument,
+				"invalid hold time")
+		}
+		opts = append(opts, corebgp.WithHoldTime(uint16(c.GetHoldTime())))
+	}
+	if c.GetMultihopTtl() > 0 {
+		if c.GetMultihopTtl() > 0xff {
+			return nil, status.Error(codes.InvalidArgument,
+				"invalid multihop ttl")
+		}
+		opts = append(opts, corebgp.WithMultihop(uint8(c.GetMultihopTtl())))
+	}
+	if len(c.GetTcpMd5Key()) > 0 {
+		opts = append(opts, corebgp.WithTCPMD5Key(c.GetTcpMd5Key()))
+	}
+	return opts, nil
+}
+
+func (g *grpcServer) AddPeer(_ context.Context,
+	req *apipb.AddPeerRequest) (*apipb.AddPeerResponse, error) {
+	if g.s.newPlugin == nil {
+		return nil, status.Error(codes.Unimplemented,
+			"adding peers is not supported")
+	}
+	c := req.GetConfig()
+	remote, err := parseAddr(c.GetRemoteAddress())
+	if err != nil {
+		return nil, err
+	}
+	opts, err := peerOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	config := corebgp.PeerConfig{
+		RemoteAddress: remote,
+		LocalAS:       c.GetLocalAs(),
+		RemoteAS:      c.GetRemoteAs(),
+	}
+	err = g.s.AddPeer(config, g.s.newPlugin(config),
+		append(slices.Clone(g.s.opts), opts...)...)
+	if err != nil {
+		if errors.Is(err, corebgp.ErrPeerAlreadyExists) {
+			return nil, toStatus(err)
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &apipb.AddPeerResponse{}, nil
+}
+
+func (g *grpcServer) DeletePeer(_ context.Context,
+	req *apipb.DeletePeerRequest) (*apipb.DeletePeerResponse, error) {
+	addr, err := parseAddr(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	err = g.s.DeletePeer(addr)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &apipb.DeletePeerResponse{}, nil
+}
+
+func (g *grpcServer) EnablePeer(_ context.Context,
+	req *apipb.EnablePeerRequest) (*apipb.EnablePeerResponse, error) {
+	addr, err := parseAddr(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	err = g.s.EnablePeer(addr)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &apipb.EnablePeerResponse{}, nil
+}
+
+func (g *grpcServer) DisablePeer(ctx context.Context,
+	req *apipb.DisablePeerRequest) (*apipb.DisablePeerResponse, error) {
+	addr, err := parseAddr(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	err = g.s.DisablePeer(ctx, addr, req.GetCommunication())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &apipb.DisablePeerResponse{}, nil
+}
+
+// peers returns the state of all peers ordered by remote address, including
+// disabled peers.
+func (g *grpcServer) peers() []*apipb.Peer {
+	type addrPeer struct {
+		addr netip.Addr
+		peer *apipb.Peer
+	}
+	snap := g.s.server.Snapshot()
+	peers := make([]addrPeer, 0, len(snap.Peers))
+	for _, ps := range snap.Peers {
+		peers = append(peers, addrPeer{ps.Config.RemoteAddress, g.newPeer(ps)})
+	}
+	for _, c := range g.s.disabledPeers() {
+		peers = append(peers, addrPeer{c.RemoteAddress, &apipb.Peer{
+			Config:  newPeerConfig(c),
+			State:   disabledState,
+			Managed: true,
+		}})
+	}
+	slices.SortFunc(peers, func(a, b addrPeer) int {
+		return a.addr.Compare(b.addr)
+	})
+	out := make([]*apipb.Peer, 0, len(peers))
+	for _, p := range peers {
+		out = append(out, p.peer)
+	}
+	return out
+}
+
+func (g *grpcServer) newPeer(ps corebgp.PeerSnapshot) *apipb.Peer {
+	p := &apipb.Peer{
+		Config:  newPeerConfig(ps.Config),
+		State:   ps.State,
+		Managed: g.s.managed(ps.Config.RemoteAddress),
+		Enabled: true,
+	}
+	if ps.Session != nil {
+		info, err := g.s.server.GetSessionInfo(ps.Config.RemoteAddress)
+		if err == nil {
+			p.Session = newSession(info, ps.Session.Established)
+		}
+	}
+	return p
+}
+
+func (g *grpcServer) ListPeers(context.Context,
+	*apipb.ListPeersRequest) (*apipb.ListPeersResponse, error) {
+	return &apipb.ListPeersResponse{Peers: g.peers()}, nil
+}
+
+func (g *grpcServer) GetPeer(_ context.Context,
+	req *apipb.GetPeerRequest) (*apipb.GetPeerResponse, error) {
+	addr, err := parseAddr(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range g.peers() {
+		if p.GetConfig().GetRemoteAddress() == addr.String() {
+			return &apipb.GetPeerResponse{Peer: p}, nil
+		}
+	}
+	return nil, toStatus(corebgp.ErrPeerNotExist)
+}
+
+func (g *grpcServer) GetPeerStats(_ context.Context,
+	req *apipb.GetPeerStatsRequest) (*apipb.GetPeerStatsResponse, error) {
+	addr, err := parseAddr(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	for _, ps := range g.s.server.Snapshot().Peers {
+		if ps.Config.RemoteAddress != addr {
+			continue
+		}
+		stats, err := g.s.server.GetPeerStats(addr)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		return &apipb.GetPeerStatsResponse{
+			Stats: newPeerStats(stats, ps.Counters),
+		}, nil
+	}
+	return nil, toStatus(corebgp.ErrPeerNotExist)
+}
+
+func (g *grpcServer) WatchEvents(req *apipb.WatchEventsRequest,
+	stream apipb.CoreBGP_WatchEventsServer) error {
+	var addr netip.Addr
+	if len(req.GetAddress()) > 0 {
+		var err error
+		addr, err = parseAddr(req.GetAddress())
+		if err != nil {
+			return err
+		}
+	}
+	w := g.s.addWatcher(addr, req.GetUpdates())
+	defer g.s.removeWatcher(w)
+	// signal that events are being watched, subsequent events are sent
+	err := stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return toStatus(stream.Context().Err())
+		case <-w.dropped:
+			return status.Error(codes.ResourceExhausted,
+				"event stream fell behind")
+		case e := <-w.ch:
+			err := stream.Send(e)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func addrString(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+	return addr.String()
+}
+
+func newPeerConfig(c corebgp.PeerSnapshotConfig) *apipb.PeerConfig {
+	holdTime := uint32(c.Timers.HoldTime / time.Second.Milliseconds())
+	return &apipb.PeerConfig{
+		RemoteAddress: c.RemoteAddress.String(),
+		LocalAs:       c.LocalAS,
+		RemoteAs:      c.RemoteAS,
+		LocalAddress:  addrString(c.LocalAddress),
+		Passive:       c.TransportMode == corebgp.TransportModePassive.String(),
+		HoldTime:      &holdTime,
+		MultihopTtl:   uint32(c.TTL),
+	}
+}
+
+func newCapabilities(caps []corebgp.Capability) []*apipb.Capability {
+	out := make([]*apipb.Capability, 0, len(caps))
+	for _, c := range caps {
+		out = append(out, &apipb.Capability{
+			Code:  uint32(c.Code),
+			Value: c.Value,
+		})
+	}
+	return out
+}
+
+func newFamilies(families []corebgp.MPExtensions) []*apipb.Family {
+	out := make([]*apipb.Family, 0, len(families))
+	for _, f := range families {
+		out = append(out, &apipb.Family{
+			Afi:  uint32(f.AFI),
+			Safi: uint32(f.SAFI),
+		})
+	}
+	return out
+}
+
+func newSession(info corebgp.SessionInfo,
+	established *time.Time) *apipb.Session {
+	s := &apipb.Session{
+		LocalAddress:        info.LocalAddress.String(),
+		RemoteAddress:       info.RemoteAddress.String(),
+		LocalRouterId:       addrString(info.LocalRouterID),
+		RemoteRouterId:      addrString(info.RemoteRouterID),
+		HoldTimeMs:          info.HoldTime.Milliseconds(),
+		KeepaliveIntervalMs: info.KeepaliveInterval.Milliseconds(),
+		LocalCapabilities:   newCapabilities(info.LocalCapabilities),
+		RemoteCapabilities:  newCapabilities(info.RemoteCapabilities),
+		Families:            newFamilies(info.Families),
+		ExtendedMessage:     info.ExtendedMessage,
+	}
+	if established != nil {
+		s.Established = timestamppb.New(*established)
+	}
+	for _, t := range info.AddPath {
+		s.AddPath = append(s.AddPath, &apipb.AddPath{
+			Afi:  uint32(t.AFI),
+			Safi: uint32(t.SAFI),
+			Tx:   t.Tx,
+			Rx:   t.Rx,
+		})
+	}
+	return s
+}
+
+func newPeerStats(stats corebgp.PeerStats,
+	counters corebgp.PeerSnapshotCounters) *apipb.PeerStats {
+	s := &apipb.PeerStats{
+		UpdateErrorsIgnored:   stats.UpdateErrorsIgnored,
+		UpdatesRateLimited:    stats.UpdatesRateLimited,
+		FamilyMismatches:      stats.FamilyMismatches,
+		Sessions:              uint64(counters.Sessions),
+		NotificationsSent:     uint64(counters.NotificationsSent),
+		NotificationsReceived: uint64(counters.NotificationsReceived),
+	}
+	for _, p := range stats.Prefixes {
+		s.Prefixes = append(s.Prefixes, &apipb.FamilyPrefixCounts{
+			Family: &apipb.Family{
+				Afi:  uint32(p.Family.AFI),
+				Safi: uint32(p.Family.SAFI),
+			},
+			Accepted:  p.Accepted,
+			Rejected:  p.Rejected,
+			Withdrawn: p.Withdrawn,
+		})
+	}
+	return s
+}
@@ -0,0 +1,341 @@
+// Package corebgpapi provides a gRPC management API for a corebgp.Server,
+// allowing external tooling to add, delete, enable, and disable peers, query
+// their state and statistics, and stream their FSM events and UPDATE messages.
+// It is a separate module so that corebgp itself does not depend on gRPC. The
+// API is defined in package apipb.
+//
+// Peers added via a Service, either by the AddPeer RPC or the AddPeer method,
+// are managed by it. Only managed peers may be enabled and disabled, and
+// report events. All peers of the Server may be queried.
+package corebgpapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgpapi/apipb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrPeerNotManaged is returned when enabling or disabling a peer that was
+// not added via the Service.
+var ErrPeerNotManaged = errors.New("peer is not managed by the service")
+
+// watchBufferSize is the number of events buffered for each WatchEvents
+// stream. A stream is closed if it falls further behind.
+const watchBufferSize = 1024
+
+// Service manages the peers of a corebgp.Server. It is exposed via gRPC with
+// Register.
+type Service struct {
+	server    *corebgp.Server
+	newPlugin func(peer corebgp.PeerConfig) corebgp.Plugin
+	opts      []corebgp.PeerOption
+
+	// mu serializes changes to peers. Plugin callbacks do not acquire it, so
+	// it may be held while calling Server methods that wait on FSMs.
+	mu    sync.Mutex
+	peers map[netip.Addr]*managedPeer
+
+	watchMu  sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+// NewService returns a new Service for server. newPlugin returns the Plugin
+// for peers added via the AddPeer RPC, which is unimplemented if it is nil.
+// opts are applied to those peers ahead of the options derived from the
+// request, e.g. to set a DialFunc.
+func NewService(server *corebgp.Server,
+	newPlugin func(peer corebgp.PeerConfig) corebgp.Plugin,
+	opts ...corebgp.PeerOption) *Service {
+	return &Service{
+		server:    server,
+		newPlugin: newPlugin,
+		opts:      opts,
+		peers:     make(map[netip.Addr]*managedPeer),
+		watchers:  make(map[*watcher]struct{}),
+	}
+}
+
+// Register registers the Service with r, e.g. a *grpc.Server.
+func (s *Service) Register(r grpc.ServiceRegistrar) {
+	apipb.RegisterCoreBGPServer(r, &grpcServer{s: s})
+}
+
+type managedPeer struct {
+	config corebgp.PeerConfig
+	// plugin layers the Service's eventPlugin ahead of the application's
+	plugin  corebgp.Plugin
+	opts    []corebgp.PeerOption
+	enabled bool
+	// disabled is the configuration of the peer as of when it was disabled,
+	// as it is no longer reported by the Server.
+	disabled *corebgp.PeerSnapshotConfig
+
+	// writer and closed are set while the peer is established, closed is
+	// closed once it is no longer
+	mu     sync.Mutex
+	writer corebgp.UpdateMessageWriter
+	closed chan struct{}
+}
+
+// AddPeer adds a peer to the Server that is managed by the Service, see
+// corebgp.Server.AddPeer.
+func (s *Service) AddPeer(config corebgp.PeerConfig, plugin corebgp.Plugin,
+	opts ...corebgp.PeerOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.peers[config.RemoteAddress]; exists {
+		return corebgp.ErrPeerAlreadyExists
+	}
+	p := &managedPeer{
+		config:  config,
+		opts:    opts,
+		enabled: true,
+	}
+	p.plugin = corebgp.NewPluginChain(&eventPlugin{s: s, p: p}, plugin)
+	err := s.server.AddPeer(config, p.plugin, opts...)
+	if err != nil {
+		return err
+	}
+	s.peers[config.RemoteAddress] = p
+	return nil
+}
+
+// DeletePeer deletes a peer from the Server, see corebgp.Server.DeletePeer.
+// A disabled peer is forgotten by the Service.
+func (s *Service) DeletePeer(ip netip.Addr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, managed := s.peers[ip]
+	if managed && !p.enabled {
+		delete(s.peers, ip)
+		return nil
+	}
+	err := s.server.DeletePeer(ip)
+	if err != nil {
+		return err
+	}
+	delete(s.peers, ip)
+	return nil
+}
+
+// DisablePeer closes the session of a managed peer, if any, with an
+// Administrative Shutdown Cease Notification carrying communication, and
+// removes the peer from the Server until it is enabled with EnablePeer. It
+// returns once the session has closed, or ctx is done. Disabling a disabled
+// peer has no effect.
+func (s *Service) DisablePeer(ctx context.Context, ip netip.Addr,
+	communication string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, managed := s.peers[ip]
+	if !managed {
+		return ErrPeerNotManaged
+	}
+	if !p.enabled {
+		return nil
+	}
+	var config *corebgp.PeerSnapshotConfig
+	for _, ps := range s.server.Snapshot().Peers {
+		if ps.Config.RemoteAddress == ip {
+			config = &ps.Config
+			break
+		}
+	}
+	p.mu.Lock()
+	w, closed := p.writer, p.closed
+	p.mu.Unlock()
+	if sw, ok := w.(corebgp.SessionWriter); ok {
+		n := corebgp.NewAdminShutdownNotification(communication)
+		if sw.WriteNotification(n, true) == nil {
+			select {
+			case <-closed:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	err := s.server.DeletePeer(ip)
+	if err != nil && !errors.Is(err, corebgp.ErrPeerNotExist) {
+		return err
+	}
+	p.enabled = false
+	p.disabled = config
+	return nil
+}
+
+// EnablePeer adds a managed peer disabled with DisablePeer back to the
+// Server. Enabling an enabled peer has no effect.
+func (s *Service) EnablePeer(ip netip.Addr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, managed := s.peers[ip]
+	if !managed {
+		return ErrPeerNotManaged
+	}
+	if p.enabled {
+		return nil
+	}
+	err := s.server.AddPeer(p.config, p.plugin, p.opts...)
+	if err != nil {
+		return err
+	}
+	p.enabled = true
+	p.disabled = nil
+	return nil
+}
+
+// managed returns true if ip is managed by the Service.
+func (s *Service) managed(ip netip.Addr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, managed := s.peers[ip]
+	return managed
+}
+
+// disabledPeers returns the configuration of all disabled peers.
+func (s *Service) disabledPeers() []corebgp.PeerSnapshotConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := make([]corebgp.PeerSnapshotConfig, 0)
+	for ip, p := range s.peers {
+		if p.enabled {
+			continue
+		}
+		if p.disabled != nil {
+			configs = append(configs, *p.disabled)
+			continue
+		}
+		configs = append(configs, corebgp.PeerSnapshotConfig{
+			RemoteAddress: ip,
+			LocalAS:       p.config.LocalAS,
+			RemoteAS:      p.config.RemoteAS,
+		})
+	}
+	return configs
+}
+
+type watcher struct {
+	address netip.Addr // the zero value matches all peers
+	updates bool
+	ch      chan *apipb.Event
+	// dropped is closed if the watcher is dropped for falling behind
+	dropped chan struct{}
+}
+
+func (s *Service) addWatcher(address netip.Addr, updates bool) *watcher {
+	w := &watcher{
+		address: address,
+		updates: updates,
+		ch:      make(chan *apipb.Event, watchBufferSize),
+		dropped: make(chan struct{}),
+	}
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+func (s *Service) removeWatcher(w *watcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	delete(s.watchers, w)
+}
+
+// publish sends the event returned by newEvent to the watchers of peer.
+// newEvent is only called if there is at least one such watcher. update
+// indicates whether the event is an UPDATE message.
+func (s *Service) publish(peer netip.Addr, update bool,
+	newEvent func() *apipb.Event) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	var e *apipb.Event
+	for w := range s.watchers {
+		if (w.address.IsValid() && w.address != peer) ||
+			(update && !w.updates) {
+			continue
+		}
+		if e == nil {
+			e = newEvent()
+			e.Address = peer.String()
+			e.Time = timestamppb.Now()
+		}
+		select {
+		case w.ch <- e:
+		default:
+			delete(s.watchers, w)
+			close(w.dropped)
+		}
+	}
+}
+
+// eventPlugin publishes the FSM events and UPDATE messages of a managed peer,
+// and tracks its session writer.
+type eventPlugin struct {
+	s *Service
+	p *managedPeer
+}
+
+func (e *eventPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (e *eventPlugin) OnOpenMessage(peer corebgp.PeerConfig,
+	routerID netip.Addr, capabilities []corebgp.Capability) *corebgp.Notification {
+	e.s.publish(peer.RemoteAddress, false, func() *apipb.Event {
+		return &apipb.Event{Event: &apipb.Event_OpenReceived{
+			OpenReceived: &apipb.OpenReceived{
+				RouterId:     routerID.String(),
+				Capabilities: newCapabilities(capabilities),
+			},
+		}}
+	})
+	return nil
+}
+
+func (e *eventPlugin) OnEstablished(peer corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	e.p.mu.Lock()
+	e.p.writer = writer
+	e.p.closed = make(chan struct{})
+	e.p.mu.Unlock()
+	e.s.publish(peer.RemoteAddress, false, func() *apipb.Event {
+		established := &apipb.Established{}
+		if sw, ok := writer.(corebgp.SessionWriter); ok {
+			established.Session = newSession(sw.SessionInfo(), nil)
+		}
+		return &apipb.Event{Event: &apipb.Event_Established{
+			Established: established,
+		}}
+	})
+	return func(peer corebgp.PeerConfig, update []byte) *corebgp.Notification {
+		e.s.publish(peer.RemoteAddress, true, func() *apipb.Event {
+			// the message may be released once handling completes
+			return &apipb.Event{Event: &apipb.Event_Update{
+				Update: &apipb.Update{Message: bytes.Clone(update)},
+			}}
+		})
+		return nil
+	}
+}
+
+func (e *eventPlugin) OnClose(peer corebgp.PeerConfig) {
+	e.p.mu.Lock()
+	e.p.writer = nil
+	if e.p.closed != nil {
+		close(e.p.closed)
+		e.p.closed = nil
+	}
+	e.p.mu.Unlock()
+	e.s.publish(peer.RemoteAddress, false, func() *apipb.Event {
+		return &apipb.Event{Event: &apipb.Event_Closed{
+			Closed: &apipb.Closed{},
+		}}
+	})
+}
@@ -0,0 +1,235 @@
+package corebgpapi
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgpapi/apipb"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testPlugin writes update to the peer once established.
+type testPlugin struct {
+	update []byte
+}
+
+func (t *testPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (t *testPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (t *testPlugin) OnEstablished(_ corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	if t.update != nil {
+		writer.WriteUpdate(t.update) // nolint: errcheck
+	}
+	return nil
+}
+
+func (t *testPlugin) OnClose(corebgp.PeerConfig) {}
+
+// newTestServer returns a running corebgp.Server listening at local on n.
+func newTestServer(t *testing.T, n *corebgptest.Network,
+	local netip.Addr) *corebgp.Server {
+	s, err := corebgp.NewServer(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	l, err := n.Listen(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	go s.Serve([]net.Listener{l})
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestClient returns a client of svc connected via an in-memory listener.
+func newTestClient(t *testing.T, svc *Service) apipb.CoreBGPClient {
+	lis := bufconn.Listen(1 << 16)
+	gs := grpc.NewServer()
+	svc.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context,
+			_ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		cc.Close()
+	})
+	return apipb.NewCoreBGPClient(cc)
+}
+
+func TestService(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	eor := []byte{0, 0, 0, 0}
+
+	// B sends an End-of-RIB marker to A once established
+	sB := newTestServer(t, n, addrB)
+	err := sB.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: addrA,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, &testPlugin{update: eor}, corebgp.WithLocalAddress(addrB),
+		corebgp.WithIdleHoldTime(time.Millisecond*100),
+		corebgp.WithDialer(n.Dialer(addrB)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sA := newTestServer(t, n, addrA)
+	svc := NewService(sA, func(corebgp.PeerConfig) corebgp.Plugin {
+		return &testPlugin{}
+	}, corebgp.WithIdleHoldTime(time.Millisecond*100),
+		corebgp.WithDialer(n.Dialer(addrA)))
+	client := newTestClient(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	events, err := client.WatchEvents(ctx,
+		&apipb.WatchEventsRequest{Updates: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = events.Header()
+	if !assert.NoError(t, err) {
+		return
+	}
+	nextEvent := func() *apipb.Event {
+		e, err := events.Recv()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, addrB.String(), e.GetAddress())
+		return e
+	}
+
+	_, err = client.AddPeer(ctx, &apipb.AddPeerRequest{
+		Config: &apipb.PeerConfig{RemoteAddress: "invalid"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	holdTime := uint32(30)
+	_, err = client.AddPeer(ctx, &apipb.AddPeerRequest{
+		Config: &apipb.PeerConfig{
+			RemoteAddress: addrB.String(),
+			LocalAs:       64512,
+			RemoteAs:      64512,
+			LocalAddress:  addrA.String(),
+			HoldTime:      &holdTime,
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = client.AddPeer(ctx, &apipb.AddPeerRequest{
+		Config: &apipb.PeerConfig{
+			RemoteAddress: addrB.String(),
+			LocalAs:       64512,
+			RemoteAs:      64512,
+		},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	assert.Equal(t, addrB.String(),
+		nextEvent().GetOpenReceived().GetRouterId())
+	established := nextEvent().GetEstablished()
+	assert.Equal(t, int64(30000), established.GetSession().GetHoldTimeMs())
+	assert.Equal(t, eor, nextEvent().GetUpdate().GetMessage())
+
+	peer, err := client.GetPeer(ctx,
+		&apipb.GetPeerRequest{Address: addrB.String()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "established", peer.GetPeer().GetState())
+	assert.True(t, peer.GetPeer().GetManaged())
+	assert.True(t, peer.GetPeer().GetEnabled())
+	assert.Equal(t, addrA.String(), peer.GetPeer().GetConfig().GetLocalAddress())
+	assert.Equal(t, uint32(30), peer.GetPeer().GetConfig().GetHoldTime())
+	assert.Equal(t, addrB.String()+":179",
+		peer.GetPeer().GetSession().GetRemoteAddress())
+	_, err = client.GetPeer(ctx,
+		&apipb.GetPeerRequest{Address: "192.0.2.3"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stats, err := client.GetPeerStats(ctx,
+		&apipb.GetPeerStatsRequest{Address: addrB.String()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint64(1), stats.GetStats().GetSessions())
+
+	// disabling closes the session with an Administrative Shutdown
+	_, err = client.DisablePeer(ctx, &apipb.DisablePeerRequest{
+		Address:       addrB.String(),
+		Communication: "maintenance",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, nextEvent().GetClosed())
+	last, err := sB.GetLastNotifications(addrA)
+	if assert.NoError(t, err) && assert.NotNil(t, last.Received) {
+		communication, ok := last.Received.Notification.ShutdownCommunication()
+		assert.True(t, ok)
+		assert.Equal(t, "maintenance", communication)
+	}
+	peers, err := client.ListPeers(ctx, &apipb.ListPeersRequest{})
+	if assert.NoError(t, err) && assert.Len(t, peers.GetPeers(), 1) {
+		p := peers.GetPeers()[0]
+		assert.Equal(t, disabledState, p.GetState())
+		assert.False(t, p.GetEnabled())
+		assert.Equal(t, addrA.String(), p.GetConfig().GetLocalAddress())
+	}
+
+	// enabling re-establishes the session
+	_, err = client.EnablePeer(ctx,
+		&apipb.EnablePeerRequest{Address: addrB.String()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, nextEvent().GetOpenReceived())
+	assert.NotNil(t, nextEvent().GetEstablished())
+
+	_, err = client.DeletePeer(ctx,
+		&apipb.DeletePeerRequest{Address: addrB.String()})
+	assert.NoError(t, err)
+	peers, err = client.ListPeers(ctx, &apipb.ListPeersRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, peers.GetPeers())
+
+	// peers not added via the Service cannot be disabled
+	err = sA.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, &testPlugin{}, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = client.DisablePeer(ctx,
+		&apipb.DisablePeerRequest{Address: addrB.String()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
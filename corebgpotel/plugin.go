@@ -0,0 +1,291 @@
+// Package corebgpotel provides OpenTelemetry instrumentation for corebgp. It
+// is a separate module so that corebgp itself does not depend on
+// OpenTelemetry.
+//
+// A Plugin is instrumented by wrapping it with NewPlugin, or a ContextPlugin
+// with NewContextPlugin, which record a span for each session establishment
+// attempt and for each invocation of the UpdateMessageHandler, along with
+// session and UPDATE message metrics. The counters of corebgp.PeerStats are
+// bridged to OpenTelemetry metrics via RegisterPeerStats.
+package corebgpotel
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/jwhited/corebgp/corebgpotel"
+
+// Attribute keys describing a peer.
+const (
+	PeerAddressKey = attribute.Key("bgp.peer.address")
+	PeerASKey      = attribute.Key("bgp.peer.as")
+	LocalASKey     = attribute.Key("bgp.local.as")
+	RouterIDKey    = attribute.Key("bgp.peer.router_id")
+)
+
+// Option configures the instrumentation.
+type Option func(o *options)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTracerProvider returns an Option that sets the TracerProvider spans are
+// recorded with. It defaults to the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider returns an Option that sets the MeterProvider metrics are
+// recorded with. It defaults to the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = mp
+	}
+}
+
+func peerAttrs(peer corebgp.PeerConfig) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		PeerAddressKey.String(peer.RemoteAddress.String()),
+		PeerASKey.Int64(int64(peer.RemoteAS)),
+		LocalASKey.Int64(int64(peer.LocalAS)),
+	}
+}
+
+var errNotEstablished = errors.New("session not established")
+
+type plugin struct {
+	p      corebgp.ContextPlugin
+	tracer trace.Tracer
+
+	establishments metric.Int64Counter
+	established    metric.Int64UpDownCounter
+	updates        metric.Int64Counter
+	updateDuration metric.Float64Histogram
+
+	mu sync.Mutex
+	// establishing holds the span of the establishment attempt in progress
+	// for each peer
+	establishing map[netip.Addr]trace.Span
+	// sessions holds the span context of the establishment of each
+	// established session, which the spans of its UPDATE messages are linked
+	// to
+	sessions map[netip.Addr]trace.SpanContext
+}
+
+// NewPlugin returns a corebgp.Plugin that instruments p. It returns an error
+// if the metric instruments cannot be created. p is passed no context, use
+// NewContextPlugin to instrument a corebgp.ContextPlugin.
+func NewPlugin(p corebgp.Plugin, opts ...Option) (corebgp.Plugin, error) {
+	cp, err := NewContextPlugin(&contextAdapter{p: p}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return corebgp.NewContextPlugin(cp), nil
+}
+
+// NewContextPlugin returns a corebgp.ContextPlugin that instruments p. The
+// contexts passed to p carry the span of the session establishment attempt,
+// or of the UPDATE message being handled. It returns an error if the metric
+// instruments cannot be created.
+func NewContextPlugin(p corebgp.ContextPlugin,
+	opts ...Option) (corebgp.ContextPlugin, error) {
+	o := newOptions(opts)
+	meter := o.meterProvider.Meter(instrumentationName)
+	pl := &plugin{
+		p:            p,
+		tracer:       o.tracerProvider.Tracer(instrumentationName),
+		establishing: make(map[netip.Addr]trace.Span),
+		sessions:     make(map[netip.Addr]trace.SpanContext),
+	}
+	var err error
+	pl.establishments, err = meter.Int64Counter("bgp.session.establishments",
+		metric.WithDescription("Number of sessions established."))
+	if err != nil {
+		return nil, err
+	}
+	pl.established, err = meter.Int64UpDownCounter("bgp.sessions.established",
+		metric.WithDescription("Number of sessions currently established."))
+	if err != nil {
+		return nil, err
+	}
+	pl.updates, err = meter.Int64Counter("bgp.updates.received",
+		metric.WithDescription("Number of UPDATE messages received."))
+	if err != nil {
+		return nil, err
+	}
+	pl.updateDuration, err = meter.Float64Histogram(
+		"bgp.update.handler.duration",
+		metric.WithDescription("Duration of UpdateMessageHandler invocations."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// endEstablishingLocked ends the span of the establishment attempt in
+// progress for peer, if any, recording err. It must be called with p.mu held.
+func (p *plugin) endEstablishingLocked(peer corebgp.PeerConfig, err error) {
+	span, ok := p.establishing[peer.RemoteAddress]
+	if !ok {
+		return
+	}
+	delete(p.establishing, peer.RemoteAddress)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// GetCapabilities starts the span of a session establishment attempt. It is
+// fired in the Connect state, prior to sending an OPEN message.
+func (p *plugin) GetCapabilities(ctx context.Context,
+	peer corebgp.PeerConfig) []corebgp.Capability {
+	ctx, span := p.tracer.Start(ctx, "bgp.session.establish",
+		trace.WithAttributes(peerAttrs(peer)...))
+	p.mu.Lock()
+	p.endEstablishingLocked(peer, errNotEstablished)
+	p.establishing[peer.RemoteAddress] = span
+	p.mu.Unlock()
+	return p.p.GetCapabilities(ctx, peer)
+}
+
+func (p *plugin) establishingContext(ctx context.Context,
+	peer corebgp.PeerConfig) (context.Context, trace.Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	span, ok := p.establishing[peer.RemoteAddress]
+	if !ok {
+		return ctx, nil
+	}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (p *plugin) OnOpenMessage(ctx context.Context, peer corebgp.PeerConfig,
+	routerID netip.Addr, capabilities []corebgp.Capability) *corebgp.Notification {
+	ctx, span := p.establishingContext(ctx, peer)
+	if span != nil {
+		span.AddEvent("open received", trace.WithAttributes(
+			RouterIDKey.String(routerID.String())))
+	}
+	n := p.p.OnOpenMessage(ctx, peer, routerID, capabilities)
+	if n != nil {
+		p.mu.Lock()
+		p.endEstablishingLocked(peer, n)
+		p.mu.Unlock()
+	}
+	return n
+}
+
+func (p *plugin) OnEstablished(ctx context.Context, peer corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.ContextUpdateMessageHandler {
+	ctx, span := p.establishingContext(ctx, peer)
+	attrs := metric.WithAttributes(peerAttrs(peer)...)
+	p.establishments.Add(ctx, 1, attrs)
+	p.established.Add(ctx, 1, attrs)
+	handler := p.p.OnEstablished(ctx, peer, writer)
+	var session trace.SpanContext
+	if span != nil {
+		session = span.SpanContext()
+	}
+	p.mu.Lock()
+	p.sessions[peer.RemoteAddress] = session
+	p.endEstablishingLocked(peer, nil)
+	p.mu.Unlock()
+	if handler == nil {
+		return nil
+	}
+	return func(ctx context.Context, peer corebgp.PeerConfig,
+		updateMessage []byte) *corebgp.Notification {
+		spanOpts := []trace.SpanStartOption{trace.WithAttributes(
+			append(peerAttrs(peer),
+				attribute.Int("bgp.update.length", len(updateMessage)))...)}
+		if session.IsValid() {
+			spanOpts = append(spanOpts,
+				trace.WithLinks(trace.Link{SpanContext: session}))
+		}
+		ctx, span := p.tracer.Start(ctx, "bgp.update", spanOpts...)
+		defer span.End()
+		start := time.Now()
+		n := handler(ctx, peer, updateMessage)
+		p.updates.Add(ctx, 1, attrs)
+		p.updateDuration.Record(ctx, time.Since(start).Seconds(), attrs)
+		if n != nil && n != corebgp.UpdateConsumed {
+			span.RecordError(n)
+			span.SetStatus(codes.Error, n.Error())
+		}
+		return n
+	}
+}
+
+func (p *plugin) OnClose(peer corebgp.PeerConfig) {
+	p.mu.Lock()
+	_, established := p.sessions[peer.RemoteAddress]
+	delete(p.sessions, peer.RemoteAddress)
+	p.mu.Unlock()
+	if established {
+		p.established.Add(context.Background(), -1,
+			metric.WithAttributes(peerAttrs(peer)...))
+	}
+	p.p.OnClose(peer)
+}
+
+// contextAdapter is a corebgp.ContextPlugin for a corebgp.Plugin.
+type contextAdapter struct {
+	p corebgp.Plugin
+}
+
+func (c *contextAdapter) GetCapabilities(_ context.Context,
+	peer corebgp.PeerConfig) []corebgp.Capability {
+	return c.p.GetCapabilities(peer)
+}
+
+func (c *contextAdapter) OnOpenMessage(_ context.Context,
+	peer corebgp.PeerConfig, routerID netip.Addr,
+	capabilities []corebgp.Capability) *corebgp.Notification {
+	return c.p.OnOpenMessage(peer, routerID, capabilities)
+}
+
+func (c *contextAdapter) OnEstablished(_ context.Context,
+	peer corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.ContextUpdateMessageHandler {
+	handler := c.p.OnEstablished(peer, writer)
+	if handler == nil {
+		return nil
+	}
+	return func(_ context.Context, peer corebgp.PeerConfig,
+		updateMessage []byte) *corebgp.Notification {
+		return handler(peer, updateMessage)
+	}
+}
+
+func (c *contextAdapter) OnClose(peer corebgp.PeerConfig) {
+	c.p.OnClose(peer)
+}
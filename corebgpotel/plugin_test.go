@@ -0,0 +1,183 @@
+package corebgpotel
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type testPlugin struct {
+	openNotification   *corebgp.Notification
+	updateNotification *corebgp.Notification
+}
+
+func (t *testPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (t *testPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return t.openNotification
+}
+
+func (t *testPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return func(corebgp.PeerConfig, []byte) *corebgp.Notification {
+		return t.updateNotification
+	}
+}
+
+func (t *testPlugin) OnClose(corebgp.PeerConfig) {}
+
+func TestPlugin(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	inner := &testPlugin{}
+	cp, err := NewContextPlugin(&contextAdapter{p: inner},
+		WithTracerProvider(tp), WithMeterProvider(mp))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx := context.Background()
+	peer := corebgp.PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.1"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	routerID := netip.MustParseAddr("192.0.2.1")
+
+	// an attempt ending without an OPEN message, and one rejected by the
+	// plugin
+	cp.GetCapabilities(ctx, peer)
+	cp.GetCapabilities(ctx, peer)
+	inner.openNotification = &corebgp.Notification{
+		Code: corebgp.NOTIF_CODE_OPEN_MESSAGE_ERR}
+	cp.OnOpenMessage(ctx, peer, routerID, nil)
+	inner.openNotification = nil
+
+	cp.GetCapabilities(ctx, peer)
+	cp.OnOpenMessage(ctx, peer, routerID, nil)
+	handler := cp.OnEstablished(ctx, peer, nil)
+	if !assert.NotNil(t, handler) {
+		return
+	}
+	handler(ctx, peer, []byte{0, 0, 0, 0})
+	inner.updateNotification = &corebgp.Notification{
+		Code: corebgp.NOTIF_CODE_UPDATE_MESSAGE_ERR}
+	handler(ctx, peer, []byte{0, 0, 0, 0})
+	cp.OnClose(peer)
+
+	spans := sr.Ended()
+	if !assert.Len(t, spans, 5) {
+		return
+	}
+	for _, s := range spans[:3] {
+		assert.Equal(t, "bgp.session.establish", s.Name())
+	}
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Len(t, spans[1].Events(), 2) // open received, exception
+	assert.Equal(t, codes.Unset, spans[2].Status().Code)
+	for _, s := range spans[3:] {
+		assert.Equal(t, "bgp.update", s.Name())
+		if assert.Len(t, s.Links(), 1) {
+			assert.Equal(t, spans[2].SpanContext(), s.Links()[0].SpanContext)
+		}
+	}
+	assert.Equal(t, codes.Unset, spans[3].Status().Code)
+	assert.Equal(t, codes.Error, spans[4].Status().Code)
+
+	var rm metricdata.ResourceMetrics
+	if !assert.NoError(t, reader.Collect(ctx, &rm)) {
+		return
+	}
+	sums := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					sums[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"bgp.session.establishments": 1,
+		"bgp.sessions.established":   0,
+		"bgp.updates.received":       2,
+	}, sums)
+}
+
+func TestRegisterPeerStats(t *testing.T) {
+	s, err := corebgp.NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	err = s.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, &testPlugin{}, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = s.AddPrefixCounts(netip.MustParseAddr("192.0.2.2"),
+		corebgp.MPExtensions{AFI: corebgp.AFI_IPV6, SAFI: corebgp.SAFI_UNICAST},
+		corebgp.PrefixCounts{Accepted: 3, Rejected: 2, Withdrawn: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	reg, err := RegisterPeerStats(s, WithMeterProvider(mp))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer reg.Unregister()
+
+	var rm metricdata.ResourceMetrics
+	if !assert.NoError(t, reader.Collect(context.Background(), &rm)) {
+		return
+	}
+	names := make([]string, 0)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !assert.True(t, ok) {
+				continue
+			}
+			if m.Name != "bgp.peer.prefixes" {
+				if assert.Len(t, sum.DataPoints, 1) {
+					assert.Equal(t, int64(0), sum.DataPoints[0].Value)
+				}
+				continue
+			}
+			prefixes := make(map[string]int64)
+			for _, dp := range sum.DataPoints {
+				afi, _ := dp.Attributes.Value(AFIKey)
+				assert.Equal(t, int64(corebgp.AFI_IPV6), afi.AsInt64())
+				result, _ := dp.Attributes.Value(PrefixResultKey)
+				prefixes[result.AsString()] = dp.Value
+			}
+			assert.Equal(t, map[string]int64{
+				"accepted":  3,
+				"rejected":  2,
+				"withdrawn": 1,
+			}, prefixes)
+		}
+	}
+	assert.ElementsMatch(t, []string{"bgp.peer.update_errors_ignored",
+		"bgp.peer.updates_rate_limited", "bgp.peer.prefixes"}, names)
+}
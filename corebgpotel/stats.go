@@ -0,0 +1,85 @@
+package corebgpotel
+
+import (
+	"context"
+
+	"github.com/jwhited/corebgp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Attribute keys describing the prefix counters of a peer.
+const (
+	AFIKey          = attribute.Key("bgp.afi")
+	SAFIKey         = attribute.Key("bgp.safi")
+	PrefixResultKey = attribute.Key("bgp.prefix.result")
+)
+
+// RegisterPeerStats registers asynchronous counters reporting the
+// corebgp.PeerStats of each peer of s, i.e. bgp.peer.update_errors_ignored,
+// bgp.peer.updates_rate_limited, and bgp.peer.prefixes. The latter is
+// reported per AFI/SAFI and PrefixResultKey, one of accepted, rejected, or
+// withdrawn. The returned Registration may be used to unregister them.
+func RegisterPeerStats(s *corebgp.Server,
+	opts ...Option) (metric.Registration, error) {
+	o := newOptions(opts)
+	meter := o.meterProvider.Meter(instrumentationName)
+	errorsIgnored, err := meter.Int64ObservableCounter(
+		"bgp.peer.update_errors_ignored",
+		metric.WithDescription("Number of UPDATE message errors ignored "+
+			"per the peer's UpdateErrorPolicy."))
+	if err != nil {
+		return nil, err
+	}
+	rateLimited, err := meter.Int64ObservableCounter(
+		"bgp.peer.updates_rate_limited",
+		metric.WithDescription("Number of UPDATE messages received in "+
+			"excess of the peer's UpdateRateLimit."))
+	if err != nil {
+		return nil, err
+	}
+	prefixes, err := meter.Int64ObservableCounter(
+		"bgp.peer.prefixes",
+		metric.WithDescription("Number of prefixes received from the peer."))
+	if err != nil {
+		return nil, err
+	}
+	return meter.RegisterCallback(func(_ context.Context,
+		obs metric.Observer) error {
+		for _, peer := range s.ListPeers() {
+			stats, err := s.GetPeerStats(peer.RemoteAddress)
+			if err != nil {
+				// the peer was deleted
+				continue
+			}
+			attrs := metric.WithAttributes(peerAttrs(peer)...)
+			obs.ObserveInt64(errorsIgnored, int64(stats.UpdateErrorsIgnored),
+				attrs)
+			obs.ObserveInt64(rateLimited, int64(stats.UpdatesRateLimited),
+				attrs)
+			for _, p := range stats.Prefixes {
+				observePrefixCounts(obs, prefixes, peer, p)
+			}
+		}
+		return nil
+	}, errorsIgnored, rateLimited, prefixes)
+}
+
+func observePrefixCounts(obs metric.Observer,
+	counter metric.Int64ObservableCounter, peer corebgp.PeerConfig,
+	p corebgp.FamilyPrefixCounts) {
+	for _, c := range []struct {
+		result string
+		n      uint64
+	}{
+		{"accepted", p.Accepted},
+		{"rejected", p.Rejected},
+		{"withdrawn", p.Withdrawn},
+	} {
+		attrs := append(peerAttrs(peer),
+			AFIKey.Int(int(p.Family.AFI)),
+			SAFIKey.Int(int(p.Family.SAFI)),
+			PrefixResultKey.String(c.result))
+		obs.ObserveInt64(counter, int64(c.n), metric.WithAttributes(attrs...))
+	}
+}
@@ -0,0 +1,300 @@
+package corebgptest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// BGP message types.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.1
+const (
+	MessageTypeOpen         uint8 = 1
+	MessageTypeUpdate       uint8 = 2
+	MessageTypeNotification uint8 = 3
+	MessageTypeKeepalive    uint8 = 4
+)
+
+const (
+	headerLength = 19
+	asTrans      = 23456
+	capFourOctet = 65
+)
+
+// ChaosPeerConfig is the configuration of a ChaosPeer.
+type ChaosPeerConfig struct {
+	// AS is the autonomous system number of the ChaosPeer.
+	AS uint32
+	// RouterID is the BGP Identifier of the ChaosPeer.
+	RouterID netip.Addr
+	// HoldTime is the hold time, in seconds, sent in the ChaosPeer's OPEN
+	// message.
+	HoldTime uint16
+	// Capabilities are included in the ChaosPeer's OPEN message in addition
+	// to the four-octet AS number capability, which is always included.
+	Capabilities []corebgp.Capability
+}
+
+// ChaosPeer is a BGP speaker that can be made to misbehave on demand, e.g.
+// delaying its OPEN message, corrupting message headers, sending oversize
+// messages, closing its side of the connection prematurely, or starving its
+// peer of KEEPALIVE messages. It is driven programmatically in order to test
+// the resilience of corebgp-based applications, or other BGP
+// implementations.
+//
+// A ChaosPeer does not run an FSM, it only sends the messages it is asked to.
+type ChaosPeer struct {
+	conn   net.Conn
+	config ChaosPeerConfig
+}
+
+// NewChaosPeer returns a ChaosPeer communicating over conn, e.g. a connection
+// obtained from a Network's Dialer or Listener.
+func NewChaosPeer(conn net.Conn, config ChaosPeerConfig) *ChaosPeer {
+	return &ChaosPeer{
+		conn:   conn,
+		config: config,
+	}
+}
+
+// Conn returns the connection of the ChaosPeer.
+func (c *ChaosPeer) Conn() net.Conn {
+	return c.conn
+}
+
+// Close closes the connection of the ChaosPeer.
+func (c *ChaosPeer) Close() error {
+	return c.conn.Close()
+}
+
+// CloseWrite closes the sending side of the connection, i.e. sends a FIN,
+// while leaving the receiving side open. The connection must implement
+// CloseWrite, as *net.TCPConn and connections of a Network do.
+func (c *ChaosPeer) CloseWrite() error {
+	cw, ok := c.conn.(interface{ CloseWrite() error })
+	if !ok {
+		return errors.New("connection does not support CloseWrite")
+	}
+	return cw.CloseWrite()
+}
+
+// WriteRaw writes b to the connection as is.
+func (c *ChaosPeer) WriteRaw(b []byte) error {
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// WriteMessage writes a message of type msgType with body.
+func (c *ChaosPeer) WriteMessage(msgType uint8, body []byte) error {
+	if len(body)+headerLength > math.MaxUint16 {
+		return errors.New("message too long")
+	}
+	return c.WriteRaw(appendHeader(nil, msgType,
+		uint16(len(body)+headerLength), body))
+}
+
+// OpenMessage returns the body of the ChaosPeer's OPEN message.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.2
+func (c *ChaosPeer) OpenMessage() ([]byte, error) {
+	if !c.config.RouterID.Is4() {
+		return nil, errors.New("router ID must be an IPv4 address")
+	}
+	caps := []byte{capFourOctet, 4}
+	caps = binary.BigEndian.AppendUint32(caps, c.config.AS)
+	for _, cap := range c.config.Capabilities {
+		caps = append(caps, cap.Code, uint8(len(cap.Value)))
+		caps = append(caps, cap.Value...)
+	}
+	if len(caps) > math.MaxUint8-2 {
+		return nil, errors.New("capabilities too long")
+	}
+	as := uint16(asTrans)
+	if c.config.AS <= math.MaxUint16 {
+		as = uint16(c.config.AS)
+	}
+	b := []byte{4} // version
+	b = binary.BigEndian.AppendUint16(b, as)
+	b = binary.BigEndian.AppendUint16(b, c.config.HoldTime)
+	b = append(b, c.config.RouterID.AsSlice()...)
+	b = append(b, uint8(len(caps)+2), 2, uint8(len(caps)))
+	return append(b, caps...), nil
+}
+
+// SendOpen sends the ChaosPeer's OPEN message.
+func (c *ChaosPeer) SendOpen() error {
+	b, err := c.OpenMessage()
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(MessageTypeOpen, b)
+}
+
+// SendOpenAfter waits for d, or for ctx to be done, before sending the
+// ChaosPeer's OPEN message.
+func (c *ChaosPeer) SendOpenAfter(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return c.SendOpen()
+}
+
+// SendKeepalive sends a KEEPALIVE message.
+func (c *ChaosPeer) SendKeepalive() error {
+	return c.WriteMessage(MessageTypeKeepalive, nil)
+}
+
+// SendKeepalives sends a KEEPALIVE message every interval until ctx is done.
+// Keepalive starvation can be simulated by canceling ctx, or by never calling
+// SendKeepalives.
+func (c *ChaosPeer) SendKeepalives(ctx context.Context,
+	interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			err := c.SendKeepalive()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SendUpdate sends an UPDATE message with body.
+func (c *ChaosPeer) SendUpdate(body []byte) error {
+	return c.WriteMessage(MessageTypeUpdate, body)
+}
+
+// SendNotification sends a NOTIFICATION message for n.
+func (c *ChaosPeer) SendNotification(n *corebgp.Notification) error {
+	return c.WriteMessage(MessageTypeNotification,
+		append([]byte{n.Code, n.Subcode}, n.Data...))
+}
+
+// SendBadMarker sends a message of type msgType with body where the marker
+// field of the header is not all ones.
+func (c *ChaosPeer) SendBadMarker(msgType uint8, body []byte) error {
+	b := appendHeader(nil, msgType, uint16(len(body)+headerLength), body)
+	b[0] = 0
+	return c.WriteRaw(b)
+}
+
+// SendBadLength sends a message of type msgType with body where the length
+// field of the header is length, regardless of the actual length of body.
+func (c *ChaosPeer) SendBadLength(msgType uint8, body []byte,
+	length uint16) error {
+	return c.WriteRaw(appendHeader(nil, msgType, length, body))
+}
+
+// SendOversize sends a message of type msgType with a length of length
+// octets, including the header, and a body of zeros. length should exceed
+// the maximum message size negotiated with the peer, 4096 octets unless
+// extended messages are supported.
+func (c *ChaosPeer) SendOversize(msgType uint8, length uint16) error {
+	if length < headerLength {
+		return fmt.Errorf("length must be >= %d", headerLength)
+	}
+	return c.WriteMessage(msgType, make([]byte, length-headerLength))
+}
+
+// ReadMessage reads a message from the connection, returning its type and
+// body.
+func (c *ChaosPeer) ReadMessage() (uint8, []byte, error) {
+	header := make([]byte, headerLength)
+	_, err := io.ReadFull(c.conn, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	l := int(binary.BigEndian.Uint16(header[16:]))
+	if l < headerLength {
+		return 0, nil, fmt.Errorf("invalid message length: %d", l)
+	}
+	body := make([]byte, l-headerLength)
+	_, err = io.ReadFull(c.conn, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return header[18], body, nil
+}
+
+// ReadNotification reads messages from the connection until a NOTIFICATION
+// message is received, which is returned. An error is returned if the
+// connection is closed or timeout elapses first. A timeout of 0 disables the
+// timeout.
+func (c *ChaosPeer) ReadNotification(timeout time.Duration) (
+	*corebgp.Notification, error) {
+	if timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout)) // nolint: errcheck
+		defer c.conn.SetReadDeadline(time.Time{})       // nolint: errcheck
+	}
+	for {
+		msgType, body, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if msgType == MessageTypeNotification {
+			return corebgp.ParseNotification(body)
+		}
+	}
+}
+
+// Establish performs the OPEN and KEEPALIVE exchange with the peer, returning
+// the body of the peer's OPEN message once a KEEPALIVE has been received from
+// it. The ChaosPeer's OPEN message is sent first.
+func (c *ChaosPeer) Establish() ([]byte, error) {
+	err := c.SendOpen()
+	if err != nil {
+		return nil, err
+	}
+	var open []byte
+	for {
+		msgType, body, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msgType {
+		case MessageTypeOpen:
+			open = body
+			err = c.SendKeepalive()
+			if err != nil {
+				return nil, err
+			}
+		case MessageTypeKeepalive:
+			if open != nil {
+				return open, nil
+			}
+		case MessageTypeNotification:
+			n, err := corebgp.ParseNotification(body)
+			if err != nil {
+				return nil, err
+			}
+			return nil, n
+		}
+	}
+}
+
+func appendHeader(b []byte, msgType uint8, length uint16, body []byte) []byte {
+	for i := 0; i < 16; i++ {
+		b = append(b, 0xff)
+	}
+	b = binary.BigEndian.AppendUint16(b, length)
+	b = append(b, msgType)
+	return append(b, body...)
+}
@@ -0,0 +1,115 @@
+package corebgptest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosPeer(t *testing.T) {
+	t.Parallel()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	cases := []struct {
+		name      string
+		misbehave func(c *ChaosPeer) error
+		want      *corebgp.Notification
+	}{
+		{
+			name: "bad marker",
+			misbehave: func(c *ChaosPeer) error {
+				return c.SendBadMarker(MessageTypeOpen, nil)
+			},
+			want: &corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_MESSAGE_HEADER_ERR,
+				Subcode: corebgp.NOTIF_SUBCODE_CONN_NOT_SYNCHRONIZED,
+			},
+		},
+		{
+			name: "bad length",
+			misbehave: func(c *ChaosPeer) error {
+				return c.SendBadLength(MessageTypeKeepalive, nil, 18)
+			},
+			want: &corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_MESSAGE_HEADER_ERR,
+				Subcode: corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN,
+			},
+		},
+		{
+			name: "oversize",
+			misbehave: func(c *ChaosPeer) error {
+				return c.SendOversize(MessageTypeUpdate, 4097)
+			},
+			want: &corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_MESSAGE_HEADER_ERR,
+				Subcode: corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN,
+			},
+		},
+		{
+			name: "keepalive starvation",
+			misbehave: func(c *ChaosPeer) error {
+				_, err := c.Establish()
+				return err
+			},
+			want: &corebgp.Notification{
+				Code: corebgp.NOTIF_CODE_HOLD_TIMER_EXPIRED,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			n := NewNetwork()
+			newTestServer(t, n, addrA, addrB, corebgp.WithPassive())
+			conn, err := n.Dialer(addrB)(context.Background(), "tcp",
+				"192.0.2.1:179")
+			if !assert.NoError(t, err) {
+				return
+			}
+			c := NewChaosPeer(conn, ChaosPeerConfig{
+				AS:       64512,
+				RouterID: addrB,
+				HoldTime: 3,
+			})
+			defer c.Close()
+			assert.NoError(t, tc.misbehave(c))
+			got, err := c.ReadNotification(time.Second * 10)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.want.Code, got.Code)
+				assert.Equal(t, tc.want.Subcode, got.Subcode)
+			}
+		})
+	}
+}
+
+func TestChaosPeer_CloseWrite(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	newTestServer(t, n, addrA, addrB, corebgp.WithPassive())
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := NewChaosPeer(conn, ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	assert.NoError(t, c.SendOpen())
+	assert.NoError(t, c.CloseWrite())
+	// the server closes the connection in response to the premature FIN
+	_, err = c.ReadNotification(time.Second * 10)
+	assert.True(t, errors.Is(err, io.EOF), "unexpected error: %v", err)
+}
@@ -0,0 +1,132 @@
+package corebgptest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// FakeClock is a corebgp.Clock whose time only moves forward when Advance is
+// called. It can be passed to corebgp.WithClock in order to deterministically
+// exercise FSM timers.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{
+		now:    now,
+		timers: make(map[*fakeTimer]struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current time of the FakeClock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a corebgp.Timer that fires once the FakeClock has been
+// advanced by at least d. A timer with a non-positive duration fires
+// immediately.
+func (c *FakeClock) NewTimer(d time.Duration) corebgp.Timer {
+	t := &fakeTimer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.arm(t, d)
+	return t
+}
+
+// Advance moves the FakeClock forward by d, firing any timers that expire
+// along the way in order of their deadline.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for t := range c.timers {
+			if !t.deadline.After(end) &&
+				(next == nil || t.deadline.Before(next.deadline)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.deadline.After(c.now) {
+			c.now = next.deadline
+		}
+		c.fire(next)
+	}
+	c.now = end
+}
+
+// BlockUntil blocks until at least n timers are active, i.e. created or reset
+// and neither expired nor stopped. This can be used to wait for an FSM to
+// reach a state before advancing time.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// arm must be called with c.mu held.
+func (c *FakeClock) arm(t *fakeTimer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		c.fire(t)
+		return
+	}
+	c.timers[t] = struct{}{}
+	c.cond.Broadcast()
+}
+
+// fire must be called with c.mu held.
+func (c *FakeClock) fire(t *fakeTimer) {
+	delete(c.timers, t)
+	select {
+	case t.c <- c.now:
+	default:
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	t.clock.arm(t, d)
+	return active
+}
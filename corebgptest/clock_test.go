@@ -0,0 +1,75 @@
+package corebgptest
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	start := time.Unix(0, 0)
+	c := NewFakeClock(start)
+
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(time.Second * 2)
+	t0 := c.NewTimer(0)
+	select {
+	case <-t0.C():
+	default:
+		t.Fatal("zero duration timer did not fire")
+	}
+	c.BlockUntil(2)
+
+	c.Advance(time.Millisecond * 1500)
+	assert.Equal(t, start.Add(time.Millisecond*1500), c.Now())
+	select {
+	case fired := <-t1.C():
+		assert.Equal(t, start.Add(time.Second), fired)
+	default:
+		t.Fatal("timer did not fire")
+	}
+	assert.False(t, t1.Stop())
+	assert.True(t, t2.Stop())
+	assert.False(t, t2.Reset(time.Second))
+	c.Advance(time.Second)
+	select {
+	case <-t2.C():
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestFakeClock_HoldTimerExpiry(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	clockA := NewFakeClock(time.Now())
+	clockB := NewFakeClock(time.Now())
+
+	sA := newTestServer(t, n, addrA, addrB, corebgp.WithClock(clockA),
+		corebgp.WithHoldTime(3))
+	// B is passive to avoid a connection collision, which could leave both
+	// sides waiting on idle hold timers that never fire
+	sB := newTestServer(t, n, addrB, addrA, corebgp.WithClock(clockB),
+		corebgp.WithHoldTime(3), corebgp.WithPassive())
+
+	established := func(s *corebgp.Server, remote netip.Addr) bool {
+		_, err := s.GetSessionInfo(remote)
+		return err == nil
+	}
+	assert.Eventually(t, func() bool {
+		return established(sA, addrB) && established(sB, addrA)
+	}, time.Second*5, time.Millisecond*10)
+
+	// time is frozen for B, so it never sends a KEEPALIVE and A's hold timer
+	// expires
+	clockA.Advance(time.Second * 4)
+	assert.Eventually(t, func() bool {
+		return !established(sA, addrB) && !established(sB, addrA)
+	}, time.Second*5, time.Millisecond*10)
+}
@@ -0,0 +1,162 @@
+// Package corebgptest provides utilities for testing corebgp applications
+// hermetically, without real sockets or ports.
+package corebgptest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Network is an in-memory network of listeners addressed by IP. It can be used
+// to connect corebgp Servers to one another by passing a Listener to
+// corebgp.Server.Serve and a Dialer via corebgp.WithDialer.
+//
+// Connections report *net.TCPAddr local and remote addresses so that they are
+// matched to peers exactly as real TCP connections would be.
+type Network struct {
+	// Pipe returns the connected pair of conns used for a new connection. The
+	// first conn is handed to the dialer and the second to the listener. If
+	// Pipe is nil the pair is created with corebgptest.Pipe. Note that
+	// net.Pipe is unsuitable as its writes are unbuffered, and both sides of a
+	// BGP session write an OPEN message before reading.
+	Pipe func(dialerAddr, listenerAddr net.Addr) (net.Conn, net.Conn)
+
+	mu        sync.Mutex
+	listeners map[netip.AddrPort]*listener
+	nextPort  uint16
+}
+
+// NewNetwork returns a new, empty Network.
+func NewNetwork() *Network {
+	return &Network{
+		listeners: make(map[netip.AddrPort]*listener),
+		nextPort:  49152,
+	}
+}
+
+// Listen returns a net.Listener for addr on port corebgp.DefaultPort.
+func (n *Network) Listen(addr netip.Addr) (net.Listener, error) {
+	return n.ListenAddrPort(netip.AddrPortFrom(addr, corebgp.DefaultPort))
+}
+
+// ListenAddrPort returns a net.Listener for addrPort.
+func (n *Network) ListenAddrPort(addrPort netip.AddrPort) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, exists := n.listeners[addrPort]
+	if exists {
+		return nil, fmt.Errorf("listen %s: %w", addrPort, syscall.EADDRINUSE)
+	}
+	l := &listener{
+		network: n,
+		addr:    addrPort,
+		connCh:  make(chan net.Conn),
+		doneCh:  make(chan struct{}),
+	}
+	n.listeners[addrPort] = l
+	return l, nil
+}
+
+// Dialer returns a corebgp.DialFunc that connects to Listeners in n. The
+// source address of a connection is the first of laddrs with the same address
+// family as the destination.
+func (n *Network) Dialer(laddrs ...netip.Addr) corebgp.DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		raddr, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return nil, err
+		}
+		var laddr netip.Addr
+		for _, l := range laddrs {
+			if l.Is4() == raddr.Addr().Is4() {
+				laddr = l
+				break
+			}
+		}
+		if !laddr.IsValid() {
+			return nil, &net.OpError{
+				Op:  "dial",
+				Net: network,
+				Err: syscall.EADDRNOTAVAIL,
+			}
+		}
+		n.mu.Lock()
+		l, exists := n.listeners[raddr]
+		n.nextPort++
+		if n.nextPort == 0 {
+			n.nextPort = 49152
+		}
+		lport := n.nextPort
+		n.mu.Unlock()
+		if !exists {
+			return nil, &net.OpError{
+				Op:  "dial",
+				Net: network,
+				Err: syscall.ECONNREFUSED,
+			}
+		}
+		pipe := n.Pipe
+		if pipe == nil {
+			pipe = Pipe
+		}
+		dc, lc := pipe(
+			net.TCPAddrFromAddrPort(netip.AddrPortFrom(laddr, lport)),
+			net.TCPAddrFromAddrPort(raddr),
+		)
+		select {
+		case l.connCh <- lc:
+			return dc, nil
+		case <-l.doneCh:
+			dc.Close()
+			lc.Close()
+			return nil, &net.OpError{
+				Op:  "dial",
+				Net: network,
+				Err: syscall.ECONNREFUSED,
+			}
+		case <-ctx.Done():
+			dc.Close()
+			lc.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type listener struct {
+	network   *Network
+	addr      netip.AddrPort
+	connCh    chan net.Conn
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.connCh:
+		return c, nil
+	case <-l.doneCh:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		err = nil
+		close(l.doneCh)
+		l.network.mu.Lock()
+		delete(l.network.listeners, l.addr)
+		l.network.mu.Unlock()
+	})
+	return err
+}
+
+func (l *listener) Addr() net.Addr {
+	return net.TCPAddrFromAddrPort(l.addr)
+}
@@ -0,0 +1,237 @@
+package corebgptest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPlugin struct{}
+
+func (t *testPlugin) GetCapabilities(_ corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (t *testPlugin) OnOpenMessage(_ corebgp.PeerConfig, _ netip.Addr,
+	_ []corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (t *testPlugin) OnEstablished(_ corebgp.PeerConfig,
+	_ corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return nil
+}
+
+func (t *testPlugin) OnClose(_ corebgp.PeerConfig) {}
+
+// newTestServer returns a running corebgp.Server at local with a single peer
+// at remote, connected via n.
+func newTestServer(t *testing.T, n *Network, local, remote netip.Addr,
+	opts ...corebgp.PeerOption) *corebgp.Server {
+	// derive a router ID from the low 32 bits of local
+	a16 := local.As16()
+	s, err := corebgp.NewServer(netip.AddrFrom4([4]byte(a16[12:])))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	opts = append([]corebgp.PeerOption{
+		corebgp.WithLocalAddress(local),
+		corebgp.WithIdleHoldTime(time.Millisecond * 100),
+		corebgp.WithDialer(n.Dialer(local)),
+	}, opts...)
+	err = s.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: remote,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, &testPlugin{}, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	l, err := n.Listen(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	go s.Serve([]net.Listener{l})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestNetwork(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	// both servers dial one another, exercising connection collision
+	// resolution
+	sA := newTestServer(t, n, addrA, addrB)
+	sB := newTestServer(t, n, addrB, addrA)
+
+	assert.Eventually(t, func() bool {
+		infoA, errA := sA.GetSessionInfo(addrB)
+		infoB, errB := sB.GetSessionInfo(addrA)
+		return errA == nil && errB == nil &&
+			infoA.RemoteRouterID == addrB && infoB.RemoteRouterID == addrA
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestPipe(t *testing.T) {
+	t.Parallel()
+	a, b := Pipe(&net.TCPAddr{Port: 1}, &net.TCPAddr{Port: 2})
+	assert.Equal(t, 1, a.LocalAddr().(*net.TCPAddr).Port)
+	assert.Equal(t, 2, b.LocalAddr().(*net.TCPAddr).Port)
+
+	// writes must not block without a reader
+	_, err := a.Write([]byte{1, 2})
+	assert.NoError(t, err)
+	_, err = a.Write([]byte{3})
+	assert.NoError(t, err)
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(b, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, buf)
+
+	err = b.SetReadDeadline(time.Now().Add(time.Millisecond * 10))
+	assert.NoError(t, err)
+	_, err = b.Read(buf)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	assert.NoError(t, a.Close())
+	assert.ErrorIs(t, a.Close(), net.ErrClosed)
+	assert.NoError(t, b.SetReadDeadline(time.Time{}))
+	_, err = b.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = b.Write(buf)
+	assert.Error(t, err)
+}
+
+func TestNetwork_DialRefused(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	_, err := n.Dialer(netip.MustParseAddr("192.0.2.1"))(context.Background(),
+		"tcp", "192.0.2.2:179")
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	l, err := n.Listen(netip.MustParseAddr("192.0.2.2"))
+	assert.NoError(t, err)
+	_, err = n.Listen(netip.MustParseAddr("192.0.2.2"))
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+	assert.NoError(t, l.Close())
+	_, err = l.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestNetwork_Shutdown(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	sA := newTestServer(t, n, addrA, addrB)
+	sB := newTestServer(t, n, addrB, addrA, corebgp.WithPassive())
+	assert.Eventually(t, func() bool {
+		_, err := sA.GetSessionInfo(addrB)
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	assert.NoError(t, sA.Shutdown(ctx, "maintenance"))
+	assert.Eventually(t, func() bool {
+		_, err := sB.GetSessionInfo(addrA)
+		return errors.Is(err, corebgp.ErrPeerNotEstablished)
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestNetwork_UpdatePeer(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	sA := newTestServer(t, n, addrA, addrB)
+	newTestServer(t, n, addrB, addrA, corebgp.WithPassive())
+	established := func() bool {
+		_, err := sA.GetSessionInfo(addrB)
+		return err == nil
+	}
+	assert.Eventually(t, established, time.Second*5, time.Millisecond*10)
+
+	config := corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}
+	action, err := sA.UpdatePeer(config, corebgp.WithLocalAddress(addrA),
+		corebgp.WithDialer(n.Dialer(addrA)), corebgp.WithHoldTime(30))
+	assert.NoError(t, err)
+	assert.Equal(t, corebgp.PeerUpdateInPlace, action)
+	assert.True(t, established())
+
+	config.LocalAS = 64513
+	action, err = sA.UpdatePeer(config, corebgp.WithLocalAddress(addrA),
+		corebgp.WithDialer(n.Dialer(addrA)))
+	assert.NoError(t, err)
+	assert.Equal(t, corebgp.PeerUpdateReset, action)
+	got, err := sA.GetPeer(addrB)
+	assert.NoError(t, err)
+	assert.Equal(t, config, got)
+}
+
+func TestNetwork_FallbackTransports(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA4 := netip.MustParseAddr("192.0.2.1")
+	addrA6 := netip.MustParseAddr("2001:db8::1")
+	addrB4 := netip.MustParseAddr("192.0.2.2")
+	addrB6 := netip.MustParseAddr("2001:db8::2")
+
+	// B only listens on IPv4, so A must fall back from IPv6
+	sA := newTestServer(t, n, addrA6, addrB6,
+		corebgp.WithDialer(n.Dialer(addrA6, addrA4)),
+		corebgp.WithFallbackTransports(corebgp.Transport{
+			RemoteAddress: addrB4,
+			LocalAddress:  addrA4,
+		}))
+	newTestServer(t, n, addrB4, addrA4, corebgp.WithPassive())
+
+	assert.Eventually(t, func() bool {
+		info, err := sA.GetSessionInfo(addrB6)
+		return err == nil &&
+			info.RemoteAddress == netip.AddrPortFrom(addrB4, corebgp.DefaultPort)
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestNetwork_TransportModes(t *testing.T) {
+	t.Parallel()
+	n := NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	sA := newTestServer(t, n, addrA, addrB,
+		corebgp.WithTransportMode(corebgp.TransportModeActive))
+	newTestServer(t, n, addrB, addrA,
+		corebgp.WithTransportMode(corebgp.TransportModePassive))
+	assert.Eventually(t, func() bool {
+		_, err := sA.GetSessionInfo(addrB)
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	// A is active-only and closes inbound connections from B
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp",
+		netip.AddrPortFrom(addrA, corebgp.DefaultPort).String())
+	if assert.NoError(t, err) {
+		_, err = conn.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+		conn.Close()
+	}
+}
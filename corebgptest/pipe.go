@@ -0,0 +1,179 @@
+package corebgptest
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeBuffer is one direction of a buffered pipe.
+type pipeBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newPipeBuffer() *pipeBuffer {
+	p := &pipeBuffer{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *pipeBuffer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// pipeConn is a net.Conn backed by a pair of pipeBuffers. Unlike net.Pipe,
+// writes do not block waiting for a reader. This matters as both sides of a
+// BGP session send an OPEN message before reading.
+type pipeConn struct {
+	r, w         *pipeBuffer
+	laddr, raddr net.Addr
+
+	mu            sync.Mutex
+	closed        bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+	deadlineTimer *time.Timer
+}
+
+// Pipe returns a connected pair of in-memory net.Conn. Data written to one
+// conn is buffered until read from the other, so writes never block. a reports
+// aAddr as its local address and bAddr as its remote address, and vice versa.
+func Pipe(aAddr, bAddr net.Addr) (a, b net.Conn) {
+	ab, ba := newPipeBuffer(), newPipeBuffer()
+	a = &pipeConn{
+		r:     ba,
+		w:     ab,
+		laddr: aAddr,
+		raddr: bAddr,
+	}
+	b = &pipeConn{
+		r:     ab,
+		w:     ba,
+		laddr: bAddr,
+		raddr: aAddr,
+	}
+	return a, b
+}
+
+func (c *pipeConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	for {
+		if c.isClosed() {
+			return 0, net.ErrClosed
+		}
+		if c.r.buf.Len() > 0 {
+			return c.r.buf.Read(b)
+		}
+		if c.r.closed {
+			return 0, io.EOF
+		}
+		c.mu.Lock()
+		deadline := c.readDeadline
+		c.mu.Unlock()
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		c.r.cond.Wait()
+	}
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	if c.isClosed() {
+		return 0, net.ErrClosed
+	}
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+	if c.w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	c.w.buf.Write(b)
+	c.w.cond.Broadcast()
+	return len(b), nil
+}
+
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+	c.closed = true
+	if c.deadlineTimer != nil {
+		c.deadlineTimer.Stop()
+	}
+	c.mu.Unlock()
+	c.r.close()
+	c.w.close()
+	return nil
+}
+
+// CloseWrite closes the sending side of the conn, the other side reads io.EOF
+// once any buffered data has been read.
+func (c *pipeConn) CloseWrite() error {
+	if c.isClosed() {
+		return net.ErrClosed
+	}
+	c.w.close()
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.SetWriteDeadline(t)
+	return c.SetReadDeadline(t)
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	if c.deadlineTimer != nil {
+		c.deadlineTimer.Stop()
+		c.deadlineTimer = nil
+	}
+	if !t.IsZero() {
+		// wake any blocked reader so that it observes the deadline
+		c.deadlineTimer = time.AfterFunc(time.Until(t), func() {
+			c.r.mu.Lock()
+			defer c.r.mu.Unlock()
+			c.r.cond.Broadcast()
+		})
+	}
+	return nil
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
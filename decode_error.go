@@ -0,0 +1,96 @@
+package corebgp
+
+import (
+	"errors"
+)
+
+// DecodeErrorObserver is called when a message received from a peer fails
+// parsing or validation. b is the raw message, including its header, err
+// describes the error, and reset is true if the session will be reset as a
+// result. b must not be retained after the observer returns, a copy should be
+// made if required, e.g. to persist malformed messages for offline analysis.
+//
+// Errors are observed for message headers and bodies that cannot be decoded,
+// OPEN messages that fail validation, and UPDATE messages for which the
+// peer's UpdateMessageHandler returns a Notification with code
+// NOTIF_CODE_UPDATE_MESSAGE_ERR. In the final case reset is false if the
+// error is ignored per the peer's UpdateErrorPolicy. UPDATE messages read into
+// pooled buffers, e.g. before the observer was set via UpdatePeer, are not
+// observed as they are owned by the UpdateMessageHandler. A message header that
+// fails validation is passed to the observer without the remainder of the
+// message. The observer is called from the FSM and must not block.
+type DecodeErrorObserver func(peer PeerConfig, b []byte, err error,
+	reset bool)
+
+// WithDecodeErrorObserver returns a PeerOption that sets a
+// DecodeErrorObserver for a peer. Pooled UPDATE buffers, see
+// WithPooledUpdateBuffers, are not used when a DecodeErrorObserver is set.
+func WithDecodeErrorObserver(fn DecodeErrorObserver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.decodeErrorObserver = fn
+	})
+}
+
+// WithDecodeErrorData returns a PeerOption that includes the offending message,
+// including its header, in the Data field of a NOTIFICATION message sent to
+// the peer as a result of a message received from it failing to decode, in
+// order to aid debugging with the peer's operator. The message is only
+// included where the Data field would otherwise be empty, i.e. where its
+// contents are not defined for the Error Code and Error Subcode, and it is
+// truncated so that the NOTIFICATION message does not exceed 4096 octets.
+// Errors resulting from the validation of OPEN messages, and Notifications
+// returned by an UpdateMessageHandler, are not affected. Pooled UPDATE
+// buffers, see WithPooledUpdateBuffers, are not used when it is set.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.5
+// Data:
+//
+//	This variable-length field is used to diagnose the reason for
+//	the NOTIFICATION. The contents of the Data field depend upon the
+//	Error Code and Error Subcode.
+func WithDecodeErrorData() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.decodeErrorData = true
+	})
+}
+
+// maxDecodeErrorData is the maximum length of the Data field of a
+// NOTIFICATION message set by setDecodeErrorData. The peer may not have
+// advertised the BGP Extended Message Capability, so the NOTIFICATION message
+// is bounded by 4096 octets.
+const maxDecodeErrorData = maxMessageLength - headerLength - 2
+
+// setDecodeErrorData sets the Data field of the Notification of err, if it is
+// a *notificationError to be sent with an empty Data field, to a copy of b
+// truncated to maxDecodeErrorData octets.
+func setDecodeErrorData(err error, b []byte) {
+	var nerr *notificationError
+	if !errors.As(err, &nerr) || !nerr.out ||
+		len(nerr.notification.Data) > 0 {
+		return
+	}
+	nerr.notification.Data = append([]byte(nil),
+		b[:min(len(b), maxDecodeErrorData)]...)
+}
+
+// observeDecodeError calls the peer's DecodeErrorObserver, if set, for err
+// if it is a *notificationError.
+func observeDecodeError(peer PeerConfig, fn DecodeErrorObserver, b []byte,
+	err error) {
+	var nerr *notificationError
+	if fn != nil && errors.As(err, &nerr) {
+		fn(peer, b, err, true)
+	}
+}
+
+// observeUpdateError calls the peer's DecodeErrorObserver, if set, for the
+// Notification n returned by an UpdateMessageHandler for the UPDATE message
+// body m.
+func (f *fsm) observeUpdateError(m updateMessage, n *Notification,
+	reset bool) {
+	fn := f.peer.options().decodeErrorObserver
+	if fn == nil || n.Code != NOTIF_CODE_UPDATE_MESSAGE_ERR {
+		return
+	}
+	fn(f.peer.config, prependHeader(m, updateMessageType), n, reset)
+}
@@ -0,0 +1,135 @@
+package corebgp_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// updateErrPlugin returns an UPDATE Message Error Notification for every
+// UPDATE message. The handler signals blockedCh and blocks on releaseCh for the
+// UPDATE message whose final octet is block.
+type updateErrPlugin struct {
+	livenessTestPlugin
+	block                byte
+	blockedCh, releaseCh chan struct{}
+	handled              atomic.Int64
+}
+
+func (u *updateErrPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return func(_ corebgp.PeerConfig, b []byte) *corebgp.Notification {
+		if b[len(b)-1] == u.block {
+			close(u.blockedCh)
+			<-u.releaseCh
+		}
+		u.handled.Add(1)
+		return &corebgp.Notification{
+			Code:    corebgp.NOTIF_CODE_UPDATE_MESSAGE_ERR,
+			Subcode: corebgp.NOTIF_SUBCODE_MALFORMED_ATTR_LIST,
+		}
+	}
+}
+
+// TestDecodeErrorObserver_pooled verifies that a DecodeErrorObserver set on a
+// peer using pooled UPDATE buffers only observes intact messages.
+func TestDecodeErrorObserver_pooled(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var (
+		mu       sync.Mutex
+		observed [][]byte
+	)
+	observer := func(peer corebgp.PeerConfig, b []byte, err error,
+		reset bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, bytes.Clone(b[19:]))
+	}
+	config := corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}
+	opts := []corebgp.PeerOption{
+		corebgp.WithPassive(),
+		corebgp.WithPooledUpdateBuffers(),
+		corebgp.WithUpdateErrorPolicy(corebgp.UpdateErrorPolicyIgnore),
+	}
+	const updates = 200
+	plugin := &updateErrPlugin{
+		block:     updates / 2,
+		blockedCh: make(chan struct{}),
+		releaseCh: make(chan struct{}),
+	}
+	// a PluginChain releases pooled buffers once handling completes
+	err = server.AddPeer(config, corebgp.NewPluginChain(plugin), opts...)
+	if !assert.NoError(t, err) {
+		return
+	}
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	go server.Serve([]net.Listener{l})
+	t.Cleanup(server.Close)
+
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	_, err = c.Establish()
+	if !assert.NoError(t, err) {
+		return
+	}
+	update := func(i int) []byte {
+		// an NLRI field with a distinct trailing octet
+		return []byte{0, 0, 0, 0, 32, 192, 0, 2, byte(i)}
+	}
+	for i := 0; i < updates; i++ {
+		assert.NoError(t, c.SendUpdate(update(i)))
+		if i == updates/2 {
+			// the blocked UPDATE message was read into a pooled buffer,
+			// which is released by the PluginChain before it could be
+			// observed
+			<-plugin.blockedCh
+			_, err = server.UpdatePeer(config, append(opts,
+				corebgp.WithDecodeErrorObserver(observer))...)
+			assert.NoError(t, err)
+			close(plugin.releaseCh)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return plugin.handled.Load() == updates
+	}, time.Second*5, time.Millisecond*10)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotEmpty(t, observed)
+	for _, b := range observed {
+		if assert.Len(t, b, 9) {
+			assert.Equal(t, update(int(b[8])), b)
+			assert.NotEqual(t, byte(updates/2), b[8])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package corebgp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInspectedMessageDecodeError(t *testing.T) {
+	type observed struct {
+		b     []byte
+		err   error
+		reset bool
+	}
+	var got []observed
+	observer := func(peer PeerConfig, b []byte, err error, reset bool) {
+		got = append(got, observed{b, err, reset})
+	}
+
+	// bad marker, only the header is observed
+	badMarker := prependHeader([]byte{1, 2, 3}, keepAliveMessageType)
+	badMarker[0] = 0
+	_, err := readInspectedMessage(bytes.NewReader(badMarker),
+		defaultLengthLimits, PeerConfig{}, nil, observer, false)
+	assert.Error(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, badMarker[:headerLength], got[0].b)
+		assert.Equal(t, err, got[0].err)
+		assert.True(t, got[0].reset)
+	}
+
+	// short OPEN message
+	shortOpen := prependHeader([]byte{4, 0, 1}, openMessageType)
+	_, err = readInspectedMessage(bytes.NewReader(shortOpen),
+		defaultLengthLimits, PeerConfig{}, nil, observer, false)
+	assert.Error(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, shortOpen, got[1].b)
+	}
+
+	// connection errors are not observed
+	_, err = readInspectedMessage(bytes.NewReader(shortOpen[:10]),
+		defaultLengthLimits, PeerConfig{}, nil, observer, false)
+	assert.Error(t, err)
+	assert.Len(t, got, 2)
+
+	m, err := readInspectedMessage(bytes.NewReader(
+		prependHeader(nil, keepAliveMessageType)), defaultLengthLimits,
+		PeerConfig{}, nil, observer, false)
+	assert.NoError(t, err)
+	assert.IsType(t, &keepAliveMessage{}, m)
+	assert.Len(t, got, 2)
+}
+
+func TestReadInspectedMessageDecodeErrorData(t *testing.T) {
+	var observed *Notification
+	observer := func(peer PeerConfig, b []byte, err error, reset bool) {
+		var nerr *notificationError
+		if assert.ErrorAs(t, err, &nerr) {
+			observed = nerr.notification
+		}
+	}
+	read := func(b []byte) *Notification {
+		_, err := readInspectedMessage(bytes.NewReader(b),
+			defaultLengthLimits, PeerConfig{}, nil, observer, true)
+		var nerr *notificationError
+		if !assert.ErrorAs(t, err, &nerr) {
+			return nil
+		}
+		assert.Equal(t, nerr.notification, observed)
+		return nerr.notification
+	}
+
+	// bad marker, the header is included
+	badMarker := prependHeader([]byte{1, 2, 3}, keepAliveMessageType)
+	badMarker[0] = 0
+	n := read(badMarker)
+	if assert.NotNil(t, n) {
+		assert.Equal(t, badMarker[:headerLength], n.Data)
+	}
+
+	// bad message type, the Data field is defined as the type
+	n = read(prependHeader(nil, 9))
+	if assert.NotNil(t, n) {
+		assert.Equal(t, []byte{9}, n.Data)
+	}
+
+	// OPEN message with a bad Optional Parameters length
+	badOpen := prependHeader([]byte{4, 0xfc, 0, 0, 90, 192, 0, 2, 1, 1},
+		openMessageType)
+	n = read(badOpen)
+	if assert.NotNil(t, n) {
+		assert.Equal(t, badOpen, n.Data)
+	}
+
+	// an OPEN message of the maximum length is truncated
+	body := make([]byte, maxMessageLength-headerLength)
+	copy(body, []byte{4, 0xfc, 0, 0, 90, 192, 0, 2, 1, 255, 255, 0, 1})
+	longOpen := prependHeader(body, openMessageType)
+	n = read(longOpen)
+	if assert.NotNil(t, n) {
+		assert.Equal(t, longOpen[:maxDecodeErrorData], n.Data)
+		b, err := n.encode()
+		assert.NoError(t, err)
+		assert.Len(t, b, maxMessageLength)
+	}
+}
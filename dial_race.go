@@ -0,0 +1,111 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultConnectRacingDelay is the default delay between the start of
+// connection attempts when racing transports, see WithConnectRacing.
+//
+// https://www.rfc-editor.org/rfc/rfc8305#section-5
+// The recommended value for a default delay is 250 milliseconds.
+const DefaultConnectRacingDelay = time.Millisecond * 250
+
+// WithConnectRacing returns a PeerOption that races outbound connection
+// attempts across a peer's transports, see WithFallbackTransports, in the
+// style of Happy Eyeballs. Attempts are started in order of preference,
+// staggered by delay, or immediately once the previous attempt fails. The
+// first connection to complete is used and the remaining attempts are
+// canceled. Each attempt may continue until the ConnectRetryTimer expires,
+// instead of being limited to an even share of that time. This reduces
+// establishment latency when the preferred transport is unreachable, e.g.
+// after a failover of a multihop peer's path.
+//
+// A delay of 0 selects DefaultConnectRacingDelay. Connect racing has no effect
+// for peers with a single transport.
+//
+// https://www.rfc-editor.org/rfc/rfc8305
+func WithConnectRacing(delay time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if delay == 0 {
+			delay = DefaultConnectRacingDelay
+		}
+		o.connectRacingDelay = delay
+	})
+}
+
+type raceResult struct {
+	conn net.Conn
+	err  error
+}
+
+// raceDial calls dial for each of n attempts, staggered by delay, returning
+// the first connection to succeed. The next attempt is started early if all
+// attempts in progress have failed. Connections that complete after the first
+// are closed. If all attempts fail the error of the last attempt to fail is
+// returned.
+func raceDial(ctx context.Context, n int, delay time.Duration,
+	dial func(ctx context.Context, i int) (net.Conn, error)) (net.Conn,
+	error) {
+	if n < 1 {
+		return nil, errors.New("no attempts to race")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan raceResult)
+	started, inFlight := 0, 0
+	start := func() {
+		i := started
+		started++
+		inFlight++
+		go func() {
+			conn, err := dial(ctx, i)
+			select {
+			case results <- raceResult{conn: conn, err: err}:
+			case <-ctx.Done():
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var lastErr error
+	for {
+		var timerC <-chan time.Time
+		if started < n {
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timerC:
+			start()
+			timer.Reset(delay)
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				return r.conn, nil
+			}
+			lastErr = r.err
+			if started < n {
+				if inFlight == 0 {
+					// start the next attempt immediately
+					if !timer.Stop() {
+						<-timer.C
+					}
+					start()
+					timer.Reset(delay)
+				}
+			} else if inFlight == 0 {
+				return nil, lastErr
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeRecordingConn struct {
+	net.Conn
+	closed atomic.Bool
+}
+
+func (c *closeRecordingConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestRaceDial(t *testing.T) {
+	errRefused := errors.New("refused")
+
+	t.Run("staggered", func(t *testing.T) {
+		slow := &closeRecordingConn{}
+		fast := &closeRecordingConn{}
+		slowDone := make(chan struct{})
+		conn, err := raceDial(context.Background(), 2, time.Millisecond*10,
+			func(ctx context.Context, i int) (net.Conn, error) {
+				if i == 0 {
+					defer close(slowDone)
+					<-ctx.Done()
+					return slow, nil
+				}
+				return fast, nil
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, fast, conn)
+		<-slowDone
+		assert.Eventually(t, slow.closed.Load, time.Second, time.Millisecond)
+		assert.False(t, fast.closed.Load())
+	})
+
+	t.Run("failure starts next attempt", func(t *testing.T) {
+		want := &closeRecordingConn{}
+		conn, err := raceDial(context.Background(), 2, time.Hour,
+			func(ctx context.Context, i int) (net.Conn, error) {
+				if i == 0 {
+					return nil, errRefused
+				}
+				return want, nil
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, want, conn)
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		var attempts atomic.Int32
+		_, err := raceDial(context.Background(), 3, time.Millisecond,
+			func(ctx context.Context, i int) (net.Conn, error) {
+				attempts.Add(1)
+				return nil, errRefused
+			})
+		assert.ErrorIs(t, err, errRefused)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Millisecond*10)
+		defer cancel()
+		_, err := raceDial(ctx, 2, time.Millisecond,
+			func(ctx context.Context, i int) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestFSM_dialPeerDeadline(t *testing.T) {
+	for _, racing := range []bool{false, true} {
+		o := defaultPeerOptions()
+		if racing {
+			WithConnectRacing(0).apply(&o)
+		}
+		WithFallbackTransports(Transport{
+			RemoteAddress: netip.MustParseAddr("2001:db8::2"),
+		}).apply(&o)
+		deadlines := make(chan time.Time, 2)
+		o.dialFn = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			d, ok := ctx.Deadline()
+			assert.True(t, ok)
+			deadlines <- d
+			return nil, errors.New("refused")
+		}
+		p := newPeer(PeerConfig{
+			RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+		}, 1, nil, o)
+		f := newFSM(p, out, nil)
+
+		// attempts must not outlast the jittered ConnectRetryTimer, which is
+		// shorter than the connect retry time
+		deadline := time.Now().Add(o.connectRetryTime / 4)
+		f.dialPeer(deadline)
+		dr := <-f.dialResultCh
+		assert.Error(t, dr.err)
+		first, second := <-deadlines, <-deadlines
+		assert.False(t, first.After(deadline))
+		assert.False(t, second.After(deadline))
+		if !racing {
+			// the first attempt is limited to an even share, its failure
+			// leaves the remaining time to the second
+			assert.True(t, first.Before(deadline.Add(-o.connectRetryTime/16)))
+			assert.True(t, second.After(first))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package corebgp
+
+import (
+	"errors"
+	"hash/maphash"
+	"sync"
+)
+
+// UpdateDispatcher fans out the handling of UPDATE messages received from many
+// peers to a bounded pool of worker goroutines. By default UPDATE messages are
+// handled inline by each peer's FSM goroutine. An UpdateDispatcher may better
+// utilize CPUs in deployments with many peers, e.g. route collectors, by
+// decoupling the handling of UPDATE messages from the FSM.
+//
+// Each peer is assigned to a single worker so that its UPDATE messages are
+// handled in the order they are received. The FSM of a peer blocks when its
+// worker's queue is full, applying backpressure to the peer via TCP.
+//
+// An UpdateDispatcher may be shared between peers via WithUpdateDispatcher.
+type UpdateDispatcher struct {
+	seed   maphash.Seed
+	queues []chan func()
+	wg     sync.WaitGroup
+	// mu is read locked while queueing, closed is set and closeCh closed with
+	// it locked so that nothing is queued once the workers start draining
+	mu      sync.RWMutex
+	closed  bool
+	closeCh chan struct{}
+}
+
+// NewUpdateDispatcher returns a new UpdateDispatcher with the provided number
+// of workers, each with a queue of queueLen UPDATE messages.
+func NewUpdateDispatcher(workers, queueLen int) (*UpdateDispatcher, error) {
+	if workers < 1 {
+		return nil, errors.New("workers must be > 0")
+	}
+	if queueLen < 0 {
+		return nil, errors.New("queue length must be >= 0")
+	}
+	d := &UpdateDispatcher{
+		seed:    maphash.MakeSeed(),
+		queues:  make([]chan func(), workers),
+		closeCh: make(chan struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan func(), queueLen)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d, nil
+}
+
+func (d *UpdateDispatcher) work(queue chan func()) {
+	defer d.wg.Done()
+	for {
+		// queued UPDATE messages take precedence over closeCh
+		select {
+		case fn := <-queue:
+			fn()
+			continue
+		default:
+		}
+		select {
+		case fn := <-queue:
+			fn()
+		case <-d.closeCh:
+			// Each queued fn is tracked by its peer's FSM, which waits for
+			// it to run before the session is torn down.
+			for {
+				select {
+				case fn := <-queue:
+					fn()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close stops the workers of the UpdateDispatcher once they have handled the
+// UPDATE messages already queued. Peers continue to handle UPDATE messages
+// inline once their UpdateDispatcher is closed.
+func (d *UpdateDispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.closeCh)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// dispatch queues fn on the worker assigned to peer. fn is run inline if the
+// UpdateDispatcher is closed. It returns false without running fn if cancelCh
+// is closed before fn could be queued.
+func (d *UpdateDispatcher) dispatch(peer PeerConfig, fn func(),
+	cancelCh <-chan struct{}) bool {
+	b, _ := peer.RemoteAddress.MarshalBinary()
+	i := maphash.Bytes(d.seed, b) % uint64(len(d.queues))
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		// run fn after any of the peer's UPDATE messages still being
+		// drained by the workers in order to preserve ordering
+		d.wg.Wait()
+		fn()
+		return true
+	}
+	defer d.mu.RUnlock()
+	select {
+	case <-cancelCh:
+		return false
+	case d.queues[i] <- fn:
+		return true
+	}
+}
+
+// WithUpdateDispatcher returns a PeerOption that sets an UpdateDispatcher for
+// a peer. The peer's UpdateMessageHandler is invoked by one of the
+// UpdateDispatcher's workers rather than the peer's FSM goroutine. A
+// Notification returned by the UpdateMessageHandler is sent to the peer by the
+// FSM once the worker has handled the message. UPDATE messages queued for an
+// established session that ends before they are handled are discarded, and
+// OnClose is not fired until any in-flight UpdateMessageHandler returns.
+func WithUpdateDispatcher(d *UpdateDispatcher) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateDispatcher = d
+	})
+}
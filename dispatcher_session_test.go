@@ -0,0 +1,113 @@
+package corebgp_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// dispatcherTestPlugin blocks its UpdateMessageHandler on releaseCh after
+// signaling blockedCh for the first UPDATE message.
+type dispatcherTestPlugin struct {
+	livenessTestPlugin
+	blockedCh, releaseCh chan struct{}
+	handled              atomic.Int64
+}
+
+func (d *dispatcherTestPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return func(peer corebgp.PeerConfig, b []byte) *corebgp.Notification {
+		if d.handled.Add(1) == 1 {
+			close(d.blockedCh)
+			<-d.releaseCh
+		}
+		return nil
+	}
+}
+
+func TestUpdateDispatcher_CloseQueued(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	d, err := corebgp.NewUpdateDispatcher(1, 8)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	plugin := &dispatcherTestPlugin{
+		blockedCh: make(chan struct{}),
+		releaseCh: make(chan struct{}),
+	}
+	err = server.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, plugin, corebgp.WithPassive(), corebgp.WithUpdateDispatcher(d))
+	if !assert.NoError(t, err) {
+		return
+	}
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	go server.Serve([]net.Listener{l})
+	t.Cleanup(server.Close)
+
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	_, err = c.Establish()
+	if !assert.NoError(t, err) {
+		return
+	}
+	const updates = 4
+	for i := 0; i < updates; i++ {
+		assert.NoError(t, c.SendUpdate([]byte{0, 0, 0, 0}))
+	}
+	<-plugin.blockedCh
+	// let the remaining UPDATE messages be queued behind the blocked one
+	time.Sleep(time.Millisecond * 100)
+
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	// release the handler once Close is waiting for the worker
+	time.Sleep(time.Millisecond * 100)
+	close(plugin.releaseCh)
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Close did not return")
+	}
+	assert.Equal(t, int64(updates), plugin.handled.Load())
+
+	deleted := make(chan error)
+	go func() {
+		deleted <- server.DeletePeer(addrB)
+	}()
+	select {
+	case err := <-deleted:
+		assert.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("DeletePeer did not return")
+	}
+}
@@ -0,0 +1,78 @@
+package corebgp
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateDispatcher(t *testing.T) {
+	_, err := NewUpdateDispatcher(0, 1)
+	assert.Error(t, err)
+	_, err = NewUpdateDispatcher(1, -1)
+	assert.Error(t, err)
+
+	d, err := NewUpdateDispatcher(4, 8)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	peers := []PeerConfig{
+		{RemoteAddress: netip.MustParseAddr("192.0.2.1")},
+		{RemoteAddress: netip.MustParseAddr("192.0.2.2")},
+		{RemoteAddress: netip.MustParseAddr("2001:db8::1")},
+	}
+	const n = 1000
+	var mu sync.Mutex
+	got := make(map[netip.Addr][]int)
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				i := i
+				d.dispatch(p, func() {
+					mu.Lock()
+					defer mu.Unlock()
+					got[p.RemoteAddress] = append(got[p.RemoteAddress], i)
+				}, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	d.Close()
+
+	for _, p := range peers {
+		seq := got[p.RemoteAddress]
+		// each peer's messages are handled in order, including those still
+		// queued at Close
+		assert.Len(t, seq, n)
+		for i := range seq {
+			assert.Equal(t, i, seq[i])
+		}
+	}
+
+	// dispatching to a closed UpdateDispatcher runs inline
+	ran := false
+	assert.True(t, d.dispatch(peers[0], func() { ran = true }, nil))
+	assert.True(t, ran)
+}
+
+func TestUpdateDispatcher_Cancel(t *testing.T) {
+	d, err := NewUpdateDispatcher(1, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer d.Close()
+	blockCh := make(chan struct{})
+	peer := PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.1")}
+	assert.True(t, d.dispatch(peer, func() { <-blockCh }, nil))
+	cancelCh := make(chan struct{})
+	close(cancelCh)
+	assert.False(t, d.dispatch(peer, func() {}, cancelCh))
+	close(blockCh)
+}
@@ -0,0 +1,117 @@
+package corebgp
+
+import (
+	"encoding/binary"
+)
+
+// NewEndOfRIB returns an End-of-RIB marker for the provided AFI/SAFI as an
+// UPDATE message suitable for UpdateMessageWriter.WriteUpdate.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-2
+// An UPDATE message with no reachable Network Layer Reachability Information
+// (NLRI) and empty withdrawn NLRI is specified as the End-of-RIB marker that
+// can be used by a BGP speaker to indicate to its peer the completion of the
+// initial routing update after the session is established. For the IPv4
+// unicast address family, the End-of-RIB marker is an UPDATE message with the
+// minimum length [BGP-4]. For any other address family, it is an UPDATE
+// message that contains only the MP_UNREACH_NLRI attribute [BGP-MP] with no
+// withdrawn routes for that <AFI, SAFI>.
+func NewEndOfRIB(afi uint16, safi uint8) []byte {
+	if afi == AFI_IPV4 && safi == SAFI_UNICAST {
+		return make([]byte, 4)
+	}
+	b := make([]byte, 10)
+	// withdrawn routes length is zero, total path attribute length is 6
+	binary.BigEndian.PutUint16(b[2:], 6)
+	b[4] = 0x80 // optional, non-transitive
+	b[5] = PATH_ATTR_MP_UNREACH_NLRI
+	b[6] = 3
+	binary.BigEndian.PutUint16(b[7:], afi)
+	b[9] = safi
+	return b
+}
+
+// IsEndOfRIB returns the AFI/SAFI and true if the UPDATE message in b is an
+// End-of-RIB marker, see NewEndOfRIB.
+func IsEndOfRIB(b []byte) (MPExtensions, bool) {
+	if len(b) < 4 {
+		return MPExtensions{}, false
+	}
+	wrl := binary.BigEndian.Uint16(b)
+	pal := int(binary.BigEndian.Uint16(b[2:]))
+	if wrl != 0 || len(b)-4 != pal {
+		return MPExtensions{}, false
+	}
+	if pal == 0 {
+		return MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}, true
+	}
+	b = b[4:]
+	if len(b) < 3 || b[1] != PATH_ATTR_MP_UNREACH_NLRI {
+		return MPExtensions{}, false
+	}
+	flags := PathAttrFlags(b[0])
+	var attrLen int
+	if flags.ExtendedLen() {
+		attrLen = int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+	} else {
+		attrLen = int(b[2])
+		b = b[3:]
+	}
+	if attrLen != 3 || len(b) != 3 {
+		return MPExtensions{}, false
+	}
+	return MPExtensions{
+		AFI:  binary.BigEndian.Uint16(b),
+		SAFI: b[2],
+	}, true
+}
+
+// EndOfRIBObserver is called when an End-of-RIB marker is received from a peer
+// for a family for the first time during an established session. pending
+// contains the families negotiated for the session, see SessionInfo.Families,
+// for which an End-of-RIB marker has yet to be received. The initial routing
+// update from the peer is complete once pending is empty.
+type EndOfRIBObserver func(peer PeerConfig, family MPExtensions,
+	pending []MPExtensions)
+
+// WithEndOfRIBObserver returns a PeerOption that sets an EndOfRIBObserver for
+// a peer. The observer is called from the peer's FSM after the
+// UpdateMessageHandler has handled the End-of-RIB marker.
+func WithEndOfRIBObserver(fn EndOfRIBObserver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.eorObserver = fn
+	})
+}
+
+// endOfRIBTracker tracks the End-of-RIB markers received during a session.
+type endOfRIBTracker struct {
+	families []MPExtensions
+	received map[MPExtensions]bool
+}
+
+func newEndOfRIBTracker(families []MPExtensions) *endOfRIBTracker {
+	return &endOfRIBTracker{
+		families: families,
+		received: make(map[MPExtensions]bool),
+	}
+}
+
+// handleUpdate checks if the UPDATE message in b is an End-of-RIB marker,
+// returning its family, the negotiated families still pending, and true if it
+// is the first marker received for the family.
+func (e *endOfRIBTracker) handleUpdate(b []byte) (MPExtensions,
+	[]MPExtensions, bool) {
+	family, ok := IsEndOfRIB(b)
+	if !ok || e.received[family] {
+		return MPExtensions{}, nil, false
+	}
+	e.received[family] = true
+	pending := make([]MPExtensions, 0)
+	for _, f := range e.families {
+		if !e.received[f] {
+			pending = append(pending, f)
+		}
+	}
+	return family, pending, true
+}
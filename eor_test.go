@@ -0,0 +1,63 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndOfRIB(t *testing.T) {
+	for _, family := range []MPExtensions{
+		{AFI: AFI_IPV4, SAFI: SAFI_UNICAST},
+		{AFI: AFI_IPV6, SAFI: SAFI_UNICAST},
+		{AFI: AFI_IPV4, SAFI: SAFI_MULTICAST},
+	} {
+		got, ok := IsEndOfRIB(NewEndOfRIB(family.AFI, family.SAFI))
+		assert.True(t, ok)
+		assert.Equal(t, family, got)
+	}
+	assert.Equal(t, []byte{0, 0, 0, 0}, NewEndOfRIB(AFI_IPV4, SAFI_UNICAST))
+
+	// extended length attribute
+	got, ok := IsEndOfRIB([]byte{0, 0, 0, 7, 0x90, 15, 0, 3, 0, 2, 1})
+	assert.True(t, ok)
+	assert.Equal(t, MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}, got)
+
+	for _, b := range [][]byte{
+		nil,
+		{0, 0, 0},
+		// withdrawn route
+		{0, 2, 8, 10, 0, 0},
+		// NLRI
+		{0, 0, 0, 0, 8, 10},
+		// MP_UNREACH_NLRI with withdrawn routes
+		{0, 0, 0, 8, 0x80, 15, 5, 0, 2, 1, 8, 10},
+		// MP_REACH_NLRI
+		{0, 0, 0, 6, 0x80, 14, 3, 0, 2, 1},
+	} {
+		_, ok := IsEndOfRIB(b)
+		assert.False(t, ok, "%v", b)
+	}
+}
+
+func TestEndOfRIBTracker(t *testing.T) {
+	v4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	v6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+	e := newEndOfRIBTracker([]MPExtensions{v4, v6})
+
+	_, _, ok := e.handleUpdate([]byte{0, 0, 0, 0, 8, 10})
+	assert.False(t, ok)
+
+	family, pending, ok := e.handleUpdate(NewEndOfRIB(v6.AFI, v6.SAFI))
+	assert.True(t, ok)
+	assert.Equal(t, v6, family)
+	assert.Equal(t, []MPExtensions{v4}, pending)
+
+	_, _, ok = e.handleUpdate(NewEndOfRIB(v6.AFI, v6.SAFI))
+	assert.False(t, ok)
+
+	family, pending, ok = e.handleUpdate(NewEndOfRIB(v4.AFI, v4.SAFI))
+	assert.True(t, ok)
+	assert.Equal(t, v4, family)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,136 @@
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+)
+
+// linkEvent is a change to a network interface.
+type linkEvent struct {
+	ifindex int
+	// down is true if the interface went down or was removed
+	down bool
+	// deletedAddr is set if an address was removed from the interface
+	deletedAddr netip.Addr
+}
+
+// InterfaceMonitor is a LivenessMonitor that signals a peer as down as soon as
+// the network interface used to reach it goes down, or the local address used
+// to reach it is removed, mimicking the "fast-external-fallover" behavior of
+// routers. The interface is determined by a route lookup for the peer's remote
+// address when its session is established. It is used with
+// WithLivenessMonitor. InterfaceMonitor is only supported on Linux.
+type InterfaceMonitor struct {
+	conn   *linkEventConn
+	doneCh chan struct{}
+
+	mu       sync.Mutex
+	watchers map[*interfaceWatcher]bool
+	closed   bool
+}
+
+type interfaceWatcher struct {
+	ifindex  int
+	src      netip.Addr
+	downCh   chan struct{}
+	downOnce sync.Once
+}
+
+func (w *interfaceWatcher) handle(e linkEvent) {
+	if e.ifindex != w.ifindex {
+		return
+	}
+	if e.down || (e.deletedAddr.IsValid() && e.deletedAddr == w.src) {
+		w.downOnce.Do(func() {
+			close(w.downCh)
+		})
+	}
+}
+
+// NewInterfaceMonitor returns a new InterfaceMonitor, which must be closed
+// once no longer in use.
+func NewInterfaceMonitor() (*InterfaceMonitor, error) {
+	conn, err := openLinkEvents()
+	if err != nil {
+		return nil, err
+	}
+	m := &InterfaceMonitor{
+		conn:     conn,
+		doneCh:   make(chan struct{}),
+		watchers: make(map[*interfaceWatcher]bool),
+	}
+	go m.run()
+	return m, nil
+}
+
+func (m *InterfaceMonitor) run() {
+	defer close(m.doneCh)
+	for {
+		events, err := m.conn.read()
+		if err != nil {
+			m.mu.Lock()
+			closed := m.closed
+			m.mu.Unlock()
+			if !closed {
+				logf("interface monitor stopped: %v", err)
+			}
+			return
+		}
+		m.handle(events)
+	}
+}
+
+func (m *InterfaceMonitor) handle(events []linkEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range events {
+		for w := range m.watchers {
+			w.handle(e)
+		}
+	}
+}
+
+// Monitor implements LivenessMonitor. If the interface used to reach peer
+// cannot be determined the returned channel is nil, and the peer is never
+// signaled as down.
+func (m *InterfaceMonitor) Monitor(peer PeerConfig) (<-chan struct{}, func()) {
+	ifindex, src, err := routeInterface(peer.RemoteAddress)
+	if err != nil {
+		logf("[%s] unable to determine interface for peer: %v",
+			peer.RemoteAddress, err)
+		return nil, func() {}
+	}
+	w := &interfaceWatcher{
+		ifindex: ifindex,
+		src:     src,
+		downCh:  make(chan struct{}),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, func() {}
+	}
+	m.watchers[w] = true
+	return w.downCh, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.watchers, w)
+	}
+}
+
+// Close stops the InterfaceMonitor. Peers are no longer signaled as down once
+// it is closed.
+func (m *InterfaceMonitor) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return errors.New("interface monitor already closed")
+	}
+	m.closed = true
+	m.watchers = make(map[*interfaceWatcher]bool)
+	m.mu.Unlock()
+	err := m.conn.close()
+	<-m.doneCh
+	return err
+}
@@ -0,0 +1,36 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceMonitor_handle(t *testing.T) {
+	src := netip.MustParseAddr("192.0.2.1")
+	m := &InterfaceMonitor{
+		watchers: make(map[*interfaceWatcher]bool),
+	}
+	w := &interfaceWatcher{ifindex: 2, src: src, downCh: make(chan struct{})}
+	m.watchers[w] = true
+
+	isDown := func() bool {
+		select {
+		case <-w.downCh:
+			return true
+		default:
+			return false
+		}
+	}
+
+	m.handle([]linkEvent{
+		{ifindex: 3, down: true},
+		{ifindex: 2, deletedAddr: netip.MustParseAddr("192.0.2.2")},
+	})
+	assert.False(t, isDown())
+	m.handle([]linkEvent{{ifindex: 2, deletedAddr: src}})
+	assert.True(t, isDown())
+	// subsequent events must not panic
+	m.handle([]linkEvent{{ifindex: 2, down: true}})
+}
@@ -0,0 +1,132 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// UpdateFamilies returns the address families the UPDATE message b carries
+// routes for, without decoding its path attributes. IPv4 unicast is included
+// if the withdrawn routes or NLRI fields are non-empty, or if b is an IPv4
+// unicast End-of-RIB marker. Other families are determined by the
+// MP_REACH_NLRI and MP_UNREACH_NLRI path attributes. An error is returned if
+// the fields or path attributes of b are malformed.
+func UpdateFamilies(b []byte) ([]MPExtensions, error) {
+	families := make([]MPExtensions, 0, 1)
+	add := func(f MPExtensions) {
+		for _, existing := range families {
+			if existing == f {
+				return
+			}
+		}
+		families = append(families, f)
+	}
+	ipv4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	if len(b) < 2 {
+		return nil, errors.New("update message too short")
+	}
+	wrl := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < wrl+2 {
+		return nil, errors.New("invalid withdrawn routes length")
+	}
+	if wrl > 0 {
+		add(ipv4)
+	}
+	b = b[wrl:]
+	tpal := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < tpal {
+		return nil, errors.New("invalid total path attribute length")
+	}
+	attrs, nlri := b[:tpal], b[tpal:]
+	if wrl == 0 && tpal == 0 {
+		// IPv4 unicast End-of-RIB marker or NLRI
+		add(ipv4)
+	}
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return nil, errors.New("malformed path attribute")
+		}
+		flags, code := PathAttrFlags(attrs[0]), attrs[1]
+		var attrLen int
+		if flags.ExtendedLen() {
+			if len(attrs) < 4 {
+				return nil, errors.New("malformed path attribute")
+			}
+			attrLen = int(binary.BigEndian.Uint16(attrs[2:]))
+			attrs = attrs[4:]
+		} else {
+			attrLen = int(attrs[2])
+			attrs = attrs[3:]
+		}
+		if len(attrs) < attrLen {
+			return nil, errors.New("invalid path attribute length")
+		}
+		if code == PATH_ATTR_MP_REACH_NLRI || code == PATH_ATTR_MP_UNREACH_NLRI {
+			if attrLen < 3 {
+				return nil, errors.New("invalid multiprotocol path attribute length")
+			}
+			add(MPExtensions{
+				AFI:  binary.BigEndian.Uint16(attrs),
+				SAFI: attrs[2],
+			})
+		}
+		attrs = attrs[attrLen:]
+	}
+	if len(nlri) > 0 {
+		add(ipv4)
+	}
+	return families, nil
+}
+
+// NewFamilyUpdateHandler returns an UpdateMessageHandler that passes each
+// UPDATE message to the handlers for the address families it carries routes
+// for, see UpdateFamilies. A message carrying routes for multiple families is
+// passed to the handler of each, in order of appearance, until one returns a
+// non-nil Notification; handlers should only act on the routes of their own
+// family.
+//
+// Messages carrying routes for families without a handler are passed to
+// fallback, once, as are messages that cannot be attributed to a family due to
+// being malformed. fallback may be nil, in which case messages for families
+// without a handler are ignored, and malformed messages result in a Malformed
+// Attribute List Notification.
+//
+// Handlers must not call ReleaseUpdateBuffer, the returned handler releases
+// the message once handling completes. When WithPooledUpdateBuffers is used
+// handlers must therefore not retain the message after returning.
+func NewFamilyUpdateHandler(handlers map[MPExtensions]UpdateMessageHandler,
+	fallback UpdateMessageHandler) UpdateMessageHandler {
+	h := make(map[MPExtensions]UpdateMessageHandler, len(handlers))
+	for k, v := range handlers {
+		h[k] = v
+	}
+	return func(peer PeerConfig, updateMessage []byte) *Notification {
+		defer ReleaseUpdateBuffer(updateMessage)
+		families, err := UpdateFamilies(updateMessage)
+		if err != nil {
+			if fallback != nil {
+				return fallback(peer, updateMessage)
+			}
+			return newNotification(NOTIF_CODE_UPDATE_MESSAGE_ERR,
+				NOTIF_SUBCODE_MALFORMED_ATTR_LIST, nil)
+		}
+		unhandled := false
+		for _, f := range families {
+			handler, ok := h[f]
+			if !ok {
+				unhandled = true
+				continue
+			}
+			n := handler(peer, updateMessage)
+			if n != nil {
+				return n
+			}
+		}
+		if unhandled && fallback != nil {
+			return fallback(peer, updateMessage)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,225 @@
+package corebgp
+
+import (
+	"encoding/binary"
+)
+
+// FamilyMismatchPolicy controls how an UPDATE message carrying routes of an
+// AFI/SAFI that was not negotiated for the session, see SessionInfo.Families,
+// is handled. Such a message carries IPv4 unicast routes in its Withdrawn
+// Routes or NLRI fields without IPv4 unicast being negotiated, or an
+// MP_REACH_NLRI or MP_UNREACH_NLRI path attribute of another family that was
+// not negotiated.
+type FamilyMismatchPolicy uint8
+
+const (
+	// FamilyMismatchPolicyAccept passes the UPDATE message to the
+	// UpdateMessageHandler unmodified. This is the default.
+	FamilyMismatchPolicyAccept FamilyMismatchPolicy = iota
+	// FamilyMismatchPolicyIgnore discards the UPDATE message, and the session
+	// remains established.
+	FamilyMismatchPolicyIgnore
+	// FamilyMismatchPolicyTreatAsWithdraw passes an UPDATE message to the
+	// UpdateMessageHandler that withdraws the routes carried by the original,
+	// i.e. its NLRI field is appended to the Withdrawn Routes field, and its
+	// MP_REACH_NLRI path attribute is replaced with an MP_UNREACH_NLRI path
+	// attribute for the same NLRI. Other path attributes are removed. If the
+	// message also carries an MP_UNREACH_NLRI path attribute of a different
+	// family it is handled per FamilyMismatchPolicyReset.
+	//
+	// https://www.rfc-editor.org/rfc/rfc7606#section-2
+	FamilyMismatchPolicyTreatAsWithdraw
+	// FamilyMismatchPolicyReset sends a Notification with the Error Code
+	// UPDATE Message Error to the peer and transitions the FSM out of the
+	// Established state. The Error Subcode is Optional Attribute Error, with
+	// the offending MP_REACH_NLRI or MP_UNREACH_NLRI path attribute as data,
+	// or Invalid Network Field for IPv4 unicast routes.
+	//
+	// https://www.rfc-editor.org/rfc/rfc4760#section-7
+	FamilyMismatchPolicyReset
+)
+
+// FamilyMismatchObserver is called when an UPDATE message carrying routes of
+// family, which was not negotiated for the session, is received from a peer.
+// policy is the FamilyMismatchPolicy applied to the message. The observer is
+// called from the FSM and must not block.
+type FamilyMismatchObserver func(peer PeerConfig, family MPExtensions,
+	policy FamilyMismatchPolicy)
+
+// WithFamilyMismatchPolicy returns a PeerOption that sets the
+// FamilyMismatchPolicy for a peer. UPDATE messages are only inspected for a
+// family mismatch if the policy is not FamilyMismatchPolicyAccept, or a
+// FamilyMismatchObserver is set. Mismatches found are counted in
+// PeerStats.FamilyMismatches.
+func WithFamilyMismatchPolicy(p FamilyMismatchPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.familyMismatchPolicy = p
+	})
+}
+
+// WithFamilyMismatchObserver returns a PeerOption that sets a
+// FamilyMismatchObserver for a peer.
+func WithFamilyMismatchObserver(fn FamilyMismatchObserver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.familyMismatchObserver = fn
+	})
+}
+
+// familyMismatch describes the first route-carrying part of an UPDATE message
+// whose family was not negotiated.
+type familyMismatch struct {
+	family MPExtensions
+	// code is the path attribute carrying the family, or 0 for the Withdrawn
+	// Routes and NLRI fields.
+	code  uint8
+	flags PathAttrFlags
+	data  []byte
+}
+
+// findFamilyMismatch returns the familyMismatch of the indexed UPDATE message
+// and true if it carries routes of a family not in families.
+func findFamilyMismatch(x *UpdateIndex,
+	families []MPExtensions) (familyMismatch, bool) {
+	negotiated := func(m MPExtensions) bool {
+		for _, f := range families {
+			if f == m {
+				return true
+			}
+		}
+		return false
+	}
+	ipv4Unicast := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	if (len(x.Withdrawn()) > 0 || len(x.NLRI()) > 0) &&
+		!negotiated(ipv4Unicast) {
+		return familyMismatch{family: ipv4Unicast}, true
+	}
+	for _, code := range []uint8{PATH_ATTR_MP_REACH_NLRI,
+		PATH_ATTR_MP_UNREACH_NLRI} {
+		flags, data, ok := x.Attr(code)
+		if !ok || len(data) < 3 {
+			continue
+		}
+		m := MPExtensions{
+			AFI:  binary.BigEndian.Uint16(data),
+			SAFI: data[2],
+		}
+		if !negotiated(m) {
+			return familyMismatch{
+				family: m,
+				code:   code,
+				flags:  flags,
+				data:   data,
+			}, true
+		}
+	}
+	return familyMismatch{}, false
+}
+
+// notification returns the Notification sent per FamilyMismatchPolicyReset.
+func (m familyMismatch) notification() *Notification {
+	if m.code == 0 {
+		return newNotification(NOTIF_CODE_UPDATE_MESSAGE_ERR,
+			NOTIF_SUBCODE_INVALID_NETWORK_FIELD, nil)
+	}
+	return newNotification(NOTIF_CODE_UPDATE_MESSAGE_ERR,
+		NOTIF_SUBCODE_OPTIONAL_ATTR_ERR,
+		AppendPathAttr(nil, m.flags, m.code, m.data))
+}
+
+// treatAsWithdraw returns an UPDATE message withdrawing the routes carried by
+// the indexed UPDATE message, see FamilyMismatchPolicyTreatAsWithdraw. It
+// returns false if the withdrawals cannot be carried by a single message.
+func treatAsWithdraw(x *UpdateIndex) ([]byte, bool) {
+	withdrawn := len(x.Withdrawn()) + len(x.NLRI())
+	if withdrawn > 0xffff {
+		return nil, false
+	}
+	var unreach []byte
+	_, unreachData, hasUnreach := x.Attr(PATH_ATTR_MP_UNREACH_NLRI)
+	if hasUnreach {
+		if len(unreachData) < 3 {
+			return nil, false
+		}
+		unreach = append(unreach, unreachData...)
+	}
+	if _, reach, ok := x.Attr(PATH_ATTR_MP_REACH_NLRI); ok {
+		if len(reach) < 5 || len(reach) < 5+int(reach[3]) {
+			return nil, false
+		}
+		nlri := reach[5+int(reach[3]):]
+		if hasUnreach {
+			if binary.BigEndian.Uint16(unreach) !=
+				binary.BigEndian.Uint16(reach) || unreach[2] != reach[2] {
+				return nil, false
+			}
+		} else {
+			unreach = append(unreach, reach[:3]...)
+		}
+		unreach = append(unreach, nlri...)
+	}
+	var attrs []byte
+	if unreach != nil {
+		attrs = AppendPathAttr(nil, 0x80, PATH_ATTR_MP_UNREACH_NLRI, unreach)
+	}
+	if len(attrs) > 0xffff {
+		return nil, false
+	}
+	b := make([]byte, 0, 4+withdrawn+len(attrs))
+	b = binary.BigEndian.AppendUint16(b, uint16(withdrawn))
+	b = append(b, x.Withdrawn()...)
+	b = append(b, x.NLRI()...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	return append(b, attrs...), true
+}
+
+// handleFamilyMismatch applies the peer's FamilyMismatchPolicy to the UPDATE
+// message m received during a session for which families were negotiated. It
+// returns the message to pass to the UpdateMessageHandler, which is nil if m
+// was discarded, or a non-nil Notification if the session should be reset.
+func (f *fsm) handleFamilyMismatch(m updateMessage,
+	families []MPExtensions) (updateMessage, *Notification) {
+	o := f.peer.options()
+	if o.familyMismatchPolicy == FamilyMismatchPolicyAccept &&
+		o.familyMismatchObserver == nil {
+		return m, nil
+	}
+	x, err := NewUpdateIndex(m)
+	if err != nil {
+		// malformed messages are left to the UpdateMessageHandler
+		return m, nil
+	}
+	mismatch, ok := findFamilyMismatch(x, families)
+	if !ok {
+		return m, nil
+	}
+	policy := o.familyMismatchPolicy
+	var (
+		out updateMessage
+		n   *Notification
+	)
+	switch policy {
+	case FamilyMismatchPolicyIgnore:
+	case FamilyMismatchPolicyTreatAsWithdraw:
+		if b, ok := treatAsWithdraw(x); ok {
+			out = b
+			break
+		}
+		policy = FamilyMismatchPolicyReset
+		n = mismatch.notification()
+	case FamilyMismatchPolicyReset:
+		n = mismatch.notification()
+	default:
+		out = m
+	}
+	f.peer.stats.familyMismatches.Add(1)
+	f.peer.logf("received UPDATE for non-negotiated AFI %d SAFI %d",
+		mismatch.family.AFI, mismatch.family.SAFI)
+	if o.familyMismatchObserver != nil {
+		o.familyMismatchObserver(f.peer.config, mismatch.family, policy)
+	}
+	if o.pooledUpdates &&
+		(out == nil || policy == FamilyMismatchPolicyTreatAsWithdraw) {
+		ReleaseUpdateBuffer(m)
+	}
+	return out, n
+}
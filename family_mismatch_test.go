@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindFamilyMismatch(t *testing.T) {
+	ipv4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	ipv6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+
+	x, err := NewUpdateIndex(newIndexTestUpdate(0, 1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, ok := findFamilyMismatch(x, []MPExtensions{ipv4})
+	assert.False(t, ok)
+	m, ok := findFamilyMismatch(x, []MPExtensions{ipv6})
+	if assert.True(t, ok) {
+		assert.Equal(t, ipv4, m.family)
+		n := m.notification()
+		assert.Equal(t, NOTIF_SUBCODE_INVALID_NETWORK_FIELD, n.Subcode)
+	}
+
+	reach := []byte{0, 2, 1, 16}
+	reach = append(reach, make([]byte, 16)...)
+	reach = append(reach, 0, 32, 0x20, 0x01, 0x0d, 0xb8)
+	b := []byte{0, 0, 0, 0}
+	b = AppendPathAttr(b, 0x80, PATH_ATTR_MP_REACH_NLRI, reach)
+	b[3] = uint8(len(b) - 4)
+	x, err = NewUpdateIndex(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, ok = findFamilyMismatch(x, []MPExtensions{ipv4, ipv6})
+	assert.False(t, ok)
+	m, ok = findFamilyMismatch(x, []MPExtensions{ipv4})
+	if assert.True(t, ok) {
+		assert.Equal(t, ipv6, m.family)
+		n := m.notification()
+		assert.Equal(t, NOTIF_SUBCODE_OPTIONAL_ATTR_ERR, n.Subcode)
+		assert.Equal(t, b[4:], n.Data)
+	}
+}
+
+func TestTreatAsWithdraw(t *testing.T) {
+	x, err := NewUpdateIndex(newIndexTestUpdate(1, 2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, ok := treatAsWithdraw(x)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{
+		0, 8, // withdrawn routes length
+		24, 198, 51, 0, 24, 198, 51, 1,
+		0, 0, // total path attribute length
+	}, b)
+
+	reach := []byte{0, 2, 1, 16}
+	reach = append(reach, make([]byte, 16)...)
+	reach = append(reach, 0, 32, 0x20, 0x01, 0x0d, 0xb8)
+	newUpdate := func(attrs ...[]byte) []byte {
+		b := []byte{0, 0, 0, 0}
+		for _, a := range attrs {
+			b = append(b, a...)
+		}
+		b[3] = uint8(len(b) - 4)
+		return b
+	}
+	x, err = NewUpdateIndex(newUpdate(
+		AppendPathAttr(nil, 0x40, PATH_ATTR_ORIGIN, []byte{0}),
+		AppendPathAttr(nil, 0x80, PATH_ATTR_MP_REACH_NLRI, reach),
+		AppendPathAttr(nil, 0x80, PATH_ATTR_MP_UNREACH_NLRI,
+			[]byte{0, 2, 1, 32, 0x20, 0x01, 0x0d, 0xb9}),
+	))
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, ok = treatAsWithdraw(x)
+	assert.True(t, ok)
+	assert.Equal(t, newUpdate(AppendPathAttr(nil, 0x80,
+		PATH_ATTR_MP_UNREACH_NLRI, []byte{0, 2, 1, 32, 0x20, 0x01, 0x0d, 0xb9,
+			32, 0x20, 0x01, 0x0d, 0xb8})), b)
+
+	// withdrawals of different families cannot be carried in one message
+	x, err = NewUpdateIndex(newUpdate(
+		AppendPathAttr(nil, 0x80, PATH_ATTR_MP_REACH_NLRI, reach),
+		AppendPathAttr(nil, 0x80, PATH_ATTR_MP_UNREACH_NLRI,
+			[]byte{0, 1, 1, 24, 192, 0, 2}),
+	))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, ok = treatAsWithdraw(x)
+	assert.False(t, ok)
+}
@@ -0,0 +1,93 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateFamilies(t *testing.T) {
+	ipv4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	ipv6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+	evpn := MPExtensions{AFI: AFI_L2VPN_INFO, SAFI: SAFI_BGP_EVPNS}
+	tests := []struct {
+		name    string
+		b       []byte
+		want    []MPExtensions
+		wantErr bool
+	}{
+		{
+			name: "ipv4 eor",
+			b:    NewEndOfRIB(AFI_IPV4, SAFI_UNICAST),
+			want: []MPExtensions{ipv4},
+		},
+		{
+			name: "ipv6 eor",
+			b:    NewEndOfRIB(AFI_IPV6, SAFI_UNICAST),
+			want: []MPExtensions{ipv6},
+		},
+		{
+			name: "ipv4 withdrawn",
+			b:    []byte{0, 2, 8, 10, 0, 0},
+			want: []MPExtensions{ipv4},
+		},
+		{
+			name: "mp reach and ipv4 nlri",
+			b: []byte{
+				0, 0, // withdrawn routes length
+				0, 9, // total path attribute length
+				0x90, PATH_ATTR_MP_REACH_NLRI, 0, 5, 0, 25, 70, 0, 0, // evpn
+				8, 10, // nlri
+			},
+			want: []MPExtensions{evpn, ipv4},
+		},
+		{
+			name:    "truncated attribute",
+			b:       []byte{0, 0, 0, 3, 0x80, PATH_ATTR_MP_REACH_NLRI, 5},
+			wantErr: true,
+		},
+		{
+			name:    "invalid withdrawn length",
+			b:       []byte{0, 5, 0, 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UpdateFamilies(tt.b)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewFamilyUpdateHandler(t *testing.T) {
+	ipv6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+	var ipv6Calls, fallbackCalls int
+	h := NewFamilyUpdateHandler(map[MPExtensions]UpdateMessageHandler{
+		ipv6: func(PeerConfig, []byte) *Notification {
+			ipv6Calls++
+			return nil
+		},
+	}, func(PeerConfig, []byte) *Notification {
+		fallbackCalls++
+		return nil
+	})
+	assert.Nil(t, h(PeerConfig{}, NewEndOfRIB(AFI_IPV6, SAFI_UNICAST)))
+	assert.Equal(t, 1, ipv6Calls)
+	assert.Equal(t, 0, fallbackCalls)
+	assert.Nil(t, h(PeerConfig{}, NewEndOfRIB(AFI_IPV4, SAFI_UNICAST)))
+	assert.Equal(t, 1, ipv6Calls)
+	assert.Equal(t, 1, fallbackCalls)
+
+	h = NewFamilyUpdateHandler(nil, nil)
+	assert.Nil(t, h(PeerConfig{}, NewEndOfRIB(AFI_IPV4, SAFI_UNICAST)))
+	n := h(PeerConfig{}, []byte{0, 5})
+	if assert.NotNil(t, n) {
+		assert.Equal(t, NOTIF_CODE_UPDATE_MESSAGE_ERR, n.Code)
+	}
+}
@@ -0,0 +1,83 @@
+// Package fib installs routes into a kernel routing table, e.g. the best paths
+// selected by a corebgp application for service routes received from or
+// announced to its peers. It is only supported on Linux, where routes are
+// programmed via netlink.
+package fib
+
+import (
+	"errors"
+	"net/netip"
+)
+
+const (
+	// DefaultTable is the main routing table.
+	DefaultTable = 254
+	// DefaultProtocol is the routing protocol identifier for BGP, RTPROT_BGP.
+	DefaultProtocol = 186
+)
+
+// Config is the configuration of a FIB.
+type Config struct {
+	// Table is the routing table routes are installed in. It defaults to
+	// DefaultTable.
+	Table uint32
+
+	// Protocol is the routing protocol identifier routes are installed with.
+	// It identifies the routes owned by the FIB, see Flush. It defaults to
+	// DefaultProtocol.
+	Protocol uint8
+
+	// Metric is the metric (priority) routes are installed with.
+	Metric uint32
+}
+
+// Route is a route to be installed in the routing table.
+type Route struct {
+	// Prefix is the destination of the route.
+	Prefix netip.Prefix
+
+	// NextHops are the gateways of the route. They must be of the same
+	// address family as Prefix. Multiple next hops result in a multipath
+	// route.
+	NextHops []netip.Addr
+
+	// Weights are the weights of NextHops for a multipath route, between 1
+	// and MaxWeight, e.g. as returned by rib.MultipathWeights. Traffic is
+	// shared equally between next hops if Weights is nil.
+	Weights []int
+}
+
+// MaxWeight is the maximum weight of a next hop of a multipath route.
+const MaxWeight = 256
+
+func (r Route) validate() error {
+	if !r.Prefix.IsValid() {
+		return errors.New("invalid prefix")
+	}
+	if len(r.NextHops) == 0 {
+		return errors.New("route must have at least one next hop")
+	}
+	for _, nh := range r.NextHops {
+		if nh.Is4() != r.Prefix.Addr().Is4() {
+			return errors.New("next hop address family mismatch")
+		}
+	}
+	if r.Weights != nil && len(r.Weights) != len(r.NextHops) {
+		return errors.New("weights must match next hops")
+	}
+	for _, w := range r.Weights {
+		if w < 1 || w > MaxWeight {
+			return errors.New("weight must be between 1 and MaxWeight")
+		}
+	}
+	return nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Table == 0 {
+		c.Table = DefaultTable
+	}
+	if c.Protocol == 0 {
+		c.Protocol = DefaultProtocol
+	}
+}
@@ -0,0 +1,337 @@
+package fib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// FIB installs routes into a kernel routing table via netlink.
+type FIB struct {
+	config Config
+
+	mu        sync.Mutex
+	fd        int
+	seq       uint32
+	installed map[netip.Prefix]bool
+	closed    bool
+}
+
+// New returns a new FIB. This function is only supported on Linux.
+func New(config Config) (*FIB, error) {
+	config.setDefaults()
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC,
+		unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("error opening netlink socket: %w", err)
+	}
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("error binding netlink socket: %w", err)
+	}
+	return &FIB{
+		config:    config,
+		fd:        fd,
+		installed: make(map[netip.Prefix]bool),
+	}, nil
+}
+
+// Replace installs r, replacing any existing route for r.Prefix in the table
+// with the same metric.
+func (f *FIB) Replace(r Route) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	r.Prefix = r.Prefix.Masked()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("fib closed")
+	}
+	err = f.request(unix.RTM_NEWROUTE,
+		unix.NLM_F_CREATE|unix.NLM_F_REPLACE, f.encodeRoute(r))
+	if err != nil {
+		return fmt.Errorf("error replacing route %s: %w", r.Prefix, err)
+	}
+	f.installed[r.Prefix] = true
+	return nil
+}
+
+// Delete removes the route for prefix. Deleting a route that does not exist
+// is not an error.
+func (f *FIB) Delete(prefix netip.Prefix) error {
+	prefix = prefix.Masked()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("fib closed")
+	}
+	err := f.deleteLocked(prefix)
+	if err != nil {
+		return fmt.Errorf("error deleting route %s: %w", prefix, err)
+	}
+	return nil
+}
+
+func (f *FIB) deleteLocked(prefix netip.Prefix) error {
+	err := f.request(unix.RTM_DELROUTE, 0,
+		f.encodeRoute(Route{Prefix: prefix}))
+	if errors.Is(err, unix.ESRCH) {
+		err = nil
+	}
+	if err == nil {
+		delete(f.installed, prefix)
+	}
+	return err
+}
+
+// Flush removes all routes in the table with the FIB's protocol, including
+// those left behind by a previous process that did not Close its FIB, e.g.
+// following a crash. It is typically called prior to installing routes.
+func (f *FIB) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("fib closed")
+	}
+	prefixes, err := f.dumpLocked()
+	if err != nil {
+		return fmt.Errorf("error dumping routes: %w", err)
+	}
+	for _, prefix := range prefixes {
+		err = f.deleteLocked(prefix)
+		if err != nil {
+			return fmt.Errorf("error deleting route %s: %w", prefix, err)
+		}
+	}
+	return nil
+}
+
+// Close removes the routes installed by the FIB and releases its resources.
+func (f *FIB) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	var errs error
+	for prefix := range f.installed {
+		err := f.deleteLocked(prefix)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error deleting route %s: %w",
+				prefix, err))
+		}
+	}
+	f.closed = true
+	return errors.Join(errs, unix.Close(f.fd))
+}
+
+func rtaAlign(l int) int {
+	return (l + unix.RTA_ALIGNTO - 1) & ^(unix.RTA_ALIGNTO - 1)
+}
+
+func appendAttr(b []byte, typ uint16, data []byte) []byte {
+	l := unix.SizeofRtAttr + len(data)
+	b = binary.NativeEndian.AppendUint16(b, uint16(l))
+	b = binary.NativeEndian.AppendUint16(b, typ)
+	b = append(b, data...)
+	for i := l; i < rtaAlign(l); i++ {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func uint32Attr(v uint32) []byte {
+	return binary.NativeEndian.AppendUint32(nil, v)
+}
+
+// encodeRoute returns the rtmsg and attributes for r. r.NextHops may be empty
+// for deletion.
+//
+// https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+func (f *FIB) encodeRoute(r Route) []byte {
+	family := unix.AF_INET
+	if r.Prefix.Addr().Is6() {
+		family = unix.AF_INET6
+	}
+	table := f.config.Table
+	rtmTable := uint8(unix.RT_TABLE_UNSPEC)
+	if table < 256 {
+		rtmTable = uint8(table)
+	}
+	b := []byte{
+		uint8(family),          // rtm_family
+		uint8(r.Prefix.Bits()), // rtm_dst_len
+		0,                      // rtm_src_len
+		0,                      // rtm_tos
+		rtmTable,               // rtm_table
+		f.config.Protocol,      // rtm_protocol
+		unix.RT_SCOPE_UNIVERSE, // rtm_scope
+		unix.RTN_UNICAST,       // rtm_type
+		0, 0, 0, 0,             // rtm_flags
+	}
+	b = appendAttr(b, unix.RTA_TABLE, uint32Attr(table))
+	b = appendAttr(b, unix.RTA_DST, r.Prefix.Addr().AsSlice())
+	b = appendAttr(b, unix.RTA_PRIORITY, uint32Attr(f.config.Metric))
+	switch {
+	case len(r.NextHops) == 1:
+		b = appendAttr(b, unix.RTA_GATEWAY, r.NextHops[0].AsSlice())
+	case len(r.NextHops) > 1:
+		var mp []byte
+		for i, nh := range r.NextHops {
+			gw := appendAttr(nil, unix.RTA_GATEWAY, nh.AsSlice())
+			// rtnh_hops is the weight of the next hop minus one
+			var hops uint8
+			if r.Weights != nil {
+				hops = uint8(r.Weights[i] - 1)
+			}
+			// struct rtnexthop
+			mp = binary.NativeEndian.AppendUint16(mp,
+				uint16(unix.SizeofRtNexthop+len(gw))) // rtnh_len
+			mp = append(mp, 0, hops)                     // rtnh_flags, rtnh_hops
+			mp = binary.NativeEndian.AppendUint32(mp, 0) // rtnh_ifindex
+			mp = append(mp, gw...)
+		}
+		b = appendAttr(b, unix.RTA_MULTIPATH, mp)
+	}
+	return b
+}
+
+// request sends a netlink request of type typ and waits for its
+// acknowledgement. It must be called with f.mu held.
+func (f *FIB) request(typ uint16, flags uint16, data []byte) error {
+	f.seq++
+	seq := f.seq
+	err := f.send(typ, unix.NLM_F_REQUEST|unix.NLM_F_ACK|flags, seq, data)
+	if err != nil {
+		return err
+	}
+	_, err = f.receive(seq)
+	return err
+}
+
+func (f *FIB) send(typ, flags uint16, seq uint32, data []byte) error {
+	b := make([]byte, 0, unix.SizeofNlMsghdr+len(data))
+	b = binary.NativeEndian.AppendUint32(b,
+		uint32(unix.SizeofNlMsghdr+len(data))) // nlmsg_len
+	b = binary.NativeEndian.AppendUint16(b, typ)   // nlmsg_type
+	b = binary.NativeEndian.AppendUint16(b, flags) // nlmsg_flags
+	b = binary.NativeEndian.AppendUint32(b, seq)   // nlmsg_seq
+	b = binary.NativeEndian.AppendUint32(b, 0)     // nlmsg_pid
+	b = append(b, data...)
+	return unix.Sendto(f.fd, b, 0, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+	})
+}
+
+// receive reads messages for seq until an acknowledgement, error, or the end
+// of a dump, returning any data messages.
+func (f *FIB) receive(seq uint32) ([]syscall.NetlinkMessage, error) {
+	buf := make([]byte, unix.Getpagesize()*8)
+	msgs := make([]syscall.NetlinkMessage, 0)
+	for {
+		n, _, err := unix.Recvfrom(f.fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range parsed {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return msgs, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, errors.New("short netlink error message")
+				}
+				errno := int32(binary.NativeEndian.Uint32(m.Data))
+				if errno == 0 {
+					return msgs, nil
+				}
+				return nil, unix.Errno(-errno)
+			default:
+				// m.Data references buf, which is reused
+				m.Data = append([]byte{}, m.Data...)
+				msgs = append(msgs, m)
+			}
+		}
+	}
+}
+
+// dumpLocked returns the prefixes of routes in the table with the FIB's
+// protocol. It must be called with f.mu held.
+func (f *FIB) dumpLocked() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		f.seq++
+		seq := f.seq
+		rtmsg := make([]byte, unix.SizeofRtMsg)
+		rtmsg[0] = family
+		err := f.send(unix.RTM_GETROUTE, unix.NLM_F_REQUEST|unix.NLM_F_DUMP,
+			seq, rtmsg)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := f.receive(seq)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			prefix, ok := f.parseRoute(m)
+			if ok {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	return prefixes, nil
+}
+
+// parseRoute returns the destination prefix of the route in m and true if it
+// belongs to the FIB's table and protocol.
+func (f *FIB) parseRoute(m syscall.NetlinkMessage) (netip.Prefix, bool) {
+	if m.Header.Type != unix.RTM_NEWROUTE || len(m.Data) < unix.SizeofRtMsg {
+		return netip.Prefix{}, false
+	}
+	dstLen, table, protocol := m.Data[1], uint32(m.Data[4]), m.Data[5]
+	if protocol != f.config.Protocol {
+		return netip.Prefix{}, false
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	var dst netip.Addr
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case unix.RTA_TABLE:
+			if len(a.Value) == 4 {
+				table = binary.NativeEndian.Uint32(a.Value)
+			}
+		case unix.RTA_DST:
+			dst, _ = netip.AddrFromSlice(a.Value)
+		}
+	}
+	if table != f.config.Table {
+		return netip.Prefix{}, false
+	}
+	if !dst.IsValid() {
+		// default route
+		dst = netip.IPv4Unspecified()
+		if m.Data[0] == unix.AF_INET6 {
+			dst = netip.IPv6Unspecified()
+		}
+	}
+	return netip.PrefixFrom(dst, int(dstLen)), true
+}
@@ -0,0 +1,117 @@
+package fib
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestRoute_validate(t *testing.T) {
+	v4 := netip.MustParsePrefix("192.0.2.0/24")
+	assert.NoError(t, Route{Prefix: v4,
+		NextHops: []netip.Addr{netip.MustParseAddr("127.0.0.1")}}.validate())
+	assert.Error(t, Route{Prefix: v4}.validate())
+	assert.Error(t, Route{Prefix: v4,
+		NextHops: []netip.Addr{netip.MustParseAddr("::1")}}.validate())
+	assert.Error(t, Route{}.validate())
+	two := []netip.Addr{netip.MustParseAddr("127.0.0.1"),
+		netip.MustParseAddr("127.0.0.2")}
+	assert.NoError(t, Route{Prefix: v4, NextHops: two,
+		Weights: []int{1, MaxWeight}}.validate())
+	assert.Error(t, Route{Prefix: v4, NextHops: two,
+		Weights: []int{1}}.validate())
+	assert.Error(t, Route{Prefix: v4, NextHops: two,
+		Weights: []int{0, 1}}.validate())
+	assert.Error(t, Route{Prefix: v4, NextHops: two,
+		Weights: []int{1, MaxWeight + 1}}.validate())
+}
+
+func TestEncodeRoute(t *testing.T) {
+	f := &FIB{config: Config{Table: 1000, Metric: 10}}
+	f.config.setDefaults()
+	b := f.encodeRoute(Route{
+		Prefix: netip.MustParsePrefix("192.0.2.0/24"),
+		NextHops: []netip.Addr{
+			netip.MustParseAddr("198.51.100.1"),
+			netip.MustParseAddr("198.51.100.2"),
+		},
+	})
+	if !assert.True(t, len(b) > unix.SizeofRtMsg) {
+		return
+	}
+	assert.Equal(t, []byte{unix.AF_INET, 24, 0, 0, unix.RT_TABLE_UNSPEC,
+		DefaultProtocol, unix.RT_SCOPE_UNIVERSE, unix.RTN_UNICAST},
+		b[:8])
+	// RTA_TABLE, RTA_DST, RTA_PRIORITY, RTA_MULTIPATH
+	assert.Equal(t, unix.SizeofRtMsg+8+8+8+(4+2*(8+8)), len(b))
+
+	b = f.encodeRoute(Route{
+		Prefix: netip.MustParsePrefix("192.0.2.0/24"),
+		NextHops: []netip.Addr{
+			netip.MustParseAddr("198.51.100.1"),
+			netip.MustParseAddr("198.51.100.2"),
+		},
+		Weights: []int{1, 3},
+	})
+	// rtnh_hops of each struct rtnexthop following the RTA_MULTIPATH header
+	mp := b[unix.SizeofRtMsg+8+8+8+4:]
+	if assert.Len(t, mp, 2*(8+8)) {
+		assert.Equal(t, uint8(0), mp[3])
+		assert.Equal(t, uint8(2), mp[16+3])
+	}
+}
+
+// newTestFIB returns a FIB for table 100, skipping the test if routes cannot
+// be programmed, e.g. due to insufficient privileges.
+func newTestFIB(t *testing.T) *FIB {
+	f, err := New(Config{Table: 100})
+	if err != nil {
+		t.Skipf("netlink unavailable: %v", err)
+	}
+	err = f.Flush()
+	if errors.Is(err, unix.EPERM) {
+		f.Close()
+		t.Skipf("insufficient privileges: %v", err)
+	}
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return f
+}
+
+func TestFIB(t *testing.T) {
+	f := newTestFIB(t)
+	prefix := netip.MustParsePrefix("198.51.100.0/24")
+	lo := netip.MustParseAddr("127.0.0.1")
+	err := f.Replace(Route{Prefix: prefix, NextHops: []netip.Addr{lo}})
+	if errors.Is(err, unix.EPERM) {
+		f.Close()
+		t.Skipf("insufficient privileges: %v", err)
+	}
+	assert.NoError(t, err)
+	err = f.Replace(Route{Prefix: netip.MustParsePrefix("203.0.113.0/24"),
+		NextHops: []netip.Addr{lo, netip.MustParseAddr("127.0.0.2")}})
+	assert.NoError(t, err)
+
+	f.mu.Lock()
+	prefixes, err := f.dumpLocked()
+	f.mu.Unlock()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []netip.Prefix{prefix,
+		netip.MustParsePrefix("203.0.113.0/24")}, prefixes)
+
+	assert.NoError(t, f.Delete(prefix))
+	assert.NoError(t, f.Delete(prefix))
+
+	assert.NoError(t, f.Close())
+	f = newTestFIB(t)
+	defer f.Close()
+	f.mu.Lock()
+	prefixes, err = f.dumpLocked()
+	f.mu.Unlock()
+	assert.NoError(t, err)
+	assert.Empty(t, prefixes)
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+// +build !linux
+
+package fib
+
+import (
+	"errors"
+	"net/netip"
+)
+
+var errUnsupported = errors.New("unsupported")
+
+// FIB installs routes into a kernel routing table.
+type FIB struct{}
+
+// New returns a new FIB. This function is only supported on Linux.
+func New(config Config) (*FIB, error) {
+	return nil, errUnsupported
+}
+
+// Replace installs r, replacing any existing route for r.Prefix.
+func (f *FIB) Replace(r Route) error {
+	return errUnsupported
+}
+
+// Delete removes the route for prefix.
+func (f *FIB) Delete(prefix netip.Prefix) error {
+	return errUnsupported
+}
+
+// Flush removes all routes in the table with the FIB's protocol.
+func (f *FIB) Flush() error {
+	return errUnsupported
+}
+
+// Close removes the routes installed by the FIB and releases its resources.
+func (f *FIB) Close() error {
+	return errUnsupported
+}
@@ -10,17 +10,28 @@ import (
 	"net/netip"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type fsm struct {
 	peer *peer
+	// dir is the index of this fsm in peer.fsms, i.e. in or out
+	dir int
 
 	// the bgp ID received in the latest open message
 	remoteID uint32
 
+	// the capabilities sent and received in the latest open messages
+	localCaps  []Capability
+	remoteCaps []Capability
+	// addPathRx is derived from the capabilities once the OPEN message is
+	// received, it is read by the reader goroutine
+	addPathRx atomic.Pointer[addPathRx]
+
 	// conn-related fields
 	conn         net.Conn
+	md5Key       string // the tcp md5 key of conn, if any
 	dialResultCh chan *dialResult
 	cancelDialFn context.CancelFunc
 
@@ -31,28 +42,36 @@ type fsm struct {
 	closeReaderCh   chan struct{}
 	closeReaderOnce sync.Once
 
+	// progress tracks reads from and writes to conn
+	progress *connProgress
+
 	// control channels
 	closeOnce sync.Once
 	closeCh   chan struct{}
 	doneCh    chan struct{}
 
 	// timers
-	connectRetryTimer *time.Timer
-	holdTimer         *time.Timer
+	connectRetryTimer Timer
+	holdTimer         Timer
 	holdTime          time.Duration
-	keepAliveTimer    *time.Timer
+	keepAliveTimer    Timer
 	keepAliveInterval time.Duration
-	idleHoldTimer     *time.Timer
+	idleHoldTimer     Timer
 }
 
-func newFSM(peer *peer, conn net.Conn) *fsm {
+func newFSM(peer *peer, dir int, conn net.Conn) *fsm {
 	f := &fsm{
 		peer:    peer,
+		dir:     dir,
 		conn:    conn,
 		closeCh: make(chan struct{}),
 		doneCh:  make(chan struct{}),
 		// we do not hold down the first time entering idle state
-		idleHoldTimer: time.NewTimer(0),
+		idleHoldTimer: peer.options().clock.NewTimer(0),
+	}
+	if dir == in {
+		// inbound connections use the listener's key at the time of accept
+		f.md5Key = peer.listenerMD5Key()
 	}
 	return f
 }
@@ -96,7 +115,7 @@ func (f *fsm) cleanup() {
 		<-f.dialResultCh
 	}
 	f.cleanupConnAndReader()
-	for _, t := range []*time.Timer{f.connectRetryTimer, f.holdTimer,
+	for _, t := range []Timer{f.connectRetryTimer, f.holdTimer,
 		f.keepAliveTimer, f.idleHoldTimer} {
 		if t != nil {
 			t.Stop()
@@ -127,11 +146,11 @@ func (f *fsm) run() {
 		// signal state transition to local peer manager for coordination with
 		// the "other" fsm.
 		select {
-		case f.peer.getFSMTransitionCh(f) <- t:
+		case f.peer.transitionCh[f.dir] <- t:
 			select {
 			case <-f.closeCh:
 				t = newStateTransition(t.from, disabledState)
-			case t = <-f.peer.getFSMTransitionCh(f):
+			case t = <-f.peer.transitionCh[f.dir]:
 			}
 		case <-f.closeCh:
 			t = newStateTransition(t.from, disabledState)
@@ -170,7 +189,7 @@ func (f *fsm) run() {
 			select {
 			case <-f.closeCh:
 				t = newStateTransition(t.to, disabledState)
-			case f.peer.getFSMErrorCh(f) <- err:
+			case f.peer.errorCh[f.dir] <- err:
 				t = newStateTransition(t.to, desired)
 			}
 		} else {
@@ -203,45 +222,157 @@ func newStateTransition(from fsmState, to fsmState) stateTransition {
 }
 
 type dialResult struct {
-	conn net.Conn
-	err  error
+	conn   net.Conn
+	md5Key string
+	err    error
 }
 
-func (f *fsm) dialPeer() {
+// startConnectRetryTimerAndDial restarts the ConnectRetryTimer with a jittered
+// initial value and initiates a TCP connection to the peer, see dialPeer.
+func (f *fsm) startConnectRetryTimerAndDial() {
+	retry := f.peer.options().jitteredConnectRetryTime()
+	f.connectRetryTimer = f.peer.options().clock.NewTimer(retry)
+	f.dialPeer(time.Now().Add(retry))
+}
+
+// dialPeer initiates a TCP connection to the peer, sending the result on
+// f.dialResultCh. deadline is the expiry of the ConnectRetryTimer, by which
+// connection attempts across all of the peer's transports should complete.
+func (f *fsm) dialPeer(deadline time.Time) {
 	ctx, cancel := context.WithCancel(context.Background())
 	dialResultCh := make(chan *dialResult)
 	f.dialResultCh = dialResultCh
 	f.cancelDialFn = cancel
 	go func() {
 		defer close(f.dialResultCh)
-		var (
-			laddr net.Addr
-			err   error
-		)
-		if f.peer.options.localAddress.IsValid() {
-			laddr, err = net.ResolveTCPAddr("tcp",
-				net.JoinHostPort(f.peer.options.localAddress.String(), "0"))
-			if err != nil {
+		transports := f.peer.transports()
+		if delay := f.peer.options().connectRacingDelay; delay > 0 &&
+			len(transports) > 1 {
+			f.raceTransports(ctx, transports, delay, deadline)
+			return
+		}
+		var err error
+		for i, t := range transports {
+			attemptCtx, attemptCancel := ctx, context.CancelFunc(func() {})
+			if len(transports) > 1 {
+				// share the time remaining until the ConnectRetryTimer
+				// expires between the remaining transports so that an
+				// unresponsive transport does not prevent falling back
+				attemptCtx, attemptCancel = context.WithDeadline(ctx,
+					attemptDeadline(deadline, time.Now(), len(transports)-i))
+			}
+			var conn net.Conn
+			md5Key := f.dialMD5Key()
+			conn, err = f.dialTransport(attemptCtx, t, md5Key)
+			attemptCancel()
+			if err == nil {
 				dialResultCh <- &dialResult{
-					conn: nil,
-					err:  err,
+					conn:   conn,
+					md5Key: md5Key,
+					err:    nil,
 				}
+				return
+			}
+			if ctx.Err() != nil {
+				break
 			}
 		}
-		dialer := &net.Dialer{
-			LocalAddr: laddr,
-			Control:   f.peer.options.dialerControlFn,
-		}
-		conn, err := dialer.DialContext(ctx, "tcp",
-			net.JoinHostPort(f.peer.config.RemoteAddress.String(),
-				strconv.Itoa(f.peer.options.port)))
 		dialResultCh <- &dialResult{
-			conn: conn,
+			conn: nil,
 			err:  err,
 		}
 	}()
 }
 
+// attemptDeadline returns the deadline of the next of remaining sequential
+// connection attempts, which is given an even share of the time from now until
+// deadline.
+func attemptDeadline(deadline, now time.Time, remaining int) time.Time {
+	return now.Add(deadline.Sub(now) / time.Duration(remaining))
+}
+
+// raceTransports races connection attempts across transports, see
+// WithConnectRacing, sending the result on f.dialResultCh. Attempts are
+// canceled at deadline.
+func (f *fsm) raceTransports(ctx context.Context, transports []Transport,
+	delay time.Duration, deadline time.Time) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	md5Key := f.dialMD5Key()
+	conn, err := raceDial(ctx, len(transports), delay,
+		func(ctx context.Context, i int) (net.Conn, error) {
+			return f.dialTransport(ctx, transports[i], md5Key)
+		})
+	if err != nil {
+		md5Key = ""
+	}
+	f.dialResultCh <- &dialResult{
+		conn:   conn,
+		md5Key: md5Key,
+		err:    err,
+	}
+}
+
+// dialMD5Key returns the TCP MD5 key for the next outbound connection attempt,
+// see nextDialMD5Key. It is empty if a DialFunc is in use, as keys are only set
+// by the default dialer.
+func (f *fsm) dialMD5Key() string {
+	if f.peer.options().dialFn != nil {
+		return ""
+	}
+	return f.peer.nextDialMD5Key()
+}
+
+func (f *fsm) dialTransport(ctx context.Context, t Transport,
+	md5Key string) (net.Conn, error) {
+	address := net.JoinHostPort(t.RemoteAddress.String(),
+		strconv.Itoa(f.peer.options().port))
+	tcpOpts := f.peer.options().tcpOptions
+	if f.peer.options().dialFn != nil {
+		conn, err := f.peer.options().dialFn(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		err = tcpOpts.applyToConn(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	var laddr net.Addr
+	if t.LocalAddress.IsValid() {
+		var err error
+		laddr, err = net.ResolveTCPAddr("tcp",
+			net.JoinHostPort(t.LocalAddress.String(), "0"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	control := tcpOpts.dialerControl(f.peer.options().dialerControlFn)
+	if len(md5Key) > 0 {
+		control = md5DialerControl(control, t.RemoteAddress, md5Key)
+	}
+	dialer := &net.Dialer{
+		LocalAddr: laddr,
+		Control:   control,
+	}
+	if tcpOpts.keepAlive {
+		// keepalive is managed via socket options
+		dialer.KeepAlive = -1
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	err = tcpOpts.applyToConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 // https://tools.ietf.org/html/rfc4271#section-8.2.2
 func (f *fsm) idle() fsmState {
 	/*
@@ -264,10 +395,9 @@ func (f *fsm) idle() fsmState {
 	select {
 	case <-f.closeCh:
 		return disabledState
-	case <-f.idleHoldTimer.C:
-		f.connectRetryTimer = time.NewTimer(f.peer.options.connectRetryTime)
-		f.dialPeer()
-		f.idleHoldTimer.Reset(f.peer.options.idleHoldTime)
+	case <-f.idleHoldTimer.C():
+		f.startConnectRetryTimerAndDial()
+		f.idleHoldTimer.Reset(f.peer.options().idleHoldTime)
 		return connectState
 	}
 }
@@ -279,8 +409,9 @@ const (
 )
 
 func (f *fsm) sendOpenAndSetHoldTimer() fsmState {
-	capabilities := f.peer.plugin.GetCapabilities(f.peer.config)
-	o, err := newOpenMessage(f.peer.config.LocalAS, f.peer.options.holdTime,
+	capabilities := pluginGetCapabilities(f.peer.ctx, f.peer.plugin,
+		f.peer.config)
+	o, err := newOpenMessage(f.peer.config.LocalAS, f.peer.options().holdTime,
 		f.peer.id, capabilities)
 	if err != nil {
 		f.conn.Close()
@@ -291,12 +422,13 @@ func (f *fsm) sendOpenAndSetHoldTimer() fsmState {
 		f.conn.Close()
 		return idleState
 	}
-	_, err = f.conn.Write(b)
+	f.localCaps = o.getCapabilities()
+	err = f.write(b)
 	if err != nil {
 		f.conn.Close()
 		return idleState
 	}
-	f.holdTimer = time.NewTimer(longHoldTime)
+	f.holdTimer = f.peer.options().clock.NewTimer(longHoldTime)
 	f.startReading()
 	return openSentState
 }
@@ -348,9 +480,10 @@ func (f *fsm) connect() fsmState {
 				A HoldTimer value of 4 minutes is suggested.
 			*/
 			f.conn = dr.conn
+			f.md5Key = dr.md5Key
 			f.connectRetryTimer.Stop()
 			return f.sendOpenAndSetHoldTimer()
-		case <-f.connectRetryTimer.C:
+		case <-f.connectRetryTimer.C():
 			/*
 				https://tools.ietf.org/html/rfc4271#page-55
 				In response to the ConnectRetryTimer_Expires event (Event 9), the
@@ -367,13 +500,13 @@ func (f *fsm) connect() fsmState {
 			f.cancelDialFn()
 			dr := <-f.dialResultCh
 			if dr.err != nil {
-				f.connectRetryTimer = time.NewTimer(f.peer.options.connectRetryTime)
-				f.dialPeer()
+				f.startConnectRetryTimerAndDial()
 				continue
 			}
 			// if dr.err == nil we ended up with an established connection
 			// during the race between connectRetryTimer and the dialer
 			f.conn = dr.conn
+			f.md5Key = dr.md5Key
 			return f.sendOpenAndSetHoldTimer()
 		}
 	}
@@ -400,9 +533,8 @@ func (f *fsm) active() fsmState {
 			- changes its state to Connect.
 	*/
 	select {
-	case <-f.connectRetryTimer.C:
-		f.connectRetryTimer = time.NewTimer(f.peer.options.connectRetryTime)
-		f.dialPeer()
+	case <-f.connectRetryTimer.C():
+		f.startConnectRetryTimerAndDial()
 		return connectState
 	case <-f.closeCh:
 		return disabledState
@@ -419,6 +551,7 @@ func (f *fsm) startReading() {
 	f.readerDoneCh = make(chan struct{})
 	f.readerErrCh = make(chan error)
 	f.readerMsgCh = make(chan message)
+	f.progress = &connProgress{}
 	go f.read()
 }
 
@@ -441,9 +574,37 @@ func (f *fsm) cleanupConnAndReader() {
 func (f *fsm) read() {
 	defer close(f.readerDoneCh)
 
+	clock := f.peer.options().clock
+	limiter := newUpdateRateLimiter(f.peer.options().updateRateLimit,
+		clock.Now())
+	extended := hasCapabilityCode(f.localCaps, CAP_EXTENDED_MESSSAGE)
 	for {
-		header := make([]byte, headerLength)
-		_, err := io.ReadFull(f.conn, header)
+		var (
+			m   message
+			err error
+		)
+		o := f.peer.options()
+		lengths := o.messageLimits.lengthLimits(extended)
+		interceptor := f.peer.traceInterceptor("recv", o.inboundInterceptor)
+		f.progress.readStarted(clock.Now())
+		if interceptor != nil || o.decodeErrorObserver != nil ||
+			o.decodeErrorData {
+			m, err = readInspectedMessage(f.conn, lengths, f.peer.config,
+				interceptor, o.decodeErrorObserver, o.decodeErrorData)
+		} else {
+			m, err = readMessage(f.conn, lengths, o.pooledUpdates)
+		}
+		f.progress.readDone()
+		if u, ok := m.(updateMessage); ok && err == nil {
+			var rx addPathRx
+			if p := f.addPathRx.Load(); p != nil {
+				rx = *p
+			}
+			err = checkUpdateLimits(u, o.messageLimits, rx)
+			if err != nil && o.pooledUpdates {
+				ReleaseUpdateBuffer(u)
+			}
+		}
 		if err != nil {
 			select {
 			case <-f.closeReaderCh:
@@ -452,61 +613,111 @@ func (f *fsm) read() {
 				return
 			}
 		}
-
-		for i := 0; i < 16; i++ {
-			if header[i] != 0xFF {
-				n := newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
-					NOTIF_SUBCODE_CONN_NOT_SYNCHRONIZED, nil)
-				select {
-				case <-f.closeReaderCh:
-					return
-				case f.readerErrCh <- newNotificationError(n, true):
-					return
-				}
+		if u, ok := m.(updateMessage); ok && limiter != nil {
+			if !f.limitUpdate(limiter, clock, len(u)) {
+				return
 			}
 		}
+		select {
+		case <-f.closeReaderCh:
+			return
+		case f.readerMsgCh <- m:
+		}
+	}
+}
 
-		// length is inclusive of header
-		bodyLen := int(binary.BigEndian.Uint16(header[16:18])) - headerLength
-		if bodyLen < 0 || bodyLen+headerLength > maxMessageLength {
-			n := newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
-				NOTIF_SUBCODE_BAD_MESSAGE_LEN, nil)
-			select {
-			case <-f.closeReaderCh:
-				return
-			case f.readerErrCh <- newNotificationError(n, true):
-				return
-			}
+// limitUpdate applies limiter to an UPDATE message of length n. It returns
+// false if the reader was closed while the message was delayed.
+func (f *fsm) limitUpdate(limiter *updateRateLimiter, clock Clock, n int) bool {
+	wait, exceeded := limiter.wait(clock.Now(), n)
+	if exceeded {
+		f.peer.stats.updatesRateLimited.Add(1)
+		if !limiter.exceeded {
+			limiter.exceeded = true
+			f.peer.logf("update rate limit exceeded")
 		}
+	}
+	if wait == 0 {
+		return true
+	}
+	t := clock.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-f.closeReaderCh:
+		return false
+	case <-t.C():
+		return true
+	}
+}
 
-		body := make([]byte, bodyLen)
-		if bodyLen > 0 {
-			_, err = io.ReadFull(f.conn, body)
-			if err != nil {
-				select {
-				case <-f.closeReaderCh:
-					return
-				case f.readerErrCh <- err:
-					return
-				}
-			}
+// readMessage reads a message from r, which may not exceed lengths. If pooled
+// is true the body of an UPDATE message is read into a pooled buffer, see
+// WithPooledUpdateBuffers.
+func readMessage(r io.Reader, lengths lengthLimits, pooled bool) (message,
+	error) {
+	header := make([]byte, headerLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	bodyLen, err := validateHeader(header, lengths)
+	if err != nil {
+		return nil, err
+	}
+
+	if pooled && header[18] == updateMessageType {
+		body := getUpdateBuffer(bodyLen)
+		_, err = io.ReadFull(r, body)
+		if err != nil {
+			ReleaseUpdateBuffer(body)
+			return nil, err
 		}
+		return updateMessage(body), nil
+	}
 
-		m, err := messageFromBytes(body, header[18])
+	body := make([]byte, bodyLen)
+	if bodyLen > 0 {
+		_, err = io.ReadFull(r, body)
 		if err != nil {
-			select {
-			case <-f.closeReaderCh:
-				return
-			case f.readerErrCh <- err:
-				return
-			}
+			return nil, err
 		}
-		select {
-		case <-f.closeReaderCh:
-			return
-		case f.readerMsgCh <- m:
+	}
+
+	return messageFromBytes(body, header[18])
+}
+
+// validateHeader validates the marker and length of a message header against
+// lengths, returning the length of the message body.
+func validateHeader(header []byte, lengths lengthLimits) (int, error) {
+	for i := 0; i < 16; i++ {
+		if header[i] != 0xFF {
+			n := newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
+				NOTIF_SUBCODE_CONN_NOT_SYNCHRONIZED, nil)
+			return 0, newNotificationError(n, true)
 		}
 	}
+
+	// length is inclusive of header
+	bodyLen := int(binary.BigEndian.Uint16(header[16:18])) - headerLength
+	if bodyLen < 0 || bodyLen+headerLength > lengths.forType(header[18]) {
+		// https://www.rfc-editor.org/rfc/rfc4271#section-6.1
+		// The Data field MUST contain the erroneous Length field.
+		n := newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
+			NOTIF_SUBCODE_BAD_MESSAGE_LEN, append([]byte{}, header[16:18]...))
+		return 0, newNotificationError(n, true)
+	}
+	return bodyLen, nil
+}
+
+// write writes b to the connection, applying the peer's outbound
+// MessageInterceptor if set.
+func (f *fsm) write(b []byte) error {
+	o := f.peer.options()
+	f.progress.writeStarted()
+	defer f.progress.writeDone()
+	return writeIntercepted(f.conn, b,
+		sessionSendHoldTime(o, f.holdTime), f.peer.config,
+		f.peer.traceInterceptor("send", o.outboundInterceptor))
 }
 
 func (f *fsm) sendNotification(n *Notification) error {
@@ -514,8 +725,7 @@ func (f *fsm) sendNotification(n *Notification) error {
 	if err != nil {
 		return err
 	}
-	_, err = f.conn.Write(b)
-	return err
+	return f.write(b)
 }
 
 func (f *fsm) sendKeepAlive() error {
@@ -524,17 +734,29 @@ func (f *fsm) sendKeepAlive() error {
 	if err != nil {
 		return err
 	}
-	_, err = f.conn.Write(b)
-	return err
+	return f.write(b)
 }
 
 func (f *fsm) drainAndResetHoldTimer() {
 	if !f.holdTimer.Stop() {
-		<-f.holdTimer.C
+		<-f.holdTimer.C()
 	}
 	f.holdTimer.Reset(f.holdTime)
 }
 
+// keepalivesEnabled returns true if KEEPALIVE messages are sent
+// automatically, i.e. the negotiated hold time is non-zero and keepalives are
+// not suppressed, see WithKeepaliveSuppression.
+func (f *fsm) keepalivesEnabled() bool {
+	return f.holdTime != 0 && !f.peer.options().suppressKeepalives
+}
+
+// keepAliveTimerInterval returns the jittered initial value of the
+// KeepaliveTimer.
+func (f *fsm) keepAliveTimerInterval() time.Duration {
+	return jitter(f.keepAliveInterval, f.peer.options().jitterMin)
+}
+
 // handleNotificationInErr checks if the error unwraps to a notificationError.
 // If a notificationError is found and its out field is true, the Notification
 // is sent to the peer and the function returns true, otherwise it returns
@@ -556,7 +778,7 @@ func (f *fsm) openSent() (fsmState, error) {
 			n := newNotification(NOTIF_CODE_CEASE, 0, nil)
 			f.sendNotification(n) // nolint: errcheck
 			return disabledState, newNotificationError(n, true)
-		case <-f.holdTimer.C:
+		case <-f.holdTimer.C():
 			/*
 				https://tools.ietf.org/html/rfc4271#page-64
 				If the HoldTimer_Expires (Event 10), the local system:
@@ -595,7 +817,7 @@ func (f *fsm) openSent() (fsmState, error) {
 				   - changes its state to Active.
 
 			*/
-			f.connectRetryTimer = time.NewTimer(f.peer.options.connectRetryTime)
+			f.connectRetryTimer = f.peer.options().clock.NewTimer(f.peer.options().jitteredConnectRetryTime())
 			return activeState, fmt.Errorf("reader error: %w", err)
 		case m := <-f.readerMsgCh:
 			switch m := m.(type) {
@@ -618,36 +840,66 @@ func (f *fsm) openSent() (fsmState, error) {
 				*/
 				err := m.validate(f.peer.id, f.peer.config.LocalAS,
 					f.peer.config.RemoteAS)
+				if err == nil {
+					err = f.peer.validateRemoteRouterID(m.bgpID)
+				}
 				if err != nil {
+					observeDecodeError(f.peer.config,
+						f.peer.options().decodeErrorObserver,
+						prependHeader(m.raw, openMessageType), err)
 					f.handleNotificationInErr(err)
 					return idleState, fmt.Errorf("error validating open message: %w", err)
 				}
 				f.remoteID = m.bgpID
-				var ridA [4]byte
-				binary.BigEndian.PutUint32(ridA[:], m.bgpID)
-				rid := netip.AddrFrom4(ridA)
-				n := f.peer.plugin.OnOpenMessage(f.peer.config, rid, m.getCapabilities())
+				f.remoteCaps = m.getCapabilities()
+				if f.peer.options().capabilityPolicy != nil {
+					n := f.peer.options().capabilityPolicy(f.peer.config,
+						f.localCaps, f.remoteCaps)
+					if n != nil {
+						f.sendNotification(n) // nolint: errcheck
+						return idleState, newNotificationError(n, true)
+					}
+				}
+				n := pluginOnOpenMessage(f.peer.ctx, f.peer.plugin,
+					f.peer.config, addrFromRouterID(m.bgpID), f.remoteCaps)
 				if n != nil {
 					f.sendNotification(n) // nolint: errcheck
 					return idleState, newNotificationError(n, true)
 				}
 
+				rx := newAddPathRx(f.localCaps, f.remoteCaps)
+				f.addPathRx.Store(&rx)
+
 				err = f.sendKeepAlive()
 				if err != nil {
 					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
 
 				f.holdTime = time.Duration(m.holdTime) * time.Second
-				if f.peer.options.holdTime < f.holdTime {
-					f.holdTime = f.peer.options.holdTime
+				if f.peer.options().holdTime < f.holdTime {
+					f.holdTime = f.peer.options().holdTime
 				}
 				if f.holdTime != 0 {
 					// https://tools.ietf.org/html/rfc4271#section-4.4
 					// A reasonable maximum time between KEEPALIVE messages would be one
 					// third of the Hold Time interval.
 					f.keepAliveInterval = f.holdTime / 3
-					f.keepAliveTimer = time.NewTimer(f.keepAliveInterval)
 					f.drainAndResetHoldTimer()
+				} else {
+					// https://tools.ietf.org/html/rfc4271#section-4.2
+					// If the value of this field is zero, then the Hold Time is
+					// zero, and KEEPALIVE messages MUST NOT be sent.
+					f.holdTimer.Stop()
+					err = f.peer.options().tcpOptions.
+						enableZeroHoldTimeKeepAlive(f.conn)
+					if err != nil {
+						f.peer.logf("error enabling tcp keepalive: %v", err)
+					}
+				}
+				if f.keepalivesEnabled() {
+					f.keepAliveTimer = f.peer.options().clock.NewTimer(f.keepAliveTimerInterval())
+				} else {
+					f.keepAliveTimer = newStoppedTimer(f.peer.options().clock)
 				}
 
 				return openConfirmState, nil
@@ -701,16 +953,16 @@ func (f *fsm) openConfirm() (fsmState, error) {
 				n := newNotification(NOTIF_CODE_CEASE, 0, nil)
 				f.sendNotification(n) // nolint: errcheck
 				return disabledState, newNotificationError(n, true)
-			case <-f.holdTimer.C:
+			case <-f.holdTimer.C():
 				n := newNotification(NOTIF_CODE_HOLD_TIMER_EXPIRED, 0, nil)
 				f.sendNotification(n) // nolint: errcheck
 				return idleState, newNotificationError(n, true)
-			case <-f.keepAliveTimer.C:
+			case <-f.keepAliveTimer.C():
 				err := f.sendKeepAlive()
 				if err != nil {
 					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
-				f.keepAliveTimer.Reset(f.keepAliveInterval)
+				f.keepAliveTimer.Reset(f.keepAliveTimerInterval())
 				continue
 			case err := <-f.readerErrCh:
 				// In OpenConfirm handling of a TCP connection fails event or
@@ -728,7 +980,9 @@ func (f *fsm) openConfirm() (fsmState, error) {
 							- restarts the HoldTimer and
 							- changes its state to Established.
 					*/
-					f.drainAndResetHoldTimer()
+					if f.holdTime != 0 {
+						f.drainAndResetHoldTimer()
+					}
 					return establishedState, nil
 				case *Notification:
 					return idleState, newNotificationError(m, false)
@@ -775,32 +1029,171 @@ func (f *fsm) openConfirm() (fsmState, error) {
 	return to, err
 }
 
+var _ SessionWriter = (*updateMessageWriter)(nil)
+
 type updateMessageWriter struct {
 	conn           net.Conn
+	session        SessionInfo
 	resetKATimerCh chan struct{}
+	notifCloseCh   chan *Notification
 	closeCh        chan struct{}
+
+	sendHoldExpiredCh chan error
+	progress          *connProgress
+
+	peer        PeerConfig
+	interceptor MessageInterceptor
+	connInfo    ConnInfo
+	policy      Policy
+	// p is the peer of the session, its send hold time is read for each write
+	// so that it may be updated in place
+	p *peer
+
+	// mu protects buf, which holds update messages pending a write. Update
+	// messages are buffered until bufSize is reached or Flush is called.
+	mu      sync.Mutex
+	buf     []byte
+	bufSize int
+}
+
+func (u *updateMessageWriter) WriteNotification(n *Notification,
+	closeSession bool) error {
+	select {
+	case <-u.closeCh:
+		return io.ErrClosedPipe
+	default:
+	}
+	if closeSession {
+		return u.closeWithNotification(n)
+	}
+	b, err := n.encode()
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// write any buffered update messages first to preserve ordering
+	if len(u.buf) > 0 {
+		u.buf = append(u.buf, b...)
+		return u.flushLocked()
+	}
+	return u.write(b)
+}
+
+// closeWithNotification writes any buffered update messages and then signals
+// the FSM to send n and tear down the session, so that n follows the update
+// messages on the wire. The FSM is signaled even if the write fails.
+func (u *updateMessageWriter) closeWithNotification(n *Notification) error {
+	u.mu.Lock()
+	err := u.flushLocked()
+	u.mu.Unlock()
+	// notifCloseCh is buffered so that WriteNotification may be called from an
+	// UpdateMessageHandler. If it's full the session is already being torn
+	// down.
+	select {
+	case u.notifCloseCh <- n:
+	default:
+	}
+	return err
+}
+
+// write writes b to the connection, signaling the FSM to tear down the session
+// if the send hold timer expires.
+func (u *updateMessageWriter) write(b []byte) error {
+	u.progress.writeStarted()
+	sendHoldTime := sessionSendHoldTime(u.p.options(), u.session.HoldTime)
+	err := writeIntercepted(u.conn, b, sendHoldTime, u.peer,
+		u.p.traceInterceptor("send", u.interceptor))
+	u.progress.writeDone()
+	var nerr *notificationError
+	if errors.As(err, &nerr) {
+		select {
+		case u.sendHoldExpiredCh <- err:
+		default:
+		}
+	}
+	return err
+}
+
+func (u *updateMessageWriter) SessionInfo() SessionInfo {
+	return u.session
 }
 
 func (u *updateMessageWriter) WriteUpdate(b []byte) error {
-	/*
-		https://tools.ietf.org/html/rfc4271#page-72
-		Each time the local system sends a KEEPALIVE or UPDATE message, it
-		restarts its KeepaliveTimer, unless the negotiated HoldTime value
-		is zero.
-	*/
+	return u.WriteUpdates([][]byte{b})
+}
+
+func (u *updateMessageWriter) WriteUpdates(updates [][]byte) error {
 	select {
 	case <-u.closeCh:
 		return io.ErrClosedPipe
 	default:
-		_, err := u.conn.Write(prependHeader(b, updateMessageType))
-		if err == nil {
-			select {
-			case <-u.closeCh:
-			case u.resetKATimerCh <- struct{}{}:
+	}
+	if len(u.policy) > 0 {
+		applied := make([][]byte, 0, len(updates))
+		for _, b := range updates {
+			b, err := u.policy.applyUpdate(b)
+			if err != nil {
+				return fmt.Errorf("error applying outbound policy: %w", err)
 			}
+			applied = append(applied, b)
 		}
-		return err
+		updates = applied
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, b := range updates {
+		u.buf = appendMessage(u.buf, b, updateMessageType)
+	}
+	if len(u.buf) < u.bufSize {
+		return nil
+	}
+	return u.flushLocked()
+}
+
+func (u *updateMessageWriter) Flush() error {
+	select {
+	case <-u.closeCh:
+		return io.ErrClosedPipe
+	default:
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.flushLocked()
+}
+
+// flushLocked writes the buffered messages. It must be called with u.mu held.
+func (u *updateMessageWriter) flushLocked() error {
+	if len(u.buf) == 0 {
+		return nil
 	}
+	err := u.write(u.buf)
+	u.buf = u.buf[:0]
+	if err == nil {
+		/*
+			https://tools.ietf.org/html/rfc4271#page-72
+			Each time the local system sends a KEEPALIVE or UPDATE message, it
+			restarts its KeepaliveTimer, unless the negotiated HoldTime value
+			is zero.
+		*/
+		select {
+		case <-u.closeCh:
+		case u.resetKATimerCh <- struct{}{}:
+		}
+	}
+	return err
+}
+
+// ignoreUpdateErr returns true if the Notification returned by an
+// UpdateMessageHandler should be ignored per the peer's UpdateErrorPolicy.
+func (f *fsm) ignoreUpdateErr(n *Notification) bool {
+	if f.peer.options().updateErrPolicy != UpdateErrorPolicyIgnore ||
+		n.Code != NOTIF_CODE_UPDATE_MESSAGE_ERR {
+		return false
+	}
+	f.peer.stats.updateErrorsIgnored.Add(1)
+	f.peer.logf("ignoring update handler error: %v", n)
+	return true
 }
 
 // https://tools.ietf.org/html/rfc4271#page-71
@@ -820,24 +1213,133 @@ func (f *fsm) established() (fsmState, error) {
 			case <-closeKAManagerCh:
 				return
 			case <-resetKATimerCh:
-				if f.holdTime != 0 {
-					f.keepAliveTimer.Reset(f.keepAliveInterval)
+				if f.keepalivesEnabled() {
+					f.keepAliveTimer.Reset(f.keepAliveTimerInterval())
 				}
 			}
 		}
 	}()
 
+	session := newSessionInfo(f.holdTime, f.peer.id, f.remoteID, f.localCaps,
+		f.remoteCaps)
+	session.LocalAddress, _ = netip.ParseAddrPort(f.conn.LocalAddr().String())
+	session.RemoteAddress, _ = netip.ParseAddrPort(f.conn.RemoteAddr().String())
+	if f.keepalivesEnabled() {
+		session.KeepaliveInterval = f.keepAliveInterval
+	}
+
 	established := func() (fsmState, error) {
 		writer := &updateMessageWriter{
 			conn:           f.conn,
+			session:        session,
 			resetKATimerCh: resetKATimerCh,
+			notifCloseCh:   make(chan *Notification, 1),
 			closeCh:        make(chan struct{}),
+
+			sendHoldExpiredCh: make(chan error, 1),
+			progress:          f.progress,
+
+			peer:        f.peer.config,
+			interceptor: f.peer.options().outboundInterceptor,
+			p:           f.peer,
+			policy:      f.peer.options().outboundPolicy,
+
+			bufSize: f.peer.options().updateWriteBufSize,
 		}
+		writer.connInfo = newConnInfo(f.conn, f.dir == in, len(f.md5Key) > 0)
+		f.peer.setSession(&session, writer)
+		f.peer.recordSessionUp()
+		f.peer.md5Established(f.md5Key)
+		ctx, cancel := context.WithCancel(f.peer.ctx)
+		var dispatchWG sync.WaitGroup
 		defer func() {
+			cancel()
 			close(closeKAManagerCh)
 			close(writer.closeCh)
+			// wait for any in-flight dispatched UPDATE messages
+			dispatchWG.Wait()
 		}()
-		handler := f.peer.plugin.OnEstablished(f.peer.config, writer)
+		handler := pluginOnEstablished(ctx, f.peer.plugin, f.peer.config,
+			writer)
+		eor := newEndOfRIBTracker(session.Families)
+		if b := f.peer.options().replayBuffer; b != nil {
+			b.sessionUp(f.peer.config.RemoteAddress)
+		}
+
+		// handleUpdate handles an UPDATE message, returning a non-nil
+		// Notification if the session should be closed.
+		handleUpdate := func(m updateMessage) *Notification {
+			// prefixes are counted, and the PrefixWatcher, ReplayBuffer, and
+			// End-of-RIB marker are checked first as m is owned by handler
+			// when pooled buffers are in use
+			var (
+				eorFamily  MPExtensions
+				eorPending []MPExtensions
+				isEOR      bool
+			)
+			m, n := f.handleFamilyMismatch(m, session.Families)
+			if n != nil {
+				return n
+			}
+			if m == nil {
+				return nil
+			}
+			var rx addPathRx
+			if p := f.addPathRx.Load(); p != nil {
+				rx = *p
+			}
+			if f.peer.options().prefixCounting {
+				countUpdatePrefixes(&f.peer.stats, m, rx)
+			}
+			if w := f.peer.options().prefixWatcher; w != nil {
+				w.handleUpdate(f.peer.config, m, rx)
+			}
+			if b := f.peer.options().replayBuffer; b != nil {
+				b.handleUpdate(f.peer.config.RemoteAddress, m)
+			}
+			eorFn := f.peer.options().eorObserver
+			if eorFn != nil {
+				eorFamily, eorPending, isEOR = eor.handleUpdate(m)
+			}
+			if handler != nil {
+				// a pooled buffer may be released by handler, in which case
+				// it can no longer be observed. Pooled buffers are not used
+				// while a DecodeErrorObserver is set, but it may have been
+				// set via UpdatePeer since m was read.
+				_, pooled := pooledBuffer(m)
+				n := handler(f.peer.config, m)
+				if n != nil && n != UpdateConsumed {
+					reset := !f.ignoreUpdateErr(n)
+					if !pooled {
+						f.observeUpdateError(m, n, reset)
+					}
+					if reset {
+						return n
+					}
+				}
+			} else if f.peer.options().pooledUpdates {
+				ReleaseUpdateBuffer(m)
+			}
+			if isEOR {
+				eorFn(f.peer.config, eorFamily, eorPending)
+			}
+			return nil
+		}
+
+		var livenessDownCh <-chan struct{}
+		if lm := f.peer.options().livenessMonitor; lm != nil {
+			var stop func()
+			livenessDownCh, stop = lm.Monitor(f.peer.config)
+			defer stop()
+		}
+
+		var stuckCh chan error
+		if s := f.peer.options().stuckPeerDetection; s.enabled() {
+			stuckCh = make(chan error)
+			detector := &stuckPeerDetector{config: s, progress: f.progress}
+			go detector.run(f.peer.options().clock, f.conn, stuckCh,
+				writer.closeCh)
+		}
 
 		for {
 			select {
@@ -845,16 +1347,33 @@ func (f *fsm) established() (fsmState, error) {
 				n := newNotification(NOTIF_CODE_CEASE, 0, nil)
 				f.sendNotification(n) // nolint: errcheck
 				return disabledState, newNotificationError(n, true)
-			case <-f.holdTimer.C:
+			case <-f.holdTimer.C():
 				n := newNotification(NOTIF_CODE_HOLD_TIMER_EXPIRED, 0, nil)
 				f.sendNotification(n) // nolint: errcheck
 				return idleState, newNotificationError(n, true)
-			case <-f.keepAliveTimer.C:
+			case <-livenessDownCh:
+				n := newNotification(NOTIF_CODE_CEASE, NOTIF_SUBCODE_BFD_DOWN, nil)
+				f.sendNotification(n) // nolint: errcheck
+				return idleState, newNotificationError(n, true)
+			case <-f.keepAliveTimer.C():
 				err := f.sendKeepAlive()
 				if err != nil {
 					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
 				resetKATimerCh <- struct{}{}
+			case n := <-writer.notifCloseCh:
+				f.sendNotification(n) // nolint: errcheck
+				return idleState, newNotificationError(n, true)
+			case err := <-writer.sendHoldExpiredCh:
+				return idleState, err
+			case err := <-stuckCh:
+				f.peer.logf("stuck peer detected: %v", err)
+				if errors.Is(err, ErrReadIdle) {
+					// the peer may still be reading
+					n := newNotification(NOTIF_CODE_CEASE, 0, nil)
+					f.sendNotification(n) // nolint: errcheck
+				}
+				return idleState, err
 			case err := <-f.readerErrCh:
 				f.handleNotificationInErr(err)
 				return idleState, fmt.Errorf("error from reader: %w", err)
@@ -899,12 +1418,30 @@ func (f *fsm) established() (fsmState, error) {
 							  non-zero, and
 							- remains in the Established state.
 					*/
-					if handler != nil {
-						n := handler(f.peer.config, m)
-						if n != nil {
-							f.sendNotification(n) // nolint: errcheck
-							return idleState, newNotificationError(n, true)
+					if d := f.peer.options().updateDispatcher; d != nil {
+						dispatchWG.Add(1)
+						dispatched := d.dispatch(f.peer.config, func() {
+							defer dispatchWG.Done()
+							select {
+							case <-writer.closeCh:
+								// the session has ended
+								if f.peer.options().pooledUpdates {
+									ReleaseUpdateBuffer(m)
+								}
+								return
+							default:
+							}
+							n := handleUpdate(m)
+							if n != nil {
+								writer.closeWithNotification(n) // nolint: errcheck
+							}
+						}, f.closeCh)
+						if !dispatched {
+							dispatchWG.Done()
 						}
+					} else if n := handleUpdate(m); n != nil {
+						f.sendNotification(n) // nolint: errcheck
+						return idleState, newNotificationError(n, true)
 					}
 					if f.holdTime != 0 {
 						f.drainAndResetHoldTimer()
@@ -949,6 +1486,8 @@ func (f *fsm) established() (fsmState, error) {
 	f.cleanupConnAndReader()
 	f.holdTimer.Stop()
 	f.keepAliveTimer.Stop()
+	f.peer.setSession(nil, nil)
+	f.peer.recordSessionDown(err)
 	f.peer.plugin.OnClose(f.peer.config)
 	return to, err
 }
@@ -0,0 +1,283 @@
+// Package fuzz provides native Go fuzz targets for corebgp's message decoders,
+// allowing downstream users to continuously fuzz them, along with seed
+// corpora derived from MRT data.
+//
+// The targets are passed to (*testing.F).Fuzz from a fuzz test:
+//
+//	func FuzzUpdateAttributes(f *testing.F) {
+//		file, _ := os.Open("testdata/updates.mrt")
+//		corpus, _ := fuzz.CorpusFromMRT(file)
+//		for _, seed := range corpus.Update {
+//			f.Add(seed)
+//		}
+//		f.Fuzz(fuzz.UpdateAttributes)
+//	}
+package fuzz
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/mrt"
+)
+
+// OpenCapabilities is a fuzz target for decoding the capabilities of an OPEN
+// message body.
+func OpenCapabilities(t *testing.T, b []byte) {
+	caps, err := corebgp.DecodeOpenCapabilities(b)
+	if err != nil {
+		return
+	}
+	for _, c := range caps {
+		switch c.Code {
+		case corebgp.CAP_MP_EXTENSIONS:
+			var m corebgp.MPExtensions
+			m.Decode(c.Value) // nolint: errcheck
+		case corebgp.CAP_GRACEFUL_RESTART:
+			var g corebgp.GracefulRestart
+			g.Decode(c.Value) // nolint: errcheck
+		case corebgp.CAP_ROLE:
+			var r corebgp.Role
+			r.Decode(c.Value) // nolint: errcheck
+		case corebgp.CAP_ADD_PATH:
+			corebgp.DecodeAddPathTuples(c.Value) // nolint: errcheck
+		case corebgp.CAP_EXTENDED_NEXT_HOP_ENCODING:
+			corebgp.DecodeExtendedNextHops(c.Value) // nolint: errcheck
+		}
+	}
+}
+
+type update struct {
+	prefixes []netip.Prefix
+}
+
+func (u *update) addPrefixes(p []netip.Prefix) error {
+	u.prefixes = append(u.prefixes, p...)
+	return nil
+}
+
+func (u *update) addAddPathPrefixes(a []corebgp.AddPathPrefix) error {
+	for _, p := range a {
+		u.prefixes = append(u.prefixes, p.Prefix)
+	}
+	return nil
+}
+
+func decodeMPPrefixes(u *update, afi uint16, b []byte) error {
+	if afi != corebgp.AFI_IPV6 {
+		return nil
+	}
+	p, err := corebgp.DecodeMPIPv6Prefixes(b)
+	if err != nil {
+		return err
+	}
+	return u.addPrefixes(p)
+}
+
+var (
+	reachDecodeFn = corebgp.NewMPReachNLRIDecodeFn[*update](
+		func(u *update, afi uint16, safi uint8, nh, nlri []byte) error {
+			if afi == corebgp.AFI_IPV6 {
+				_, err := corebgp.DecodeMPReachIPv6NextHops(nh)
+				if err != nil {
+					return err
+				}
+			}
+			return decodeMPPrefixes(u, afi, nlri)
+		})
+	unreachDecodeFn = corebgp.NewMPUnreachNLRIDecodeFn[*update](
+		func(u *update, afi uint16, safi uint8, withdrawn []byte) error {
+			return decodeMPPrefixes(u, afi, withdrawn)
+		})
+)
+
+func decodePathAttr(u *update, code uint8, flags corebgp.PathAttrFlags,
+	b []byte) error {
+	switch code {
+	case corebgp.PATH_ATTR_ORIGIN:
+		var o corebgp.OriginPathAttr
+		return o.Decode(flags, b)
+	case corebgp.PATH_ATTR_AS_PATH:
+		var a corebgp.ASPathAttr
+		err := a.Decode(flags, b)
+		if err != nil {
+			return err
+		}
+		_, err = corebgp.DecodeASPath(b)
+		return err
+	case corebgp.PATH_ATTR_NEXT_HOP:
+		var n corebgp.NextHopPathAttr
+		return n.Decode(flags, b)
+	case corebgp.PATH_ATTR_MED:
+		var m corebgp.MEDPathAttr
+		return m.Decode(flags, b)
+	case corebgp.PATH_ATTR_LOCAL_PREF:
+		var l corebgp.LocalPrefPathAttr
+		return l.Decode(flags, b)
+	case corebgp.PATH_ATTR_ATOMIC_AGGREGATE:
+		var a corebgp.AtomicAggregatePathAttr
+		return a.Decode(flags, b)
+	case corebgp.PATH_ATTR_AGGREGATOR:
+		var a corebgp.AggregatorPathAttr
+		return a.Decode(flags, b)
+	case corebgp.PATH_ATTR_COMMUNITY:
+		var c corebgp.CommunitiesPathAttr
+		return c.Decode(flags, b)
+	case corebgp.PATH_ATTR_ORIGINATOR_ID:
+		var o corebgp.OriginatorIDPathAttr
+		return o.Decode(flags, b)
+	case corebgp.PATH_ATTR_CLUSTER_LIST:
+		var c corebgp.ClusterListPathAttr
+		return c.Decode(flags, b)
+	case corebgp.PATH_ATTR_LARGE_COMMUNITY:
+		var l corebgp.LargeCommunitiesPathAttr
+		return l.Decode(flags, b)
+	case corebgp.PATH_ATTR_MP_REACH_NLRI:
+		return reachDecodeFn(u, flags, b)
+	case corebgp.PATH_ATTR_MP_UNREACH_NLRI:
+		return unreachDecodeFn(u, flags, b)
+	}
+	return nil
+}
+
+// UpdateAttributes is a fuzz target for decoding an UPDATE message body,
+// including all path attributes supported by corebgp.
+func UpdateAttributes(t *testing.T, b []byte) {
+	ud := corebgp.NewUpdateDecoder[*update](
+		corebgp.NewWithdrawnRoutesDecodeFn((*update).addPrefixes),
+		decodePathAttr,
+		corebgp.NewNLRIDecodeFn((*update).addPrefixes),
+	)
+	u := &update{}
+	err := ud.Decode(u, b)
+	if err == nil {
+		checkPrefixes(t, u.prefixes)
+	}
+	corebgp.UpdateFamilies(b) // nolint: errcheck
+}
+
+// NLRI is a fuzz target for decoding NLRI, with and without ADD-PATH, for
+// IPv4 and IPv6.
+func NLRI(t *testing.T, b []byte) {
+	fns := []corebgp.DecodeFn[*update]{
+		corebgp.NewNLRIDecodeFn((*update).addPrefixes),
+		corebgp.NewNLRIAddPathDecodeFn((*update).addAddPathPrefixes),
+		func(u *update, b []byte) error {
+			p, err := corebgp.DecodeMPIPv6Prefixes(b)
+			if err != nil {
+				return err
+			}
+			return u.addPrefixes(p)
+		},
+		func(u *update, b []byte) error {
+			a, err := corebgp.DecodeMPIPv6AddPathPrefixes(b)
+			if err != nil {
+				return err
+			}
+			return u.addAddPathPrefixes(a)
+		},
+	}
+	for _, fn := range fns {
+		u := &update{}
+		if fn(u, b) == nil {
+			checkPrefixes(t, u.prefixes)
+		}
+	}
+}
+
+func checkPrefixes(t *testing.T, prefixes []netip.Prefix) {
+	for _, p := range prefixes {
+		if !p.IsValid() {
+			t.Errorf("decoded invalid prefix: %v", p)
+		}
+	}
+}
+
+// Corpus contains seeds for the fuzz targets of this package.
+type Corpus struct {
+	// Open contains seeds for OpenCapabilities.
+	Open [][]byte
+	// Update contains seeds for UpdateAttributes.
+	Update [][]byte
+	// NLRI contains seeds for NLRI.
+	NLRI [][]byte
+}
+
+const (
+	headerLength      = 19
+	openMessageType   = 1
+	updateMessageType = 2
+)
+
+// CorpusFromMRT reads MRT records from r and returns a Corpus derived from
+// them. OPEN and UPDATE messages are extracted from BGP4MP message records.
+// RIB entries of TABLE_DUMP_V2 records are converted to UPDATE messages
+// announcing their prefix. Records of other types are ignored.
+func CorpusFromMRT(r io.Reader) (Corpus, error) {
+	var c Corpus
+	mr := mrt.NewReader(r)
+	for {
+		rec, err := mr.Next()
+		if errors.Is(err, io.EOF) {
+			return c, nil
+		}
+		if err != nil {
+			return c, err
+		}
+		switch rec.Type {
+		case mrt.TypeBGP4MP, mrt.TypeBGP4MPET:
+			msg, err := rec.BGPMessage()
+			if err != nil || len(msg) < headerLength {
+				continue
+			}
+			body := msg[headerLength:]
+			switch msg[18] {
+			case openMessageType:
+				c.Open = append(c.Open, body)
+			case updateMessageType:
+				c.Update = append(c.Update, body)
+				if nlri, ok := updateNLRI(body); ok && len(nlri) > 0 {
+					c.NLRI = append(c.NLRI, nlri)
+				}
+			}
+		case mrt.TypeTableDumpV2:
+			prefix, entries, err := rec.RIB()
+			if err != nil {
+				continue
+			}
+			nlri := append([]byte{uint8(prefix.Bits())},
+				prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+			c.NLRI = append(c.NLRI, nlri)
+			for _, e := range entries {
+				// IPv6 RIB entries carry an abbreviated MP_REACH_NLRI
+				// attribute, they are kept as they exercise error paths.
+				u := []byte{0, 0, uint8(len(e.Attributes) >> 8),
+					uint8(len(e.Attributes))}
+				u = append(u, e.Attributes...)
+				if prefix.Addr().Is4() {
+					u = append(u, nlri...)
+				}
+				c.Update = append(c.Update, u)
+			}
+		}
+	}
+}
+
+// updateNLRI returns the NLRI field of the UPDATE message body b.
+func updateNLRI(b []byte) ([]byte, bool) {
+	if len(b) < 2 {
+		return nil, false
+	}
+	wrl := int(b[0])<<8 | int(b[1])
+	if len(b) < 4+wrl {
+		return nil, false
+	}
+	tpal := int(b[2+wrl])<<8 | int(b[3+wrl])
+	if len(b) < 4+wrl+tpal {
+		return nil, false
+	}
+	return b[4+wrl+tpal:], true
+}
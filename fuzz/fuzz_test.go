@@ -0,0 +1,51 @@
+package fuzz
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadCorpus(t testing.TB) Corpus {
+	f, err := os.Open("testdata/sample.mrt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, err := CorpusFromMRT(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCorpusFromMRT(t *testing.T) {
+	c := loadCorpus(t)
+	assert.Len(t, c.Open, 1)
+	// 2 RIB entries and 2 UPDATE messages
+	assert.Len(t, c.Update, 4)
+	// 2 RIB prefixes and 1 UPDATE message with IPv4 NLRI
+	assert.Len(t, c.NLRI, 3)
+}
+
+func FuzzOpenCapabilities(f *testing.F) {
+	for _, seed := range loadCorpus(f).Open {
+		f.Add(seed)
+	}
+	f.Fuzz(OpenCapabilities)
+}
+
+func FuzzUpdateAttributes(f *testing.F) {
+	for _, seed := range loadCorpus(f).Update {
+		f.Add(seed)
+	}
+	f.Fuzz(UpdateAttributes)
+}
+
+func FuzzNLRI(f *testing.F) {
+	for _, seed := range loadCorpus(f).NLRI {
+		f.Add(seed)
+	}
+	f.Fuzz(NLRI)
+}
@@ -0,0 +1,19 @@
+module github.com/jwhited/corebgp/gobgpconv
+
+go 1.21
+
+require (
+	github.com/jwhited/corebgp v0.0.0
+	github.com/osrg/gobgp/v3 v3.26.0
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.5.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/jwhited/corebgp => ../
@@ -0,0 +1,330 @@
+// Package gobgpconv converts between corebgp's message and path attribute
+// types and the packet/bgp types of github.com/osrg/gobgp/v3. It allows code
+// bases built on gobgp to migrate to corebgp incrementally, and Plugins to
+// reuse gobgp's codecs for attributes and families that corebgp does not
+// decode.
+package gobgpconv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/jwhited/corebgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+)
+
+// PathAttr is the set of corebgp path attribute types with a gobgp
+// counterpart, see ToPathAttribute and FromPathAttribute.
+type PathAttr interface {
+	corebgp.OriginPathAttr | corebgp.ASPath | corebgp.NextHopPathAttr |
+		corebgp.MEDPathAttr | corebgp.LocalPrefPathAttr |
+		corebgp.AtomicAggregatePathAttr | corebgp.AggregatorPathAttr |
+		corebgp.CommunitiesPathAttr | corebgp.OriginatorIDPathAttr |
+		corebgp.ClusterListPathAttr | corebgp.AIGPPathAttr |
+		corebgp.LargeCommunitiesPathAttr
+}
+
+// pathAttrCode returns the code of the path attribute type of v, which must be
+// one of the PathAttr types.
+func pathAttrCode(v any) uint8 {
+	switch v.(type) {
+	case corebgp.OriginPathAttr:
+		return corebgp.PATH_ATTR_ORIGIN
+	case corebgp.ASPath:
+		return corebgp.PATH_ATTR_AS_PATH
+	case corebgp.NextHopPathAttr:
+		return corebgp.PATH_ATTR_NEXT_HOP
+	case corebgp.MEDPathAttr:
+		return corebgp.PATH_ATTR_MED
+	case corebgp.LocalPrefPathAttr:
+		return corebgp.PATH_ATTR_LOCAL_PREF
+	case corebgp.AtomicAggregatePathAttr:
+		return corebgp.PATH_ATTR_ATOMIC_AGGREGATE
+	case corebgp.AggregatorPathAttr:
+		return corebgp.PATH_ATTR_AGGREGATOR
+	case corebgp.CommunitiesPathAttr:
+		return corebgp.PATH_ATTR_COMMUNITY
+	case corebgp.OriginatorIDPathAttr:
+		return corebgp.PATH_ATTR_ORIGINATOR_ID
+	case corebgp.ClusterListPathAttr:
+		return corebgp.PATH_ATTR_CLUSTER_LIST
+	case corebgp.AIGPPathAttr:
+		return corebgp.PATH_ATTR_AIGP
+	case corebgp.LargeCommunitiesPathAttr:
+		return corebgp.PATH_ATTR_LARGE_COMMUNITY
+	}
+	panic(fmt.Sprintf("unhandled path attribute type %T", v))
+}
+
+// encodePathAttr returns the data of the path attribute v, which must be one
+// of the PathAttr types other than corebgp.ASPath.
+func encodePathAttr(v any) []byte {
+	switch v := v.(type) {
+	case corebgp.OriginPathAttr:
+		return []byte{uint8(v)}
+	case corebgp.NextHopPathAttr:
+		return netip.Addr(v).AsSlice()
+	case corebgp.MEDPathAttr:
+		return binary.BigEndian.AppendUint32(nil, uint32(v))
+	case corebgp.LocalPrefPathAttr:
+		return binary.BigEndian.AppendUint32(nil, uint32(v))
+	case corebgp.AtomicAggregatePathAttr:
+		return []byte{}
+	case corebgp.AggregatorPathAttr:
+		return v.Encode()
+	case corebgp.CommunitiesPathAttr:
+		return v.Encode()
+	case corebgp.OriginatorIDPathAttr:
+		return v.Encode()
+	case corebgp.ClusterListPathAttr:
+		return v.Encode()
+	case corebgp.AIGPPathAttr:
+		return v.Encode()
+	case corebgp.LargeCommunitiesPathAttr:
+		return v.Encode()
+	}
+	panic(fmt.Sprintf("unhandled path attribute type %T", v))
+}
+
+// ToPathAttribute returns the gobgp path attribute for v. The attribute flags
+// are the well-known flags of the attribute type. An error is returned for a
+// false corebgp.AtomicAggregatePathAttr, which has no path attribute.
+func ToPathAttribute[T PathAttr](v T) (bgp.PathAttributeInterface, error) {
+	switch v := any(v).(type) {
+	case corebgp.AtomicAggregatePathAttr:
+		if !v {
+			return nil, errors.New("false ATOMIC_AGGREGATE has no path attribute")
+		}
+	case corebgp.NextHopPathAttr:
+		if !netip.Addr(v).Is4() {
+			return nil, errors.New("NEXT_HOP must be an IPv4 address")
+		}
+	}
+	if p, ok := any(v).(corebgp.ASPath); ok {
+		// construct directly to avoid gobgp guessing the AS number length
+		params := make([]bgp.AsPathParamInterface, 0, len(p))
+		for _, seg := range p {
+			params = append(params, bgp.NewAs4PathParam(seg.Type,
+				slices.Clone(seg.ASNs)))
+		}
+		return bgp.NewPathAttributeAsPath(params), nil
+	}
+	code, b := pathAttrCode(v), encodePathAttr(v)
+	flags := bgp.PathAttrFlags[bgp.BGPAttrType(code)]
+	return DecodePathAttribute(code, corebgp.PathAttrFlags(flags), b)
+}
+
+// FromPathAttribute returns the corebgp path attribute of type T for p. An
+// error is returned if p is not of the attribute type corresponding to T, or
+// if p is malformed according to corebgp's Decode method for T.
+func FromPathAttribute[T PathAttr](p bgp.PathAttributeInterface) (T, error) {
+	var t T
+	if uint8(p.GetType()) != pathAttrCode(t) {
+		return t, fmt.Errorf("path attribute code %d does not match %T",
+			p.GetType(), t)
+	}
+	if a, ok := p.(*bgp.PathAttributeAsPath); ok {
+		// AS numbers may be two or four octets in gobgp
+		path := make(corebgp.ASPath, 0, len(a.Value))
+		for _, param := range a.Value {
+			path = append(path, corebgp.ASPathSegment{
+				Type: param.GetType(),
+				ASNs: param.GetAS(),
+			})
+		}
+		*any(&t).(*corebgp.ASPath) = path
+		return t, nil
+	}
+	if _, ok := p.(*bgp.PathAttributeAtomicAggregate); ok {
+		// the attribute has no data, its presence is its value
+		*any(&t).(*corebgp.AtomicAggregatePathAttr) = true
+		return t, nil
+	}
+	_, flags, b, err := EncodePathAttribute(p)
+	if err != nil {
+		return t, err
+	}
+	switch v := any(&t).(type) {
+	case *corebgp.OriginPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.NextHopPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.MEDPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.LocalPrefPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.AggregatorPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.CommunitiesPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.OriginatorIDPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.ClusterListPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.AIGPPathAttr:
+		err = v.Decode(flags, b)
+	case *corebgp.LargeCommunitiesPathAttr:
+		err = v.Decode(flags, b)
+	}
+	return t, err
+}
+
+// DecodePathAttribute decodes the path attribute with code, flags, and data
+// b, e.g. as passed to a corebgp.PathAttrsDecodeFn, using gobgp's codecs.
+// Attributes unknown to gobgp are returned as a *bgp.PathAttributeUnknown. b is
+// not retained.
+func DecodePathAttribute(code uint8, flags corebgp.PathAttrFlags, b []byte,
+	opts ...*bgp.MarshallingOption) (bgp.PathAttributeInterface, error) {
+	if len(b) > 0xffff {
+		return nil, errors.New("path attribute data too long")
+	}
+	raw := corebgp.AppendPathAttr(nil, flags, code, b)
+	p, err := bgp.GetPathAttribute(raw)
+	if err != nil {
+		return nil, err
+	}
+	err = p.DecodeFromBytes(raw, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// EncodePathAttribute returns the code, flags, and data of the gobgp path
+// attribute p, as accepted by corebgp.AppendPathAttr and the Decode methods of
+// corebgp's path attribute types.
+func EncodePathAttribute(p bgp.PathAttributeInterface,
+	opts ...*bgp.MarshallingOption) (uint8, corebgp.PathAttrFlags, []byte,
+	error) {
+	raw, err := p.Serialize(opts...)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(raw) < 3 {
+		return 0, 0, nil, errors.New("short path attribute")
+	}
+	flags, code := corebgp.PathAttrFlags(raw[0]), raw[1]
+	if flags.ExtendedLen() {
+		if len(raw) < 4 {
+			return 0, 0, nil, errors.New("short path attribute")
+		}
+		return code, flags, raw[4:], nil
+	}
+	return code, flags, raw[3:], nil
+}
+
+// ToPrefix returns the gobgp IPv4 or IPv6 unicast NLRI for p. The add-path ID
+// of p is set as the path identifier of the NLRI.
+func ToPrefix(p corebgp.AddPathPrefix) bgp.AddrPrefixInterface {
+	var a bgp.AddrPrefixInterface
+	if p.Prefix.Addr().Is4() {
+		a = bgp.NewIPAddrPrefix(uint8(p.Prefix.Bits()),
+			p.Prefix.Addr().String())
+	} else {
+		a = bgp.NewIPv6AddrPrefix(uint8(p.Prefix.Bits()),
+			p.Prefix.Addr().String())
+	}
+	a.SetPathIdentifier(p.ID)
+	return a
+}
+
+// FromPrefix returns the corebgp.AddPathPrefix for the gobgp IPv4 or IPv6
+// unicast NLRI a. The ID of the returned AddPathPrefix is the path identifier
+// of a, which is zero if add-path is not in use.
+func FromPrefix(a bgp.AddrPrefixInterface) (corebgp.AddPathPrefix, error) {
+	var p *bgp.IPAddrPrefix
+	switch v := a.(type) {
+	case *bgp.IPAddrPrefix:
+		p = v
+	case *bgp.IPv6AddrPrefix:
+		p = &v.IPAddrPrefix
+	default:
+		return corebgp.AddPathPrefix{}, fmt.Errorf("unsupported NLRI type %T",
+			a)
+	}
+	addr, ok := netip.AddrFromSlice(p.Prefix)
+	if !ok {
+		return corebgp.AddPathPrefix{}, errors.New("invalid prefix address")
+	}
+	if a.AFI() == bgp.AFI_IP {
+		addr = addr.Unmap()
+	}
+	prefix, err := addr.Prefix(int(p.Length))
+	if err != nil {
+		return corebgp.AddPathPrefix{}, err
+	}
+	return corebgp.AddPathPrefix{Prefix: prefix, ID: a.PathIdentifier()}, nil
+}
+
+// ToNotification returns the gobgp NOTIFICATION message for n.
+func ToNotification(n *corebgp.Notification) *bgp.BGPMessage {
+	return bgp.NewBGPNotificationMessage(n.Code, n.Subcode,
+		slices.Clone(n.Data))
+}
+
+// FromNotification returns the corebgp.Notification for the gobgp
+// NOTIFICATION message m.
+func FromNotification(m *bgp.BGPMessage) (*corebgp.Notification, error) {
+	body, ok := m.Body.(*bgp.BGPNotification)
+	if !ok {
+		return nil, fmt.Errorf("message body %T is not a NOTIFICATION", m.Body)
+	}
+	return &corebgp.Notification{
+		Code:    body.ErrorCode,
+		Subcode: body.ErrorSubcode,
+		Data:    slices.Clone(body.Data),
+	}, nil
+}
+
+// DecodeUpdate decodes the UPDATE message b, as passed to a
+// corebgp.UpdateMessageHandler, using gobgp's codecs. opts must describe the
+// add-path families negotiated for the session, if any. b is not retained, so
+// DecodeUpdate may be used with corebgp.WithPooledUpdateBuffers.
+func DecodeUpdate(b []byte, opts ...*bgp.MarshallingOption) (*bgp.BGPMessage,
+	error) {
+	if len(b) > bgp.BGP_MAX_MESSAGE_LENGTH-bgp.BGP_HEADER_LENGTH {
+		return nil, errors.New("UPDATE message too long")
+	}
+	h := &bgp.BGPHeader{
+		Len:  uint16(bgp.BGP_HEADER_LENGTH + len(b)),
+		Type: bgp.BGP_MSG_UPDATE,
+	}
+	return bgp.ParseBGPBody(h, slices.Clone(b), opts...)
+}
+
+// EncodeUpdate returns the UPDATE message m without its header, as accepted
+// by corebgp.UpdateMessageWriter.WriteUpdate. opts must describe the add-path
+// families negotiated for the session, if any.
+func EncodeUpdate(m *bgp.BGPMessage, opts ...*bgp.MarshallingOption) ([]byte,
+	error) {
+	if _, ok := m.Body.(*bgp.BGPUpdate); !ok {
+		return nil, fmt.Errorf("message body %T is not an UPDATE", m.Body)
+	}
+	return m.Body.Serialize(opts...)
+}
+
+// ToCapability decodes c using gobgp's capability codecs.
+func ToCapability(c corebgp.Capability) (bgp.ParameterCapabilityInterface,
+	error) {
+	if len(c.Value) > 255 {
+		return nil, errors.New("capability value too long")
+	}
+	b := append([]byte{c.Code, uint8(len(c.Value))}, c.Value...)
+	return bgp.DecodeCapability(b)
+}
+
+// FromCapability returns the corebgp.Capability for the gobgp capability c.
+func FromCapability(c bgp.ParameterCapabilityInterface) (corebgp.Capability,
+	error) {
+	b, err := c.Serialize()
+	if err != nil {
+		return corebgp.Capability{}, err
+	}
+	if len(b) < 2 || len(b)-2 != int(b[1]) {
+		return corebgp.Capability{}, errors.New("malformed capability")
+	}
+	return corebgp.Capability{Code: b[0], Value: b[2:]}, nil
+}
@@ -0,0 +1,199 @@
+package gobgpconv
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func roundTrip[T PathAttr](t *testing.T, v T, want bgp.PathAttributeInterface) {
+	t.Helper()
+	p, err := ToPathAttribute(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, want.String(), p.String())
+	got, err := FromPathAttribute[T](want)
+	if assert.NoError(t, err) {
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestPathAttribute(t *testing.T) {
+	ip := netip.MustParseAddr("192.0.2.1")
+	roundTrip(t, corebgp.OriginIncomplete,
+		bgp.NewPathAttributeOrigin(bgp.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE))
+	roundTrip(t, corebgp.ASPath{
+		{
+			Type: corebgp.ASPathSegmentTypeSequence,
+			ASNs: []uint32{65001, 4200000000},
+		},
+		{Type: corebgp.ASPathSegmentTypeSet, ASNs: []uint32{65002}},
+	}, bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ,
+			[]uint32{65001, 4200000000}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, []uint32{65002}),
+	}))
+	roundTrip(t, corebgp.NextHopPathAttr(ip),
+		bgp.NewPathAttributeNextHop("192.0.2.1"))
+	roundTrip(t, corebgp.MEDPathAttr(10), bgp.NewPathAttributeMultiExitDisc(10))
+	roundTrip(t, corebgp.LocalPrefPathAttr(200), bgp.NewPathAttributeLocalPref(200))
+	roundTrip(t, corebgp.AtomicAggregatePathAttr(true),
+		bgp.NewPathAttributeAtomicAggregate())
+	roundTrip(t, corebgp.AggregatorPathAttr{AS: 4200000000, IP: ip},
+		bgp.NewPathAttributeAggregator(uint32(4200000000), "192.0.2.1"))
+	roundTrip(t, corebgp.CommunitiesPathAttr{0xfde80001, 0xffffff01},
+		bgp.NewPathAttributeCommunities([]uint32{0xfde80001, 0xffffff01}))
+	roundTrip(t, corebgp.OriginatorIDPathAttr(ip),
+		bgp.NewPathAttributeOriginatorId("192.0.2.1"))
+	roundTrip(t, corebgp.ClusterListPathAttr{ip, netip.MustParseAddr("192.0.2.2")},
+		bgp.NewPathAttributeClusterList([]string{"192.0.2.1", "192.0.2.2"}))
+	roundTrip(t, corebgp.AIGPPathAttr(100), bgp.NewPathAttributeAigp(
+		[]bgp.AigpTLVInterface{bgp.NewAigpTLVIgpMetric(100)}))
+	roundTrip(t, corebgp.LargeCommunitiesPathAttr{
+		{GlobalAdmin: 65001, LocalData1: 1, LocalData2: 2},
+	},
+		bgp.NewPathAttributeLargeCommunities([]*bgp.LargeCommunity{
+			bgp.NewLargeCommunity(65001, 1, 2),
+		}))
+
+	// two-octet AS numbers as decoded by gobgp without the 4-octet AS
+	// capability
+	path, err := FromPathAttribute[corebgp.ASPath](bgp.NewPathAttributeAsPath(
+		[]bgp.AsPathParamInterface{
+			bgp.NewAsPathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint16{65001}),
+		}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, corebgp.ASPath{
+			{Type: corebgp.ASPathSegmentTypeSequence, ASNs: []uint32{65001}},
+		}, path)
+	}
+
+	_, err = FromPathAttribute[corebgp.MEDPathAttr](
+		bgp.NewPathAttributeLocalPref(100))
+	assert.Error(t, err)
+}
+
+func TestDecodePathAttribute(t *testing.T) {
+	// an extended community, which corebgp does not decode
+	p, err := DecodePathAttribute(corebgp.PATH_ATTR_EXTENDED_COMMUNITIES, 0xc0,
+		[]byte{0x00, 0x02, 0xfd, 0xe9, 0x00, 0x00, 0x00, 0x64})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ext, ok := p.(*bgp.PathAttributeExtendedCommunities)
+	if assert.True(t, ok) && assert.Len(t, ext.Value, 1) {
+		assert.Equal(t, "65001:100", ext.Value[0].String())
+	}
+	code, flags, b, err := EncodePathAttribute(p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, corebgp.PATH_ATTR_EXTENDED_COMMUNITIES, code)
+		assert.Equal(t, corebgp.PathAttrFlags(0xc0), flags)
+		assert.Equal(t, []byte{0x00, 0x02, 0xfd, 0xe9, 0x00, 0x00, 0x00, 0x64}, b)
+	}
+
+	// the Extended Length flag is preserved
+	long := make(corebgp.CommunitiesPathAttr, 100)
+	p, err = ToPathAttribute(long)
+	if assert.NoError(t, err) {
+		_, flags, b, err = EncodePathAttribute(p)
+		if assert.NoError(t, err) {
+			assert.True(t, flags.ExtendedLen())
+			assert.Len(t, b, 400)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	for _, p := range []corebgp.AddPathPrefix{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24")},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), ID: 7},
+	} {
+		a := ToPrefix(p)
+		assert.Equal(t, p.Prefix.String(), a.String())
+		assert.Equal(t, p.ID, a.PathIdentifier())
+		got, err := FromPrefix(a)
+		if assert.NoError(t, err) {
+			assert.Equal(t, p, got)
+		}
+	}
+	_, err := FromPrefix(bgp.NewLabeledIPAddrPrefix(24, "192.0.2.0",
+		*bgp.NewMPLSLabelStack(100)))
+	assert.Error(t, err)
+}
+
+func TestNotification(t *testing.T) {
+	n := &corebgp.Notification{
+		Code:    corebgp.NOTIF_CODE_CEASE,
+		Subcode: corebgp.NOTIF_SUBCODE_ADMIN_SHUTDOWN,
+		Data:    []byte{0},
+	}
+	m := ToNotification(n)
+	got, err := FromNotification(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, n, got)
+	}
+	_, err = FromNotification(bgp.NewBGPKeepAliveMessage())
+	assert.Error(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	attrs := corebgp.AppendPathAttr(nil, 0x40, corebgp.PATH_ATTR_ORIGIN,
+		[]byte{0})
+	attrs = corebgp.AppendPathAttr(attrs, 0x40, corebgp.PATH_ATTR_AS_PATH,
+		corebgp.ASPath{{
+			Type: corebgp.ASPathSegmentTypeSequence,
+			ASNs: []uint32{65001},
+		}}.Encode())
+	attrs = corebgp.AppendPathAttr(attrs, 0x40, corebgp.PATH_ATTR_NEXT_HOP,
+		[]byte{192, 0, 2, 1})
+	b := []byte{0, 4, 24, 198, 51, 100}
+	b = append(b, 0, uint8(len(attrs)))
+	b = append(b, attrs...)
+	b = append(b, 24, 192, 0, 2)
+
+	m, err := DecodeUpdate(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	u := m.Body.(*bgp.BGPUpdate)
+	if assert.Len(t, u.WithdrawnRoutes, 1) {
+		assert.Equal(t, "198.51.100.0/24", u.WithdrawnRoutes[0].String())
+	}
+	if assert.Len(t, u.NLRI, 1) {
+		assert.Equal(t, "192.0.2.0/24", u.NLRI[0].String())
+	}
+	if assert.Len(t, u.PathAttributes, 3) {
+		path, err := FromPathAttribute[corebgp.ASPath](u.PathAttributes[1])
+		if assert.NoError(t, err) {
+			assert.Equal(t, []uint32{65001}, path[0].ASNs)
+		}
+	}
+
+	got, err := EncodeUpdate(m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, b, got)
+	}
+	_, err = EncodeUpdate(bgp.NewBGPKeepAliveMessage())
+	assert.Error(t, err)
+}
+
+func TestCapability(t *testing.T) {
+	c := corebgp.NewMPExtensionsCapability(corebgp.AFI_IPV6,
+		corebgp.SAFI_UNICAST)
+	g, err := ToCapability(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	mp, ok := g.(*bgp.CapMultiProtocol)
+	if assert.True(t, ok) {
+		assert.Equal(t, bgp.RF_IPv6_UC, mp.CapValue)
+	}
+	got, err := FromCapability(g)
+	if assert.NoError(t, err) {
+		assert.Equal(t, c, got)
+	}
+}
@@ -0,0 +1,78 @@
+package corebgp_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInboundSourcePorts(t *testing.T) {
+	t.Parallel()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+
+	for _, tc := range []struct {
+		name             string
+		minPort, maxPort uint16
+		wantEstablished  bool
+	}{
+		// corebgptest.Network allocates source ports from 49152
+		{"allowed", 49152, 65535, true},
+		{"rejected", 1, 1024, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			n := corebgptest.NewNetwork()
+			servers := make([]*corebgp.Server, 0, 2)
+			for _, s := range []struct {
+				local, remote netip.Addr
+				opts          []corebgp.PeerOption
+			}{
+				{addrA, addrB, []corebgp.PeerOption{corebgp.WithPassive(),
+					corebgp.WithInboundSourcePorts(tc.minPort, tc.maxPort)}},
+				{addrB, addrA, nil},
+			} {
+				server, err := corebgp.NewServer(s.local)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				err = server.AddPeer(corebgp.PeerConfig{
+					RemoteAddress: s.remote,
+					LocalAS:       64512,
+					RemoteAS:      64512,
+				}, &livenessTestPlugin{}, append(s.opts,
+					corebgp.WithLocalAddress(s.local),
+					corebgp.WithIdleHoldTime(time.Millisecond*100),
+					corebgp.WithDialer(n.Dialer(s.local)))...)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				l, err := n.Listen(s.local)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				go server.Serve([]net.Listener{l})
+				t.Cleanup(server.Close)
+				servers = append(servers, server)
+			}
+
+			established := func() bool {
+				_, err := servers[0].GetSessionInfo(addrB)
+				return err == nil
+			}
+			if tc.wantEstablished {
+				assert.Eventually(t, established, time.Second*5,
+					time.Millisecond*10)
+			} else {
+				assert.Never(t, established, time.Millisecond*500,
+					time.Millisecond*10)
+			}
+		})
+	}
+}
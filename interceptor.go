@@ -0,0 +1,127 @@
+package corebgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+// MessageInterceptor inspects a raw BGP message b, including its header. It
+// returns the message to be used in place of b, which may be b itself, a
+// rewritten message, or nil to drop the message.
+//
+// Interceptors are intended for logging, fault injection in tests, and
+// working around peers with broken implementations. Dropping or rewriting
+// messages can easily violate the protocol and should be done with care.
+type MessageInterceptor func(peer PeerConfig, b []byte) []byte
+
+// WithInboundInterceptor returns a PeerOption that sets a MessageInterceptor
+// for messages received from a peer. It is invoked before the message is
+// processed by the FSM or Plugin. A rewritten message is subject to the same
+// validation as one read from the connection. Pooled UPDATE buffers, see
+// WithPooledUpdateBuffers, are not used when an inbound interceptor is set.
+func WithInboundInterceptor(fn MessageInterceptor) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.inboundInterceptor = fn
+	})
+}
+
+// WithOutboundInterceptor returns a PeerOption that sets a MessageInterceptor
+// for messages sent to a peer. It is invoked for each message immediately
+// before it is written to the connection.
+func WithOutboundInterceptor(fn MessageInterceptor) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.outboundInterceptor = fn
+	})
+}
+
+// readRawMessage reads a message from r, returning it including its header.
+// The header is validated as by readMessage, if validation fails the header
+// is returned along with the error.
+func readRawMessage(r io.Reader, lengths lengthLimits) ([]byte, error) {
+	b := make([]byte, headerLength, maxMessageLength)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return nil, err
+	}
+	bodyLen, err := validateHeader(b, lengths)
+	if err != nil {
+		return b, err
+	}
+	if headerLength+bodyLen > cap(b) {
+		// BGP Extended Message
+		b = append(make([]byte, 0, headerLength+bodyLen), b...)
+	}
+	b = b[:headerLength+bodyLen]
+	_, err = io.ReadFull(r, b[headerLength:])
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readInspectedMessage reads messages from r, passing them to fn, if non-nil,
+// until it returns a non-nil message, which is then decoded. Decoding errors
+// are passed to observer, if non-nil. If errData is true the offending
+// message is included in the Notification of decoding errors, see
+// WithDecodeErrorData.
+func readInspectedMessage(r io.Reader, lengths lengthLimits, peer PeerConfig,
+	fn MessageInterceptor, observer DecodeErrorObserver,
+	errData bool) (message, error) {
+	for {
+		b, err := readRawMessage(r, lengths)
+		if err != nil {
+			if errData {
+				setDecodeErrorData(err, b)
+			}
+			observeDecodeError(peer, observer, b, err)
+			return nil, err
+		}
+		if fn != nil {
+			b = fn(peer, b)
+			if b == nil {
+				continue
+			}
+		}
+		m, err := readMessage(bytes.NewReader(b), lengths, false)
+		if err != nil {
+			if errData {
+				setDecodeErrorData(err, b)
+			}
+			observeDecodeError(peer, observer, b, err)
+		}
+		return m, err
+	}
+}
+
+// interceptMessages applies fn to each of the messages in b, returning the
+// resulting messages.
+func interceptMessages(peer PeerConfig, fn MessageInterceptor,
+	b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for len(b) >= headerLength {
+		l := int(binary.BigEndian.Uint16(b[16:18]))
+		if l < headerLength || l > len(b) {
+			// not a message we encoded; pass it through unmodified
+			return append(out, b...)
+		}
+		out = append(out, fn(peer, b[:l])...)
+		b = b[l:]
+	}
+	return append(out, b...)
+}
+
+// writeIntercepted applies fn, if non-nil, to the messages in b before writing
+// them to conn, see writeWithSendHold.
+func writeIntercepted(conn net.Conn, b []byte, sendHoldTime time.Duration,
+	peer PeerConfig, fn MessageInterceptor) error {
+	if fn != nil {
+		b = interceptMessages(peer, fn, b)
+		if len(b) == 0 {
+			return nil
+		}
+	}
+	return writeWithSendHold(conn, b, sendHoldTime)
+}
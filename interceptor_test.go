@@ -0,0 +1,57 @@
+package corebgp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMessageWriter_OutboundInterceptor(t *testing.T) {
+	conn := &recordingConn{}
+	w := newTestUpdateMessageWriter(conn, 0)
+	eor := NewEndOfRIB(AFI_IPV6, SAFI_UNICAST)
+	// drop IPv4 End-of-RIB markers
+	w.interceptor = func(peer PeerConfig, b []byte) []byte {
+		if len(b) == headerLength+4 {
+			return nil
+		}
+		return b
+	}
+	assert.NoError(t, w.WriteUpdates([][]byte{{0, 0, 0, 0}, eor}))
+	if assert.Len(t, conn.writes, 1) {
+		assert.Equal(t, prependHeader(eor, updateMessageType), conn.writes[0])
+	}
+
+	assert.NoError(t, w.WriteUpdate([]byte{0, 0, 0, 0}))
+	assert.Len(t, conn.writes, 1)
+}
+
+func TestReadInspectedMessage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(prependHeader(nil, keepAliveMessageType))
+	buf.Write(prependHeader([]byte{0, 0, 0, 0}, updateMessageType))
+
+	calls := 0
+	m, err := readInspectedMessage(&buf, defaultLengthLimits, PeerConfig{},
+		func(peer PeerConfig, b []byte) []byte {
+			calls++
+			if b[18] == keepAliveMessageType {
+				return nil
+			}
+			// rewrite to an IPv6 End-of-RIB marker
+			return prependHeader(NewEndOfRIB(AFI_IPV6, SAFI_UNICAST),
+				updateMessageType)
+		}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, updateMessage(NewEndOfRIB(AFI_IPV6, SAFI_UNICAST)), m)
+
+	// rewritten messages are validated
+	buf.Write(prependHeader(nil, keepAliveMessageType))
+	_, err = readInspectedMessage(&buf, defaultLengthLimits, PeerConfig{},
+		func(peer PeerConfig, b []byte) []byte {
+			return b[:headerLength-1]
+		}, nil, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package irr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Source loads a corebgp.PrefixSet, see Filters.
+type Source func(ctx context.Context) (*corebgp.PrefixSet, error)
+
+// FileSource returns a Source that parses the file at path in format.
+func FileSource(path string, format Format) Source {
+	return func(ctx context.Context) (*corebgp.PrefixSet, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return format.Parse(f)
+	}
+}
+
+// CommandSource returns a Source that runs the named program with args and
+// parses its standard output in format, e.g.
+//
+//	CommandSource(FormatBGPQ4JSON, "bgpq4", "-4", "-j", "-l", "NN",
+//		"AS-EXAMPLE")
+func CommandSource(format Format, name string, args ...string) Source {
+	return func(ctx context.Context) (*corebgp.PrefixSet, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %s", name, err,
+				bytes.TrimSpace(stderr.Bytes()))
+		}
+		return format.Parse(&stdout)
+	}
+}
+
+// Filters holds a corebgp.PrefixSet per peer, loaded from the peer's Source,
+// for filtering the routes received from it, e.g. by a route server. The
+// PrefixSets are replaced atomically when reloaded, so that Allowed may be
+// called concurrently with Reload, e.g. from UpdateMessageHandlers.
+type Filters struct {
+	sets atomic.Pointer[map[netip.Addr]*corebgp.PrefixSet]
+
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+
+	mu      sync.Mutex
+	sources map[netip.Addr]Source
+}
+
+// NewFilters returns a Filters without peers.
+func NewFilters() *Filters {
+	f := &Filters{
+		sources: make(map[netip.Addr]Source),
+	}
+	f.sets.Store(&map[netip.Addr]*corebgp.PrefixSet{})
+	return f
+}
+
+// Set sets the Source of peer's PrefixSet, which is loaded on the next call
+// to Reload. Until then any previously loaded PrefixSet remains in use.
+func (f *Filters) Set(peer netip.Addr, src Source) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sources[peer] = src
+}
+
+// Remove removes peer and its PrefixSet.
+func (f *Filters) Remove(peer netip.Addr) {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+	f.mu.Lock()
+	delete(f.sources, peer)
+	f.mu.Unlock()
+	old := *f.sets.Load()
+	if _, ok := old[peer]; !ok {
+		return
+	}
+	sets := make(map[netip.Addr]*corebgp.PrefixSet, len(old))
+	for addr, s := range old {
+		if addr != peer {
+			sets[addr] = s
+		}
+	}
+	f.sets.Store(&sets)
+}
+
+// PrefixSet returns the PrefixSet loaded for peer, and true if one was
+// loaded.
+func (f *Filters) PrefixSet(peer netip.Addr) (*corebgp.PrefixSet, bool) {
+	s, ok := (*f.sets.Load())[peer]
+	return s, ok
+}
+
+// Allowed returns true if prefix is contained in the PrefixSet loaded for
+// peer. It returns false if no PrefixSet was loaded for peer, i.e. routes are
+// rejected until their filter is available.
+func (f *Filters) Allowed(peer netip.Addr, prefix netip.Prefix) bool {
+	s, ok := f.PrefixSet(peer)
+	return ok && s.Contains(prefix)
+}
+
+// Reload loads the PrefixSet of every peer from its Source. If a Source fails
+// the peer's previously loaded PrefixSet, if any, remains in use, and an
+// error joining the errors of all failed Sources is returned.
+func (f *Filters) Reload(ctx context.Context) error {
+	f.reloadMu.Lock()
+	defer f.reloadMu.Unlock()
+	f.mu.Lock()
+	sources := make(map[netip.Addr]Source, len(f.sources))
+	for peer, src := range f.sources {
+		sources[peer] = src
+	}
+	f.mu.Unlock()
+
+	old := *f.sets.Load()
+	sets := make(map[netip.Addr]*corebgp.PrefixSet, len(sources))
+	var errs []error
+	for peer, src := range sources {
+		s, err := src(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer, err))
+			if prev, ok := old[peer]; ok {
+				sets[peer] = prev
+			}
+			continue
+		}
+		sets[peer] = s
+	}
+	f.sets.Store(&sets)
+	return errors.Join(errs...)
+}
+
+// Run calls Reload every interval until ctx is done, passing any error
+// returned by Reload to onError if it is non-nil. The first Reload happens
+// immediately. Run returns when ctx is done.
+func (f *Filters) Run(ctx context.Context, interval time.Duration,
+	onError func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := f.Reload(ctx)
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
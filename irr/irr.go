@@ -0,0 +1,253 @@
+// Package irr builds corebgp.PrefixSets from Internet Routing Registry (IRR)
+// data, e.g. the output of bgpq4 or RPSL route objects, and maintains them per
+// peer for inbound filtering, reloading them as the IRR data changes.
+package irr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Format is the format of IRR data.
+type Format uint8
+
+const (
+	// FormatText is one prefix range per line in a form accepted by
+	// corebgp.ParsePrefixSet, e.g. bgpq4's default Cisco prefix list output.
+	FormatText Format = iota
+	// FormatBGPQ4JSON is the JSON output of bgpq4 -j, see ParseBGPQ4JSON.
+	FormatBGPQ4JSON
+	// FormatRPSL is RPSL route and route6 objects, see ParseRoutes.
+	FormatRPSL
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatBGPQ4JSON:
+		return "bgpq4 JSON"
+	case FormatRPSL:
+		return "RPSL"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse parses a corebgp.PrefixSet in format f from r. All route objects are
+// included for FormatRPSL, see NewPrefixSet to filter them by origin.
+func (f Format) Parse(r io.Reader) (*corebgp.PrefixSet, error) {
+	switch f {
+	case FormatText:
+		return corebgp.ParsePrefixSet(r)
+	case FormatBGPQ4JSON:
+		return ParseBGPQ4JSON(r)
+	case FormatRPSL:
+		routes, err := ParseRoutes(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrefixSet(routes, 0, 0)
+	default:
+		return nil, fmt.Errorf("unknown format: %d", f)
+	}
+}
+
+// bgpq4Entry is a member of a prefix list output by bgpq4 -j.
+type bgpq4Entry struct {
+	Prefix       string `json:"prefix"`
+	Exact        bool   `json:"exact"`
+	GreaterEqual *int   `json:"greater-equal"`
+	LessEqual    *int   `json:"less-equal"`
+}
+
+// ParseBGPQ4JSON parses the JSON output of bgpq4 -j, an object mapping prefix
+// list names to their entries, e.g.
+//
+//	{ "NN": [
+//	  { "prefix": "192.0.2.0/24", "exact": true },
+//	  { "prefix": "198.51.100.0/22", "exact": false,
+//	    "greater-equal": 23, "less-equal": 24 }
+//	] }
+//
+// The entries of all prefix lists are added to the returned PrefixSet. An
+// entry that is not exact without greater-equal or less-equal, e.g. as output
+// with -R, matches the prefix and its more specifics.
+func ParseBGPQ4JSON(r io.Reader) (*corebgp.PrefixSet, error) {
+	var lists map[string][]bgpq4Entry
+	err := json.NewDecoder(r).Decode(&lists)
+	if err != nil {
+		return nil, err
+	}
+	s := &corebgp.PrefixSet{}
+	for name, entries := range lists {
+		for i, e := range entries {
+			p, err := netip.ParsePrefix(e.Prefix)
+			if err != nil {
+				return nil, fmt.Errorf("%s entry %d: %w", name, i, err)
+			}
+			pr := corebgp.ExactPrefixRange(p)
+			if !e.Exact {
+				pr.Max = p.Addr().BitLen()
+				if e.GreaterEqual != nil {
+					pr.Min = *e.GreaterEqual
+				}
+				if e.LessEqual != nil {
+					pr.Max = *e.LessEqual
+				}
+			}
+			err = s.Add(pr)
+			if err != nil {
+				return nil, fmt.Errorf("%s entry %d: %w", name, i, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Route is an RPSL route or route6 object.
+//
+// https://www.rfc-editor.org/rfc/rfc2622#section-4
+// https://www.rfc-editor.org/rfc/rfc4012#section-3
+type Route struct {
+	Prefix netip.Prefix
+	Origin uint32
+}
+
+// ParseRoutes parses the route and route6 objects in r, e.g. an IRR database
+// dump or the output of a whois query, ignoring all other objects and
+// attributes. Objects are separated by blank lines. Comments, i.e. lines
+// beginning with # or %, are ignored. Errors include the offending line
+// number.
+func ParseRoutes(r io.Reader) ([]Route, error) {
+	routes := make([]Route, 0)
+	var (
+		route              Route
+		inRoute, hasOrigin bool
+		line, objectStart  int
+		lastAttr           string
+	)
+	end := func() error {
+		if inRoute {
+			if !hasOrigin {
+				return fmt.Errorf("line %d: route object without origin",
+					objectStart)
+			}
+			routes = append(routes, route)
+		}
+		route, inRoute, hasOrigin, lastAttr = Route{}, false, false, ""
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if len(strings.TrimSpace(text)) == 0 {
+			if err := end(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if text[0] == '#' || text[0] == '%' {
+			continue
+		}
+		if text[0] == ' ' || text[0] == '\t' || text[0] == '+' {
+			// a continuation of lastAttr, which route and origin never
+			// require
+			continue
+		}
+		attr, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed attribute", line)
+		}
+		attr = strings.ToLower(strings.TrimSpace(attr))
+		// remove any trailing comment
+		value, _, _ = strings.Cut(value, "#")
+		value = strings.TrimSpace(value)
+		if len(lastAttr) == 0 {
+			// the first attribute determines the object class
+			objectStart = line
+			if attr == "route" || attr == "route6" {
+				p, err := netip.ParsePrefix(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				if p.Addr().Is4() != (attr == "route") {
+					return nil, fmt.Errorf("line %d: %s object with prefix %s",
+						line, attr, p)
+				}
+				route.Prefix = p.Masked()
+				inRoute = true
+			}
+		} else if inRoute && attr == "origin" {
+			asn, err := parseASN(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			route.Origin = asn
+			hasOrigin = true
+		}
+		lastAttr = attr
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := end(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// parseASN parses an AS number in RPSL notation, e.g. AS64496.
+func parseASN(s string) (uint32, error) {
+	if len(s) < 3 || !strings.EqualFold(s[:2], "AS") {
+		return 0, fmt.Errorf("invalid AS number: %s", s)
+	}
+	asn, err := strconv.ParseUint(s[2:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid AS number: %s", s)
+	}
+	return uint32(asn), nil
+}
+
+// NewPrefixSet returns a corebgp.PrefixSet containing the prefixes of routes.
+// If maxLen4 or maxLen6 exceed the length of an IPv4 or IPv6 prefix
+// respectively, its more specifics up to that length are also included, e.g.
+// to permit a peer to deaggregate. If origins are provided only routes
+// originated by one of them are included.
+func NewPrefixSet(routes []Route, maxLen4, maxLen6 int,
+	origins ...uint32) (*corebgp.PrefixSet, error) {
+	s := &corebgp.PrefixSet{}
+	for _, r := range routes {
+		if len(origins) > 0 && !containsASN(origins, r.Origin) {
+			continue
+		}
+		pr := corebgp.ExactPrefixRange(r.Prefix)
+		maxLen := maxLen6
+		if r.Prefix.Addr().Is4() {
+			maxLen = maxLen4
+		}
+		pr.Max = max(pr.Max, min(maxLen, r.Prefix.Addr().BitLen()))
+		err := s.Add(pr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func containsASN(asns []uint32, asn uint32) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
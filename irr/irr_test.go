@@ -0,0 +1,147 @@
+package irr
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBGPQ4JSON(t *testing.T) {
+	s, err := ParseBGPQ4JSON(strings.NewReader(`{ "NN": [
+  { "prefix": "192.0.2.0/24", "exact": true },
+  { "prefix": "198.51.100.0/22", "exact": false, "greater-equal": 23, "less-equal": 24 },
+  { "prefix": "2001:db8::/32", "exact": false, "less-equal": 48 }
+] }`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for p, want := range map[string]bool{
+		"192.0.2.0/24":    true,
+		"192.0.2.0/25":    false,
+		"198.51.100.0/22": false,
+		"198.51.100.0/23": true,
+		"198.51.103.0/24": true,
+		"198.51.100.0/25": false,
+		"2001:db8::/32":   true,
+		"2001:db8:1::/48": true,
+		"2001:db8::/49":   false,
+	} {
+		assert.Equal(t, want, s.Contains(netip.MustParsePrefix(p)), p)
+	}
+
+	_, err = ParseBGPQ4JSON(strings.NewReader(
+		`{"NN":[{"prefix":"192.0.2.0/24","exact":false,"less-equal":33}]}`))
+	assert.Error(t, err)
+	_, err = ParseBGPQ4JSON(strings.NewReader(`{"NN":[{"prefix":"x"}]}`))
+	assert.Error(t, err)
+}
+
+const testRoutes = `% whois comment
+route:          192.0.2.0/24
+descr:          example
+                continued
+origin:         AS64496 # comment
+source:         RADB
+
+aut-num:        AS64496
+origin:         AS64497
+
+route6:         2001:db8::/32
+origin:         as64497
+`
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(strings.NewReader(testRoutes))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Route{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Origin: 64496},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Origin: 64497},
+	}, routes)
+
+	for _, invalid := range []string{
+		"route: 192.0.2.0/24\nsource: RADB\n",
+		"route: 192.0.2.0/24\norigin: 64496\n",
+		"route6: 192.0.2.0/24\norigin: AS64496\n",
+		"route: 192.0.2.0\norigin: AS64496\n",
+		"route 192.0.2.0/24\n",
+	} {
+		_, err = ParseRoutes(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNewPrefixSet(t *testing.T) {
+	routes, err := ParseRoutes(strings.NewReader(testRoutes))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := NewPrefixSet(routes, 25, 0)
+	if assert.NoError(t, err) {
+		assert.True(t, s.Contains(netip.MustParsePrefix("192.0.2.128/25")))
+		assert.False(t, s.Contains(netip.MustParsePrefix("192.0.2.0/26")))
+		assert.True(t, s.Contains(netip.MustParsePrefix("2001:db8::/32")))
+		assert.False(t, s.Contains(netip.MustParsePrefix("2001:db8::/48")))
+	}
+	s, err = NewPrefixSet(routes, 0, 0, 64497)
+	if assert.NoError(t, err) {
+		assert.False(t, s.Contains(netip.MustParsePrefix("192.0.2.0/24")))
+		assert.True(t, s.Contains(netip.MustParsePrefix("2001:db8::/32")))
+	}
+}
+
+func TestFilters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "peer.txt")
+	err := os.WriteFile(path, []byte("192.0.2.0/24\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+	a := netip.MustParseAddr("203.0.113.1")
+	b := netip.MustParseAddr("203.0.113.2")
+	p := netip.MustParsePrefix("192.0.2.0/24")
+	q := netip.MustParsePrefix("198.51.100.0/24")
+
+	f := NewFilters()
+	f.Set(a, FileSource(path, FormatText))
+	assert.False(t, f.Allowed(a, p))
+	assert.NoError(t, f.Reload(context.Background()))
+	assert.True(t, f.Allowed(a, p))
+	assert.False(t, f.Allowed(a, q))
+	assert.False(t, f.Allowed(b, p))
+
+	// reload picks up changes
+	err = os.WriteFile(path, []byte("198.51.100.0/24\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, f.Reload(context.Background()))
+	assert.False(t, f.Allowed(a, p))
+	assert.True(t, f.Allowed(a, q))
+
+	// a failing source retains the previous set
+	errFailed := errors.New("failed")
+	f.Set(a, func(ctx context.Context) (*corebgp.PrefixSet, error) {
+		return nil, errFailed
+	})
+	f.Set(b, FileSource(filepath.Join(dir, "missing"), FormatText))
+	err = f.Reload(context.Background())
+	assert.ErrorIs(t, err, errFailed)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.True(t, f.Allowed(a, q))
+	_, ok := f.PrefixSet(b)
+	assert.False(t, ok)
+
+	f.Remove(a)
+	assert.False(t, f.Allowed(a, q))
+	f.Remove(b)
+	assert.NoError(t, f.Reload(context.Background()))
+}
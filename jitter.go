@@ -0,0 +1,56 @@
+package corebgp
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// DefaultJitterMin is the default lower bound of the jitter applied to
+// keepalive and connect retry timers, see WithJitter.
+//
+// https://tools.ietf.org/html/rfc4271#section-10
+// The suggested default amount of jitter SHALL be determined by multiplying
+// the base value of the appropriate timer by a random factor, which is
+// uniformly distributed in the range from 0.75 to 1.0.
+const DefaultJitterMin = 0.75
+
+// WithJitter returns a PeerOption that sets the lower bound of the random
+// factor applied to a peer's keepalive and connect retry timers, which
+// defaults to DefaultJitterMin. Each time one of the timers is started its
+// base value is multiplied by a factor uniformly distributed in the range from
+// min to 1.0, so that many speakers sharing a configuration do not synchronize
+// their message bursts. min must be in the range (0, 1], a value of 1 disables
+// jitter.
+//
+// https://tools.ietf.org/html/rfc4271#section-10
+// To minimize the likelihood that the distribution of BGP messages by a given
+// BGP speaker will contain peaks, jitter SHOULD be applied to the timers
+// associated with MinASOriginationIntervalTimer, KeepaliveTimer,
+// MinRouteAdvertisementIntervalTimer, and ConnectRetryTimer.
+func WithJitter(min float64) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.jitterMin = min
+	})
+}
+
+func validateJitterMin(min float64) error {
+	if !(min > 0 && min <= 1) {
+		return errors.New("jitter min must be in the range (0, 1]")
+	}
+	return nil
+}
+
+// jitter returns d multiplied by a random factor uniformly distributed in the
+// range [min, 1.0].
+func jitter(d time.Duration, min float64) time.Duration {
+	if min >= 1 || d <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (min + rand.Float64()*(1-min)))
+}
+
+// jitteredConnectRetryTime returns the jittered initial value of the ConnectRetryTimer.
+func (o *peerOptions) jitteredConnectRetryTime() time.Duration {
+	return jitter(o.connectRetryTime, o.jitterMin)
+}
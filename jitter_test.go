@@ -0,0 +1,32 @@
+package corebgp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	d := time.Second * 30
+	for i := 0; i < 1000; i++ {
+		j := jitter(d, DefaultJitterMin)
+		assert.GreaterOrEqual(t, j, time.Second*22+time.Millisecond*500)
+		assert.LessOrEqual(t, j, d)
+	}
+	assert.Equal(t, d, jitter(d, 1))
+	assert.Equal(t, time.Duration(0), jitter(0, DefaultJitterMin))
+}
+
+func TestWithJitter(t *testing.T) {
+	for _, min := range []float64{0, -0.5, 1.1} {
+		o := defaultPeerOptions()
+		WithJitter(min).apply(&o)
+		assert.Error(t, o.validate(), "min %v", min)
+	}
+	for _, min := range []float64{0.1, DefaultJitterMin, 1} {
+		o := defaultPeerOptions()
+		WithJitter(min).apply(&o)
+		assert.NoError(t, o.validate(), "min %v", min)
+	}
+}
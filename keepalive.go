@@ -0,0 +1,57 @@
+package corebgp
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrZeroHoldTime is returned by Server.SendKeepalive for a session with a
+// negotiated hold time of zero.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.2
+// If the value of this field is zero, then the Hold Time is zero, and
+// KEEPALIVE messages MUST NOT be sent.
+var ErrZeroHoldTime = errors.New("negotiated hold time is zero")
+
+// WithKeepaliveSuppression returns a PeerOption that suppresses the automatic
+// sending of KEEPALIVE messages once the KEEPALIVE acknowledging the peer's
+// OPEN message has been sent. KEEPALIVE messages may then be sent on demand
+// via Server.SendKeepalive. This is intended for conformance testing of
+// other BGP implementations, e.g. exercising their hold timers. Unless the
+// negotiated hold time is 0 the peer will eventually close the session if
+// KEEPALIVE messages are not sent.
+func WithKeepaliveSuppression() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.suppressKeepalives = true
+	})
+}
+
+// newStoppedTimer returns a Timer created by clock that has been stopped,
+// used in place of a timer that is disabled.
+func newStoppedTimer(clock Clock) Timer {
+	t := clock.NewTimer(time.Hour)
+	t.Stop()
+	return t
+}
+
+// writeKeepAlive writes a KEEPALIVE message after any buffered update
+// messages, restarting the KeepaliveTimer.
+func (u *updateMessageWriter) writeKeepAlive() error {
+	select {
+	case <-u.closeCh:
+		return io.ErrClosedPipe
+	default:
+	}
+	if u.session.HoldTime == 0 {
+		return ErrZeroHoldTime
+	}
+	b, err := (&keepAliveMessage{}).encode()
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.buf = append(u.buf, b...)
+	return u.flushLocked()
+}
@@ -0,0 +1,112 @@
+package corebgp_test
+
+import (
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newKeepaliveTestServers returns servers for addrA and addrB peered with
+// each other, where the server for addrA uses optsA. It returns a pointer to
+// the number of KEEPALIVE messages received by the server for addrB.
+func newKeepaliveTestServers(t *testing.T, holdTime uint16,
+	optsA ...corebgp.PeerOption) ([]*corebgp.Server, *atomic.Int64) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	var keepalives atomic.Int64
+	countKeepalives := func(peer corebgp.PeerConfig, b []byte) []byte {
+		if b[18] == 4 {
+			keepalives.Add(1)
+		}
+		return b
+	}
+	servers := make([]*corebgp.Server, 0, 2)
+	for _, s := range []struct {
+		local, remote netip.Addr
+		opts          []corebgp.PeerOption
+	}{
+		{addrA, addrB, optsA},
+		{addrB, addrA, []corebgp.PeerOption{corebgp.WithPassive(),
+			corebgp.WithInboundInterceptor(countKeepalives)}},
+	} {
+		server, err := corebgp.NewServer(s.local)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		err = server.AddPeer(corebgp.PeerConfig{
+			RemoteAddress: s.remote,
+			LocalAS:       64512,
+			RemoteAS:      64512,
+		}, &livenessTestPlugin{}, append(s.opts, corebgp.WithLocalAddress(s.local),
+			corebgp.WithHoldTime(holdTime),
+			corebgp.WithIdleHoldTime(time.Millisecond*100),
+			corebgp.WithDialer(n.Dialer(s.local)))...)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		l, err := n.Listen(s.local)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		go server.Serve([]net.Listener{l})
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+	}
+	for i, remote := range []netip.Addr{addrB, addrA} {
+		assert.Eventually(t, func() bool {
+			_, err := servers[i].GetSessionInfo(remote)
+			return err == nil
+		}, time.Second*5, time.Millisecond*10)
+	}
+	return servers, &keepalives
+}
+
+func TestWithKeepaliveSuppression(t *testing.T) {
+	addrB := netip.MustParseAddr("192.0.2.2")
+	servers, keepalives := newKeepaliveTestServers(t, 3,
+		corebgp.WithKeepaliveSuppression())
+
+	info, err := servers[0].GetSessionInfo(addrB)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Duration(0), info.KeepaliveInterval)
+	}
+	info, err = servers[1].GetSessionInfo(netip.MustParseAddr("192.0.2.1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Second, info.KeepaliveInterval)
+	}
+
+	// only the KEEPALIVE acknowledging the OPEN message is sent
+	time.Sleep(time.Millisecond * 1500)
+	assert.Equal(t, int64(1), keepalives.Load())
+
+	assert.NoError(t, servers[0].SendKeepalive(addrB))
+	assert.Eventually(t, func() bool {
+		return keepalives.Load() == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	assert.ErrorIs(t, servers[0].SendKeepalive(
+		netip.MustParseAddr("192.0.2.3")), corebgp.ErrPeerNotExist)
+}
+
+func TestZeroHoldTime(t *testing.T) {
+	addrB := netip.MustParseAddr("192.0.2.2")
+	servers, keepalives := newKeepaliveTestServers(t, 0)
+	info, err := servers[0].GetSessionInfo(addrB)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Duration(0), info.HoldTime)
+		assert.Equal(t, time.Duration(0), info.KeepaliveInterval)
+	}
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, int64(1), keepalives.Load())
+	assert.ErrorIs(t, servers[0].SendKeepalive(addrB),
+		corebgp.ErrZeroHoldTime)
+	_, err = servers[0].GetSessionInfo(addrB)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// extCommTypeTransitiveTwoOctetAS is the Transitive Two-Octet AS-Specific
+	// Extended Community Type.
+	extCommTypeTransitiveTwoOctetAS = 0x00
+	// extCommTypeNonTransitiveTwoOctetAS is the Non-Transitive Two-Octet
+	// AS-Specific Extended Community Type.
+	extCommTypeNonTransitiveTwoOctetAS = 0x40
+	// extCommSubTypeLinkBandwidth is the Link Bandwidth Extended Community
+	// Sub-Type of both Two-Octet AS-Specific Extended Community Types.
+	extCommSubTypeLinkBandwidth = 0x04
+)
+
+// LinkBandwidth is a Link Bandwidth Extended Community, which carries the
+// bandwidth of the link to the next hop of a path, e.g. for weighting the
+// next hops of a multipath route.
+//
+// The community was originally specified as non-transitive. It is also used
+// in its transitive form, e.g. to carry the aggregate bandwidth of an anycast
+// service across AS boundaries.
+//
+// https://datatracker.ietf.org/doc/html/draft-ietf-idr-link-bandwidth
+type LinkBandwidth struct {
+	// AS is the Global Administrator field, the AS of the router attaching
+	// the community. AS_TRANS is used if the AS does not fit in two octets.
+	AS uint16
+	// Bandwidth is the bandwidth of the link in bytes per second.
+	Bandwidth float32
+	// Transitive is true for the transitive form of the community.
+	Transitive bool
+}
+
+// NewLinkBandwidthCommunity returns the Link Bandwidth Extended Community for
+// l, encoded as a member of the EXTENDED COMMUNITIES path attribute.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|  0x40 or 0x00 |      0x04     |    Global Administrator (AS)  |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|               Bandwidth (IEEE floating point, bytes/s)        |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+func NewLinkBandwidthCommunity(l LinkBandwidth) [8]byte {
+	var c [8]byte
+	c[0] = extCommTypeNonTransitiveTwoOctetAS
+	if l.Transitive {
+		c[0] = extCommTypeTransitiveTwoOctetAS
+	}
+	c[1] = extCommSubTypeLinkBandwidth
+	binary.BigEndian.PutUint16(c[2:], l.AS)
+	binary.BigEndian.PutUint32(c[4:], math.Float32bits(l.Bandwidth))
+	return c
+}
+
+// DecodeLinkBandwidthCommunity returns the LinkBandwidth and true if c is a
+// Link Bandwidth Extended Community with a valid bandwidth, i.e. a finite,
+// non-negative number.
+func DecodeLinkBandwidthCommunity(c [8]byte) (LinkBandwidth, bool) {
+	if !isLinkBandwidthCommunity(c) {
+		return LinkBandwidth{}, false
+	}
+	bw := math.Float32frombits(binary.BigEndian.Uint32(c[4:]))
+	if math.IsNaN(float64(bw)) || math.IsInf(float64(bw), 0) || bw < 0 {
+		return LinkBandwidth{}, false
+	}
+	return LinkBandwidth{
+		AS:         binary.BigEndian.Uint16(c[2:]),
+		Bandwidth:  bw,
+		Transitive: c[0] == extCommTypeTransitiveTwoOctetAS,
+	}, true
+}
+
+func isLinkBandwidthCommunity(c [8]byte) bool {
+	return (c[0] == extCommTypeNonTransitiveTwoOctetAS ||
+		c[0] == extCommTypeTransitiveTwoOctetAS) &&
+		c[1] == extCommSubTypeLinkBandwidth
+}
+
+// FindLinkBandwidth returns the first Link Bandwidth Extended Community in b,
+// the value of an EXTENDED COMMUNITIES path attribute, and true if one is
+// present.
+func FindLinkBandwidth(b []byte) (LinkBandwidth, bool) {
+	for len(b) >= 8 {
+		if l, ok := DecodeLinkBandwidthCommunity([8]byte(b[:8])); ok {
+			return l, true
+		}
+		b = b[8:]
+	}
+	return LinkBandwidth{}, false
+}
+
+// AppendLinkBandwidthCommunity appends the Link Bandwidth Extended Community
+// for l to the EXTENDED COMMUNITIES path attribute value b, replacing any
+// existing Link Bandwidth Extended Community.
+func AppendLinkBandwidthCommunity(b []byte, l LinkBandwidth) []byte {
+	out := make([]byte, 0, len(b)+8)
+	for len(b) >= 8 {
+		c := [8]byte(b[:8])
+		if !isLinkBandwidthCommunity(c) {
+			out = append(out, c[:]...)
+		}
+		b = b[8:]
+	}
+	c := NewLinkBandwidthCommunity(l)
+	return append(out, c[:]...)
+}
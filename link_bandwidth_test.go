@@ -0,0 +1,49 @@
+package corebgp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkBandwidthCommunity(t *testing.T) {
+	l := LinkBandwidth{AS: 64512, Bandwidth: 1.25e9}
+	c := NewLinkBandwidthCommunity(l)
+	assert.Equal(t, [8]byte{0x40, 0x04, 0xfc, 0x00, 0x4e, 0x95, 0x02, 0xf9}, c)
+	got, ok := DecodeLinkBandwidthCommunity(c)
+	assert.True(t, ok)
+	assert.Equal(t, l, got)
+
+	l.Transitive = true
+	c = NewLinkBandwidthCommunity(l)
+	assert.Equal(t, byte(0x00), c[0])
+	got, ok = DecodeLinkBandwidthCommunity(c)
+	assert.True(t, ok)
+	assert.Equal(t, l, got)
+
+	_, ok = DecodeLinkBandwidthCommunity(NewLinkBandwidthCommunity(
+		LinkBandwidth{Bandwidth: float32(math.NaN())}))
+	assert.False(t, ok)
+	_, ok = DecodeLinkBandwidthCommunity(NewLinkBandwidthCommunity(
+		LinkBandwidth{Bandwidth: -1}))
+	assert.False(t, ok)
+	_, ok = DecodeLinkBandwidthCommunity([8]byte{0x40, 0x05})
+	assert.False(t, ok)
+}
+
+func TestAppendLinkBandwidthCommunity(t *testing.T) {
+	other := [8]byte{0x00, 0x02, 0xfc, 0x00, 0, 0, 0, 1} // route target
+	old := NewLinkBandwidthCommunity(LinkBandwidth{AS: 64512, Bandwidth: 1})
+	b := append(other[:], old[:]...)
+	l := LinkBandwidth{AS: 64513, Bandwidth: 2, Transitive: true}
+	b = AppendLinkBandwidthCommunity(b, l)
+	c := NewLinkBandwidthCommunity(l)
+	assert.Equal(t, append(other[:], c[:]...), b)
+
+	got, ok := FindLinkBandwidth(b)
+	assert.True(t, ok)
+	assert.Equal(t, l, got)
+	_, ok = FindLinkBandwidth(other[:])
+	assert.False(t, ok)
+}
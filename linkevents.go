@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+)
+
+type linkEventConn struct{}
+
+func openLinkEvents() (*linkEventConn, error) {
+	return nil, errors.New("unsupported")
+}
+
+func (l *linkEventConn) read() ([]linkEvent, error) {
+	return nil, errors.New("unsupported")
+}
+
+func (l *linkEventConn) close() error {
+	return errors.New("unsupported")
+}
+
+func routeInterface(dst netip.Addr) (int, netip.Addr, error) {
+	return 0, netip.Addr{}, errors.New("unsupported")
+}
@@ -0,0 +1,208 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// linkEventConn is a netlink socket subscribed to link and address changes.
+type linkEventConn struct {
+	f   *os.File
+	buf []byte
+}
+
+func openLinkEvents() (*linkEventConn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK,
+		unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR |
+			unix.RTMGRP_IPV6_IFADDR,
+	})
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	// a non-blocking fd results in a pollable *os.File, allowing a blocked
+	// read to be interrupted by close
+	return &linkEventConn{
+		f:   os.NewFile(uintptr(fd), "netlink"),
+		buf: make([]byte, os.Getpagesize()*8),
+	}, nil
+}
+
+func (l *linkEventConn) read() ([]linkEvent, error) {
+	n, err := l.f.Read(l.buf)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(l.buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	events := make([]linkEvent, 0, len(msgs))
+	for _, m := range msgs {
+		e, ok := parseLinkEvent(m)
+		if ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// parseLinkEvent returns the linkEvent for m and true if m is relevant to
+// peer liveness.
+//
+// https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+func parseLinkEvent(m syscall.NetlinkMessage) (linkEvent, bool) {
+	switch m.Header.Type {
+	case unix.RTM_NEWLINK, unix.RTM_DELLINK:
+		if len(m.Data) < unix.SizeofIfInfomsg {
+			return linkEvent{}, false
+		}
+		// struct ifinfomsg
+		ifindex := int(int32(binary.NativeEndian.Uint32(m.Data[4:])))
+		flags := binary.NativeEndian.Uint32(m.Data[8:])
+		down := m.Header.Type == unix.RTM_DELLINK ||
+			flags&unix.IFF_UP == 0 || flags&unix.IFF_LOWER_UP == 0
+		return linkEvent{ifindex: ifindex, down: down}, down
+	case unix.RTM_DELADDR:
+		if len(m.Data) < unix.SizeofIfAddrmsg {
+			return linkEvent{}, false
+		}
+		// struct ifaddrmsg
+		e := linkEvent{
+			ifindex: int(binary.NativeEndian.Uint32(m.Data[4:])),
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return linkEvent{}, false
+		}
+		for _, a := range attrs {
+			// IFA_LOCAL is preferred as IFA_ADDRESS is the destination
+			// address of point-to-point interfaces
+			switch a.Attr.Type {
+			case unix.IFA_LOCAL:
+				e.deletedAddr, _ = netip.AddrFromSlice(a.Value)
+			case unix.IFA_ADDRESS:
+				if !e.deletedAddr.IsValid() {
+					e.deletedAddr, _ = netip.AddrFromSlice(a.Value)
+				}
+			}
+		}
+		return e, e.deletedAddr.IsValid()
+	}
+	return linkEvent{}, false
+}
+
+func (l *linkEventConn) close() error {
+	return l.f.Close()
+}
+
+// routeInterface returns the index of the interface and the preferred source
+// address of the route to dst. The source address is invalid if the route
+// does not specify one.
+func routeInterface(dst netip.Addr) (int, netip.Addr, error) {
+	if dst.Zone() != "" {
+		// link-local addresses are scoped to their zone
+		ifindex, err := strconv.Atoi(dst.Zone())
+		if err == nil {
+			return ifindex, netip.Addr{}, nil
+		}
+		ifi, err := net.InterfaceByName(dst.Zone())
+		if err != nil {
+			return 0, netip.Addr{}, err
+		}
+		return ifi.Index, netip.Addr{}, nil
+	}
+	dst = dst.Unmap()
+	family := unix.AF_INET
+	if dst.Is6() {
+		family = unix.AF_INET6
+	}
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC,
+		unix.NETLINK_ROUTE)
+	if err != nil {
+		return 0, netip.Addr{}, err
+	}
+	defer unix.Close(fd)
+
+	// struct rtmsg followed by an RTA_DST attribute
+	body := make([]byte, unix.SizeofRtMsg, unix.SizeofRtMsg+unix.SizeofRtAttr+16)
+	body[0] = uint8(family)
+	body[1] = uint8(dst.BitLen())
+	body = binary.NativeEndian.AppendUint16(body,
+		uint16(unix.SizeofRtAttr+dst.BitLen()/8))
+	body = binary.NativeEndian.AppendUint16(body, unix.RTA_DST)
+	body = append(body, dst.AsSlice()...)
+	req := make([]byte, 0, unix.SizeofNlMsghdr+len(body))
+	req = binary.NativeEndian.AppendUint32(req,
+		uint32(unix.SizeofNlMsghdr+len(body))) // nlmsg_len
+	req = binary.NativeEndian.AppendUint16(req, unix.RTM_GETROUTE)  // nlmsg_type
+	req = binary.NativeEndian.AppendUint16(req, unix.NLM_F_REQUEST) // nlmsg_flags
+	req = binary.NativeEndian.AppendUint32(req, 1)                  // nlmsg_seq
+	req = binary.NativeEndian.AppendUint32(req, 0)                  // nlmsg_pid
+	req = append(req, body...)
+	err = unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+	})
+	if err != nil {
+		return 0, netip.Addr{}, err
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return 0, netip.Addr{}, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return 0, netip.Addr{}, err
+	}
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case unix.NLMSG_ERROR:
+			if len(m.Data) >= 4 {
+				errno := int32(binary.NativeEndian.Uint32(m.Data))
+				if errno != 0 {
+					return 0, netip.Addr{}, unix.Errno(-errno)
+				}
+			}
+		case unix.RTM_NEWROUTE:
+			attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+			if err != nil {
+				return 0, netip.Addr{}, err
+			}
+			var (
+				ifindex int
+				src     netip.Addr
+			)
+			for _, a := range attrs {
+				switch a.Attr.Type {
+				case unix.RTA_OIF:
+					if len(a.Value) == 4 {
+						ifindex = int(binary.NativeEndian.Uint32(a.Value))
+					}
+				case unix.RTA_PREFSRC:
+					src, _ = netip.AddrFromSlice(a.Value)
+				}
+			}
+			if ifindex == 0 {
+				return 0, netip.Addr{}, errors.New("route has no output interface")
+			}
+			return ifindex, src, nil
+		}
+	}
+	return 0, netip.Addr{}, fmt.Errorf("no route to %s", dst)
+}
@@ -0,0 +1,40 @@
+package corebgp
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteInterface(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("loopback interface not found: %v", err)
+	}
+	ifindex, src, err := routeInterface(netip.MustParseAddr("127.0.0.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, lo.Index, ifindex)
+	assert.Equal(t, netip.MustParseAddr("127.0.0.1"), src)
+
+	ifindex, _, err = routeInterface(netip.MustParseAddr("fe80::1%lo"))
+	assert.NoError(t, err)
+	assert.Equal(t, lo.Index, ifindex)
+}
+
+func TestInterfaceMonitor_Close(t *testing.T) {
+	m, err := NewInterfaceMonitor()
+	if !assert.NoError(t, err) {
+		return
+	}
+	downCh, stop := m.Monitor(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.1"),
+	})
+	assert.NotNil(t, downCh)
+	stop()
+	assert.NoError(t, m.Close())
+	assert.Error(t, m.Close())
+}
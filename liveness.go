@@ -0,0 +1,25 @@
+package corebgp
+
+// LivenessMonitor is implemented by an external mechanism that detects the
+// loss of connectivity to a peer faster than the hold timer, e.g. a BFD
+// (RFC5880) implementation.
+type LivenessMonitor interface {
+	// Monitor is called when the session with peer transitions to the
+	// Established state. The returned channel should be closed, or sent a
+	// value, once the peer is detected as down. stop is called when the
+	// session transitions out of the Established state and must not block.
+	Monitor(peer PeerConfig) (downCh <-chan struct{}, stop func())
+}
+
+// WithLivenessMonitor returns a PeerOption that sets a LivenessMonitor for a
+// peer. When the LivenessMonitor signals that the peer is down a Cease
+// Notification with the BFD Down subcode is sent and the FSM transitions to
+// the Idle state, subject to peer oscillation damping, rather than waiting for
+// the hold timer to expire.
+//
+// https://www.rfc-editor.org/rfc/rfc9384#section-2
+func WithLivenessMonitor(m LivenessMonitor) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.livenessMonitor = m
+	})
+}
@@ -0,0 +1,139 @@
+package corebgp_test
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+type livenessTestPlugin struct {
+	mu     sync.Mutex
+	closes int
+}
+
+func (l *livenessTestPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (l *livenessTestPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (l *livenessTestPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return nil
+}
+
+func (l *livenessTestPlugin) OnClose(corebgp.PeerConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closes++
+}
+
+func (l *livenessTestPlugin) closeCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closes
+}
+
+type testLivenessMonitor struct {
+	mu     sync.Mutex
+	downCh chan struct{}
+	stops  int
+}
+
+func (t *testLivenessMonitor) Monitor(corebgp.PeerConfig) (<-chan struct{},
+	func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.downCh = make(chan struct{})
+	return t.downCh, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.stops++
+	}
+}
+
+func (t *testLivenessMonitor) down() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.downCh == nil {
+		return false
+	}
+	close(t.downCh)
+	t.downCh = nil
+	return true
+}
+
+func TestWithLivenessMonitor(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	monitor := &testLivenessMonitor{}
+	plugin := &livenessTestPlugin{}
+	servers := make([]*corebgp.Server, 0, 2)
+	for _, s := range []struct {
+		local, remote netip.Addr
+		opts          []corebgp.PeerOption
+	}{
+		{addrA, addrB, []corebgp.PeerOption{corebgp.WithLivenessMonitor(monitor)}},
+		{addrB, addrA, []corebgp.PeerOption{corebgp.WithPassive()}},
+	} {
+		server, err := corebgp.NewServer(s.local)
+		if !assert.NoError(t, err) {
+			return
+		}
+		err = server.AddPeer(corebgp.PeerConfig{
+			RemoteAddress: s.remote,
+			LocalAS:       64512,
+			RemoteAS:      64512,
+		}, plugin, append(s.opts, corebgp.WithLocalAddress(s.local),
+			corebgp.WithIdleHoldTime(time.Millisecond*100),
+			corebgp.WithDialer(n.Dialer(s.local)))...)
+		if !assert.NoError(t, err) {
+			return
+		}
+		l, err := n.Listen(s.local)
+		if !assert.NoError(t, err) {
+			return
+		}
+		go server.Serve([]net.Listener{l})
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+	}
+
+	assert.Eventually(t, monitor.down, time.Second*5, time.Millisecond*10)
+	// both sides of the session close
+	assert.Eventually(t, func() bool {
+		return plugin.closeCount() == 2
+	}, time.Second*5, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		monitor.mu.Lock()
+		defer monitor.mu.Unlock()
+		return monitor.stops == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	var last corebgp.LastNotifications
+	assert.Eventually(t, func() bool {
+		var err error
+		last, err = servers[0].GetLastNotifications(addrB)
+		return err == nil && last.Sent != nil
+	}, time.Second*5, time.Millisecond*10)
+	if assert.NotNil(t, last.Sent) {
+		assert.Equal(t, "Cease/BFD Down", last.Sent.Notification.String())
+		assert.False(t, last.Sent.Time.IsZero())
+	}
+	assert.Nil(t, last.Received)
+	assert.Eventually(t, func() bool {
+		last, err := servers[1].GetLastNotifications(addrA)
+		return err == nil && last.Received != nil &&
+			last.Received.Notification.Subcode == corebgp.NOTIF_SUBCODE_BFD_DOWN
+	}, time.Second*5, time.Millisecond*10)
+}
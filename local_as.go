@@ -0,0 +1,46 @@
+package corebgp
+
+// LocalAS describes the AS migration behavior for a peer whose
+// PeerConfig.LocalAS, the AS presented to the peer, differs from the AS of the
+// local system as seen by its other peers (the global AS). This is commonly
+// used to migrate peers from one AS to another without having to coordinate
+// reconfiguration of the peers. corebgp does not modify the AS_PATH of
+// UPDATE messages, LocalAS is applied by Plugins when processing received
+// routes and when building routes to advertise.
+type LocalAS struct {
+	// GlobalAS is the AS of the local system.
+	GlobalAS uint32
+	// LocalAS is the AS presented to the peer.
+	LocalAS uint32
+	// NoPrepend disables prepending of LocalAS to the AS_PATH of routes
+	// received from the peer.
+	NoPrepend bool
+	// ReplaceAS causes only LocalAS, and not GlobalAS, to be prepended to the
+	// AS_PATH of routes advertised to the peer, hiding GlobalAS from it.
+	ReplaceAS bool
+}
+
+// Inbound returns the AS_PATH for a route received from the peer with path,
+// which has LocalAS prepended unless NoPrepend is set. The AS_PATH then
+// reflects the route traversing LocalAS when advertised to other peers.
+func (l LocalAS) Inbound(path ASPath) ASPath {
+	if l.NoPrepend || l.LocalAS == l.GlobalAS {
+		return path
+	}
+	return path.Prepend(l.LocalAS)
+}
+
+// Outbound returns the AS_PATH for a route advertised to the peer, where path
+// is the AS_PATH of the route as held by the local system, i.e. without
+// GlobalAS prepended. LocalAS and GlobalAS are prepended such that the peer
+// sees LocalAS as the neighboring AS, unless ReplaceAS is set in which case
+// only LocalAS is prepended.
+func (l LocalAS) Outbound(path ASPath) ASPath {
+	if l.LocalAS == l.GlobalAS {
+		return path.Prepend(l.GlobalAS)
+	}
+	if l.ReplaceAS {
+		return path.Prepend(l.LocalAS)
+	}
+	return path.Prepend(l.LocalAS, l.GlobalAS)
+}
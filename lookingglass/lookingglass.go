@@ -0,0 +1,233 @@
+// Package lookingglass provides an http.Handler exposing the state of a
+// corebgp.Server as JSON, a lightweight looking glass and health endpoint for
+// corebgp deployments.
+package lookingglass
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// LookupFunc returns a JSON-encodable representation of the routes for
+// prefix, e.g. from a RIB maintained by the application's Plugins. It should
+// return ErrNotFound if there are no routes for prefix.
+type LookupFunc func(prefix netip.Prefix) (any, error)
+
+// ErrNotFound may be returned by a LookupFunc if there are no routes for a
+// prefix.
+var ErrNotFound = errors.New("not found")
+
+// Peer is the JSON representation of a peer.
+type Peer struct {
+	RemoteAddress netip.Addr           `json:"remote_address"`
+	LocalAS       uint32               `json:"local_as"`
+	RemoteAS      uint32               `json:"remote_as"`
+	Established   bool                 `json:"established"`
+	Session       *corebgp.SessionInfo `json:"session,omitempty"`
+	Stats         corebgp.PeerStats    `json:"stats"`
+
+	LastNotificationSent     *Notification `json:"last_notification_sent,omitempty"`
+	LastNotificationReceived *Notification `json:"last_notification_received,omitempty"`
+
+	History []Session `json:"history,omitempty"`
+}
+
+// Session is the JSON representation of a corebgp.SessionRecord.
+type Session struct {
+	Established      time.Time  `json:"established"`
+	Down             *time.Time `json:"down,omitempty"`
+	Reason           string     `json:"reason,omitempty"`
+	Notification     string     `json:"notification,omitempty"`
+	NotificationSent bool       `json:"notification_sent,omitempty"`
+}
+
+func newSessions(records []corebgp.SessionRecord) []Session {
+	sessions := make([]Session, 0, len(records))
+	for _, r := range records {
+		s := Session{
+			Established:      r.Established,
+			Reason:           r.Reason,
+			NotificationSent: r.NotificationSent,
+		}
+		if !r.Down.IsZero() {
+			down := r.Down
+			s.Down = &down
+		}
+		if r.Notification != nil {
+			s.Notification = r.Notification.String()
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// Notification is the JSON representation of a corebgp.NotificationRecord.
+type Notification struct {
+	Time        time.Time `json:"time"`
+	Code        uint8     `json:"code"`
+	Subcode     uint8     `json:"subcode"`
+	Description string    `json:"description"`
+}
+
+func newNotification(r *corebgp.NotificationRecord) *Notification {
+	if r == nil {
+		return nil
+	}
+	return &Notification{
+		Time:        r.Time,
+		Code:        r.Notification.Code,
+		Subcode:     r.Notification.Subcode,
+		Description: r.Notification.String(),
+	}
+}
+
+// Health is the JSON representation of the Server's health.
+type Health struct {
+	Peers       int `json:"peers"`
+	Established int `json:"established"`
+}
+
+// Handler serves the following read-only endpoints:
+//
+//	GET /health          Health
+//	GET /peers           []Peer
+//	GET /peers/{address} Peer
+//	GET /lookup?prefix=  the value returned by the LookupFunc
+//
+// A Handler may be mounted into an existing mux with http.StripPrefix.
+type Handler struct {
+	server *corebgp.Server
+	lookup LookupFunc
+}
+
+// NewHandler returns a new Handler for server. lookup is optional, the /lookup
+// endpoint returns 404 Not Found if it is nil.
+func NewHandler(server *corebgp.Server, lookup LookupFunc) *Handler {
+	return &Handler{
+		server: server,
+		lookup: lookup,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/health":
+		h.serveHealth(w)
+	case path == "/peers":
+		writeJSON(w, http.StatusOK, h.peers())
+	case strings.HasPrefix(path, "/peers/"):
+		h.servePeer(w, strings.TrimPrefix(path, "/peers/"))
+	case path == "/lookup":
+		h.serveLookup(w, r.URL.Query().Get("prefix"))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) peer(config corebgp.PeerConfig) Peer {
+	p := Peer{
+		RemoteAddress: config.RemoteAddress,
+		LocalAS:       config.LocalAS,
+		RemoteAS:      config.RemoteAS,
+	}
+	info, err := h.server.GetSessionInfo(config.RemoteAddress)
+	if err == nil {
+		p.Established = true
+		p.Session = &info
+	}
+	p.Stats, _ = h.server.GetPeerStats(config.RemoteAddress)
+	last, _ := h.server.GetLastNotifications(config.RemoteAddress)
+	p.LastNotificationSent = newNotification(last.Sent)
+	p.LastNotificationReceived = newNotification(last.Received)
+	history, _ := h.server.GetSessionHistory(config.RemoteAddress)
+	p.History = newSessions(history)
+	return p
+}
+
+func (h *Handler) peers() []Peer {
+	configs := h.server.ListPeers()
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].RemoteAddress.Less(configs[j].RemoteAddress)
+	})
+	peers := make([]Peer, 0, len(configs))
+	for _, c := range configs {
+		peers = append(peers, h.peer(c))
+	}
+	return peers
+}
+
+func (h *Handler) serveHealth(w http.ResponseWriter) {
+	var health Health
+	for _, p := range h.peers() {
+		health.Peers++
+		if p.Established {
+			health.Established++
+		}
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+func (h *Handler) servePeer(w http.ResponseWriter, addr string) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid address")
+		return
+	}
+	config, err := h.server.GetPeer(ip)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, h.peer(config))
+}
+
+func (h *Handler) serveLookup(w http.ResponseWriter, prefix string) {
+	if h.lookup == nil {
+		writeError(w, http.StatusNotFound, "lookup not supported")
+		return
+	}
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		// allow lookups of a single address
+		var addr netip.Addr
+		addr, err = netip.ParseAddr(prefix)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid prefix")
+			return
+		}
+		p = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	v, err := h.lookup(p.Masked())
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,102 @@
+package lookingglass
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+type nopPlugin struct{}
+
+func (nopPlugin) GetCapabilities(corebgp.PeerConfig) []corebgp.Capability {
+	return nil
+}
+
+func (nopPlugin) OnOpenMessage(corebgp.PeerConfig, netip.Addr,
+	[]corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (nopPlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return nil
+}
+
+func (nopPlugin) OnClose(corebgp.PeerConfig) {}
+
+func get(t *testing.T, h http.Handler, target string, v any) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if v != nil {
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(v))
+	}
+	return rec.Code
+}
+
+func TestHandler(t *testing.T) {
+	s, err := corebgp.NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	for _, addr := range []string{"192.0.2.3", "192.0.2.2"} {
+		err = s.AddPeer(corebgp.PeerConfig{
+			RemoteAddress: netip.MustParseAddr(addr),
+			LocalAS:       64512,
+			RemoteAS:      64513,
+		}, nopPlugin{})
+		assert.NoError(t, err)
+	}
+	routes := map[netip.Prefix]string{
+		netip.MustParsePrefix("198.51.100.0/24"): "route",
+		netip.MustParsePrefix("192.0.2.1/32"):    "host",
+	}
+	h := NewHandler(s, func(p netip.Prefix) (any, error) {
+		r, ok := routes[p]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return r, nil
+	})
+
+	var health Health
+	assert.Equal(t, http.StatusOK, get(t, h, "/health", &health))
+	assert.Equal(t, Health{Peers: 2}, health)
+
+	var peers []Peer
+	assert.Equal(t, http.StatusOK, get(t, h, "/peers", &peers))
+	if assert.Len(t, peers, 2) {
+		assert.Equal(t, netip.MustParseAddr("192.0.2.2"), peers[0].RemoteAddress)
+		assert.False(t, peers[0].Established)
+		assert.Nil(t, peers[0].Session)
+		assert.Empty(t, peers[0].History)
+	}
+
+	var peer Peer
+	assert.Equal(t, http.StatusOK, get(t, h, "/peers/192.0.2.3", &peer))
+	assert.Equal(t, uint32(64513), peer.RemoteAS)
+	assert.Equal(t, http.StatusNotFound, get(t, h, "/peers/192.0.2.4", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, h, "/peers/bogus", nil))
+
+	var route string
+	assert.Equal(t, http.StatusOK,
+		get(t, h, "/lookup?prefix=198.51.100.0/24", &route))
+	assert.Equal(t, "route", route)
+	assert.Equal(t, http.StatusOK, get(t, h, "/lookup?prefix=192.0.2.1", &route))
+	assert.Equal(t, "host", route)
+	assert.Equal(t, http.StatusNotFound,
+		get(t, h, "/lookup?prefix=203.0.113.0/24", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, h, "/lookup?prefix=x", nil))
+
+	assert.Equal(t, http.StatusNotFound,
+		get(t, NewHandler(s, nil), "/lookup?prefix=192.0.2.1", nil))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/peers", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
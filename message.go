@@ -0,0 +1,301 @@
+package corebgp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// Message is a BGP message. MarshalBinary encodes the message in its wire
+// format, including the message header, and UnmarshalBinary decodes it from
+// the same. Message is implemented by *OpenMessage, *UpdateMessage,
+// *NotificationMessage and *KeepaliveMessage.
+//
+// Message, along with ReadMessage, WriteMessage and UnmarshalMessage, exposes
+// the codec used by a Server for use outside of one, e.g. in test tools and
+// scanners. Messages are neither validated against a peer's configuration nor
+// against the session state.
+type Message interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	messageType() uint8
+}
+
+// OpenMessage is an OPEN message.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.2
+type OpenMessage struct {
+	Version uint8
+	// AS is the My Autonomous System field, which is AS_TRANS if the sender's
+	// AS number does not fit in two octets, see ASN.
+	AS       uint16
+	HoldTime uint16
+	// RouterID is the BGP Identifier field, an IPv4 address.
+	RouterID     netip.Addr
+	Capabilities []Capability
+}
+
+// NewOpenMessage returns an OpenMessage for a BGP speaker with AS number asn,
+// holdTime truncated to seconds, routerID, and caps. The four-octet AS number
+// capability is included implicitly, as it is by a Server.
+func NewOpenMessage(asn uint32, holdTime time.Duration, routerID netip.Addr,
+	caps []Capability) (*OpenMessage, error) {
+	if !routerID.Is4() {
+		return nil, errors.New("router ID must be an IPv4 address")
+	}
+	o, err := newOpenMessage(asn, holdTime,
+		binary.BigEndian.Uint32(routerID.AsSlice()), caps)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenMessage{
+		Version:      o.version,
+		AS:           o.asn,
+		HoldTime:     o.holdTime,
+		RouterID:     routerID,
+		Capabilities: o.getCapabilities(),
+	}, nil
+}
+
+func (m *OpenMessage) messageType() uint8 {
+	return openMessageType
+}
+
+// ASN returns the AS number from the four-octet AS number capability, or AS if
+// the capability is absent.
+func (m *OpenMessage) ASN() uint32 {
+	return fourOctetAS(m.AS, m.Capabilities)
+}
+
+func (m *OpenMessage) MarshalBinary() ([]byte, error) {
+	if !m.RouterID.Is4() {
+		return nil, errors.New("router ID must be an IPv4 address")
+	}
+	o := &openMessage{
+		version:  m.Version,
+		asn:      m.AS,
+		holdTime: m.HoldTime,
+		bgpID:    binary.BigEndian.Uint32(m.RouterID.AsSlice()),
+	}
+	if len(m.Capabilities) > 0 {
+		o.optionalParams = []optionalParam{
+			&capabilityOptionalParam{
+				capabilities: m.Capabilities,
+			},
+		}
+	}
+	return o.encode()
+}
+
+func (m *OpenMessage) UnmarshalBinary(b []byte) error {
+	body, err := messageBody(b, openMessageType)
+	if err != nil {
+		return err
+	}
+	o := &openMessage{}
+	// capabilities reference the buffer they are decoded from
+	err = o.decode(bytes.Clone(body))
+	if err != nil {
+		return notificationOf(err)
+	}
+	*m = OpenMessage{
+		Version:      o.version,
+		AS:           o.asn,
+		HoldTime:     o.holdTime,
+		RouterID:     addrFromRouterID(o.bgpID),
+		Capabilities: o.getCapabilities(),
+	}
+	return nil
+}
+
+// UpdateMessage is an UPDATE message, excluding the message header, as passed
+// to an UpdateMessageHandler and written by an UpdateMessageWriter. It may be
+// decoded using an UpdateDecoder or UpdateIndex.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.3
+type UpdateMessage []byte
+
+func (u *UpdateMessage) messageType() uint8 {
+	return updateMessageType
+}
+
+func (u *UpdateMessage) MarshalBinary() ([]byte, error) {
+	if headerLength+len(*u) > math.MaxUint16 {
+		return nil, errors.New("update message too long")
+	}
+	return prependHeader(*u, updateMessageType), nil
+}
+
+func (u *UpdateMessage) UnmarshalBinary(b []byte) error {
+	body, err := messageBody(b, updateMessageType)
+	if err != nil {
+		return err
+	}
+	if len(body) < 4 {
+		// withdrawn routes length + total path attribute length
+		return badMessageLength(b)
+	}
+	*u = bytes.Clone(body)
+	return nil
+}
+
+// NotificationMessage is a NOTIFICATION message. It may be converted to and
+// from a Notification.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.5
+type NotificationMessage Notification
+
+func (n *NotificationMessage) messageType() uint8 {
+	return notificationMessageType
+}
+
+func (n *NotificationMessage) MarshalBinary() ([]byte, error) {
+	return (*Notification)(n).encode()
+}
+
+func (n *NotificationMessage) UnmarshalBinary(b []byte) error {
+	body, err := messageBody(b, notificationMessageType)
+	if err != nil {
+		return err
+	}
+	if len(body) < 2 {
+		return badMessageLength(b)
+	}
+	*n = NotificationMessage{}
+	return (*Notification)(n).decode(body)
+}
+
+// KeepaliveMessage is a KEEPALIVE message.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.4
+type KeepaliveMessage struct{}
+
+func (k *KeepaliveMessage) messageType() uint8 {
+	return keepAliveMessageType
+}
+
+func (k *KeepaliveMessage) MarshalBinary() ([]byte, error) {
+	return keepAliveMessage{}.encode()
+}
+
+func (k *KeepaliveMessage) UnmarshalBinary(b []byte) error {
+	body, err := messageBody(b, keepAliveMessageType)
+	if err != nil {
+		return err
+	}
+	if len(body) != 0 {
+		return badMessageLength(b)
+	}
+	return nil
+}
+
+// UnmarshalMessage decodes the message b, including its header, to a Message
+// of the type indicated by its header. Errors that would be reported to the
+// sender of b with a NOTIFICATION message by a Server are of type
+// *Notification.
+func UnmarshalMessage(b []byte) (Message, error) {
+	if len(b) < headerLength {
+		return nil, badMessageLength(b)
+	}
+	var m Message
+	switch b[18] {
+	case openMessageType:
+		m = &OpenMessage{}
+	case updateMessageType:
+		m = &UpdateMessage{}
+	case notificationMessageType:
+		m = &NotificationMessage{}
+	case keepAliveMessageType:
+		m = &KeepaliveMessage{}
+	default:
+		return nil, newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
+			NOTIF_SUBCODE_BAD_MESSAGE_TYPE, []byte{b[18]})
+	}
+	err := m.UnmarshalBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadMessage reads a message from r and decodes it as by UnmarshalMessage.
+// Messages longer than 4096 octets are rejected unless extended is true, in
+// which case UPDATE and NOTIFICATION messages of up to 65535 octets are
+// accepted as per the BGP Extended Message Capability (RFC8654).
+func ReadMessage(r io.Reader, extended bool) (Message, error) {
+	limits := MessageLimits{}.lengthLimits(extended)
+	b, err := readRawMessage(r, limits)
+	if err != nil {
+		return nil, notificationOf(err)
+	}
+	return UnmarshalMessage(b)
+}
+
+// WriteMessage encodes m and writes it to w.
+func WriteMessage(w io.Writer, m Message) error {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// messageBody validates the header of the message b, which must be of type t,
+// and returns the message body.
+func messageBody(b []byte, t uint8) ([]byte, error) {
+	if len(b) < headerLength {
+		return nil, badMessageLength(b)
+	}
+	_, err := validateHeader(b, lengthLimits{max: math.MaxUint16})
+	if err != nil {
+		return nil, notificationOf(err)
+	}
+	if int(binary.BigEndian.Uint16(b[16:18])) != len(b) {
+		return nil, badMessageLength(b)
+	}
+	if b[18] != t {
+		return nil, newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
+			NOTIF_SUBCODE_BAD_MESSAGE_TYPE, []byte{b[18]})
+	}
+	return b[headerLength:], nil
+}
+
+// badMessageLength returns a Bad Message Length Notification for the message
+// b, which may be truncated.
+func badMessageLength(b []byte) *Notification {
+	// https://www.rfc-editor.org/rfc/rfc4271#section-6.1
+	// The Data field MUST contain the erroneous Length field.
+	var data []byte
+	if len(b) >= 18 {
+		data = append(data, b[16:18]...)
+	}
+	return newNotification(NOTIF_CODE_MESSAGE_HEADER_ERR,
+		NOTIF_SUBCODE_BAD_MESSAGE_LEN, data)
+}
+
+// notificationOf returns the Notification of err if it is a notificationError,
+// otherwise err.
+func notificationOf(err error) error {
+	var nerr *notificationError
+	if errors.As(err, &nerr) {
+		return nerr.notification
+	}
+	return err
+}
+
+// fourOctetAS returns the AS number from the four-octet AS number capability
+// in caps, or as if the capability is absent.
+func fourOctetAS(as uint16, caps []Capability) uint32 {
+	for _, c := range caps {
+		if c.Code == CAP_FOUR_OCTET_AS && len(c.Value) == 4 {
+			return binary.BigEndian.Uint32(c.Value)
+		}
+	}
+	return uint32(as)
+}
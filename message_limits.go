@@ -0,0 +1,241 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// MessageLimits bounds the messages accepted from a peer in order to contain
+// the resources consumed by a misbehaving or hostile peer. A zero value field
+// applies the default.
+type MessageLimits struct {
+	// MaxMessageLength is the maximum length, including the header, of a
+	// message received from the peer when the BGP Extended Message Capability
+	// has not been advertised to it. It also applies to OPEN and KEEPALIVE
+	// messages regardless. It must be between 19 and 4096, the default is
+	// 4096.
+	//
+	// https://www.rfc-editor.org/rfc/rfc4271#section-6.1
+	// If the Length field of the message header is less than 19 or greater
+	// than 4096, or if the Length field of an OPEN message is less than the
+	// minimum length of the OPEN message, or if the Length field of an UPDATE
+	// message is less than the minimum length of the UPDATE message, or if
+	// the Length field of a KEEPALIVE message is not equal to 19, or if the
+	// Length field of a NOTIFICATION message is less than the minimum length
+	// of the NOTIFICATION message, then the Error Subcode MUST be set to Bad
+	// Message Length.
+	MaxMessageLength int
+
+	// MaxExtendedMessageLength is the maximum length, including the header,
+	// of a message other than OPEN and KEEPALIVE received from the peer when
+	// the BGP Extended Message Capability has been advertised to it. It must
+	// be between MaxMessageLength and 65535, the default is 65535. A value
+	// below 65535 deviates from RFC8654, which requires a speaker advertising
+	// the capability to accept messages up to 65535 octets.
+	//
+	// https://www.rfc-editor.org/rfc/rfc8654#section-4
+	// An implementation that advertises the BGP Extended Message Capability
+	// MUST be capable of receiving a message with a length up to and
+	// including 65,535 octets.
+	MaxExtendedMessageLength int
+
+	// MaxWithdrawn is the maximum number of withdrawn routes in an UPDATE
+	// message. It counts the routes in the Withdrawn Routes field and in an
+	// MP_UNREACH_NLRI path attribute for IPv4 or IPv6 unicast. The default, 0,
+	// is unlimited.
+	MaxWithdrawn int
+
+	// MaxNLRI is the maximum number of reachable routes in an UPDATE message.
+	// It counts the routes in the NLRI field and in an MP_REACH_NLRI path
+	// attribute for IPv4 or IPv6 unicast. The default, 0, is unlimited.
+	MaxNLRI int
+
+	// MaxPathAttributesLength is the maximum Total Path Attribute Length of an
+	// UPDATE message. The default, 0, is unlimited.
+	MaxPathAttributesLength int
+}
+
+func (l MessageLimits) validate() error {
+	if l.MaxMessageLength != 0 && (l.MaxMessageLength < headerLength ||
+		l.MaxMessageLength > maxMessageLength) {
+		return errors.New("max message length must be between 19 and 4096")
+	}
+	if l.MaxExtendedMessageLength != 0 &&
+		(l.MaxExtendedMessageLength < l.lengthLimits(false).max ||
+			l.MaxExtendedMessageLength > math.MaxUint16) {
+		return errors.New("max extended message length must be between max " +
+			"message length and 65535")
+	}
+	if l.MaxWithdrawn < 0 || l.MaxNLRI < 0 || l.MaxPathAttributesLength < 0 {
+		return errors.New("message limits must be >= 0")
+	}
+	return nil
+}
+
+// WithMessageLimits returns a PeerOption that sets the MessageLimits applied
+// to messages received from the peer. A message exceeding MaxMessageLength or
+// MaxExtendedMessageLength results in a NOTIFICATION with the Error Code
+// Message Header Error and the Error Subcode Bad Message Length. An UPDATE
+// message exceeding MaxWithdrawn, MaxNLRI, or MaxPathAttributesLength is not
+// malformed, it results in a NOTIFICATION with the Error Code Cease and the
+// Error Subcode Out of Resources.
+func WithMessageLimits(limits MessageLimits) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.messageLimits = limits
+	})
+}
+
+// lengthLimits are the maximum message lengths accepted on a connection.
+type lengthLimits struct {
+	max int
+	// maxExtended is 0 if the BGP Extended Message Capability was not
+	// advertised
+	maxExtended int
+}
+
+var defaultLengthLimits = lengthLimits{max: maxMessageLength}
+
+// lengthLimits returns the lengthLimits for l. extended is true if the BGP
+// Extended Message Capability was advertised to the peer.
+func (l MessageLimits) lengthLimits(extended bool) lengthLimits {
+	limits := defaultLengthLimits
+	if l.MaxMessageLength != 0 {
+		limits.max = l.MaxMessageLength
+	}
+	if extended {
+		limits.maxExtended = math.MaxUint16
+		if l.MaxExtendedMessageLength != 0 {
+			limits.maxExtended = l.MaxExtendedMessageLength
+		}
+	}
+	return limits
+}
+
+// forType returns the maximum length of a message of msgType.
+func (l lengthLimits) forType(msgType uint8) int {
+	// https://www.rfc-editor.org/rfc/rfc8654#section-4
+	// The BGP Extended Message Capability applies to all messages except for
+	// OPEN and KEEPALIVE messages. These exceptions reduce the complexity of
+	// providing backward compatibility.
+	if l.maxExtended == 0 || msgType == openMessageType ||
+		msgType == keepAliveMessageType {
+		return l.max
+	}
+	return l.maxExtended
+}
+
+// addPathRx indicates the unicast families for which multiple paths may be
+// received, i.e. NLRI are preceded by a Path Identifier.
+type addPathRx struct {
+	ipv4, ipv6 bool
+}
+
+func newAddPathRx(local, remote []Capability) addPathRx {
+	var rx addPathRx
+	s := newSessionInfo(0, 0, 0, local, remote)
+	for _, t := range s.AddPath {
+		if !t.Rx || t.SAFI != SAFI_UNICAST {
+			continue
+		}
+		switch t.AFI {
+		case AFI_IPV4:
+			rx.ipv4 = true
+		case AFI_IPV6:
+			rx.ipv6 = true
+		}
+	}
+	return rx
+}
+
+func (a addPathRx) forAFI(afi uint16) bool {
+	if afi == AFI_IPV6 {
+		return a.ipv6
+	}
+	return a.ipv4
+}
+
+// countPrefixes returns the number of prefixes encoded in b as in the NLRI
+// field of an UPDATE message. If addPath is true each prefix is preceded by a
+// 4 octet Path Identifier. Counting stops at the first malformed prefix,
+// which is left to be handled by the UpdateMessageHandler.
+func countPrefixes(b []byte, addPath bool) int {
+	n := 0
+	for len(b) > 0 {
+		if addPath {
+			if len(b) < 5 {
+				break
+			}
+			b = b[4:]
+		}
+		l := 1 + (int(b[0])+7)/8
+		if len(b) < l {
+			break
+		}
+		b = b[l:]
+		n++
+	}
+	return n
+}
+
+// countMPPrefixes returns the number of IPv4 or IPv6 unicast prefixes in b,
+// the data of an MP_REACH_NLRI or MP_UNREACH_NLRI path attribute.
+func countMPPrefixes(b []byte, reach bool, rx addPathRx) int {
+	if len(b) < 3 {
+		return 0
+	}
+	afi, safi := binary.BigEndian.Uint16(b), b[2]
+	if (afi != AFI_IPV4 && afi != AFI_IPV6) || safi != SAFI_UNICAST {
+		return 0
+	}
+	b = b[3:]
+	if reach {
+		// next hop length, next hop, and reserved octet
+		if len(b) < 1 || len(b) < 2+int(b[0]) {
+			return 0
+		}
+		b = b[2+int(b[0]):]
+	}
+	return countPrefixes(b, rx.forAFI(afi))
+}
+
+// checkUpdateLimits returns a notificationError if the UPDATE message b
+// exceeds the MaxWithdrawn, MaxNLRI, or MaxPathAttributesLength of l.
+// Malformed messages are left to be handled by the UpdateMessageHandler.
+func checkUpdateLimits(b []byte, l MessageLimits, rx addPathRx) error {
+	if l.MaxWithdrawn == 0 && l.MaxNLRI == 0 && l.MaxPathAttributesLength == 0 {
+		return nil
+	}
+	var x UpdateIndex
+	if x.Reset(b) != nil {
+		return nil
+	}
+	exceeded := func() error {
+		n := newNotification(NOTIF_CODE_CEASE, NOTIF_SUBCODE_OUT_OF_RESOURCES,
+			nil)
+		return newNotificationError(n, true)
+	}
+	if l.MaxPathAttributesLength > 0 &&
+		len(b)-4-len(x.Withdrawn())-len(x.NLRI()) > l.MaxPathAttributesLength {
+		return exceeded()
+	}
+	if l.MaxWithdrawn > 0 {
+		n := countPrefixes(x.Withdrawn(), rx.ipv4)
+		if _, data, ok := x.Attr(PATH_ATTR_MP_UNREACH_NLRI); ok {
+			n += countMPPrefixes(data, false, rx)
+		}
+		if n > l.MaxWithdrawn {
+			return exceeded()
+		}
+	}
+	if l.MaxNLRI > 0 {
+		n := countPrefixes(x.NLRI(), rx.ipv4)
+		if _, data, ok := x.Attr(PATH_ATTR_MP_REACH_NLRI); ok {
+			n += countMPPrefixes(data, true, rx)
+		}
+		if n > l.MaxNLRI {
+			return exceeded()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package corebgp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageLimits_validate(t *testing.T) {
+	for _, tc := range []struct {
+		limits  MessageLimits
+		wantErr bool
+	}{
+		{MessageLimits{}, false},
+		{MessageLimits{MaxMessageLength: 19}, false},
+		{MessageLimits{MaxMessageLength: 18}, true},
+		{MessageLimits{MaxMessageLength: 4097}, true},
+		{MessageLimits{MaxExtendedMessageLength: 8192}, false},
+		{MessageLimits{MaxExtendedMessageLength: 4095}, true},
+		{MessageLimits{MaxMessageLength: 1024,
+			MaxExtendedMessageLength: 1024}, false},
+		{MessageLimits{MaxExtendedMessageLength: 65536}, true},
+		{MessageLimits{MaxNLRI: -1}, true},
+	} {
+		err := tc.limits.validate()
+		if tc.wantErr {
+			assert.Error(t, err, "%+v", tc.limits)
+		} else {
+			assert.NoError(t, err, "%+v", tc.limits)
+		}
+	}
+}
+
+func TestValidateHeader_lengthLimits(t *testing.T) {
+	update := prependHeader(make([]byte, 5000), updateMessageType)
+	open := prependHeader(make([]byte, 5000), openMessageType)
+
+	extended := MessageLimits{}.lengthLimits(true)
+	bodyLen, err := validateHeader(update, extended)
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, bodyLen)
+
+	for _, tc := range []struct {
+		header  []byte
+		lengths lengthLimits
+	}{
+		{update, defaultLengthLimits},
+		{open, extended},
+		{update, MessageLimits{MaxExtendedMessageLength: 4096}.lengthLimits(true)},
+		{prependHeader(make([]byte, 100), updateMessageType),
+			MessageLimits{MaxMessageLength: 100}.lengthLimits(false)},
+	} {
+		_, err = validateHeader(tc.header, tc.lengths)
+		var nerr *notificationError
+		if assert.True(t, errors.As(err, &nerr)) {
+			assert.Equal(t, NOTIF_SUBCODE_BAD_MESSAGE_LEN,
+				nerr.notification.Subcode)
+			assert.Equal(t, tc.header[16:18], nerr.notification.Data)
+		}
+	}
+}
+
+func TestReadMessage_extended(t *testing.T) {
+	b := prependHeader(make([]byte, 5000), updateMessageType)
+	extended := MessageLimits{}.lengthLimits(true)
+	for _, pooled := range []bool{false, true} {
+		m, err := readMessage(bytes.NewReader(b), extended, pooled)
+		if assert.NoError(t, err) {
+			assert.Len(t, m, 5000)
+		}
+	}
+	raw, err := readRawMessage(bytes.NewReader(b), extended)
+	assert.NoError(t, err)
+	assert.Equal(t, b, raw)
+}
+
+func TestCheckUpdateLimits(t *testing.T) {
+	// 2 withdrawn, 2 path attribute octets, 3 NLRI
+	b := []byte{0, 4, 8, 10, 8, 11, 0, 3, 0x40, 0x01, 0x00, 8, 10, 8, 11,
+		16, 172, 16}
+	// the same NLRI with ADD-PATH Path Identifiers
+	addPath := []byte{0, 0, 0, 3, 0x40, 0x01, 0x00, 0, 0, 0, 1, 8, 10, 0, 0,
+		0, 2, 8, 10, 0, 0, 0, 3, 8, 10}
+	// MP_REACH_NLRI for IPv6 unicast with 2 NLRI
+	mpReach := []byte{0, 0, 0, 32, 0x80, 14, 29, 0, 2, 1, 16,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+		32, 0x20, 0x01, 0x0d, 0xb8, 16, 0x20, 0x01}
+
+	for _, tc := range []struct {
+		b       []byte
+		limits  MessageLimits
+		rx      addPathRx
+		wantErr bool
+	}{
+		{b, MessageLimits{}, addPathRx{}, false},
+		{b, MessageLimits{MaxWithdrawn: 2, MaxNLRI: 3,
+			MaxPathAttributesLength: 3}, addPathRx{}, false},
+		{b, MessageLimits{MaxWithdrawn: 1}, addPathRx{}, true},
+		{b, MessageLimits{MaxNLRI: 2}, addPathRx{}, true},
+		{b, MessageLimits{MaxPathAttributesLength: 2}, addPathRx{}, true},
+		{addPath, MessageLimits{MaxNLRI: 3}, addPathRx{ipv4: true}, false},
+		{addPath, MessageLimits{MaxNLRI: 2}, addPathRx{ipv4: true}, true},
+		{mpReach, MessageLimits{MaxNLRI: 2}, addPathRx{}, false},
+		{mpReach, MessageLimits{MaxNLRI: 1}, addPathRx{}, true},
+		// malformed messages are left to the UpdateMessageHandler
+		{[]byte{0, 9}, MessageLimits{MaxNLRI: 1}, addPathRx{}, false},
+	} {
+		err := checkUpdateLimits(tc.b, tc.limits, tc.rx)
+		if !tc.wantErr {
+			assert.NoError(t, err, "%+v", tc.limits)
+			continue
+		}
+		var nerr *notificationError
+		if assert.True(t, errors.As(err, &nerr), "%+v", tc.limits) {
+			assert.Equal(t, NOTIF_CODE_CEASE, nerr.notification.Code)
+			assert.Equal(t, NOTIF_SUBCODE_OUT_OF_RESOURCES,
+				nerr.notification.Subcode)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package corebgp_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_roundTrip(t *testing.T) {
+	open, err := corebgp.NewOpenMessage(4200000000, time.Second*90,
+		netip.MustParseAddr("192.0.2.1"), []corebgp.Capability{
+			corebgp.NewMPExtensionsCapability(corebgp.AFI_IPV4,
+				corebgp.SAFI_UNICAST),
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint16(23456), open.AS)
+	assert.Equal(t, uint32(4200000000), open.ASN())
+	update := corebgp.UpdateMessage{0, 0, 0, 0, 24, 198, 51, 100}
+	tests := []struct {
+		name string
+		m    corebgp.Message
+		len  int
+	}{
+		{"open", open, 43},
+		{"update", &update, 27},
+		{"notification", (*corebgp.NotificationMessage)(
+			corebgp.NewAdminShutdownNotification("maintenance")), 33},
+		{"keepalive", &corebgp.KeepaliveMessage{}, 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if !assert.NoError(t, corebgp.WriteMessage(&buf, tt.m)) {
+				return
+			}
+			assert.Equal(t, tt.len, buf.Len())
+			got, err := corebgp.ReadMessage(&buf, false)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.m, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMessage_errors(t *testing.T) {
+	keepalive, _ := (&corebgp.KeepaliveMessage{}).MarshalBinary()
+	marker := bytes.Repeat([]byte{0xff}, 16)
+	tests := []struct {
+		name    string
+		b       []byte
+		subcode uint8
+	}{
+		{"short", keepalive[:18], corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN},
+		{"not synchronized", append([]byte{0}, keepalive[1:]...),
+			corebgp.NOTIF_SUBCODE_CONN_NOT_SYNCHRONIZED},
+		{"bad type", append(append([]byte{}, marker...), 0, 19, 9),
+			corebgp.NOTIF_SUBCODE_BAD_MESSAGE_TYPE},
+		{"length mismatch", append(append([]byte{}, keepalive...), 0),
+			corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN},
+		{"keepalive with body", append(append([]byte{}, marker...), 0, 20, 4,
+			0), corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN},
+		{"short update", append(append([]byte{}, marker...), 0, 21, 2, 0, 0),
+			corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := corebgp.UnmarshalMessage(tt.b)
+			var n *corebgp.Notification
+			if assert.ErrorAs(t, err, &n) {
+				assert.Equal(t, corebgp.NOTIF_CODE_MESSAGE_HEADER_ERR, n.Code)
+				assert.Equal(t, tt.subcode, n.Subcode)
+			}
+		})
+	}
+}
+
+func TestReadMessage_extended(t *testing.T) {
+	update := make(corebgp.UpdateMessage, 5000)
+	b, err := update.MarshalBinary()
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = corebgp.ReadMessage(bytes.NewReader(b), false)
+	var n *corebgp.Notification
+	if assert.ErrorAs(t, err, &n) {
+		assert.Equal(t, corebgp.NOTIF_SUBCODE_BAD_MESSAGE_LEN, n.Subcode)
+	}
+	m, err := corebgp.ReadMessage(bytes.NewReader(b), true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &update, m)
+	}
+}
+
+// TestReadMessage_server establishes a session with a Server using only
+// ReadMessage and WriteMessage.
+func TestReadMessage_server(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = server.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, &livenessTestPlugin{}, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	go server.Serve([]net.Listener{l})
+	t.Cleanup(server.Close)
+
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	open, err := corebgp.NewOpenMessage(64513, time.Second*90, addrB, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, corebgp.WriteMessage(conn, open))
+	m, err := corebgp.ReadMessage(conn, false)
+	if !assert.NoError(t, err) || !assert.IsType(t, &corebgp.OpenMessage{}, m) {
+		return
+	}
+	assert.Equal(t, uint32(64512), m.(*corebgp.OpenMessage).ASN())
+	assert.Equal(t, addrA, m.(*corebgp.OpenMessage).RouterID)
+	m, err = corebgp.ReadMessage(conn, false)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &corebgp.KeepaliveMessage{}, m)
+	}
+	assert.NoError(t, corebgp.WriteMessage(conn, &corebgp.KeepaliveMessage{}))
+	assert.Eventually(t, func() bool {
+		_, err := server.GetSessionInfo(addrB)
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+
+	assert.NoError(t, server.DeletePeer(addrB))
+	m, err = corebgp.ReadMessage(conn, false)
+	if assert.NoError(t, err) &&
+		assert.IsType(t, &corebgp.NotificationMessage{}, m) {
+		assert.Equal(t, corebgp.NOTIF_CODE_CEASE,
+			m.(*corebgp.NotificationMessage).Code)
+	}
+}
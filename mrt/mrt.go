@@ -0,0 +1,181 @@
+// Package mrt reads and writes routing information in the Multi-Threaded
+// Routing Toolkit (MRT) export format, allowing collectors built on corebgp to
+// publish RouteViews-compatible table dumps.
+//
+// https://www.rfc-editor.org/rfc/rfc6396
+package mrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// MRT types and their subtypes.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-4
+const (
+	TypeTableDumpV2 uint16 = 13
+	TypeBGP4MP      uint16 = 16
+	TypeBGP4MPET    uint16 = 17
+
+	SubtypePeerIndexTable uint16 = 1
+	SubtypeRIBIPv4Unicast uint16 = 2
+	SubtypeRIBIPv6Unicast uint16 = 4
+
+	SubtypeBGP4MPMessage         uint16 = 1
+	SubtypeBGP4MPMessageAS4      uint16 = 4
+	SubtypeBGP4MPMessageLocal    uint16 = 6
+	SubtypeBGP4MPMessageAS4Local uint16 = 7
+)
+
+const (
+	peerTypeIPv6 = 0x01
+	peerTypeAS4  = 0x02
+)
+
+// Peer is an entry of the PEER_INDEX_TABLE. RIBEntry.PeerIndex refers to the
+// index of a Peer in the slice passed to NewTableDumpV2Writer.
+type Peer struct {
+	// BGPID is the BGP identifier of the peer.
+	BGPID netip.Addr
+	// Address is the IP address of the peer.
+	Address netip.Addr
+	// AS is the autonomous system number of the peer.
+	AS uint32
+}
+
+// RIBEntry is a route for a prefix received from a peer.
+type RIBEntry struct {
+	// PeerIndex is the index of the peer in the PEER_INDEX_TABLE.
+	PeerIndex uint16
+
+	// OriginatedTime is the time the route was received.
+	OriginatedTime time.Time
+
+	// Attributes are the BGP path attributes of the route, encoded as in an
+	// UPDATE message.
+	//
+	// https://www.rfc-editor.org/rfc/rfc6396#section-4.3.4
+	// The BGP Attribute field contains the BGP attribute information for
+	// the RIB Entry.  The AS_PATH attribute MUST be encoded as 4-byte AS
+	// numbers [...] There is one exception to the encoding of BGP
+	// attributes for the BGP MP_REACH_NLRI attribute (BGP Type Code 14).
+	// Since the AFI, SAFI, and NLRI information is already encoded in the
+	// RIB Entry Header or RIB_GENERIC Entry Header, only the Next Hop
+	// Address Length and Next Hop Address fields are included.
+	Attributes []byte
+}
+
+// TableDumpV2Writer writes a TABLE_DUMP_V2 snapshot.
+type TableDumpV2Writer struct {
+	w         io.Writer
+	timestamp uint32
+	peers     int
+	seq       uint32
+}
+
+// NewTableDumpV2Writer writes a PEER_INDEX_TABLE for peers to w and returns a
+// TableDumpV2Writer for writing the RIB entries of the snapshot. All records
+// carry timestamp, the time of the snapshot. collectorID is the BGP
+// identifier of the collector and viewName is optional.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-4.3.1
+func NewTableDumpV2Writer(w io.Writer, timestamp time.Time,
+	collectorID netip.Addr, viewName string,
+	peers []Peer) (*TableDumpV2Writer, error) {
+	if !collectorID.Is4() {
+		return nil, errors.New("collector ID must be an IPv4 address")
+	}
+	if len(viewName) > math.MaxUint16 {
+		return nil, errors.New("view name too long")
+	}
+	if len(peers) > math.MaxUint16 {
+		return nil, errors.New("too many peers")
+	}
+	t := &TableDumpV2Writer{
+		w:         w,
+		timestamp: uint32(timestamp.Unix()),
+		peers:     len(peers),
+	}
+	b := collectorID.AsSlice()
+	b = binary.BigEndian.AppendUint16(b, uint16(len(viewName)))
+	b = append(b, viewName...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(peers)))
+	for i, p := range peers {
+		if !p.BGPID.Is4() || !p.Address.IsValid() {
+			return nil, fmt.Errorf("invalid peer at index %d", i)
+		}
+		// always encode the peer AS as 4 bytes as AS_PATHs are 4-byte
+		peerType := byte(peerTypeAS4)
+		if p.Address.Is6() {
+			peerType |= peerTypeIPv6
+		}
+		b = append(b, peerType)
+		b = append(b, p.BGPID.AsSlice()...)
+		b = append(b, p.Address.AsSlice()...)
+		b = binary.BigEndian.AppendUint32(b, p.AS)
+	}
+	err := t.writeRecord(SubtypePeerIndexTable, b)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *TableDumpV2Writer) writeRecord(subtype uint16, body []byte) error {
+	// https://www.rfc-editor.org/rfc/rfc6396#section-2
+	b := make([]byte, 0, 12+len(body))
+	b = binary.BigEndian.AppendUint32(b, t.timestamp)
+	b = binary.BigEndian.AppendUint16(b, TypeTableDumpV2)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+	b = append(b, body...)
+	_, err := t.w.Write(b)
+	return err
+}
+
+// WriteRIB writes a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record containing
+// entries for prefix. Records are assigned incrementing sequence numbers.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-4.3.2
+func (t *TableDumpV2Writer) WriteRIB(prefix netip.Prefix,
+	entries []RIBEntry) error {
+	if !prefix.IsValid() {
+		return errors.New("invalid prefix")
+	}
+	if len(entries) > math.MaxUint16 {
+		return errors.New("too many entries")
+	}
+	prefix = prefix.Masked()
+	subtype := SubtypeRIBIPv4Unicast
+	if prefix.Addr().Is6() {
+		subtype = SubtypeRIBIPv6Unicast
+	}
+	b := binary.BigEndian.AppendUint32(nil, t.seq)
+	b = append(b, uint8(prefix.Bits()))
+	b = append(b, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(entries)))
+	for _, e := range entries {
+		if int(e.PeerIndex) >= t.peers {
+			return fmt.Errorf("invalid peer index: %d", e.PeerIndex)
+		}
+		if len(e.Attributes) > math.MaxUint16 {
+			return errors.New("attributes too long")
+		}
+		b = binary.BigEndian.AppendUint16(b, e.PeerIndex)
+		b = binary.BigEndian.AppendUint32(b, uint32(e.OriginatedTime.Unix()))
+		b = binary.BigEndian.AppendUint16(b, uint16(len(e.Attributes)))
+		b = append(b, e.Attributes...)
+	}
+	err := t.writeRecord(subtype, b)
+	if err != nil {
+		return err
+	}
+	t.seq++
+	return nil
+}
@@ -0,0 +1,76 @@
+package mrt
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableDumpV2Writer(t *testing.T) {
+	var buf bytes.Buffer
+	ts := time.Unix(0x01020304, 0)
+	w, err := NewTableDumpV2Writer(&buf, ts, netip.MustParseAddr("192.0.2.1"),
+		"v", []Peer{
+			{
+				BGPID:   netip.MustParseAddr("192.0.2.2"),
+				Address: netip.MustParseAddr("192.0.2.2"),
+				AS:      64512,
+			},
+			{
+				BGPID:   netip.MustParseAddr("192.0.2.3"),
+				Address: netip.MustParseAddr("2001:db8::3"),
+				AS:      4200000000,
+			},
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+	want := []byte{
+		1, 2, 3, 4, // timestamp
+		0, 13, 0, 1, // TABLE_DUMP_V2, PEER_INDEX_TABLE
+		0, 0, 0, 47, // length
+		192, 0, 2, 1, // collector BGP ID
+		0, 1, 'v', // view name
+		0, 2, // peer count
+		0x02, 192, 0, 2, 2, 192, 0, 2, 2, 0, 0, 0xfc, 0x00,
+		0x03, 192, 0, 2, 3, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 3, 0xfa, 0x56, 0xea, 0x00,
+	}
+	assert.Equal(t, want, buf.Bytes())
+	buf.Reset()
+
+	// ORIGIN IGP
+	attrs := []byte{0x40, 1, 1, 0}
+	err = w.WriteRIB(netip.MustParsePrefix("198.51.100.0/22"), []RIBEntry{
+		{PeerIndex: 1, OriginatedTime: ts, Attributes: attrs},
+	})
+	assert.NoError(t, err)
+	want = []byte{
+		1, 2, 3, 4,
+		0, 13, 0, 2, // RIB_IPV4_UNICAST
+		0, 0, 0, 22,
+		0, 0, 0, 0, // sequence number
+		22, 198, 51, 100, // prefix
+		0, 1, // entry count
+		0, 1, 1, 2, 3, 4, 0, 4, 0x40, 1, 1, 0,
+	}
+	assert.Equal(t, want, buf.Bytes())
+	buf.Reset()
+
+	err = w.WriteRIB(netip.MustParsePrefix("2001:db8::/32"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		1, 2, 3, 4,
+		0, 13, 0, 4, // RIB_IPV6_UNICAST
+		0, 0, 0, 11,
+		0, 0, 0, 1,
+		32, 0x20, 0x01, 0x0d, 0xb8,
+		0, 0,
+	}, buf.Bytes())
+
+	assert.Error(t, w.WriteRIB(netip.MustParsePrefix("192.0.2.0/24"),
+		[]RIBEntry{{PeerIndex: 2}}))
+}
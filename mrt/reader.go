@@ -0,0 +1,154 @@
+package mrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// maxRecordLen bounds the length of records read by a Reader.
+const maxRecordLen = 1 << 24
+
+// Record is an MRT record.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-2
+type Record struct {
+	Timestamp time.Time
+	Type      uint16
+	Subtype   uint16
+	// Message is the message field of the record. The microsecond timestamp
+	// of _ET types is not included, it is reflected in Timestamp.
+	Message []byte
+}
+
+// Reader reads MRT records.
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next returns the next record. It returns io.EOF when there are no more
+// records, and io.ErrUnexpectedEOF if a record is truncated.
+func (r *Reader) Next() (Record, error) {
+	var header [12]byte
+	_, err := io.ReadFull(r.r, header[:])
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint32(header[:])), 0),
+		Type:      binary.BigEndian.Uint16(header[4:]),
+		Subtype:   binary.BigEndian.Uint16(header[6:]),
+	}
+	l := binary.BigEndian.Uint32(header[8:])
+	if l > maxRecordLen {
+		return Record{}, errors.New("record too long")
+	}
+	rec.Message = make([]byte, l)
+	_, err = io.ReadFull(r.r, rec.Message)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	if rec.Type == TypeBGP4MPET {
+		// https://www.rfc-editor.org/rfc/rfc6396#section-3
+		// The Length field does include the length of the Microsecond
+		// Timestamp field.
+		if len(rec.Message) < 4 {
+			return Record{}, errors.New("missing microsecond timestamp")
+		}
+		us := binary.BigEndian.Uint32(rec.Message)
+		rec.Timestamp = rec.Timestamp.Add(time.Duration(us) * time.Microsecond)
+		rec.Message = rec.Message[4:]
+	}
+	return rec, nil
+}
+
+// RIB decodes a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-4.3.2
+func (r Record) RIB() (netip.Prefix, []RIBEntry, error) {
+	if r.Type != TypeTableDumpV2 || (r.Subtype != SubtypeRIBIPv4Unicast &&
+		r.Subtype != SubtypeRIBIPv6Unicast) {
+		return netip.Prefix{}, nil, errors.New("not a RIB unicast record")
+	}
+	malformed := errors.New("malformed RIB record")
+	b := r.Message
+	if len(b) < 5 {
+		return netip.Prefix{}, nil, malformed
+	}
+	bits := int(b[4])
+	b = b[5:]
+	addr := make([]byte, 4)
+	if r.Subtype == SubtypeRIBIPv6Unicast {
+		addr = make([]byte, 16)
+	}
+	n := (bits + 7) / 8
+	if bits > len(addr)*8 || len(b) < n+2 {
+		return netip.Prefix{}, nil, malformed
+	}
+	copy(addr, b[:n])
+	a, _ := netip.AddrFromSlice(addr)
+	prefix := netip.PrefixFrom(a, bits)
+	count := int(binary.BigEndian.Uint16(b[n:]))
+	b = b[n+2:]
+	entries := make([]RIBEntry, 0, count)
+	for i := 0; i < count; i++ {
+		if len(b) < 8 {
+			return netip.Prefix{}, nil, malformed
+		}
+		attrLen := int(binary.BigEndian.Uint16(b[6:]))
+		if len(b) < 8+attrLen {
+			return netip.Prefix{}, nil, malformed
+		}
+		entries = append(entries, RIBEntry{
+			PeerIndex: binary.BigEndian.Uint16(b),
+			OriginatedTime: time.Unix(
+				int64(binary.BigEndian.Uint32(b[2:])), 0),
+			Attributes: b[8 : 8+attrLen],
+		})
+		b = b[8+attrLen:]
+	}
+	return prefix, entries, nil
+}
+
+// BGPMessage returns the BGP message, including its header, of a
+// BGP4MP_MESSAGE, BGP4MP_MESSAGE_AS4, BGP4MP_MESSAGE_LOCAL, or
+// BGP4MP_MESSAGE_AS4_LOCAL record, or their _ET equivalents.
+//
+// https://www.rfc-editor.org/rfc/rfc6396#section-4.4.2
+func (r Record) BGPMessage() ([]byte, error) {
+	if r.Type != TypeBGP4MP && r.Type != TypeBGP4MPET {
+		return nil, errors.New("not a BGP4MP record")
+	}
+	asLen := 2
+	switch r.Subtype {
+	case SubtypeBGP4MPMessage, SubtypeBGP4MPMessageLocal:
+	case SubtypeBGP4MPMessageAS4, SubtypeBGP4MPMessageAS4Local:
+		asLen = 4
+	default:
+		return nil, errors.New("not a BGP4MP message record")
+	}
+	// peer AS, local AS, interface index, address family
+	hdrLen := asLen*2 + 4
+	if len(r.Message) < hdrLen {
+		return nil, errors.New("malformed BGP4MP record")
+	}
+	addrLen := 4
+	if binary.BigEndian.Uint16(r.Message[hdrLen-2:]) == 2 {
+		addrLen = 16
+	}
+	hdrLen += addrLen * 2
+	if len(r.Message) < hdrLen {
+		return nil, errors.New("malformed BGP4MP record")
+	}
+	return r.Message[hdrLen:], nil
+}
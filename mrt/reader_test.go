@@ -0,0 +1,72 @@
+package mrt
+
+import (
+	"bytes"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	var buf bytes.Buffer
+	ts := time.Unix(0x01020304, 0)
+	w, err := NewTableDumpV2Writer(&buf, ts, netip.MustParseAddr("192.0.2.1"),
+		"", []Peer{{
+			BGPID:   netip.MustParseAddr("192.0.2.2"),
+			Address: netip.MustParseAddr("2001:db8::2"),
+			AS:      64512,
+		}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	entries := []RIBEntry{{OriginatedTime: ts, Attributes: []byte{0x40, 1, 1, 0}}}
+	prefix := netip.MustParsePrefix("2001:db8::/33")
+	assert.NoError(t, w.WriteRIB(prefix, entries))
+	// BGP4MP_MESSAGE_AS4_ET, IPv4, KEEPALIVE
+	buf.Write([]byte{
+		1, 2, 3, 4, 0, 17, 0, 4, 0, 0, 0, 43,
+		0, 0, 0, 5, // microseconds
+		0, 0, 0xfc, 0, 0, 0, 0xfc, 1, 0, 0, 0, 1,
+		192, 0, 2, 2, 192, 0, 2, 1,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 19, 4,
+	})
+
+	r := NewReader(&buf)
+	rec, err := r.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, SubtypePeerIndexTable, rec.Subtype)
+	_, _, err = rec.RIB()
+	assert.Error(t, err)
+
+	rec, err = r.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	gotPrefix, gotEntries, err := rec.RIB()
+	assert.NoError(t, err)
+	assert.Equal(t, prefix, gotPrefix)
+	assert.Equal(t, entries, gotEntries)
+
+	rec, err = r.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, ts.Add(time.Microsecond*5), rec.Timestamp)
+	msg, err := rec.BGPMessage()
+	assert.NoError(t, err)
+	assert.Len(t, msg, 19)
+	assert.Equal(t, uint8(4), msg[18])
+
+	_, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	r = NewReader(bytes.NewReader([]byte{0, 0, 0, 0, 0, 13, 0, 1, 0, 0, 0, 9}))
+	_, err = r.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// LinkLocalNeighbors returns the IPv6 link-local addresses found in the
+// kernel's neighbor cache for the provided interface. Returned addresses are
+// zoned with the interface name. Neighbor entries are typically populated via
+// router advertisements or neighbor discovery. This function is only supported
+// on Linux.
+func LinkLocalNeighbors(iface string) ([]netip.Addr, error) {
+	return nil, errors.New("unsupported")
+}
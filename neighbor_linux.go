@@ -0,0 +1,76 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// LinkLocalNeighbors returns the IPv6 link-local addresses found in the
+// kernel's neighbor cache for the provided interface. Returned addresses are
+// zoned with the interface name. Neighbor entries are typically populated via
+// router advertisements or neighbor discovery. This function is only supported
+// on Linux.
+func LinkLocalNeighbors(iface string) ([]netip.Addr, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, unix.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+	return parseNeighbors(msgs, ifi.Index, iface)
+}
+
+// parseNeighbors returns the IPv6 link-local addresses of the usable neighbor
+// entries for the interface with index ifindex found in msgs, zoned with
+// iface.
+//
+// https://man7.org/linux/man-pages/man7/rtnetlink.7.html
+func parseNeighbors(msgs []syscall.NetlinkMessage, ifindex int,
+	iface string) ([]netip.Addr, error) {
+	neighbors := make([]netip.Addr, 0)
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH {
+			continue
+		}
+		if len(m.Data) < unix.SizeofNdMsg {
+			return nil, errors.New("short neighbor message")
+		}
+		nd := (*unix.NdMsg)(unsafe.Pointer(&m.Data[0]))
+		if int(nd.Ifindex) != ifindex ||
+			nd.State&(unix.NUD_INCOMPLETE|unix.NUD_FAILED|unix.NUD_NOARP) != 0 {
+			continue
+		}
+		attrs := m.Data[unix.SizeofNdMsg:]
+		for len(attrs) >= unix.SizeofRtAttr {
+			attrLen := int(binary.NativeEndian.Uint16(attrs))
+			attrType := binary.NativeEndian.Uint16(attrs[2:])
+			if attrLen < unix.SizeofRtAttr || attrLen > len(attrs) {
+				return nil, errors.New("malformed neighbor attribute")
+			}
+			if attrType == unix.NDA_DST {
+				addr, ok := netip.AddrFromSlice(attrs[unix.SizeofRtAttr:attrLen])
+				if ok && addr.Is6() && addr.IsLinkLocalUnicast() {
+					neighbors = append(neighbors, addr.WithZone(iface))
+				}
+			}
+			aligned := (attrLen + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+			if aligned > len(attrs) {
+				break
+			}
+			attrs = attrs[aligned:]
+		}
+	}
+	return neighbors, nil
+}
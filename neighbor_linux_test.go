@@ -0,0 +1,81 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// newNeighborMessage returns an RTM_NEWNEIGH message for a neighbor entry on
+// the interface with index ifindex in state with attrs appended.
+func newNeighborMessage(ifindex int32, state uint16,
+	attrs ...[]byte) syscall.NetlinkMessage {
+	data := make([]byte, unix.SizeofNdMsg)
+	data[0] = unix.AF_INET6
+	binary.NativeEndian.PutUint32(data[4:], uint32(ifindex))
+	binary.NativeEndian.PutUint16(data[8:], state)
+	for _, a := range attrs {
+		data = append(data, a...)
+	}
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: unix.RTM_NEWNEIGH},
+		Data:   data,
+	}
+}
+
+// newNeighborAttr returns a route attribute of type t with value v, padded to
+// its alignment.
+func newNeighborAttr(t uint16, v []byte) []byte {
+	b := make([]byte, unix.SizeofRtAttr, unix.SizeofRtAttr+len(v)+3)
+	binary.NativeEndian.PutUint16(b, uint16(unix.SizeofRtAttr+len(v)))
+	binary.NativeEndian.PutUint16(b[2:], t)
+	b = append(b, v...)
+	for len(b)%unix.NLMSG_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParseNeighbors(t *testing.T) {
+	linkLocal := netip.MustParseAddr("fe80::1")
+	global := netip.MustParseAddr("2001:db8::1")
+	lladdr := newNeighborAttr(unix.NDA_LLADDR, []byte{2, 0, 0, 0, 0, 1})
+	msgs := []syscall.NetlinkMessage{
+		newNeighborMessage(2, unix.NUD_REACHABLE, lladdr,
+			newNeighborAttr(unix.NDA_DST, linkLocal.AsSlice())),
+		newNeighborMessage(2, unix.NUD_STALE,
+			newNeighborAttr(unix.NDA_DST, global.AsSlice())),
+		newNeighborMessage(2, unix.NUD_FAILED,
+			newNeighborAttr(unix.NDA_DST,
+				netip.MustParseAddr("fe80::2").AsSlice())),
+		newNeighborMessage(3, unix.NUD_REACHABLE,
+			newNeighborAttr(unix.NDA_DST,
+				netip.MustParseAddr("fe80::3").AsSlice())),
+		{
+			Header: syscall.NlMsghdr{Type: unix.RTM_NEWLINK},
+		},
+	}
+	got, err := parseNeighbors(msgs, 2, "eth0")
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{linkLocal.WithZone("eth0")}, got)
+
+	got, err = parseNeighbors(nil, 2, "eth0")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	short := newNeighborMessage(2, unix.NUD_REACHABLE)
+	short.Data = short.Data[:unix.SizeofNdMsg-1]
+	_, err = parseNeighbors([]syscall.NetlinkMessage{short}, 2, "eth0")
+	assert.Error(t, err)
+
+	malformed := newNeighborAttr(unix.NDA_DST, linkLocal.AsSlice())
+	binary.NativeEndian.PutUint16(malformed, uint16(len(malformed)+1))
+	_, err = parseNeighbors([]syscall.NetlinkMessage{
+		newNeighborMessage(2, unix.NUD_REACHABLE, malformed),
+	}, 2, "eth0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// NextHopMode determines how a NextHopPolicy sets the next hop of advertised
+// routes.
+type NextHopMode uint8
+
+const (
+	// NextHopModeUnchanged advertises routes with their next hops unchanged.
+	NextHopModeUnchanged NextHopMode = iota
+	// NextHopModeSelf advertises routes with the local address of the
+	// session as their next hop.
+	NextHopModeSelf
+	// NextHopModeExplicit advertises routes with explicitly configured next
+	// hops per address family.
+	NextHopModeExplicit
+)
+
+// NextHopPolicy determines the next hops of routes advertised to a peer.
+// corebgp does not maintain a RIB, NextHopPolicy is applied by Plugins when
+// building routes to advertise.
+type NextHopPolicy struct {
+	Mode NextHopMode
+
+	// Explicit contains the next hops per address family for
+	// NextHopModeExplicit. For IPv6 next hops a global and an optional
+	// link-local address may be provided, in that order.
+	Explicit map[MPExtensions][]netip.Addr
+
+	// LinkLocal is an optional local IPv6 link-local address included with
+	// the session's local address for NextHopModeSelf when it is a global
+	// IPv6 address. It should only be set if the peer is directly connected.
+	LinkLocal netip.Addr
+}
+
+// NextHops returns the next hops for a route in family with next hops nh
+// advertised over session. For IPv6 next hops the result contains a global
+// and optionally a link-local address, see EncodeNextHops.
+func (p NextHopPolicy) NextHops(session SessionInfo, family MPExtensions,
+	nh []netip.Addr) ([]netip.Addr, error) {
+	switch p.Mode {
+	case NextHopModeUnchanged:
+		return nh, nil
+	case NextHopModeSelf:
+		local := session.LocalAddress.Addr().Unmap()
+		if !local.IsValid() {
+			return nil, errors.New("session has no local address")
+		}
+		if local.Is6() && !local.IsLinkLocalUnicast() &&
+			p.LinkLocal.IsValid() {
+			return []netip.Addr{local, p.LinkLocal}, nil
+		}
+		return []netip.Addr{local}, nil
+	case NextHopModeExplicit:
+		explicit, ok := p.Explicit[family]
+		if !ok || len(explicit) == 0 {
+			return nil, fmt.Errorf("no next hop configured for AFI %d SAFI %d",
+				family.AFI, family.SAFI)
+		}
+		return explicit, nil
+	default:
+		return nil, fmt.Errorf("unknown next hop mode: %d", p.Mode)
+	}
+}
+
+// EncodeNextHops encodes nh, as returned by NextHopPolicy.NextHops, for use in
+// a NEXT_HOP path attribute or the next hop field of a MP_REACH_NLRI path
+// attribute. nh must contain a single IPv4 address, or an IPv6 global address
+// and an optional link-local address. A single IPv6 link-local address, e.g.
+// for unnumbered sessions, is also accepted.
+func EncodeNextHops(nh []netip.Addr) ([]byte, error) {
+	if len(nh) == 0 || len(nh) > 2 {
+		return nil, errors.New("invalid number of next hops")
+	}
+	first := nh[0].Unmap()
+	if first.Is4() {
+		if len(nh) != 1 {
+			return nil, errors.New("IPv4 next hop must be a single address")
+		}
+		a := first.As4()
+		return a[:], nil
+	}
+	if len(nh) == 1 && first.IsLinkLocalUnicast() {
+		a := first.As16()
+		return a[:], nil
+	}
+	var linkLocal netip.Addr
+	if len(nh) == 2 {
+		linkLocal = nh[1]
+	}
+	return EncodeMPReachIPv6NextHops(first, linkLocal)
+}
@@ -0,0 +1,53 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextHopPolicy(t *testing.T) {
+	ipv4 := MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}
+	ipv6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+	session := SessionInfo{
+		LocalAddress: netip.MustParseAddrPort("[2001:db8::1]:179"),
+	}
+	nh := []netip.Addr{netip.MustParseAddr("2001:db8::2")}
+	linkLocal := netip.MustParseAddr("fe80::1")
+
+	got, err := NextHopPolicy{}.NextHops(session, ipv6, nh)
+	assert.NoError(t, err)
+	assert.Equal(t, nh, got)
+
+	got, err = NextHopPolicy{
+		Mode:      NextHopModeSelf,
+		LinkLocal: linkLocal,
+	}.NextHops(session, ipv6, nh)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{session.LocalAddress.Addr(), linkLocal}, got)
+	b, err := EncodeNextHops(got)
+	assert.NoError(t, err)
+	assert.Len(t, b, 32)
+
+	explicit := NextHopPolicy{
+		Mode: NextHopModeExplicit,
+		Explicit: map[MPExtensions][]netip.Addr{
+			ipv4: {netip.MustParseAddr("192.0.2.1")},
+		},
+	}
+	got, err = explicit.NextHops(session, ipv4, nil)
+	assert.NoError(t, err)
+	b, err = EncodeNextHops(got)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{192, 0, 2, 1}, b)
+	_, err = explicit.NextHops(session, ipv6, nil)
+	assert.Error(t, err)
+
+	b, err = EncodeNextHops([]netip.Addr{linkLocal})
+	assert.NoError(t, err)
+	assert.Len(t, b, 16)
+	_, err = EncodeNextHops([]netip.Addr{netip.MustParseAddr("192.0.2.1"),
+		linkLocal})
+	assert.Error(t, err)
+}
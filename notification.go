@@ -0,0 +1,166 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseNotification parses the body of a NOTIFICATION message, i.e. the
+// message excluding its header.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.5
+func ParseNotification(b []byte) (*Notification, error) {
+	n := &Notification{}
+	err := n.decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// String returns a human-readable rendering of n, e.g. "Cease/Administrative
+// Shutdown: maintenance window". Data is rendered as a Shutdown Communication
+// or Maximum Number of Prefixes Reached data where applicable, and in hex
+// otherwise.
+func (n *Notification) String() string {
+	var sb strings.Builder
+	d, ok := notifCodesMap[n.Code]
+	if ok {
+		sb.WriteString(d.desc)
+	} else {
+		fmt.Fprintf(&sb, "Code %d", n.Code)
+	}
+	if s, ok := d.subcodes[n.Subcode]; ok {
+		sb.WriteString("/" + s)
+	} else if n.Subcode != 0 {
+		fmt.Fprintf(&sb, "/Subcode %d", n.Subcode)
+	}
+	if len(n.Data) == 0 {
+		return sb.String()
+	}
+	if c, ok := n.ShutdownCommunication(); ok {
+		if len(c) > 0 {
+			sb.WriteString(": " + c)
+		}
+		return sb.String()
+	}
+	if family, limit, ok := n.MaxPrefixes(); ok {
+		fmt.Fprintf(&sb, ": AFI %d SAFI %d limit %d", family.AFI, family.SAFI,
+			limit)
+		return sb.String()
+	}
+	sb.WriteString(": data " + hex.EncodeToString(n.Data))
+	return sb.String()
+}
+
+// ShutdownCommunication returns the Shutdown Communication carried by n and
+// true if n is a Cease Notification with the Administrative Shutdown or
+// Administrative Reset subcode whose data is a valid Shutdown Communication.
+//
+// The data of such a Notification consists of a one octet length followed by
+// the UTF-8 encoded Shutdown Communication.
+//
+// https://www.rfc-editor.org/rfc/rfc9003#section-2
+func (n *Notification) ShutdownCommunication() (string, bool) {
+	if n.Code != NOTIF_CODE_CEASE || (n.Subcode != NOTIF_SUBCODE_ADMIN_SHUTDOWN &&
+		n.Subcode != NOTIF_SUBCODE_ADMIN_RESET) {
+		return "", false
+	}
+	if len(n.Data) == 0 {
+		return "", true
+	}
+	l := int(n.Data[0])
+	if len(n.Data) < 1+l {
+		return "", false
+	}
+	c := n.Data[1 : 1+l]
+	if !utf8.Valid(c) {
+		return "", false
+	}
+	return string(c), true
+}
+
+// MaxPrefixes returns the address family and upper bound on the number of
+// prefixes carried by n, and true if n is a Cease Notification with the
+// Maximum Number of Prefixes Reached subcode containing said data.
+//
+// https://www.rfc-editor.org/rfc/rfc4486#section-4
+// If a BGP speaker decides to terminate its peering with a neighbor because
+// the number of address prefixes received from the neighbor exceeds a
+// locally configured upper bound (as described in [BGP-4]), then the speaker
+// MUST send to the neighbor a NOTIFICATION message with the Error Code Cease
+// and the Error Subcode "Maximum Number of Prefixes Reached".  The message
+// MAY optionally include the Address Family information [BGP-MP] and the
+// upper bound in the "Data" field, as shown in Figure 1, where the meaning
+// and use of the <AFI, SAFI> tuple is the same as defined in [BGP-MP],
+// Section 7.
+func (n *Notification) MaxPrefixes() (MPExtensions, uint32, bool) {
+	if n.Code != NOTIF_CODE_CEASE ||
+		n.Subcode != NOTIF_SUBCODE_MAX_NUM_OF_PREFIXES_REACHED ||
+		len(n.Data) != 7 {
+		return MPExtensions{}, 0, false
+	}
+	return MPExtensions{
+		AFI:  binary.BigEndian.Uint16(n.Data),
+		SAFI: n.Data[2],
+	}, binary.BigEndian.Uint32(n.Data[3:]), true
+}
+
+// NotificationObserver is called when a Notification is sent to or received
+// from a peer, terminating the session. sent is true if the Notification was
+// sent by the local system. It is called from the peer's event loop and must
+// not block.
+type NotificationObserver func(peer PeerConfig, n *Notification, sent bool)
+
+// WithNotificationObserver returns a PeerOption that sets a
+// NotificationObserver for a peer.
+func WithNotificationObserver(fn NotificationObserver) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.notificationObserver = fn
+	})
+}
+
+// NotificationRecord is a Notification sent to or received from a peer, and
+// the time at which it was.
+type NotificationRecord struct {
+	Notification *Notification
+	Time         time.Time
+}
+
+// LastNotifications contains the last Notifications sent to and received from
+// a peer, across sessions for the lifetime of the peer. A field is nil if no
+// such Notification has occurred.
+type LastNotifications struct {
+	Sent     *NotificationRecord
+	Received *NotificationRecord
+}
+
+// recordNotification records n as the last Notification sent to or received
+// from the peer.
+func (p *peer) recordNotification(n *Notification, sent bool) {
+	r := &NotificationRecord{
+		Notification: &Notification{
+			Code:    n.Code,
+			Subcode: n.Subcode,
+			Data:    append([]byte{}, n.Data...),
+		},
+		Time: p.options().clock.Now(),
+	}
+	p.notifMu.Lock()
+	defer p.notifMu.Unlock()
+	if sent {
+		p.lastNotifs.Sent = r
+	} else {
+		p.lastNotifs.Received = r
+	}
+}
+
+func (p *peer) getLastNotifications() LastNotifications {
+	p.notifMu.Lock()
+	defer p.notifMu.Unlock()
+	return p.lastNotifs
+}
@@ -23,16 +23,6 @@ func (n *notificationError) Error() string {
 	if n.out {
 		direction = "sent"
 	}
-	var codeDesc, subcodeDesc string
-	d, ok := notifCodesMap[n.notification.Code]
-	if ok {
-		codeDesc = d.desc
-		s, ok := d.subcodes[n.notification.Subcode]
-		if ok {
-			subcodeDesc = s
-		}
-	}
-	return fmt.Sprintf("notification %s code: %d (%s) subcode: %d (%s)",
-		direction, n.notification.Code, codeDesc, n.notification.Subcode,
-		subcodeDesc)
+	return fmt.Sprintf("notification %s: %s", direction,
+		n.notification.String())
 }
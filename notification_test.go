@@ -0,0 +1,72 @@
+package corebgp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotification_String(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *Notification
+		want string
+	}{
+		{
+			name: "shutdown communication",
+			n:    NewAdminShutdownNotification("maintenance window"),
+			want: "Cease/Administrative Shutdown: maintenance window",
+		},
+		{
+			name: "admin shutdown without communication",
+			n:    NewAdminShutdownNotification(""),
+			want: "Cease/Administrative Shutdown",
+		},
+		{
+			name: "max prefixes",
+			n: newNotification(NOTIF_CODE_CEASE,
+				NOTIF_SUBCODE_MAX_NUM_OF_PREFIXES_REACHED,
+				[]byte{0, 2, 1, 0, 0, 0x03, 0xe8}),
+			want: "Cease/Maximum Number of Prefixes Reached: AFI 2 SAFI 1 limit 1000",
+		},
+		{
+			name: "hold timer expired",
+			n:    newNotification(NOTIF_CODE_HOLD_TIMER_EXPIRED, 0, nil),
+			want: "Hold Timer Expired",
+		},
+		{
+			name: "unknown subcode with data",
+			n:    newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 99, []byte{1, 2}),
+			want: "OPEN Message Error/Subcode 99: data 0102",
+		},
+		{
+			name: "unknown code",
+			n:    newNotification(99, 1, nil),
+			want: "Code 99/Subcode 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.n.String())
+		})
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	n, err := ParseNotification([]byte{NOTIF_CODE_CEASE,
+		NOTIF_SUBCODE_ADMIN_RESET, 3, 'f', 'o', 'o'})
+	if !assert.NoError(t, err) {
+		return
+	}
+	c, ok := n.ShutdownCommunication()
+	assert.True(t, ok)
+	assert.Equal(t, "foo", c)
+
+	// length exceeds data
+	n.Data = []byte{4, 'f', 'o', 'o'}
+	_, ok = n.ShutdownCommunication()
+	assert.False(t, ok)
+
+	_, err = ParseNotification([]byte{NOTIF_CODE_CEASE})
+	assert.Error(t, err)
+}
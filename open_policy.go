@@ -0,0 +1,212 @@
+package corebgp
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// OpenInfo is the OPEN message received on an inbound connection, see
+// OpenPolicy.
+type OpenInfo struct {
+	Version uint8
+	// AS is the My Autonomous System field, which is AS_TRANS if the sender's
+	// AS number does not fit in two octets.
+	AS uint16
+	// ASN is the AS number from the four-octet AS number capability, or AS if
+	// the capability is absent.
+	ASN          uint32
+	HoldTime     uint16
+	RouterID     netip.Addr
+	Capabilities []Capability
+}
+
+func newOpenInfo(o *openMessage) OpenInfo {
+	caps := o.getCapabilities()
+	return OpenInfo{
+		Version:      o.version,
+		AS:           o.asn,
+		ASN:          fourOctetAS(o.asn, caps),
+		HoldTime:     o.holdTime,
+		RouterID:     addrFromRouterID(o.bgpID),
+		Capabilities: caps,
+	}
+}
+
+// OpenPolicy is invoked for each inbound connection with its remote and local
+// addresses and the OPEN message received on it, before the connection is
+// matched to a peer. It returns the address used to match the connection to a
+// peer, i.e. a PeerConfig.RemoteAddress or the RemoteAddress of a fallback
+// Transport, ordinarily remote.Addr(). This allows peers behind NAT or
+// multiplexed by source port to be matched to their configuration.
+//
+// Returning a non-nil Notification rejects the connection, the Notification
+// is sent and the connection closed. RFC4486 defines the Connection Rejected
+// subcode of the Cease error code for this purpose.
+//
+// The OPEN message is passed on to the matched peer's FSM, which validates it
+// as usual.
+type OpenPolicy func(remote, local netip.AddrPort, open OpenInfo) (netip.Addr,
+	*Notification)
+
+// SetOpenPolicy sets the OpenPolicy applied to inbound connections. A nil
+// policy, the default, matches connections to peers by their remote address
+// as soon as they are accepted.
+//
+// With an OpenPolicy set the OPEN message of an inbound connection is read
+// before the connection is matched, each connection is handled in its own
+// goroutine until then. A connection that does not send an OPEN message
+// within 4 minutes, the initial hold time of the OpenSent state, or before the
+// Server stops serving, is closed.
+func (s *Server) SetOpenPolicy(policy OpenPolicy) {
+	if policy == nil {
+		s.openPolicy.Store(nil)
+		return
+	}
+	s.openPolicy.Store(&policy)
+}
+
+// handleInboundOpen reads the OPEN message of an inbound connection and
+// applies policy to it before matching the connection to a peer.
+func (s *Server) handleInboundOpen(conn net.Conn, policy OpenPolicy) {
+	remote, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		conn.Close()
+		return
+	}
+	local, _ := netip.ParseAddrPort(conn.LocalAddr().String())
+	if !s.pendingOpens.add(conn) {
+		conn.Close()
+		return
+	}
+	b, open, err := readInboundOpen(conn)
+	if !s.pendingOpens.done(conn) {
+		// closed as the Server stopped serving
+		return
+	}
+	if err != nil {
+		var nerr *notificationError
+		if errors.As(err, &nerr) && nerr.out {
+			writeInboundNotification(conn, nerr.notification)
+		}
+		conn.Close()
+		return
+	}
+	match, n := policy(remote, local, open)
+	if n != nil {
+		writeInboundNotification(conn, n)
+		conn.Close()
+		return
+	}
+	if !match.IsValid() {
+		conn.Close()
+		return
+	}
+	s.matchInboundConn(conn, match, b)
+}
+
+// pendingConns tracks inbound connections awaiting an OPEN message so that
+// they can be closed when the Server stops serving.
+type pendingConns struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// add tracks conn, returning false if pendingConns is closed.
+func (p *pendingConns) add(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	if p.conns == nil {
+		p.conns = make(map[net.Conn]struct{})
+	}
+	p.conns[conn] = struct{}{}
+	return true
+}
+
+// done stops tracking conn, returning false if it was closed by closeAll.
+func (p *pendingConns) done(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.conns[conn]
+	delete(p.conns, conn)
+	return ok
+}
+
+// closeAll closes the tracked connections, and any added subsequently.
+func (p *pendingConns) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+// readInboundOpen reads an OPEN message from conn, returning it including its
+// header along with its decoded form.
+func readInboundOpen(conn net.Conn) ([]byte, OpenInfo, error) {
+	err := conn.SetReadDeadline(time.Now().Add(longHoldTime))
+	if err != nil {
+		return nil, OpenInfo{}, err
+	}
+	b, err := readRawMessage(conn, defaultLengthLimits)
+	if err != nil {
+		return nil, OpenInfo{}, err
+	}
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, OpenInfo{}, err
+	}
+	if b[18] != openMessageType {
+		n := newNotification(NOTIF_CODE_FSM_ERR, 0, nil)
+		return nil, OpenInfo{}, newNotificationError(n, true)
+	}
+	m, err := messageFromBytes(b[headerLength:], openMessageType)
+	if err != nil {
+		return nil, OpenInfo{}, err
+	}
+	return b, newOpenInfo(m.(*openMessage)), nil
+}
+
+func writeInboundNotification(conn net.Conn, n *Notification) {
+	b, err := n.encode()
+	if err != nil {
+		return
+	}
+	writeWithSendHold(conn, b, DefaultSendHoldTime) // nolint: errcheck
+}
+
+// replayConn is a net.Conn that returns replay from Read before reading from
+// the underlying net.Conn. It hands an inbound connection whose OPEN message
+// was read by handleInboundOpen to a peer's FSM.
+type replayConn struct {
+	net.Conn
+	replay []byte
+}
+
+func (r *replayConn) Read(b []byte) (int, error) {
+	if len(r.replay) > 0 {
+		n := copy(b, r.replay)
+		r.replay = r.replay[n:]
+		return n, nil
+	}
+	return r.Conn.Read(b)
+}
+
+// SyscallConn returns the syscall.RawConn of the underlying net.Conn, if it
+// implements syscall.Conn.
+func (r *replayConn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := r.Conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("conn does not implement syscall.Conn")
+	}
+	return sc.SyscallConn()
+}
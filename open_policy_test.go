@@ -0,0 +1,136 @@
+package corebgp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SetOpenPolicy(t *testing.T) {
+	t.Parallel()
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	// the address addrB's connections are translated to
+	addrNAT := netip.MustParseAddr("198.51.100.1")
+
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = server.AddPeer(corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, &livenessTestPlugin{}, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	var gotOpen corebgp.OpenInfo
+	server.SetOpenPolicy(func(remote, local netip.AddrPort,
+		open corebgp.OpenInfo) (netip.Addr, *corebgp.Notification) {
+		if remote.Addr() != addrNAT {
+			return netip.Addr{}, &corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_CEASE,
+				Subcode: corebgp.NOTIF_SUBCODE_CONN_REJECTED,
+			}
+		}
+		gotOpen = open
+		return addrB, nil
+	})
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	go server.Serve([]net.Listener{l})
+	t.Cleanup(server.Close)
+
+	// a connection from an unexpected address is rejected by the policy
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	assert.NoError(t, c.SendOpen())
+	notif, err := c.ReadNotification(time.Second * 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, corebgp.NOTIF_SUBCODE_CONN_REJECTED, notif.Subcode)
+	}
+
+	// a connection from the NAT address is matched to addrB
+	conn, err = n.Dialer(addrNAT)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c = corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	_, err = c.Establish()
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := server.GetSessionInfo(addrB)
+		return err == nil
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, uint32(64512), gotOpen.ASN)
+	assert.Equal(t, addrB, gotOpen.RouterID)
+}
+
+func TestServer_SetOpenPolicy_Close(t *testing.T) {
+	t.Parallel()
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server.SetOpenPolicy(func(remote, local netip.AddrPort,
+		open corebgp.OpenInfo) (netip.Addr, *corebgp.Notification) {
+		return remote.Addr(), nil
+	})
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	served := make(chan error)
+	go func() {
+		served <- server.Serve([]net.Listener{l})
+	}()
+
+	// a connection that never sends an OPEN message is closed along with
+	// the Server
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	time.Sleep(time.Millisecond * 100)
+	server.Close()
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, corebgp.ErrServerClosed)
+	case <-time.After(time.Second * 5):
+		t.Fatal("Serve did not return")
+	}
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+	_, err = conn.Read(make([]byte, 1))
+	var nerr net.Error
+	if assert.Error(t, err) && errors.As(err, &nerr) {
+		assert.False(t, nerr.Timeout(), "conn was not closed")
+	}
+}
@@ -6,8 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"net/netip"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -28,7 +28,7 @@ const (
 func messageFromBytes(b []byte, messageType uint8) (message, error) {
 	switch messageType {
 	case openMessageType:
-		o := &openMessage{}
+		o := &openMessage{raw: b}
 		err := o.decode(b)
 		if err != nil {
 			return nil, err
@@ -58,15 +58,17 @@ func messageFromBytes(b []byte, messageType uint8) (message, error) {
 }
 
 func prependHeader(m []byte, t uint8) []byte {
-	b := make([]byte, headerLength)
+	return appendMessage(make([]byte, 0, headerLength+len(m)), m, t)
+}
+
+// appendMessage appends m, with a header of message type t, to b.
+func appendMessage(b, m []byte, t uint8) []byte {
 	for i := 0; i < 16; i++ {
-		b[i] = 0xFF
+		b = append(b, 0xFF)
 	}
-	msgLen := uint16(len(m) + headerLength)
-	binary.BigEndian.PutUint16(b[16:], msgLen)
-	b[18] = t
-	b = append(b, m...)
-	return b
+	b = binary.BigEndian.AppendUint16(b, uint16(len(m)+headerLength))
+	b = append(b, t)
+	return append(b, m...)
 }
 
 type AddPathTuple struct {
@@ -210,7 +212,7 @@ func (n *Notification) encode() ([]byte, error) {
 	b := make([]byte, 2)
 	b[0] = n.Code
 	b[1] = n.Subcode
-	if len(n.Data) > 1 {
+	if len(n.Data) > 0 {
 		b = append(b, n.Data...)
 	}
 	return prependHeader(b, notificationMessageType), nil
@@ -234,12 +236,44 @@ func (n *Notification) AsSessionReset() *Notification {
 	return n
 }
 
+const (
+	maxShutdownCommunicationLen = 255
+)
+
+// NewAdminShutdownNotification returns a Cease Notification with the
+// Administrative Shutdown subcode. If communication is non-empty it is
+// included as a Shutdown Communication, truncated to 255 octets.
+//
+// https://www.rfc-editor.org/rfc/rfc9003#section-2
+// If a BGP speaker decides to terminate its session with a BGP neighbor, and
+// it sends a NOTIFICATION message with the Error Code "Cease" and Error
+// Subcode "Administrative Shutdown" or "Administrative Reset", it MAY include
+// a UTF-8-encoded string.
+func NewAdminShutdownNotification(communication string) *Notification {
+	var data []byte
+	if len(communication) > 0 {
+		c := communication
+		if len(c) > maxShutdownCommunicationLen {
+			c = c[:maxShutdownCommunicationLen]
+			// avoid splitting a multi-byte UTF-8 sequence
+			for len(c) > 0 && !utf8.RuneStart(communication[len(c)]) {
+				c = c[:len(c)-1]
+			}
+		}
+		data = append([]byte{uint8(len(c))}, c...)
+	}
+	return newNotification(NOTIF_CODE_CEASE, NOTIF_SUBCODE_ADMIN_SHUTDOWN,
+		data)
+}
+
 type openMessage struct {
 	version        uint8
 	asn            uint16
 	holdTime       uint16
 	bgpID          uint32
 	optionalParams []optionalParam
+	// raw is the message body the openMessage was decoded from, if any
+	raw []byte
 }
 
 func (o *openMessage) messageType() uint8 {
@@ -268,15 +302,25 @@ func (o *openMessage) validate(localID, localAS, remoteAS uint32) error {
 			NOTIF_SUBCODE_UNACCEPTABLE_HOLD_TIME, nil)
 		return newNotificationError(n, true)
 	}
-	var id [4]byte
-	binary.BigEndian.PutUint32(id[:], o.bgpID)
-	addr := netip.AddrFrom4(id)
-	if addr.IsMulticast() {
+	/*
+		https://tools.ietf.org/html/rfc6286#section-2.1
+		The BGP Identifier is a 4-octet, unsigned, non-zero integer that
+		should be unique within an AS.
+
+		https://tools.ietf.org/html/rfc6286#section-2.2
+		If the BGP Identifier field of the OPEN message is zero, or if it is
+		the same as the BGP Identifier of the local BGP speaker and the
+		message is from an internal peer, then the Error Subcode is set to
+		"Bad BGP Identifier".
+
+		Identifiers that are not valid unicast IPv4 host addresses are
+		accepted, as are identifiers equal to our own from external peers.
+	*/
+	if o.bgpID == 0 {
 		n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR,
 			NOTIF_SUBCODE_BAD_BGP_ID, nil)
 		return newNotificationError(n, true)
 	}
-	// https://tools.ietf.org/html/rfc6286#section-2.2
 	if localAS == remoteAS && localID == o.bgpID {
 		n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR,
 			NOTIF_SUBCODE_BAD_BGP_ID, nil)
@@ -324,6 +368,18 @@ func (o *openMessage) validate(localID, localAS, remoteAS uint32) error {
 	return nil
 }
 
+// DecodeOpenCapabilities decodes the OPEN message body b, excluding the
+// message header, and returns the capabilities contained in its optional
+// parameters.
+func DecodeOpenCapabilities(b []byte) ([]Capability, error) {
+	o := &openMessage{}
+	err := o.decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return o.getCapabilities(), nil
+}
+
 func (o *openMessage) getCapabilities() []Capability {
 	caps := make([]Capability, 0)
 	for _, param := range o.optionalParams {
@@ -346,11 +402,30 @@ func (o *openMessage) decode(b []byte) error {
 	o.holdTime = binary.BigEndian.Uint16(b[3:5])
 	o.bgpID = binary.BigEndian.Uint32(b[5:9])
 	optionalParamsLen := int(b[9])
-	if optionalParamsLen != len(b)-10 {
+	b = b[10:]
+	extended := false
+	// https://www.rfc-editor.org/rfc/rfc9072#section-2
+	// If the value of the "Non-Ext OP Type" field is 255, then the
+	// "Extended Opt. Parm. Length" field follows and the Optional
+	// Parameters field is encoded using the extended format.
+	if optionalParamsLen == 255 && len(b) > 0 &&
+		b[0] == extendedOptionalParamsType {
+		if len(b) < 3 {
+			n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 0, nil)
+			return newNotificationError(n, true)
+		}
+		extended = true
+		optionalParamsLen = int(binary.BigEndian.Uint16(b[1:3]))
+		b = b[3:]
+	}
+	if optionalParamsLen != len(b) {
 		n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 0, nil)
 		return newNotificationError(n, true)
 	}
-	optionalParams, err := decodeOptionalParams(b[10:])
+	if len(b) == 0 {
+		return nil
+	}
+	optionalParams, err := decodeOptionalParams(b, extended)
 	if err != nil {
 		return err
 	}
@@ -358,25 +433,31 @@ func (o *openMessage) decode(b []byte) error {
 	return nil
 }
 
-func decodeOptionalParams(b []byte) ([]optionalParam, error) {
+// decodeOptionalParams decodes the optional parameters in b. If extended is
+// true the parameters are expected to be encoded using the extended format
+// described in RFC9072, i.e. with a two-octet parameter length.
+func decodeOptionalParams(b []byte, extended bool) ([]optionalParam, error) {
 	params := make([]optionalParam, 0)
+	hdrLen := 2
+	if extended {
+		hdrLen = 3
+	}
 	for {
-		if len(b) < 2 {
+		if len(b) < hdrLen {
 			n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 0, nil)
 			return nil, newNotificationError(n, true)
 		}
 		paramCode := b[0]
-		paramLen := b[1]
-		if len(b) < int(paramLen)+2 {
+		paramLen := int(b[1])
+		if extended {
+			paramLen = int(binary.BigEndian.Uint16(b[1:3]))
+		}
+		if len(b) < paramLen+hdrLen {
 			n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 0, nil)
 			return nil, newNotificationError(n, true)
 		}
-		paramToDecode := make([]byte, 0)
-		if paramLen > 0 {
-			paramToDecode = b[2 : paramLen+2]
-		}
-		nextParam := 2 + int(paramLen)
-		b = b[nextParam:]
+		paramToDecode := b[hdrLen : hdrLen+paramLen]
+		b = b[hdrLen+paramLen:]
 		switch paramCode {
 		case capabilityOptionalParamType:
 			c := &capabilityOptionalParam{}
@@ -403,17 +484,40 @@ func (o *openMessage) encode() ([]byte, error) {
 	binary.BigEndian.PutUint16(b[1:3], o.asn)
 	binary.BigEndian.PutUint16(b[3:5], o.holdTime)
 	binary.BigEndian.PutUint32(b[5:9], o.bgpID)
+	params, err := o.encodeOptionalParams(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) > math.MaxUint8 {
+		// https://www.rfc-editor.org/rfc/rfc9072#section-2
+		// A BGP speaker MUST use the extended format if the length of the
+		// Optional Parameters in the BGP OPEN message does exceed 255.
+		params, err = o.encodeOptionalParams(true)
+		if err != nil {
+			return nil, err
+		}
+		if len(params) > math.MaxUint16 {
+			return nil, errors.New("optional parameters too long")
+		}
+		b = append(b, math.MaxUint8, extendedOptionalParamsType, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-2:], uint16(len(params)))
+	} else {
+		b = append(b, uint8(len(params)))
+	}
+	b = append(b, params...)
+	return prependHeader(b, openMessageType), nil
+}
+
+func (o *openMessage) encodeOptionalParams(extended bool) ([]byte, error) {
 	params := make([]byte, 0)
 	for _, param := range o.optionalParams {
-		p, err := param.encode()
+		p, err := param.encode(extended)
 		if err != nil {
 			return nil, err
 		}
 		params = append(params, p...)
 	}
-	b = append(b, uint8(len(params)))
-	b = append(b, params...)
-	return prependHeader(b, openMessageType), nil
+	return params, nil
 }
 
 const (
@@ -459,11 +563,13 @@ func newOpenMessage(asn uint32, holdTime time.Duration, bgpID uint32,
 
 const (
 	capabilityOptionalParamType uint8 = 2
+	// https://www.rfc-editor.org/rfc/rfc9072#section-4
+	extendedOptionalParamsType uint8 = 255
 )
 
 type optionalParam interface {
 	paramType() uint8
-	encode() ([]byte, error)
+	encode(extended bool) ([]byte, error)
 	decode(b []byte) error
 }
 
@@ -504,7 +610,7 @@ func (c *capabilityOptionalParam) decode(b []byte) error {
 	}
 }
 
-func (c *capabilityOptionalParam) encode() ([]byte, error) {
+func (c *capabilityOptionalParam) encode(extended bool) ([]byte, error) {
 	b := make([]byte, 0)
 	caps := make([]byte, 0)
 	if len(c.capabilities) > 0 {
@@ -515,7 +621,16 @@ func (c *capabilityOptionalParam) encode() ([]byte, error) {
 		return nil, errors.New("empty capabilities in capability optional param")
 	}
 	b = append(b, capabilityOptionalParamType)
-	b = append(b, uint8(len(caps)))
+	if extended {
+		if len(caps) > math.MaxUint16 {
+			return nil, errors.New("capabilities too long")
+		}
+		b = append(b, 0, 0)
+		binary.BigEndian.PutUint16(b[1:], uint16(len(caps)))
+	} else {
+		// the caller falls back to the extended format if this overflows
+		b = append(b, uint8(len(caps)))
+	}
 	b = append(b, caps...)
 	return b, nil
 }
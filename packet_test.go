@@ -2,7 +2,9 @@ package corebgp
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -166,3 +168,92 @@ func TestDecodeAddPathTuples(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenMessage_ExtendedOptionalParams(t *testing.T) {
+	caps := make([]Capability, 0)
+	for i := 0; i < 64; i++ {
+		caps = append(caps, NewMPExtensionsCapability(uint16(i), SAFI_UNICAST))
+	}
+	for _, tt := range []struct {
+		name     string
+		caps     []Capability
+		extended bool
+	}{
+		{
+			name:     "non-extended",
+			caps:     caps[:1],
+			extended: false,
+		},
+		{
+			name:     "extended",
+			caps:     caps,
+			extended: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newOpenMessage(64512, time.Second*90, 1, tt.caps)
+			if !assert.NoError(t, err) {
+				return
+			}
+			b, err := o.encode()
+			if !assert.NoError(t, err) {
+				return
+			}
+			body := b[headerLength:]
+			assert.Equal(t, tt.extended, body[9] == 255 &&
+				body[10] == extendedOptionalParamsType)
+			got := &openMessage{}
+			if !assert.NoError(t, got.decode(body)) {
+				return
+			}
+			assert.Equal(t, o.getCapabilities(), got.getCapabilities())
+		})
+	}
+}
+
+func TestNewAdminShutdownNotification(t *testing.T) {
+	n := NewAdminShutdownNotification("")
+	assert.Equal(t, NOTIF_CODE_CEASE, n.Code)
+	assert.Equal(t, NOTIF_SUBCODE_ADMIN_SHUTDOWN, n.Subcode)
+	assert.Empty(t, n.Data)
+
+	n = NewAdminShutdownNotification("maintenance")
+	assert.Equal(t, append([]byte{11}, "maintenance"...), n.Data)
+
+	// a multi-byte rune straddling the 255 octet limit is dropped
+	n = NewAdminShutdownNotification(strings.Repeat("a", 254) + "é")
+	assert.Equal(t, uint8(254), n.Data[0])
+	assert.Len(t, n.Data, 255)
+}
+
+func TestOpenMessage_validateBGPID(t *testing.T) {
+	tests := []struct {
+		name     string
+		bgpID    uint32
+		localID  uint32
+		localAS  uint32
+		remoteAS uint32
+		wantErr  bool
+	}{
+		{"zero", 0, 1, 64512, 64513, true},
+		{"multicast", 0xe0000001, 1, 64512, 64513, false},
+		{"class e", 0xf0000001, 1, 64512, 64513, false},
+		{"same as local ebgp", 1, 1, 64512, 64513, false},
+		{"same as local ibgp", 1, 1, 64512, 64512, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newOpenMessage(tt.remoteAS, time.Second*90, tt.bgpID,
+				nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			err = o.validate(tt.localID, tt.localAS, tt.remoteAS)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
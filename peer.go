@@ -1,9 +1,11 @@
 package corebgp
 
 import (
+	"context"
 	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,25 +21,67 @@ const (
 
 // peer manages the FSMs for a peer.
 type peer struct {
-	config  PeerConfig
-	id      uint32
-	plugin  Plugin
-	options peerOptions
+	config PeerConfig
+	id     uint32
+	plugin Plugin
+	// group is the name of the PeerGroup the peer is a member of, if any, and
+	// memberOpts are the PeerOptions applied on top of the group's. Both are
+	// protected by Server.mu.
+	group      string
+	memberOpts []PeerOption
+	// opts may be swapped by UpdatePeer, use options() to read it
+	opts atomic.Pointer[peerOptions]
 
 	fsms         [2]*fsm
 	fsmState     [2]fsmState
 	transitionCh [2]chan stateTransition
 	errorCh      [2]chan error
 
+	// publishedState mirrors fsmState for readers outside of run(), see
+	// state()
+	publishedState [2]atomic.Uint32
+
 	lastProtoError    *time.Time
 	startupDelay      time.Duration
-	startupDelayTimer *time.Timer
+	startupDelayTimer Timer
 	inHoldDown        bool
 
+	stats peerStats
+
+	// trace holds the TraceLevel of the peer, which may be changed at runtime
+	trace atomic.Uint32
+
+	notifMu    sync.Mutex
+	lastNotifs LastNotifications
+
+	// history is bounded by peerOptions.sessionHistorySize
+	historyMu sync.Mutex
+	history   []SessionRecord
+
+	// md5PrevKey is the TCP MD5 key prior to the last rotation, it is retained
+	// until a session is established with the current key. md5ListenerKey is
+	// the key currently set on the listeners, which alternates between the
+	// current and previous key while the latter is retained.
+	md5Mu          sync.Mutex
+	md5PrevKey     string
+	md5DialCount   uint64
+	md5ListenerKey string
+	md5Rotations   uint64
+
+	// session is non-nil while an FSM is in the established state
+	sessionMu     sync.Mutex
+	session       *SessionInfo
+	sessionWriter *updateMessageWriter
+
 	inConnCh  chan net.Conn
 	closeOnce sync.Once
 	closeCh   chan struct{}
 	doneCh    chan struct{}
+
+	// ctx is passed to a contextAwarePlugin, it is canceled when the peer is
+	// stopped
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 const (
@@ -50,13 +94,16 @@ func newPeer(config PeerConfig, id uint32, plugin Plugin, options peerOptions) *
 		config:            config,
 		id:                id,
 		plugin:            plugin,
-		options:           options,
 		inConnCh:          make(chan net.Conn),
 		closeCh:           make(chan struct{}),
 		doneCh:            make(chan struct{}),
-		startupDelayTimer: time.NewTimer(0),
+		startupDelayTimer: options.clock.NewTimer(0),
+		md5ListenerKey:    options.md5Key,
 	}
-	<-p.startupDelayTimer.C
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.opts.Store(&options)
+	p.trace.Store(uint32(options.traceLevel))
+	<-p.startupDelayTimer.C()
 	for i := 0; i < 2; i++ {
 		p.fsmState[i] = disabledState
 		p.transitionCh[i] = make(chan stateTransition)
@@ -65,22 +112,6 @@ func newPeer(config PeerConfig, id uint32, plugin Plugin, options peerOptions) *
 	return p
 }
 
-// getFSMTransitionCh returns the stateTransition channel for the provided FSM.
-func (p *peer) getFSMTransitionCh(f *fsm) chan stateTransition {
-	if f == p.fsms[out] {
-		return p.transitionCh[out]
-	}
-	return p.transitionCh[in]
-}
-
-// getFSMErrorCh returns the error channel for the provided FSM.
-func (p *peer) getFSMErrorCh(f *fsm) chan error {
-	if f == p.fsms[out] {
-		return p.errorCh[out]
-	}
-	return p.errorCh[in]
-}
-
 func other(i int) int {
 	if i == out {
 		return in
@@ -89,8 +120,7 @@ func other(i int) int {
 }
 
 func (p *peer) logTransition(i int, from, to fsmState) {
-	logf("[%s] FSM-%s transition %s => %s", p.config.RemoteAddress,
-		direction(i), from, to)
+	p.logf("FSM-%s transition %s => %s", direction(i), from, to)
 }
 
 func (p *peer) disableFSM(i int) {
@@ -100,7 +130,7 @@ func (p *peer) disableFSM(i int) {
 	p.logTransition(i, p.fsmState[i], disabledState)
 	p.fsms[i].stop()
 	p.fsms[i] = nil
-	p.fsmState[i] = disabledState
+	p.setFSMState(i, disabledState)
 }
 
 func (p *peer) sendTransitionToFSM(i int, t stateTransition) {
@@ -109,17 +139,29 @@ func (p *peer) sendTransitionToFSM(i int, t stateTransition) {
 		return
 	case p.transitionCh[i] <- t:
 		p.logTransition(i, t.from, t.to)
-		p.fsmState[i] = t.to
+		p.setFSMState(i, t.to)
 	}
 }
 
+func (p *peer) setFSMState(i int, s fsmState) {
+	p.fsmState[i] = s
+	p.publishedState[i].Store(uint32(s))
+}
+
+// state returns the most advanced state of the peer's FSMs. It is safe to
+// call from outside of run().
+func (p *peer) state() fsmState {
+	return fsmState(max(p.publishedState[out].Load(),
+		p.publishedState[in].Load()))
+}
+
 func (p *peer) enableFSM(i int, conn net.Conn) {
-	if i == out && p.options.passive {
+	if i == out && p.options().transportMode == TransportModePassive {
 		return
 	}
 	if p.fsms[i] == nil {
-		p.fsms[i] = newFSM(p, conn)
-		p.fsmState[i] = disabledState
+		p.fsms[i] = newFSM(p, i, conn)
+		p.setFSMState(i, disabledState)
 		p.fsms[i].start()
 	}
 }
@@ -136,61 +178,40 @@ func (p *peer) handleStateTransition(i int, t stateTransition) {
 		p.enableFSM(out, nil)
 	case t.to == openConfirmState:
 		// https://tools.ietf.org/html/rfc4271#section-6.8
-		switch p.fsmState[other(i)] {
-		case establishedState:
-			/*
-				Unless allowed via configuration, a connection collision with an
-				existing BGP connection that is in the Established state causes
-				closing of the newly created connection.
-			*/
+		otherState := p.fsmState[other(i)]
+		if otherState != establishedState && otherState != openConfirmState {
+			p.sendTransitionToFSM(i, t)
+			return
+		}
+		if p.resolveCollision(i, otherState == establishedState) != i {
+			// disable this fsm
 			p.disableFSM(i)
-		case openConfirmState:
-			// https://github.com/BIRD/bird/blob/v2.0.2/proto/bgp/packets.c#L666
-			/*
-				Description of collision detection rules in RFC 4271 is confusing and
-				contradictory, but it is essentially:
-
-					1. Router with higher ID is dominant
-					2. If both have the same ID, router with higher ASN is dominant [RFC6286]
-					3. When both connections are in OpenConfirm state, one initiated by
-					 the dominant router is kept.
-			*/
-			remoteID := p.fsms[i].remoteID
-			localID := p.id
-			dominant := localID > remoteID ||
-				(localID == remoteID) && (p.config.LocalAS > p.config.RemoteAS)
-			if dominant && i == out {
-				// attempt to disable other FSM
-				select {
-				case <-p.closeCh:
-					return
-				case p.fsms[other(i)].closeCh <- struct{}{}:
-					// we send an empty struct rather than close the channel in
-					// case we lose on the select race in fsm.openConfirm()
-					p.disableFSM(other(i)) // wait for it to stop completely
-					p.sendTransitionToFSM(i, t)
-				case otherT := <-p.transitionCh[other(i)]:
-					// other FSM transitioned before we could disable it
-					if otherT.to == establishedState {
-						// other FSM entered established state before we could
-						// disable it. disable this FSM and then handle the
-						// transition from the other FSM.
-						p.disableFSM(i)
-						p.handleStateTransition(other(i), otherT)
-					} else {
-						// other FSM went down, allow this FSM to transition to
-						// openConfirm and then handle the transition from the
-						// other FSM.
-						p.sendTransitionToFSM(i, t)
-						p.handleStateTransition(other(i), otherT)
-					}
-				}
-			} else {
-				// disable this fsm
+			return
+		}
+		// attempt to disable other FSM
+		select {
+		case <-p.closeCh:
+			return
+		case p.fsms[other(i)].closeCh <- struct{}{}:
+			// we send an empty struct rather than close the channel in
+			// case we lose on the select race in fsm.openConfirm()
+			p.disableFSM(other(i)) // wait for it to stop completely
+			p.sendTransitionToFSM(i, t)
+		case otherT := <-p.transitionCh[other(i)]:
+			// other FSM transitioned before we could disable it
+			if otherT.to == establishedState {
+				// other FSM entered established state before we could
+				// disable it. disable this FSM and then handle the
+				// transition from the other FSM.
 				p.disableFSM(i)
+				p.handleStateTransition(other(i), otherT)
+			} else {
+				// other FSM went down, allow this FSM to transition to
+				// openConfirm and then handle the transition from the
+				// other FSM.
+				p.sendTransitionToFSM(i, t)
+				p.handleStateTransition(other(i), otherT)
 			}
-		default:
-			p.sendTransitionToFSM(i, t)
 		}
 	default:
 		p.sendTransitionToFSM(i, t)
@@ -206,10 +227,13 @@ func direction(i int) string {
 
 // handleError handles an error during fsm operation
 func (p *peer) handleError(i int, err error) {
-	logf("[%s] FSM-%s %s error: %v",
-		p.config.RemoteAddress, direction(i), p.fsmState[i], err)
+	p.logf("FSM-%s %s error: %v", direction(i), p.fsmState[i], err)
 	var nerr *notificationError
 	if errors.As(err, &nerr) {
+		p.recordNotification(nerr.notification, nerr.out)
+		if fn := p.options().notificationObserver; fn != nil {
+			fn(p.config, nerr.notification, nerr.out)
+		}
 		if nerr.dampPeer() {
 			p.disableFSM(in)
 			p.disableFSM(out)
@@ -225,11 +249,11 @@ func (p *peer) handleError(i int, err error) {
 // https://github.com/BIRD/bird/blob/v2.0.2/proto/bgp/bgp.c#L384
 func (p *peer) updateStartupDelay() {
 	if p.lastProtoError != nil &&
-		(time.Since(*p.lastProtoError) >= errorAmnesiaTime) {
+		(p.options().clock.Now().Sub(*p.lastProtoError) >= errorAmnesiaTime) {
 		p.startupDelay = 0
 	}
 
-	lastProtoError := time.Now()
+	lastProtoError := p.options().clock.Now()
 	p.lastProtoError = &lastProtoError
 
 	if p.startupDelay > 0 {
@@ -239,8 +263,8 @@ func (p *peer) updateStartupDelay() {
 	}
 
 	p.startupDelayTimer.Stop()
-	p.startupDelayTimer = time.NewTimer(p.startupDelay)
-	logf("[%s] damping peer for %s", p.config.RemoteAddress, p.startupDelay)
+	p.startupDelayTimer = p.options().clock.NewTimer(p.startupDelay)
+	p.logf("damping peer for %s", p.startupDelay)
 }
 
 // main run loop
@@ -256,9 +280,8 @@ func (p *peer) run() {
 		select {
 		case <-p.closeCh:
 			return
-		case <-p.startupDelayTimer.C:
-			logf("[%s] startup delay timer expired, enabling peer",
-				p.config.RemoteAddress)
+		case <-p.startupDelayTimer.C():
+			p.logf("startup delay timer expired, enabling peer")
 			p.enableFSM(out, nil)
 			p.inHoldDown = false
 		case err := <-p.errorCh[in]:
@@ -286,6 +309,62 @@ func (p *peer) run() {
 	}
 }
 
+// options returns the current peerOptions. The returned value must not be
+// modified.
+func (p *peer) options() *peerOptions {
+	return p.opts.Load()
+}
+
+// transports returns the transports for the peer in order of preference.
+func (p *peer) transports() []Transport {
+	transports := []Transport{{
+		RemoteAddress: p.config.RemoteAddress,
+		LocalAddress:  p.options().localAddress,
+	}}
+	return append(transports, p.options().fallbackTransports...)
+}
+
+// setSession sets the SessionInfo and writer of the established FSM, or clears
+// them if the peer is no longer established.
+func (p *peer) setSession(s *SessionInfo, w *updateMessageWriter) {
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+	p.session = s
+	p.sessionWriter = w
+}
+
+// getSessionWriter returns the writer of the established FSM, or nil if the
+// peer is not established.
+func (p *peer) getSessionWriter() *updateMessageWriter {
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+	return p.sessionWriter
+}
+
+func (p *peer) getSessionInfo() (SessionInfo, bool) {
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+	if p.session == nil {
+		return SessionInfo{}, false
+	}
+	return *p.session, true
+}
+
+// resetSession sends a Cease Notification with the Administrative Reset
+// subcode to the established session, if any. It returns a channel that is
+// closed once the session has closed, or nil if no session was reset.
+func (p *peer) resetSession() <-chan struct{} {
+	w := p.getSessionWriter()
+	if w == nil {
+		return nil
+	}
+	n := newNotification(NOTIF_CODE_CEASE, NOTIF_SUBCODE_ADMIN_RESET, nil)
+	if w.WriteNotification(n, true) != nil {
+		return nil
+	}
+	return w.closeCh
+}
+
 func (p *peer) start() {
 	p.enableFSM(out, nil)
 	go p.run()
@@ -293,6 +372,7 @@ func (p *peer) start() {
 
 func (p *peer) stop() {
 	p.closeOnce.Do(func() {
+		p.cancel()
 		close(p.closeCh)
 	})
 	<-p.doneCh
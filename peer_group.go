@@ -0,0 +1,159 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	ErrPeerGroupNotExist      = errors.New("peer group does not exist")
+	ErrPeerGroupAlreadyExists = errors.New("peer group already exists")
+	ErrPeerGroupNotEmpty      = errors.New("peer group has members")
+)
+
+// PeerGroup is a named template shared by a set of peers. Members inherit
+// the group's Options, which may be overridden by PeerOptions provided for the
+// individual peer, and are handled by a Plugin returned from NewPlugin.
+type PeerGroup struct {
+	// Name uniquely identifies the PeerGroup within a Server.
+	Name string
+
+	// NewPlugin returns the Plugin for a member peer when it is added to the
+	// group.
+	NewPlugin func(config PeerConfig) Plugin
+
+	// Options are the default PeerOptions for members of the group.
+	Options []PeerOption
+}
+
+// AddPeerGroup adds a PeerGroup to the Server.
+func (s *Server) AddPeerGroup(group PeerGroup) error {
+	if len(group.Name) == 0 {
+		return errors.New("peer group name must be non-empty")
+	}
+	if group.NewPlugin == nil {
+		return errors.New("peer group NewPlugin must be non-nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.groups[group.Name]
+	if exists {
+		return ErrPeerGroupAlreadyExists
+	}
+	s.groups[group.Name] = group
+	return nil
+}
+
+// UpdatePeerGroup replaces the PeerGroup with the same Name and reconfigures
+// all of its members as UpdatePeer would. The update is atomic, if the
+// resulting configuration of any member is invalid no changes are made. The
+// returned map contains the PeerUpdateAction taken for each member by remote
+// address. NewPlugin only applies to members added after the update.
+func (s *Server) UpdatePeerGroup(
+	group PeerGroup) (map[netip.Addr]PeerUpdateAction, error) {
+	if group.NewPlugin == nil {
+		return nil, errors.New("peer group NewPlugin must be non-nil")
+	}
+	s.mu.Lock()
+	_, exists := s.groups[group.Name]
+	if !exists {
+		s.mu.Unlock()
+		return nil, ErrPeerGroupNotExist
+	}
+	members := s.groupMembersLocked(group.Name)
+	resolved := make([]peerOptions, 0, len(members))
+	for _, p := range members {
+		o, err := resolvePeerOptions(p.config, group.Options, p.memberOpts)
+		if err == nil {
+			err = s.transportConflictLocked(p.config, o, p)
+		}
+		if err != nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("peer %s: %w", p.config.RemoteAddress, err)
+		}
+		resolved = append(resolved, o)
+	}
+	s.groups[group.Name] = group
+	actions := make(map[netip.Addr]PeerUpdateAction, len(members))
+	waits := make([]func(), 0, len(members))
+	for i, p := range members {
+		action, wait := s.updatePeerLocked(p, p.config, resolved[i],
+			p.memberOpts)
+		actions[p.config.RemoteAddress] = action
+		waits = append(waits, wait)
+	}
+	s.mu.Unlock()
+	for _, wait := range waits {
+		wait()
+	}
+	return actions, nil
+}
+
+// DeletePeerGroup deletes a PeerGroup from the Server. A PeerGroup with members
+// cannot be deleted.
+func (s *Server) DeletePeerGroup(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.groups[name]
+	if !exists {
+		return ErrPeerGroupNotExist
+	}
+	if len(s.groupMembersLocked(name)) > 0 {
+		return ErrPeerGroupNotEmpty
+	}
+	delete(s.groups, name)
+	return nil
+}
+
+// AddPeerToGroup adds a peer to the Server as a member of the named PeerGroup.
+// opts are applied on top of the group's Options.
+func (s *Server) AddPeerToGroup(group string, config PeerConfig,
+	opts ...PeerOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, exists := s.groups[group]
+	if !exists {
+		return ErrPeerGroupNotExist
+	}
+	o, err := resolvePeerOptions(config, g.Options, opts)
+	if err != nil {
+		return err
+	}
+	err = s.addPeerLocked(config, g.NewPlugin(config), o)
+	if err != nil {
+		return err
+	}
+	p, _ := s.peers.get(config.RemoteAddress)
+	p.group = group
+	p.memberOpts = opts
+	return nil
+}
+
+// ListPeerGroupMembers returns the configuration for all members of the named
+// PeerGroup.
+func (s *Server) ListPeerGroupMembers(group string) ([]PeerConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.groups[group]
+	if !exists {
+		return nil, ErrPeerGroupNotExist
+	}
+	configs := make([]PeerConfig, 0)
+	for _, p := range s.groupMembersLocked(group) {
+		configs = append(configs, p.config)
+	}
+	return configs, nil
+}
+
+// groupMembersLocked must be called with s.mu held.
+func (s *Server) groupMembersLocked(group string) []*peer {
+	members := make([]*peer, 0)
+	s.peers.each(func(p *peer) bool {
+		if p.group == group {
+			members = append(members, p)
+		}
+		return true
+	})
+	return members
+}
@@ -0,0 +1,59 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PeerGroup(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	assert.NoError(t, err)
+
+	newPlugin := func(PeerConfig) Plugin { return nil }
+	g := PeerGroup{
+		Name:      "rs-clients",
+		NewPlugin: newPlugin,
+		Options:   []PeerOption{WithHoldTime(30)},
+	}
+	pc := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	assert.ErrorIs(t, s.AddPeerToGroup(g.Name, pc), ErrPeerGroupNotExist)
+	assert.NoError(t, s.AddPeerGroup(g))
+	assert.ErrorIs(t, s.AddPeerGroup(g), ErrPeerGroupAlreadyExists)
+
+	assert.NoError(t, s.AddPeerToGroup(g.Name, pc, WithPassive()))
+	p, _ := s.peers.get(pc.RemoteAddress)
+	assert.Equal(t, 30*time.Second, p.options().holdTime)
+	assert.Equal(t, TransportModePassive, p.options().transportMode)
+
+	members, err := s.ListPeerGroupMembers(g.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, []PeerConfig{pc}, members)
+	assert.ErrorIs(t, s.DeletePeerGroup(g.Name), ErrPeerGroupNotEmpty)
+
+	// an invalid group update is rejected without changes
+	g.Options = []PeerOption{WithHoldTime(1)}
+	_, err = s.UpdatePeerGroup(g)
+	assert.Error(t, err)
+	assert.Equal(t, 30*time.Second, p.options().holdTime)
+
+	// member overrides are retained across group updates
+	g.Options = []PeerOption{WithHoldTime(60)}
+	actions, err := s.UpdatePeerGroup(g)
+	assert.NoError(t, err)
+	assert.Equal(t, map[netip.Addr]PeerUpdateAction{
+		pc.RemoteAddress: PeerUpdateInPlace,
+	}, actions)
+	assert.Equal(t, 60*time.Second, p.options().holdTime)
+	assert.Equal(t, TransportModePassive, p.options().transportMode)
+
+	assert.NoError(t, s.DeletePeer(pc.RemoteAddress))
+	assert.NoError(t, s.DeletePeerGroup(g.Name))
+	assert.ErrorIs(t, s.DeletePeerGroup(g.Name), ErrPeerGroupNotExist)
+}
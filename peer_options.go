@@ -1,7 +1,9 @@
 package corebgp
 
 import (
+	"context"
 	"errors"
+	"net"
 	"net/netip"
 	"syscall"
 	"time"
@@ -12,9 +14,50 @@ type peerOptions struct {
 	idleHoldTime     time.Duration
 	connectRetryTime time.Duration
 	port             int
-	passive          bool
+	transportMode    TransportMode
 	dialerControlFn  func(network, address string, c syscall.RawConn) error
 	localAddress     netip.Addr
+	capabilityPolicy CapabilityPolicy
+	updateErrPolicy  UpdateErrorPolicy
+	tcpOptions       tcpOptions
+	dialFn           DialFunc
+	clock            Clock
+
+	fallbackTransports   []Transport
+	routerID             netip.Addr
+	collisionResolver    CollisionResolver
+	collisionObserver    CollisionObserver
+	md5Key               string
+	sendHoldTime         time.Duration
+	sendHoldTimeSet      bool
+	jitterMin            float64
+	eorObserver          EndOfRIBObserver
+	updateDispatcher     *UpdateDispatcher
+	pooledUpdates        bool
+	updateWriteBufSize   int
+	updateRateLimit      *UpdateRateLimit
+	inboundInterceptor   MessageInterceptor
+	outboundInterceptor  MessageInterceptor
+	livenessMonitor      LivenessMonitor
+	notificationObserver NotificationObserver
+	decodeErrorObserver  DecodeErrorObserver
+	decodeErrorData      bool
+	suppressKeepalives   bool
+	connectRacingDelay   time.Duration
+	inboundSrcPorts      [2]uint16
+	traceLevel           TraceLevel
+	sessionHistorySize   int
+	messageLimits        MessageLimits
+	remoteRouterID       netip.Addr
+	remoteSources        []netip.Prefix
+	prefixWatcher        *PrefixWatcher
+	replayBuffer         *ReplayBuffer
+	prefixCounting       bool
+	stuckPeerDetection   StuckPeerDetection
+
+	familyMismatchPolicy   FamilyMismatchPolicy
+	familyMismatchObserver FamilyMismatchObserver
+	outboundPolicy         Policy
 }
 
 func (p peerOptions) validate() error {
@@ -24,7 +67,62 @@ func (p peerOptions) validate() error {
 	if p.port < 1 || p.port > 65535 {
 		return errors.New("port must be between 1 and 65535")
 	}
-	return nil
+	if p.routerID.IsValid() &&
+		(!p.routerID.Is4() || p.routerID.IsUnspecified()) {
+		return errors.New("invalid router ID")
+	}
+	if err := validateRemoteIdentity(p.remoteRouterID,
+		p.remoteSources); err != nil {
+		return err
+	}
+	if p.transportMode > TransportModeActive {
+		return errors.New("invalid transport mode")
+	}
+	if p.sendHoldTime < 0 {
+		return errors.New("send hold time must be >= 0")
+	}
+	if err := p.stuckPeerDetection.validate(); err != nil {
+		return err
+	}
+	if p.inboundSrcPorts[0] > p.inboundSrcPorts[1] {
+		return errors.New("inbound source port min must be <= max")
+	}
+	if p.updateErrPolicy > UpdateErrorPolicyIgnore {
+		return errors.New("invalid update error policy")
+	}
+	if p.familyMismatchPolicy > FamilyMismatchPolicyReset {
+		return errors.New("invalid family mismatch policy")
+	}
+	if p.traceLevel > TraceHexDump {
+		return errors.New("invalid trace level")
+	}
+	if p.sessionHistorySize < 0 {
+		return errors.New("session history size must be >= 0")
+	}
+	if p.connectRacingDelay < 0 {
+		return errors.New("connect racing delay must be >= 0")
+	}
+	if p.updateWriteBufSize < 0 {
+		return errors.New("update write buffer size must be >= 0")
+	}
+	if err := p.messageLimits.validate(); err != nil {
+		return err
+	}
+	if p.updateRateLimit != nil {
+		if err := p.updateRateLimit.validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.outboundPolicy.validate(); err != nil {
+		return err
+	}
+	if err := validateJitterMin(p.jitterMin); err != nil {
+		return err
+	}
+	if p.clock == nil {
+		return errors.New("clock must be non-nil")
+	}
+	return p.tcpOptions.validate()
 }
 
 type PeerOption interface {
@@ -49,12 +147,17 @@ const (
 
 func defaultPeerOptions() peerOptions {
 	return peerOptions{
-		holdTime:         time.Second * time.Duration(DefaultHoldTimeSeconds),
-		idleHoldTime:     DefaultIdleHoldTime,
-		connectRetryTime: DefaultConnectRetryTime,
-		port:             DefaultPort,
-		passive:          false,
-		localAddress:     netip.Addr{},
+		holdTime:           time.Second * time.Duration(DefaultHoldTimeSeconds),
+		idleHoldTime:       DefaultIdleHoldTime,
+		connectRetryTime:   DefaultConnectRetryTime,
+		port:               DefaultPort,
+		transportMode:      TransportModeBoth,
+		localAddress:       netip.Addr{},
+		clock:              realClock{},
+		sendHoldTime:       DefaultSendHoldTime,
+		jitterMin:          DefaultJitterMin,
+		traceLevel:         TraceEvents,
+		sessionHistorySize: DefaultSessionHistorySize,
 	}
 }
 
@@ -72,14 +175,50 @@ func newFuncPeerOption(f func(*peerOptions)) *funcPeerOption {
 	}
 }
 
-// WithPassive returns a PeerOption that sets a Peer to passive mode. In passive
-// mode a peer will not dial out and will only accept incoming connections.
-func WithPassive() PeerOption {
+// TransportMode controls which side(s) of a peering may initiate the TCP
+// connection.
+type TransportMode uint8
+
+const (
+	// TransportModeBoth dials the peer and accepts connections from it. This
+	// is the default.
+	TransportModeBoth TransportMode = iota
+	// TransportModePassive never dials the peer, connections are only
+	// accepted.
+	TransportModePassive
+	// TransportModeActive never accepts connections from the peer, it is only
+	// dialed.
+	TransportModeActive
+)
+
+func (t TransportMode) String() string {
+	switch t {
+	case TransportModeBoth:
+		return "both"
+	case TransportModePassive:
+		return "passive"
+	case TransportModeActive:
+		return "active"
+	default:
+		return "unknown"
+	}
+}
+
+// WithTransportMode returns a PeerOption that sets the TransportMode for a
+// peer.
+func WithTransportMode(m TransportMode) PeerOption {
 	return newFuncPeerOption(func(o *peerOptions) {
-		o.passive = true
+		o.transportMode = m
 	})
 }
 
+// WithPassive returns a PeerOption that sets a Peer to passive mode. In passive
+// mode a peer will not dial out and will only accept incoming connections. It
+// is equivalent to WithTransportMode(TransportModePassive).
+func WithPassive() PeerOption {
+	return WithTransportMode(TransportModePassive)
+}
+
 // WithIdleHoldTime returns a PeerOption that sets the idle hold time for a
 // peer. Idle hold time controls how quickly a peer can oscillate from idle to
 // the connect state.
@@ -97,13 +236,37 @@ func WithConnectRetryTime(t time.Duration) PeerOption {
 	})
 }
 
-// WithPort returns a PeerOption that sets the TCP port for a peer.
+// WithClock returns a PeerOption that sets the Clock used by a peer's FSM
+// timers. This is intended for tests that need to advance time
+// deterministically, e.g. to cover hold timer expiry without sleeping.
+func WithClock(c Clock) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.clock = c
+	})
+}
+
+// WithPort returns a PeerOption that sets the TCP port for a peer, i.e. the
+// destination port of outbound connections. A port other than 179 is
+// typically used in testing environments where the peer does not listen on
+// the well-known port. It has no effect on inbound connections, see
+// WithInboundSourcePorts.
 func WithPort(p int) PeerOption {
 	return newFuncPeerOption(func(o *peerOptions) {
 		o.port = p
 	})
 }
 
+// WithInboundSourcePorts returns a PeerOption that requires inbound
+// connections from a peer to originate from a TCP source port within minPort
+// and maxPort, inclusive. Connections from other source ports are rejected. By
+// default inbound connections are accepted from any source port, as long as
+// they originate from one of the peer's remote addresses.
+func WithInboundSourcePorts(minPort, maxPort uint16) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.inboundSrcPorts = [2]uint16{minPort, maxPort}
+	})
+}
+
 // WithDialerControl returns a PeerOption that sets the outbound net.Dialer
 // Control field. This is commonly used to set socket options, e.g. ip TTL, tcp
 // md5, tcp_nodelay, etc...
@@ -132,3 +295,113 @@ func WithHoldTime(seconds uint16) PeerOption {
 		o.holdTime = time.Duration(seconds) * time.Second
 	})
 }
+
+// WithCapabilityPolicy returns a PeerOption that sets a CapabilityPolicy to be
+// evaluated against the capabilities received from the peer.
+func WithCapabilityPolicy(p CapabilityPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.capabilityPolicy = p
+	})
+}
+
+// UpdateErrorPolicy controls how a non-nil Notification returned by an
+// UpdateMessageHandler is handled. The session is either reset or the error
+// is ignored, the routes of an UPDATE message are never withdrawn on behalf of
+// the UpdateMessageHandler. An UpdateMessageHandler implementing
+// "treat-as-withdraw" (RFC7606) must withdraw the routes itself and return a
+// nil Notification, see TreatAsWithdrawUpdateErr.
+type UpdateErrorPolicy uint8
+
+const (
+	// UpdateErrorPolicyReset sends the Notification to the peer and
+	// transitions the FSM out of the Established state. This is the default.
+	UpdateErrorPolicyReset UpdateErrorPolicy = iota
+	// UpdateErrorPolicyIgnore logs and counts Notifications with the Error
+	// Code UPDATE Message Error without sending them, and the session remains
+	// established. Notifications with any other Error Code are handled per
+	// UpdateErrorPolicyReset. Ignored Notifications are counted in
+	// PeerStats.UpdateErrorsIgnored.
+	UpdateErrorPolicyIgnore
+)
+
+// WithUpdateErrorPolicy returns a PeerOption that sets the UpdateErrorPolicy
+// for a peer. This is useful for collectors that prioritize session uptime
+// over strictness.
+func WithUpdateErrorPolicy(p UpdateErrorPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateErrPolicy = p
+	})
+}
+
+// DialFunc dials address on the named network. Its signature matches that of
+// net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// WithDialer returns a PeerOption that replaces the default TCP dialer used for
+// outbound connections with fn. This can be used to run sessions over proxies,
+// userspace tunnels, or instrumented connections. The PeerOptions that
+// configure the default dialer (WithLocalAddress source selection,
+// WithDialerControl, WithBindToDevice, and WithTCPMD5Key) have no effect on
+// outbound connections when a custom dialer is set; fn is responsible for any
+// such behavior.
+func WithDialer(fn DialFunc) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.dialFn = fn
+	})
+}
+
+// Transport is a pair of addresses over which a peer may be reached.
+type Transport struct {
+	// RemoteAddress is the remote address of the peer.
+	RemoteAddress netip.Addr
+
+	// LocalAddress is the optional source address for outbound connections.
+	// If set, inbound connections must be destined to it.
+	LocalAddress netip.Addr
+}
+
+// WithFallbackTransports returns a PeerOption that sets additional transports
+// for a peer, e.g. an IPv4 address pair to fall back to when the IPv6 address
+// pair in PeerConfig is unreachable. Outbound connections are attempted with
+// the transport described by PeerConfig and WithLocalAddress first, followed
+// by transports in order, with the time until the ConnectRetryTimer expires
+// divided evenly between the transports remaining to be attempted. Inbound
+// connections are accepted from any of the peer's transports. The transport
+// in use is reported in SessionInfo.
+func WithFallbackTransports(transports ...Transport) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.fallbackTransports = transports
+	})
+}
+
+// WithRouterID returns a PeerOption that sets the local BGP identifier used
+// with a peer, overriding the router ID of the Server. This allows a single
+// Server to terminate sessions on behalf of multiple logical routers, e.g.
+// route server instances or per-VRF identities. As with NewServer, routerID
+// may be any non-zero IPv4 address.
+func WithRouterID(routerID netip.Addr) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.routerID = routerID
+	})
+}
+
+// WithUpdateWriteBuffer returns a PeerOption that enables buffering of update
+// messages sent via the UpdateMessageWriter for a peer. Update messages are
+// written once at least size bytes are buffered, or when SessionWriter.Flush
+// is called, which reduces the number of syscalls and TCP segments for
+// high-rate route injection. Buffered update messages are not sent until one of these occurs.
+// A size of 0, the default, disables buffering.
+func WithUpdateWriteBuffer(size int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateWriteBufSize = size
+	})
+}
+
+// inboundSrcPortAllowed returns true if port is allowed as the source port of
+// an inbound connection, see WithInboundSourcePorts.
+func (p peerOptions) inboundSrcPortAllowed(port uint16) bool {
+	if p.inboundSrcPorts == [2]uint16{} {
+		return true
+	}
+	return port >= p.inboundSrcPorts[0] && port <= p.inboundSrcPorts[1]
+}
@@ -0,0 +1,159 @@
+package corebgp
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// peerTableShards is the number of shards of a peerTable, a power of 2.
+const peerTableShards = 64
+
+// peerTable indexes the peers of a Server by remote address, including the
+// remote addresses of their fallback transports. It is sharded by address so
+// that lookups, e.g. those of the Server's per-peer methods and the matching
+// of inbound connections, neither contend with each other nor with the
+// Server's mutex. Modifications are serialized by the caller holding
+// Server.mu, so that a sequence of modifications, e.g. checking for transport
+// conflicts and then adding a peer, is atomic with respect to others.
+type peerTable struct {
+	shards [peerTableShards]peerTableShard
+}
+
+type peerTableShard struct {
+	mu    sync.RWMutex
+	peers map[netip.Addr]*peer
+	// transports maps the remote addresses of fallback transports to their
+	// peer
+	transports map[netip.Addr]*peer
+}
+
+func newPeerTable() *peerTable {
+	t := &peerTable{}
+	for i := range t.shards {
+		t.shards[i].peers = make(map[netip.Addr]*peer)
+		t.shards[i].transports = make(map[netip.Addr]*peer)
+	}
+	return t
+}
+
+// shard returns the shard of addr, selected by the FNV-1a hash of its 16 byte
+// representation.
+func (t *peerTable) shard(addr netip.Addr) *peerTableShard {
+	h := uint32(2166136261)
+	for _, b := range addr.As16() {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+	return &t.shards[h&(peerTableShards-1)]
+}
+
+// get returns the peer with remote address addr.
+func (t *peerTable) get(addr netip.Addr) (*peer, bool) {
+	s := t.shard(addr)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.peers[addr]
+	return p, ok
+}
+
+// forInbound returns the peer with a transport matching the remote address
+// of an inbound connection, along with the local address the connection must
+// be destined to, if any.
+func (t *peerTable) forInbound(remote netip.Addr) (*peer, netip.Addr, bool) {
+	p, ok := t.get(remote)
+	if ok {
+		return p, p.options().localAddress, true
+	}
+	s := t.shard(remote)
+	s.mu.RLock()
+	p, ok = s.transports[remote]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, netip.Addr{}, false
+	}
+	for _, tr := range p.options().fallbackTransports {
+		if tr.RemoteAddress == remote {
+			return p, tr.LocalAddress, true
+		}
+	}
+	// the fallback transport was removed concurrently
+	return nil, netip.Addr{}, false
+}
+
+// set indexes p by its remote address, replacing the peer previously indexed
+// by it, if any, and by the remote addresses of its fallback transports.
+// oldFallbacks are the fallback transports the address was previously
+// indexed with, those no longer in use are removed.
+func (t *peerTable) set(p *peer, oldFallbacks []Transport) {
+	s := t.shard(p.config.RemoteAddress)
+	s.mu.Lock()
+	s.peers[p.config.RemoteAddress] = p
+	s.mu.Unlock()
+	fallbacks := p.options().fallbackTransports
+	for _, tr := range oldFallbacks {
+		if !hasTransport(fallbacks, tr.RemoteAddress) {
+			t.deleteTransport(tr.RemoteAddress)
+		}
+	}
+	for _, tr := range fallbacks {
+		s := t.shard(tr.RemoteAddress)
+		s.mu.Lock()
+		s.transports[tr.RemoteAddress] = p
+		s.mu.Unlock()
+	}
+}
+
+// delete removes p, and its fallback transports, from the table.
+func (t *peerTable) delete(p *peer) {
+	s := t.shard(p.config.RemoteAddress)
+	s.mu.Lock()
+	delete(s.peers, p.config.RemoteAddress)
+	s.mu.Unlock()
+	for _, tr := range p.options().fallbackTransports {
+		t.deleteTransport(tr.RemoteAddress)
+	}
+}
+
+func (t *peerTable) deleteTransport(remote netip.Addr) {
+	s := t.shard(remote)
+	s.mu.Lock()
+	delete(s.transports, remote)
+	s.mu.Unlock()
+}
+
+func hasTransport(transports []Transport, remote netip.Addr) bool {
+	for _, tr := range transports {
+		if tr.RemoteAddress == remote {
+			return true
+		}
+	}
+	return false
+}
+
+// each calls fn for each peer until fn returns false. Shards are read locked
+// in turn while fn is called, so fn must not block or modify the table.
+// Without Server.mu held the peers visited may reflect concurrent
+// modifications.
+func (t *peerTable) each(fn func(p *peer) bool) {
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.RLock()
+		for _, p := range s.peers {
+			if !fn(p) {
+				s.mu.RUnlock()
+				return
+			}
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// list returns all peers, see each.
+func (t *peerTable) list() []*peer {
+	peers := make([]*peer, 0)
+	t.each(func(p *peer) bool {
+		peers = append(peers, p)
+		return true
+	})
+	return peers
+}
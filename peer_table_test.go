@@ -0,0 +1,108 @@
+package corebgp
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerTable(t *testing.T) {
+	newTestPeer := func(remote string, fallbacks ...Transport) *peer {
+		o := defaultPeerOptions()
+		o.localAddress = netip.MustParseAddr("192.0.2.254")
+		o.fallbackTransports = fallbacks
+		p := &peer{config: PeerConfig{
+			RemoteAddress: netip.MustParseAddr(remote),
+		}}
+		p.opts.Store(&o)
+		return p
+	}
+	a := netip.MustParseAddr("192.0.2.1")
+	fa := netip.MustParseAddr("2001:db8::1")
+	fb := netip.MustParseAddr("2001:db8::2")
+	fallbackA := Transport{
+		RemoteAddress: fa,
+		LocalAddress:  netip.MustParseAddr("2001:db8::ff"),
+	}
+
+	table := newPeerTable()
+	p := newTestPeer("192.0.2.1", fallbackA)
+	table.set(p, nil)
+	got, ok := table.get(a)
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+	_, ok = table.get(fa)
+	assert.False(t, ok, "fallback transports are not peers")
+
+	got, local, ok := table.forInbound(a)
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.254"), local)
+	got, local, ok = table.forInbound(fa)
+	assert.True(t, ok)
+	assert.Equal(t, p, got)
+	assert.Equal(t, fallbackA.LocalAddress, local)
+
+	// replacing the fallback transports
+	np := newTestPeer("192.0.2.1", Transport{RemoteAddress: fb})
+	table.set(np, p.options().fallbackTransports)
+	_, _, ok = table.forInbound(fa)
+	assert.False(t, ok)
+	got, _, ok = table.forInbound(fb)
+	assert.True(t, ok)
+	assert.Equal(t, np, got)
+
+	for i := 2; i < 200; i++ {
+		addr := netip.AddrFrom4([4]byte{198, 51, byte(i / 100), byte(i)})
+		table.set(newTestPeer(addr.String()), nil)
+	}
+	assert.Len(t, table.list(), 199)
+	visited := 0
+	table.each(func(p *peer) bool {
+		visited++
+		return visited < 10
+	})
+	assert.Equal(t, 10, visited)
+
+	table.delete(np)
+	_, ok = table.get(a)
+	assert.False(t, ok)
+	_, _, ok = table.forInbound(fb)
+	assert.False(t, ok)
+	assert.Len(t, table.list(), 198)
+}
+
+func TestServer_concurrentPeers(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				addr := netip.AddrFrom4([4]byte{198, 51, byte(i), byte(j)})
+				err := s.AddPeer(PeerConfig{
+					RemoteAddress: addr,
+					LocalAS:       64512,
+					RemoteAS:      64513,
+				}, nil, WithPassive())
+				assert.NoError(t, err)
+				_, err = s.GetPeer(addr)
+				assert.NoError(t, err)
+				_, err = s.GetPeerStats(addr)
+				assert.NoError(t, err)
+				s.ListPeers()
+				if j%2 == 0 {
+					assert.NoError(t, s.DeletePeer(addr))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, s.ListPeers(), 8*25)
+}
@@ -51,3 +51,36 @@ type UpdateMessageWriter interface {
 	// state.
 	WriteUpdate([]byte) error
 }
+
+// SessionWriter is an UpdateMessageWriter with additional methods for the
+// established session it is bound to. The UpdateMessageWriter provided to
+// Plugin.OnEstablished by a Server implements SessionWriter, callers may
+// access it via a type assertion. It is kept separate from
+// UpdateMessageWriter so that existing implementations of the latter, e.g.
+// those wrapping a writer, continue to satisfy it.
+type SessionWriter interface {
+	UpdateMessageWriter
+
+	// WriteUpdates sends multiple update messages to the remote peer,
+	// coalescing them into as few writes as possible. An error is returned if
+	// the write fails and/or the FSM is no longer in an established state.
+	WriteUpdates([][]byte) error
+
+	// Flush writes any update messages buffered by WriteUpdate and
+	// WriteUpdates, see WithUpdateWriteBuffer. An error is returned if the
+	// write fails and/or the FSM is no longer in an established state.
+	Flush() error
+
+	// WriteNotification sends a Notification message to the remote peer. If
+	// closeSession is true the Notification is sent by the FSM, which then
+	// transitions out of the Established state. Otherwise the Notification is
+	// written immediately and the session remains established. An error is
+	// returned if the write fails and/or the FSM is no longer in an
+	// established state. Update messages buffered by WriteUpdate and
+	// WriteUpdates are written ahead of the Notification in either case.
+	WriteNotification(n *Notification, closeSession bool) error
+
+	// SessionInfo returns the parameters negotiated for the established
+	// session the writer is bound to.
+	SessionInfo() SessionInfo
+}
@@ -0,0 +1,113 @@
+package corebgp
+
+import (
+	"context"
+	"net/netip"
+)
+
+// UpdateConsumed may be returned by an UpdateMessageHandler of a Plugin in a
+// PluginChain to indicate that it has consumed the Update message, in which
+// case the handlers of subsequent plugins are not fired. It is treated as a
+// nil Notification outside of a PluginChain.
+var UpdateConsumed = &Notification{}
+
+// NewPluginChain returns a Plugin that layers plugins in order, e.g. to apply
+// cross-cutting concerns such as logging or metrics ahead of an application's
+// Plugin.
+//
+// The capabilities of all plugins are merged, omitting duplicates. Open
+// messages are passed to each plugin in turn until one returns a non-nil
+// Notification. OnEstablished and OnClose are fired for every plugin.
+//
+// Update messages are passed to the UpdateMessageHandler of each plugin in
+// turn until one returns a non-nil Notification, or UpdateConsumed. Handlers
+// in a chain must not call ReleaseUpdateBuffer, the chain releases the message
+// once handling completes. When WithPooledUpdateBuffers is used handlers must
+// therefore not retain the message after returning.
+func NewPluginChain(plugins ...Plugin) Plugin {
+	return &pluginChain{
+		plugins: append([]Plugin{}, plugins...),
+	}
+}
+
+type pluginChain struct {
+	plugins []Plugin
+}
+
+func (p *pluginChain) GetCapabilities(peer PeerConfig) []Capability {
+	return p.getCapabilities(context.Background(), peer)
+}
+
+func (p *pluginChain) getCapabilities(ctx context.Context,
+	peer PeerConfig) []Capability {
+	caps := make([]Capability, 0)
+	for _, plugin := range p.plugins {
+		for _, c := range pluginGetCapabilities(ctx, plugin, peer) {
+			dup := false
+			for _, existing := range caps {
+				if existing.Equal(c) {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				caps = append(caps, c)
+			}
+		}
+	}
+	return caps
+}
+
+func (p *pluginChain) OnOpenMessage(peer PeerConfig, routerID netip.Addr,
+	capabilities []Capability) *Notification {
+	return p.onOpenMessage(context.Background(), peer, routerID, capabilities)
+}
+
+func (p *pluginChain) onOpenMessage(ctx context.Context, peer PeerConfig,
+	routerID netip.Addr, capabilities []Capability) *Notification {
+	for _, plugin := range p.plugins {
+		n := pluginOnOpenMessage(ctx, plugin, peer, routerID, capabilities)
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (p *pluginChain) OnEstablished(peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	return p.onEstablished(context.Background(), peer, writer)
+}
+
+func (p *pluginChain) onEstablished(ctx context.Context, peer PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	handlers := make([]UpdateMessageHandler, 0, len(p.plugins))
+	for _, plugin := range p.plugins {
+		h := pluginOnEstablished(ctx, plugin, peer, writer)
+		if h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	if len(handlers) == 0 {
+		return nil
+	}
+	return func(peer PeerConfig, updateMessage []byte) *Notification {
+		defer ReleaseUpdateBuffer(updateMessage)
+		for _, h := range handlers {
+			n := h(peer, updateMessage)
+			if n == UpdateConsumed {
+				return nil
+			}
+			if n != nil {
+				return n
+			}
+		}
+		return nil
+	}
+}
+
+func (p *pluginChain) OnClose(peer PeerConfig) {
+	for _, plugin := range p.plugins {
+		plugin.OnClose(peer)
+	}
+}
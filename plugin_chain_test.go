@@ -0,0 +1,69 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chainTestPlugin struct {
+	nopPlugin
+	caps    []Capability
+	open    *Notification
+	update  *Notification
+	updates int
+	closes  int
+}
+
+func (c *chainTestPlugin) GetCapabilities(PeerConfig) []Capability {
+	return c.caps
+}
+
+func (c *chainTestPlugin) OnOpenMessage(PeerConfig, netip.Addr,
+	[]Capability) *Notification {
+	return c.open
+}
+
+func (c *chainTestPlugin) OnEstablished(PeerConfig,
+	UpdateMessageWriter) UpdateMessageHandler {
+	return func(PeerConfig, []byte) *Notification {
+		c.updates++
+		return c.update
+	}
+}
+
+func (c *chainTestPlugin) OnClose(PeerConfig) {
+	c.closes++
+}
+
+func TestPluginChain(t *testing.T) {
+	ipv4 := NewMPExtensionsCapability(AFI_IPV4, SAFI_UNICAST)
+	ipv6 := NewMPExtensionsCapability(AFI_IPV6, SAFI_UNICAST)
+	a := &chainTestPlugin{caps: []Capability{ipv4}}
+	b := &chainTestPlugin{caps: []Capability{ipv4, ipv6}}
+	c := &chainTestPlugin{}
+	chain := NewPluginChain(a, b, c)
+	peer := PeerConfig{}
+
+	assert.Equal(t, []Capability{ipv4, ipv6}, chain.GetCapabilities(peer))
+
+	assert.Nil(t, chain.OnOpenMessage(peer, netip.Addr{}, nil))
+	b.open = newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, 0, nil)
+	assert.Equal(t, b.open, chain.OnOpenMessage(peer, netip.Addr{}, nil))
+
+	handler := chain.OnEstablished(peer, nil)
+	assert.Nil(t, handler(peer, nil))
+	assert.Equal(t, []int{1, 1, 1}, []int{a.updates, b.updates, c.updates})
+
+	b.update = UpdateConsumed
+	assert.Nil(t, handler(peer, nil))
+	assert.Equal(t, []int{2, 2, 1}, []int{a.updates, b.updates, c.updates})
+
+	a.update = newNotification(NOTIF_CODE_UPDATE_MESSAGE_ERR, 0, nil)
+	assert.Equal(t, a.update, handler(peer, nil))
+	assert.Equal(t, []int{3, 2, 1}, []int{a.updates, b.updates, c.updates})
+
+	chain.OnClose(peer)
+	assert.Equal(t, []int{1, 1, 1}, []int{a.closes, b.closes, c.closes})
+}
@@ -0,0 +1,456 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"sort"
+)
+
+// Policy is an ordered list of Actions that modify the path attributes of
+// routes advertised to a peer, e.g. to prepend to the AS_PATH or tag routes
+// with communities. Policies may be applied by Plugins to the path attributes
+// of their routes via Apply, or to all UPDATE messages written to a peer via
+// WithOutboundPolicy.
+//
+// Actions operate on path attributes as they appear in the Path Attributes
+// field of an UPDATE message. The AS_PATH attribute is assumed to contain
+// four-octet AS numbers, i.e. the Support for 4-octet AS Number Capability is
+// negotiated.
+type Policy []Action
+
+// Action modifies a set of path attributes, see Policy.
+type Action interface {
+	apply(attrs *pathAttrList) error
+	validate() error
+}
+
+type funcAction struct {
+	fn  func(attrs *pathAttrList) error
+	err error
+}
+
+func (f *funcAction) apply(attrs *pathAttrList) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.fn(attrs)
+}
+
+func (f *funcAction) validate() error {
+	return f.err
+}
+
+func newFuncAction(fn func(attrs *pathAttrList) error) *funcAction {
+	return &funcAction{
+		fn: fn,
+	}
+}
+
+// newInvalidAction returns an Action that fails to apply with err, for
+// constructors that received invalid arguments.
+func newInvalidAction(err error) *funcAction {
+	return &funcAction{
+		err: err,
+	}
+}
+
+// validate returns the first error of an invalid Action of p.
+func (p Policy) validate() error {
+	for _, a := range p {
+		if err := a.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errMalformedPathAttrs = errors.New("malformed path attributes")
+
+// Apply returns the path attributes in attrs, as they appear in the Path
+// Attributes field of an UPDATE message, modified by each Action of p in
+// order. attrs is not modified. Path attributes are returned in ascending
+// order of their type code.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-5
+// The sender of an UPDATE message SHOULD order path attributes within the
+// UPDATE message in ascending order of attribute type.
+func (p Policy) Apply(attrs []byte) ([]byte, error) {
+	l, err := parsePathAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range p {
+		err = a.apply(&l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l.encode(), nil
+}
+
+// applyUpdate applies p to the path attributes of the UPDATE message b, which
+// must not include the message header. UPDATE messages without path
+// attributes, i.e. those that only withdraw routes, are returned unmodified.
+func (p Policy) applyUpdate(b []byte) ([]byte, error) {
+	if len(b) < 2 {
+		return nil, errMalformedPathAttrs
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 4+withdrawnLen {
+		return nil, errMalformedPathAttrs
+	}
+	attrsStart := 4 + withdrawnLen
+	attrsLen := int(binary.BigEndian.Uint16(b[2+withdrawnLen:]))
+	if len(b) < attrsStart+attrsLen {
+		return nil, errMalformedPathAttrs
+	}
+	if attrsLen == 0 {
+		return b, nil
+	}
+	attrs, err := p.Apply(b[attrsStart : attrsStart+attrsLen])
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) > 0xffff {
+		return nil, errors.New("path attributes too long")
+	}
+	nlri := b[attrsStart+attrsLen:]
+	out := make([]byte, 0, attrsStart+len(attrs)+len(nlri))
+	out = append(out, b[:2+withdrawnLen]...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(attrs)))
+	out = append(out, attrs...)
+	return append(out, nlri...), nil
+}
+
+// WithOutboundPolicy returns a PeerOption that applies p to the path
+// attributes of every UPDATE message written to the peer via its
+// UpdateMessageWriter. A write fails without writing any of its UPDATE
+// messages if p cannot be applied to one of them. Adding or updating a peer
+// fails if p contains an Action constructed with invalid arguments.
+func WithOutboundPolicy(p Policy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.outboundPolicy = p
+	})
+}
+
+type pathAttr struct {
+	flags PathAttrFlags
+	code  uint8
+	data  []byte
+}
+
+// pathAttrList is a parsed Path Attributes field.
+type pathAttrList []pathAttr
+
+func parsePathAttrs(b []byte) (pathAttrList, error) {
+	l := make(pathAttrList, 0)
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errMalformedPathAttrs
+		}
+		a := pathAttr{
+			flags: PathAttrFlags(b[0]),
+			code:  b[1],
+		}
+		var attrLen int
+		if a.flags.ExtendedLen() {
+			if len(b) < 4 {
+				return nil, errMalformedPathAttrs
+			}
+			attrLen = int(binary.BigEndian.Uint16(b[2:]))
+			b = b[4:]
+		} else {
+			attrLen = int(b[2])
+			b = b[3:]
+		}
+		if len(b) < attrLen {
+			return nil, errMalformedPathAttrs
+		}
+		a.data = b[:attrLen]
+		b = b[attrLen:]
+		l = append(l, a)
+	}
+	return l, nil
+}
+
+func (l pathAttrList) encode() []byte {
+	sorted := append(pathAttrList{}, l...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].code < sorted[j].code
+	})
+	b := make([]byte, 0)
+	for _, a := range sorted {
+		b = AppendPathAttr(b, a.flags, a.code, a.data)
+	}
+	return b
+}
+
+// get returns the data of the first path attribute with code, and true if it
+// is present.
+func (l pathAttrList) get(code uint8) ([]byte, bool) {
+	for _, a := range l {
+		if a.code == code {
+			return a.data, true
+		}
+	}
+	return nil, false
+}
+
+// set replaces the data of the path attributes with code, or adds it with
+// flags if it is not present. The flags of a present attribute are retained,
+// other than Extended Length, which is set according to the length of data
+// when encoded.
+func (l *pathAttrList) set(flags PathAttrFlags, code uint8, data []byte) {
+	for _, a := range *l {
+		if a.code == code {
+			flags = a.flags
+			break
+		}
+	}
+	l.remove(code)
+	*l = append(*l, pathAttr{flags: flags, code: code, data: data})
+}
+
+// remove removes the path attributes with code.
+func (l *pathAttrList) remove(code uint8) {
+	out := (*l)[:0]
+	for _, a := range *l {
+		if a.code != code {
+			out = append(out, a)
+		}
+	}
+	*l = out
+}
+
+// PrependASPath returns an Action that prepends asn to the AS_PATH count
+// times, e.g. to make routes advertised to a peer less preferred. An AS_PATH
+// is added if none is present. count must be between 0 and 255, the maximum
+// number of AS numbers in a path segment.
+func PrependASPath(asn uint32, count int) Action {
+	if count < 0 || count > maxASPathSegmentLen {
+		return newInvalidAction(errors.New(
+			"AS_PATH prepend count must be between 0 and 255"))
+	}
+	return newFuncAction(func(attrs *pathAttrList) error {
+		var path ASPath
+		if b, ok := attrs.get(PATH_ATTR_AS_PATH); ok {
+			var err error
+			path, err = DecodeASPath(b)
+			if err != nil {
+				return err
+			}
+		}
+		asns := make([]uint32, count)
+		for i := range asns {
+			asns[i] = asn
+		}
+		attrs.set(0x40, PATH_ATTR_AS_PATH, path.Prepend(asns...).Encode())
+		return nil
+	})
+}
+
+// Encode returns the COMMUNITIES attribute data for c.
+func (c CommunitiesPathAttr) Encode() []byte {
+	b := make([]byte, 0, len(c)*4)
+	for _, community := range c {
+		b = binary.BigEndian.AppendUint32(b, community)
+	}
+	return b
+}
+
+// Encode returns the LARGE_COMMUNITY attribute data for l.
+func (l LargeCommunitiesPathAttr) Encode() []byte {
+	b := make([]byte, 0, len(l)*12)
+	for _, c := range l {
+		b = binary.BigEndian.AppendUint32(b, c.GlobalAdmin)
+		b = binary.BigEndian.AppendUint32(b, c.LocalData1)
+		b = binary.BigEndian.AppendUint32(b, c.LocalData2)
+	}
+	return b
+}
+
+func (l *pathAttrList) communities() CommunitiesPathAttr {
+	b, _ := l.get(PATH_ATTR_COMMUNITY)
+	c := make(CommunitiesPathAttr, 0, len(b)/4)
+	for ; len(b) >= 4; b = b[4:] {
+		c = append(c, binary.BigEndian.Uint32(b))
+	}
+	return c
+}
+
+func (l *pathAttrList) setCommunities(c CommunitiesPathAttr) {
+	if len(c) == 0 {
+		l.remove(PATH_ATTR_COMMUNITY)
+		return
+	}
+	l.set(0xc0, PATH_ATTR_COMMUNITY, c.Encode())
+}
+
+func (l *pathAttrList) largeCommunities() LargeCommunitiesPathAttr {
+	b, _ := l.get(PATH_ATTR_LARGE_COMMUNITY)
+	c := make(LargeCommunitiesPathAttr, 0, len(b)/12)
+	for ; len(b) >= 12; b = b[12:] {
+		c = append(c, LargeCommunity{
+			GlobalAdmin: binary.BigEndian.Uint32(b),
+			LocalData1:  binary.BigEndian.Uint32(b[4:]),
+			LocalData2:  binary.BigEndian.Uint32(b[8:]),
+		})
+	}
+	return c
+}
+
+func (l *pathAttrList) setLargeCommunities(c LargeCommunitiesPathAttr) {
+	if len(c) == 0 {
+		l.remove(PATH_ATTR_LARGE_COMMUNITY)
+		return
+	}
+	l.set(0xc0, PATH_ATTR_LARGE_COMMUNITY, c.Encode())
+}
+
+// addUnique appends the members of add to s that are not already present.
+func addUnique[T comparable](s []T, add []T) []T {
+	for _, v := range add {
+		if !contains(s, v) {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+// removeAll returns s without the members of remove.
+func removeAll[T comparable](s []T, remove []T) []T {
+	out := s[:0]
+	for _, v := range s {
+		if !contains(remove, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func contains[T comparable](s []T, v T) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCommunities returns an Action that replaces the COMMUNITIES attribute
+// with c. The attribute is removed if c is empty.
+//
+// https://www.rfc-editor.org/rfc/rfc1997
+func SetCommunities(c ...uint32) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setCommunities(addUnique(nil, c))
+		return nil
+	})
+}
+
+// AddCommunities returns an Action that adds the members of c not already
+// present to the COMMUNITIES attribute.
+func AddCommunities(c ...uint32) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setCommunities(addUnique(attrs.communities(), c))
+		return nil
+	})
+}
+
+// RemoveCommunities returns an Action that removes the members of c from the
+// COMMUNITIES attribute. The attribute is removed if no communities remain.
+func RemoveCommunities(c ...uint32) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setCommunities(removeAll(attrs.communities(), c))
+		return nil
+	})
+}
+
+// SetLargeCommunities returns an Action that replaces the LARGE_COMMUNITY
+// attribute with l. The attribute is removed if l is empty.
+//
+// https://www.rfc-editor.org/rfc/rfc8092
+func SetLargeCommunities(l ...LargeCommunity) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setLargeCommunities(addUnique(nil, l))
+		return nil
+	})
+}
+
+// AddLargeCommunities returns an Action that adds the members of l not
+// already present to the LARGE_COMMUNITY attribute.
+func AddLargeCommunities(l ...LargeCommunity) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setLargeCommunities(addUnique(attrs.largeCommunities(), l))
+		return nil
+	})
+}
+
+// RemoveLargeCommunities returns an Action that removes the members of l from
+// the LARGE_COMMUNITY attribute. The attribute is removed if no large
+// communities remain.
+func RemoveLargeCommunities(l ...LargeCommunity) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.setLargeCommunities(removeAll(attrs.largeCommunities(), l))
+		return nil
+	})
+}
+
+// SetMED returns an Action that sets the MULTI_EXIT_DISC attribute to med.
+func SetMED(med uint32) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.set(0x80, PATH_ATTR_MED, binary.BigEndian.AppendUint32(nil, med))
+		return nil
+	})
+}
+
+// SetLocalPref returns an Action that sets the LOCAL_PREF attribute to
+// localPref. LOCAL_PREF must only be sent to internal peers.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-5.1.5
+// A BGP speaker MUST NOT include this attribute in UPDATE messages it sends
+// to external peers, except in the case of BGP Confederations [RFC3065].
+func SetLocalPref(localPref uint32) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		attrs.set(0x40, PATH_ATTR_LOCAL_PREF,
+			binary.BigEndian.AppendUint32(nil, localPref))
+		return nil
+	})
+}
+
+// SetNextHop returns an Action that sets the next hop to nh. The next hop
+// field of an MP_REACH_NLRI attribute is replaced with nh, and, if nh is an
+// IPv4 address, the NEXT_HOP attribute is set to nh unless an MP_REACH_NLRI
+// attribute is present without one. Applying the Action fails if nh is an
+// IPv6 address and a NEXT_HOP attribute is present, as the NEXT_HOP of IPv4
+// routes outside of MP_REACH_NLRI cannot carry it.
+func SetNextHop(nh netip.Addr) Action {
+	return newFuncAction(func(attrs *pathAttrList) error {
+		if !nh.IsValid() {
+			return errors.New("invalid next hop")
+		}
+		reach, hasReach := attrs.get(PATH_ATTR_MP_REACH_NLRI)
+		if hasReach {
+			if len(reach) < 5 || len(reach) < 5+int(reach[3]) {
+				return errMalformedPathAttrs
+			}
+			addr := nh.AsSlice()
+			data := make([]byte, 0, len(reach)-int(reach[3])+len(addr))
+			data = append(data, reach[:3]...)
+			data = append(data, uint8(len(addr)))
+			data = append(data, addr...)
+			data = append(data, reach[4+int(reach[3]):]...)
+			attrs.set(0x80, PATH_ATTR_MP_REACH_NLRI, data)
+		}
+		_, hasNextHop := attrs.get(PATH_ATTR_NEXT_HOP)
+		if !nh.Is4() && hasNextHop {
+			return errors.New("IPv6 next hop cannot be set in NEXT_HOP")
+		}
+		if nh.Is4() && (hasNextHop || !hasReach) {
+			a := nh.As4()
+			attrs.set(0x40, PATH_ATTR_NEXT_HOP, a[:])
+		}
+		return nil
+	})
+}
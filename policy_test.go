@@ -0,0 +1,171 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyApply(t *testing.T) {
+	var attrs []byte
+	attrs = AppendPathAttr(attrs, 0xc0, PATH_ATTR_COMMUNITY,
+		CommunitiesPathAttr{1, 2, 3}.Encode())
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_ORIGIN, []byte{0})
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_AS_PATH, ASPath{{
+		Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{64513},
+	}}.Encode())
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_NEXT_HOP,
+		[]byte{192, 0, 2, 1})
+	orig := append([]byte{}, attrs...)
+
+	large := LargeCommunity{GlobalAdmin: 64512, LocalData1: 1, LocalData2: 2}
+	p := Policy{
+		PrependASPath(64512, 2),
+		RemoveCommunities(2),
+		AddCommunities(3, 4),
+		AddLargeCommunities(large, large),
+		SetMED(10),
+		SetNextHop(netip.MustParseAddr("198.51.100.1")),
+	}
+	got, err := p.Apply(attrs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, orig, attrs)
+
+	var want []byte
+	want = AppendPathAttr(want, 0x40, PATH_ATTR_ORIGIN, []byte{0})
+	want = AppendPathAttr(want, 0x40, PATH_ATTR_AS_PATH, ASPath{{
+		Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{64512, 64512, 64513},
+	}}.Encode())
+	want = AppendPathAttr(want, 0x40, PATH_ATTR_NEXT_HOP,
+		[]byte{198, 51, 100, 1})
+	want = AppendPathAttr(want, 0x80, PATH_ATTR_MED, []byte{0, 0, 0, 10})
+	want = AppendPathAttr(want, 0xc0, PATH_ATTR_COMMUNITY,
+		CommunitiesPathAttr{1, 3, 4}.Encode())
+	want = AppendPathAttr(want, 0xc0, PATH_ATTR_LARGE_COMMUNITY,
+		LargeCommunitiesPathAttr{large}.Encode())
+	assert.Equal(t, want, got)
+
+	got, err = Policy{
+		SetCommunities(),
+		SetLargeCommunities(),
+		RemoveLargeCommunities(large),
+		SetLocalPref(200),
+	}.Apply(attrs)
+	if assert.NoError(t, err) {
+		x, err := NewUpdateIndex(append([]byte{0, 0, 0, uint8(len(got))},
+			got...))
+		if assert.NoError(t, err) {
+			assert.False(t, x.Has(PATH_ATTR_COMMUNITY))
+			assert.False(t, x.Has(PATH_ATTR_LARGE_COMMUNITY))
+			_, lp, ok := x.Attr(PATH_ATTR_LOCAL_PREF)
+			assert.True(t, ok)
+			assert.Equal(t, uint32(200), binary.BigEndian.Uint32(lp))
+		}
+	}
+
+	_, err = p.Apply([]byte{0x40, PATH_ATTR_ORIGIN, 2, 0})
+	assert.Error(t, err)
+}
+
+func TestPolicySetNextHopMPReach(t *testing.T) {
+	reach := []byte{0, 2, 1, 32}
+	reach = append(reach, make([]byte, 32)...)
+	reach = append(reach, 0, 32, 0x20, 0x01, 0x0d, 0xb8)
+	attrs := AppendPathAttr(nil, 0x80, PATH_ATTR_MP_REACH_NLRI, reach)
+	nh := netip.MustParseAddr("2001:db8::1")
+	got, err := Policy{SetNextHop(nh)}.Apply(attrs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	want := []byte{0, 2, 1, 16}
+	want = append(want, nh.AsSlice()...)
+	want = append(want, 0, 32, 0x20, 0x01, 0x0d, 0xb8)
+	assert.Equal(t, AppendPathAttr(nil, 0x80, PATH_ATTR_MP_REACH_NLRI, want),
+		got)
+}
+
+func TestPolicyApplyUpdate(t *testing.T) {
+	p := Policy{SetMED(10)}
+	withdraw := []byte{0, 4, 24, 192, 0, 2, 0, 0}
+	got, err := p.applyUpdate(withdraw)
+	assert.NoError(t, err)
+	assert.Equal(t, withdraw, got)
+
+	b := newIndexTestUpdate(0, 1)
+	got, err = p.applyUpdate(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	x, err := NewUpdateIndex(got)
+	if assert.NoError(t, err) {
+		_, med, ok := x.Attr(PATH_ATTR_MED)
+		assert.True(t, ok)
+		assert.Equal(t, []byte{0, 0, 0, 10}, med)
+		assert.Equal(t, []byte{24, 198, 51, 0}, x.NLRI())
+	}
+
+	_, err = p.applyUpdate([]byte{0, 4, 24})
+	assert.Error(t, err)
+}
+
+func TestPolicyPrependASPathCount(t *testing.T) {
+	for _, count := range []int{-1, 256} {
+		a := PrependASPath(64512, count)
+		assert.Error(t, a.validate(), "count %d", count)
+		_, err := Policy{a}.Apply(nil)
+		assert.Error(t, err, "count %d", count)
+		o := defaultPeerOptions()
+		WithOutboundPolicy(Policy{SetMED(10), a}).apply(&o)
+		assert.Error(t, o.validate(), "count %d", count)
+	}
+
+	got, err := Policy{PrependASPath(64512, 255)}.Apply(nil)
+	if assert.NoError(t, err) {
+		path, err := DecodeASPath(got[4:]) // extended length
+		if assert.NoError(t, err) && assert.Len(t, path, 1) {
+			assert.Len(t, path[0].ASNs, 255)
+		}
+	}
+	o := defaultPeerOptions()
+	WithOutboundPolicy(Policy{PrependASPath(64512, 0)}).apply(&o)
+	assert.NoError(t, o.validate())
+}
+
+func TestPolicySetNextHopIPv6(t *testing.T) {
+	attrs := AppendPathAttr(nil, 0x40, PATH_ATTR_NEXT_HOP,
+		[]byte{192, 0, 2, 1})
+	_, err := Policy{SetNextHop(netip.MustParseAddr("2001:db8::1"))}.
+		Apply(attrs)
+	assert.Error(t, err)
+}
+
+func TestPolicyPreservesFlags(t *testing.T) {
+	// Partial is set by a speaker that passed on an unrecognized optional
+	// transitive attribute, and must be retained
+	attrs := AppendPathAttr(nil, 0xe0, PATH_ATTR_COMMUNITY,
+		CommunitiesPathAttr{1}.Encode())
+	attrs = AppendPathAttr(attrs, 0x50, PATH_ATTR_AS_PATH, ASPath{{
+		Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{64513},
+	}}.Encode())
+	got, err := Policy{
+		AddCommunities(2),
+		PrependASPath(64512, 1),
+	}.Apply(attrs)
+	if !assert.NoError(t, err) {
+		return
+	}
+	want := AppendPathAttr(nil, 0x40, PATH_ATTR_AS_PATH, ASPath{{
+		Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{64512, 64513},
+	}}.Encode())
+	want = AppendPathAttr(want, 0xe0, PATH_ATTR_COMMUNITY,
+		CommunitiesPathAttr{1, 2}.Encode())
+	assert.Equal(t, want, got)
+}
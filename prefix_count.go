@@ -0,0 +1,48 @@
+package corebgp
+
+import "encoding/binary"
+
+// WithPrefixCounting returns a PeerOption that counts the IPv4 and IPv6
+// unicast prefixes received from the peer in its PeerStats. The prefixes of
+// the NLRI field and the MP_REACH_NLRI path attribute of each UPDATE message
+// are counted as accepted, and those of the Withdrawn Routes field and the
+// MP_UNREACH_NLRI path attribute as withdrawn, taking negotiated ADD-PATH
+// into account. Prefixes are counted before the peer's UpdateMessageHandler
+// is invoked, so rejections are not observed. A plugin applying policy should
+// report its own counts via Server.AddPrefixCounts instead.
+func WithPrefixCounting() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.prefixCounting = true
+	})
+}
+
+// countUpdatePrefixes adds the prefixes of the UPDATE message b to the prefix
+// counters of s, see WithPrefixCounting. Malformed messages are left to be
+// handled by the UpdateMessageHandler.
+func countUpdatePrefixes(s *peerStats, b []byte, rx addPathRx) {
+	var x UpdateIndex
+	if x.Reset(b) != nil {
+		return
+	}
+	s.addPrefixCounts(MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST},
+		PrefixCounts{
+			Accepted:  uint64(countPrefixes(x.NLRI(), rx.ipv4)),
+			Withdrawn: uint64(countPrefixes(x.Withdrawn(), rx.ipv4)),
+		})
+	if _, data, ok := x.Attr(PATH_ATTR_MP_REACH_NLRI); ok && len(data) >= 3 {
+		s.addPrefixCounts(mpFamily(data), PrefixCounts{
+			Accepted: uint64(countMPPrefixes(data, true, rx)),
+		})
+	}
+	if _, data, ok := x.Attr(PATH_ATTR_MP_UNREACH_NLRI); ok && len(data) >= 3 {
+		s.addPrefixCounts(mpFamily(data), PrefixCounts{
+			Withdrawn: uint64(countMPPrefixes(data, false, rx)),
+		})
+	}
+}
+
+// mpFamily returns the AFI/SAFI of b, the data of an MP_REACH_NLRI or
+// MP_UNREACH_NLRI path attribute of at least 3 octets.
+func mpFamily(b []byte) MPExtensions {
+	return MPExtensions{AFI: binary.BigEndian.Uint16(b), SAFI: b[2]}
+}
@@ -0,0 +1,73 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountUpdatePrefixes(t *testing.T) {
+	// 2 withdrawn, 3 NLRI
+	b := []byte{0, 4, 8, 10, 8, 11, 0, 3, 0x40, 0x01, 0x00, 8, 10, 8, 11,
+		16, 172, 16}
+	// MP_REACH_NLRI for IPv6 unicast with 2 NLRI
+	mpReach := []byte{0, 0, 0, 32, 0x80, 14, 29, 0, 2, 1, 16,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+		32, 0x20, 0x01, 0x0d, 0xb8, 16, 0x20, 0x01}
+	// MP_UNREACH_NLRI for IPv6 unicast with 1 NLRI with a Path Identifier
+	mpUnreach := []byte{0, 0, 0, 13, 0x80, 15, 10, 0, 2, 1, 0, 0, 0, 1, 16,
+		0x20, 0x01}
+	// MP_UNREACH_NLRI for IPv4 flowspec is not counted
+	flowspec := []byte{0, 0, 0, 8, 0x80, 15, 5, 0, 1, 133, 1, 0}
+
+	var s peerStats
+	countUpdatePrefixes(&s, b, addPathRx{})
+	countUpdatePrefixes(&s, mpReach, addPathRx{})
+	countUpdatePrefixes(&s, mpUnreach, addPathRx{ipv6: true})
+	countUpdatePrefixes(&s, flowspec, addPathRx{})
+	countUpdatePrefixes(&s, NewEndOfRIB(AFI_IPV4, SAFI_UNICAST), addPathRx{})
+	countUpdatePrefixes(&s, []byte{0, 9}, addPathRx{})
+	assert.Equal(t, []FamilyPrefixCounts{
+		{
+			Family:       MPExtensions{AFI: AFI_IPV4, SAFI: SAFI_UNICAST},
+			PrefixCounts: PrefixCounts{Accepted: 3, Withdrawn: 2},
+		},
+		{
+			Family:       MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST},
+			PrefixCounts: PrefixCounts{Accepted: 2, Withdrawn: 1},
+		},
+	}, s.snapshot().Prefixes)
+}
+
+func TestServer_AddPrefixCounts(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ip := netip.MustParseAddr("192.0.2.2")
+	v6 := MPExtensions{AFI: AFI_IPV6, SAFI: SAFI_UNICAST}
+	err = s.AddPrefixCounts(ip, v6, PrefixCounts{Accepted: 1})
+	assert.ErrorIs(t, err, ErrPeerNotExist)
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: ip,
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, nil, WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	stats, err := s.GetPeerStats(ip)
+	assert.NoError(t, err)
+	assert.Nil(t, stats.Prefixes)
+	assert.NoError(t, s.AddPrefixCounts(ip, v6, PrefixCounts{Accepted: 3,
+		Rejected: 1}))
+	assert.NoError(t, s.AddPrefixCounts(ip, v6, PrefixCounts{Accepted: 1,
+		Withdrawn: 2}))
+	stats, err = s.GetPeerStats(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, []FamilyPrefixCounts{{
+		Family:       v6,
+		PrefixCounts: PrefixCounts{Accepted: 4, Rejected: 1, Withdrawn: 2},
+	}}, stats.Prefixes)
+}
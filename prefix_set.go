@@ -0,0 +1,323 @@
+package corebgp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// PrefixRange matches a prefix covered by Prefix whose length is in the range
+// [Min, Max], e.g. 192.0.2.0/24 with Min 24 and Max 32 matches 192.0.2.0/24
+// and all of its more specifics.
+type PrefixRange struct {
+	Prefix   netip.Prefix
+	Min, Max int
+}
+
+// ExactPrefixRange returns the PrefixRange matching p only.
+func ExactPrefixRange(p netip.Prefix) PrefixRange {
+	return PrefixRange{Prefix: p, Min: p.Bits(), Max: p.Bits()}
+}
+
+func (r PrefixRange) validate() error {
+	if !r.Prefix.IsValid() {
+		return errors.New("invalid prefix")
+	}
+	if r.Min < r.Prefix.Bits() || r.Max < r.Min ||
+		r.Max > r.Prefix.Addr().BitLen() {
+		return fmt.Errorf("invalid length range %d-%d for %s", r.Min, r.Max,
+			r.Prefix)
+	}
+	return nil
+}
+
+// String returns r in RPSL range operator notation, e.g. 192.0.2.0/24^24-32.
+//
+// https://www.rfc-editor.org/rfc/rfc2622#section-2
+func (r PrefixRange) String() string {
+	if r.Min == r.Prefix.Bits() && r.Max == r.Min {
+		return r.Prefix.String()
+	}
+	return fmt.Sprintf("%s^%d-%d", r.Prefix, r.Min, r.Max)
+}
+
+// PrefixSet is a set of PrefixRanges, e.g. a prefix list generated from IRR
+// data, which is matched against a prefix in time proportional to the
+// prefix's length regardless of the number of PrefixRanges. The zero value
+// is an empty set. PrefixSet is not safe for concurrent modification, but may
+// be matched against concurrently once populated.
+type PrefixSet struct {
+	v4, v6 *prefixSetNode
+	len    int
+}
+
+// prefixSetNode is a node of a binary trie keyed by prefix bits. lengths is a
+// bitmap of the prefix lengths matched by the PrefixRanges whose prefix is the
+// path to the node.
+type prefixSetNode struct {
+	children [2]*prefixSetNode
+	lengths  [3]uint64
+}
+
+func (n *prefixSetNode) hasLength(l int) bool {
+	return n.lengths[l/64]&(1<<(l%64)) != 0
+}
+
+func bitAt(b []byte, i int) int {
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// Add adds r to s. An error is returned if r is invalid, i.e. its prefix is
+// invalid or its length range does not fall within [r.Prefix.Bits(), the bit
+// length of its address].
+func (s *PrefixSet) Add(r PrefixRange) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	r.Prefix = r.Prefix.Masked()
+	root := &s.v4
+	if r.Prefix.Addr().Is6() {
+		root = &s.v6
+	}
+	if *root == nil {
+		*root = &prefixSetNode{}
+	}
+	n := *root
+	addr := r.Prefix.Addr().AsSlice()
+	for i := 0; i < r.Prefix.Bits(); i++ {
+		bit := bitAt(addr, i)
+		if n.children[bit] == nil {
+			n.children[bit] = &prefixSetNode{}
+		}
+		n = n.children[bit]
+	}
+	for l := r.Min; l <= r.Max; l++ {
+		if !n.hasLength(l) {
+			n.lengths[l/64] |= 1 << (l % 64)
+			s.len++
+		}
+	}
+	return nil
+}
+
+// Contains returns true if p is matched by a PrefixRange of s.
+func (s *PrefixSet) Contains(p netip.Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	p = p.Masked()
+	n := s.v4
+	if p.Addr().Is6() {
+		n = s.v6
+	}
+	addr := p.Addr().AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.hasLength(p.Bits()) {
+			return true
+		}
+		if i == p.Bits() {
+			break
+		}
+		n = n.children[bitAt(addr, i)]
+	}
+	return false
+}
+
+// Len returns the number of distinct prefix lengths matched across the
+// prefixes of s, e.g. a PrefixRange of 192.0.2.0/24^24-32 counts as 9.
+func (s *PrefixSet) Len() int {
+	return s.len
+}
+
+// ParsePrefixRange parses a PrefixRange in one of the following forms, as
+// produced by IRR tooling such as bgpq4:
+//
+//   - a prefix, e.g. 192.0.2.0/24, matching only itself
+//   - a prefix followed by an RPSL range operator, e.g. 192.0.2.0/24^+,
+//     192.0.2.0/24^-, 192.0.2.0/24^26, or 192.0.2.0/24^24-28
+//   - a prefix followed by ge and/or le clauses, e.g. 192.0.2.0/24 le 28, or
+//     192.0.2.0/24 ge 26 le 28
+//   - a prefix followed by a Junos-style upto or prefix-length-range clause,
+//     e.g. 192.0.2.0/24 upto /28, or 192.0.2.0/24 prefix-length-range /26-/28
+//
+// https://www.rfc-editor.org/rfc/rfc2622#section-2
+// ^- is the exclusive more specifics operator; it stands for the more
+// specifics of the address prefix excluding the address prefix itself. [...]
+// ^+ is the inclusive more specifics operator; it stands for the more
+// specifics of the address prefix including the address prefix itself. [...]
+// ^n where n is an integer, stands for all the length n specifics of the
+// address prefix. [...] ^n-m where n and m are integers, stands for all the
+// length n to length m specifics of the address prefix.
+func ParsePrefixRange(s string) (PrefixRange, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return PrefixRange{}, errors.New("empty prefix range")
+	}
+	prefixStr, op, hasOp := strings.Cut(fields[0], "^")
+	p, err := netip.ParsePrefix(prefixStr)
+	if err != nil {
+		return PrefixRange{}, err
+	}
+	r := ExactPrefixRange(p)
+	maxLen := p.Addr().BitLen()
+	if hasOp {
+		if len(fields) > 1 {
+			return PrefixRange{}, fmt.Errorf("unexpected %q", fields[1])
+		}
+		switch op {
+		case "-":
+			r.Min, r.Max = p.Bits()+1, maxLen
+		case "+":
+			r.Max = maxLen
+		default:
+			minStr, maxStr, isRange := strings.Cut(op, "-")
+			if !isRange {
+				maxStr = minStr
+			}
+			r.Min, err = strconv.Atoi(minStr)
+			if err != nil {
+				return PrefixRange{}, fmt.Errorf("invalid range operator: %s",
+					op)
+			}
+			r.Max, err = strconv.Atoi(maxStr)
+			if err != nil {
+				return PrefixRange{}, fmt.Errorf("invalid range operator: %s",
+					op)
+			}
+		}
+		return r, r.validate()
+	}
+	parseLen := func(s string) (int, error) {
+		return strconv.Atoi(strings.TrimPrefix(s, "/"))
+	}
+	hasGE, hasLE := false, false
+	for rest := fields[1:]; len(rest) > 0; rest = rest[2:] {
+		if len(rest) < 2 {
+			return PrefixRange{}, fmt.Errorf("missing value for %q", rest[0])
+		}
+		switch rest[0] {
+		case "ge":
+			r.Min, err = parseLen(rest[1])
+			hasGE = true
+		case "le", "upto":
+			r.Max, err = parseLen(rest[1])
+			hasLE = true
+		case "prefix-length-range":
+			minStr, maxStr, ok := strings.Cut(rest[1], "-")
+			if !ok {
+				return PrefixRange{}, fmt.Errorf(
+					"invalid prefix-length-range: %s", rest[1])
+			}
+			r.Min, err = parseLen(minStr)
+			if err == nil {
+				r.Max, err = parseLen(maxStr)
+			}
+			hasGE, hasLE = true, true
+		default:
+			return PrefixRange{}, fmt.Errorf("unexpected %q", rest[0])
+		}
+		if err != nil {
+			return PrefixRange{}, fmt.Errorf("invalid length for %q: %w",
+				rest[0], err)
+		}
+	}
+	if hasGE && !hasLE {
+		// ge without le matches up to the maximum length
+		r.Max = maxLen
+	}
+	return r, r.validate()
+}
+
+// ParsePrefixSet parses a PrefixSet from r, which contains one PrefixRange
+// per line in a form accepted by ParsePrefixRange. Blank lines and comments,
+// i.e. lines beginning with # or !, are ignored. The output of bgpq4 in its
+// default Cisco, Junos (-J), and user defined (-F) formats is accepted, i.e.
+// the "no ip prefix-list" statements and leading "ip prefix-list NAME [seq N]
+// permit" words of Cisco-style prefix lists, and the block statements,
+// trailing semicolons, and route-filter match types (exact, orlonger, longer,
+// upto, and prefix-length-range) of Junos-style prefix lists and route
+// filters. Prefix list entries with a deny action result in an error. Errors
+// include the offending line number.
+func ParsePrefixSet(r io.Reader) (*PrefixSet, error) {
+	s := &PrefixSet{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' || text[0] == '!' {
+			continue
+		}
+		text, err := trimPrefixListEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(text) == 0 {
+			// e.g. an empty prefix list declaration
+			continue
+		}
+		pr, err := ParsePrefixRange(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err = s.Add(pr); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// trimPrefixListEntry removes the words surrounding the prefix of a
+// Cisco-style prefix list entry, or a Junos-style prefix list or route filter
+// entry, returning text unmodified if it is neither. An empty string is
+// returned for Junos-style statements opening or closing a block, or
+// replacing the configuration, and for Cisco-style statements removing a
+// prefix list.
+func trimPrefixListEntry(text string) (string, error) {
+	if strings.HasSuffix(text, "{") || strings.HasSuffix(text, ":") ||
+		text == "}" || strings.HasPrefix(text, "no ") {
+		return "", nil
+	}
+	text = strings.TrimSuffix(text, ";")
+	text = strings.TrimPrefix(text, "route-filter ")
+	if exact, ok := strings.CutSuffix(text, " exact"); ok {
+		return exact, nil
+	}
+	if orLonger, ok := strings.CutSuffix(text, " orlonger"); ok {
+		return orLonger + "^+", nil
+	}
+	if longer, ok := strings.CutSuffix(text, " longer"); ok {
+		return longer + "^-", nil
+	}
+	fields := strings.Fields(text)
+	if len(fields) < 2 || (fields[0] != "ip" && fields[0] != "ipv6") ||
+		fields[1] != "prefix-list" {
+		return text, nil
+	}
+	fields = fields[2:]
+	if len(fields) > 0 {
+		// the prefix list name
+		fields = fields[1:]
+	}
+	if len(fields) >= 2 && fields[0] == "seq" {
+		fields = fields[2:]
+	}
+	if len(fields) == 0 || fields[0] == "description" {
+		return "", nil
+	}
+	switch fields[0] {
+	case "permit":
+	case "deny":
+		return "", errors.New("deny entries are not supported")
+	default:
+		return "", fmt.Errorf("unexpected %q", fields[0])
+	}
+	return strings.Join(fields[1:], " "), nil
+}
@@ -0,0 +1,124 @@
+package corebgp
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrefixRange(t *testing.T) {
+	p := netip.MustParsePrefix("192.0.2.0/24")
+	for _, tt := range []struct {
+		in       string
+		min, max int
+		wantErr  bool
+	}{
+		{in: "192.0.2.0/24", min: 24, max: 24},
+		{in: "192.0.2.0/24^+", min: 24, max: 32},
+		{in: "192.0.2.0/24^-", min: 25, max: 32},
+		{in: "192.0.2.0/24^26", min: 26, max: 26},
+		{in: "192.0.2.0/24^25-28", min: 25, max: 28},
+		{in: "192.0.2.0/24 le 28", min: 24, max: 28},
+		{in: "192.0.2.0/24 ge 26", min: 26, max: 32},
+		{in: "192.0.2.0/24 ge 24", min: 24, max: 32},
+		{in: "192.0.2.0/24 ge 26 le 28", min: 26, max: 28},
+		{in: "192.0.2.0/24 upto /28", min: 24, max: 28},
+		{in: "192.0.2.0/24 prefix-length-range /26-/28", min: 26, max: 28},
+		{in: "192.0.2.0/24^23", wantErr: true},
+		{in: "192.0.2.0/24^28-26", wantErr: true},
+		{in: "192.0.2.0/24 le 33", wantErr: true},
+		{in: "192.0.2.0/24 le", wantErr: true},
+		{in: "192.0.2.0/24 foo 26", wantErr: true},
+		{in: "192.0.2.0", wantErr: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			r, err := ParsePrefixRange(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, PrefixRange{Prefix: p, Min: tt.min, Max: tt.max},
+					r)
+			}
+		})
+	}
+	r, _ := ParsePrefixRange("192.0.2.0/24^25-28")
+	assert.Equal(t, "192.0.2.0/24^25-28", r.String())
+}
+
+func TestPrefixSet(t *testing.T) {
+	var s PrefixSet
+	assert.False(t, s.Contains(netip.MustParsePrefix("192.0.2.0/24")))
+	assert.NoError(t, s.Add(PrefixRange{
+		Prefix: netip.MustParsePrefix("192.0.2.0/24"), Min: 25, Max: 26}))
+	assert.NoError(t, s.Add(ExactPrefixRange(
+		netip.MustParsePrefix("198.51.100.0/24"))))
+	assert.NoError(t, s.Add(PrefixRange{
+		Prefix: netip.MustParsePrefix("2001:db8::/32"), Min: 32, Max: 48}))
+	assert.NoError(t, s.Add(ExactPrefixRange(netip.MustParsePrefix("0.0.0.0/0"))))
+	assert.Error(t, s.Add(PrefixRange{
+		Prefix: netip.MustParsePrefix("192.0.2.0/24"), Min: 23, Max: 24}))
+	assert.Equal(t, 1+2+1+17, s.Len())
+
+	for _, tt := range []struct {
+		prefix string
+		want   bool
+	}{
+		{"192.0.2.0/24", false},
+		{"192.0.2.0/25", true},
+		{"192.0.2.192/26", true},
+		{"192.0.2.0/27", false},
+		{"198.51.100.0/24", true},
+		{"198.51.100.0/25", false},
+		{"198.51.0.0/16", false},
+		{"0.0.0.0/0", true},
+		{"2001:db8:1::/48", true},
+		{"2001:db8::/49", false},
+		{"2001:db9::/32", false},
+		{"::/0", false},
+	} {
+		assert.Equal(t, tt.want, s.Contains(netip.MustParsePrefix(tt.prefix)),
+			tt.prefix)
+	}
+}
+
+func TestParsePrefixSet(t *testing.T) {
+	for name, in := range map[string]string{
+		"cisco": `no ip prefix-list NN
+ip prefix-list NN permit 192.0.2.0/24
+ip prefix-list NN seq 10 permit 198.51.100.0/24 le 25
+`,
+		"junos": `policy-options {
+replace:
+ prefix-list NN {
+    192.0.2.0/24;
+ }
+ route-filter-list NN {
+    route-filter 198.51.100.0/24 upto /25;
+ }
+}
+`,
+		"plain": `# comment
+192.0.2.0/24
+198.51.100.0/24^24-25
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParsePrefixSet(strings.NewReader(in))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, s.Contains(netip.MustParsePrefix("192.0.2.0/24")))
+			assert.False(t, s.Contains(netip.MustParsePrefix("192.0.2.0/25")))
+			assert.True(t, s.Contains(netip.MustParsePrefix("198.51.100.128/25")))
+			assert.Equal(t, 3, s.Len())
+		})
+	}
+
+	_, err := ParsePrefixSet(strings.NewReader(
+		"192.0.2.0/24\nip prefix-list NN deny 198.51.100.0/24\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,226 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// PrefixMatch determines which prefixes of an UPDATE message match a watched
+// prefix, see PrefixWatcher.
+type PrefixMatch uint8
+
+const (
+	// PrefixMatchExact matches the watched prefix only.
+	PrefixMatchExact PrefixMatch = iota
+	// PrefixMatchOrLonger matches the watched prefix and all prefixes it
+	// covers, i.e. its more-specifics.
+	PrefixMatchOrLonger
+)
+
+// WatchedUpdate describes the prefixes of an UPDATE message matching a watched
+// prefix, see PrefixWatcher.Watch.
+type WatchedUpdate struct {
+	Peer PeerConfig
+	// Watched is the watched prefix.
+	Watched netip.Prefix
+	// Reachable contains the matching prefixes of the NLRI field and of the
+	// MP_REACH_NLRI path attribute.
+	Reachable []netip.Prefix
+	// Withdrawn contains the matching prefixes of the Withdrawn Routes field
+	// and of the MP_UNREACH_NLRI path attribute.
+	Withdrawn []netip.Prefix
+	// Update is the UPDATE message, which is only valid for the duration of
+	// the callback and must not be modified.
+	Update []byte
+}
+
+type prefixWatch struct {
+	prefix netip.Prefix
+	match  PrefixMatch
+	fn     func(u WatchedUpdate)
+}
+
+func (w *prefixWatch) matches(p netip.Prefix) bool {
+	if w.match == PrefixMatchExact {
+		return p == w.prefix
+	}
+	return w.prefix.Bits() <= p.Bits() && w.prefix.Contains(p.Addr())
+}
+
+// PrefixWatcher invokes callbacks for the UPDATE messages received from peers
+// that announce or withdraw prefixes of interest, allowing an application,
+// e.g. a route collector watching a handful of prefixes, to avoid decoding
+// and storing the routes of every UPDATE message. It matches the IPv4 and
+// IPv6 unicast prefixes of the NLRI and Withdrawn Routes fields and of the
+// MP_REACH_NLRI and MP_UNREACH_NLRI path attributes, taking negotiated
+// ADD-PATH into account, without fully decoding the UPDATE message.
+//
+// A PrefixWatcher is attached to peers via WithPrefixWatcher, and may be
+// shared between them. Its callbacks are invoked by the peer's FSM goroutine,
+// or the worker of its UpdateDispatcher, before the peer's
+// UpdateMessageHandler.
+type PrefixWatcher struct {
+	mu sync.Mutex
+	// watches is replaced rather than modified so that it may be read
+	// without holding mu
+	watches atomic.Pointer[[]*prefixWatch]
+}
+
+// NewPrefixWatcher returns a PrefixWatcher with no watched prefixes.
+func NewPrefixWatcher() *PrefixWatcher {
+	return &PrefixWatcher{}
+}
+
+// Watch registers fn to be called for each UPDATE message containing prefixes
+// matching p according to match. fn is called once per UPDATE message with
+// all of its matching prefixes. It returns a function that removes the
+// registration.
+func (w *PrefixWatcher) Watch(p netip.Prefix, match PrefixMatch,
+	fn func(u WatchedUpdate)) (stop func()) {
+	pw := &prefixWatch{prefix: p.Masked(), match: match, fn: fn}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var watches []*prefixWatch
+	if cur := w.watches.Load(); cur != nil {
+		watches = append(watches, *cur...)
+	}
+	watches = append(watches, pw)
+	w.watches.Store(&watches)
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		cur := w.watches.Load()
+		watches := make([]*prefixWatch, 0, len(*cur))
+		for _, c := range *cur {
+			if c != pw {
+				watches = append(watches, c)
+			}
+		}
+		w.watches.Store(&watches)
+	}
+}
+
+// handleUpdate matches the prefixes of the UPDATE message b against the
+// watched prefixes and invokes the callbacks of those matched.
+func (w *PrefixWatcher) handleUpdate(peer PeerConfig, b []byte,
+	rx addPathRx) {
+	cur := w.watches.Load()
+	if cur == nil || len(*cur) == 0 {
+		return
+	}
+	watches := *cur
+	var x UpdateIndex
+	if x.Reset(b) != nil {
+		return
+	}
+	var matched map[*prefixWatch]*WatchedUpdate
+	add := func(p netip.Prefix, reach bool) {
+		for _, pw := range watches {
+			if !pw.matches(p) {
+				continue
+			}
+			if matched == nil {
+				matched = make(map[*prefixWatch]*WatchedUpdate)
+			}
+			u, ok := matched[pw]
+			if !ok {
+				u = &WatchedUpdate{Peer: peer, Watched: pw.prefix, Update: b}
+				matched[pw] = u
+			}
+			if reach {
+				u.Reachable = append(u.Reachable, p)
+			} else {
+				u.Withdrawn = append(u.Withdrawn, p)
+			}
+		}
+	}
+	walkPrefixes(x.Withdrawn(), false, rx.ipv4, func(p netip.Prefix) {
+		add(p, false)
+	})
+	if _, data, ok := x.Attr(PATH_ATTR_MP_UNREACH_NLRI); ok {
+		walkMPPrefixes(data, false, rx, func(p netip.Prefix) {
+			add(p, false)
+		})
+	}
+	walkPrefixes(x.NLRI(), false, rx.ipv4, func(p netip.Prefix) {
+		add(p, true)
+	})
+	if _, data, ok := x.Attr(PATH_ATTR_MP_REACH_NLRI); ok {
+		walkMPPrefixes(data, true, rx, func(p netip.Prefix) {
+			add(p, true)
+		})
+	}
+	// callbacks are invoked in the order the watches were registered
+	for _, pw := range watches {
+		if u, ok := matched[pw]; ok {
+			pw.fn(*u)
+		}
+	}
+}
+
+// walkPrefixes calls fn for each prefix encoded in b as in the NLRI field of
+// an UPDATE message, see countPrefixes. Walking stops at the first malformed
+// prefix, which is left to be handled by the UpdateMessageHandler.
+func walkPrefixes(b []byte, ipv6, addPath bool, fn func(p netip.Prefix)) {
+	maxBits := 32
+	if ipv6 {
+		maxBits = 128
+	}
+	for len(b) > 0 {
+		if addPath {
+			if len(b) < 5 {
+				return
+			}
+			b = b[4:]
+		}
+		bits := int(b[0])
+		l := 1 + (bits+7)/8
+		if bits > maxBits || len(b) < l {
+			return
+		}
+		var a [16]byte
+		copy(a[:], b[1:l])
+		var addr netip.Addr
+		if ipv6 {
+			addr = netip.AddrFrom16(a)
+		} else {
+			addr = netip.AddrFrom4([4]byte(a[:4]))
+		}
+		p, err := addr.Prefix(bits)
+		if err == nil {
+			fn(p)
+		}
+		b = b[l:]
+	}
+}
+
+// walkMPPrefixes calls fn for each IPv4 or IPv6 unicast prefix in b, the data
+// of an MP_REACH_NLRI or MP_UNREACH_NLRI path attribute.
+func walkMPPrefixes(b []byte, reach bool, rx addPathRx,
+	fn func(p netip.Prefix)) {
+	if len(b) < 3 {
+		return
+	}
+	afi, safi := binary.BigEndian.Uint16(b), b[2]
+	if (afi != AFI_IPV4 && afi != AFI_IPV6) || safi != SAFI_UNICAST {
+		return
+	}
+	b = b[3:]
+	if reach {
+		// next hop length, next hop, and reserved octet
+		if len(b) < 1 || len(b) < 2+int(b[0]) {
+			return
+		}
+		b = b[2+int(b[0]):]
+	}
+	walkPrefixes(b, afi == AFI_IPV6, rx.forAFI(afi), fn)
+}
+
+// WithPrefixWatcher returns a PeerOption that sets a PrefixWatcher for a peer.
+func WithPrefixWatcher(w *PrefixWatcher) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.prefixWatcher = w
+	})
+}
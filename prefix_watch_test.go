@@ -0,0 +1,96 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixWatcher(t *testing.T) {
+	// withdrawn 198.51.100.0/24, NLRI 192.0.2.0/25 and 203.0.113.0/24,
+	// MP_REACH_NLRI 2001:db8:1::/48
+	withdrawn := []byte{24, 198, 51, 100}
+	nlri := []byte{25, 192, 0, 2, 0, 24, 203, 0, 113}
+	mpReach := []byte{0, 2, 1, 16}
+	mpReach = append(mpReach, netip.MustParseAddr("2001:db8::1").AsSlice()...)
+	mpReach = append(mpReach, 0, 48, 0x20, 0x01, 0x0d, 0xb8, 0, 1)
+	attrs := append([]byte{0x80, PATH_ATTR_MP_REACH_NLRI, byte(len(mpReach))},
+		mpReach...)
+	var b []byte
+	b = binary.BigEndian.AppendUint16(b, uint16(len(withdrawn)))
+	b = append(b, withdrawn...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	b = append(b, attrs...)
+	b = append(b, nlri...)
+
+	peer := PeerConfig{RemoteAS: 64512}
+	w := NewPrefixWatcher()
+	var got []WatchedUpdate
+	fn := func(u WatchedUpdate) {
+		got = append(got, u)
+	}
+	stop := w.Watch(netip.MustParsePrefix("192.0.2.0/24"), PrefixMatchExact,
+		fn)
+	w.Watch(netip.MustParsePrefix("192.0.2.0/23"), PrefixMatchOrLonger, fn)
+	w.Watch(netip.MustParsePrefix("198.51.100.0/24"), PrefixMatchExact, fn)
+	w.Watch(netip.MustParsePrefix("2001:db8::/32"), PrefixMatchOrLonger, fn)
+	w.handleUpdate(peer, b, addPathRx{})
+
+	want := []WatchedUpdate{
+		{
+			Peer:      peer,
+			Watched:   netip.MustParsePrefix("192.0.2.0/23"),
+			Reachable: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/25")},
+			Update:    b,
+		},
+		{
+			Peer:      peer,
+			Watched:   netip.MustParsePrefix("198.51.100.0/24"),
+			Withdrawn: []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+			Update:    b,
+		},
+		{
+			Peer:      peer,
+			Watched:   netip.MustParsePrefix("2001:db8::/32"),
+			Reachable: []netip.Prefix{netip.MustParsePrefix("2001:db8:1::/48")},
+			Update:    b,
+		},
+	}
+	assert.Equal(t, want, got)
+
+	// ADD-PATH NLRI
+	got = nil
+	b = []byte{0, 0, 0, 0, 0, 0, 0, 1, 24, 192, 0, 2}
+	w.handleUpdate(peer, b, addPathRx{ipv4: true})
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"),
+			got[0].Watched)
+		assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+			got[0].Reachable)
+	}
+
+	got = nil
+	stop()
+	w.handleUpdate(peer, b, addPathRx{ipv4: true})
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, netip.MustParsePrefix("192.0.2.0/23"),
+			got[0].Watched)
+	}
+}
+
+func TestWalkPrefixes_malformed(t *testing.T) {
+	var got []netip.Prefix
+	fn := func(p netip.Prefix) {
+		got = append(got, p)
+	}
+	// second prefix is truncated
+	walkPrefixes([]byte{8, 10, 16, 172}, false, false, fn)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, got)
+
+	got = nil
+	// invalid prefix length
+	walkPrefixes([]byte{33, 10, 0, 0, 0, 0}, false, false, fn)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,160 @@
+package corebgp
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// UpdateRateLimitAction controls how UPDATE messages received in excess of an
+// UpdateRateLimit are handled.
+type UpdateRateLimitAction uint8
+
+const (
+	// UpdateRateLimitDelay delays reading from the peer's connection until
+	// the UPDATE message is within the limit, applying backpressure to the
+	// peer via TCP. This is the default.
+	UpdateRateLimitDelay UpdateRateLimitAction = iota
+	// UpdateRateLimitLog handles UPDATE messages in excess of the limit
+	// without delay, counting them in PeerStats.UpdatesRateLimited and logging
+	// when the limit is first exceeded.
+	UpdateRateLimitLog
+)
+
+// UpdateRateLimit is a token bucket limit on the rate of UPDATE messages
+// received from a peer. A rate of zero disables the respective limit.
+type UpdateRateLimit struct {
+	// MessagesPerSecond is the sustained rate of UPDATE messages.
+	MessagesPerSecond float64
+	// MessageBurst is the number of UPDATE messages that may exceed
+	// MessagesPerSecond in a burst. It defaults to one second's worth.
+	MessageBurst int
+
+	// BytesPerSecond is the sustained rate of UPDATE message bytes, excluding
+	// the message header.
+	BytesPerSecond float64
+	// ByteBurst is the number of bytes that may exceed BytesPerSecond in a
+	// burst. It defaults to one second's worth.
+	ByteBurst int
+
+	// Action controls how UPDATE messages in excess of the limit are
+	// handled.
+	Action UpdateRateLimitAction
+}
+
+func (u UpdateRateLimit) validate() error {
+	if u.MessagesPerSecond < 0 || u.BytesPerSecond < 0 {
+		return errors.New("update rate limit must be >= 0")
+	}
+	if u.MessageBurst < 0 || u.ByteBurst < 0 {
+		return errors.New("update rate limit burst must be >= 0")
+	}
+	if u.Action > UpdateRateLimitLog {
+		return errors.New("invalid update rate limit action")
+	}
+	return nil
+}
+
+// WithUpdateRateLimit returns a PeerOption that limits the rate of UPDATE
+// messages processed from a peer, protecting the host from a peer sending
+// UPDATE messages faster than they can be handled. UPDATE messages are limited
+// as they are read from the connection and before they are handled. Other
+// messages are not limited, but are delayed behind UPDATE messages when
+// UpdateRateLimitDelay is in use. The limit should therefore allow KEEPALIVE
+// messages to be read well within the hold time.
+func WithUpdateRateLimit(l UpdateRateLimit) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateRateLimit = &l
+	})
+}
+
+// tokenBucket is a token bucket with tokens replenished at rate per second up
+// to burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	b := float64(burst)
+	if b == 0 {
+		b = math.Max(rate, 1)
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   now,
+	}
+}
+
+// reserve takes n tokens from the bucket, returning the duration until the
+// bucket is no longer in debt.
+func (t *tokenBucket) reserve(now time.Time, n float64) time.Duration {
+	if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens = math.Min(t.burst, t.tokens+elapsed.Seconds()*t.rate)
+		t.last = now
+	}
+	t.tokens -= n
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.rate * float64(time.Second))
+}
+
+// cancel returns n tokens to the bucket.
+func (t *tokenBucket) cancel(n float64) {
+	t.tokens += n
+}
+
+// updateRateLimiter applies an UpdateRateLimit to UPDATE messages.
+type updateRateLimiter struct {
+	action   UpdateRateLimitAction
+	messages *tokenBucket
+	bytes    *tokenBucket
+	exceeded bool
+}
+
+func newUpdateRateLimiter(l *UpdateRateLimit, now time.Time) *updateRateLimiter {
+	if l == nil {
+		return nil
+	}
+	return &updateRateLimiter{
+		action:   l.Action,
+		messages: newTokenBucket(l.MessagesPerSecond, l.MessageBurst, now),
+		bytes:    newTokenBucket(l.BytesPerSecond, l.ByteBurst, now),
+	}
+}
+
+// wait returns the duration to wait before handling an UPDATE message of
+// length n. For UpdateRateLimitLog it returns zero and true if the message
+// exceeds the limit.
+func (u *updateRateLimiter) wait(now time.Time, n int) (time.Duration, bool) {
+	var wait time.Duration
+	if u.messages != nil {
+		wait = u.messages.reserve(now, 1)
+	}
+	if u.bytes != nil {
+		wait = max(wait, u.bytes.reserve(now, float64(n)))
+	}
+	if u.action == UpdateRateLimitDelay {
+		return wait, false
+	}
+	if wait == 0 {
+		u.exceeded = false
+		return 0, false
+	}
+	// the message is not delayed, return the tokens
+	if u.messages != nil {
+		u.messages.cancel(1)
+	}
+	if u.bytes != nil {
+		u.bytes.cancel(float64(n))
+	}
+	return 0, true
+}
@@ -0,0 +1,71 @@
+package corebgp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRateLimiter_Delay(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newUpdateRateLimiter(&UpdateRateLimit{
+		MessagesPerSecond: 10,
+		MessageBurst:      2,
+	}, now)
+
+	for i := 0; i < 2; i++ {
+		wait, exceeded := l.wait(now, 100)
+		assert.Equal(t, time.Duration(0), wait)
+		assert.False(t, exceeded)
+	}
+	wait, _ := l.wait(now, 100)
+	assert.Equal(t, time.Millisecond*100, wait)
+	wait, _ = l.wait(now, 100)
+	assert.Equal(t, time.Millisecond*200, wait)
+
+	// the bucket refills over time, up to the burst
+	now = now.Add(time.Hour)
+	wait, _ = l.wait(now, 100)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestUpdateRateLimiter_Bytes(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newUpdateRateLimiter(&UpdateRateLimit{
+		MessagesPerSecond: 1000,
+		BytesPerSecond:    1000,
+	}, now)
+	wait, _ := l.wait(now, 1000)
+	assert.Equal(t, time.Duration(0), wait)
+	wait, _ = l.wait(now, 500)
+	assert.Equal(t, time.Millisecond*500, wait)
+}
+
+func TestUpdateRateLimiter_Log(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newUpdateRateLimiter(&UpdateRateLimit{
+		MessagesPerSecond: 1,
+		MessageBurst:      1,
+		Action:            UpdateRateLimitLog,
+	}, now)
+	wait, exceeded := l.wait(now, 0)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.False(t, exceeded)
+	for i := 0; i < 3; i++ {
+		wait, exceeded = l.wait(now, 0)
+		assert.Equal(t, time.Duration(0), wait)
+		assert.True(t, exceeded)
+	}
+	// exceeding messages do not consume tokens
+	wait, exceeded = l.wait(now.Add(time.Second), 0)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.False(t, exceeded)
+}
+
+func TestUpdateRateLimit_validate(t *testing.T) {
+	assert.NoError(t, UpdateRateLimit{}.validate())
+	assert.Error(t, UpdateRateLimit{MessagesPerSecond: -1}.validate())
+	assert.Error(t, UpdateRateLimit{ByteBurst: -1}.validate())
+	assert.Error(t, UpdateRateLimit{Action: 2}.validate())
+}
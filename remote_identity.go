@@ -0,0 +1,101 @@
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// WithRemoteRouterID returns a PeerOption that sets the BGP Identifier
+// expected from the peer. An OPEN message carrying a different BGP Identifier
+// is rejected with the Bad BGP Identifier subcode.
+//
+// By default an inbound connection is matched to a peer by its remote
+// address. Setting sources additionally matches inbound connections whose
+// remote address belongs to no peer but is contained in one of sources by the
+// BGP Identifier and AS number of their OPEN message, i.e. id and
+// PeerConfig.RemoteAS. This allows sessions with peers behind NAT, or anycast
+// peers, whose transport address differs from PeerConfig.RemoteAddress. Such
+// peers should typically also be passive, see WithPassive, as they cannot be
+// dialed at PeerConfig.RemoteAddress.
+//
+// An OpenPolicy set via Server.SetOpenPolicy takes precedence over matching
+// by BGP Identifier.
+func WithRemoteRouterID(id netip.Addr, sources ...netip.Prefix) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.remoteRouterID = id
+		o.remoteSources = sources
+	})
+}
+
+func validateRemoteIdentity(id netip.Addr, sources []netip.Prefix) error {
+	if id.IsValid() && (!id.Is4() || id.IsUnspecified()) {
+		return errors.New("invalid remote router ID")
+	}
+	if !id.IsValid() && len(sources) > 0 {
+		return errors.New("remote sources require a remote router ID")
+	}
+	for _, s := range sources {
+		if !s.IsValid() {
+			return errors.New("invalid remote source prefix")
+		}
+	}
+	return nil
+}
+
+// validateRemoteRouterID returns a notificationError if the BGP Identifier
+// received from the peer does not match the one set via WithRemoteRouterID.
+func (p *peer) validateRemoteRouterID(bgpID uint32) error {
+	want := p.options().remoteRouterID
+	if !want.IsValid() || addrFromRouterID(bgpID) == want {
+		return nil
+	}
+	n := newNotification(NOTIF_CODE_OPEN_MESSAGE_ERR, NOTIF_SUBCODE_BAD_BGP_ID,
+		nil)
+	return newNotificationError(n, true)
+}
+
+// identityMatch returns true if an inbound connection from remote may be
+// matched to a peer by the BGP Identifier and AS number of its OPEN message.
+func (s *Server) identityMatch(remote netip.Addr) bool {
+	if _, _, exists := s.peers.forInbound(remote); exists {
+		return false
+	}
+	addr := remote.WithZone("").Unmap()
+	match := false
+	s.peers.each(func(p *peer) bool {
+		for _, prefix := range p.options().remoteSources {
+			if prefix.Contains(addr) {
+				match = true
+				return false
+			}
+		}
+		return true
+	})
+	return match
+}
+
+// identityOpenPolicy is the OpenPolicy applied to inbound connections for
+// which identityMatch returns true.
+func (s *Server) identityOpenPolicy(remote, _ netip.AddrPort,
+	open OpenInfo) (netip.Addr, *Notification) {
+	addr := remote.Addr().WithZone("").Unmap()
+	var matched netip.Addr
+	s.peers.each(func(p *peer) bool {
+		o := p.options()
+		if o.remoteRouterID != open.RouterID || p.config.RemoteAS != open.ASN {
+			return true
+		}
+		for _, prefix := range o.remoteSources {
+			if prefix.Contains(addr) {
+				matched = p.config.RemoteAddress
+				return false
+			}
+		}
+		return true
+	})
+	if matched.IsValid() {
+		return matched, nil
+	}
+	return netip.Addr{}, newNotification(NOTIF_CODE_CEASE,
+		NOTIF_SUBCODE_CONN_REJECTED, nil)
+}
@@ -0,0 +1,104 @@
+package corebgp_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRemoteRouterID(t *testing.T) {
+	t.Parallel()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	routerIDB := netip.MustParseAddr("10.0.0.2")
+
+	for _, tc := range []struct {
+		name        string
+		from, id    netip.Addr
+		want        *corebgp.Notification
+		established bool
+	}{
+		{"matched by identity", netip.MustParseAddr("198.51.100.7"),
+			routerIDB, nil, true},
+		{"unexpected router ID", addrB, netip.MustParseAddr("10.0.0.3"),
+			&corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_OPEN_MESSAGE_ERR,
+				Subcode: corebgp.NOTIF_SUBCODE_BAD_BGP_ID,
+			}, false},
+		{"unknown identity", netip.MustParseAddr("198.51.100.8"),
+			netip.MustParseAddr("10.0.0.3"), &corebgp.Notification{
+				Code:    corebgp.NOTIF_CODE_CEASE,
+				Subcode: corebgp.NOTIF_SUBCODE_CONN_REJECTED,
+			}, false},
+		{"outside sources", netip.MustParseAddr("203.0.113.1"), routerIDB,
+			nil, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			n := corebgptest.NewNetwork()
+			server, err := corebgp.NewServer(addrA)
+			if !assert.NoError(t, err) {
+				return
+			}
+			err = server.AddPeer(corebgp.PeerConfig{
+				RemoteAddress: addrB,
+				LocalAS:       64512,
+				RemoteAS:      64512,
+			}, &livenessTestPlugin{}, corebgp.WithPassive(),
+				corebgp.WithRemoteRouterID(routerIDB,
+					netip.MustParsePrefix("198.51.100.0/24")))
+			if !assert.NoError(t, err) {
+				return
+			}
+			l, err := n.Listen(addrA)
+			if !assert.NoError(t, err) {
+				return
+			}
+			go server.Serve([]net.Listener{l})
+			t.Cleanup(server.Close)
+
+			conn, err := n.Dialer(tc.from)(context.Background(), "tcp",
+				"192.0.2.1:179")
+			if !assert.NoError(t, err) {
+				return
+			}
+			c := corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+				AS:       64512,
+				RouterID: tc.id,
+				HoldTime: 90,
+			})
+			defer c.Close()
+
+			if tc.established {
+				_, err = c.Establish()
+				assert.NoError(t, err)
+				assert.Eventually(t, func() bool {
+					_, err := server.GetSessionInfo(addrB)
+					return err == nil
+				}, time.Second*5, time.Millisecond*10)
+				return
+			}
+			if tc.want == nil {
+				// the connection is closed without a Notification, possibly
+				// before the OPEN message is written
+				c.SendOpen() // nolint: errcheck
+				_, err = c.ReadNotification(time.Second * 5)
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, c.SendOpen())
+			got, err := c.ReadNotification(time.Second * 5)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.want.Code, got.Code)
+				assert.Equal(t, tc.want.Subcode, got.Subcode)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package corebgp
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// ReplayBuffer retains the most recent UPDATE messages received from peers so
+// that a consumer attaching after a session is established, e.g. a BMP
+// exporter or a debug tap, can catch up on the routes received without
+// triggering a route refresh. The UPDATE messages of each peer are retained
+// separately and are discarded when a new session is established with the
+// peer.
+//
+// A ReplayBuffer is attached to peers via WithReplayBuffer, and may be shared
+// between them. UPDATE messages are retained by the peer's FSM goroutine, or
+// the worker of its UpdateDispatcher, before the peer's UpdateMessageHandler
+// is invoked.
+type ReplayBuffer struct {
+	size          int
+	sinceEndOfRIB bool
+
+	mu    sync.Mutex
+	peers map[netip.Addr]*replayRing
+}
+
+// replayRing holds the retained UPDATE messages of a peer and its attached
+// consumers.
+type replayRing struct {
+	updates   [][]byte
+	start     int
+	len       int
+	consumers map[*replayConsumer]struct{}
+}
+
+type replayConsumer struct {
+	fn func(update []byte)
+}
+
+func (r *replayRing) push(update []byte) {
+	i := (r.start + r.len) % len(r.updates)
+	r.updates[i] = update
+	if r.len < len(r.updates) {
+		r.len++
+	} else {
+		r.start = (r.start + 1) % len(r.updates)
+	}
+}
+
+func (r *replayRing) clear() {
+	for i := range r.updates {
+		r.updates[i] = nil
+	}
+	r.start, r.len = 0, 0
+}
+
+func (r *replayRing) walk(fn func(update []byte)) {
+	for i := 0; i < r.len; i++ {
+		fn(r.updates[(r.start+i)%len(r.updates)])
+	}
+}
+
+// NewReplayBuffer returns a ReplayBuffer retaining up to size UPDATE messages
+// per peer, a size less than one is treated as one. If sinceEndOfRIB is true
+// the retained messages are also discarded upon receipt of an End-of-RIB
+// marker, so that only the messages received since the most recent marker
+// are retained.
+func NewReplayBuffer(size int, sinceEndOfRIB bool) *ReplayBuffer {
+	return &ReplayBuffer{
+		size:          max(size, 1),
+		sinceEndOfRIB: sinceEndOfRIB,
+		peers:         make(map[netip.Addr]*replayRing),
+	}
+}
+
+// WithReplayBuffer returns a PeerOption that retains the UPDATE messages
+// received from a peer in b.
+func WithReplayBuffer(b *ReplayBuffer) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.replayBuffer = b
+	})
+}
+
+func (b *ReplayBuffer) ringLocked(peer netip.Addr) *replayRing {
+	r, ok := b.peers[peer]
+	if !ok {
+		r = &replayRing{
+			updates:   make([][]byte, b.size),
+			consumers: make(map[*replayConsumer]struct{}),
+		}
+		b.peers[peer] = r
+	}
+	return r
+}
+
+// Attach calls fn with each retained UPDATE message of the peer with remote
+// address peer, oldest first, and then with each UPDATE message subsequently
+// received from the peer, until detach is called. No messages are missed or
+// repeated between the two. fn is called while the ReplayBuffer is locked,
+// from the goroutine calling Attach for the retained messages and from the
+// peer's FSM goroutine thereafter, so it must not block or call methods of the
+// ReplayBuffer. The messages passed to fn must not be modified.
+func (b *ReplayBuffer) Attach(peer netip.Addr,
+	fn func(update []byte)) (detach func()) {
+	c := &replayConsumer{fn: fn}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.ringLocked(peer)
+	r.walk(fn)
+	r.consumers[c] = struct{}{}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(r.consumers, c)
+	}
+}
+
+// Len returns the number of UPDATE messages retained for the peer with remote
+// address peer.
+func (b *ReplayBuffer) Len(peer netip.Addr) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.peers[peer]
+	if !ok {
+		return 0
+	}
+	return r.len
+}
+
+// sessionUp discards the retained UPDATE messages of peer as a new session
+// has been established.
+func (b *ReplayBuffer) sessionUp(peer netip.Addr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := b.peers[peer]; ok {
+		r.clear()
+	}
+}
+
+// handleUpdate retains a copy of the UPDATE message update received from peer
+// and passes it to attached consumers.
+func (b *ReplayBuffer) handleUpdate(peer netip.Addr, update []byte) {
+	update = append([]byte(nil), update...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.ringLocked(peer)
+	if _, isEOR := IsEndOfRIB(update); isEOR && b.sinceEndOfRIB {
+		r.clear()
+	} else {
+		r.push(update)
+	}
+	for c := range r.consumers {
+		c.fn(update)
+	}
+}
@@ -0,0 +1,55 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.2")
+	u := func(i byte) []byte {
+		return []byte{0, 0, 0, 0, 24, 198, 51, i}
+	}
+
+	rb := NewReplayBuffer(2, false)
+	assert.Equal(t, 0, rb.Len(a))
+	m := u(1)
+	rb.handleUpdate(a, m)
+	m[7] = 0xff // retained messages are copied
+	rb.handleUpdate(a, u(2))
+	rb.handleUpdate(a, u(3))
+	rb.handleUpdate(b, u(4))
+	assert.Equal(t, 2, rb.Len(a))
+	assert.Equal(t, 1, rb.Len(b))
+
+	var got [][]byte
+	detach := rb.Attach(a, func(update []byte) {
+		got = append(got, update)
+	})
+	assert.Equal(t, [][]byte{u(2), u(3)}, got)
+	rb.handleUpdate(a, u(5))
+	rb.handleUpdate(b, u(6))
+	assert.Equal(t, [][]byte{u(2), u(3), u(5)}, got)
+	detach()
+	rb.handleUpdate(a, u(7))
+	assert.Len(t, got, 3)
+
+	// a new session discards the retained messages
+	rb.sessionUp(a)
+	assert.Equal(t, 0, rb.Len(a))
+	assert.Equal(t, 2, rb.Len(b))
+
+	rb = NewReplayBuffer(10, true)
+	rb.handleUpdate(a, u(1))
+	rb.handleUpdate(a, NewEndOfRIB(AFI_IPV4, SAFI_UNICAST))
+	assert.Equal(t, 0, rb.Len(a))
+	rb.handleUpdate(a, u(2))
+	got = nil
+	rb.Attach(a, func(update []byte) {
+		got = append(got, update)
+	})
+	assert.Equal(t, [][]byte{u(2)}, got)
+}
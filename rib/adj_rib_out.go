@@ -0,0 +1,239 @@
+package rib
+
+import (
+	"bytes"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Change is a change to the routes advertised to a peer, see AdjRIBOut.
+type Change struct {
+	Prefix netip.Prefix
+	// Attrs holds the path attributes of the advertised route, as they appear
+	// in the Path Attributes field of an UPDATE message. It is nil if the
+	// route is withdrawn.
+	Attrs []byte
+}
+
+// Withdrawn returns true if c withdraws the route for c.Prefix.
+func (c Change) Withdrawn() bool {
+	return c.Attrs == nil
+}
+
+// AdjRIBOutStats contains counters of an AdjRIBOut.
+type AdjRIBOutStats struct {
+	// Sent is the number of Changes sent.
+	Sent uint64
+	// Duplicates is the number of advertisements suppressed as they were
+	// byte-identical to the route last sent for their prefix.
+	Duplicates uint64
+	// Collapsed is the number of Changes superseded by a later Change for the
+	// same prefix within the same MinRouteAdvertisementInterval, including
+	// advertisements of routes that were withdrawn before they were sent.
+	Collapsed uint64
+}
+
+// AdjRIBOut holds the routes advertised to a single peer, and suppresses
+// changes that would not change them. An advertisement that is byte-identical
+// to the route last sent for its prefix, or a withdrawal of a prefix with no
+// route sent, is dropped.
+//
+// Changes are held for the MinRouteAdvertisementInterval before being sent,
+// during which only the latest Change for each prefix is retained. An
+// advertisement followed by a withdrawal of the same prefix within the
+// interval is therefore sent as a single withdrawal, or not at all if the
+// prefix was not previously advertised.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.2.1.1
+// MinRouteAdvertisementIntervalTimer determines the minimum amount of time
+// that must elapse between an advertisement and/or withdrawal of routes to a
+// particular destination by a BGP speaker to a peer. This rate limiting
+// procedure applies on a per-destination basis, although the value of
+// MinRouteAdvertisementIntervalTimer is set on a per BGP peer basis.
+//
+// The interval is applied to the peer as a whole rather than per prefix, i.e.
+// it begins with the first Change held and ends with all held Changes being
+// sent. AdjRIBOut is safe for concurrent use.
+type AdjRIBOut struct {
+	mrai time.Duration
+	send func(changes []Change)
+
+	// sendMu serializes calls to send so that Changes are delivered in order
+	sendMu sync.Mutex
+
+	mu      sync.Mutex
+	sent    map[netip.Prefix][]byte
+	pending map[netip.Prefix][]byte
+	stats   AdjRIBOutStats
+	timer   *time.Timer
+	// gen is incremented each time the timer is stopped so that a timer that
+	// fires concurrently has no effect.
+	gen    uint64
+	closed bool
+}
+
+// NewAdjRIBOut returns an AdjRIBOut with no routes sent. send is called with
+// the Changes to send to the peer, sorted by prefix, once mrai has elapsed
+// since the first Change was held, or immediately if mrai is 0. It may be
+// called from a timer goroutine, is not called concurrently, and must not
+// call methods of the AdjRIBOut other than Sent and Stats.
+func NewAdjRIBOut(mrai time.Duration, send func(changes []Change)) *AdjRIBOut {
+	return &AdjRIBOut{
+		mrai:    mrai,
+		send:    send,
+		sent:    make(map[netip.Prefix][]byte),
+		pending: make(map[netip.Prefix][]byte),
+	}
+}
+
+// Advertise advertises the route for p with the path attributes in attrs,
+// which are copied and so may be reused by the caller.
+func (a *AdjRIBOut) Advertise(p netip.Prefix, attrs []byte) {
+	a.change(p.Masked(), append(make([]byte, 0, len(attrs)), attrs...))
+}
+
+// Withdraw withdraws the route for p.
+func (a *AdjRIBOut) Withdraw(p netip.Prefix) {
+	a.change(p.Masked(), nil)
+}
+
+func (a *AdjRIBOut) change(p netip.Prefix, attrs []byte) {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	if _, ok := a.pending[p]; ok {
+		a.stats.Collapsed++
+	}
+	a.pending[p] = attrs
+	if a.mrai > 0 {
+		if a.timer == nil {
+			gen := a.gen
+			a.timer = time.AfterFunc(a.mrai, func() {
+				a.flush(gen, true)
+			})
+		}
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+	a.Flush()
+}
+
+// Flush sends any held Changes without waiting for the
+// MinRouteAdvertisementInterval to elapse, e.g. ahead of an End-of-RIB
+// marker.
+func (a *AdjRIBOut) Flush() {
+	a.flush(0, false)
+}
+
+func (a *AdjRIBOut) flush(gen uint64, fromTimer bool) {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	a.mu.Lock()
+	if fromTimer && gen != a.gen {
+		a.mu.Unlock()
+		return
+	}
+	a.stopTimerLocked()
+	changes := a.takePendingLocked()
+	a.mu.Unlock()
+	if len(changes) > 0 {
+		a.send(changes)
+	}
+}
+
+// takePendingLocked returns the held Changes that alter the routes sent,
+// recording them as sent. It must be called with a.mu held.
+func (a *AdjRIBOut) takePendingLocked() []Change {
+	changes := make([]Change, 0, len(a.pending))
+	for p, attrs := range a.pending {
+		delete(a.pending, p)
+		sent, ok := a.sent[p]
+		if attrs == nil {
+			if !ok {
+				// never sent, or an advertisement withdrawn within the
+				// interval
+				continue
+			}
+			delete(a.sent, p)
+		} else {
+			if ok && bytes.Equal(sent, attrs) {
+				a.stats.Duplicates++
+				continue
+			}
+			a.sent[p] = attrs
+		}
+		changes = append(changes, Change{Prefix: p, Attrs: attrs})
+	}
+	a.stats.Sent += uint64(len(changes))
+	sort.Slice(changes, func(i, j int) bool {
+		return comparePrefix(changes[i].Prefix, changes[j].Prefix) < 0
+	})
+	return changes
+}
+
+// stopTimerLocked stops the timer, if any. It must be called with a.mu held.
+func (a *AdjRIBOut) stopTimerLocked() {
+	a.gen++
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+// Sent returns the path attributes of the route last sent for p, and true if
+// one was sent and not since withdrawn. The returned slice must not be
+// modified.
+func (a *AdjRIBOut) Sent(p netip.Prefix) ([]byte, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attrs, ok := a.sent[p.Masked()]
+	return attrs, ok
+}
+
+// Len returns the number of routes sent and not since withdrawn.
+func (a *AdjRIBOut) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.sent)
+}
+
+// Stats returns the counters of the AdjRIBOut.
+func (a *AdjRIBOut) Stats() AdjRIBOutStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// Reset discards all routes sent and Changes held, e.g. when the session with
+// the peer terminates. Routes advertised following Reset are sent regardless
+// of what was previously sent.
+func (a *AdjRIBOut) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopTimerLocked()
+	a.sent = make(map[netip.Prefix][]byte)
+	a.pending = make(map[netip.Prefix][]byte)
+}
+
+// Close discards any held Changes and stops the AdjRIBOut. Changes made
+// following Close are ignored.
+func (a *AdjRIBOut) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	a.stopTimerLocked()
+	a.pending = make(map[netip.Prefix][]byte)
+}
+
+// comparePrefix orders prefixes by address, then by prefix length.
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
@@ -0,0 +1,86 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjRIBOut(t *testing.T) {
+	var sent [][]Change
+	a := NewAdjRIBOut(0, func(changes []Change) {
+		sent = append(sent, changes)
+	})
+	defer a.Close()
+	p := netip.MustParsePrefix("192.0.2.0/24")
+	attrs := []byte{0x40, 1, 1, 0}
+
+	a.Advertise(p, attrs)
+	attrs[3] = 2 // the caller may reuse attrs
+	a.Advertise(p, []byte{0x40, 1, 1, 0})
+	a.Withdraw(p)
+	a.Withdraw(p)
+	assert.Equal(t, [][]Change{
+		{{Prefix: p, Attrs: []byte{0x40, 1, 1, 0}}},
+		{{Prefix: p}},
+	}, sent)
+	assert.True(t, sent[1][0].Withdrawn())
+	assert.Equal(t, AdjRIBOutStats{Sent: 2, Duplicates: 1}, a.Stats())
+
+	// routes are sent again following a Reset
+	a.Advertise(p, attrs)
+	a.Reset()
+	a.Advertise(p, attrs)
+	assert.Len(t, sent, 4)
+	got, ok := a.Sent(p)
+	assert.True(t, ok)
+	assert.Equal(t, attrs, got)
+}
+
+func TestAdjRIBOutMRAI(t *testing.T) {
+	sentCh := make(chan []Change, 4)
+	a := NewAdjRIBOut(time.Millisecond*50, func(changes []Change) {
+		sentCh <- changes
+	})
+	defer a.Close()
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	p3 := netip.MustParsePrefix("2001:db8::/32")
+	attrs := []byte{0x40, 1, 1, 0}
+
+	a.Advertise(p3, attrs)
+	a.Advertise(p2, attrs)
+	a.Advertise(p1, []byte{0x40, 1, 1, 1})
+	a.Advertise(p1, attrs)
+	// added and withdrawn within the interval
+	a.Withdraw(p3)
+	select {
+	case changes := <-sentCh:
+		assert.Equal(t, []Change{
+			{Prefix: p1, Attrs: attrs},
+			{Prefix: p2, Attrs: attrs},
+		}, changes)
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for changes")
+	}
+	assert.Equal(t, AdjRIBOutStats{Sent: 2, Collapsed: 2}, a.Stats())
+	assert.Equal(t, 2, a.Len())
+
+	// withdrawn and re-advertised unchanged within the interval
+	a.Withdraw(p2)
+	a.Advertise(p2, attrs)
+	a.Advertise(p3, attrs)
+	a.Flush()
+	assert.Equal(t, []Change{{Prefix: p3, Attrs: attrs}}, <-sentCh)
+	assert.Equal(t, AdjRIBOutStats{Sent: 3, Duplicates: 1, Collapsed: 3},
+		a.Stats())
+
+	a.Withdraw(p3)
+	a.Close()
+	time.Sleep(time.Millisecond * 100)
+	assert.Empty(t, sentCh)
+	_, ok := a.Sent(p3)
+	assert.True(t, ok)
+}
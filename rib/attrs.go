@@ -0,0 +1,105 @@
+package rib
+
+import (
+	"bytes"
+	"hash/maphash"
+	"sync"
+)
+
+// Attrs is an interned set of path attributes, see AttrTable. Routes with
+// identical path attributes share a single Attrs.
+type Attrs struct {
+	b    []byte
+	hash uint64
+	refs int
+}
+
+// Bytes returns the path attributes, as they appear in the Path Attributes
+// field of an UPDATE message. The returned slice is shared and must not be
+// modified.
+func (a *Attrs) Bytes() []byte {
+	return a.b
+}
+
+// AttrTable interns sets of path attributes behind reference-counted Attrs
+// handles. The majority of paths in a full table share a small number of
+// attribute sets, so storing a handle per route instead of a copy of its
+// attributes significantly reduces the memory required by a RIB, especially
+// one holding full tables from multiple peers.
+//
+// Attribute sets are compared byte-wise, so sets that differ only in the
+// order of their attributes are interned separately. AttrTable is safe for
+// concurrent use.
+type AttrTable struct {
+	seed maphash.Seed
+
+	mu      sync.Mutex
+	buckets map[uint64][]*Attrs
+	len     int
+}
+
+// NewAttrTable returns an empty AttrTable.
+func NewAttrTable() *AttrTable {
+	return &AttrTable{
+		seed:    maphash.MakeSeed(),
+		buckets: make(map[uint64][]*Attrs),
+	}
+}
+
+// Intern returns the Attrs for the path attributes in b, incrementing its
+// reference count. b is copied if it is not already interned, so it may be
+// reused by the caller, e.g. an UPDATE message buffer. Each call to Intern
+// must be paired with a call to Release once the Attrs is no longer
+// referenced.
+func (t *AttrTable) Intern(b []byte) *Attrs {
+	h := maphash.Bytes(t.seed, b)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, a := range t.buckets[h] {
+		if bytes.Equal(a.b, b) {
+			a.refs++
+			return a
+		}
+	}
+	a := &Attrs{
+		b:    append([]byte(nil), b...),
+		hash: h,
+		refs: 1,
+	}
+	t.buckets[h] = append(t.buckets[h], a)
+	t.len++
+	return a
+}
+
+// Release decrements the reference count of a, removing it from the table
+// once it is no longer referenced. a must have been returned by Intern on t.
+func (t *AttrTable) Release(a *Attrs) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a.refs--
+	if a.refs > 0 {
+		return
+	}
+	bucket := t.buckets[a.hash]
+	for i, v := range bucket {
+		if v == a {
+			bucket[i] = bucket[len(bucket)-1]
+			bucket[len(bucket)-1] = nil
+			bucket = bucket[:len(bucket)-1]
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(t.buckets, a.hash)
+	} else {
+		t.buckets[a.hash] = bucket
+	}
+	t.len--
+}
+
+// Len returns the number of distinct attribute sets in the table.
+func (t *AttrTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.len
+}
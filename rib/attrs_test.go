@@ -0,0 +1,56 @@
+package rib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttrTable(t *testing.T) {
+	table := NewAttrTable()
+	buf := []byte{0x40, 1, 1, 0}
+	a := table.Intern(buf)
+	// the caller's buffer may be reused
+	buf[3] = 2
+	b := table.Intern(buf)
+	c := table.Intern([]byte{0x40, 1, 1, 0})
+	assert.Same(t, a, c)
+	assert.NotSame(t, a, b)
+	assert.Equal(t, []byte{0x40, 1, 1, 0}, a.Bytes())
+	assert.Equal(t, []byte{0x40, 1, 1, 2}, b.Bytes())
+	assert.Equal(t, 2, table.Len())
+
+	table.Release(a)
+	assert.Equal(t, 2, table.Len())
+	table.Release(c)
+	assert.Equal(t, 1, table.Len())
+	// a released set is interned anew
+	d := table.Intern([]byte{0x40, 1, 1, 0})
+	assert.NotSame(t, a, d)
+	table.Release(b)
+	table.Release(d)
+	assert.Equal(t, 0, table.Len())
+	assert.Empty(t, table.buckets)
+}
+
+func TestAttrTable_collision(t *testing.T) {
+	table := NewAttrTable()
+	a := &Attrs{b: []byte{1}, hash: 1, refs: 1}
+	b := &Attrs{b: []byte{2}, hash: 1, refs: 1}
+	table.buckets[1] = []*Attrs{a, b}
+	table.len = 2
+	table.Release(a)
+	assert.Equal(t, []*Attrs{b}, table.buckets[1])
+	table.Release(b)
+	assert.Empty(t, table.buckets)
+}
+
+func BenchmarkAttrTable_Intern(b *testing.B) {
+	table := NewAttrTable()
+	attrs := []byte{0x40, 1, 1, 0, 0x40, 2, 6, 2, 1, 0, 0, 0xfc, 0}
+	table.Intern(attrs)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table.Release(table.Intern(attrs))
+	}
+}
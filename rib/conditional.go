@@ -0,0 +1,182 @@
+package rib
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+)
+
+// ConditionType is the type of a Condition.
+type ConditionType uint8
+
+const (
+	// ConditionExist holds while a matching route is present.
+	ConditionExist ConditionType = iota
+	// ConditionNonExist holds while no matching route is present.
+	ConditionNonExist
+)
+
+// Condition is a condition over the routes of a Table, see
+// ConditionalAdvertisement.
+type Condition[T any] struct {
+	Type   ConditionType
+	Prefix netip.Prefix
+	// OrLonger extends the condition to the routes of prefixes covered by
+	// Prefix, i.e. its more-specifics.
+	OrLonger bool
+	// Match, if non-nil, restricts the condition to routes for which it
+	// returns true, e.g. those received from a particular upstream.
+	Match func(v T) bool
+}
+
+func (c *Condition[T]) covers(p netip.Prefix) bool {
+	if !c.OrLonger {
+		return p == c.Prefix
+	}
+	return c.Prefix.Bits() <= p.Bits() && c.Prefix.Contains(p.Addr())
+}
+
+// ConditionalAdvertisement is a set of routes that is advertised while all of
+// its conditions hold, e.g. a default route advertised only while an
+// upstream's prefix is present. The application advertises and withdraws the
+// routes upon calls to OnChange.
+type ConditionalAdvertisement[T any] struct {
+	Name       string
+	Conditions []Condition[T]
+	// OnChange is called with true when all conditions start to hold, and
+	// with false when one of them stops holding.
+	OnChange func(advertise bool)
+}
+
+type conditionalAdvertisement[T any] struct {
+	ConditionalAdvertisement[T]
+	holds      []bool
+	advertised bool
+}
+
+// ConditionalAdvertiser evaluates the conditions of ConditionalAdvertisements
+// over the routes of a Table, e.g. a Loc-RIB. Conditions are evaluated
+// incrementally, only those whose prefix is affected by a change to the Table
+// are re-evaluated.
+type ConditionalAdvertiser[T any] struct {
+	sub *Subscription[T]
+
+	mu     sync.Mutex
+	routes Trie[T]
+	ads    []*conditionalAdvertisement[T]
+	synced bool
+}
+
+// NewConditionalAdvertiser returns a ConditionalAdvertiser evaluating ads over
+// the routes of t. The routes of every ConditionalAdvertisement are initially
+// withdrawn. Once the routes present in t have been evaluated, OnChange is
+// called with true for those whose conditions hold, and subsequently upon
+// each change. OnChange is called from the goroutine of a Subscription to t,
+// one call at a time.
+func NewConditionalAdvertiser[T any](t *Table[T],
+	ads ...ConditionalAdvertisement[T]) (*ConditionalAdvertiser[T], error) {
+	c := &ConditionalAdvertiser[T]{}
+	names := make(map[string]bool)
+	for _, ad := range ads {
+		if names[ad.Name] {
+			return nil, fmt.Errorf("duplicate conditional advertisement: %s",
+				ad.Name)
+		}
+		names[ad.Name] = true
+		if len(ad.Conditions) == 0 {
+			return nil, errors.New("conditional advertisement has no conditions")
+		}
+		if ad.OnChange == nil {
+			return nil, errors.New("nil OnChange")
+		}
+		conds := make([]Condition[T], len(ad.Conditions))
+		for i, cond := range ad.Conditions {
+			if !cond.Prefix.IsValid() {
+				return nil, errors.New("invalid condition prefix")
+			}
+			if cond.Type > ConditionNonExist {
+				return nil, errors.New("invalid condition type")
+			}
+			cond.Prefix = cond.Prefix.Masked()
+			conds[i] = cond
+		}
+		ad.Conditions = conds
+		c.ads = append(c.ads, &conditionalAdvertisement[T]{
+			ConditionalAdvertisement: ad,
+			holds:                    make([]bool, len(conds)),
+		})
+	}
+	c.sub = t.Subscribe(c.handleEvent)
+	return c, nil
+}
+
+// Close stops the evaluation of conditions. OnChange may be called by an
+// evaluation in progress, see Subscription.Close.
+func (c *ConditionalAdvertiser[T]) Close() {
+	c.sub.Close()
+}
+
+// Advertised returns true if the routes of the ConditionalAdvertisement named
+// name are advertised.
+func (c *ConditionalAdvertiser[T]) Advertised(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ad := range c.ads {
+		if ad.Name == name {
+			return ad.advertised
+		}
+	}
+	return false
+}
+
+// evaluateLocked returns true if cond holds for the routes of c. It must be
+// called with c.mu held.
+func (c *ConditionalAdvertiser[T]) evaluateLocked(cond *Condition[T]) bool {
+	found := false
+	match := func(_ netip.Prefix, v T) bool {
+		found = cond.Match == nil || cond.Match(v)
+		return !found
+	}
+	if cond.OrLonger {
+		c.routes.WalkCovered(cond.Prefix, match)
+	} else if v, ok := c.routes.Get(cond.Prefix); ok {
+		match(cond.Prefix, v)
+	}
+	if cond.Type == ConditionNonExist {
+		return !found
+	}
+	return found
+}
+
+func (c *ConditionalAdvertiser[T]) handleEvent(e Event[T]) {
+	c.mu.Lock()
+	var changed []func()
+	switch e.Type {
+	case EventAdd, EventUpdate:
+		c.routes.Insert(e.Prefix, e.Value)
+	case EventWithdraw:
+		c.routes.Delete(e.Prefix)
+	case EventEndOfSnapshot:
+		c.synced = true
+	}
+	for _, ad := range c.ads {
+		all := true
+		for i := range ad.Conditions {
+			cond := &ad.Conditions[i]
+			if e.Type == EventEndOfSnapshot || cond.covers(e.Prefix) {
+				ad.holds[i] = c.evaluateLocked(cond)
+			}
+			all = all && ad.holds[i]
+		}
+		if c.synced && all != ad.advertised {
+			ad.advertised = all
+			onChange := ad.OnChange
+			changed = append(changed, func() { onChange(all) })
+		}
+	}
+	c.mu.Unlock()
+	for _, fn := range changed {
+		fn()
+	}
+}
@@ -0,0 +1,99 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalAdvertiser(t *testing.T) {
+	upstream := netip.MustParsePrefix("198.51.100.0/24")
+	backup := netip.MustParsePrefix("203.0.113.0/24")
+	var table Table[string]
+	table.Set(upstream, "isp-a")
+
+	type change struct {
+		name      string
+		advertise bool
+	}
+	ch := make(chan change, 16)
+	onChange := func(name string) func(bool) {
+		return func(advertise bool) {
+			ch <- change{name, advertise}
+		}
+	}
+	c, err := NewConditionalAdvertiser(&table,
+		ConditionalAdvertisement[string]{
+			Name: "default",
+			Conditions: []Condition[string]{
+				{
+					Type:     ConditionExist,
+					Prefix:   netip.MustParsePrefix("198.51.100.0/22"),
+					OrLonger: true,
+					Match:    func(v string) bool { return v == "isp-a" },
+				},
+			},
+			OnChange: onChange("default"),
+		},
+		ConditionalAdvertisement[string]{
+			Name: "backup",
+			Conditions: []Condition[string]{
+				{Type: ConditionNonExist, Prefix: upstream},
+				{Type: ConditionExist, Prefix: backup},
+			},
+			OnChange: onChange("backup"),
+		},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	next := func() change {
+		select {
+		case c := <-ch:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for change")
+			return change{}
+		}
+	}
+	assert.Equal(t, change{"default", true}, next())
+	assert.True(t, c.Advertised("default"))
+	assert.False(t, c.Advertised("backup"))
+
+	table.Set(backup, "isp-b")
+	table.Set(upstream, "isp-b")
+	assert.Equal(t, change{"default", false}, next())
+	table.Delete(upstream)
+	assert.Equal(t, change{"backup", true}, next())
+	table.Set(upstream, "isp-a")
+	assert.Equal(t, change{"default", true}, next())
+	assert.Equal(t, change{"backup", false}, next())
+
+	select {
+	case c := <-ch:
+		t.Fatalf("unexpected change: %v", c)
+	default:
+	}
+}
+
+func TestNewConditionalAdvertiser_invalid(t *testing.T) {
+	var table Table[string]
+	cond := Condition[string]{Prefix: netip.MustParsePrefix("192.0.2.0/24")}
+	fn := func(bool) {}
+	for _, ads := range [][]ConditionalAdvertisement[string]{
+		{{Name: "a", OnChange: fn}},
+		{{Name: "a", Conditions: []Condition[string]{cond}}},
+		{{Name: "a", Conditions: []Condition[string]{{}}, OnChange: fn}},
+		{
+			{Name: "a", Conditions: []Condition[string]{cond}, OnChange: fn},
+			{Name: "a", Conditions: []Condition[string]{cond}, OnChange: fn},
+		},
+	} {
+		_, err := NewConditionalAdvertiser(&table, ads...)
+		assert.Error(t, err)
+	}
+}
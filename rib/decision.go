@@ -0,0 +1,437 @@
+package rib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Path is a candidate path for a prefix, as considered by a DecisionProcess.
+// Value carries the application's representation of the path.
+type Path[T any] struct {
+	// LocalPref is the degree of preference of the path, i.e. its LOCAL_PREF.
+	LocalPref uint32
+	// ASPathLength is the length of the AS_PATH, see corebgp.ASPath.Len.
+	ASPathLength int
+	Origin       corebgp.OriginPathAttr
+	MED          uint32
+	// MEDMissing is true if the path has no MULTI_EXIT_DISC attribute, in
+	// which case MED is ignored. A missing MED is treated as the lowest
+	// value unless WithMissingMEDAsWorst is used.
+	MEDMissing bool
+	// NeighborAS is the AS the path was received from, i.e. the first AS of
+	// the AS_PATH. MED is only compared between paths with the same
+	// NeighborAS.
+	NeighborAS uint32
+	// EBGP is true if the path was received via eBGP.
+	EBGP bool
+	// IGPCost is the interior cost to the path's next hop.
+	IGPCost uint32
+	// AIGP is the accumulated IGP metric of the path, nil if it has none, see
+	// CompareAIGP.
+	AIGP *uint64
+	// LinkBandwidth is the bandwidth in bytes per second of the path's Link
+	// Bandwidth Extended Community, zero if it has none, see
+	// corebgp.FindLinkBandwidth and MultipathWeights.
+	LinkBandwidth float32
+	// RouterID is the BGP Identifier of the peer the path was received from,
+	// or its ORIGINATOR_ID if present.
+	RouterID       netip.Addr
+	ClusterListLen int
+	PeerAddress    netip.Addr
+	Value          T
+}
+
+// Compare compares two paths during a step of a DecisionProcess. It returns a
+// negative number if a is preferred, a positive number if b is preferred,
+// and zero if neither is.
+type Compare[T any] func(a, b *Path[T]) int
+
+// Step identifies one of the steps of the BGP decision process, the points
+// at which custom steps may be inserted into a DecisionProcess.
+type Step string
+
+// The steps of the BGP decision process in their order.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.2.2
+// https://www.rfc-editor.org/rfc/rfc4456#section-9
+const (
+	StepLocalPref         Step = "local-pref"
+	StepASPathLength      Step = "as-path-length"
+	StepOrigin            Step = "origin"
+	StepMED               Step = "med"
+	StepEBGP              Step = "ebgp"
+	StepIGPCost           Step = "igp-cost"
+	StepRouterID          Step = "router-id"
+	StepClusterListLength Step = "cluster-list-length"
+	StepPeerAddress       Step = "peer-address"
+)
+
+type decisionStep[T any] struct {
+	step Step
+	// filter returns the paths preferred by the step, which are a non-empty
+	// subset of paths
+	filter func(paths []*Path[T]) []*Path[T]
+}
+
+// DecisionProcess selects the best path among the candidate paths for a
+// prefix, applying the steps of the BGP decision process in order until a
+// single path remains. Custom steps, e.g. preferring paths with a lower
+// accumulated IGP metric or with a color matching an SR Policy, may be
+// inserted at defined points via InsertBefore and InsertAfter.
+//
+// A DecisionProcess must not be modified concurrently with its use.
+type DecisionProcess[T any] struct {
+	opts  decisionOptions
+	steps []decisionStep[T]
+}
+
+type decisionOptions struct {
+	alwaysCompareMED  bool
+	deterministicMED  bool
+	missingMEDAsWorst bool
+}
+
+// DecisionOption configures a DecisionProcess.
+type DecisionOption func(o *decisionOptions)
+
+// WithAlwaysCompareMED returns a DecisionOption that compares the MED of paths
+// regardless of their neighboring AS.
+func WithAlwaysCompareMED() DecisionOption {
+	return func(o *decisionOptions) {
+		o.alwaysCompareMED = true
+	}
+}
+
+// WithDeterministicMED returns a DecisionOption that first selects the best
+// path among the paths from each neighboring AS, and then the best path among
+// those, as implementations offering deterministic MED do. As each step is
+// applied to all remaining paths at once, the selection of a DecisionProcess
+// never depends on the order of paths, and for the standard steps matches
+// deterministic MED without this option. It changes the selection when
+// custom steps whose preference is not transitive are inserted.
+func WithDeterministicMED() DecisionOption {
+	return func(o *decisionOptions) {
+		o.deterministicMED = true
+	}
+}
+
+// WithMissingMEDAsWorst returns a DecisionOption that treats a missing MED as
+// the highest, i.e. least preferred, value rather than the lowest.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.2.2
+// If a route does not have a MULTI_EXIT_DISC attribute, it is assigned the
+// lowest value.
+func WithMissingMEDAsWorst() DecisionOption {
+	return func(o *decisionOptions) {
+		o.missingMEDAsWorst = true
+	}
+}
+
+// NewDecisionProcess returns a DecisionProcess made up of the steps of the
+// BGP decision process.
+func NewDecisionProcess[T any](opts ...DecisionOption) *DecisionProcess[T] {
+	d := &DecisionProcess[T]{}
+	for _, o := range opts {
+		o(&d.opts)
+	}
+	for _, s := range []struct {
+		step Step
+		cmp  Compare[T]
+	}{
+		{StepLocalPref, func(a, b *Path[T]) int {
+			return compareUint(b.LocalPref, a.LocalPref)
+		}},
+		{StepASPathLength, func(a, b *Path[T]) int {
+			return a.ASPathLength - b.ASPathLength
+		}},
+		{StepOrigin, func(a, b *Path[T]) int {
+			return compareUint(a.Origin, b.Origin)
+		}},
+		{StepMED, nil},
+		{StepEBGP, func(a, b *Path[T]) int {
+			return compareBool(a.EBGP, b.EBGP)
+		}},
+		{StepIGPCost, func(a, b *Path[T]) int {
+			return compareUint(a.IGPCost, b.IGPCost)
+		}},
+		{StepRouterID, func(a, b *Path[T]) int {
+			return a.RouterID.Compare(b.RouterID)
+		}},
+		{StepClusterListLength, func(a, b *Path[T]) int {
+			return a.ClusterListLen - b.ClusterListLen
+		}},
+		{StepPeerAddress, func(a, b *Path[T]) int {
+			return a.PeerAddress.Compare(b.PeerAddress)
+		}},
+	} {
+		filter := newMEDFilter[T](d.opts)
+		if s.cmp != nil {
+			filter = newFilter(s.cmp)
+		}
+		d.steps = append(d.steps, decisionStep[T]{step: s.step, filter: filter})
+	}
+	return d
+}
+
+func compareUint[U ~uint8 | ~uint32](a, b U) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareBool prefers true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// newFilter returns a filter keeping the paths that are not less preferred
+// than any other path according to cmp.
+func newFilter[T any](cmp Compare[T]) func([]*Path[T]) []*Path[T] {
+	return func(paths []*Path[T]) []*Path[T] {
+		best := paths[0]
+		for _, p := range paths[1:] {
+			if cmp(p, best) < 0 {
+				best = p
+			}
+		}
+		kept := make([]*Path[T], 0, len(paths))
+		for _, p := range paths {
+			if cmp(p, best) <= 0 {
+				kept = append(kept, p)
+			}
+		}
+		return kept
+	}
+}
+
+// newMEDFilter returns a filter removing the paths with a MED higher than that
+// of another path from the same neighboring AS, or of any other path if
+// opts.alwaysCompareMED is set.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-9.1.2.2
+// c) Remove from consideration routes with less-preferred
+// MULTI_EXIT_DISC attributes.  MULTI_EXIT_DISC is only comparable
+// between routes learned from the same neighboring AS (the
+// neighboring AS is determined from the AS_PATH attribute).
+func newMEDFilter[T any](opts decisionOptions) func([]*Path[T]) []*Path[T] {
+	med := func(p *Path[T]) uint32 {
+		switch {
+		case !p.MEDMissing:
+			return p.MED
+		case opts.missingMEDAsWorst:
+			return math.MaxUint32
+		default:
+			return 0
+		}
+	}
+	group := func(p *Path[T]) uint32 {
+		if opts.alwaysCompareMED {
+			return 0
+		}
+		return p.NeighborAS
+	}
+	return func(paths []*Path[T]) []*Path[T] {
+		minMED := make(map[uint32]uint32)
+		for _, p := range paths {
+			m, ok := minMED[group(p)]
+			if !ok || med(p) < m {
+				minMED[group(p)] = med(p)
+			}
+		}
+		kept := make([]*Path[T], 0, len(paths))
+		for _, p := range paths {
+			if med(p) == minMED[group(p)] {
+				kept = append(kept, p)
+			}
+		}
+		return kept
+	}
+}
+
+// Steps returns the steps of d in order.
+func (d *DecisionProcess[T]) Steps() []Step {
+	steps := make([]Step, 0, len(d.steps))
+	for _, s := range d.steps {
+		steps = append(steps, s.step)
+	}
+	return steps
+}
+
+func (d *DecisionProcess[T]) insert(at Step, offset int, step Step,
+	cmp Compare[T]) error {
+	if cmp == nil {
+		return errors.New("nil Compare")
+	}
+	i := -1
+	for j, s := range d.steps {
+		if s.step == step {
+			return fmt.Errorf("step %s already exists", step)
+		}
+		if s.step == at {
+			i = j
+		}
+	}
+	if i == -1 {
+		return fmt.Errorf("step %s does not exist", at)
+	}
+	i += offset
+	d.steps = append(d.steps, decisionStep[T]{})
+	copy(d.steps[i+1:], d.steps[i:])
+	d.steps[i] = decisionStep[T]{step: step, filter: newFilter(cmp)}
+	return nil
+}
+
+// InsertBefore inserts a custom step named step, which prefers paths according
+// to cmp, immediately before the step at. at may itself be a custom step.
+func (d *DecisionProcess[T]) InsertBefore(at, step Step, cmp Compare[T]) error {
+	return d.insert(at, 0, step, cmp)
+}
+
+// InsertAfter inserts a custom step named step, which prefers paths according
+// to cmp, immediately after the step at. at may itself be a custom step.
+func (d *DecisionProcess[T]) InsertAfter(at, step Step, cmp Compare[T]) error {
+	return d.insert(at, 1, step, cmp)
+}
+
+// Best returns the best of paths along with the step that selected it. The
+// step is empty if paths contains a single path. Best returns nil if paths is
+// empty. If paths remain tied after all steps the first of them is returned
+// along with the last step.
+//
+// With WithDeterministicMED the returned step is the one that selected the
+// best path among the best paths of each neighboring AS, or, if all paths are
+// from the same neighboring AS, the one that selected it among those.
+func (d *DecisionProcess[T]) Best(paths []*Path[T]) (*Path[T], Step) {
+	if !d.opts.deterministicMED || d.opts.alwaysCompareMED {
+		return d.best(paths)
+	}
+	var (
+		order  []uint32
+		groups = make(map[uint32][]*Path[T])
+	)
+	for _, p := range paths {
+		if _, ok := groups[p.NeighborAS]; !ok {
+			order = append(order, p.NeighborAS)
+		}
+		groups[p.NeighborAS] = append(groups[p.NeighborAS], p)
+	}
+	if len(order) < 2 {
+		return d.best(paths)
+	}
+	winners := make([]*Path[T], 0, len(order))
+	for _, as := range order {
+		best, _ := d.best(groups[as])
+		winners = append(winners, best)
+	}
+	return d.best(winners)
+}
+
+func (d *DecisionProcess[T]) best(paths []*Path[T]) (*Path[T], Step) {
+	switch len(paths) {
+	case 0:
+		return nil, ""
+	case 1:
+		return paths[0], ""
+	}
+	var last Step
+	for _, s := range d.steps {
+		paths = s.filter(paths)
+		last = s.step
+		if len(paths) == 1 {
+			break
+		}
+	}
+	return paths[0], last
+}
+
+// CompareAIGP returns a Compare preferring the path with the lowest AIGP
+// metric plus IGPCost. Paths without an AIGP metric are less preferred than
+// paths with one. It is typically inserted before StepASPathLength.
+//
+// https://www.rfc-editor.org/rfc/rfc7311#section-4
+func CompareAIGP[T any]() Compare[T] {
+	return func(a, b *Path[T]) int {
+		switch {
+		case a.AIGP == nil && b.AIGP == nil:
+			return 0
+		case a.AIGP == nil:
+			return 1
+		case b.AIGP == nil:
+			return -1
+		}
+		am, bm := *a.AIGP+uint64(a.IGPCost), *b.AIGP+uint64(b.IGPCost)
+		switch {
+		case am < bm:
+			return -1
+		case am > bm:
+			return 1
+		default:
+			return 0
+		}
+	}
+}
+
+// Multipath returns the paths equally preferred to the best of paths by the
+// steps preceding StepRouterID, including any custom steps inserted before
+// it, i.e. the paths that may be installed as a multipath route. The best
+// path, as returned by Best, is first.
+func (d *DecisionProcess[T]) Multipath(paths []*Path[T]) []*Path[T] {
+	best, _ := d.Best(paths)
+	if best == nil {
+		return nil
+	}
+	for _, s := range d.steps {
+		if s.step == StepRouterID || len(paths) == 1 {
+			break
+		}
+		paths = s.filter(paths)
+	}
+	multipath := make([]*Path[T], 0, len(paths))
+	multipath = append(multipath, best)
+	for _, p := range paths {
+		if p != best {
+			multipath = append(multipath, p)
+		}
+	}
+	return multipath
+}
+
+// MultipathWeights returns the weight of each of paths, e.g. as returned by
+// Multipath, proportional to its LinkBandwidth and scaled to the range
+// [1, maxWeight]. If any of paths has no LinkBandwidth all weights are 1, i.e.
+// traffic is shared equally.
+func MultipathWeights[T any](paths []*Path[T], maxWeight int) []int {
+	weights := make([]int, len(paths))
+	var highest float32
+	for _, p := range paths {
+		if p.LinkBandwidth <= 0 {
+			highest = 0
+			break
+		}
+		highest = max(highest, p.LinkBandwidth)
+	}
+	for i, p := range paths {
+		weights[i] = 1
+		if highest > 0 && maxWeight > 1 {
+			w := math.Round(float64(p.LinkBandwidth/highest) * float64(maxWeight))
+			weights[i] = max(int(w), 1)
+		}
+	}
+	return weights
+}
@@ -0,0 +1,247 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionProcess_Best(t *testing.T) {
+	addr := netip.MustParseAddr
+	base := func(name string) *Path[string] {
+		return &Path[string]{
+			LocalPref:    100,
+			ASPathLength: 2,
+			Origin:       corebgp.OriginIGP,
+			NeighborAS:   65001,
+			RouterID:     addr("192.0.2.1"),
+			PeerAddress:  addr("198.51.100.1"),
+			Value:        name,
+		}
+	}
+	with := func(name string, fn func(p *Path[string])) *Path[string] {
+		p := base(name)
+		fn(p)
+		return p
+	}
+	d := NewDecisionProcess[string]()
+
+	tests := []struct {
+		name     string
+		paths    []*Path[string]
+		wantBest string
+		wantStep Step
+	}{
+		{
+			name:     "single",
+			paths:    []*Path[string]{base("a")},
+			wantBest: "a",
+		},
+		{
+			name: "local pref",
+			paths: []*Path[string]{
+				base("a"),
+				with("b", func(p *Path[string]) { p.LocalPref = 200 }),
+			},
+			wantBest: "b",
+			wantStep: StepLocalPref,
+		},
+		{
+			name: "as path length",
+			paths: []*Path[string]{
+				with("a", func(p *Path[string]) { p.ASPathLength = 3 }),
+				base("b"),
+			},
+			wantBest: "b",
+			wantStep: StepASPathLength,
+		},
+		{
+			name: "med same neighbor as",
+			paths: []*Path[string]{
+				with("a", func(p *Path[string]) { p.MED = 10 }),
+				with("b", func(p *Path[string]) { p.MED = 5 }),
+			},
+			wantBest: "b",
+			wantStep: StepMED,
+		},
+		{
+			name: "med different neighbor as",
+			paths: []*Path[string]{
+				with("a", func(p *Path[string]) {
+					p.MED = 10
+					p.EBGP = true
+				}),
+				with("b", func(p *Path[string]) {
+					p.MED = 5
+					p.NeighborAS = 65002
+				}),
+			},
+			wantBest: "a",
+			wantStep: StepEBGP,
+		},
+		{
+			name: "igp cost",
+			paths: []*Path[string]{
+				with("a", func(p *Path[string]) { p.IGPCost = 20 }),
+				with("b", func(p *Path[string]) { p.IGPCost = 10 }),
+			},
+			wantBest: "b",
+			wantStep: StepIGPCost,
+		},
+		{
+			name: "peer address",
+			paths: []*Path[string]{
+				with("a", func(p *Path[string]) {
+					p.PeerAddress = addr("198.51.100.2")
+				}),
+				base("b"),
+			},
+			wantBest: "b",
+			wantStep: StepPeerAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, step := d.Best(tt.paths)
+			if assert.NotNil(t, best) {
+				assert.Equal(t, tt.wantBest, best.Value)
+			}
+			assert.Equal(t, tt.wantStep, step)
+		})
+	}
+
+	best, step := d.Best(nil)
+	assert.Nil(t, best)
+	assert.Equal(t, Step(""), step)
+}
+
+func TestDecisionProcess_Insert(t *testing.T) {
+	const stepAIGP Step = "aigp"
+	const stepColor Step = "color"
+	d := NewDecisionProcess[uint32]()
+	assert.NoError(t, d.InsertBefore(StepASPathLength, stepAIGP,
+		CompareAIGP[uint32]()))
+	// prefer paths whose color, carried in Value, is 100
+	assert.NoError(t, d.InsertAfter(stepAIGP, stepColor,
+		func(a, b *Path[uint32]) int {
+			return compareBool(a.Value == 100, b.Value == 100)
+		}))
+	assert.Error(t, d.InsertAfter(StepMED, stepColor,
+		func(a, b *Path[uint32]) int { return 0 }))
+	assert.Error(t, d.InsertAfter("unknown", "other",
+		func(a, b *Path[uint32]) int { return 0 }))
+	assert.Error(t, d.InsertAfter(StepMED, "other", nil))
+	assert.Equal(t, []Step{
+		StepLocalPref,
+		stepAIGP,
+		stepColor,
+		StepASPathLength,
+		StepOrigin,
+		StepMED,
+		StepEBGP,
+		StepIGPCost,
+		StepRouterID,
+		StepClusterListLength,
+		StepPeerAddress,
+	}, d.Steps())
+
+	aigp := func(v uint64) *uint64 { return &v }
+	paths := []*Path[uint32]{
+		{AIGP: nil, Value: 100},
+		{AIGP: aigp(20), IGPCost: 5, ASPathLength: 1, Value: 200},
+		{AIGP: aigp(10), IGPCost: 15, ASPathLength: 2, Value: 100},
+		{AIGP: aigp(10), IGPCost: 10, ASPathLength: 3, Value: 300},
+	}
+	best, step := d.Best(paths)
+	assert.Equal(t, paths[3], best)
+	assert.Equal(t, stepAIGP, step)
+
+	paths[3].IGPCost = 15
+	best, step = d.Best(paths)
+	assert.Equal(t, paths[2], best)
+	assert.Equal(t, stepColor, step)
+}
+
+func TestDecisionProcess_MEDOptions(t *testing.T) {
+	paths := []*Path[string]{
+		{NeighborAS: 65001, MED: 10, IGPCost: 10, Value: "a"},
+		{NeighborAS: 65002, MED: 20, IGPCost: 5, Value: "b"},
+		{NeighborAS: 65001, MEDMissing: true, IGPCost: 20, Value: "c"},
+	}
+	tests := []struct {
+		name     string
+		opts     []DecisionOption
+		wantBest string
+		wantStep Step
+	}{
+		{
+			name:     "default",
+			wantBest: "b",
+			wantStep: StepIGPCost,
+		},
+		{
+			name:     "always compare med",
+			opts:     []DecisionOption{WithAlwaysCompareMED()},
+			wantBest: "c",
+			wantStep: StepMED,
+		},
+		{
+			name: "always compare med missing med as worst",
+			opts: []DecisionOption{WithAlwaysCompareMED(),
+				WithMissingMEDAsWorst()},
+			wantBest: "a",
+			wantStep: StepMED,
+		},
+		{
+			name:     "deterministic med",
+			opts:     []DecisionOption{WithDeterministicMED()},
+			wantBest: "b",
+			wantStep: StepIGPCost,
+		},
+		{
+			name: "deterministic med missing med as worst",
+			opts: []DecisionOption{WithDeterministicMED(),
+				WithMissingMEDAsWorst()},
+			wantBest: "b",
+			wantStep: StepIGPCost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecisionProcess[string](tt.opts...)
+			best, step := d.Best(paths)
+			if assert.NotNil(t, best) {
+				assert.Equal(t, tt.wantBest, best.Value)
+			}
+			assert.Equal(t, tt.wantStep, step)
+		})
+	}
+
+	// paths from the same neighboring AS are compared directly
+	d := NewDecisionProcess[string](WithDeterministicMED(),
+		WithMissingMEDAsWorst())
+	best, step := d.Best([]*Path[string]{paths[0], paths[2]})
+	assert.Equal(t, paths[0], best)
+	assert.Equal(t, StepMED, step)
+}
+
+func TestDecisionProcess_Multipath(t *testing.T) {
+	addr := netip.MustParseAddr
+	paths := []*Path[string]{
+		{IGPCost: 10, RouterID: addr("192.0.2.2"), LinkBandwidth: 1e9,
+			Value: "a"},
+		{IGPCost: 10, RouterID: addr("192.0.2.1"), LinkBandwidth: 3e9,
+			Value: "b"},
+		{IGPCost: 20, RouterID: addr("192.0.2.3"), Value: "c"},
+	}
+	d := NewDecisionProcess[string]()
+	multipath := d.Multipath(paths)
+	assert.Equal(t, []*Path[string]{paths[1], paths[0]}, multipath)
+	assert.Equal(t, []int{256, 85}, MultipathWeights(multipath, 256))
+	assert.Equal(t, []int{1, 1}, MultipathWeights(multipath, 1))
+	assert.Equal(t, []int{1, 1, 1}, MultipathWeights(paths, 256))
+	assert.Nil(t, d.Multipath(nil))
+	assert.Equal(t, []*Path[string]{paths[2]}, d.Multipath(paths[2:]))
+}
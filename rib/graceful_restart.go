@@ -0,0 +1,410 @@
+package rib
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// GracefulRestartEventType is the type of a GracefulRestartEvent.
+type GracefulRestartEventType uint8
+
+const (
+	// GracefulRestartStale indicates the routes of a family were retained and
+	// marked stale as the session went down.
+	GracefulRestartStale GracefulRestartEventType = iota
+	// GracefulRestartFlushed indicates the routes of a family were removed as
+	// the session went down, as the family was not covered by the peer's
+	// Graceful Restart Capability.
+	GracefulRestartFlushed
+	// GracefulRestartSweptNotPreserved indicates the stale routes of a family
+	// were removed once the session was re-established, as the peer did not
+	// preserve forwarding state for the family.
+	GracefulRestartSweptNotPreserved
+	// GracefulRestartSweptEndOfRIB indicates the stale routes of a family
+	// that were not refreshed were removed upon receipt of End-of-RIB.
+	GracefulRestartSweptEndOfRIB
+	// GracefulRestartSweptRestartTimer indicates the stale routes of a
+	// family were removed as the session was not re-established within the
+	// restart time.
+	GracefulRestartSweptRestartTimer
+	// GracefulRestartSweptStaleTimer indicates the stale routes of a family
+	// were removed as End-of-RIB was not received within the stale routes
+	// time, see GracefulRestart.SessionUp.
+	GracefulRestartSweptStaleTimer
+	// GracefulRestartRestored indicates routes of a family were restored from
+	// a Store and marked stale, see GracefulRestart.Persist.
+	GracefulRestartRestored
+)
+
+func (g GracefulRestartEventType) String() string {
+	switch g {
+	case GracefulRestartStale:
+		return "stale"
+	case GracefulRestartFlushed:
+		return "flushed"
+	case GracefulRestartSweptNotPreserved:
+		return "swept-not-preserved"
+	case GracefulRestartSweptEndOfRIB:
+		return "swept-end-of-rib"
+	case GracefulRestartSweptRestartTimer:
+		return "swept-restart-timer"
+	case GracefulRestartSweptStaleTimer:
+		return "swept-stale-timer"
+	case GracefulRestartRestored:
+		return "restored"
+	default:
+		return "unknown"
+	}
+}
+
+// GracefulRestartEvent describes a phase of the Graceful Restart procedures
+// for a family.
+type GracefulRestartEvent struct {
+	Type   GracefulRestartEventType
+	Family corebgp.MPExtensions
+	// Routes is the number of routes marked stale or removed.
+	Routes int
+}
+
+// GracefulRestart implements the procedures of the Receiving Speaker for the
+// routes received from a single peer, e.g. its Adj-RIB-In, held in a Table
+// per family.
+//
+// Routes must be added and removed via Set and Delete, which refresh stale
+// routes. The application calls SessionDown when the session with the peer
+// terminates, SessionUp when it is re-established, and EndOfRIB when an
+// End-of-RIB marker is received, e.g. from a corebgp.EndOfRIBObserver.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-4.2
+type GracefulRestart[T any] struct {
+	observer func(e GracefulRestartEvent)
+
+	mu     sync.Mutex
+	tables map[corebgp.MPExtensions]*Table[T]
+	stale  map[corebgp.MPExtensions]map[netip.Prefix]struct{}
+	timer  *time.Timer
+	// gen is incremented each time the timer is replaced so that a timer
+	// that fires concurrently with its replacement has no effect.
+	gen uint64
+	// persist, if non-nil, subscribes newly created tables to a Store, see
+	// Persist.
+	persist func(family corebgp.MPExtensions, t *Table[T])
+}
+
+// NewGracefulRestart returns a GracefulRestart with no routes. observer, if
+// non-nil, is called for each GracefulRestartEvent. It may be called from a
+// timer goroutine, and must not call methods of the GracefulRestart.
+func NewGracefulRestart[T any](
+	observer func(e GracefulRestartEvent)) *GracefulRestart[T] {
+	return &GracefulRestart[T]{
+		observer: observer,
+		tables:   make(map[corebgp.MPExtensions]*Table[T]),
+		stale:    make(map[corebgp.MPExtensions]map[netip.Prefix]struct{}),
+	}
+}
+
+// Table returns the Table of family, creating it if it does not exist. The
+// Table must not be modified directly, see Set and Delete.
+func (g *GracefulRestart[T]) Table(family corebgp.MPExtensions) *Table[T] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.tableLocked(family)
+}
+
+func (g *GracefulRestart[T]) tableLocked(
+	family corebgp.MPExtensions) *Table[T] {
+	t, ok := g.tables[family]
+	if !ok {
+		t = &Table[T]{}
+		g.tables[family] = t
+		if g.persist != nil {
+			g.persist(family, t)
+		}
+	}
+	return t
+}
+
+// Persist restores the routes held in c.Store, marking them stale, and
+// writes subsequent changes to the routes of all families through to
+// c.Store. It is typically called once at startup, so that the routes
+// received from the peer prior to a restart are available immediately, and
+// are refreshed or removed per the Graceful Restart procedures once the
+// session is re-established, see SessionUp and EndOfRIB. restartTime, if
+// non-zero, bounds the time to wait for the session to be re-established
+// before the restored routes are removed, e.g. the restart time of the
+// peer's Graceful Restart Capability received prior to the restart.
+//
+// Prefixes already present are not replaced by restored routes. An error is
+// returned if routes have already been persisted, or if c.Store could not be
+// read, in which case the routes restored prior to the error are retained but
+// not persisted.
+func (g *GracefulRestart[T]) Persist(c StoreConfig[T],
+	restartTime time.Duration) error {
+	g.mu.Lock()
+	if g.persist != nil {
+		g.mu.Unlock()
+		return errors.New("routes are already persisted")
+	}
+	restored := make(map[corebgp.MPExtensions]map[netip.Prefix]struct{})
+	err := c.restore(func(family corebgp.MPExtensions, p netip.Prefix) bool {
+		_, exists := g.tableLocked(family).Get(p)
+		return !exists
+	}, func(family corebgp.MPExtensions, p netip.Prefix, v T) {
+		g.tableLocked(family).Set(p, v)
+		if restored[family] == nil {
+			restored[family] = make(map[netip.Prefix]struct{})
+		}
+		restored[family][p] = struct{}{}
+		if g.stale[family] == nil {
+			g.stale[family] = make(map[netip.Prefix]struct{})
+		}
+		g.stale[family][p] = struct{}{}
+	})
+	if err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	g.persist = func(family corebgp.MPExtensions, t *Table[T]) {
+		t.Subscribe(c.writer(family, restored[family]))
+	}
+	events := make([]GracefulRestartEvent, 0, len(restored))
+	for family, t := range g.tables {
+		g.persist(family, t)
+		if n := len(restored[family]); n > 0 {
+			events = append(events, GracefulRestartEvent{
+				Type:   GracefulRestartRestored,
+				Family: family,
+				Routes: n,
+			})
+		}
+	}
+	if restartTime > 0 && len(g.stale) > 0 {
+		g.startTimerLocked(restartTime, GracefulRestartSweptRestartTimer)
+	}
+	g.mu.Unlock()
+	g.notify(events)
+	return nil
+}
+
+// Set sets the value of p in the Table of family, refreshing p if it is
+// stale.
+func (g *GracefulRestart[T]) Set(family corebgp.MPExtensions, p netip.Prefix,
+	v T) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.stale[family], p.Masked())
+	g.tableLocked(family).Set(p, v)
+}
+
+// Delete removes p from the Table of family, returning true if it was
+// present.
+func (g *GracefulRestart[T]) Delete(family corebgp.MPExtensions,
+	p netip.Prefix) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.stale[family], p.Masked())
+	return g.tableLocked(family).Delete(p)
+}
+
+// Stale returns the number of stale routes of family.
+func (g *GracefulRestart[T]) Stale(family corebgp.MPExtensions) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.stale[family])
+}
+
+// SessionDown applies the procedures for the termination of the session with
+// the peer. capability is the Graceful Restart Capability received from the
+// peer for the session, or nil if none was received, in which case all
+// routes are removed.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-4.2
+// If the session does not get re-established within the "Restart Time" that
+// the peer advertised previously, the Receiving Speaker MUST delete all the
+// stale routes from the peer that it is retaining.
+//
+// When the Receiving Speaker detects termination of the TCP session for a
+// BGP session with a peer that has advertised the Graceful Restart
+// Capability, it MUST retain the routes received from the peer for all the
+// address families that were previously received in the Graceful Restart
+// Capability and MUST mark them as stale routing information.
+func (g *GracefulRestart[T]) SessionDown(
+	capability *corebgp.GracefulRestart) {
+	g.mu.Lock()
+	events := make([]GracefulRestartEvent, 0, len(g.tables))
+	retain := make(map[corebgp.MPExtensions]bool)
+	if capability != nil {
+		for _, f := range capability.Families {
+			retain[corebgp.MPExtensions{AFI: f.AFI, SAFI: f.SAFI}] = true
+		}
+	}
+	for family, t := range g.tables {
+		if !retain[family] {
+			events = append(events, GracefulRestartEvent{
+				Type:   GracefulRestartFlushed,
+				Family: family,
+				Routes: g.sweepLocked(family, true),
+			})
+			continue
+		}
+		stale := make(map[netip.Prefix]struct{})
+		t.Walk(func(p netip.Prefix, _ T) bool {
+			stale[p] = struct{}{}
+			return true
+		})
+		g.stale[family] = stale
+		events = append(events, GracefulRestartEvent{
+			Type:   GracefulRestartStale,
+			Family: family,
+			Routes: len(stale),
+		})
+	}
+	if capability != nil {
+		g.startTimerLocked(time.Duration(capability.RestartTime)*time.Second,
+			GracefulRestartSweptRestartTimer)
+	}
+	g.mu.Unlock()
+	g.notify(events)
+}
+
+// SessionUp applies the procedures for the re-establishment of the session
+// with the peer. capability is the Graceful Restart Capability received from
+// the peer for the new session, or nil if none was received. staleTime, if
+// non-zero, bounds the time to wait for End-of-RIB before the remaining stale
+// routes are removed.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-4.2
+// If the Graceful Restart Capability is not received in the re-established
+// session, or the "Forwarding State" bit is not set for an address family in
+// the re-established session, the Receiving Speaker MUST immediately remove
+// all the stale routes from the peer that it is retaining for that address
+// family.
+func (g *GracefulRestart[T]) SessionUp(capability *corebgp.GracefulRestart,
+	staleTime time.Duration) {
+	g.mu.Lock()
+	g.stopTimerLocked()
+	preserved := make(map[corebgp.MPExtensions]bool)
+	if capability != nil {
+		for _, f := range capability.Families {
+			if f.ForwardingPreserved {
+				preserved[corebgp.MPExtensions{AFI: f.AFI, SAFI: f.SAFI}] = true
+			}
+		}
+	}
+	events := make([]GracefulRestartEvent, 0)
+	for family := range g.stale {
+		if !preserved[family] {
+			events = append(events, GracefulRestartEvent{
+				Type:   GracefulRestartSweptNotPreserved,
+				Family: family,
+				Routes: g.sweepLocked(family, false),
+			})
+		}
+	}
+	if len(g.stale) > 0 && staleTime > 0 {
+		g.startTimerLocked(staleTime, GracefulRestartSweptStaleTimer)
+	}
+	g.mu.Unlock()
+	g.notify(events)
+}
+
+// EndOfRIB removes the stale routes of family that have not been refreshed.
+//
+// https://www.rfc-editor.org/rfc/rfc4724#section-4.2
+// Once the End-of-RIB marker for an address family is received from the
+// peer, it MUST immediately remove any routes from the peer that are still
+// marked as stale for that address family.
+func (g *GracefulRestart[T]) EndOfRIB(family corebgp.MPExtensions) {
+	g.mu.Lock()
+	_, ok := g.stale[family]
+	if !ok {
+		g.mu.Unlock()
+		return
+	}
+	n := g.sweepLocked(family, false)
+	if len(g.stale) == 0 {
+		g.stopTimerLocked()
+	}
+	g.mu.Unlock()
+	g.notify([]GracefulRestartEvent{{
+		Type:   GracefulRestartSweptEndOfRIB,
+		Family: family,
+		Routes: n,
+	}})
+}
+
+// sweepLocked removes the stale routes of family, or all routes if all is
+// true, returning the number removed. It must be called with g.mu held.
+func (g *GracefulRestart[T]) sweepLocked(family corebgp.MPExtensions,
+	all bool) int {
+	t := g.tables[family]
+	n := 0
+	if all {
+		prefixes := make([]netip.Prefix, 0, t.Len())
+		t.Walk(func(p netip.Prefix, _ T) bool {
+			prefixes = append(prefixes, p)
+			return true
+		})
+		for _, p := range prefixes {
+			if t.Delete(p) {
+				n++
+			}
+		}
+	} else {
+		for p := range g.stale[family] {
+			if t.Delete(p) {
+				n++
+			}
+		}
+	}
+	delete(g.stale, family)
+	return n
+}
+
+// startTimerLocked replaces the timer with one sweeping all stale routes
+// after d. It must be called with g.mu held.
+func (g *GracefulRestart[T]) startTimerLocked(d time.Duration,
+	typ GracefulRestartEventType) {
+	g.stopTimerLocked()
+	gen := g.gen
+	g.timer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		if gen != g.gen {
+			g.mu.Unlock()
+			return
+		}
+		g.timer = nil
+		events := make([]GracefulRestartEvent, 0, len(g.stale))
+		for family := range g.stale {
+			events = append(events, GracefulRestartEvent{
+				Type:   typ,
+				Family: family,
+				Routes: g.sweepLocked(family, false),
+			})
+		}
+		g.mu.Unlock()
+		g.notify(events)
+	})
+}
+
+// stopTimerLocked stops the timer, if any. It must be called with g.mu held.
+func (g *GracefulRestart[T]) stopTimerLocked() {
+	g.gen++
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+}
+
+func (g *GracefulRestart[T]) notify(events []GracefulRestartEvent) {
+	if g.observer == nil {
+		return
+	}
+	for _, e := range events {
+		g.observer(e)
+	}
+}
@@ -0,0 +1,135 @@
+package rib
+
+import (
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+type grEventRecorder struct {
+	mu     sync.Mutex
+	events []GracefulRestartEvent
+}
+
+func (r *grEventRecorder) observe(e GracefulRestartEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *grEventRecorder) get() []GracefulRestartEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]GracefulRestartEvent{}, r.events...)
+}
+
+func TestGracefulRestart(t *testing.T) {
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	v6 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV6, SAFI: corebgp.SAFI_UNICAST}
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	p3 := netip.MustParsePrefix("2001:db8::/32")
+
+	var r grEventRecorder
+	g := NewGracefulRestart[int](r.observe)
+	g.Set(v4, p1, 1)
+	g.Set(v4, p2, 2)
+	g.Set(v6, p3, 3)
+
+	// only IPv4 unicast is covered by the capability
+	g.SessionDown(&corebgp.GracefulRestart{
+		RestartTime: 120,
+		Families: []corebgp.GracefulRestartFamily{
+			{AFI: v4.AFI, SAFI: v4.SAFI, ForwardingPreserved: true},
+		},
+	})
+	assert.ElementsMatch(t, []GracefulRestartEvent{
+		{Type: GracefulRestartStale, Family: v4, Routes: 2},
+		{Type: GracefulRestartFlushed, Family: v6, Routes: 1},
+	}, r.get())
+	assert.Equal(t, 2, g.Table(v4).Len(), "stale routes must be retained")
+	assert.Equal(t, 0, g.Table(v6).Len())
+	assert.Equal(t, 2, g.Stale(v4))
+
+	g.SessionUp(&corebgp.GracefulRestart{
+		Families: []corebgp.GracefulRestartFamily{
+			{AFI: v4.AFI, SAFI: v4.SAFI, ForwardingPreserved: true},
+		},
+	}, 0)
+	// p1 is refreshed by the peer, p2 is not
+	g.Set(v4, p1, 10)
+	assert.Equal(t, 1, g.Stale(v4))
+	g.EndOfRIB(v4)
+	assert.Equal(t, GracefulRestartEvent{
+		Type:   GracefulRestartSweptEndOfRIB,
+		Family: v4,
+		Routes: 1,
+	}, r.get()[2])
+	v, ok := g.Table(v4).Get(p1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	_, ok = g.Table(v4).Get(p2)
+	assert.False(t, ok)
+	assert.Equal(t, 0, g.Stale(v4))
+
+	// forwarding state not preserved upon re-establishment
+	g.SessionDown(&corebgp.GracefulRestart{
+		RestartTime: 120,
+		Families: []corebgp.GracefulRestartFamily{
+			{AFI: v4.AFI, SAFI: v4.SAFI},
+		},
+	})
+	g.SessionUp(nil, 0)
+	assert.Equal(t, GracefulRestartEvent{
+		Type:   GracefulRestartSweptNotPreserved,
+		Family: v4,
+		Routes: 1,
+	}, r.get()[len(r.get())-1])
+	assert.Equal(t, 0, g.Table(v4).Len())
+
+	// no capability, routes are removed immediately
+	g.Set(v4, p1, 1)
+	g.SessionDown(nil)
+	assert.Equal(t, 0, g.Table(v4).Len())
+}
+
+func TestGracefulRestart_timers(t *testing.T) {
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	capability := &corebgp.GracefulRestart{
+		RestartTime: 1,
+		Families: []corebgp.GracefulRestartFamily{
+			{AFI: v4.AFI, SAFI: v4.SAFI, ForwardingPreserved: true},
+		},
+	}
+
+	var r grEventRecorder
+	g := NewGracefulRestart[int](r.observe)
+	g.Set(v4, p1, 1)
+	g.SessionDown(capability)
+	assert.Eventually(t, func() bool {
+		return g.Table(v4).Len() == 0
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, GracefulRestartEvent{
+		Type:   GracefulRestartSweptRestartTimer,
+		Family: v4,
+		Routes: 1,
+	}, r.get()[1])
+
+	g.Set(v4, p1, 1)
+	g.SessionDown(capability)
+	g.SessionUp(capability, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		return g.Table(v4).Len() == 0
+	}, time.Second*5, time.Millisecond*10)
+	events := r.get()
+	assert.Equal(t, GracefulRestartEvent{
+		Type:   GracefulRestartSweptStaleTimer,
+		Family: v4,
+		Routes: 1,
+	}, events[len(events)-1])
+}
@@ -0,0 +1,254 @@
+package rib
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/jwhited/corebgp/rpki"
+)
+
+// MonitorEventType is the type of a MonitorEvent.
+type MonitorEventType uint8
+
+const (
+	// MonitorEventOriginChange indicates the set of origin ASes of a prefix
+	// changed, e.g. a second AS started originating it. It is not raised when
+	// a prefix is first announced or when it is withdrawn by all sources.
+	MonitorEventOriginChange MonitorEventType = iota
+	// MonitorEventMoreSpecific indicates a prefix more specific than a
+	// watched prefix was announced by a source while it was announced by no
+	// other.
+	MonitorEventMoreSpecific
+	// MonitorEventInvalid indicates an announcement whose route origin
+	// validation state is invalid.
+	MonitorEventInvalid
+)
+
+func (m MonitorEventType) String() string {
+	switch m {
+	case MonitorEventOriginChange:
+		return "origin-change"
+	case MonitorEventMoreSpecific:
+		return "more-specific"
+	case MonitorEventInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// MonitorEvent is an event raised by a Monitor.
+type MonitorEvent struct {
+	Type   MonitorEventType
+	Prefix netip.Prefix
+	// Source is the source whose announcement or withdrawal raised the event.
+	Source string
+	// Origin is the origin AS of the announcement that raised the event, zero
+	// if the event was raised by a withdrawal.
+	Origin uint32
+	// Origins and PreviousOrigins are the sorted origin ASes of Prefix across
+	// all sources after and before the change, respectively.
+	Origins         []uint32
+	PreviousOrigins []uint32
+	// Watched is the most specific watched prefix covering Prefix, set for
+	// MonitorEventMoreSpecific.
+	Watched netip.Prefix
+}
+
+// Validator performs route origin validation, e.g. an *rpki.Client or an
+// *rpki.VRPSet.
+type Validator interface {
+	Validate(prefix netip.Prefix, origin uint32) rpki.ValidationState
+}
+
+// Monitor raises events for changes to the routes of one or more sources,
+// e.g. the Adj-RIB-In of each peer, that may indicate a prefix hijack: a
+// change to the set of ASes originating a prefix, the announcement of a
+// prefix more specific than a watched prefix, and announcements that are
+// invalid according to route origin validation.
+//
+// Routes are reported via Announce and Withdraw, or by attaching a Table with
+// MonitorTable.
+type Monitor struct {
+	validator Validator
+	observer  func(e MonitorEvent)
+
+	mu      sync.Mutex
+	watched Trie[struct{}]
+	// origins holds the origin AS of each prefix by source
+	origins map[netip.Prefix]map[string]uint32
+}
+
+// NewMonitor returns a Monitor with no routes and no watched prefixes.
+// validator, if non-nil, is used to raise MonitorEventInvalid events. observer
+// is called for each MonitorEvent, in the order the events are raised, from
+// the goroutine reporting the change. It must not call methods of the
+// Monitor.
+func NewMonitor(validator Validator,
+	observer func(e MonitorEvent)) *Monitor {
+	return &Monitor{
+		validator: validator,
+		observer:  observer,
+		origins:   make(map[netip.Prefix]map[string]uint32),
+	}
+}
+
+// Watch adds p to the watched prefixes. The announcement of a more specific
+// prefix raises a MonitorEventMoreSpecific event.
+func (m *Monitor) Watch(p netip.Prefix) {
+	if !p.IsValid() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watched.Insert(p.Masked(), struct{}{})
+}
+
+// Unwatch removes p from the watched prefixes.
+func (m *Monitor) Unwatch(p netip.Prefix) {
+	if !p.IsValid() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watched.Delete(p.Masked())
+}
+
+// Origins returns the sorted origin ASes of p across all sources.
+func (m *Monitor) Origins(p netip.Prefix) []uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.originsLocked(p.Masked())
+}
+
+func (m *Monitor) originsLocked(p netip.Prefix) []uint32 {
+	bySource := m.origins[p]
+	if len(bySource) == 0 {
+		return nil
+	}
+	origins := make([]uint32, 0, len(bySource))
+	for _, origin := range bySource {
+		origins = append(origins, origin)
+	}
+	slices.Sort(origins)
+	return slices.Compact(origins)
+}
+
+// Announce reports the announcement of p by source, originated by origin.
+// origin should be 0 if the origin AS cannot be determined, e.g. the AS_PATH
+// ends with an AS_SET. An announcement replaces the previous announcement of
+// p by source, if any.
+func (m *Monitor) Announce(source string, p netip.Prefix, origin uint32) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.originsLocked(p)
+	bySource, ok := m.origins[p]
+	if !ok {
+		bySource = make(map[string]uint32)
+		m.origins[p] = bySource
+	}
+	bySource[source] = origin
+	cur := m.originsLocked(p)
+	e := MonitorEvent{
+		Prefix:          p,
+		Source:          source,
+		Origin:          origin,
+		Origins:         cur,
+		PreviousOrigins: prev,
+	}
+	if len(prev) == 0 {
+		m.watched.WalkCovering(p, func(w netip.Prefix, _ struct{}) bool {
+			if w.Bits() < p.Bits() {
+				e.Watched = w
+			}
+			return true
+		})
+		if e.Watched.IsValid() {
+			e.Type = MonitorEventMoreSpecific
+			m.notify(e)
+		}
+	} else if !slices.Equal(prev, cur) {
+		e.Type = MonitorEventOriginChange
+		m.notify(e)
+	}
+	if m.validator != nil &&
+		m.validator.Validate(p, origin) == rpki.ValidationStateInvalid {
+		e.Type = MonitorEventInvalid
+		e.Watched = netip.Prefix{}
+		m.notify(e)
+	}
+}
+
+// Withdraw reports the withdrawal of p by source.
+func (m *Monitor) Withdraw(source string, p netip.Prefix) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.withdrawLocked(source, p)
+}
+
+func (m *Monitor) withdrawLocked(source string, p netip.Prefix) {
+	bySource := m.origins[p]
+	if _, ok := bySource[source]; !ok {
+		return
+	}
+	prev := m.originsLocked(p)
+	delete(bySource, source)
+	if len(bySource) == 0 {
+		delete(m.origins, p)
+		return
+	}
+	cur := m.originsLocked(p)
+	if !slices.Equal(prev, cur) {
+		m.notify(MonitorEvent{
+			Type:            MonitorEventOriginChange,
+			Prefix:          p,
+			Source:          source,
+			Origins:         cur,
+			PreviousOrigins: prev,
+		})
+	}
+}
+
+// RemoveSource withdraws all prefixes announced by source, e.g. when the
+// session with a peer terminates.
+func (m *Monitor) RemoveSource(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for p, bySource := range m.origins {
+		if _, ok := bySource[source]; ok {
+			m.withdrawLocked(source, p)
+		}
+	}
+}
+
+func (m *Monitor) notify(e MonitorEvent) {
+	if m.observer != nil {
+		m.observer(e)
+	}
+}
+
+// MonitorTable reports the routes of t, e.g. the Adj-RIB-In of a peer, to m
+// as those of source. origin returns the origin AS of a route, see
+// Monitor.Announce. The routes of source are reported for as long as the
+// returned Subscription is open. Closing it does not withdraw them, see
+// Monitor.RemoveSource.
+func MonitorTable[T any](m *Monitor, source string, t *Table[T],
+	origin func(v T) uint32) *Subscription[T] {
+	return t.Subscribe(func(e Event[T]) {
+		switch e.Type {
+		case EventAdd, EventUpdate:
+			m.Announce(source, e.Prefix, origin(e.Value))
+		case EventWithdraw:
+			m.Withdraw(source, e.Prefix)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp/rpki"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	watched := netip.MustParsePrefix("192.0.2.0/23")
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	vrps := rpki.NewVRPSet([]rpki.VRP{
+		{Prefix: p2, MaxLength: 24, ASN: 64500},
+	})
+	var events []MonitorEvent
+	m := NewMonitor(vrps, func(e MonitorEvent) {
+		events = append(events, e)
+	})
+	m.Watch(watched)
+
+	// a more specific of a watched prefix appears
+	m.Announce("a", p1, 64500)
+	// a second source with the same origin, no event
+	m.Announce("b", p1, 64500)
+	// a third source with another origin
+	m.Announce("c", p1, 64501)
+	assert.Equal(t, []uint32{64500, 64501}, m.Origins(p1))
+	m.Withdraw("a", p1)
+	m.RemoveSource("c")
+	m.Withdraw("b", p1)
+	assert.Nil(t, m.Origins(p1))
+
+	// watched prefix itself, no event
+	m.Announce("a", watched, 64500)
+	m.Unwatch(watched)
+	m.Announce("a", p1, 64500)
+
+	// valid and invalid
+	m.Announce("a", p2, 64500)
+	m.Announce("b", p2, 64502)
+
+	want := []MonitorEvent{
+		{
+			Type:    MonitorEventMoreSpecific,
+			Prefix:  p1,
+			Source:  "a",
+			Origin:  64500,
+			Origins: []uint32{64500},
+			Watched: watched,
+		},
+		{
+			Type:            MonitorEventOriginChange,
+			Prefix:          p1,
+			Source:          "c",
+			Origin:          64501,
+			Origins:         []uint32{64500, 64501},
+			PreviousOrigins: []uint32{64500},
+		},
+		{
+			Type:            MonitorEventOriginChange,
+			Prefix:          p1,
+			Source:          "c",
+			Origins:         []uint32{64500},
+			PreviousOrigins: []uint32{64500, 64501},
+		},
+		{
+			Type:            MonitorEventOriginChange,
+			Prefix:          p2,
+			Source:          "b",
+			Origin:          64502,
+			Origins:         []uint32{64500, 64502},
+			PreviousOrigins: []uint32{64500},
+		},
+		{
+			Type:            MonitorEventInvalid,
+			Prefix:          p2,
+			Source:          "b",
+			Origin:          64502,
+			Origins:         []uint32{64500, 64502},
+			PreviousOrigins: []uint32{64500},
+		},
+	}
+	assert.Equal(t, want, events)
+}
+
+func TestMonitorTable(t *testing.T) {
+	p := netip.MustParsePrefix("192.0.2.0/24")
+	ch := make(chan MonitorEvent, 16)
+	m := NewMonitor(nil, func(e MonitorEvent) {
+		ch <- e
+	})
+	var a, b Table[uint32]
+	a.Set(p, 64500)
+	subA := MonitorTable(m, "a", &a, func(v uint32) uint32 { return v })
+	defer subA.Close()
+	subB := MonitorTable(m, "b", &b, func(v uint32) uint32 { return v })
+	defer subB.Close()
+	b.Set(p, 64501)
+
+	select {
+	case e := <-ch:
+		assert.Equal(t, MonitorEventOriginChange, e.Type)
+		assert.Equal(t, []uint32{64500, 64501}, e.Origins)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
@@ -0,0 +1,4 @@
+// Package rib provides building blocks for the Routing Information Bases of
+// corebgp applications, e.g. an Adj-RIB-In per peer or a Loc-RIB of selected
+// routes. corebgp itself does not maintain a RIB.
+package rib
@@ -0,0 +1,148 @@
+package rib
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Store persists routes across process restarts, e.g. so that the routes of
+// an Adj-RIB-In are available immediately after a restart, and retained as
+// stale routes per Graceful Restart until the session is re-established, see
+// GracefulRestart.Persist. Routes are keyed by a bucket, e.g. the address of
+// the peer they were received from, family, and prefix. See the ribbolt module
+// for an implementation backed by bbolt.
+//
+// A Store must be safe for concurrent use.
+type Store interface {
+	// Put stores v as the route for p, replacing any existing route.
+	Put(bucket string, family corebgp.MPExtensions, p netip.Prefix,
+		v []byte) error
+
+	// Delete removes the route for p, if any.
+	Delete(bucket string, family corebgp.MPExtensions, p netip.Prefix) error
+
+	// Walk calls fn for each route in bucket until fn returns an error,
+	// which is returned by Walk. v is only valid until fn returns.
+	Walk(bucket string, fn func(family corebgp.MPExtensions, p netip.Prefix,
+		v []byte) error) error
+
+	// Clear removes all routes in bucket.
+	Clear(bucket string) error
+}
+
+// Codec encodes and decodes the values of a Table for a Store.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// BytesCodec is a Codec for Tables of byte slices, e.g. path attributes.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+func (BytesCodec) Decode(b []byte) ([]byte, error) {
+	return append([]byte(nil), b...), nil
+}
+
+// AttrsCodec is a Codec for Tables of Attrs interned in Table. Each decoded
+// Attrs must be released per AttrTable.Intern.
+type AttrsCodec struct {
+	Table *AttrTable
+}
+
+func (c AttrsCodec) Encode(v *Attrs) ([]byte, error) {
+	return v.Bytes(), nil
+}
+
+func (c AttrsCodec) Decode(b []byte) (*Attrs, error) {
+	return c.Table.Intern(b), nil
+}
+
+// StoreConfig configures the persistence of routes to a Store, see Persist.
+type StoreConfig[T any] struct {
+	Store Store
+
+	// Bucket scopes the routes in Store, e.g. to a peer.
+	Bucket string
+
+	Codec Codec[T]
+
+	// OnError, if non-nil, is called with errors writing changes to Store.
+	// Changes are written asynchronously, so they cannot be returned to the
+	// caller modifying a Table.
+	OnError func(err error)
+}
+
+// writer returns a Subscription callback writing changes to the Table of
+// family through to c.Store. Events of the initial snapshot for prefixes in
+// restored, which were read from c.Store, are not written back.
+func (c StoreConfig[T]) writer(family corebgp.MPExtensions,
+	restored map[netip.Prefix]struct{}) func(e Event[T]) {
+	return func(e Event[T]) {
+		var err error
+		switch e.Type {
+		case EventAdd, EventUpdate:
+			if _, ok := restored[e.Prefix]; ok {
+				return
+			}
+			var b []byte
+			b, err = c.Codec.Encode(e.Value)
+			if err == nil {
+				err = c.Store.Put(c.Bucket, family, e.Prefix, b)
+			}
+		case EventWithdraw:
+			err = c.Store.Delete(c.Bucket, family, e.Prefix)
+		case EventEndOfSnapshot:
+			restored = nil
+		}
+		if err != nil && c.OnError != nil {
+			c.OnError(fmt.Errorf("error persisting %s of %s: %w", e.Type,
+				e.Prefix, err))
+		}
+	}
+}
+
+// restore calls fn with each route of c.Store for which want returns true,
+// decoded.
+func (c StoreConfig[T]) restore(want func(family corebgp.MPExtensions,
+	p netip.Prefix) bool, fn func(family corebgp.MPExtensions, p netip.Prefix,
+	v T)) error {
+	return c.Store.Walk(c.Bucket, func(family corebgp.MPExtensions,
+		p netip.Prefix, b []byte) error {
+		if !want(family, p) {
+			return nil
+		}
+		v, err := c.Codec.Decode(b)
+		if err != nil {
+			return fmt.Errorf("error decoding %s: %w", p, err)
+		}
+		fn(family, p, v)
+		return nil
+	})
+}
+
+// Persist restores the routes of family in c.Store to t, and writes subsequent
+// changes to t through to c.Store until the returned Subscription is closed.
+// Prefixes already present in t are not replaced by restored routes, and are
+// written to c.Store along with the changes. t must not be modified
+// concurrently with Persist.
+func Persist[T any](t *Table[T], family corebgp.MPExtensions,
+	c StoreConfig[T]) (*Subscription[T], error) {
+	restored := make(map[netip.Prefix]struct{})
+	err := c.restore(func(f corebgp.MPExtensions, p netip.Prefix) bool {
+		_, exists := t.Get(p)
+		return f == family && !exists
+	}, func(_ corebgp.MPExtensions, p netip.Prefix, v T) {
+		t.Set(p, v)
+		restored[p] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.Subscribe(c.writer(family, restored)), nil
+}
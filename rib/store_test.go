@@ -0,0 +1,207 @@
+package rib
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStoreKey struct {
+	family corebgp.MPExtensions
+	prefix netip.Prefix
+}
+
+// memStore is an in-memory Store.
+type memStore struct {
+	mu      sync.Mutex
+	buckets map[string]map[memStoreKey][]byte
+	putErr  error
+}
+
+func newMemStore() *memStore {
+	return &memStore{buckets: make(map[string]map[memStoreKey][]byte)}
+}
+
+func (m *memStore) Put(bucket string, family corebgp.MPExtensions,
+	p netip.Prefix, v []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.putErr != nil {
+		return m.putErr
+	}
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[memStoreKey][]byte)
+	}
+	m.buckets[bucket][memStoreKey{family, p}] = append([]byte(nil), v...)
+	return nil
+}
+
+func (m *memStore) Delete(bucket string, family corebgp.MPExtensions,
+	p netip.Prefix) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets[bucket], memStoreKey{family, p})
+	return nil
+}
+
+func (m *memStore) Walk(bucket string, fn func(family corebgp.MPExtensions,
+	p netip.Prefix, v []byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.buckets[bucket] {
+		if err := fn(k.family, k.prefix, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memStore) Clear(bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets, bucket)
+	return nil
+}
+
+func (m *memStore) get(bucket string, family corebgp.MPExtensions,
+	p netip.Prefix) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.buckets[bucket][memStoreKey{family, p}]
+	return v, ok
+}
+
+func TestPersist(t *testing.T) {
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	v6 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV6, SAFI: corebgp.SAFI_UNICAST}
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	p3 := netip.MustParsePrefix("2001:db8::/32")
+
+	s := newMemStore()
+	assert.NoError(t, s.Put("peer", v4, p1, []byte{1}))
+	assert.NoError(t, s.Put("peer", v4, p2, []byte{2}))
+	assert.NoError(t, s.Put("peer", v6, p3, []byte{3}))
+	assert.NoError(t, s.Put("other", v4, p1, []byte{4}))
+
+	table := &Table[[]byte]{}
+	table.Set(p2, []byte{5})
+	sub, err := Persist[[]byte](table, v4, StoreConfig[[]byte]{
+		Store:  s,
+		Bucket: "peer",
+		Codec:  BytesCodec{},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sub.Close()
+	assert.Equal(t, 2, table.Len())
+	v, _ := table.Get(p1)
+	assert.Equal(t, []byte{1}, v)
+	v, _ = table.Get(p2)
+	assert.Equal(t, []byte{5}, v, "existing routes must not be replaced")
+
+	// changes are written through
+	table.Delete(p1)
+	assert.Eventually(t, func() bool {
+		_, ok := s.get("peer", v4, p1)
+		v, _ := s.get("peer", v4, p2)
+		return !ok && v[0] == 5
+	}, time.Second, time.Millisecond)
+
+	// errors are surfaced via OnError
+	errFailed := errors.New("failed")
+	s.mu.Lock()
+	s.putErr = errFailed
+	s.mu.Unlock()
+	errCh := make(chan error, 1)
+	table = &Table[[]byte]{}
+	sub, err = Persist[[]byte](table, v6, StoreConfig[[]byte]{
+		Store:  s,
+		Bucket: "peer",
+		Codec:  BytesCodec{},
+		OnError: func(err error) {
+			errCh <- err
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sub.Close()
+	assert.Equal(t, 1, table.Len())
+	table.Set(p3, []byte{6})
+	select {
+	case err = <-errCh:
+		assert.ErrorIs(t, err, errFailed)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+}
+
+func TestGracefulRestart_Persist(t *testing.T) {
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	v6 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV6, SAFI: corebgp.SAFI_UNICAST}
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	p3 := netip.MustParsePrefix("2001:db8::/32")
+
+	s := newMemStore()
+	attrs := NewAttrTable()
+	c := StoreConfig[*Attrs]{
+		Store:  s,
+		Bucket: "peer",
+		Codec:  AttrsCodec{Table: attrs},
+	}
+
+	// populate the store prior to a "restart"
+	g := NewGracefulRestart[*Attrs](nil)
+	assert.NoError(t, g.Persist(c, 0))
+	assert.Error(t, g.Persist(c, 0))
+	g.Set(v4, p1, attrs.Intern([]byte{1}))
+	g.Set(v4, p2, attrs.Intern([]byte{2}))
+	g.Set(v6, p3, attrs.Intern([]byte{3}))
+	assert.Eventually(t, func() bool {
+		_, ok := s.get("peer", v6, p3)
+		return ok
+	}, time.Second, time.Millisecond)
+
+	var r grEventRecorder
+	attrs = NewAttrTable()
+	c.Codec = AttrsCodec{Table: attrs}
+	g = NewGracefulRestart[*Attrs](r.observe)
+	assert.NoError(t, g.Persist(c, time.Hour))
+	assert.ElementsMatch(t, []GracefulRestartEvent{
+		{Type: GracefulRestartRestored, Family: v4, Routes: 2},
+		{Type: GracefulRestartRestored, Family: v6, Routes: 1},
+	}, r.get())
+	assert.Equal(t, 2, g.Stale(v4))
+	assert.Equal(t, 1, g.Stale(v6))
+	v, ok := g.Table(v4).Get(p1)
+	if assert.True(t, ok) {
+		assert.Equal(t, []byte{1}, v.Bytes())
+	}
+	assert.Equal(t, 3, attrs.Len())
+
+	// the session is re-established, p1 is refreshed and the remaining stale
+	// routes are removed upon End-of-RIB, from the store as well
+	g.SessionUp(&corebgp.GracefulRestart{
+		Families: []corebgp.GracefulRestartFamily{
+			{AFI: v4.AFI, SAFI: v4.SAFI, ForwardingPreserved: true},
+		},
+	}, 0)
+	g.Set(v4, p1, attrs.Intern([]byte{4}))
+	g.EndOfRIB(v4)
+	assert.Equal(t, 1, g.Table(v4).Len())
+	assert.Equal(t, 0, g.Table(v6).Len())
+	assert.Eventually(t, func() bool {
+		v, _ := s.get("peer", v4, p1)
+		_, ok2 := s.get("peer", v4, p2)
+		_, ok3 := s.get("peer", v6, p3)
+		return len(v) == 1 && v[0] == 4 && !ok2 && !ok3
+	}, time.Second, time.Millisecond)
+}
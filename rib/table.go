@@ -0,0 +1,226 @@
+package rib
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// EventType is the type of an Event.
+type EventType uint8
+
+const (
+	// EventAdd indicates a prefix was added to a Table.
+	EventAdd EventType = iota
+	// EventUpdate indicates the value of a prefix in a Table was replaced.
+	EventUpdate
+	// EventWithdraw indicates a prefix was removed from a Table.
+	EventWithdraw
+	// EventEndOfSnapshot follows the EventAdd events making up the initial
+	// snapshot of a Table delivered to a new Subscription. Its Prefix and
+	// Value are the zero value.
+	EventEndOfSnapshot
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventAdd:
+		return "add"
+	case EventUpdate:
+		return "update"
+	case EventWithdraw:
+		return "withdraw"
+	case EventEndOfSnapshot:
+		return "end-of-snapshot"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a Table.
+type Event[T any] struct {
+	Type   EventType
+	Prefix netip.Prefix
+	// Value is the new value for EventAdd and EventUpdate, and the removed
+	// value for EventWithdraw.
+	Value T
+}
+
+// Table is a set of prefixes and their values, e.g. the routes of an
+// Adj-RIB-In or Loc-RIB, that delivers changes to subscribers, e.g. a FIB
+// writer or an exporter. It is backed by a Trie and is safe for concurrent
+// use.
+type Table[T any] struct {
+	mu   sync.Mutex
+	trie Trie[T]
+	subs map[*Subscription[T]]struct{}
+}
+
+// Set sets the value of p to v. p is masked prior to insertion. Invalid
+// prefixes are ignored.
+func (t *Table[T]) Set(p netip.Prefix, v T) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	typ := EventAdd
+	if _, exists := t.trie.Get(p); exists {
+		typ = EventUpdate
+	}
+	t.trie.Insert(p, v)
+	t.publishLocked(Event[T]{Type: typ, Prefix: p, Value: v})
+}
+
+// Delete removes p, returning true if it was present.
+func (t *Table[T]) Delete(p netip.Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	p = p.Masked()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, exists := t.trie.Get(p)
+	if !exists {
+		return false
+	}
+	t.trie.Delete(p)
+	t.publishLocked(Event[T]{Type: EventWithdraw, Prefix: p, Value: v})
+	return true
+}
+
+// Get returns the value of p and true if p is present.
+func (t *Table[T]) Get(p netip.Prefix) (T, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Get(p)
+}
+
+// Lookup returns the longest prefix containing addr, along with its value,
+// and true if one exists.
+func (t *Table[T]) Lookup(addr netip.Addr) (netip.Prefix, T, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Lookup(addr)
+}
+
+// Len returns the number of prefixes in t.
+func (t *Table[T]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Len()
+}
+
+// Walk calls fn for each prefix in t, in prefix order, until fn returns
+// false. t must not be modified by fn.
+func (t *Table[T]) Walk(fn func(prefix netip.Prefix, v T) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Walk(fn)
+}
+
+func (t *Table[T]) publishLocked(e Event[T]) {
+	for s := range t.subs {
+		s.enqueue(e)
+	}
+}
+
+// Subscribe returns a Subscription delivering changes to t to fn. fn is
+// first called with an EventAdd for each prefix in t, in prefix order,
+// followed by an EventEndOfSnapshot, and then with each subsequent change in
+// the order it was made. fn is called from a dedicated goroutine, one event at
+// a time, so a slow subscriber does not block changes to t. Events are
+// queued without bound until fn returns.
+//
+// A channel may be used in place of a callback, e.g.
+//
+//	ch := make(chan Event[T], 1024)
+//	sub := t.Subscribe(func(e Event[T]) { ch <- e })
+func (t *Table[T]) Subscribe(fn func(e Event[T])) *Subscription[T] {
+	s := &Subscription[T]{
+		table: t,
+		done:  make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	t.mu.Lock()
+	t.trie.Walk(func(p netip.Prefix, v T) bool {
+		s.queue = append(s.queue, Event[T]{Type: EventAdd, Prefix: p, Value: v})
+		return true
+	})
+	s.queue = append(s.queue, Event[T]{Type: EventEndOfSnapshot})
+	if t.subs == nil {
+		t.subs = make(map[*Subscription[T]]struct{})
+	}
+	t.subs[s] = struct{}{}
+	t.mu.Unlock()
+	go s.run(fn)
+	return s
+}
+
+// Subscription is a subscription to the changes of a Table, see
+// Table.Subscribe.
+type Subscription[T any] struct {
+	table *Table[T]
+	done  chan struct{}
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Event[T]
+	closed bool
+}
+
+func (s *Subscription[T]) enqueue(e Event[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, e)
+	s.cond.Signal()
+}
+
+func (s *Subscription[T]) run(fn func(e Event[T])) {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		batch := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+		for _, e := range batch {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			fn(e)
+		}
+	}
+}
+
+// Close ends the subscription, discarding undelivered events. It may be
+// called from the subscription's callback. The callback is not called once
+// Done is closed.
+func (s *Subscription[T]) Close() {
+	s.table.mu.Lock()
+	delete(s.table.subs, s)
+	s.table.mu.Unlock()
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.cond.Signal()
+	s.mu.Unlock()
+}
+
+// Done returns a channel that is closed once the subscription's callback
+// will no longer be called, following Close.
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,82 @@
+package rib
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable_Subscribe(t *testing.T) {
+	var table Table[int]
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("198.51.100.0/24")
+	p3 := netip.MustParsePrefix("2001:db8::/32")
+	table.Set(p2, 2)
+	table.Set(p1, 1)
+
+	ch := make(chan Event[int], 16)
+	sub := table.Subscribe(func(e Event[int]) {
+		ch <- e
+	})
+	table.Set(p1, 10)
+	table.Set(p3, 3)
+	assert.True(t, table.Delete(p2))
+	assert.False(t, table.Delete(p2))
+
+	want := []Event[int]{
+		{Type: EventAdd, Prefix: p1, Value: 1},
+		{Type: EventAdd, Prefix: p2, Value: 2},
+		{Type: EventEndOfSnapshot},
+		{Type: EventUpdate, Prefix: p1, Value: 10},
+		{Type: EventAdd, Prefix: p3, Value: 3},
+		{Type: EventWithdraw, Prefix: p2, Value: 2},
+	}
+	for _, w := range want {
+		select {
+		case got := <-ch:
+			assert.Equal(t, w, got)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timeout waiting for %v", w)
+		}
+	}
+
+	sub.Close()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for subscription to close")
+	}
+	table.Set(p2, 2)
+	assert.Empty(t, ch)
+	assert.Equal(t, 3, table.Len())
+	v, ok := table.Get(p1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	p, v, ok := table.Lookup(netip.MustParseAddr("2001:db8::1"))
+	assert.True(t, ok)
+	assert.Equal(t, p3, p)
+	assert.Equal(t, 3, v)
+}
+
+func TestSubscription_CloseFromCallback(t *testing.T) {
+	var table Table[int]
+	table.Set(netip.MustParsePrefix("192.0.2.0/24"), 1)
+	table.Set(netip.MustParsePrefix("198.51.100.0/24"), 2)
+	calls := 0
+	var sub *Subscription[int]
+	ready := make(chan struct{})
+	sub = table.Subscribe(func(e Event[int]) {
+		<-ready
+		calls++
+		sub.Close()
+	})
+	close(ready)
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for subscription to close")
+	}
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,287 @@
+package rib
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// Trie is a path-compressed binary radix trie mapping prefixes to values of
+// type T. It supports exact match, longest-prefix-match, covering (less
+// specific) and covered (more specific) queries, and ordered iteration.
+// IPv4 and IPv6 prefixes are held separately, IPv4-mapped IPv6 prefixes are
+// treated as IPv6. The zero value is an empty Trie. Trie is not safe for
+// concurrent use.
+//
+// Iteration is in prefix order: by address, with a less specific prefix
+// preceding the more specific prefixes it covers. IPv4 prefixes precede IPv6
+// prefixes.
+type Trie[T any] struct {
+	v4, v6 *trieNode[T]
+	len    int
+}
+
+type trieNode[T any] struct {
+	prefix netip.Prefix
+	value  T
+	set    bool
+	child  [2]*trieNode[T]
+}
+
+func (t *Trie[T]) root(p netip.Prefix) **trieNode[T] {
+	if p.Addr().Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// addrBit returns bit i of a, where bit 0 is the most significant.
+func addrBit(a netip.Addr, i int) int {
+	if a.Is4() {
+		b := a.As4()
+		return int(b[i/8]>>(7-i%8)) & 1
+	}
+	b := a.As16()
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// commonBits returns the number of leading bits a and b have in common, up to
+// limit.
+func commonBits(a, b netip.Addr, limit int) int {
+	var x, y []byte
+	if a.Is4() {
+		a4, b4 := a.As4(), b.As4()
+		x, y = a4[:], b4[:]
+	} else {
+		a16, b16 := a.As16(), b.As16()
+		x, y = a16[:], b16[:]
+	}
+	n := 0
+	for i := range x {
+		if x[i] != y[i] {
+			n += bits.LeadingZeros8(x[i] ^ y[i])
+			break
+		}
+		n += 8
+	}
+	return min(n, limit)
+}
+
+// Len returns the number of prefixes in t.
+func (t *Trie[T]) Len() int {
+	return t.len
+}
+
+// Insert sets the value of p to v, replacing any existing value. p is masked
+// prior to insertion. Invalid prefixes are ignored.
+func (t *Trie[T]) Insert(p netip.Prefix, v T) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	n := t.root(p)
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &trieNode[T]{prefix: p, value: v, set: true}
+			t.len++
+			return
+		}
+		common := commonBits(cur.prefix.Addr(), p.Addr(),
+			min(cur.prefix.Bits(), p.Bits()))
+		switch {
+		case common == cur.prefix.Bits() && common == p.Bits():
+			if !cur.set {
+				t.len++
+			}
+			cur.value = v
+			cur.set = true
+			return
+		case common == cur.prefix.Bits():
+			// cur covers p
+			n = &cur.child[addrBit(p.Addr(), common)]
+			continue
+		case common == p.Bits():
+			// p covers cur
+			nn := &trieNode[T]{prefix: p, value: v, set: true}
+			nn.child[addrBit(cur.prefix.Addr(), common)] = cur
+			*n = nn
+		default:
+			// p and cur diverge, join them with a glue node
+			glue := &trieNode[T]{
+				prefix: netip.PrefixFrom(p.Addr(), common).Masked(),
+			}
+			glue.child[addrBit(cur.prefix.Addr(), common)] = cur
+			glue.child[addrBit(p.Addr(), common)] = &trieNode[T]{
+				prefix: p,
+				value:  v,
+				set:    true,
+			}
+			*n = glue
+		}
+		t.len++
+		return
+	}
+}
+
+// find returns the slots leading to the node for p, the last of which holds
+// the node for p if it exists.
+func (t *Trie[T]) find(p netip.Prefix) []**trieNode[T] {
+	slots := make([]**trieNode[T], 0, 8)
+	n := t.root(p)
+	for *n != nil {
+		cur := *n
+		if cur.prefix.Bits() > p.Bits() || !cur.prefix.Contains(p.Addr()) {
+			break
+		}
+		slots = append(slots, n)
+		if cur.prefix.Bits() == p.Bits() {
+			break
+		}
+		n = &cur.child[addrBit(p.Addr(), cur.prefix.Bits())]
+	}
+	return slots
+}
+
+// Get returns the value of p and true if p is present.
+func (t *Trie[T]) Get(p netip.Prefix) (T, bool) {
+	var zero T
+	if !p.IsValid() {
+		return zero, false
+	}
+	p = p.Masked()
+	slots := t.find(p)
+	if len(slots) == 0 {
+		return zero, false
+	}
+	n := *slots[len(slots)-1]
+	if n.prefix != p || !n.set {
+		return zero, false
+	}
+	return n.value, true
+}
+
+// Delete removes p, returning true if it was present.
+func (t *Trie[T]) Delete(p netip.Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	p = p.Masked()
+	slots := t.find(p)
+	if len(slots) == 0 {
+		return false
+	}
+	n := *slots[len(slots)-1]
+	if n.prefix != p || !n.set {
+		return false
+	}
+	var zero T
+	n.value = zero
+	n.set = false
+	t.len--
+	compact(slots[len(slots)-1])
+	if len(slots) > 1 {
+		// removing n may leave its parent as a glue node with a single child
+		compact(slots[len(slots)-2])
+	}
+	return true
+}
+
+// compact removes the node in slot if it holds no value and has fewer than
+// two children.
+func compact[T any](slot **trieNode[T]) {
+	n := *slot
+	if n == nil || n.set {
+		return
+	}
+	switch {
+	case n.child[0] == nil:
+		*slot = n.child[1]
+	case n.child[1] == nil:
+		*slot = n.child[0]
+	}
+}
+
+// LookupPrefix returns the longest prefix in t covering p, including p
+// itself, along with its value, and true if one exists.
+func (t *Trie[T]) LookupPrefix(p netip.Prefix) (netip.Prefix, T, bool) {
+	var (
+		best  netip.Prefix
+		value T
+		found bool
+	)
+	t.WalkCovering(p, func(prefix netip.Prefix, v T) bool {
+		best, value, found = prefix, v, true
+		return true
+	})
+	return best, value, found
+}
+
+// Lookup returns the longest prefix in t containing addr, along with its
+// value, and true if one exists.
+func (t *Trie[T]) Lookup(addr netip.Addr) (netip.Prefix, T, bool) {
+	return t.LookupPrefix(netip.PrefixFrom(addr.WithZone(""), addr.BitLen()))
+}
+
+// WalkCovering calls fn for each prefix in t covering p, including p itself,
+// from least to most specific, until fn returns false.
+func (t *Trie[T]) WalkCovering(p netip.Prefix,
+	fn func(prefix netip.Prefix, v T) bool) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	n := *t.root(p)
+	for n != nil && n.prefix.Bits() <= p.Bits() &&
+		n.prefix.Contains(p.Addr()) {
+		if n.set && !fn(n.prefix, n.value) {
+			return
+		}
+		if n.prefix.Bits() == p.Bits() {
+			return
+		}
+		n = n.child[addrBit(p.Addr(), n.prefix.Bits())]
+	}
+}
+
+// WalkCovered calls fn for each prefix in t covered by p, including p
+// itself, in prefix order, until fn returns false.
+func (t *Trie[T]) WalkCovered(p netip.Prefix,
+	fn func(prefix netip.Prefix, v T) bool) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	n := *t.root(p)
+	for n != nil {
+		if n.prefix.Bits() >= p.Bits() {
+			if p.Contains(n.prefix.Addr()) {
+				walk(n, fn)
+			}
+			return
+		}
+		if !n.prefix.Contains(p.Addr()) {
+			return
+		}
+		n = n.child[addrBit(p.Addr(), n.prefix.Bits())]
+	}
+}
+
+// Walk calls fn for each prefix in t, in prefix order, until fn returns
+// false.
+func (t *Trie[T]) Walk(fn func(prefix netip.Prefix, v T) bool) {
+	if walk(t.v4, fn) {
+		walk(t.v6, fn)
+	}
+}
+
+// walk calls fn for each prefix in the subtree rooted at n in prefix order,
+// returning false if fn returned false.
+func walk[T any](n *trieNode[T], fn func(prefix netip.Prefix, v T) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.set && !fn(n.prefix, n.value) {
+		return false
+	}
+	return walk(n.child[0], fn) && walk(n.child[1], fn)
+}
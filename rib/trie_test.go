@@ -0,0 +1,150 @@
+package rib
+
+import (
+	"math/rand"
+	"net/netip"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collect[T any](walk func(fn func(netip.Prefix, T) bool)) []netip.Prefix {
+	out := make([]netip.Prefix, 0)
+	walk(func(p netip.Prefix, _ T) bool {
+		out = append(out, p)
+		return true
+	})
+	return out
+}
+
+func mustPrefixes(s ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(s))
+	for _, v := range s {
+		out = append(out, netip.MustParsePrefix(v))
+	}
+	return out
+}
+
+func TestTrie(t *testing.T) {
+	var trie Trie[string]
+	for _, p := range []string{
+		"192.0.2.0/24", "0.0.0.0/0", "192.0.2.128/25", "192.0.2.0/26",
+		"198.51.100.0/24", "2001:db8::/32", "2001:db8:1::/48",
+	} {
+		trie.Insert(netip.MustParsePrefix(p), p)
+	}
+	// unmasked prefixes are masked
+	trie.Insert(netip.MustParsePrefix("192.0.2.1/24"), "replaced")
+	assert.Equal(t, 7, trie.Len())
+
+	v, ok := trie.Get(netip.MustParsePrefix("192.0.2.0/24"))
+	assert.True(t, ok)
+	assert.Equal(t, "replaced", v)
+	_, ok = trie.Get(netip.MustParsePrefix("192.0.2.0/25"))
+	assert.False(t, ok, "glue node must not match")
+
+	p, v, ok := trie.Lookup(netip.MustParseAddr("192.0.2.5"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.0/26"), p)
+	assert.Equal(t, "192.0.2.0/26", v)
+	p, _, ok = trie.Lookup(netip.MustParseAddr("192.0.2.100"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), p)
+	p, _, ok = trie.Lookup(netip.MustParseAddr("203.0.113.1"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("0.0.0.0/0"), p)
+	_, _, ok = trie.Lookup(netip.MustParseAddr("2001:db9::1"))
+	assert.False(t, ok)
+	p, _, ok = trie.LookupPrefix(netip.MustParsePrefix("2001:db8:1:2::/64"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParsePrefix("2001:db8:1::/48"), p)
+
+	assert.Equal(t, mustPrefixes("0.0.0.0/0", "192.0.2.0/24",
+		"192.0.2.128/25"), collect(func(fn func(netip.Prefix, string) bool) {
+		trie.WalkCovering(netip.MustParsePrefix("192.0.2.129/32"), fn)
+	}))
+	assert.Equal(t, mustPrefixes("192.0.2.0/24", "192.0.2.0/26",
+		"192.0.2.128/25"), collect(func(fn func(netip.Prefix, string) bool) {
+		trie.WalkCovered(netip.MustParsePrefix("192.0.2.0/23"), fn)
+	}))
+	assert.Equal(t, mustPrefixes("0.0.0.0/0", "192.0.2.0/24", "192.0.2.0/26",
+		"192.0.2.128/25", "198.51.100.0/24", "2001:db8::/32",
+		"2001:db8:1::/48"), collect(trie.Walk))
+
+	assert.True(t, trie.Delete(netip.MustParsePrefix("192.0.2.0/24")))
+	assert.False(t, trie.Delete(netip.MustParsePrefix("192.0.2.0/24")))
+	assert.False(t, trie.Delete(netip.MustParsePrefix("192.0.2.0/25")))
+	p, _, _ = trie.Lookup(netip.MustParseAddr("192.0.2.100"))
+	assert.Equal(t, netip.MustParsePrefix("0.0.0.0/0"), p)
+	assert.Equal(t, 6, trie.Len())
+}
+
+func TestTrie_random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randPrefix := func() netip.Prefix {
+		// a narrow address space exercises overlapping prefixes
+		a := netip.AddrFrom4([4]byte{10, uint8(r.Intn(4)),
+			uint8(r.Intn(256)), 0})
+		return netip.PrefixFrom(a, 8+r.Intn(17)).Masked()
+	}
+	var trie Trie[int]
+	want := make(map[netip.Prefix]int)
+	for i := 0; i < 5000; i++ {
+		p := randPrefix()
+		if r.Intn(3) == 0 {
+			_, exists := want[p]
+			assert.Equal(t, exists, trie.Delete(p))
+			delete(want, p)
+		} else {
+			trie.Insert(p, i)
+			want[p] = i
+		}
+	}
+	assert.Equal(t, len(want), trie.Len())
+
+	wantOrder := make([]netip.Prefix, 0, len(want))
+	for p, v := range want {
+		got, ok := trie.Get(p)
+		assert.True(t, ok)
+		assert.Equal(t, v, got)
+		wantOrder = append(wantOrder, p)
+	}
+	sort.Slice(wantOrder, func(i, j int) bool {
+		if c := wantOrder[i].Addr().Compare(wantOrder[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return wantOrder[i].Bits() < wantOrder[j].Bits()
+	})
+	assert.Equal(t, wantOrder, collect(trie.Walk))
+
+	for i := 0; i < 1000; i++ {
+		addr := netip.AddrFrom4([4]byte{10, uint8(r.Intn(4)),
+			uint8(r.Intn(256)), uint8(r.Intn(256))})
+		var best netip.Prefix
+		for p := range want {
+			if p.Contains(addr) && (!best.IsValid() || p.Bits() > best.Bits()) {
+				best = p
+			}
+		}
+		got, _, ok := trie.Lookup(addr)
+		assert.Equal(t, best.IsValid(), ok)
+		assert.Equal(t, best, got)
+	}
+}
+
+func BenchmarkTrie_Lookup(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	var trie Trie[int]
+	for i := 0; i < 100000; i++ {
+		a := netip.AddrFrom4([4]byte{uint8(r.Intn(224)), uint8(r.Intn(256)),
+			uint8(r.Intn(256)), 0})
+		trie.Insert(netip.PrefixFrom(a, 16+r.Intn(9)), i)
+	}
+	addr := netip.MustParseAddr("192.0.2.5")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Lookup(addr)
+	}
+}
@@ -0,0 +1,127 @@
+// Package ribbolt provides a rib.Store backed by a bbolt database, allowing
+// the routes of corebgp RIB components to be persisted across process
+// restarts.
+package ribbolt
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/rib"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a rib.Store backed by a bbolt database. Each bucket of the Store
+// is a top-level bbolt bucket of the same name, holding routes keyed by
+// family and prefix. Writes are coalesced across goroutines via
+// bolt.DB.Batch.
+type Store struct {
+	db *bolt.DB
+}
+
+var _ rib.Store = (*Store)(nil)
+
+// New returns a Store persisting routes in db.
+func New(db *bolt.DB) *Store {
+	return &Store{db: db}
+}
+
+// Open opens the bbolt database at path, creating it if it does not exist,
+// and returns a Store persisting routes in it. options may be nil, see
+// bolt.Open.
+func Open(path string, options *bolt.Options) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, options)
+	if err != nil {
+		return nil, err
+	}
+	return New(db), nil
+}
+
+// DB returns the bbolt database of s.
+func (s *Store) DB() *bolt.DB {
+	return s.db
+}
+
+// Close closes the bbolt database of s.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// encodeKey returns the key of p in family. The key is the AFI, SAFI, the
+// address of p, and its length, so that the routes of a family are
+// contiguous.
+func encodeKey(family corebgp.MPExtensions, p netip.Prefix) []byte {
+	addr := p.Addr().AsSlice()
+	k := make([]byte, 0, 4+len(addr))
+	k = append(k, byte(family.AFI>>8), byte(family.AFI), family.SAFI)
+	k = append(k, addr...)
+	return append(k, byte(p.Bits()))
+}
+
+func decodeKey(k []byte) (corebgp.MPExtensions, netip.Prefix, error) {
+	if len(k) != 8 && len(k) != 20 {
+		return corebgp.MPExtensions{}, netip.Prefix{},
+			errors.New("invalid key length")
+	}
+	family := corebgp.MPExtensions{
+		AFI:  uint16(k[0])<<8 | uint16(k[1]),
+		SAFI: k[2],
+	}
+	addr, _ := netip.AddrFromSlice(k[3 : len(k)-1])
+	p, err := addr.Prefix(int(k[len(k)-1]))
+	return family, p, err
+}
+
+// Put implements rib.Store.
+func (s *Store) Put(bucket string, family corebgp.MPExtensions,
+	p netip.Prefix, v []byte) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put(encodeKey(family, p.Masked()), v)
+	})
+}
+
+// Delete implements rib.Store.
+func (s *Store) Delete(bucket string, family corebgp.MPExtensions,
+	p netip.Prefix) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete(encodeKey(family, p.Masked()))
+	})
+}
+
+// Walk implements rib.Store.
+func (s *Store) Walk(bucket string, fn func(family corebgp.MPExtensions,
+	p netip.Prefix, v []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			family, p, err := decodeKey(k)
+			if err != nil {
+				return err
+			}
+			return fn(family, p, v)
+		})
+	})
+}
+
+// Clear implements rib.Store.
+func (s *Store) Clear(bucket string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucket))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
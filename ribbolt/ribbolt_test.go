@@ -0,0 +1,109 @@
+package ribbolt
+
+import (
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/rib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "rib.db"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	v6 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV6, SAFI: corebgp.SAFI_UNICAST}
+	p1 := netip.MustParsePrefix("192.0.2.0/24")
+	p2 := netip.MustParsePrefix("2001:db8::/32")
+
+	assert.NoError(t, s.Put("peer", v4, p1, []byte{1}))
+	assert.NoError(t, s.Put("peer", v6, p2, []byte{2}))
+	assert.NoError(t, s.Put("peer", v6, p2, []byte{3}))
+	assert.NoError(t, s.Put("other", v4, p1, []byte{4}))
+
+	type route struct {
+		family corebgp.MPExtensions
+		prefix netip.Prefix
+		v      []byte
+	}
+	walk := func(bucket string) []route {
+		routes := make([]route, 0)
+		err := s.Walk(bucket, func(family corebgp.MPExtensions,
+			p netip.Prefix, v []byte) error {
+			routes = append(routes, route{family, p, append([]byte{}, v...)})
+			return nil
+		})
+		assert.NoError(t, err)
+		return routes
+	}
+	assert.Equal(t, []route{
+		{v4, p1, []byte{1}},
+		{v6, p2, []byte{3}},
+	}, walk("peer"))
+
+	errStop := errors.New("stop")
+	err = s.Walk("peer", func(corebgp.MPExtensions, netip.Prefix,
+		[]byte) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	assert.NoError(t, s.Delete("peer", v4, p1))
+	assert.NoError(t, s.Delete("missing", v4, p1))
+	assert.Equal(t, []route{{v6, p2, []byte{3}}}, walk("peer"))
+
+	assert.NoError(t, s.Clear("peer"))
+	assert.NoError(t, s.Clear("peer"))
+	assert.Empty(t, walk("peer"))
+	assert.Len(t, walk("other"), 1)
+}
+
+func TestStore_GracefulRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rib.db")
+	s, err := Open(path, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	v4 := corebgp.MPExtensions{AFI: corebgp.AFI_IPV4, SAFI: corebgp.SAFI_UNICAST}
+	p := netip.MustParsePrefix("192.0.2.0/24")
+	c := rib.StoreConfig[[]byte]{
+		Store:  s,
+		Bucket: "192.0.2.1",
+		Codec:  rib.BytesCodec{},
+	}
+	g := rib.NewGracefulRestart[[]byte](nil)
+	assert.NoError(t, g.Persist(c, 0))
+	g.Set(v4, p, []byte{1})
+	assert.Eventually(t, func() bool {
+		n := 0
+		s.Walk(c.Bucket, func(corebgp.MPExtensions, netip.Prefix,
+			[]byte) error {
+			n++
+			return nil
+		})
+		return n == 1
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, s.Close())
+
+	// restart
+	s, err = Open(path, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	c.Store = s
+	g = rib.NewGracefulRestart[[]byte](nil)
+	assert.NoError(t, g.Persist(c, 0))
+	v, ok := g.Table(v4).Get(p)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1}, v)
+	assert.Equal(t, 1, g.Stale(v4))
+}
@@ -0,0 +1,137 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// AppendPathAttr appends a path attribute with flags, code, and data to b.
+// The Extended Length flag is set or cleared according to the length of data.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.3
+func AppendPathAttr(b []byte, flags PathAttrFlags, code uint8,
+	data []byte) []byte {
+	if len(data) > 255 {
+		b = append(b, uint8(flags)|0x10, code)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	} else {
+		b = append(b, uint8(flags)&^0x10, code, uint8(len(data)))
+	}
+	return append(b, data...)
+}
+
+// Encode returns the ORIGINATOR_ID attribute data for o.
+func (o OriginatorIDPathAttr) Encode() []byte {
+	a := netip.Addr(o).As4()
+	return a[:]
+}
+
+// Encode returns the CLUSTER_LIST attribute data for c.
+func (c ClusterListPathAttr) Encode() []byte {
+	b := make([]byte, 0, len(c)*4)
+	for _, id := range c {
+		a := id.As4()
+		b = append(b, a[:]...)
+	}
+	return b
+}
+
+// ReflectorPeerType is the type of a peer with respect to a RouteReflector.
+type ReflectorPeerType uint8
+
+const (
+	// ReflectorPeerExternal is an external (eBGP) peer.
+	ReflectorPeerExternal ReflectorPeerType = iota
+	// ReflectorPeerNonClient is an internal peer that is not a client of the
+	// route reflector.
+	ReflectorPeerNonClient
+	// ReflectorPeerClient is an internal peer that is a client of the route
+	// reflector.
+	ReflectorPeerClient
+)
+
+// RouteReflector implements the procedures of a BGP route reflector. corebgp
+// does not maintain a RIB, RouteReflector is applied by Plugins when
+// propagating routes between peers.
+//
+// https://www.rfc-editor.org/rfc/rfc4456
+type RouteReflector struct {
+	// RouterID is the BGP Identifier of the local system.
+	RouterID netip.Addr
+	// ClusterID is the CLUSTER_ID of the route reflector. If it is the zero
+	// value RouterID is used.
+	ClusterID netip.Addr
+}
+
+func (r RouteReflector) clusterID() netip.Addr {
+	if r.ClusterID.IsValid() {
+		return r.ClusterID
+	}
+	return r.RouterID
+}
+
+// ShouldAdvertise returns true if a route received from a peer of type from
+// should be advertised to a peer of type to. Routes should never be
+// advertised back to the peer they were received from.
+//
+// https://www.rfc-editor.org/rfc/rfc4456#section-6
+//
+//  1. A Route from a Non-Client IBGP peer: Reflect to all the Clients.
+//  2. A Route from a Client: Reflect to all the Non-Client peers and also to
+//     the Client peers. (Hence the Client peers are not required to be fully
+//     meshed.)
+//  3. Route from an EBGP peer: Send to all the Client and Non-Client Peers.
+func (r RouteReflector) ShouldAdvertise(from, to ReflectorPeerType) bool {
+	if from == ReflectorPeerNonClient && to == ReflectorPeerNonClient {
+		return false
+	}
+	return true
+}
+
+// Loop returns true if a route received from an internal peer with
+// originatorID and clusterList should be ignored.
+//
+// https://www.rfc-editor.org/rfc/rfc4456#section-8
+// A router that recognizes the ORIGINATOR_ID attribute SHOULD ignore a route
+// received with its BGP Identifier as the ORIGINATOR_ID.
+//
+// [...] If the local CLUSTER_ID is found in the CLUSTER_LIST, the
+// advertisement received SHOULD be ignored.
+func (r RouteReflector) Loop(originatorID OriginatorIDPathAttr,
+	clusterList ClusterListPathAttr) bool {
+	if netip.Addr(originatorID) == r.RouterID {
+		return true
+	}
+	id := r.clusterID()
+	for _, c := range clusterList {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Reflect returns the ORIGINATOR_ID and CLUSTER_LIST attributes for a route
+// reflected to an internal peer. originatorID and clusterList are those of
+// the route as received, either may be the zero value if absent. fromRouterID
+// is the BGP Identifier of the peer the route was received from.
+//
+// https://www.rfc-editor.org/rfc/rfc4456#section-8
+// This attribute will be created by an RR in reflecting a route. This
+// attribute will carry the BGP Identifier of the originator of the route in
+// the local AS. A BGP speaker SHOULD NOT create an ORIGINATOR_ID attribute if
+// one already exists.
+//
+// [...] When an RR reflects a route, it MUST prepend the local CLUSTER_ID to
+// the CLUSTER_LIST. If the CLUSTER_LIST is empty, it MUST create a new one.
+func (r RouteReflector) Reflect(originatorID OriginatorIDPathAttr,
+	clusterList ClusterListPathAttr,
+	fromRouterID netip.Addr) (OriginatorIDPathAttr, ClusterListPathAttr) {
+	if !netip.Addr(originatorID).IsValid() {
+		originatorID = OriginatorIDPathAttr(fromRouterID)
+	}
+	c := make(ClusterListPathAttr, 0, len(clusterList)+1)
+	c = append(c, r.clusterID())
+	c = append(c, clusterList...)
+	return originatorID, c
+}
@@ -0,0 +1,39 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteReflector(t *testing.T) {
+	r := RouteReflector{RouterID: netip.MustParseAddr("192.0.2.1")}
+	client := netip.MustParseAddr("192.0.2.2")
+
+	assert.False(t, r.ShouldAdvertise(ReflectorPeerNonClient,
+		ReflectorPeerNonClient))
+	assert.True(t, r.ShouldAdvertise(ReflectorPeerNonClient,
+		ReflectorPeerClient))
+	assert.True(t, r.ShouldAdvertise(ReflectorPeerClient, ReflectorPeerClient))
+	assert.True(t, r.ShouldAdvertise(ReflectorPeerExternal,
+		ReflectorPeerNonClient))
+
+	o, c := r.Reflect(OriginatorIDPathAttr{}, nil, client)
+	assert.Equal(t, OriginatorIDPathAttr(client), o)
+	assert.Equal(t, ClusterListPathAttr{r.RouterID}, c)
+	assert.True(t, r.Loop(OriginatorIDPathAttr{}, c))
+	assert.True(t, r.Loop(OriginatorIDPathAttr(r.RouterID), nil))
+	assert.False(t, r.Loop(o, nil))
+
+	r.ClusterID = netip.MustParseAddr("198.51.100.1")
+	o, c = r.Reflect(o, c, netip.MustParseAddr("192.0.2.3"))
+	assert.Equal(t, OriginatorIDPathAttr(client), o)
+	assert.Equal(t, ClusterListPathAttr{r.ClusterID, r.RouterID}, c)
+
+	attrs := AppendPathAttr(nil, 0x80, PATH_ATTR_CLUSTER_LIST, c.Encode())
+	var decoded ClusterListPathAttr
+	assert.NoError(t, decoded.Decode(PathAttrFlags(attrs[0]), attrs[3:]))
+	assert.Equal(t, c, decoded)
+	assert.Equal(t, []byte{192, 0, 2, 2}, o.Encode())
+}
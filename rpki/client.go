@@ -0,0 +1,338 @@
+package rpki
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Default timing parameters, used until a cache provides its own via an End
+// of Data PDU.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-6
+const (
+	DefaultRefreshInterval = time.Second * 3600
+	DefaultRetryInterval   = time.Second * 600
+	DefaultExpireInterval  = time.Second * 7200
+)
+
+// ClientConfig is the configuration for a Client.
+type ClientConfig struct {
+	// Address is the host:port of the RTR cache.
+	Address string
+
+	// Dial is used to connect to the cache. If nil a TCP connection is
+	// dialed with a net.Dialer.
+	Dial corebgp.DialFunc
+
+	// OnUpdate is called with the new VRPSet each time the Client's VRPs are
+	// updated, e.g. to trigger revalidation of routes.
+	OnUpdate func(*VRPSet)
+}
+
+// Client is an RTR client that maintains the set of VRPs held by an RTR cache.
+//
+// https://www.rfc-editor.org/rfc/rfc8210
+type Client struct {
+	config ClientConfig
+	vrps   atomic.Pointer[VRPSet]
+
+	mu         sync.Mutex
+	version    uint8
+	sessionID  uint16
+	serial     uint32
+	hasSerial  bool
+	refresh    time.Duration
+	retry      time.Duration
+	expire     time.Duration
+	lastUpdate time.Time
+}
+
+// NewClient returns a new Client. Run must be called to connect to the cache.
+func NewClient(config ClientConfig) (*Client, error) {
+	if len(config.Address) == 0 {
+		return nil, errors.New("address must be non-empty")
+	}
+	if config.Dial == nil {
+		var d net.Dialer
+		config.Dial = d.DialContext
+	}
+	c := &Client{
+		config:  config,
+		version: rtrVersion1,
+		refresh: DefaultRefreshInterval,
+		retry:   DefaultRetryInterval,
+		expire:  DefaultExpireInterval,
+	}
+	c.vrps.Store(&VRPSet{})
+	return c, nil
+}
+
+// VRPs returns the current VRPSet, which is empty until the initial
+// synchronization with the cache completes.
+func (c *Client) VRPs() *VRPSet {
+	return c.vrps.Load()
+}
+
+// Validate validates prefix and origin against the current VRPSet, see
+// VRPSet.Validate.
+func (c *Client) Validate(prefix netip.Prefix, origin uint32) ValidationState {
+	return c.VRPs().Validate(prefix, origin)
+}
+
+// Run connects to the cache and keeps the Client's VRPs synchronized with it,
+// reconnecting after the retry interval upon failure. If the cache is
+// unreachable for longer than the expire interval the VRPs are discarded. Run
+// returns when ctx is done.
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		err := c.runSession(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var e *ErrorReport
+		if errors.As(err, &e) && e.Code == errCodeUnsupportedProtocolVer {
+			c.mu.Lock()
+			downgrade := c.version == rtrVersion1
+			c.version = rtrVersion0
+			c.mu.Unlock()
+			if downgrade {
+				// https://www.rfc-editor.org/rfc/rfc8210#section-7
+				// the router MAY use the Error Report PDU to negotiate down
+				continue
+			}
+		}
+		c.mu.Lock()
+		retry := c.retry
+		expired := !c.lastUpdate.IsZero() &&
+			time.Since(c.lastUpdate) > c.expire
+		c.mu.Unlock()
+		if expired {
+			c.setVRPs(&VRPSet{})
+		}
+		t := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) setVRPs(s *VRPSet) {
+	c.vrps.Store(s)
+	if c.config.OnUpdate != nil {
+		c.config.OnUpdate(s)
+	}
+}
+
+// runSession runs a single connection to the cache.
+func (c *Client) runSession(ctx context.Context) error {
+	conn, err := c.config.Dial(ctx, "tcp", c.config.Address)
+	if err != nil {
+		return err
+	}
+	doneCh := make(chan struct{})
+	defer func() {
+		close(doneCh)
+		conn.Close()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-doneCh:
+		}
+	}()
+
+	pduCh := make(chan pdu)
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			p, err := readPDU(conn)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			select {
+			case pduCh <- p:
+			case <-doneCh:
+				return
+			}
+		}
+	}()
+
+	c.mu.Lock()
+	version := c.version
+	query := encodeResetQuery(version)
+	if c.hasSerial {
+		query = encodeSerialQuery(version, c.sessionID, c.serial)
+	}
+	c.mu.Unlock()
+	_, err = conn.Write(query)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pending   *VRPSet // non-nil while receiving data
+		refreshCh <-chan time.Time
+	)
+	for {
+		var p pdu
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errCh:
+			return err
+		case <-refreshCh:
+			refreshCh = nil
+			err = c.sendSerialQuery(conn)
+			if err != nil {
+				return err
+			}
+			continue
+		case p = <-pduCh:
+		}
+
+		if p.typ == pduTypeErrorReport {
+			return decodeErrorReport(p)
+		}
+		if p.version != version {
+			// https://www.rfc-editor.org/rfc/rfc8210#section-7
+			// If a router receives a PDU with a version other than the
+			// negotiated one it MUST send an Unexpected Protocol Version
+			// error.
+			err = c.sendError(conn, version, errCodeUnexpectedProtocolVer, p,
+				"unexpected protocol version")
+			return fmt.Errorf("%w: %d", err, p.version)
+		}
+
+		switch p.typ {
+		case pduTypeSerialNotify:
+			if pending == nil {
+				err = c.sendSerialQuery(conn)
+				if err != nil {
+					return err
+				}
+			}
+		case pduTypeCacheResponse:
+			c.mu.Lock()
+			sameSession := c.hasSerial && c.sessionID == p.session
+			c.sessionID = p.session
+			c.mu.Unlock()
+			if sameSession {
+				pending = c.VRPs().clone()
+			} else {
+				pending = NewVRPSet(nil)
+			}
+		case pduTypeIPv4Prefix, pduTypeIPv6Prefix:
+			if pending == nil {
+				return c.sendError(conn, version, errCodeCorruptData, p,
+					"prefix PDU outside of cache response")
+			}
+			vrp, announce, err := decodePrefixPDU(p)
+			if err != nil {
+				return c.sendError(conn, version, errCodeCorruptData, p,
+					err.Error())
+			}
+			if announce {
+				if !pending.add(vrp) {
+					return c.sendError(conn, version,
+						errCodeDuplicateAnnouncement, p, "duplicate announcement")
+				}
+			} else if !pending.remove(vrp) {
+				return c.sendError(conn, version,
+					errCodeWithdrawalOfUnknownRecord, p, "withdrawal of unknown record")
+			}
+		case pduTypeRouterKey:
+			// router keys are not used for origin validation
+		case pduTypeEndOfData:
+			if pending == nil {
+				return c.sendError(conn, version, errCodeCorruptData, p,
+					"end of data outside of cache response")
+			}
+			err = c.handleEndOfData(p, version)
+			if err != nil {
+				return c.sendError(conn, version, errCodeCorruptData, p,
+					err.Error())
+			}
+			c.setVRPs(pending)
+			pending = nil
+			c.mu.Lock()
+			refresh := c.refresh
+			c.mu.Unlock()
+			refreshCh = time.After(refresh)
+		case pduTypeCacheReset:
+			// https://www.rfc-editor.org/rfc/rfc8210#section-5.9
+			// The cache server MAY respond to a Serial Query informing the
+			// router that the cache cannot provide an incremental update
+			// starting from the Serial Number specified by the router.
+			// The router must decide whether to issue a Reset Query or
+			// switch to a different cache.
+			c.mu.Lock()
+			c.hasSerial = false
+			c.mu.Unlock()
+			_, err = conn.Write(encodeResetQuery(version))
+			if err != nil {
+				return err
+			}
+		default:
+			return c.sendError(conn, version, errCodeUnsupportedPDUType, p,
+				"unsupported PDU type")
+		}
+	}
+}
+
+// handleEndOfData records the serial and timing parameters of an End of Data
+// PDU.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.8
+func (c *Client) handleEndOfData(p pdu, version uint8) error {
+	want := 4
+	if version == rtrVersion1 {
+		want = 16
+	}
+	if len(p.body) != want {
+		return errors.New("invalid end of data PDU length")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial = binary.BigEndian.Uint32(p.body)
+	c.hasSerial = true
+	c.lastUpdate = time.Now()
+	if version == rtrVersion1 {
+		c.refresh = time.Duration(binary.BigEndian.Uint32(p.body[4:])) *
+			time.Second
+		c.retry = time.Duration(binary.BigEndian.Uint32(p.body[8:])) *
+			time.Second
+		c.expire = time.Duration(binary.BigEndian.Uint32(p.body[12:])) *
+			time.Second
+	}
+	return nil
+}
+
+func (c *Client) sendSerialQuery(conn net.Conn) error {
+	c.mu.Lock()
+	query := encodeSerialQuery(c.version, c.sessionID, c.serial)
+	c.mu.Unlock()
+	_, err := conn.Write(query)
+	return err
+}
+
+// sendError sends an Error Report PDU for p and returns it as an error.
+func (c *Client) sendError(conn net.Conn, version uint8, code uint16, p pdu,
+	text string) error {
+	conn.Write(encodeErrorReport(version, code, // nolint: errcheck
+		encodePDU(p.version, p.typ, p.session, p.body), text))
+	return &ErrorReport{Code: code, Text: text}
+}
@@ -0,0 +1,154 @@
+package rpki
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePrefixPDU(version uint8, v VRP, announce bool) []byte {
+	typ := pduTypeIPv4Prefix
+	if v.Prefix.Addr().Is6() {
+		typ = pduTypeIPv6Prefix
+	}
+	var flags uint8
+	if announce {
+		flags = prefixFlagAnnouncement
+	}
+	b := []byte{flags, uint8(v.Prefix.Bits()), v.MaxLength, 0}
+	b = append(b, v.Prefix.Addr().AsSlice()...)
+	b = binary.BigEndian.AppendUint32(b, v.ASN)
+	return encodePDU(version, typ, 0, b)
+}
+
+func encodeEndOfData(session uint16, serial uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, serial)
+	b = binary.BigEndian.AppendUint32(b, 3600)
+	b = binary.BigEndian.AppendUint32(b, 600)
+	b = binary.BigEndian.AppendUint32(b, 7200)
+	return encodePDU(rtrVersion1, pduTypeEndOfData, session, b)
+}
+
+// testCache is the cache side of a Client connection.
+type testCache struct {
+	t      *testing.T
+	connCh chan net.Conn
+}
+
+func (c *testCache) dial(ctx context.Context, network,
+	address string) (net.Conn, error) {
+	a, b := net.Pipe()
+	select {
+	case c.connCh <- b:
+		return a, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *testCache) expect(conn net.Conn, version, typ uint8) pdu {
+	p, err := readPDU(conn)
+	if assert.NoError(c.t, err) {
+		assert.Equal(c.t, version, p.version)
+		assert.Equal(c.t, typ, p.typ)
+	}
+	return p
+}
+
+func (c *testCache) write(conn net.Conn, pdus ...[]byte) {
+	for _, p := range pdus {
+		_, err := conn.Write(p)
+		assert.NoError(c.t, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	cache := &testCache{t: t, connCh: make(chan net.Conn)}
+	updateCh := make(chan *VRPSet, 2)
+	client, err := NewClient(ClientConfig{
+		Address: "192.0.2.1:323",
+		Dial:    cache.dial,
+		OnUpdate: func(s *VRPSet) {
+			updateCh <- s
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErrCh := make(chan error)
+	go func() {
+		runErrCh <- client.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-runErrCh
+	}()
+
+	v4 := VRP{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, ASN: 64512}
+	v6 := VRP{Prefix: netip.MustParsePrefix("2001:db8::/32"), MaxLength: 48, ASN: 64513}
+
+	conn := <-cache.connCh
+	cache.expect(conn, rtrVersion1, pduTypeResetQuery)
+	cache.write(conn,
+		encodePDU(rtrVersion1, pduTypeCacheResponse, 7, nil),
+		encodePrefixPDU(rtrVersion1, v4, true),
+		encodePrefixPDU(rtrVersion1, v6, true),
+		encodeEndOfData(7, 1),
+	)
+	initial := <-updateCh
+	assert.ElementsMatch(t, []VRP{v4, v6}, initial.VRPs())
+	assert.Equal(t, ValidationStateValid, client.Validate(v4.Prefix, v4.ASN))
+
+	// incremental update
+	cache.write(conn, encodePDU(rtrVersion1, pduTypeSerialNotify, 7,
+		binary.BigEndian.AppendUint32(nil, 2)))
+	p := cache.expect(conn, rtrVersion1, pduTypeSerialQuery)
+	assert.Equal(t, uint16(7), p.session)
+	assert.Equal(t, []byte{0, 0, 0, 1}, p.body)
+	cache.write(conn,
+		encodePDU(rtrVersion1, pduTypeCacheResponse, 7, nil),
+		encodePrefixPDU(rtrVersion1, v4, false),
+		encodeEndOfData(7, 2),
+	)
+	s := <-updateCh
+	assert.Equal(t, []VRP{v6}, s.VRPs())
+	assert.Equal(t, ValidationStateNotFound, client.Validate(v4.Prefix, v4.ASN))
+	// the previous set is unmodified
+	assert.Equal(t, 2, initial.Len())
+}
+
+func TestClient_VersionDowngrade(t *testing.T) {
+	cache := &testCache{t: t, connCh: make(chan net.Conn)}
+	client, err := NewClient(ClientConfig{
+		Address: "192.0.2.1:323",
+		Dial:    cache.dial,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	runErrCh := make(chan error)
+	go func() {
+		runErrCh <- client.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-runErrCh
+	}()
+
+	conn := <-cache.connCh
+	p := cache.expect(conn, rtrVersion1, pduTypeResetQuery)
+	cache.write(conn, encodeErrorReport(rtrVersion0,
+		errCodeUnsupportedProtocolVer, encodePDU(p.version, p.typ, p.session,
+			p.body), ""))
+	conn.Close()
+
+	conn = <-cache.connCh
+	cache.expect(conn, rtrVersion0, pduTypeResetQuery)
+}
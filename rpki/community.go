@@ -0,0 +1,60 @@
+package rpki
+
+const (
+	// extCommTypeNonTransitiveOpaque is the Non-Transitive Opaque Extended
+	// Community Type.
+	extCommTypeNonTransitiveOpaque = 0x43
+	// extCommSubTypeOriginValidation is the BGP Origin Validation State
+	// Extended Community Sub-Type.
+	extCommSubTypeOriginValidation = 0x00
+)
+
+// NewOriginValidationCommunity returns the BGP Prefix Origin Validation State
+// Extended Community for state, encoded as a member of the EXTENDED
+// COMMUNITIES path attribute.
+//
+// https://www.rfc-editor.org/rfc/rfc8097#section-2
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|       0x43    |      0x00     |             Reserved          |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                    Reserved                   |validationstate|
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+func NewOriginValidationCommunity(state ValidationState) [8]byte {
+	var c [8]byte
+	c[0] = extCommTypeNonTransitiveOpaque
+	c[1] = extCommSubTypeOriginValidation
+	c[7] = byte(state)
+	return c
+}
+
+// DecodeOriginValidationCommunity returns the ValidationState and true if c is
+// a BGP Prefix Origin Validation State Extended Community.
+func DecodeOriginValidationCommunity(c [8]byte) (ValidationState, bool) {
+	if c[0] != extCommTypeNonTransitiveOpaque ||
+		c[1] != extCommSubTypeOriginValidation {
+		return 0, false
+	}
+	return ValidationState(c[7]), true
+}
+
+// AppendOriginValidationCommunity appends the BGP Prefix Origin Validation
+// State Extended Community for state to the EXTENDED COMMUNITIES path
+// attribute value b, replacing any existing Origin Validation State Extended
+// Community. Per RFC8097 the community is non-transitive and is meant only for
+// iBGP and confederation peers.
+func AppendOriginValidationCommunity(b []byte,
+	state ValidationState) []byte {
+	out := make([]byte, 0, len(b)+8)
+	for len(b) >= 8 {
+		c := [8]byte(b[:8])
+		if _, ok := DecodeOriginValidationCommunity(c); !ok {
+			out = append(out, c[:]...)
+		}
+		b = b[8:]
+	}
+	c := NewOriginValidationCommunity(state)
+	return append(out, c[:]...)
+}
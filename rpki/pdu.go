@@ -0,0 +1,192 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// RTR protocol versions.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-13
+const (
+	rtrVersion0 uint8 = 0
+	rtrVersion1 uint8 = 1
+)
+
+// RTR PDU types.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-13
+const (
+	pduTypeSerialNotify  uint8 = 0
+	pduTypeSerialQuery   uint8 = 1
+	pduTypeResetQuery    uint8 = 2
+	pduTypeCacheResponse uint8 = 3
+	pduTypeIPv4Prefix    uint8 = 4
+	pduTypeIPv6Prefix    uint8 = 6
+	pduTypeEndOfData     uint8 = 7
+	pduTypeCacheReset    uint8 = 8
+	pduTypeRouterKey     uint8 = 9
+	pduTypeErrorReport   uint8 = 10
+)
+
+// RTR Error Report error codes.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-13
+const (
+	errCodeCorruptData               uint16 = 0
+	errCodeInternalError             uint16 = 1
+	errCodeNoDataAvailable           uint16 = 2
+	errCodeInvalidRequest            uint16 = 3
+	errCodeUnsupportedProtocolVer    uint16 = 4
+	errCodeUnsupportedPDUType        uint16 = 5
+	errCodeWithdrawalOfUnknownRecord uint16 = 6
+	errCodeDuplicateAnnouncement     uint16 = 7
+	errCodeUnexpectedProtocolVer     uint16 = 8
+)
+
+const (
+	pduHeaderLen = 8
+	// maxPDULen bounds the length of received PDUs. Router Key and Error
+	// Report PDUs are the only variable length PDUs.
+	maxPDULen = 1 << 16
+
+	prefixFlagAnnouncement = 0x01
+)
+
+// ErrorReport is an Error Report PDU.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.11
+type ErrorReport struct {
+	Code uint16
+	Text string
+}
+
+func (e *ErrorReport) Error() string {
+	return fmt.Sprintf("rtr error report code %d: %s", e.Code, e.Text)
+}
+
+// pdu is a decoded RTR PDU.
+type pdu struct {
+	version uint8
+	typ     uint8
+	// session is the Session ID or Error Code field, depending on typ
+	session uint16
+	body    []byte
+}
+
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.1
+//
+//	 0          8          16         24        31
+//	.-------------------------------------------.
+//	| Protocol |   PDU    |                     |
+//	| Version  |   Type   |    Session ID       |
+//	|          |          |                     |
+//	+-------------------------------------------+
+//	|                                           |
+//	|                 Length                    |
+//	|                                           |
+//	`-------------------------------------------'
+func readPDU(r io.Reader) (pdu, error) {
+	var header [pduHeaderLen]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return pdu{}, err
+	}
+	p := pdu{
+		version: header[0],
+		typ:     header[1],
+		session: binary.BigEndian.Uint16(header[2:]),
+	}
+	l := binary.BigEndian.Uint32(header[4:])
+	if l < pduHeaderLen || l > maxPDULen {
+		return pdu{}, &ErrorReport{
+			Code: errCodeCorruptData,
+			Text: fmt.Sprintf("invalid PDU length: %d", l),
+		}
+	}
+	p.body = make([]byte, l-pduHeaderLen)
+	_, err = io.ReadFull(r, p.body)
+	if err != nil {
+		return pdu{}, err
+	}
+	return p, nil
+}
+
+func encodePDU(version, typ uint8, session uint16, body []byte) []byte {
+	b := make([]byte, pduHeaderLen, pduHeaderLen+len(body))
+	b[0] = version
+	b[1] = typ
+	binary.BigEndian.PutUint16(b[2:], session)
+	binary.BigEndian.PutUint32(b[4:], uint32(pduHeaderLen+len(body)))
+	return append(b, body...)
+}
+
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.4
+func encodeResetQuery(version uint8) []byte {
+	return encodePDU(version, pduTypeResetQuery, 0, nil)
+}
+
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.3
+func encodeSerialQuery(version uint8, session uint16, serial uint32) []byte {
+	return encodePDU(version, pduTypeSerialQuery, session,
+		binary.BigEndian.AppendUint32(nil, serial))
+}
+
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.11
+func encodeErrorReport(version uint8, code uint16, erroneous []byte,
+	text string) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(erroneous)))
+	b = append(b, erroneous...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(text)))
+	b = append(b, text...)
+	return encodePDU(version, pduTypeErrorReport, code, b)
+}
+
+func decodeErrorReport(p pdu) *ErrorReport {
+	e := &ErrorReport{Code: p.session}
+	b := p.body
+	if len(b) < 4 {
+		return e
+	}
+	pduLen := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint32(len(b)) < pduLen+4 {
+		return e
+	}
+	b = b[pduLen:]
+	textLen := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint32(len(b)) >= textLen {
+		e.Text = string(b[:textLen])
+	}
+	return e
+}
+
+// decodePrefixPDU decodes an IPv4 or IPv6 Prefix PDU, returning the VRP and
+// true if it is an announcement.
+//
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.6
+// https://www.rfc-editor.org/rfc/rfc8210#section-5.7
+func decodePrefixPDU(p pdu) (VRP, bool, error) {
+	addrLen := 4
+	if p.typ == pduTypeIPv6Prefix {
+		addrLen = 16
+	}
+	if len(p.body) != 8+addrLen {
+		return VRP{}, false, errors.New("invalid prefix PDU length")
+	}
+	flags, prefixLen, maxLen := p.body[0], p.body[1], p.body[2]
+	addr, _ := netip.AddrFromSlice(p.body[4 : 4+addrLen])
+	if int(prefixLen) > addr.BitLen() || int(maxLen) > addr.BitLen() ||
+		maxLen < prefixLen {
+		return VRP{}, false, errors.New("invalid prefix PDU lengths")
+	}
+	return VRP{
+		Prefix:    netip.PrefixFrom(addr, int(prefixLen)).Masked(),
+		MaxLength: maxLen,
+		ASN:       binary.BigEndian.Uint32(p.body[4+addrLen:]),
+	}, flags&prefixFlagAnnouncement != 0, nil
+}
@@ -0,0 +1,190 @@
+// Package rpki provides RPKI origin validation for corebgp applications. It
+// includes an RTR (RFC8210) client that maintains a set of Validated ROA
+// Payloads (VRPs) from a cache, route origin validation (RFC6811), and
+// helpers for the BGP Prefix Origin Validation State Extended Community
+// (RFC8097).
+package rpki
+
+import (
+	"net/netip"
+)
+
+// VRP is a Validated ROA Payload.
+//
+// https://www.rfc-editor.org/rfc/rfc6811#section-2
+type VRP struct {
+	// Prefix is the ROA prefix.
+	Prefix netip.Prefix
+	// MaxLength is the maximum length of prefixes covered by the VRP that
+	// may be originated by ASN.
+	MaxLength uint8
+	// ASN is the AS authorized to originate routes for Prefix.
+	ASN uint32
+}
+
+// ValidationState is the result of route origin validation.
+//
+// https://www.rfc-editor.org/rfc/rfc6811#section-2
+type ValidationState uint8
+
+// ValidationState values match those of the validation state field of the BGP
+// Prefix Origin Validation State Extended Community.
+//
+// https://www.rfc-editor.org/rfc/rfc8097#section-2
+const (
+	ValidationStateValid    ValidationState = 0
+	ValidationStateNotFound ValidationState = 1
+	ValidationStateInvalid  ValidationState = 2
+)
+
+func (v ValidationState) String() string {
+	switch v {
+	case ValidationStateValid:
+		return "valid"
+	case ValidationStateNotFound:
+		return "not-found"
+	case ValidationStateInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+type vrpValue struct {
+	maxLength uint8
+	asn       uint32
+}
+
+// VRPSet is an immutable set of VRPs. The zero value is an empty set.
+type VRPSet struct {
+	vrps map[netip.Prefix][]vrpValue
+	len  int
+}
+
+// NewVRPSet returns a VRPSet containing vrps. Duplicate VRPs are ignored.
+func NewVRPSet(vrps []VRP) *VRPSet {
+	s := &VRPSet{
+		vrps: make(map[netip.Prefix][]vrpValue),
+	}
+	for _, v := range vrps {
+		s.add(v)
+	}
+	return s
+}
+
+// add adds v to the set, returning false if it already exists. It must only be
+// called on a set that has not been published.
+func (s *VRPSet) add(v VRP) bool {
+	prefix := v.Prefix.Masked()
+	val := vrpValue{maxLength: v.MaxLength, asn: v.ASN}
+	for _, existing := range s.vrps[prefix] {
+		if existing == val {
+			return false
+		}
+	}
+	s.vrps[prefix] = append(s.vrps[prefix], val)
+	s.len++
+	return true
+}
+
+// remove removes v from the set, returning false if it does not exist. It must
+// only be called on a set that has not been published.
+func (s *VRPSet) remove(v VRP) bool {
+	prefix := v.Prefix.Masked()
+	val := vrpValue{maxLength: v.MaxLength, asn: v.ASN}
+	vals := s.vrps[prefix]
+	for i, existing := range vals {
+		if existing != val {
+			continue
+		}
+		// copy rather than modify in place as the slice may be shared with a
+		// published set
+		rest := make([]vrpValue, 0, len(vals)-1)
+		rest = append(rest, vals[:i]...)
+		rest = append(rest, vals[i+1:]...)
+		if len(rest) == 0 {
+			delete(s.vrps, prefix)
+		} else {
+			s.vrps[prefix] = rest
+		}
+		s.len--
+		return true
+	}
+	return false
+}
+
+// clone returns a copy of s that may be modified without affecting s.
+func (s *VRPSet) clone() *VRPSet {
+	c := &VRPSet{
+		vrps: make(map[netip.Prefix][]vrpValue, len(s.vrps)),
+		len:  s.len,
+	}
+	for k, v := range s.vrps {
+		// limit capacity so that add() does not write to a shared array
+		c.vrps[k] = v[:len(v):len(v)]
+	}
+	return c
+}
+
+// Len returns the number of VRPs in the set.
+func (s *VRPSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.len
+}
+
+// VRPs returns the VRPs in the set in no particular order.
+func (s *VRPSet) VRPs() []VRP {
+	vrps := make([]VRP, 0, s.Len())
+	if s == nil {
+		return vrps
+	}
+	for prefix, vals := range s.vrps {
+		for _, v := range vals {
+			vrps = append(vrps, VRP{
+				Prefix:    prefix,
+				MaxLength: v.maxLength,
+				ASN:       v.asn,
+			})
+		}
+	}
+	return vrps
+}
+
+// Validate returns the ValidationState of a route for prefix originated by
+// origin. origin should be 0 if the origin AS cannot be determined, e.g. the
+// AS_PATH ends with an AS_SET.
+//
+// https://www.rfc-editor.org/rfc/rfc6811#section-2
+//
+//   - NotFound: No VRP Covers the Route Prefix.
+//   - Valid: At least one VRP Matches the Route Prefix.
+//   - Invalid: At least one VRP Covers the Route Prefix, but no VRP Matches
+//     it.
+func (s *VRPSet) Validate(prefix netip.Prefix, origin uint32) ValidationState {
+	if s == nil || !prefix.IsValid() {
+		return ValidationStateNotFound
+	}
+	prefix = prefix.Masked()
+	covered := false
+	for bits := 0; bits <= prefix.Bits(); bits++ {
+		vals, ok := s.vrps[netip.PrefixFrom(prefix.Addr(), bits).Masked()]
+		if !ok {
+			continue
+		}
+		covered = true
+		for _, v := range vals {
+			// https://www.rfc-editor.org/rfc/rfc6811#section-2
+			// A VRP with an origin AS of zero cannot be Matched by any route.
+			if v.asn != 0 && v.asn == origin &&
+				prefix.Bits() <= int(v.maxLength) {
+				return ValidationStateValid
+			}
+		}
+	}
+	if covered {
+		return ValidationStateInvalid
+	}
+	return ValidationStateNotFound
+}
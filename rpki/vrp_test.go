@@ -0,0 +1,74 @@
+package rpki
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVRPSet_Validate(t *testing.T) {
+	s := NewVRPSet([]VRP{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, ASN: 64512},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/22"), MaxLength: 24, ASN: 64513},
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24"), MaxLength: 24, ASN: 0},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), MaxLength: 48, ASN: 64514},
+		// duplicate
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, ASN: 64512},
+	})
+	assert.Equal(t, 4, s.Len())
+	assert.Len(t, s.VRPs(), 4)
+
+	tests := []struct {
+		prefix string
+		origin uint32
+		want   ValidationState
+	}{
+		{"192.0.2.0/24", 64512, ValidationStateValid},
+		{"192.0.2.0/24", 64515, ValidationStateInvalid},
+		{"192.0.2.0/25", 64512, ValidationStateInvalid},
+		{"192.0.0.0/16", 64512, ValidationStateNotFound},
+		{"198.51.101.0/24", 64513, ValidationStateValid},
+		{"198.51.100.0/22", 64513, ValidationStateValid},
+		{"198.51.100.0/25", 64513, ValidationStateInvalid},
+		{"203.0.113.0/24", 0, ValidationStateInvalid},
+		{"2001:db8:1::/48", 64514, ValidationStateValid},
+		{"2001:db8:1::/64", 64514, ValidationStateInvalid},
+		{"2001:db9::/32", 64514, ValidationStateNotFound},
+	}
+	for _, tt := range tests {
+		got := s.Validate(netip.MustParsePrefix(tt.prefix), tt.origin)
+		assert.Equal(t, tt.want, got, "%s AS%d", tt.prefix, tt.origin)
+	}
+
+	var empty *VRPSet
+	assert.Equal(t, ValidationStateNotFound,
+		empty.Validate(netip.MustParsePrefix("192.0.2.0/24"), 64512))
+}
+
+func TestVRPSet_clone(t *testing.T) {
+	v1 := VRP{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, ASN: 64512}
+	v2 := VRP{Prefix: netip.MustParsePrefix("192.0.2.0/24"), MaxLength: 24, ASN: 64513}
+	s := NewVRPSet([]VRP{v1})
+	c := s.clone()
+	assert.True(t, c.add(v2))
+	assert.True(t, c.remove(v1))
+	assert.False(t, c.remove(v1))
+	assert.Equal(t, []VRP{v1}, s.VRPs())
+	assert.Equal(t, []VRP{v2}, c.VRPs())
+}
+
+func TestOriginValidationCommunity(t *testing.T) {
+	c := NewOriginValidationCommunity(ValidationStateInvalid)
+	assert.Equal(t, [8]byte{0x43, 0, 0, 0, 0, 0, 0, 2}, c)
+	state, ok := DecodeOriginValidationCommunity(c)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationStateInvalid, state)
+	_, ok = DecodeOriginValidationCommunity([8]byte{0x00, 0x02})
+	assert.False(t, ok)
+
+	other := []byte{0x00, 0x02, 0xfd, 0xe8, 0, 0, 0, 1}
+	b := AppendOriginValidationCommunity(append(other, c[:]...),
+		ValidationStateValid)
+	assert.Equal(t, append(other, 0x43, 0, 0, 0, 0, 0, 0, 0), b)
+}
@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// notifCodeSendHoldTimerExpired is the Send Hold Timer Expired Error Code.
+	// It is not yet present in the generated IANA constants.
+	//
+	// https://www.rfc-editor.org/rfc/rfc9687#section-5
+	notifCodeSendHoldTimerExpired uint8 = 8
+
+	// DefaultSendHoldTime is the default send hold time for a peer.
+	//
+	// https://www.rfc-editor.org/rfc/rfc9687#section-3
+	// The suggested default value for the SendHoldTime is 8 minutes.
+	DefaultSendHoldTime = time.Minute * 8
+)
+
+// newSendHoldTimerExpiredErr returns the error returned by writes that did not
+// complete within the send hold time.
+func newSendHoldTimerExpiredErr() error {
+	return newNotificationError(newNotification(notifCodeSendHoldTimerExpired,
+		0, nil), true)
+}
+
+// sessionSendHoldTime returns the send hold time of a session with the
+// negotiated holdTime for a peer with options o, or 0 if the send hold timer
+// is disabled. A send hold time set via WithSendHoldTime is used as is.
+//
+// https://www.rfc-editor.org/rfc/rfc9687#section-3
+// The suggested default value for the SendHoldTime is 8 minutes or two times
+// the negotiated Hold Time value, whichever is longer.
+func sessionSendHoldTime(o *peerOptions, holdTime time.Duration) time.Duration {
+	if o.sendHoldTimeSet {
+		return o.sendHoldTime
+	}
+	return max(o.sendHoldTime, 2*holdTime)
+}
+
+// writeWithSendHold writes b to conn. If sendHoldTime is non-zero and the
+// write does not complete within it, an error wrapping a Send Hold Timer
+// Expired Notification is returned. A sendHoldTime of 0 clears any deadline
+// set by a previous write.
+//
+// https://www.rfc-editor.org/rfc/rfc9687#section-4
+// If the SendHoldTimer expires, the local system:
+//
+//   - (optionally) sends a NOTIFICATION message with the BGP Error Code "Send
+//     Hold Timer Expired" if the local system can determine that doing so
+//     will not delay the following actions in this paragraph,
+//   - logs an error message in the local system with the BGP Error Code "Send
+//     Hold Timer Expired",
+//   - releases all BGP resources,
+//   - sets the ConnectRetryTimer to zero,
+//   - drops the TCP connection, [...]
+//
+// As the connection is unable to make progress no Notification is sent.
+func writeWithSendHold(conn net.Conn, b []byte,
+	sendHoldTime time.Duration) error {
+	var deadline time.Time
+	if sendHoldTime > 0 {
+		deadline = time.Now().Add(sendHoldTime)
+	}
+	err := conn.SetWriteDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return newSendHoldTimerExpiredErr()
+	}
+	return err
+}
+
+// WithSendHoldTime returns a PeerOption that sets the send hold time for a
+// peer. If a write to the peer's connection is unable to complete within the
+// send hold time, e.g. because the peer has stopped reading from its socket,
+// the session is torn down rather than being left wedged. A value of 0
+// disables the send hold timer.
+//
+// If WithSendHoldTime is not used, the send hold time of a session is the
+// larger of DefaultSendHoldTime and twice the negotiated hold time, per the
+// default suggested by RFC9687. Otherwise t is used as is, even if it is less
+// than twice the negotiated hold time.
+//
+// https://www.rfc-editor.org/rfc/rfc9687
+func WithSendHoldTime(t time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.sendHoldTime = t
+		o.sendHoldTimeSet = true
+	})
+}
@@ -0,0 +1,111 @@
+package corebgp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteWithSendHold(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	// nothing reads from b, the write must not block beyond sendHoldTime
+	err := writeWithSendHold(a, []byte{1}, time.Millisecond*10)
+	var nerr *notificationError
+	if assert.True(t, errors.As(err, &nerr)) {
+		assert.Equal(t, notifCodeSendHoldTimerExpired, nerr.notification.Code)
+		assert.True(t, nerr.dampPeer())
+	}
+
+	go func() {
+		buf := make([]byte, 1)
+		b.Read(buf) // nolint: errcheck
+	}()
+	err = writeWithSendHold(a, []byte{1}, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestWriteWithSendHold_clear(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	go io.Copy(io.Discard, b) // nolint: errcheck
+
+	err := writeWithSendHold(a, []byte{1}, time.Millisecond*10)
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond * 20)
+	// the deadline of the previous write has passed
+	err = writeWithSendHold(a, []byte{1}, 0)
+	assert.NoError(t, err)
+}
+
+func TestSessionSendHoldTime(t *testing.T) {
+	// the RFC9687 default applies when unset
+	o := defaultPeerOptions()
+	assert.Equal(t, DefaultSendHoldTime,
+		sessionSendHoldTime(&o, time.Second*90))
+	assert.Equal(t, time.Minute*10, sessionSendHoldTime(&o, time.Minute*5))
+
+	// a configured value is used as is
+	for _, d := range []time.Duration{0, time.Second, DefaultSendHoldTime} {
+		o := defaultPeerOptions()
+		WithSendHoldTime(d).apply(&o)
+		assert.Equal(t, d, sessionSendHoldTime(&o, time.Minute*5))
+	}
+}
+
+// readAfter reads n bytes from conn once d has elapsed.
+func readAfter(conn net.Conn, n int, d time.Duration) {
+	go func() {
+		time.Sleep(d)
+		io.ReadFull(conn, make([]byte, n)) // nolint: errcheck
+	}()
+}
+
+func TestSendHoldTime_updateInPlace(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	config := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}
+	err = s.AddPeer(config, nopPlugin{},
+		WithSendHoldTime(time.Millisecond*10))
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, _ := s.peers.get(config.RemoteAddress)
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	f := &fsm{peer: p, conn: a}
+	w := newTestUpdateMessageWriter(a, 0)
+	w.p = p
+	update := []byte{0, 0, 0, 0}
+
+	// nothing reads from b
+	err = f.write([]byte{1})
+	var nerr *notificationError
+	if assert.ErrorAs(t, err, &nerr) {
+		assert.Equal(t, notifCodeSendHoldTimerExpired, nerr.notification.Code)
+	}
+	assert.ErrorAs(t, w.WriteUpdate(update), &nerr)
+
+	action, err := s.UpdatePeer(config, WithSendHoldTime(0))
+	assert.NoError(t, err)
+	assert.Equal(t, PeerUpdateInPlace, action)
+	readAfter(b, 1, time.Millisecond*50)
+	assert.NoError(t, f.write([]byte{1}))
+	readAfter(b, headerLength+len(update), time.Millisecond*50)
+	assert.NoError(t, w.WriteUpdate(update))
+}
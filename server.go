@@ -1,47 +1,88 @@
 package corebgp
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
 	"net/netip"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Server is a BGP server that manages peers.
+//
+// The methods of a Server are safe for concurrent use. Methods that add,
+// update, or delete peers or PeerGroups are serialized by a mutex, whereas
+// methods reading or acting on a single peer, e.g. GetSessionInfo or
+// GetPeerStats, and the matching of inbound connections to peers only lock a
+// shard of an index of peers by remote address, so they neither contend with
+// each other nor wait on those that modify the Server. Each listener passed
+// to Serve accepts connections in its own goroutine, and each accepted
+// connection is matched to its peer in its own goroutine, so one Server may
+// handle thousands of concurrent sessions. Each peer's FSM runs in goroutines
+// of its own, and its Plugin is invoked from them.
+//
+// Methods returning multiple peers, e.g. ListPeers, observe each peer at a
+// slightly different time if peers are concurrently added or deleted.
 type Server struct {
-	mu    sync.Mutex
-	id    uint32
-	peers map[string]*peer
+	// mu serializes the modification of peers, and protects groups,
+	// listenerMD5Keys, and the run state
+	mu     sync.Mutex
+	id     uint32
+	peers  *peerTable
+	groups map[string]PeerGroup
+	// listenerMD5Keys are tcp md5 keys set via SetListenerTCPMD5Key
+	listenerMD5Keys map[netip.Prefix]string
+	// md5AlternateInterval is the interval at which listener tcp md5 keys
+	// alternate following a rotation, see SetTCPMD5Key
+	md5AlternateInterval time.Duration
+	// openPolicy is set via SetOpenPolicy
+	openPolicy atomic.Pointer[OpenPolicy]
+	// pendingOpens are the inbound connections whose OPEN message is being
+	// read by handleInboundOpen
+	pendingOpens pendingConns
 
 	// control channels & run state
 	serving       bool
+	shuttingDown  atomic.Bool
+	listeners     []net.Listener
 	doneServingCh chan struct{}
 	closeCh       chan struct{}
 	closeOnce     sync.Once
 }
 
-// NewServer creates a new Server.
+// NewServer creates a new Server. routerID is the default BGP identifier for
+// peers. Per RFC6286 it may be any non-zero IPv4 address, it is not required
+// to be a valid unicast host address, but it should be unique within the local
+// AS.
 func NewServer(routerID netip.Addr) (*Server, error) {
-	if !routerID.Is4() {
+	if !routerID.Is4() || routerID.IsUnspecified() {
 		return nil, errors.New("invalid router ID")
 	}
 
 	s := &Server{
-		mu:            sync.Mutex{},
-		id:            binary.BigEndian.Uint32(routerID.AsSlice()),
-		peers:         make(map[string]*peer),
-		doneServingCh: make(chan struct{}),
-		closeCh:       make(chan struct{}),
+		mu:              sync.Mutex{},
+		id:              binary.BigEndian.Uint32(routerID.AsSlice()),
+		peers:           newPeerTable(),
+		groups:          make(map[string]PeerGroup),
+		listenerMD5Keys: make(map[netip.Prefix]string),
+		doneServingCh:   make(chan struct{}),
+		closeCh:         make(chan struct{}),
+
+		md5AlternateInterval: md5ListenerAlternateInterval,
 	}
 	return s, nil
 }
 
 var (
-	ErrServerClosed      = errors.New("server closed")
-	ErrPeerNotExist      = errors.New("peer does not exist")
-	ErrPeerAlreadyExists = errors.New("peer already exists")
+	ErrServerClosed       = errors.New("server closed")
+	ErrPeerNotExist       = errors.New("peer does not exist")
+	ErrPeerAlreadyExists  = errors.New("peer already exists")
+	ErrPeerNotEstablished = errors.New("peer is not established")
 )
 
 func (s *Server) handleInboundConn(conn net.Conn) {
@@ -50,27 +91,112 @@ func (s *Server) handleInboundConn(conn net.Conn) {
 		conn.Close()
 		return
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	p, exists := s.peers[h]
+	remote, err := netip.ParseAddr(h)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	var policy OpenPolicy
+	if p := s.openPolicy.Load(); p != nil {
+		policy = *p
+	} else if s.identityMatch(remote) {
+		policy = s.identityOpenPolicy
+	}
+	if policy != nil {
+		s.handleInboundOpen(conn, policy)
+		return
+	}
+	s.matchInboundConn(conn, remote, nil)
+}
+
+// matchInboundConn hands conn to the peer matching remote, if any. replay is
+// the data already read from conn by an OpenPolicy, if any.
+func (s *Server) matchInboundConn(conn net.Conn, remote netip.Addr,
+	replay []byte) {
+	_, port, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if s.shuttingDown.Load() {
+		conn.Close()
+		return
+	}
+	p, wantLocal, exists := s.peers.forInbound(remote)
 	if !exists {
 		conn.Close()
 		return
 	}
-	if p.options.localAddress.IsValid() {
-		h, _, err = net.SplitHostPort(conn.LocalAddr().String())
+	if p.options().transportMode == TransportModeActive {
+		p.logf("rejecting inbound connection: peer is active-only")
+		conn.Close()
+		return
+	}
+	srcPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || !p.options().inboundSrcPortAllowed(uint16(srcPort)) {
+		p.logf("rejecting inbound connection: source port %s not allowed",
+			port)
+		conn.Close()
+		return
+	}
+	if wantLocal.IsValid() {
+		h, _, err := net.SplitHostPort(conn.LocalAddr().String())
 		laddr, _ := netip.ParseAddr(h)
-		if err != nil || p.options.localAddress != laddr {
+		if len(wantLocal.Zone()) == 0 {
+			// a zone-less local address matches regardless of the zone
+			// reported for link-local addresses
+			laddr = laddr.WithZone("")
+		}
+		if err != nil || wantLocal != laddr {
 			conn.Close()
 			return
 		}
 	}
+	err = p.options().tcpOptions.verifyInbound(conn)
+	if err != nil {
+		p.logf("rejecting inbound connection: %v", err)
+		conn.Close()
+		return
+	}
+	err = p.options().tcpOptions.applyToConn(conn)
+	if err != nil {
+		p.logf("error applying tcp options to inbound connection: %v", err)
+		conn.Close()
+		return
+	}
+	if len(replay) > 0 {
+		conn = &replayConn{Conn: conn, replay: replay}
+	}
 	p.incomingConnection(conn)
 }
 
+// transportConflictLocked returns an error if any of the remote addresses of
+// config and o belong to a peer other than exclude. It must be called with
+// s.mu held.
+func (s *Server) transportConflictLocked(config PeerConfig, o peerOptions,
+	exclude *peer) error {
+	remotes := []netip.Addr{config.RemoteAddress}
+	for _, t := range o.fallbackTransports {
+		remotes = append(remotes, t.RemoteAddress)
+	}
+	for _, r := range remotes {
+		p, _, exists := s.peers.forInbound(r)
+		if exists && p != exclude {
+			return fmt.Errorf("%w: transport %s in use", ErrPeerAlreadyExists,
+				r)
+		}
+	}
+	return nil
+}
+
 // Serve starts all peers' FSMs, starts handling incoming connections if a
 // non-nil listener is provided, and then blocks. Serve returns ErrServerClosed
 // upon Close() or a listener error if one occurs.
+//
+// listeners may be any net.Listener implementation, e.g. one wrapping a
+// *net.TCPListener to instrument connections. Accepted connections are matched
+// to peers by the IP address in their RemoteAddr(), or by the address returned
+// from the OpenPolicy if one is set, see SetOpenPolicy.
 func (s *Server) Serve(listeners []net.Listener) error {
 	s.mu.Lock()
 	// check if server has been closed
@@ -84,9 +210,31 @@ func (s *Server) Serve(listeners []net.Listener) error {
 	default:
 	}
 
+	// set tcp md5 keys on listeners
+	s.listeners = listeners
+	for prefix, key := range s.listenerMD5Keys {
+		err := s.setListenerMD5PrefixesLocked([]netip.Prefix{prefix}, key)
+		if err != nil {
+			s.listeners = nil
+			s.mu.Unlock()
+			return err
+		}
+	}
+	peers := s.peers.list()
+	for _, p := range peers {
+		if key := p.listenerMD5Key(); len(key) > 0 {
+			err := s.setListenerMD5KeysLocked(p, key)
+			if err != nil {
+				s.listeners = nil
+				s.mu.Unlock()
+				return err
+			}
+		}
+	}
+
 	// set serving state and enable peers
 	s.serving = true
-	for _, peer := range s.peers {
+	for _, peer := range peers {
 		peer.start()
 	}
 	s.mu.Unlock()
@@ -94,16 +242,20 @@ func (s *Server) Serve(listeners []net.Listener) error {
 	defer func() {
 		// disable peers and set serving state before returning
 		s.mu.Lock()
-		for _, peer := range s.peers {
+		for _, peer := range s.peers.list() {
 			peer.stop()
 		}
 		s.serving = false
+		s.listeners = nil
 		close(s.doneServingCh)
 		s.mu.Unlock()
 	}()
 
 	lisErrCh := make(chan error)
 	lisWG := &sync.WaitGroup{}
+	// connWG tracks the goroutines handling accepted connections, which
+	// must not outlive the peers they are handed to
+	connWG := &sync.WaitGroup{}
 	closingListeners := make(chan struct{})
 	for _, lis := range listeners {
 		lisWG.Add(1)
@@ -118,7 +270,11 @@ func (s *Server) Serve(listeners []net.Listener) error {
 					}
 					return
 				}
-				s.handleInboundConn(conn)
+				connWG.Add(1)
+				go func() {
+					defer connWG.Done()
+					s.handleInboundConn(conn)
+				}()
 			}
 		}(lis)
 	}
@@ -129,6 +285,10 @@ func (s *Server) Serve(listeners []net.Listener) error {
 			lis.Close()
 		}
 		lisWG.Wait()
+		// connections waiting for an OPEN message may otherwise block
+		// for up to the OpenSent hold time
+		s.pendingOpens.closeAll()
+		connWG.Wait()
 	}
 
 	select {
@@ -155,9 +315,47 @@ func (s *Server) Close() {
 	<-s.doneServingCh
 }
 
+// Shutdown gracefully stops the Server. It stops accepting new connections,
+// sends a Cease Notification with the Administrative Shutdown subcode to all
+// established peers, including communication if non-empty, and waits for
+// their sessions to close before calling Close. If ctx is done before all
+// sessions have closed, Close is called immediately and the context's error
+// is returned. An instance of a stopped Server cannot be re-used.
+func (s *Server) Shutdown(ctx context.Context, communication string) error {
+	s.shuttingDown.Store(true)
+	writers := make([]*updateMessageWriter, 0)
+	s.peers.each(func(p *peer) bool {
+		w := p.getSessionWriter()
+		if w != nil {
+			writers = append(writers, w)
+		}
+		return true
+	})
+
+	n := NewAdminShutdownNotification(communication)
+	var err error
+	for _, w := range writers {
+		w.WriteNotification(n, true) // nolint: errcheck
+	}
+	for _, w := range writers {
+		select {
+		case <-w.closeCh:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	s.Close()
+	return err
+}
+
 // PeerConfig is the required configuration for a Peer.
 type PeerConfig struct {
-	// RemoteAddress is the remote address of the peer.
+	// RemoteAddress is the remote address of the peer. IPv6 link-local
+	// addresses must include the zone of the interface the peer is reachable
+	// through, e.g. fe80::1%eth0.
 	RemoteAddress netip.Addr
 
 	// LocalAS is the local autonomous system number to populate in outbound
@@ -170,17 +368,17 @@ type PeerConfig struct {
 }
 
 func (p PeerConfig) validate(opts peerOptions) error {
-	if !opts.localAddress.IsValid() && p.RemoteAddress.IsValid() {
-		return nil
-	}
-	localIsIPv4 := opts.localAddress.Is4()
-	remoteIsIPv4 := p.RemoteAddress.Is4()
-	if localIsIPv4 != remoteIsIPv4 {
-		return errors.New("mixed address family peer address pair")
+	err := validateTransport(p.RemoteAddress, opts.localAddress)
+	if err != nil {
+		return err
 	}
-	if !localIsIPv4 {
-		if !opts.localAddress.Is6() || !p.RemoteAddress.Is6() {
-			return errors.New("invalid peer address pair")
+	for _, t := range opts.fallbackTransports {
+		err = validateTransport(t.RemoteAddress, t.LocalAddress)
+		if err != nil {
+			return fmt.Errorf("fallback transport %s: %w", t.RemoteAddress, err)
+		}
+		if t.RemoteAddress == p.RemoteAddress {
+			return errors.New("fallback transport duplicates remote address")
 		}
 	}
 	// https://tools.ietf.org/html/rfc7607
@@ -196,57 +394,271 @@ func (p PeerConfig) validate(opts peerOptions) error {
 	return nil
 }
 
+func validateTransport(remote, local netip.Addr) error {
+	if remote.Is6() && remote.IsLinkLocalUnicast() && len(remote.Zone()) == 0 {
+		return errors.New("link-local remote address requires a zone")
+	}
+	if !local.IsValid() && remote.IsValid() {
+		return nil
+	}
+	if len(local.Zone()) > 0 && local.Zone() != remote.Zone() {
+		return errors.New("mismatched local and remote address zones")
+	}
+	localIsIPv4 := local.Is4()
+	remoteIsIPv4 := remote.Is4()
+	if localIsIPv4 != remoteIsIPv4 {
+		return errors.New("mixed address family peer address pair")
+	}
+	if !localIsIPv4 {
+		if !local.Is6() || !remote.Is6() {
+			return errors.New("invalid peer address pair")
+		}
+	}
+	return nil
+}
+
 // AddPeer adds a peer to the Server to be handled with the provided Plugin and
 // PeerOptions.
 func (s *Server) AddPeer(config PeerConfig, plugin Plugin,
 	opts ...PeerOption) error {
+	o, err := resolvePeerOptions(config, nil, opts)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addPeerLocked(config, plugin, o)
+}
+
+// resolvePeerOptions applies groupOpts and then opts to the default
+// peerOptions, and validates the result along with config.
+func resolvePeerOptions(config PeerConfig, groupOpts,
+	opts []PeerOption) (peerOptions, error) {
 	o := defaultPeerOptions()
+	for _, opt := range groupOpts {
+		opt.apply(&o)
+	}
 	for _, opt := range opts {
 		opt.apply(&o)
 	}
 	err := o.validate()
 	if err != nil {
-		return fmt.Errorf("invalid peer options: %v", err)
+		return o, fmt.Errorf("invalid peer options: %v", err)
 	}
 	err = config.validate(o)
 	if err != nil {
-		return fmt.Errorf("peer config invalid: %v", err)
+		return o, fmt.Errorf("peer config invalid: %v", err)
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	_, exists := s.peers[config.RemoteAddress.String()]
+	return o, nil
+}
+
+// routerID returns the BGP identifier for a peer with options o.
+func (s *Server) routerID(o peerOptions) uint32 {
+	if o.routerID.IsValid() {
+		return binary.BigEndian.Uint32(o.routerID.AsSlice())
+	}
+	return s.id
+}
+
+// addPeerLocked must be called with s.mu held.
+func (s *Server) addPeerLocked(config PeerConfig, plugin Plugin,
+	o peerOptions) error {
+	_, exists := s.peers.get(config.RemoteAddress)
 	if exists {
 		return ErrPeerAlreadyExists
 	}
-	p := newPeer(config, s.id, plugin, o)
+	err := s.transportConflictLocked(config, o, nil)
+	if err != nil {
+		return err
+	}
+	p := newPeer(config, s.routerID(o), plugin, o)
+	if len(o.md5Key) > 0 {
+		err = s.setListenerMD5KeysLocked(p, o.md5Key)
+		if err != nil {
+			return err
+		}
+	}
 	if s.serving {
 		p.start()
 	}
-	s.peers[p.config.RemoteAddress.String()] = p
+	s.peers.set(p, nil)
 	return nil
 }
 
+// PeerUpdateAction describes how UpdatePeer applied a change to a peer.
+type PeerUpdateAction uint8
+
+const (
+	// PeerUpdateInPlace indicates the change was applied without affecting
+	// the peer's session. Changed PeerOptions take effect as they are next
+	// consulted, e.g. hold time and TCP options apply to the next connection.
+	PeerUpdateInPlace PeerUpdateAction = iota
+	// PeerUpdateReset indicates the change required renegotiation and the
+	// established session was reset with a Cease Notification (Administrative
+	// Reset).
+	PeerUpdateReset
+)
+
+func (a PeerUpdateAction) String() string {
+	switch a {
+	case PeerUpdateInPlace:
+		return "in-place"
+	case PeerUpdateReset:
+		return "reset"
+	default:
+		return "unknown"
+	}
+}
+
+// UpdatePeer reconfigures the existing peer at config.RemoteAddress with config
+// and opts. As with AddPeer, opts are applied on top of the default
+// PeerOptions, they are not merged with the peer's current PeerOptions. If the
+// peer is a member of a PeerGroup, opts are applied on top of the group's
+// Options and retained as the peer's overrides. The peer's Plugin is retained.
+//
+// Changes that do not require renegotiation are applied in place. The
+// established session, if any, is reset when the local or remote AS or the
+// router ID changes, or when the capabilities returned by the Plugin no
+// longer match those that were advertised for the session. The returned
+// PeerUpdateAction reports which of these occurred. A reset session has
+// closed by the time UpdatePeer returns, other Server methods are not blocked
+// while it closes.
+func (s *Server) UpdatePeer(config PeerConfig,
+	opts ...PeerOption) (PeerUpdateAction, error) {
+	s.mu.Lock()
+	p, exists := s.peers.get(config.RemoteAddress)
+	if !exists {
+		s.mu.Unlock()
+		return 0, ErrPeerNotExist
+	}
+	var groupOpts []PeerOption
+	if len(p.group) > 0 {
+		groupOpts = s.groups[p.group].Options
+	}
+	o, err := resolvePeerOptions(config, groupOpts, opts)
+	if err == nil {
+		err = s.transportConflictLocked(config, o, p)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	action, wait := s.updatePeerLocked(p, config, o, opts)
+	s.mu.Unlock()
+	wait()
+	return action, nil
+}
+
+// updatePeerLocked must be called with s.mu held. The returned func waits for
+// a reset session to close, and for a replaced peer to stop, it must be called
+// once s.mu is released so that the Plugin of the peer may call Server
+// methods while its session is torn down.
+func (s *Server) updatePeerLocked(p *peer, config PeerConfig, o peerOptions,
+	memberOpts []PeerOption) (PeerUpdateAction, func()) {
+	if config != p.config || s.routerID(o) != p.id {
+		// PeerConfig and the router ID are immutable for the lifetime of a
+		// peer, replace it.
+		action := PeerUpdateInPlace
+		closedCh := p.resetSession()
+		if closedCh != nil {
+			action = PeerUpdateReset
+		}
+		np := newPeer(config, s.routerID(o), p.plugin, o)
+		np.group = p.group
+		np.memberOpts = memberOpts
+		s.updateMD5KeyLocked(np, p.options().md5Key, o.md5Key)
+		serving := s.serving
+		if serving {
+			np.start()
+		}
+		s.peers.set(np, p.options().fallbackTransports)
+		return action, func() {
+			if closedCh != nil {
+				<-closedCh
+			}
+			if serving {
+				p.stop()
+			}
+		}
+	}
+
+	oldKey := p.options().md5Key
+	oldFallbacks := p.options().fallbackTransports
+	p.opts.Store(&o)
+	p.memberOpts = memberOpts
+	s.peers.set(p, oldFallbacks)
+	s.updateMD5KeyLocked(p, oldKey, o.md5Key)
+	session, established := p.getSessionInfo()
+	if established {
+		om, err := newOpenMessage(config.LocalAS, o.holdTime, p.id,
+			pluginGetCapabilities(p.ctx, p.plugin, config))
+		if err == nil &&
+			!capabilitiesEqual(om.getCapabilities(), session.LocalCapabilities) {
+			if closedCh := p.resetSession(); closedCh != nil {
+				return PeerUpdateReset, func() {
+					<-closedCh
+				}
+			}
+		}
+	}
+	return PeerUpdateInPlace, func() {}
+}
+
+// updateMD5KeyLocked rotates the tcp md5 key of p as part of an update, logging
+// any error. It must be called with s.mu held.
+func (s *Server) updateMD5KeyLocked(p *peer, oldKey, newKey string) {
+	err := s.rotateMD5KeyLocked(p, oldKey, newKey)
+	if err != nil {
+		p.logf("%v", err)
+	}
+}
+
+// capabilitiesEqual returns true if a and b contain the same capabilities,
+// regardless of order.
+func capabilitiesEqual(a, b []Capability) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ca := range a {
+		found := false
+		for _, cb := range b {
+			if ca.Equal(cb) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // DeletePeer deletes a peer from the Server.
 func (s *Server) DeletePeer(ip netip.Addr) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p, exists := s.peers[ip.String()]
+	p, exists := s.peers.get(ip)
 	if !exists {
 		return ErrPeerNotExist
 	}
 	if s.serving {
 		p.stop()
 	}
-	delete(s.peers, ip.String())
+	if len(p.listenerMD5Key()) > 0 {
+		err := s.setListenerMD5KeysLocked(p, "")
+		if err != nil {
+			p.logf("%v", err)
+		}
+	}
+	s.peers.delete(p)
 	return nil
 }
 
 // GetPeer returns the configuration for the provided peer, or an error if it
 // does not exist.
 func (s *Server) GetPeer(ip netip.Addr) (PeerConfig, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	p, exists := s.peers[ip.String()]
+	p, exists := s.peers.get(ip)
 	if !exists {
 		return PeerConfig{}, ErrPeerNotExist
 	}
@@ -255,11 +667,76 @@ func (s *Server) GetPeer(ip netip.Addr) (PeerConfig, error) {
 
 // ListPeers returns the configuration for all peers.
 func (s *Server) ListPeers() []PeerConfig {
-	s.mu.Lock()
-	defer s.mu.Unlock()
 	configs := make([]PeerConfig, 0)
-	for _, peer := range s.peers {
-		configs = append(configs, peer.config)
-	}
+	s.peers.each(func(p *peer) bool {
+		configs = append(configs, p.config)
+		return true
+	})
 	return configs
 }
+
+// GetSessionInfo returns the SessionInfo for the provided peer, or an error if
+// it does not exist or is not in the established state.
+func (s *Server) GetSessionInfo(ip netip.Addr) (SessionInfo, error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return SessionInfo{}, ErrPeerNotExist
+	}
+	info, ok := p.getSessionInfo()
+	if !ok {
+		return SessionInfo{}, ErrPeerNotEstablished
+	}
+	return info, nil
+}
+
+// SendKeepalive sends a KEEPALIVE message to the provided peer, after any
+// buffered UPDATE messages, regardless of its KeepaliveTimer. It returns an
+// error if the peer does not exist, is not established, the negotiated hold
+// time is zero, see ErrZeroHoldTime, or the write fails. It is typically used
+// along with WithKeepaliveSuppression.
+func (s *Server) SendKeepalive(ip netip.Addr) error {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return ErrPeerNotExist
+	}
+	w := p.getSessionWriter()
+	if w == nil {
+		return ErrPeerNotEstablished
+	}
+	return w.writeKeepAlive()
+}
+
+// GetPeerStats returns the PeerStats for the provided peer, or an error if it
+// does not exist.
+func (s *Server) GetPeerStats(ip netip.Addr) (PeerStats, error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return PeerStats{}, ErrPeerNotExist
+	}
+	return p.stats.snapshot(), nil
+}
+
+// AddPrefixCounts adds c to the prefix counters of family in the PeerStats of
+// the provided peer, or returns an error if it does not exist. It allows a
+// plugin applying policy to the routes received from a peer to report the
+// prefixes it accepted, rejected, and withdrew, see WithPrefixCounting.
+func (s *Server) AddPrefixCounts(ip netip.Addr, family MPExtensions,
+	c PrefixCounts) error {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return ErrPeerNotExist
+	}
+	p.stats.addPrefixCounts(family, c)
+	return nil
+}
+
+// GetLastNotifications returns the last Notifications sent to and received
+// from the provided peer, or an error if it does not exist.
+func (s *Server) GetLastNotifications(ip netip.Addr) (LastNotifications,
+	error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return LastNotifications{}, ErrPeerNotExist
+	}
+	return p.getLastNotifications(), nil
+}
@@ -28,6 +28,13 @@ func TestServer(t *testing.T) {
 	}, nil, WithLocalAddress(netip.MustParseAddr("::1")))
 	assert.Error(t, err)
 
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, nil, WithUpdateErrorPolicy(UpdateErrorPolicyIgnore+1))
+	assert.Error(t, err)
+
 	pcIPv4 := PeerConfig{
 		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
 		LocalAS:       64512,
@@ -63,8 +70,141 @@ func TestServer(t *testing.T) {
 		assert.True(t, found[1])
 	}
 
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("fe80::2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, nil)
+	assert.Error(t, err)
+	pcLinkLocal := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("fe80::2%eth0"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	err = s.AddPeer(pcLinkLocal, nil,
+		WithLocalAddress(netip.MustParseAddr("fe80::1%eth1")))
+	assert.Error(t, err)
+	err = s.AddPeer(pcLinkLocal, nil,
+		WithLocalAddress(netip.MustParseAddr("fe80::1")))
+	assert.NoError(t, err)
+	_, err = s.GetPeer(pcLinkLocal.RemoteAddress)
+	assert.NoError(t, err)
+	_, err = s.GetPeer(pcLinkLocal.RemoteAddress.WithZone("eth1"))
+	assert.ErrorIs(t, err, ErrPeerNotExist)
+	err = s.DeletePeer(pcLinkLocal.RemoteAddress)
+	assert.NoError(t, err)
+
 	err = s.DeletePeer(pcIPv4.RemoteAddress)
 	assert.NoError(t, err)
 	err = s.DeletePeer(pcIPv4.RemoteAddress)
 	assert.ErrorIs(t, err, ErrPeerNotExist)
 }
+
+func TestServer_UpdatePeer(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	assert.NoError(t, err)
+
+	pc := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	_, err = s.UpdatePeer(pc)
+	assert.ErrorIs(t, err, ErrPeerNotExist)
+
+	err = s.AddPeer(pc, nil)
+	assert.NoError(t, err)
+	_, err = s.UpdatePeer(pc, WithHoldTime(1))
+	assert.Error(t, err)
+
+	action, err := s.UpdatePeer(pc, WithHoldTime(30))
+	assert.NoError(t, err)
+	assert.Equal(t, PeerUpdateInPlace, action)
+
+	pc.RemoteAS = 64514
+	action, err = s.UpdatePeer(pc)
+	assert.NoError(t, err)
+	assert.Equal(t, PeerUpdateInPlace, action)
+	got, err := s.GetPeer(pc.RemoteAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, pc, got)
+}
+
+func TestServer_FallbackTransports(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	assert.NoError(t, err)
+
+	pc := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("::2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	err = s.AddPeer(pc, nil, WithFallbackTransports(Transport{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAddress:  netip.MustParseAddr("::1"),
+	}))
+	assert.Error(t, err)
+
+	err = s.AddPeer(pc, nil, WithFallbackTransports(Transport{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+	}))
+	assert.NoError(t, err)
+
+	// the fallback transport address belongs to the first peer
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, nil)
+	assert.ErrorIs(t, err, ErrPeerAlreadyExists)
+
+	p, _, exists := s.peers.forInbound(netip.MustParseAddr("127.0.0.2"))
+	if assert.True(t, exists) {
+		assert.Equal(t, pc, p.config)
+	}
+}
+
+func TestServer_RouterIDOverride(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	assert.NoError(t, err)
+
+	pc := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("127.0.0.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	err = s.AddPeer(pc, nil, WithRouterID(netip.MustParseAddr("::1")))
+	assert.Error(t, err)
+
+	err = s.AddPeer(pc, nil)
+	assert.NoError(t, err)
+	p, _ := s.peers.get(pc.RemoteAddress)
+	assert.Equal(t, s.id, p.id)
+
+	_, err = s.UpdatePeer(pc, WithRouterID(netip.MustParseAddr("192.0.2.1")))
+	assert.NoError(t, err)
+	p, _ = s.peers.get(pc.RemoteAddress)
+	assert.Equal(t, uint32(0xc0000201), p.id)
+}
+
+func TestNewServer_RouterID(t *testing.T) {
+	_, err := NewServer(netip.MustParseAddr("0.0.0.0"))
+	assert.Error(t, err)
+	_, err = NewServer(netip.MustParseAddr("255.255.255.254"))
+	assert.NoError(t, err)
+}
+
+// nopPlugin is a Plugin that accepts all peers and ignores all messages.
+type nopPlugin struct{}
+
+func (nopPlugin) GetCapabilities(PeerConfig) []Capability { return nil }
+
+func (nopPlugin) OnOpenMessage(PeerConfig, netip.Addr, []Capability) *Notification {
+	return nil
+}
+
+func (nopPlugin) OnEstablished(PeerConfig, UpdateMessageWriter) UpdateMessageHandler {
+	return nil
+}
+
+func (nopPlugin) OnClose(PeerConfig) {}
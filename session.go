@@ -0,0 +1,158 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"time"
+)
+
+// SessionInfo contains the parameters negotiated with a peer for an
+// established session.
+type SessionInfo struct {
+	// LocalAddress and RemoteAddress are the addresses of the transport in
+	// use for the session. They may differ from the PeerConfig when a fallback
+	// transport is in use, see WithFallbackTransports. They are the zero value
+	// if the connection's addresses are not IP addresses.
+	LocalAddress  netip.AddrPort
+	RemoteAddress netip.AddrPort
+
+	// HoldTime is the negotiated hold time. A value of zero indicates that
+	// the hold and keepalive timers are disabled, in which case the liveness
+	// of the session relies on the transport alone. TCP keepalives are
+	// enabled for such sessions, see WithTCPKeepAlive.
+	HoldTime time.Duration
+
+	// KeepaliveInterval is the interval at which KEEPALIVE messages are sent
+	// to the peer. It is zero if they are not sent automatically, i.e. if
+	// HoldTime is zero or WithKeepaliveSuppression is in use.
+	KeepaliveInterval time.Duration
+
+	// LocalRouterID is the BGP identifier sent to the peer.
+	LocalRouterID netip.Addr
+
+	// RemoteRouterID is the BGP identifier received from the peer.
+	RemoteRouterID netip.Addr
+
+	// LocalCapabilities are the capabilities sent to the peer.
+	LocalCapabilities []Capability
+
+	// RemoteCapabilities are the capabilities received from the peer.
+	RemoteCapabilities []Capability
+
+	// Families contains the AFI/SAFI tuples advertised via the Multiprotocol
+	// Extensions Capability by both sides. A side that did not advertise the
+	// capability is considered to support IPv4 unicast only.
+	Families []MPExtensions
+
+	// ExtendedMessage is true if both sides advertised the BGP Extended
+	// Message Capability.
+	ExtendedMessage bool
+
+	// AddPath contains the effective ADD-PATH directions per AFI/SAFI from the
+	// local point of view. Tx is set if we may send multiple paths, Rx is set if
+	// we may receive multiple paths. AFI/SAFI tuples with neither direction
+	// enabled are omitted.
+	AddPath []AddPathTuple
+}
+
+func addrFromRouterID(id uint32) netip.Addr {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], id)
+	return netip.AddrFrom4(b)
+}
+
+func hasCapabilityCode(caps []Capability, code uint8) bool {
+	for _, c := range caps {
+		if c.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// mpFamilies returns the families advertised in caps via the Multiprotocol
+// Extensions Capability, or IPv4 unicast in the absence of the capability.
+func mpFamilies(caps []Capability) []MPExtensions {
+	families := make([]MPExtensions, 0)
+	for _, c := range caps {
+		if c.Code != CAP_MP_EXTENSIONS {
+			continue
+		}
+		var m MPExtensions
+		if m.Decode(c.Value) == nil {
+			families = append(families, m)
+		}
+	}
+	if len(families) == 0 {
+		// https://www.rfc-editor.org/rfc/rfc4760#section-1
+		// IPv4 unicast is implied in the absence of the capability.
+		families = append(families, MPExtensions{
+			AFI:  AFI_IPV4,
+			SAFI: SAFI_UNICAST,
+		})
+	}
+	return families
+}
+
+func addPathTuples(caps []Capability) []AddPathTuple {
+	tuples := make([]AddPathTuple, 0)
+	for _, c := range caps {
+		if c.Code != CAP_ADD_PATH {
+			continue
+		}
+		t, err := DecodeAddPathTuples(c.Value)
+		if err == nil {
+			tuples = append(tuples, t...)
+		}
+	}
+	return tuples
+}
+
+func newSessionInfo(holdTime time.Duration, localID, remoteID uint32,
+	local, remote []Capability) SessionInfo {
+	s := SessionInfo{
+		HoldTime:           holdTime,
+		LocalRouterID:      addrFromRouterID(localID),
+		RemoteRouterID:     addrFromRouterID(remoteID),
+		LocalCapabilities:  local,
+		RemoteCapabilities: remote,
+		ExtendedMessage: hasCapabilityCode(local, CAP_EXTENDED_MESSSAGE) &&
+			hasCapabilityCode(remote, CAP_EXTENDED_MESSSAGE),
+		Families: make([]MPExtensions, 0),
+		AddPath:  make([]AddPathTuple, 0),
+	}
+
+	localFamilies, remoteFamilies := mpFamilies(local), mpFamilies(remote)
+	for _, l := range localFamilies {
+		for _, r := range remoteFamilies {
+			if l == r {
+				s.Families = append(s.Families, l)
+				break
+			}
+		}
+	}
+
+	remoteAddPath := addPathTuples(remote)
+	for _, l := range addPathTuples(local) {
+		for _, r := range remoteAddPath {
+			if l.AFI != r.AFI || l.SAFI != r.SAFI {
+				continue
+			}
+			// https://www.rfc-editor.org/rfc/rfc7911#section-4
+			// A BGP speaker that wants to send multiple paths MUST have its
+			// Send/Receive field set to 2 or 3 and the peer MUST have its
+			// Send/Receive field set to 1 or 3, and vice versa.
+			t := AddPathTuple{
+				AFI:  l.AFI,
+				SAFI: l.SAFI,
+				Tx:   l.Tx && r.Rx,
+				Rx:   l.Rx && r.Tx,
+			}
+			if t.Tx || t.Rx {
+				s.AddPath = append(s.AddPath, t)
+			}
+			break
+		}
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+)
+
+// DefaultSessionHistorySize is the default number of SessionRecords retained
+// for a peer.
+const DefaultSessionHistorySize = 16
+
+// SessionRecord describes a single established session with a peer.
+type SessionRecord struct {
+	// Established is the time at which the session entered the established
+	// state.
+	Established time.Time
+
+	// Down is the time at which the session left the established state. It is
+	// the zero value if the session is still established.
+	Down time.Time
+
+	// Reason describes why the session went down. It is empty if the session
+	// is still established.
+	Reason string
+
+	// Notification is the Notification sent or received when the session went
+	// down, if any. NotificationSent is true if it was sent to the peer.
+	Notification     *Notification
+	NotificationSent bool
+}
+
+// Uptime returns the duration for which the session was established, or zero
+// if it is still established.
+func (r SessionRecord) Uptime() time.Duration {
+	if r.Down.IsZero() {
+		return 0
+	}
+	return r.Down.Sub(r.Established)
+}
+
+// WithSessionHistorySize returns a PeerOption that sets the number of
+// SessionRecords retained for the peer, see Server.GetSessionHistory. The
+// oldest record is discarded once size is exceeded. A size of 0 disables
+// session history. The default is DefaultSessionHistorySize.
+func WithSessionHistorySize(size int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.sessionHistorySize = size
+	})
+}
+
+// recordSessionUp appends a SessionRecord for a newly established session.
+func (p *peer) recordSessionUp() {
+	size := p.options().sessionHistorySize
+	if size == 0 {
+		return
+	}
+	now := p.options().clock.Now()
+	p.historyMu.Lock()
+	defer p.historyMu.Unlock()
+	if len(p.history) >= size {
+		// shift rather than reslice so the backing array remains bounded
+		n := copy(p.history, p.history[len(p.history)-size+1:])
+		p.history = p.history[:n]
+	}
+	p.history = append(p.history, SessionRecord{Established: now})
+}
+
+// recordSessionDown completes the SessionRecord of the established session
+// with err, the error that caused it to go down. A nil err indicates the FSM
+// was stopped.
+func (p *peer) recordSessionDown(err error) {
+	now := p.options().clock.Now()
+	p.historyMu.Lock()
+	defer p.historyMu.Unlock()
+	if len(p.history) == 0 || !p.history[len(p.history)-1].Down.IsZero() {
+		return
+	}
+	r := &p.history[len(p.history)-1]
+	r.Down = now
+	if err == nil {
+		r.Reason = "FSM stopped"
+		return
+	}
+	r.Reason = err.Error()
+	var nerr *notificationError
+	if errors.As(err, &nerr) {
+		r.Notification = &Notification{
+			Code:    nerr.notification.Code,
+			Subcode: nerr.notification.Subcode,
+			Data:    append([]byte{}, nerr.notification.Data...),
+		}
+		r.NotificationSent = nerr.out
+	}
+}
+
+func (p *peer) getSessionHistory() []SessionRecord {
+	p.historyMu.Lock()
+	defer p.historyMu.Unlock()
+	return append([]SessionRecord{}, p.history...)
+}
+
+// GetSessionHistory returns the SessionRecords for the provided peer, oldest
+// first, or an error if it does not exist. The history spans the lifetime of
+// the peer and is bounded by WithSessionHistorySize.
+func (s *Server) GetSessionHistory(ip netip.Addr) ([]SessionRecord, error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return nil, ErrPeerNotExist
+	}
+	return p.getSessionHistory(), nil
+}
@@ -0,0 +1,63 @@
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionHistory(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := netip.MustParseAddr("192.0.2.2")
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: addr,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, nil, WithSessionHistorySize(2), WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, _ := s.peers.get(addr)
+
+	// a session down without a session up is ignored
+	p.recordSessionDown(errors.New("ignored"))
+	history, err := s.GetSessionHistory(addr)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	p.recordSessionUp()
+	p.recordSessionDown(errors.New("EOF"))
+	p.recordSessionUp()
+	n := newNotification(NOTIF_CODE_HOLD_TIMER_EXPIRED, 0, []byte{1})
+	p.recordSessionDown(newNotificationError(n, true))
+	p.recordSessionUp()
+
+	history, err = s.GetSessionHistory(addr)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.False(t, history[0].Down.IsZero())
+		assert.Equal(t, newNotificationError(n, true).Error(),
+			history[0].Reason)
+		assert.Equal(t, n, history[0].Notification)
+		assert.True(t, history[0].NotificationSent)
+		assert.True(t, history[0].Uptime() >= 0)
+		assert.True(t, history[1].Down.IsZero())
+		assert.Empty(t, history[1].Reason)
+		assert.Zero(t, history[1].Uptime())
+	}
+
+	p.recordSessionDown(nil)
+	history, _ = s.GetSessionHistory(addr)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "FSM stopped", history[1].Reason)
+		assert.Nil(t, history[1].Notification)
+	}
+
+	_, err = s.GetSessionHistory(netip.MustParseAddr("192.0.2.3"))
+	assert.ErrorIs(t, err, ErrPeerNotExist)
+}
@@ -0,0 +1,57 @@
+package corebgp
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSessionInfo(t *testing.T) {
+	local := []Capability{
+		newFourOctetASCap(64512),
+		NewMPExtensionsCapability(AFI_IPV4, SAFI_UNICAST),
+		NewMPExtensionsCapability(AFI_IPV6, SAFI_UNICAST),
+		{Code: CAP_EXTENDED_MESSSAGE},
+		NewAddPathCapability([]AddPathTuple{
+			{AFI: AFI_IPV4, SAFI: SAFI_UNICAST, Tx: true, Rx: true},
+			{AFI: AFI_IPV6, SAFI: SAFI_UNICAST, Rx: true},
+		}),
+	}
+	remote := []Capability{
+		newFourOctetASCap(64513),
+		NewMPExtensionsCapability(AFI_IPV6, SAFI_UNICAST),
+		NewAddPathCapability([]AddPathTuple{
+			{AFI: AFI_IPV4, SAFI: SAFI_UNICAST, Rx: true},
+			{AFI: AFI_IPV6, SAFI: SAFI_UNICAST, Rx: true},
+		}),
+	}
+	got := newSessionInfo(time.Second*90, 0x01020304, 0x05060708, local, remote)
+	assert.Equal(t, SessionInfo{
+		HoldTime:           time.Second * 90,
+		LocalRouterID:      netip.MustParseAddr("1.2.3.4"),
+		RemoteRouterID:     netip.MustParseAddr("5.6.7.8"),
+		LocalCapabilities:  local,
+		RemoteCapabilities: remote,
+		Families: []MPExtensions{
+			{AFI: AFI_IPV6, SAFI: SAFI_UNICAST},
+		},
+		ExtendedMessage: false,
+		AddPath: []AddPathTuple{
+			{AFI: AFI_IPV4, SAFI: SAFI_UNICAST, Tx: true},
+		},
+	}, got)
+
+	got = newSessionInfo(0, 1, 2, nil, nil)
+	assert.Equal(t, []MPExtensions{{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}},
+		got.Families)
+
+	// only one side advertises the capability, the other implies IPv4
+	// unicast
+	got = newSessionInfo(0, 1, 2, local, nil)
+	assert.Equal(t, []MPExtensions{{AFI: AFI_IPV4, SAFI: SAFI_UNICAST}},
+		got.Families)
+	got = newSessionInfo(0, 1, 2, nil, remote)
+	assert.Empty(t, got.Families)
+}
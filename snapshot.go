@@ -0,0 +1,365 @@
+package corebgp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the version of the JSON schema of Snapshot. It is
+// incremented when a change to the schema is not backwards compatible.
+const SnapshotVersion = 1
+
+// Snapshot is the state of all peers of a Server, see Server.Snapshot. It is
+// encoded in JSON with a stable schema, suitable for scraping, backups, and
+// pre-seeding the peers of another Server via LoadSnapshot and SeedPeers,
+// e.g. during a blue/green upgrade.
+type Snapshot struct {
+	Version  int            `json:"version"`
+	Time     time.Time      `json:"time"`
+	RouterID netip.Addr     `json:"router_id"`
+	Peers    []PeerSnapshot `json:"peers"`
+}
+
+// PeerSnapshot is the state of a peer.
+type PeerSnapshot struct {
+	Config PeerSnapshotConfig `json:"config"`
+
+	// State is the state of the peer's most advanced FSM, e.g.
+	// "established".
+	State string `json:"state"`
+
+	// Session is set while the peer is established.
+	Session *SessionSnapshot `json:"session,omitempty"`
+
+	Counters PeerSnapshotCounters `json:"counters"`
+}
+
+// PeerSnapshotConfig is the configuration of a peer, including the subset of
+// its PeerOptions that can be serialized. PeerOptions taking functions or
+// interfaces, e.g. WithDialer or WithCapabilityPolicy, are omitted, as are
+// TCP MD5 keys.
+type PeerSnapshotConfig struct {
+	RemoteAddress netip.Addr `json:"remote_address"`
+	LocalAS       uint32     `json:"local_as"`
+	RemoteAS      uint32     `json:"remote_as"`
+
+	// Group is the name of the PeerGroup the peer is a member of, if any.
+	Group string `json:"group,omitempty"`
+
+	LocalAddress       netip.Addr          `json:"local_address,omitempty"`
+	RouterID           netip.Addr          `json:"router_id,omitempty"`
+	Port               int                 `json:"port"`
+	TransportMode      string              `json:"transport_mode"`
+	FallbackTransports []TransportSnapshot `json:"fallback_transports,omitempty"`
+	TTL                uint8               `json:"ttl,omitempty"`
+	TOS                *uint8              `json:"tos,omitempty"`
+	Timers             TimersSnapshot      `json:"timers"`
+}
+
+// TransportSnapshot is a Transport.
+type TransportSnapshot struct {
+	RemoteAddress netip.Addr `json:"remote_address"`
+	LocalAddress  netip.Addr `json:"local_address,omitempty"`
+}
+
+// TimersSnapshot contains the configured timers of a peer in milliseconds.
+// SendHoldTime is nil if it was not set via WithSendHoldTime.
+type TimersSnapshot struct {
+	HoldTime         int64  `json:"hold_time_ms"`
+	IdleHoldTime     int64  `json:"idle_hold_time_ms"`
+	ConnectRetryTime int64  `json:"connect_retry_time_ms"`
+	SendHoldTime     *int64 `json:"send_hold_time_ms,omitempty"`
+}
+
+// SessionSnapshot is the SessionInfo of an established peer.
+type SessionSnapshot struct {
+	// Established is the time at which the session was established. It is
+	// unset if session history is disabled, see WithSessionHistorySize.
+	Established        *time.Time           `json:"established,omitempty"`
+	LocalAddress       netip.AddrPort       `json:"local_address"`
+	RemoteAddress      netip.AddrPort       `json:"remote_address"`
+	LocalRouterID      netip.Addr           `json:"local_router_id"`
+	RemoteRouterID     netip.Addr           `json:"remote_router_id"`
+	HoldTime           int64                `json:"hold_time_ms"`
+	KeepaliveInterval  int64                `json:"keepalive_interval_ms"`
+	LocalCapabilities  []CapabilitySnapshot `json:"local_capabilities"`
+	RemoteCapabilities []CapabilitySnapshot `json:"remote_capabilities"`
+	Families           []FamilySnapshot     `json:"families"`
+	ExtendedMessage    bool                 `json:"extended_message"`
+	AddPath            []AddPathSnapshot    `json:"add_path,omitempty"`
+}
+
+// CapabilitySnapshot is a Capability with its value encoded in hex.
+type CapabilitySnapshot struct {
+	Code  uint8  `json:"code"`
+	Value string `json:"value"`
+}
+
+// FamilySnapshot is an AFI/SAFI tuple.
+type FamilySnapshot struct {
+	AFI  uint16 `json:"afi"`
+	SAFI uint8  `json:"safi"`
+}
+
+// AddPathSnapshot is an AddPathTuple.
+type AddPathSnapshot struct {
+	AFI  uint16 `json:"afi"`
+	SAFI uint8  `json:"safi"`
+	Tx   bool   `json:"tx"`
+	Rx   bool   `json:"rx"`
+}
+
+// PeerSnapshotCounters contains the PeerStats of a peer along with counters
+// derived from its session history, which span the peer's lifetime bounded by
+// WithSessionHistorySize.
+type PeerSnapshotCounters struct {
+	UpdateErrorsIgnored uint64 `json:"update_errors_ignored"`
+	UpdatesRateLimited  uint64 `json:"updates_rate_limited"`
+	FamilyMismatches    uint64 `json:"family_mismatches"`
+
+	// Sessions is the number of sessions established.
+	Sessions int `json:"sessions"`
+
+	// NotificationsSent and NotificationsReceived are the number of sessions
+	// that went down due to a Notification sent or received respectively.
+	NotificationsSent     int `json:"notifications_sent"`
+	NotificationsReceived int `json:"notifications_received"`
+}
+
+// Snapshot returns the state of all peers of the Server, ordered by remote
+// address.
+func (s *Server) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := Snapshot{
+		Version:  SnapshotVersion,
+		Time:     time.Now(),
+		RouterID: addrFromRouterID(s.id),
+		Peers:    make([]PeerSnapshot, 0),
+	}
+	for _, p := range s.peers.list() {
+		snap.Peers = append(snap.Peers, p.snapshot())
+	}
+	sort.Slice(snap.Peers, func(i, j int) bool {
+		return snap.Peers[i].Config.RemoteAddress.Less(
+			snap.Peers[j].Config.RemoteAddress)
+	})
+	return snap
+}
+
+func (p *peer) snapshot() PeerSnapshot {
+	o := p.options()
+	c := PeerSnapshotConfig{
+		RemoteAddress: p.config.RemoteAddress,
+		LocalAS:       p.config.LocalAS,
+		RemoteAS:      p.config.RemoteAS,
+		Group:         p.group,
+		LocalAddress:  o.localAddress,
+		RouterID:      o.routerID,
+		Port:          o.port,
+		TransportMode: o.transportMode.String(),
+		TTL:           o.tcpOptions.ttl,
+		Timers: TimersSnapshot{
+			HoldTime:         o.holdTime.Milliseconds(),
+			IdleHoldTime:     o.idleHoldTime.Milliseconds(),
+			ConnectRetryTime: o.connectRetryTime.Milliseconds(),
+		},
+	}
+	if o.sendHoldTimeSet {
+		sendHoldTime := o.sendHoldTime.Milliseconds()
+		c.Timers.SendHoldTime = &sendHoldTime
+	}
+	if o.tcpOptions.tosSet {
+		tos := o.tcpOptions.tos
+		c.TOS = &tos
+	}
+	for _, t := range o.fallbackTransports {
+		c.FallbackTransports = append(c.FallbackTransports,
+			TransportSnapshot(t))
+	}
+
+	stats := p.stats.snapshot()
+	snap := PeerSnapshot{
+		Config: c,
+		State:  p.state().String(),
+		Counters: PeerSnapshotCounters{
+			UpdateErrorsIgnored: stats.UpdateErrorsIgnored,
+			UpdatesRateLimited:  stats.UpdatesRateLimited,
+			FamilyMismatches:    stats.FamilyMismatches,
+		},
+	}
+	history := p.getSessionHistory()
+	for _, r := range history {
+		snap.Counters.Sessions++
+		if r.Notification == nil {
+			continue
+		}
+		if r.NotificationSent {
+			snap.Counters.NotificationsSent++
+		} else {
+			snap.Counters.NotificationsReceived++
+		}
+	}
+	info, ok := p.getSessionInfo()
+	if ok {
+		snap.Session = newSessionSnapshot(info)
+		if len(history) > 0 && history[len(history)-1].Down.IsZero() {
+			established := history[len(history)-1].Established
+			snap.Session.Established = &established
+		}
+	}
+	return snap
+}
+
+func newSessionSnapshot(info SessionInfo) *SessionSnapshot {
+	s := &SessionSnapshot{
+		LocalAddress:       info.LocalAddress,
+		RemoteAddress:      info.RemoteAddress,
+		LocalRouterID:      info.LocalRouterID,
+		RemoteRouterID:     info.RemoteRouterID,
+		HoldTime:           info.HoldTime.Milliseconds(),
+		KeepaliveInterval:  info.KeepaliveInterval.Milliseconds(),
+		LocalCapabilities:  newCapabilitySnapshots(info.LocalCapabilities),
+		RemoteCapabilities: newCapabilitySnapshots(info.RemoteCapabilities),
+		Families:           make([]FamilySnapshot, 0, len(info.Families)),
+		ExtendedMessage:    info.ExtendedMessage,
+	}
+	for _, f := range info.Families {
+		s.Families = append(s.Families, FamilySnapshot(f))
+	}
+	for _, a := range info.AddPath {
+		s.AddPath = append(s.AddPath, AddPathSnapshot(a))
+	}
+	return s
+}
+
+func newCapabilitySnapshots(caps []Capability) []CapabilitySnapshot {
+	snaps := make([]CapabilitySnapshot, 0, len(caps))
+	for _, c := range caps {
+		snaps = append(snaps, CapabilitySnapshot{
+			Code:  c.Code,
+			Value: hex.EncodeToString(c.Value),
+		})
+	}
+	return snaps
+}
+
+// WriteTo writes s to w as JSON.
+func (s Snapshot) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// LoadSnapshot decodes a Snapshot from the JSON document in r. An error is
+// returned if its version is not SnapshotVersion.
+func LoadSnapshot(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	err := json.NewDecoder(r).Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version: %d", s.Version)
+	}
+	return s, nil
+}
+
+// PeerConfig returns the PeerConfig of c.
+func (c PeerSnapshotConfig) PeerConfig() PeerConfig {
+	return PeerConfig{
+		RemoteAddress: c.RemoteAddress,
+		LocalAS:       c.LocalAS,
+		RemoteAS:      c.RemoteAS,
+	}
+}
+
+// Options returns the PeerOptions of c.
+func (c PeerSnapshotConfig) Options() ([]PeerOption, error) {
+	opts := []PeerOption{
+		WithPort(c.Port),
+		newFuncPeerOption(func(o *peerOptions) {
+			o.holdTime = time.Duration(c.Timers.HoldTime) * time.Millisecond
+			o.idleHoldTime = time.Duration(c.Timers.IdleHoldTime) *
+				time.Millisecond
+			o.connectRetryTime = time.Duration(c.Timers.ConnectRetryTime) *
+				time.Millisecond
+		}),
+	}
+	if c.Timers.SendHoldTime != nil {
+		opts = append(opts, WithSendHoldTime(
+			time.Duration(*c.Timers.SendHoldTime)*time.Millisecond))
+	}
+	switch c.TransportMode {
+	case TransportModeBoth.String():
+	case TransportModePassive.String():
+		opts = append(opts, WithTransportMode(TransportModePassive))
+	case TransportModeActive.String():
+		opts = append(opts, WithTransportMode(TransportModeActive))
+	default:
+		return nil, fmt.Errorf("invalid transport mode: %s", c.TransportMode)
+	}
+	if c.LocalAddress.IsValid() {
+		opts = append(opts, WithLocalAddress(c.LocalAddress))
+	}
+	if c.RouterID.IsValid() {
+		opts = append(opts, WithRouterID(c.RouterID))
+	}
+	if len(c.FallbackTransports) > 0 {
+		transports := make([]Transport, 0, len(c.FallbackTransports))
+		for _, t := range c.FallbackTransports {
+			transports = append(transports, Transport(t))
+		}
+		opts = append(opts, WithFallbackTransports(transports...))
+	}
+	if c.TTL > 0 {
+		opts = append(opts, WithMultihop(c.TTL))
+	}
+	if c.TOS != nil {
+		opts = append(opts, WithTOS(*c.TOS))
+	}
+	return opts, nil
+}
+
+// SeedPeers adds the peers of snap that do not already exist to the Server, and
+// returns their remote addresses. Peers that were members of a PeerGroup are
+// added to the PeerGroup of the same name, which must exist, with the Options
+// of the group rather than those in snap. Other peers are added with the
+// PeerOptions of their PeerSnapshotConfig, followed by opts, and the Plugin
+// returned by newPlugin. Peers are added in order, if an error occurs the
+// returned addresses describe the peers added prior to the error.
+func (s *Server) SeedPeers(snap *Snapshot, newPlugin func(PeerConfig) Plugin,
+	opts ...PeerOption) ([]netip.Addr, error) {
+	added := make([]netip.Addr, 0)
+	for _, ps := range snap.Peers {
+		c := ps.Config
+		config := c.PeerConfig()
+		_, err := s.GetPeer(c.RemoteAddress)
+		if err == nil {
+			continue
+		}
+		if len(c.Group) > 0 {
+			err = s.AddPeerToGroup(c.Group, config)
+		} else {
+			var peerOpts []PeerOption
+			peerOpts, err = c.Options()
+			if err == nil {
+				err = s.AddPeer(config, newPlugin(config),
+					append(peerOpts, opts...)...)
+			}
+		}
+		if err != nil {
+			return added, fmt.Errorf("peer %s: %w", c.RemoteAddress, err)
+		}
+		added = append(added, c.RemoteAddress)
+	}
+	return added, nil
+}
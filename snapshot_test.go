@@ -0,0 +1,159 @@
+package corebgp
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Snapshot(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	a := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("198.51.100.2"),
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}
+	b := PeerConfig{
+		RemoteAddress: netip.MustParseAddr("198.51.100.1"),
+		LocalAS:       64512,
+		RemoteAS:      64514,
+	}
+	err = s.AddPeer(a, nil, WithHoldTime(30), WithPassive(), WithPort(1179),
+		WithLocalAddress(netip.MustParseAddr("198.51.100.254")),
+		WithMultihop(2), WithDSCP(DSCPCS6), WithFallbackTransports(Transport{
+			RemoteAddress: netip.MustParseAddr("2001:db8::2"),
+		}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = s.AddPeerGroup(PeerGroup{
+		Name:      "group",
+		NewPlugin: func(PeerConfig) Plugin { return nil },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = s.AddPeerToGroup("group", b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, _ := s.peers.get(a.RemoteAddress)
+	p.stats.familyMismatches.Add(2)
+
+	snap := s.Snapshot()
+	assert.Equal(t, SnapshotVersion, snap.Version)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), snap.RouterID)
+	if !assert.Len(t, snap.Peers, 2) {
+		return
+	}
+	assert.Equal(t, b.RemoteAddress, snap.Peers[0].Config.RemoteAddress)
+	assert.Equal(t, "group", snap.Peers[0].Config.Group)
+	got := snap.Peers[1]
+	assert.Equal(t, "disabled", got.State)
+	assert.Nil(t, got.Session)
+	assert.Equal(t, uint64(2), got.Counters.FamilyMismatches)
+	tos := dscpToTOS(DSCPCS6)
+	assert.Equal(t, PeerSnapshotConfig{
+		RemoteAddress: a.RemoteAddress,
+		LocalAS:       64512,
+		RemoteAS:      64513,
+		LocalAddress:  netip.MustParseAddr("198.51.100.254"),
+		Port:          1179,
+		TransportMode: "passive",
+		FallbackTransports: []TransportSnapshot{{
+			RemoteAddress: netip.MustParseAddr("2001:db8::2"),
+		}},
+		TTL: 2,
+		TOS: &tos,
+		Timers: TimersSnapshot{
+			HoldTime:         30000,
+			IdleHoldTime:     DefaultIdleHoldTime.Milliseconds(),
+			ConnectRetryTime: DefaultConnectRetryTime.Milliseconds(),
+		},
+	}, got.Config)
+
+	var buf bytes.Buffer
+	_, err = snap.WriteTo(&buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	loaded, err := LoadSnapshot(&buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, snap.Peers, loaded.Peers)
+	assert.True(t, snap.Time.Equal(loaded.Time))
+
+	// pre-seed another Server
+	seeded, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = seeded.SeedPeers(loaded, func(PeerConfig) Plugin { return nil })
+	assert.ErrorIs(t, err, ErrPeerGroupNotExist)
+	err = seeded.AddPeerGroup(PeerGroup{
+		Name:      "group",
+		NewPlugin: func(PeerConfig) Plugin { return nil },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	added, err := seeded.SeedPeers(loaded,
+		func(PeerConfig) Plugin { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{b.RemoteAddress, a.RemoteAddress}, added)
+	reseeded := seeded.Snapshot()
+	if assert.Len(t, reseeded.Peers, 2) {
+		for i := range snap.Peers {
+			assert.Equal(t, snap.Peers[i].Config, reseeded.Peers[i].Config)
+		}
+	}
+	added, err = seeded.SeedPeers(loaded,
+		func(PeerConfig) Plugin { return nil })
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+
+	_, err = LoadSnapshot(strings.NewReader(`{"version":0}`))
+	assert.Error(t, err)
+}
+
+func TestPeerSnapshotConfig_Options(t *testing.T) {
+	sendHoldTime := int64(3000)
+	c := PeerSnapshotConfig{
+		Port:          179,
+		TransportMode: "active",
+		RouterID:      netip.MustParseAddr("192.0.2.2"),
+		Timers: TimersSnapshot{
+			HoldTime:         9000,
+			IdleHoldTime:     1000,
+			ConnectRetryTime: 2000,
+			SendHoldTime:     &sendHoldTime,
+		},
+	}
+	opts, err := c.Options()
+	if !assert.NoError(t, err) {
+		return
+	}
+	o := defaultPeerOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	assert.Equal(t, TransportModeActive, o.transportMode)
+	assert.Equal(t, c.RouterID, o.routerID)
+	assert.Equal(t, 9*time.Second, o.holdTime)
+	assert.Equal(t, time.Second, o.idleHoldTime)
+	assert.Equal(t, 2*time.Second, o.connectRetryTime)
+	assert.Equal(t, 3*time.Second, o.sendHoldTime)
+	assert.True(t, o.sendHoldTimeSet)
+
+	c.TransportMode = "invalid"
+	_, err = c.Options()
+	assert.Error(t, err)
+}
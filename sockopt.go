@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import "errors"
+
+func (t tcpOptions) setSockOpts(fd int) error {
+	return errors.New("unsupported")
+}
+
+func setTOS(fd int, tos uint8) error {
+	return errors.New("unsupported")
+}
+
+func setTTL(fd int, ttl uint8) error {
+	return errors.New("unsupported")
+}
+
+func bindToDevice(fd int, device string) error {
+	return errors.New("unsupported")
+}
+
+func boundDevice(fd int) (string, error) {
+	return "", errors.New("unsupported")
+}
+
+func sockInfo(fd int, info *ConnInfo) {}
@@ -0,0 +1,164 @@
+package corebgp
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func (t tcpOptions) setSockOpts(fd int) error {
+	if t.keepAlive {
+		for _, opt := range []struct {
+			level, name, value int
+		}{
+			{unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, int(t.keepAliveIdle.Seconds())},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, int(t.keepAliveIntvl.Seconds())},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPCNT, t.keepAliveCount},
+		} {
+			err := unix.SetsockoptInt(fd, opt.level, opt.name, opt.value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if t.userTimeout > 0 {
+		err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT,
+			int(t.userTimeout.Milliseconds()))
+		if err != nil {
+			return err
+		}
+	}
+	if t.tosSet {
+		err := setTOS(fd, t.tos)
+		if err != nil {
+			return err
+		}
+	}
+	if t.ttl > 0 {
+		err := setTTL(fd, t.ttl)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sockFamilies returns whether the IPv4 and IPv6 socket options of fd apply
+// to its traffic. Both apply to an IPv6 socket whose local address is
+// v4-mapped or unspecified, i.e. a connection to an IPv4 peer accepted by, or
+// a listener on, a dual-stack socket.
+func sockFamilies(fd int) (v4, v6 bool, err error) {
+	sa, err := unix.Getsockname(fd)
+	if err != nil {
+		return false, false, err
+	}
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return true, false, nil
+	case *unix.SockaddrInet6:
+		addr := netip.AddrFrom16(sa.Addr)
+		return addr.Is4In6() || addr.IsUnspecified(), true, nil
+	default:
+		return false, false, errors.New("unknown socket type")
+	}
+}
+
+// setTOS sets the IP TOS (IPv4) and/or traffic class (IPv6) of fd to tos.
+func setTOS(fd int, tos uint8) error {
+	v4, v6, err := sockFamilies(fd)
+	if err != nil {
+		return err
+	}
+	if v6 {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_TCLASS,
+			int(tos))
+		if err != nil {
+			return err
+		}
+	}
+	if v4 {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_TOS, int(tos))
+	}
+	return nil
+}
+
+// setTTL sets the IP TTL (IPv4) and/or unicast hop limit (IPv6) of fd to ttl.
+func setTTL(fd int, ttl uint8) error {
+	v4, v6, err := sockFamilies(fd)
+	if err != nil {
+		return err
+	}
+	if v6 {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS,
+			int(ttl))
+		if err != nil {
+			return err
+		}
+	}
+	if v4 {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_TTL, int(ttl))
+	}
+	return nil
+}
+
+func bindToDevice(fd int, device string) error {
+	return unix.BindToDevice(fd, device)
+}
+
+func boundDevice(fd int) (string, error) {
+	return unix.GetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE)
+}
+
+// sockInfo populates the TTL and TCPInfo fields of info from fd. Fields that
+// cannot be read are left unmodified.
+func sockInfo(fd int, info *ConnInfo) {
+	v4, _, err := sockFamilies(fd)
+	if err != nil {
+		return
+	}
+	// the IPv4 options apply to IPv6 sockets carrying IPv4 traffic
+	if v4 {
+		if v, err := unix.GetsockoptInt(fd, unix.IPPROTO_IP,
+			unix.IP_TTL); err == nil {
+			info.TTL = v
+		}
+		if v, err := unix.GetsockoptInt(fd, unix.IPPROTO_IP,
+			unix.IP_MINTTL); err == nil {
+			info.MinTTL = v
+		}
+	} else {
+		if v, err := unix.GetsockoptInt(fd, unix.IPPROTO_IPV6,
+			unix.IPV6_UNICAST_HOPS); err == nil {
+			info.TTL = v
+		}
+		if v, err := unix.GetsockoptInt(fd, unix.IPPROTO_IPV6,
+			unix.IPV6_MINHOPCOUNT); err == nil {
+			info.MinTTL = v
+		}
+	}
+	t, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+	if err != nil {
+		return
+	}
+	info.TCPInfo = &TCPInfo{
+		State:        t.State,
+		RTT:          time.Duration(t.Rtt) * time.Microsecond,
+		RTTVar:       time.Duration(t.Rttvar) * time.Microsecond,
+		RTO:          time.Duration(t.Rto) * time.Microsecond,
+		SndMSS:       t.Snd_mss,
+		RcvMSS:       t.Rcv_mss,
+		PMTU:         t.Pmtu,
+		SndCwnd:      t.Snd_cwnd,
+		Unacked:      t.Unacked,
+		Lost:         t.Lost,
+		Retransmits:  t.Retransmits,
+		TotalRetrans: t.Total_retrans,
+		LastDataSent: time.Duration(t.Last_data_sent) * time.Millisecond,
+		LastDataRecv: time.Duration(t.Last_data_recv) * time.Millisecond,
+		NotSentBytes: t.Notsent_bytes,
+		BytesAcked:   t.Bytes_acked,
+	}
+}
@@ -0,0 +1,312 @@
+package corebgp
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTCPOptions_applyToConn(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp4", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	defer conn.Close()
+
+	noDelay := false
+	opts := tcpOptions{
+		keepAlive:      true,
+		keepAliveIdle:  time.Second * 7,
+		keepAliveIntvl: time.Second * 3,
+		keepAliveCount: 4,
+		userTimeout:    time.Second * 10,
+		noDelay:        &noDelay,
+		tos:            0xC0,
+		tosSet:         true,
+	}
+	err = opts.applyToConn(conn)
+	if err != nil {
+		t.Fatalf("error applying options: %v", err)
+	}
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("error getting raw conn: %v", err)
+	}
+	err = raw.Control(func(fdPtr uintptr) {
+		fd := int(fdPtr)
+		for _, want := range []struct {
+			name       string
+			level, opt int
+			value      int
+		}{
+			{"SO_KEEPALIVE", unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+			{"TCP_KEEPIDLE", unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, 7},
+			{"TCP_KEEPINTVL", unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, 3},
+			{"TCP_KEEPCNT", unix.IPPROTO_TCP, unix.TCP_KEEPCNT, 4},
+			{"TCP_USER_TIMEOUT", unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, 10000},
+			{"TCP_NODELAY", unix.IPPROTO_TCP, unix.TCP_NODELAY, 0},
+			{"IP_TOS", unix.IPPROTO_IP, unix.IP_TOS, 0xC0},
+		} {
+			got, err := unix.GetsockoptInt(fd, want.level, want.opt)
+			if err != nil {
+				t.Errorf("error getting %s: %v", want.name, err)
+				continue
+			}
+			if got != want.value {
+				t.Errorf("%s got: %d want: %d", want.name, got, want.value)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("control err: %v", err)
+	}
+}
+
+func TestTCPOptions_verifyInbound(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := net.Dial("tcp4", lis.Addr().String())
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatalf("error accepting: %v", err)
+	}
+	defer conn.Close()
+
+	err = tcpOptions{}.verifyInbound(conn)
+	if err != nil {
+		t.Fatalf("unexpected error without device: %v", err)
+	}
+	err = tcpOptions{bindToDevice: "vrf-blue"}.verifyInbound(conn)
+	if err == nil {
+		t.Fatal("connection not bound to device should fail")
+	}
+}
+
+func getsockoptTOS(t *testing.T, c syscall.Conn) int {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		t.Fatalf("error getting raw conn: %v", err)
+	}
+	var (
+		tos    int
+		getErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		tos, getErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+	})
+	if err != nil {
+		t.Fatalf("control err: %v", err)
+	}
+	if getErr != nil {
+		t.Fatalf("error getting IP_TOS: %v", getErr)
+	}
+	return tos
+}
+
+func TestDSCP(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer lis.Close()
+	err = SetListenerDSCP(lis, DSCPCS6)
+	if err != nil {
+		t.Fatalf("error setting listener DSCP: %v", err)
+	}
+	if got := getsockoptTOS(t, lis.(syscall.Conn)); got != 0xC0 {
+		t.Errorf("listener IP_TOS got: %d want: %d", got, 0xC0)
+	}
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+
+	var o peerOptions
+	WithDSCP(DSCPCS7).apply(&o)
+	dialer := &net.Dialer{Control: o.tcpOptions.dialerControl(nil)}
+	conn, err := dialer.Dial("tcp4", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	defer conn.Close()
+	if got := getsockoptTOS(t, conn.(syscall.Conn)); got != 0xE0 {
+		t.Errorf("dialed IP_TOS got: %d want: %d", got, 0xE0)
+	}
+}
+
+// listenDualStack returns a listener on a dual-stack socket, skipping t if
+// one cannot be created.
+func listenDualStack(t *testing.T) net.Listener {
+	lis, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		t.Skipf("error listening on dual-stack socket: %v", err)
+	}
+	return lis
+}
+
+// acceptIPv4 dials lis over IPv4 and returns the accepted connection.
+func acceptIPv4(t *testing.T, lis net.Listener) net.Conn {
+	port := lis.Addr().(*net.TCPAddr).Port
+	conn, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1",
+		strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	accepted, err := lis.Accept()
+	if err != nil {
+		t.Fatalf("error accepting: %v", err)
+	}
+	t.Cleanup(func() { accepted.Close() })
+	return accepted
+}
+
+func TestDSCP_dualStack(t *testing.T) {
+	lis := listenDualStack(t)
+	defer lis.Close()
+	err := SetListenerDSCP(lis, DSCPCS6)
+	if err != nil {
+		t.Fatalf("error setting listener DSCP: %v", err)
+	}
+	if got := getsockoptTOS(t, lis.(syscall.Conn)); got != 0xC0 {
+		t.Errorf("listener IP_TOS got: %d want: %d", got, 0xC0)
+	}
+
+	conn := acceptIPv4(t, lis)
+	var o peerOptions
+	WithDSCP(DSCPCS7).apply(&o)
+	err = o.tcpOptions.applyToConn(conn)
+	if err != nil {
+		t.Fatalf("error applying options: %v", err)
+	}
+	if got := getsockoptTOS(t, conn.(syscall.Conn)); got != 0xE0 {
+		t.Errorf("accepted IP_TOS got: %d want: %d", got, 0xE0)
+	}
+}
+
+func TestMultihop(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+
+	var o peerOptions
+	WithMultihop(2).apply(&o)
+	dialer := &net.Dialer{Control: o.tcpOptions.dialerControl(nil)}
+	conn, err := dialer.Dial("tcp4", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	defer conn.Close()
+	info := newConnInfo(conn, false, false)
+	readSockInfo(conn, &info)
+	if info.TTL != 2 {
+		t.Errorf("TTL got: %d want: %d", info.TTL, 2)
+	}
+}
+
+func TestMultihop_dualStack(t *testing.T) {
+	lis := listenDualStack(t)
+	defer lis.Close()
+	conn := acceptIPv4(t, lis)
+
+	var o peerOptions
+	WithMultihop(2).apply(&o)
+	err := o.tcpOptions.applyToConn(conn)
+	if err != nil {
+		t.Fatalf("error applying options: %v", err)
+	}
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatalf("error getting raw conn: %v", err)
+	}
+	var (
+		ttl    int
+		getErr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		ttl, getErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL)
+	})
+	if err != nil {
+		t.Fatalf("control err: %v", err)
+	}
+	if getErr != nil {
+		t.Fatalf("error getting IP_TTL: %v", getErr)
+	}
+	if ttl != 2 {
+		t.Errorf("IP_TTL got: %d want: %d", ttl, 2)
+	}
+	info := newConnInfo(conn, true, false)
+	readSockInfo(conn, &info)
+	if info.TTL != 2 {
+		t.Errorf("TTL got: %d want: %d", info.TTL, 2)
+	}
+}
+
+func TestReadSockInfo(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp4", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	defer conn.Close()
+
+	info := newConnInfo(conn, false, false)
+	if info.RemoteAddress.String() != lis.Addr().String() {
+		t.Errorf("got remote address %s, want %s", info.RemoteAddress,
+			lis.Addr())
+	}
+	readSockInfo(conn, &info)
+	if info.TTL == 0 {
+		t.Error("TTL not set")
+	}
+	if info.TCPInfo == nil {
+		t.Fatal("TCPInfo not set")
+	}
+	if info.TCPInfo.SndMSS == 0 {
+		t.Error("TCPInfo.SndMSS not set")
+	}
+}
@@ -0,0 +1,115 @@
+package corebgp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// PeerStats contains counters for a peer. Counters accumulate across sessions
+// for the lifetime of the peer.
+type PeerStats struct {
+	// UpdateErrorsIgnored is the number of Notifications returned by an
+	// UpdateMessageHandler that were ignored per UpdateErrorPolicyIgnore.
+	UpdateErrorsIgnored uint64
+
+	// UpdatesRateLimited is the number of UPDATE messages received in excess
+	// of the peer's UpdateRateLimit with UpdateRateLimitLog.
+	UpdatesRateLimited uint64
+
+	// FamilyMismatches is the number of UPDATE messages received carrying
+	// routes of an AFI/SAFI that was not negotiated, see
+	// WithFamilyMismatchPolicy.
+	FamilyMismatches uint64
+
+	// Prefixes contains the prefix counters of each AFI/SAFI for which
+	// prefixes have been counted, ordered by AFI and then SAFI. They are fed
+	// by WithPrefixCounting or Server.AddPrefixCounts.
+	Prefixes []FamilyPrefixCounts
+}
+
+// PrefixCounts contains counters of the prefixes received from a peer. When
+// ADD-PATH is in use each path is counted separately.
+type PrefixCounts struct {
+	// Accepted is the number of prefixes announced by the peer that were
+	// accepted.
+	Accepted uint64
+
+	// Rejected is the number of prefixes announced by the peer that were
+	// rejected, e.g. by policy.
+	Rejected uint64
+
+	// Withdrawn is the number of prefixes withdrawn by the peer.
+	Withdrawn uint64
+}
+
+func (c *PrefixCounts) add(o PrefixCounts) {
+	c.Accepted += o.Accepted
+	c.Rejected += o.Rejected
+	c.Withdrawn += o.Withdrawn
+}
+
+// FamilyPrefixCounts contains the PrefixCounts of an AFI/SAFI.
+type FamilyPrefixCounts struct {
+	Family MPExtensions
+	PrefixCounts
+}
+
+type peerStats struct {
+	updateErrorsIgnored atomic.Uint64
+	updatesRateLimited  atomic.Uint64
+	familyMismatches    atomic.Uint64
+
+	prefixesMu sync.Mutex
+	prefixes   map[MPExtensions]*PrefixCounts
+}
+
+// addPrefixCounts adds c to the prefix counters of family.
+func (p *peerStats) addPrefixCounts(family MPExtensions, c PrefixCounts) {
+	if c == (PrefixCounts{}) {
+		return
+	}
+	p.prefixesMu.Lock()
+	defer p.prefixesMu.Unlock()
+	counts, ok := p.prefixes[family]
+	if !ok {
+		if p.prefixes == nil {
+			p.prefixes = make(map[MPExtensions]*PrefixCounts)
+		}
+		counts = &PrefixCounts{}
+		p.prefixes[family] = counts
+	}
+	counts.add(c)
+}
+
+func (p *peerStats) snapshotPrefixes() []FamilyPrefixCounts {
+	p.prefixesMu.Lock()
+	defer p.prefixesMu.Unlock()
+	if len(p.prefixes) == 0 {
+		return nil
+	}
+	prefixes := make([]FamilyPrefixCounts, 0, len(p.prefixes))
+	for family, counts := range p.prefixes {
+		prefixes = append(prefixes, FamilyPrefixCounts{
+			Family:       family,
+			PrefixCounts: *counts,
+		})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i].Family, prefixes[j].Family
+		if a.AFI != b.AFI {
+			return a.AFI < b.AFI
+		}
+		return a.SAFI < b.SAFI
+	})
+	return prefixes
+}
+
+func (p *peerStats) snapshot() PeerStats {
+	return PeerStats{
+		UpdateErrorsIgnored: p.updateErrorsIgnored.Load(),
+		UpdatesRateLimited:  p.updatesRateLimited.Load(),
+		FamilyMismatches:    p.familyMismatches.Load(),
+		Prefixes:            p.snapshotPrefixes(),
+	}
+}
@@ -0,0 +1,185 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrReadIdle is wrapped by the error a session is closed with when
+	// nothing was received from the peer within StuckPeerDetection.ReadIdle.
+	ErrReadIdle = errors.New("read idle time exceeded")
+	// ErrWriteStalled is wrapped by the error a session is closed with when
+	// data owed to the peer made no progress within
+	// StuckPeerDetection.WriteStall.
+	ErrWriteStalled = errors.New("write stalled")
+)
+
+// StuckPeerDetection configures the detection of a peer that keeps its
+// transport connection alive while the session stops making progress, see
+// WithStuckPeerDetection. A zero threshold disables the respective check.
+type StuckPeerDetection struct {
+	// ReadIdle is the maximum duration the local system waits on the
+	// connection without receiving a complete message. Unlike the hold timer
+	// it applies regardless of the negotiated hold time, which may be 0, and
+	// it does not include time spent handling previously received messages.
+	ReadIdle time.Duration
+
+	// WriteStall is the maximum duration data owed to the peer, i.e. data
+	// being written to, or held unsent or unacknowledged by, the connection,
+	// may make no progress, e.g. because the peer advertises a zero receive
+	// window. Unlike the send hold timer, which bounds each individual write,
+	// it also detects a wedged connection while no write is blocked. Outside
+	// of Linux only writes in progress are considered.
+	WriteStall time.Duration
+
+	// Interval is how often the thresholds are checked. It defaults to a
+	// quarter of the smallest non-zero threshold.
+	Interval time.Duration
+}
+
+func (s StuckPeerDetection) enabled() bool {
+	return s.ReadIdle > 0 || s.WriteStall > 0
+}
+
+func (s StuckPeerDetection) validate() error {
+	if s.ReadIdle < 0 || s.WriteStall < 0 || s.Interval < 0 {
+		return errors.New("stuck peer detection durations must be >= 0")
+	}
+	return nil
+}
+
+func (s StuckPeerDetection) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	d := s.ReadIdle
+	if d == 0 || (s.WriteStall > 0 && s.WriteStall < d) {
+		d = s.WriteStall
+	}
+	return d / 4
+}
+
+// WithStuckPeerDetection returns a PeerOption that enables the detection of a
+// stuck peer during the Established state, which is disabled by default. The
+// hold timer is reset by any message received and so does not catch a peer
+// that keeps sending KEEPALIVE messages while it stops reading, and the send
+// hold timer only bounds writes that are in progress. If a threshold of s is
+// exceeded the session is closed with an error wrapping ErrReadIdle or
+// ErrWriteStalled, which is reflected in the Reason of its SessionRecord.
+func WithStuckPeerDetection(s StuckPeerDetection) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.stuckPeerDetection = s
+	})
+}
+
+// connProgress tracks the progress of reads from and writes to a connection.
+type connProgress struct {
+	// readingSince is the time in unix nanoseconds at which the reader began
+	// waiting on the connection for a message, 0 if it is not waiting.
+	readingSince atomic.Int64
+	// writing is the number of writes in progress.
+	writing atomic.Int32
+	// written is the number of completed writes.
+	written atomic.Uint64
+}
+
+func (c *connProgress) readStarted(now time.Time) {
+	c.readingSince.Store(now.UnixNano())
+}
+
+func (c *connProgress) readDone() {
+	c.readingSince.Store(0)
+}
+
+// writeStarted and writeDone bracket a write to the connection. They are
+// no-ops on a nil *connProgress.
+func (c *connProgress) writeStarted() {
+	if c != nil {
+		c.writing.Add(1)
+	}
+}
+
+func (c *connProgress) writeDone() {
+	if c != nil {
+		c.written.Add(1)
+		c.writing.Add(-1)
+	}
+}
+
+// stuckPeerDetector checks a connProgress against StuckPeerDetection
+// thresholds.
+type stuckPeerDetector struct {
+	config   StuckPeerDetection
+	progress *connProgress
+
+	lastProgress uint64
+	stalledSince time.Time
+}
+
+// check returns a non-nil error if a threshold is exceeded at now. info holds
+// the socket statistics of the connection, if available.
+func (s *stuckPeerDetector) check(now time.Time, info *TCPInfo) error {
+	if s.config.ReadIdle > 0 {
+		since := s.progress.readingSince.Load()
+		if since != 0 {
+			idle := now.Sub(time.Unix(0, since))
+			if idle >= s.config.ReadIdle {
+				return fmt.Errorf("%w: no message received for %s",
+					ErrReadIdle, idle)
+			}
+		}
+	}
+	if s.config.WriteStall == 0 {
+		return nil
+	}
+	owed := s.progress.writing.Load() > 0
+	progress := s.progress.written.Load()
+	if info != nil {
+		owed = owed || info.NotSentBytes > 0 || info.Unacked > 0
+		progress += info.BytesAcked
+	}
+	if !owed || progress != s.lastProgress || s.stalledSince.IsZero() {
+		s.lastProgress = progress
+		s.stalledSince = time.Time{}
+		if owed {
+			s.stalledSince = now
+		}
+		return nil
+	}
+	stalled := now.Sub(s.stalledSince)
+	if stalled >= s.config.WriteStall {
+		return fmt.Errorf("%w: no progress writing to peer for %s",
+			ErrWriteStalled, stalled)
+	}
+	return nil
+}
+
+// run checks the thresholds every interval until closeCh is closed, sending
+// the first error to errCh.
+func (s *stuckPeerDetector) run(clock Clock, conn net.Conn,
+	errCh chan<- error, closeCh <-chan struct{}) {
+	interval := s.config.interval()
+	timer := clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-closeCh:
+			return
+		case now := <-timer.C():
+			var info ConnInfo
+			readSockInfo(conn, &info)
+			if err := s.check(now, info.TCPInfo); err != nil {
+				select {
+				case errCh <- err:
+				case <-closeCh:
+				}
+				return
+			}
+			timer.Reset(interval)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package corebgp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStuckPeerDetectorReadIdle(t *testing.T) {
+	progress := &connProgress{}
+	d := &stuckPeerDetector{
+		config:   StuckPeerDetection{ReadIdle: time.Minute},
+		progress: progress,
+	}
+	start := time.Unix(1000, 0)
+	assert.NoError(t, d.check(start.Add(time.Hour), nil))
+
+	progress.readStarted(start)
+	assert.NoError(t, d.check(start.Add(time.Second*59), nil))
+	err := d.check(start.Add(time.Minute), nil)
+	assert.True(t, errors.Is(err, ErrReadIdle))
+
+	// time spent handling a received message is not counted
+	progress.readDone()
+	assert.NoError(t, d.check(start.Add(time.Hour), nil))
+}
+
+func TestStuckPeerDetectorWriteStall(t *testing.T) {
+	progress := &connProgress{}
+	d := &stuckPeerDetector{
+		config:   StuckPeerDetection{WriteStall: time.Minute},
+		progress: progress,
+	}
+	now := time.Unix(1000, 0)
+	tick := func(info *TCPInfo) error {
+		now = now.Add(time.Second * 30)
+		return d.check(now, info)
+	}
+
+	// nothing is owed
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, tick(nil))
+	}
+
+	// a blocked write
+	progress.writeStarted()
+	assert.NoError(t, tick(nil))
+	assert.NoError(t, tick(nil))
+	assert.True(t, errors.Is(tick(nil), ErrWriteStalled))
+	progress.writeDone()
+	assert.NoError(t, tick(nil))
+
+	// unsent data in the socket with acknowledgements progressing
+	info := &TCPInfo{NotSentBytes: 100, BytesAcked: 10}
+	for i := 0; i < 4; i++ {
+		info.BytesAcked += 10
+		assert.NoError(t, tick(info))
+	}
+	// a zero window, acknowledgements stop
+	assert.NoError(t, tick(info))
+	assert.True(t, errors.Is(tick(info), ErrWriteStalled))
+}
+
+func TestStuckPeerDetectionInterval(t *testing.T) {
+	assert.Equal(t, time.Second*15, StuckPeerDetection{
+		ReadIdle: time.Minute}.interval())
+	assert.Equal(t, time.Second*15, StuckPeerDetection{
+		ReadIdle: time.Minute * 2, WriteStall: time.Minute}.interval())
+	assert.Equal(t, time.Second, StuckPeerDetection{
+		WriteStall: time.Minute, Interval: time.Second}.interval())
+	assert.Error(t, StuckPeerDetection{ReadIdle: -1}.validate())
+}
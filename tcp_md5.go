@@ -0,0 +1,282 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// md5ListenerAlternateInterval is the interval at which the TCP MD5 key set on
+// the listeners for a peer alternates between its current and previous key
+// following a rotation, see Server.SetTCPMD5Key.
+const md5ListenerAlternateInterval = time.Second * 15
+
+// WithTCPMD5Key returns a PeerOption that enables TCP MD5 signatures with key
+// for a peer. The key is set on the sockets of outbound connections before they
+// are connected, and on the listeners provided to Server.Serve for all of the
+// peer's transport addresses. Listeners must implement syscall.Conn, e.g.
+// *net.TCPListener. This is only supported on Linux.
+//
+// The key may be rotated at runtime with Server.SetTCPMD5Key.
+//
+// https://tools.ietf.org/html/rfc2385
+func WithTCPMD5Key(key string) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.md5Key = key
+	})
+}
+
+// SetTCPMD5Key rotates the TCP MD5 key for the provided peer, see
+// WithTCPMD5Key. An empty key disables TCP MD5 signatures.
+//
+// The new key is set on the listeners immediately and applies to future
+// inbound connections. An established session is unaffected as its socket
+// retains the key it was created with. Until a session is established using
+// the new key, outbound connection attempts alternate between the new and
+// previous key, and the key set on the listeners alternates between them
+// every 15 seconds, so that the peer remains reachable while the remote side
+// is reconfigured. A listener socket holds a single key per remote address,
+// so inbound connections using the previous key are only accepted while it is
+// set.
+func (s *Server) SetTCPMD5Key(ip netip.Addr, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return ErrPeerNotExist
+	}
+	o := *p.options()
+	oldKey := o.md5Key
+	o.md5Key = key
+	p.opts.Store(&o)
+	return s.rotateMD5KeyLocked(p, oldKey, key)
+}
+
+// rotateMD5KeyLocked applies newKey to listeners for p's transports and
+// records oldKey as the previous key for outbound connection attempts and
+// listeners, see alternateListenerMD5Key. It must be called with s.mu held.
+func (s *Server) rotateMD5KeyLocked(p *peer, oldKey, newKey string) error {
+	if oldKey == newKey {
+		return nil
+	}
+	p.md5Mu.Lock()
+	p.md5PrevKey = oldKey
+	p.md5DialCount = 0
+	p.md5ListenerKey = newKey
+	p.md5Rotations++
+	rotation := p.md5Rotations
+	p.md5Mu.Unlock()
+	err := s.setListenerMD5KeysLocked(p, newKey)
+	if err != nil {
+		return err
+	}
+	if len(oldKey) > 0 {
+		go s.alternateListenerMD5Key(p, rotation)
+	}
+	return nil
+}
+
+// alternateListenerMD5Key alternates the TCP MD5 key set on the listeners for
+// p's transports between its current and previous key, so that inbound
+// connections using either are accepted in turn. It returns once the previous
+// key is discarded, the key is rotated again, or p is stopped or replaced.
+func (s *Server) alternateListenerMD5Key(p *peer, rotation uint64) {
+	timer := p.options().clock.NewTimer(s.md5AlternateInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.closeCh:
+			return
+		case <-s.closeCh:
+			return
+		case <-timer.C():
+		}
+		if !s.swapListenerMD5Key(p, rotation) {
+			return
+		}
+		timer.Reset(s.md5AlternateInterval)
+	}
+}
+
+// swapListenerMD5Key sets the key not currently set on the listeners for p's
+// transports, or the current key once the previous key has been discarded. It
+// returns false if alternation should stop.
+func (s *Server) swapListenerMD5Key(p *peer, rotation uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, exists := s.peers.get(p.config.RemoteAddress); !exists ||
+		current != p {
+		return false
+	}
+	key := p.options().md5Key
+	p.md5Mu.Lock()
+	if p.md5Rotations != rotation {
+		p.md5Mu.Unlock()
+		return false
+	}
+	alternate := len(p.md5PrevKey) > 0 && p.md5PrevKey != key
+	next := key
+	if alternate && p.md5ListenerKey == key {
+		next = p.md5PrevKey
+	}
+	changed := next != p.md5ListenerKey
+	p.md5ListenerKey = next
+	p.md5Mu.Unlock()
+	if changed {
+		err := s.setListenerMD5KeysLocked(p, next)
+		if err != nil {
+			p.logf("%v", err)
+		}
+	}
+	return alternate
+}
+
+// listenerMD5Key returns the TCP MD5 key currently set on the listeners for
+// p's transports.
+func (p *peer) listenerMD5Key() string {
+	p.md5Mu.Lock()
+	defer p.md5Mu.Unlock()
+	return p.md5ListenerKey
+}
+
+// setListenerMD5KeysLocked sets key on the Server's listeners for each of p's
+// transport addresses. It must be called with s.mu held.
+func (s *Server) setListenerMD5KeysLocked(p *peer, key string) error {
+	prefixes := make([]netip.Prefix, 0)
+	for _, t := range p.transports() {
+		prefixes = append(prefixes, netip.PrefixFrom(t.RemoteAddress,
+			int(md5PrefixLen(t.RemoteAddress))))
+	}
+	return s.setListenerMD5PrefixesLocked(prefixes, key)
+}
+
+// setListenerMD5PrefixesLocked sets key on the Server's listeners for each of
+// prefixes. It must be called with s.mu held.
+func (s *Server) setListenerMD5PrefixesLocked(prefixes []netip.Prefix,
+	key string) error {
+	for _, lis := range s.listeners {
+		sc, ok := lis.(syscall.Conn)
+		if !ok {
+			continue
+		}
+		tcpAddr, ok := lis.Addr().(*net.TCPAddr)
+		if !ok {
+			continue
+		}
+		lisIs4 := tcpAddr.IP.To4() != nil
+		rc, err := sc.SyscallConn()
+		if err != nil {
+			return err
+		}
+		for _, prefix := range prefixes {
+			if lisIs4 && !prefix.Addr().Is4() {
+				// an AF_INET socket cannot hold a key for an IPv6 address
+				continue
+			}
+			var setErr error
+			err = rc.Control(func(fd uintptr) {
+				setErr = SetTCPMD5Signature(int(fd), prefix.Addr(),
+					uint8(prefix.Bits()), key)
+			})
+			if err == nil {
+				err = setErr
+			}
+			if err != nil {
+				return fmt.Errorf("error setting tcp md5 key on listener %s: %w",
+					lis.Addr(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetListenerTCPMD5Key sets a TCP MD5 key on the Server's listeners for all
+// remote addresses within prefix, allowing authenticated connections from
+// peers in a range rather than from individually configured peers, e.g. when
+// peers are added dynamically upon connecting. An empty key removes the key
+// for prefix. Keys set for more specific prefixes, including those of
+// configured peers, take precedence. Prefix lengths are ignored on kernels <
+// 4.13. Keys are retained and applied to the listeners provided to any
+// subsequent call to Serve. This is only supported on Linux.
+//
+// TCP-AO (RFC5925) keys are not supported.
+func (s *Server) SetListenerTCPMD5Key(prefix netip.Prefix, key string) error {
+	if !prefix.IsValid() {
+		return errors.New("invalid prefix")
+	}
+	prefix = prefix.Masked()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.setListenerMD5PrefixesLocked([]netip.Prefix{prefix}, key)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		delete(s.listenerMD5Keys, prefix)
+	} else {
+		s.listenerMD5Keys[prefix] = key
+	}
+	return nil
+}
+
+func md5PrefixLen(addr netip.Addr) uint8 {
+	if addr.Is4() {
+		return 32
+	}
+	return 128
+}
+
+// nextDialMD5Key returns the TCP MD5 key to use for the next outbound
+// connection attempt. While a previous key is retained, attempts alternate
+// between the current and previous keys.
+func (p *peer) nextDialMD5Key() string {
+	key := p.options().md5Key
+	p.md5Mu.Lock()
+	defer p.md5Mu.Unlock()
+	if len(p.md5PrevKey) == 0 || p.md5PrevKey == key {
+		return key
+	}
+	p.md5DialCount++
+	if p.md5DialCount%2 == 0 {
+		return p.md5PrevKey
+	}
+	return key
+}
+
+// md5Established is called when a session is established. usedKey is the TCP
+// MD5 key of the session's connection. The previous key is discarded once a
+// session is established with the current key, after which the current key is
+// restored on the listeners by alternateListenerMD5Key.
+func (p *peer) md5Established(usedKey string) {
+	p.md5Mu.Lock()
+	defer p.md5Mu.Unlock()
+	if usedKey == p.options().md5Key {
+		p.md5PrevKey = ""
+		p.md5DialCount = 0
+	}
+}
+
+func md5DialerControl(next func(network, address string,
+	c syscall.RawConn) error, remote netip.Addr,
+	key string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var setErr error
+		err := c.Control(func(fd uintptr) {
+			setErr = SetTCPMD5Signature(int(fd), remote, md5PrefixLen(remote),
+				key)
+		})
+		if err != nil {
+			return err
+		}
+		if setErr != nil {
+			return fmt.Errorf("error setting tcp md5 key: %w", setErr)
+		}
+		if next != nil {
+			return next(network, address, c)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package corebgp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SetTCPMD5Key(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	s.md5AlternateInterval = time.Millisecond * 500
+	remote := netip.MustParseAddr("127.0.0.2")
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: remote,
+		LocalAS:       64512,
+		RemoteAS:      64513,
+	}, nopPlugin{}, WithPassive(), WithTCPMD5Key("old"))
+	if err != nil {
+		t.Fatalf("error adding peer: %v", err)
+	}
+	go s.Serve([]net.Listener{lis})
+	defer s.Close()
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.serving
+	}, time.Second, time.Millisecond*10)
+
+	dial := func(key string) error {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Millisecond*200)
+		defer cancel()
+		d := &net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: remote.AsSlice()},
+			Control:   md5DialerControl(nil, netip.MustParseAddr("127.0.0.1"), key),
+		}
+		conn, err := d.DialContext(ctx, "tcp", lis.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	assert.NoError(t, dial("old"))
+
+	err = s.SetTCPMD5Key(remote, "new")
+	assert.NoError(t, err)
+	assert.NoError(t, dial("new"))
+	assert.Error(t, dial("old"))
+
+	// the listeners alternate between the keys until a session is
+	// established with the new key
+	assert.Eventually(t, func() bool {
+		return dial("old") == nil
+	}, time.Second*5, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		return dial("new") == nil
+	}, time.Second*5, time.Millisecond*10)
+	p, _ := s.peers.get(remote)
+	p.md5Established("new")
+	assert.Eventually(t, func() bool {
+		return p.listenerMD5Key() == "new"
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(s.md5AlternateInterval * 3)
+	assert.Equal(t, "new", p.listenerMD5Key())
+	assert.Error(t, dial("old"))
+	assert.NoError(t, dial("new"))
+	assert.ErrorIs(t, s.SetTCPMD5Key(netip.MustParseAddr("127.0.0.3"), "new"),
+		ErrPeerNotExist)
+}
+
+func TestServer_SetListenerTCPMD5Key(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	s, err := NewServer(netip.MustParseAddr("127.0.0.1"))
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	// set prior to serving, applied by Serve
+	err = s.SetListenerTCPMD5Key(netip.MustParsePrefix("127.0.0.0/24"),
+		"range")
+	assert.NoError(t, err)
+	go s.Serve([]net.Listener{lis})
+	defer s.Close()
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.serving
+	}, time.Second, time.Millisecond*10)
+
+	dial := func(src, key string) error {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			time.Millisecond*200)
+		defer cancel()
+		d := &net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: net.ParseIP(src)},
+			Control: md5DialerControl(nil, netip.MustParseAddr("127.0.0.1"),
+				key),
+		}
+		conn, err := d.DialContext(ctx, "tcp", lis.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	assert.NoError(t, dial("127.0.0.5", "range"))
+	assert.Error(t, dial("127.0.0.6", "wrong"))
+
+	err = s.SetListenerTCPMD5Key(netip.MustParsePrefix("127.0.0.0/24"), "")
+	assert.NoError(t, err)
+	assert.Len(t, s.listenerMD5Keys, 0)
+}
@@ -0,0 +1,61 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeer_nextDialMD5Key(t *testing.T) {
+	o := defaultPeerOptions()
+	o.md5Key = "new"
+	p := newPeer(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+	}, 1, nil, o)
+	assert.Equal(t, "new", p.nextDialMD5Key())
+	assert.Equal(t, "new", p.nextDialMD5Key())
+
+	// attempts alternate while the previous key is retained
+	p.md5PrevKey = "old"
+	assert.Equal(t, "new", p.nextDialMD5Key())
+	assert.Equal(t, "old", p.nextDialMD5Key())
+	assert.Equal(t, "new", p.nextDialMD5Key())
+
+	// establishing with the previous key retains it
+	p.md5Established("old")
+	assert.Equal(t, "old", p.nextDialMD5Key())
+
+	// establishing with the current key discards it
+	p.md5Established("new")
+	assert.Equal(t, "new", p.nextDialMD5Key())
+	assert.Equal(t, "new", p.nextDialMD5Key())
+}
+
+func TestFSM_md5Key(t *testing.T) {
+	o := defaultPeerOptions()
+	o.md5Key = "new"
+	p := newPeer(PeerConfig{
+		RemoteAddress: netip.MustParseAddr("192.0.2.2"),
+	}, 1, nil, o)
+	p.md5PrevKey = "old"
+	f := newFSM(p, out, nil)
+	assert.Equal(t, "new", f.dialMD5Key())
+
+	// keys are not recorded for connections of a DialFunc, which does not
+	// set them, nor do its attempts advance the alternation
+	o.dialFn = func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("unused")
+	}
+	p.opts.Store(&o)
+	assert.Equal(t, "", f.dialMD5Key())
+	assert.Equal(t, "", f.dialMD5Key())
+	assert.Equal(t, "old", p.nextDialMD5Key())
+
+	// inbound connections use the key set on the listeners when accepted
+	p.md5ListenerKey = "old"
+	assert.Equal(t, "old", newFSM(p, in, nil).md5Key)
+}
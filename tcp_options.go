@@ -0,0 +1,294 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// tcpOptions are socket options applied to both outbound (dialed) and inbound
+// (accepted) connections for a peer.
+type tcpOptions struct {
+	keepAlive      bool
+	keepAliveIdle  time.Duration
+	keepAliveIntvl time.Duration
+	keepAliveCount int
+	userTimeout    time.Duration
+	noDelay        *bool
+	bindToDevice   string
+	tos            uint8
+	tosSet         bool
+	ttl            uint8
+}
+
+func (t tcpOptions) validate() error {
+	if t.keepAlive && (t.keepAliveIdle < time.Second ||
+		t.keepAliveIntvl < time.Second || t.keepAliveCount < 1) {
+		return errors.New("tcp keepalive idle and interval must be >= 1 second and count >= 1")
+	}
+	if t.userTimeout < 0 {
+		return errors.New("tcp user timeout must be >= 0")
+	}
+	return nil
+}
+
+// needsRawConn returns true if any of the options must be applied at the
+// socket level.
+func (t tcpOptions) needsRawConn() bool {
+	return t.keepAlive || t.userTimeout > 0 || t.tosSet || t.ttl > 0
+}
+
+// applyToConn applies the options that are set post-connection to conn.
+func (t tcpOptions) applyToConn(conn net.Conn) error {
+	if t.noDelay != nil {
+		tc, ok := conn.(*net.TCPConn)
+		if !ok {
+			return errors.New("tcp no delay requires a *net.TCPConn")
+		}
+		err := tc.SetNoDelay(*t.noDelay)
+		if err != nil {
+			return err
+		}
+	}
+	if !t.needsRawConn() {
+		return nil
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("socket options require a syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		sockErr = t.setSockOpts(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// verifyInbound returns an error if conn does not satisfy the options that
+// constrain inbound connections.
+func (t tcpOptions) verifyInbound(conn net.Conn) error {
+	if len(t.bindToDevice) == 0 {
+		return nil
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("bind to device requires a syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var (
+		device  string
+		sockErr error
+	)
+	err = rc.Control(func(fd uintptr) {
+		device, sockErr = boundDevice(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return sockErr
+	}
+	if device != t.bindToDevice {
+		return fmt.Errorf("connection bound to device %q, want %q", device,
+			t.bindToDevice)
+	}
+	return nil
+}
+
+// dialerControl returns a net.Dialer Control function applying options that
+// must be set prior to connecting, and then calling next if non-nil. The TOS
+// and TTL are set prior to connecting so that they apply to the SYN.
+func (t tcpOptions) dialerControl(next func(network, address string,
+	c syscall.RawConn) error) func(network, address string,
+	c syscall.RawConn) error {
+	if len(t.bindToDevice) == 0 && !t.tosSet && t.ttl == 0 {
+		return next
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if len(t.bindToDevice) > 0 {
+				sockErr = bindToDevice(int(fd), t.bindToDevice)
+				if sockErr != nil {
+					return
+				}
+			}
+			if t.tosSet {
+				sockErr = setTOS(int(fd), t.tos)
+				if sockErr != nil {
+					return
+				}
+			}
+			if t.ttl > 0 {
+				sockErr = setTTL(int(fd), t.ttl)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if sockErr != nil {
+			return sockErr
+		}
+		if next != nil {
+			return next(network, address, c)
+		}
+		return nil
+	}
+}
+
+// WithTCPKeepAlive returns a PeerOption that enables TCP keepalives with the
+// provided idle time, probe interval, and probe count. This is only supported
+// on Linux. If it is not set, TCP keepalives are enabled with a period of 15
+// seconds for sessions with a negotiated hold time of zero.
+func WithTCPKeepAlive(idle, interval time.Duration, count int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.keepAlive = true
+		o.tcpOptions.keepAliveIdle = idle
+		o.tcpOptions.keepAliveIntvl = interval
+		o.tcpOptions.keepAliveCount = count
+	})
+}
+
+// zeroHoldTimeKeepAlive is the TCP keepalive period of a session with a
+// negotiated hold time of zero, see enableZeroHoldTimeKeepAlive.
+const zeroHoldTimeKeepAlive = time.Second * 15
+
+// enableZeroHoldTimeKeepAlive enables TCP keepalives on conn, the connection
+// of a session with a negotiated hold time of zero, whose liveness would
+// otherwise go undetected as neither KEEPALIVE messages nor the hold timer
+// are in use. It is a no-op if keepalives are configured via WithTCPKeepAlive
+// or conn is not a *net.TCPConn.
+func (t tcpOptions) enableZeroHoldTimeKeepAlive(conn net.Conn) error {
+	tc, ok := conn.(*net.TCPConn)
+	if t.keepAlive || !ok {
+		return nil
+	}
+	err := tc.SetKeepAlive(true)
+	if err != nil {
+		return err
+	}
+	return tc.SetKeepAlivePeriod(zeroHoldTimeKeepAlive)
+}
+
+// WithTCPUserTimeout returns a PeerOption that sets TCP_USER_TIMEOUT, the
+// maximum amount of time transmitted data may remain unacknowledged before
+// the connection is forcibly closed. This is only supported on Linux.
+//
+// https://www.rfc-editor.org/rfc/rfc5482
+func WithTCPUserTimeout(t time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.userTimeout = t
+	})
+}
+
+// WithTCPNoDelay returns a PeerOption that sets TCP_NODELAY. The Go runtime
+// enables TCP_NODELAY by default.
+func WithTCPNoDelay(noDelay bool) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.noDelay = &noDelay
+	})
+}
+
+// WithBindToDevice returns a PeerOption that binds outbound connections to the
+// provided network device via SO_BINDTODEVICE, and requires inbound
+// connections to be bound to the same device. On Linux the device may be a VRF
+// master device, allowing a single Server to peer within multiple VRFs.
+// Inbound connections are bound to a VRF by listening on a socket bound to the
+// VRF device, or via the net.ipv4.tcp_l3mdev_accept sysctl. This is only
+// supported on Linux.
+//
+// Peers are identified by their remote address, so remote addresses must be
+// unique across all VRFs handled by a Server.
+func WithBindToDevice(device string) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.bindToDevice = device
+	})
+}
+
+// WithTOS returns a PeerOption that sets the IP TOS (IPv4) or traffic class
+// (IPv6) on a peer's connections. Both are set on IPv6 sockets carrying IPv4
+// traffic, e.g. connections accepted by a dual-stack listener. This is only
+// supported on Linux.
+func WithTOS(tos uint8) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.tos = tos
+		o.tcpOptions.tosSet = true
+	})
+}
+
+// WithMultihop returns a PeerOption that sets the IP TTL (IPv4) or unicast hop
+// limit (IPv6) of packets sent on a peer's connections to ttl, i.e. eBGP
+// multihop, allowing them to reach a peer up to ttl hops away. Only the
+// outbound TTL is set, the TTL of received packets is not checked. A ttl of 0,
+// the default, leaves the system default in place. Outbound connections are
+// configured prior to connecting, inbound connections once accepted. This is
+// only supported on Linux.
+func WithMultihop(ttl uint8) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpOptions.ttl = ttl
+	})
+}
+
+// DSCP values commonly used for BGP sessions.
+//
+// https://www.rfc-editor.org/rfc/rfc4594#section-3.1
+const (
+	// DSCPCS6 is Class Selector 6, used for network control traffic such as
+	// routing protocols.
+	DSCPCS6 uint8 = 48
+	// DSCPCS7 is Class Selector 7, reserved for network control traffic.
+	DSCPCS7 uint8 = 56
+)
+
+// dscpToTOS returns the TOS octet for dscp, with the ECN bits cleared.
+//
+// https://www.rfc-editor.org/rfc/rfc2474#section-3
+func dscpToTOS(dscp uint8) uint8 {
+	return (dscp & 0x3f) << 2
+}
+
+// WithDSCP returns a PeerOption that sets the IP DSCP, e.g. DSCPCS6, on a
+// peer's connections. It is equivalent to WithTOS with dscp in the upper six
+// bits, bits beyond the six bit DSCP field are ignored. Outbound connections
+// are marked prior to connecting, inbound connections once accepted, see
+// SetListenerDSCP for marking the SYN-ACK of inbound connections. This is
+// only supported on Linux.
+func WithDSCP(dscp uint8) PeerOption {
+	return WithTOS(dscpToTOS(dscp))
+}
+
+// SetListenerDSCP sets the IP DSCP on lis, which must implement
+// syscall.Conn, e.g. *net.TCPListener. Packets sent by lis, namely the SYN-ACK
+// of inbound connections, are marked with dscp, as are accepted connections
+// until a peer's WithDSCP or WithTOS option is applied. A listener is shared
+// by all peers, so its DSCP is not per peer. This is only supported on Linux.
+func SetListenerDSCP(lis net.Listener, dscp uint8) error {
+	sc, ok := lis.(syscall.Conn)
+	if !ok {
+		return errors.New("listener does not implement syscall.Conn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		sockErr = setTOS(int(fd), dscpToTOS(dscp))
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
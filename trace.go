@@ -0,0 +1,150 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+)
+
+// TraceLevel controls the verbosity of logging for a peer, see SetLogger.
+type TraceLevel uint32
+
+const (
+	// TraceOff disables logging for a peer.
+	TraceOff TraceLevel = iota
+	// TraceEvents logs events such as FSM state transitions and errors. This
+	// is the default.
+	TraceEvents
+	// TraceMessages additionally logs a one line summary of each message
+	// sent to and received from a peer.
+	TraceMessages
+	// TraceHexDump additionally logs a hex dump of each message sent to and
+	// received from a peer.
+	TraceHexDump
+)
+
+func (t TraceLevel) String() string {
+	switch t {
+	case TraceOff:
+		return "off"
+	case TraceEvents:
+		return "events"
+	case TraceMessages:
+		return "messages"
+	case TraceHexDump:
+		return "hexdump"
+	default:
+		return "unknown"
+	}
+}
+
+// WithTraceLevel returns a PeerOption that sets the initial TraceLevel for a
+// peer. It may be changed at runtime via Server.SetTraceLevel.
+func WithTraceLevel(level TraceLevel) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.traceLevel = level
+	})
+}
+
+// SetTraceLevel sets the TraceLevel for the provided peer at runtime, e.g. to
+// debug a single session without enabling verbose logging for all peers. It
+// takes effect immediately, including for an established session, and
+// persists across updates of the peer via UpdatePeer that do not replace it.
+// Messages are only traced once a Logger has been set via SetLogger.
+func (s *Server) SetTraceLevel(ip netip.Addr, level TraceLevel) error {
+	if level > TraceHexDump {
+		return fmt.Errorf("invalid trace level: %d", level)
+	}
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return ErrPeerNotExist
+	}
+	p.trace.Store(uint32(level))
+	return nil
+}
+
+// GetTraceLevel returns the TraceLevel for the provided peer, or an error if
+// it does not exist.
+func (s *Server) GetTraceLevel(ip netip.Addr) (TraceLevel, error) {
+	p, exists := s.peers.get(ip)
+	if !exists {
+		return 0, ErrPeerNotExist
+	}
+	return p.traceLevel(), nil
+}
+
+func (p *peer) traceLevel() TraceLevel {
+	return TraceLevel(p.trace.Load())
+}
+
+// logf logs for the peer if its TraceLevel is at least TraceEvents.
+func (p *peer) logf(format string, v ...interface{}) {
+	if logger == nil || p.traceLevel() < TraceEvents {
+		return
+	}
+	logf("[%s] "+format, append([]interface{}{p.config.RemoteAddress},
+		v...)...)
+}
+
+// traceInterceptor returns a MessageInterceptor tracing messages in the
+// direction dir, "recv" or "send", before passing them to next, if the peer's
+// TraceLevel is at least TraceMessages. Otherwise next is returned.
+func (p *peer) traceInterceptor(dir string,
+	next MessageInterceptor) MessageInterceptor {
+	if p == nil || logger == nil || p.traceLevel() < TraceMessages {
+		return next
+	}
+	return func(peer PeerConfig, b []byte) []byte {
+		level := p.traceLevel()
+		if level >= TraceMessages {
+			p.logf("%s %s", dir, summarizeMessage(b))
+		}
+		if level >= TraceHexDump {
+			p.logf("%s hex dump:\n%s", dir, hex.Dump(b))
+		}
+		if next != nil {
+			return next(peer, b)
+		}
+		return b
+	}
+}
+
+// summarizeMessage returns a one line summary of the message b, including its
+// header.
+func summarizeMessage(b []byte) string {
+	if len(b) < headerLength {
+		return fmt.Sprintf("truncated message len=%d", len(b))
+	}
+	body := b[headerLength:]
+	switch b[18] {
+	case openMessageType:
+		if len(body) < 9 {
+			break
+		}
+		return fmt.Sprintf("OPEN len=%d version=%d as=%d hold=%d id=%s",
+			len(b), body[0], binary.BigEndian.Uint16(body[1:]),
+			binary.BigEndian.Uint16(body[3:]),
+			netip.AddrFrom4([4]byte(body[5:9])))
+	case updateMessageType:
+		x, err := NewUpdateIndex(body)
+		if err != nil {
+			break
+		}
+		if family, ok := IsEndOfRIB(body); ok {
+			return fmt.Sprintf("UPDATE len=%d End-of-RIB afi=%d safi=%d",
+				len(b), family.AFI, family.SAFI)
+		}
+		return fmt.Sprintf("UPDATE len=%d withdrawn=%d attrs=%d nlri=%d",
+			len(b), len(x.Withdrawn()), x.NumAttrs(), len(x.NLRI()))
+	case notificationMessageType:
+		n := &Notification{}
+		if n.decode(body) != nil {
+			break
+		}
+		return fmt.Sprintf("NOTIFICATION len=%d %s", len(b), n.Error())
+	case keepAliveMessageType:
+		return fmt.Sprintf("KEEPALIVE len=%d", len(b))
+	}
+	return fmt.Sprintf("message type=%d len=%d", b[18], len(b))
+}
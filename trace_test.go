@@ -0,0 +1,99 @@
+package corebgp
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeMessage(t *testing.T) {
+	open, err := newOpenMessage(64512, 90*time.Second, 0x01020304, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	openMsg, err := open.encode()
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, tc := range []struct {
+		b    []byte
+		want string
+	}{
+		{openMsg, fmt.Sprintf("OPEN len=%d version=4 as=64512 hold=90 id=1.2.3.4",
+			len(openMsg))},
+		{prependHeader([]byte{0, 0, 0, 0, 24, 192, 0, 2}, updateMessageType),
+			"UPDATE len=27 withdrawn=0 attrs=0 nlri=4"},
+		{prependHeader(NewEndOfRIB(AFI_IPV6, SAFI_UNICAST), updateMessageType),
+			"UPDATE len=29 End-of-RIB afi=2 safi=1"},
+		{prependHeader([]byte{NOTIF_CODE_CEASE, NOTIF_SUBCODE_ADMIN_SHUTDOWN},
+			notificationMessageType),
+			"NOTIFICATION len=21 " + newNotification(NOTIF_CODE_CEASE,
+				NOTIF_SUBCODE_ADMIN_SHUTDOWN, nil).Error()},
+		{prependHeader(nil, keepAliveMessageType), "KEEPALIVE len=19"},
+		{prependHeader(nil, 5), "message type=5 len=19"},
+		{[]byte{0xff}, "truncated message len=1"},
+	} {
+		assert.Equal(t, tc.want, summarizeMessage(tc.b))
+	}
+}
+
+func TestServer_SetTraceLevel(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := netip.MustParseAddr("192.0.2.2")
+	err = s.AddPeer(PeerConfig{
+		RemoteAddress: addr,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}, nil, WithTraceLevel(TraceOff))
+	if !assert.NoError(t, err) {
+		return
+	}
+	level, err := s.GetTraceLevel(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, TraceOff, level)
+	assert.NoError(t, s.SetTraceLevel(addr, TraceHexDump))
+	level, _ = s.GetTraceLevel(addr)
+	assert.Equal(t, TraceHexDump, level)
+	assert.Error(t, s.SetTraceLevel(addr, TraceHexDump+1))
+	assert.ErrorIs(t, s.SetTraceLevel(netip.MustParseAddr("192.0.2.3"),
+		TraceOff), ErrPeerNotExist)
+}
+
+func TestPeer_traceInterceptor(t *testing.T) {
+	var logged []string
+	SetLogger(func(v ...interface{}) {
+		logged = append(logged, fmt.Sprint(v...))
+	})
+	defer SetLogger(nil)
+
+	p := newPeer(PeerConfig{RemoteAddress: netip.MustParseAddr("192.0.2.2")},
+		0, nil, defaultPeerOptions())
+	assert.Nil(t, p.traceInterceptor("recv", nil))
+	p.logf("event")
+	assert.Equal(t, []string{"[192.0.2.2] event"}, logged)
+
+	p.trace.Store(uint32(TraceOff))
+	p.logf("event")
+	assert.Len(t, logged, 1)
+
+	p.trace.Store(uint32(TraceHexDump))
+	calls := 0
+	fn := p.traceInterceptor("recv", func(peer PeerConfig, b []byte) []byte {
+		calls++
+		return nil
+	})
+	assert.Nil(t, fn(p.config, prependHeader(nil, keepAliveMessageType)))
+	assert.Equal(t, 1, calls)
+	if assert.Len(t, logged, 3) {
+		assert.Equal(t, "[192.0.2.2] recv KEEPALIVE len=19", logged[1])
+		assert.True(t, strings.HasPrefix(logged[2],
+			"[192.0.2.2] recv hex dump:\n00000000  ff ff"))
+	}
+}
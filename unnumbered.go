@@ -0,0 +1,218 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// unnumberedResolveInterval is the interval at which the remote address of an
+// UnnumberedPeer is re-resolved.
+const unnumberedResolveInterval = time.Second * 5
+
+// UnnumberedPeer is a peer reachable over a network interface without a
+// configured remote address, see AddUnnumberedPeer.
+type UnnumberedPeer struct {
+	s         *Server
+	iface     string
+	localAS   uint32
+	remoteAS  uint32
+	plugin    Plugin
+	opts      []PeerOption
+	neighbors func(iface string) ([]netip.Addr, error)
+
+	// mu protects config, which is the zero value until a neighbor has been
+	// discovered
+	mu     sync.Mutex
+	config PeerConfig
+
+	closeCh   chan struct{}
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// AddUnnumberedPeer adds a peer reachable over the provided interface without
+// a configured remote address. The remote address is discovered from the IPv6
+// link-local neighbors of the interface (see LinkLocalNeighbors), so the
+// neighbor must be present in the kernel's neighbor cache, e.g. via a received
+// router advertisement.
+//
+// The neighbors of the interface are re-resolved periodically until the
+// UnnumberedPeer is closed, or the Server is closed. A peer is added to the
+// Server once exactly one link-local neighbor is present. If the neighbor of
+// an existing peer disappears and exactly one other is present, the peer is
+// deleted and a peer for the new neighbor is added in its place.
+//
+// The Extended Next Hop Encoding Capability (RFC8950) for IPv4 unicast NLRI
+// with IPv6 next hops is implicitly included in OPEN messages sent to the peer
+// unless the Plugin returns its own Extended Next Hop Encoding Capability.
+func (s *Server) AddUnnumberedPeer(iface string, localAS, remoteAS uint32,
+	plugin Plugin, opts ...PeerOption) (*UnnumberedPeer, error) {
+	return s.addUnnumberedPeer(iface, localAS, remoteAS, plugin, opts,
+		LinkLocalNeighbors, unnumberedResolveInterval)
+}
+
+func (s *Server) addUnnumberedPeer(iface string, localAS, remoteAS uint32,
+	plugin Plugin, opts []PeerOption,
+	neighbors func(iface string) ([]netip.Addr, error),
+	interval time.Duration) (*UnnumberedPeer, error) {
+	if plugin == nil {
+		return nil, errors.New("nil plugin")
+	}
+	_, err := neighbors(iface)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering neighbors: %v", err)
+	}
+	u := &UnnumberedPeer{
+		s:         s,
+		iface:     iface,
+		localAS:   localAS,
+		remoteAS:  remoteAS,
+		plugin:    &extendedNextHopPlugin{Plugin: plugin},
+		opts:      slices.Clone(opts),
+		neighbors: neighbors,
+		closeCh:   make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	err = u.resolve()
+	if err != nil {
+		return nil, err
+	}
+	go u.run(interval)
+	return u, nil
+}
+
+func (u *UnnumberedPeer) run(interval time.Duration) {
+	defer close(u.doneCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.closeCh:
+			return
+		case <-u.s.closeCh:
+			return
+		case <-ticker.C:
+			err := u.resolve()
+			if err != nil {
+				logf("unnumbered peer on %s: %v", u.iface, err)
+			}
+		}
+	}
+}
+
+// resolve discovers the neighbors of u.iface and adds or replaces the peer if
+// required.
+func (u *UnnumberedPeer) resolve() error {
+	neighbors, err := u.neighbors(u.iface)
+	if err != nil {
+		return fmt.Errorf("error discovering neighbors: %v", err)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	current := u.config.RemoteAddress
+	if current.IsValid() && slices.Contains(neighbors, current) {
+		return nil
+	}
+	if len(neighbors) != 1 {
+		if !current.IsValid() {
+			logf("found %d link-local neighbors on %s, want 1",
+				len(neighbors), u.iface)
+		}
+		return nil
+	}
+	if current.IsValid() {
+		err = u.s.DeletePeer(current)
+		if err != nil && !errors.Is(err, ErrPeerNotExist) {
+			return err
+		}
+		u.config = PeerConfig{}
+	}
+	config := PeerConfig{
+		RemoteAddress: neighbors[0],
+		LocalAS:       u.localAS,
+		RemoteAS:      u.remoteAS,
+	}
+	err = u.s.AddPeer(config, u.plugin, u.opts...)
+	if err != nil {
+		return err
+	}
+	u.config = config
+	return nil
+}
+
+// Config returns the PeerConfig of the peer, which can be used to refer to it
+// in other Server methods. It returns false if no neighbor has been discovered
+// yet.
+func (u *UnnumberedPeer) Config() (PeerConfig, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.config, u.config.RemoteAddress.IsValid()
+}
+
+// Close stops re-resolving the neighbors of the interface and deletes the
+// peer from the Server, if one was added.
+func (u *UnnumberedPeer) Close() error {
+	closed := false
+	u.closeOnce.Do(func() {
+		close(u.closeCh)
+		closed = true
+	})
+	if !closed {
+		return errors.New("already closed")
+	}
+	<-u.doneCh
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.config.RemoteAddress.IsValid() {
+		return nil
+	}
+	err := u.s.DeletePeer(u.config.RemoteAddress)
+	u.config = PeerConfig{}
+	if errors.Is(err, ErrPeerNotExist) {
+		return nil
+	}
+	return err
+}
+
+// extendedNextHopPlugin wraps a Plugin to include the Extended Next Hop
+// Encoding Capability for IPv4 unicast NLRI with IPv6 next hops.
+type extendedNextHopPlugin struct {
+	Plugin
+}
+
+func (e *extendedNextHopPlugin) GetCapabilities(peer PeerConfig) []Capability {
+	return e.getCapabilities(context.Background(), peer)
+}
+
+func (e *extendedNextHopPlugin) getCapabilities(ctx context.Context,
+	peer PeerConfig) []Capability {
+	caps := pluginGetCapabilities(ctx, e.Plugin, peer)
+	if hasCapabilityCode(caps, CAP_EXTENDED_NEXT_HOP_ENCODING) {
+		return caps
+	}
+	// the returned slice may be retained by the wrapped Plugin
+	return append(slices.Clone(caps), NewExtendedNextHopCapability(
+		[]ExtendedNextHop{
+			{
+				NLRIAFI:    AFI_IPV4,
+				NLRISAFI:   uint16(SAFI_UNICAST),
+				NextHopAFI: AFI_IPV6,
+			},
+		}))
+}
+
+func (e *extendedNextHopPlugin) onOpenMessage(ctx context.Context,
+	peer PeerConfig, routerID netip.Addr,
+	capabilities []Capability) *Notification {
+	return pluginOnOpenMessage(ctx, e.Plugin, peer, routerID, capabilities)
+}
+
+func (e *extendedNextHopPlugin) onEstablished(ctx context.Context,
+	peer PeerConfig, writer UpdateMessageWriter) UpdateMessageHandler {
+	return pluginOnEstablished(ctx, e.Plugin, peer, writer)
+}
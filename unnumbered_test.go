@@ -0,0 +1,158 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unnumberedCtxPlugin is a ContextPlugin recording the context passed to
+// GetCapabilities.
+type unnumberedCtxPlugin struct {
+	ctx context.Context
+}
+
+func (u *unnumberedCtxPlugin) GetCapabilities(ctx context.Context,
+	_ PeerConfig) []Capability {
+	u.ctx = ctx
+	return nil
+}
+
+func (u *unnumberedCtxPlugin) OnOpenMessage(context.Context, PeerConfig,
+	netip.Addr, []Capability) *Notification {
+	return nil
+}
+
+func (u *unnumberedCtxPlugin) OnEstablished(context.Context, PeerConfig,
+	UpdateMessageWriter) ContextUpdateMessageHandler {
+	return nil
+}
+
+func (u *unnumberedCtxPlugin) OnClose(PeerConfig) {}
+
+func TestExtendedNextHopPlugin(t *testing.T) {
+	enh := NewExtendedNextHopCapability([]ExtendedNextHop{
+		{
+			NLRIAFI:    AFI_IPV4,
+			NLRISAFI:   uint16(SAFI_UNICAST),
+			NextHopAFI: AFI_IPV6,
+		},
+	})
+	mp := NewMPExtensionsCapability(AFI_IPV4, SAFI_UNICAST)
+	caps := make([]Capability, 1, 2)
+	caps[0] = mp
+	wrapped := &extendedNextHopPlugin{Plugin: &chainTestPlugin{caps: caps}}
+	got := wrapped.GetCapabilities(PeerConfig{})
+	assert.Equal(t, []Capability{mp, enh}, got)
+	// the slice returned by the wrapped Plugin must not be written to
+	assert.Equal(t, Capability{}, caps[:2][1])
+
+	own := NewExtendedNextHopCapability([]ExtendedNextHop{
+		{
+			NLRIAFI:    AFI_IPV6,
+			NLRISAFI:   uint16(SAFI_UNICAST),
+			NextHopAFI: AFI_IPV4,
+		},
+	})
+	wrapped = &extendedNextHopPlugin{Plugin: &chainTestPlugin{
+		caps: []Capability{own},
+	}}
+	assert.Equal(t, []Capability{own},
+		wrapped.GetCapabilities(PeerConfig{}))
+
+	// the contexts of a ContextPlugin are forwarded
+	ctxPlugin := &unnumberedCtxPlugin{}
+	var plugin Plugin = &extendedNextHopPlugin{
+		Plugin: NewContextPlugin(ctxPlugin),
+	}
+	_, ok := plugin.(contextAwarePlugin)
+	assert.True(t, ok)
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+	assert.Equal(t, []Capability{enh},
+		pluginGetCapabilities(ctx, plugin, PeerConfig{}))
+	assert.Equal(t, ctx, ctxPlugin.ctx)
+}
+
+func TestAddUnnumberedPeer(t *testing.T) {
+	s, err := NewServer(netip.MustParseAddr("192.0.2.1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	var (
+		mu        sync.Mutex
+		neighbors []netip.Addr
+	)
+	setNeighbors := func(addrs ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		neighbors = nil
+		for _, a := range addrs {
+			neighbors = append(neighbors, netip.MustParseAddr(a))
+		}
+	}
+	neighborsFn := func(iface string) ([]netip.Addr, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]netip.Addr{}, neighbors...), nil
+	}
+	configured := func(u *UnnumberedPeer, addr string) func() bool {
+		return func() bool {
+			config, ok := u.Config()
+			if !ok || config.RemoteAddress != netip.MustParseAddr(addr) {
+				return false
+			}
+			_, err := s.GetPeer(config.RemoteAddress)
+			return err == nil && len(s.ListPeers()) == 1
+		}
+	}
+
+	_, err = s.addUnnumberedPeer("eth0", 64512, 64513, nil, nil, neighborsFn,
+		time.Millisecond*10)
+	assert.Error(t, err)
+	_, err = s.addUnnumberedPeer("eth0", 64512, 64513, nopPlugin{}, nil,
+		func(string) ([]netip.Addr, error) {
+			return nil, errors.New("no such interface")
+		}, time.Millisecond*10)
+	assert.Error(t, err)
+
+	// no neighbor is discovered initially
+	setNeighbors("fe80::1%eth0", "fe80::2%eth0")
+	u, err := s.addUnnumberedPeer("eth0", 64512, 64513, nopPlugin{}, nil,
+		neighborsFn, time.Millisecond*10)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, ok := u.Config()
+	assert.False(t, ok)
+	assert.Empty(t, s.ListPeers())
+
+	setNeighbors("fe80::1%eth0")
+	assert.Eventually(t, configured(u, "fe80::1%eth0"), time.Second*5,
+		time.Millisecond*10)
+	config, _ := u.Config()
+	assert.Equal(t, uint32(64512), config.LocalAS)
+	assert.Equal(t, uint32(64513), config.RemoteAS)
+
+	// the current neighbor is retained while it is present
+	setNeighbors("fe80::1%eth0", "fe80::2%eth0")
+	time.Sleep(time.Millisecond * 50)
+	assert.True(t, configured(u, "fe80::1%eth0")())
+
+	// the peer is replaced once its neighbor is gone
+	setNeighbors("fe80::2%eth0")
+	assert.Eventually(t, configured(u, "fe80::2%eth0"), time.Second*5,
+		time.Millisecond*10)
+
+	assert.NoError(t, u.Close())
+	assert.Empty(t, s.ListPeers())
+	_, ok = u.Config()
+	assert.False(t, ok)
+	assert.Error(t, u.Close())
+}
@@ -61,6 +61,15 @@ func (p PathAttrFlags) Validate(forCode uint8, attrData []byte, wantOptional, wa
 
 type OriginPathAttr uint8
 
+// ORIGIN attribute values.
+//
+// https://www.rfc-editor.org/rfc/rfc4271#section-4.3
+const (
+	OriginIGP        OriginPathAttr = 0
+	OriginEGP        OriginPathAttr = 1
+	OriginIncomplete OriginPathAttr = 2
+)
+
 // UpdateNotificationFromErr finds the highest severity *Notification in err's
 // tree. This is useful for Plugins using UpdateDecoder that do not handle the
 // additional error approaches described by RFC7606, and instead are designed to
@@ -247,11 +256,11 @@ func (o *OriginPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 	return nil
 }
 
-func decodeUint32Set(b []byte) ([]uint32, error) {
+func decodeUint32Set(b []byte, a *DecodeArena) ([]uint32, error) {
 	if len(b) == 0 || len(b)%4 != 0 {
 		return nil, fmt.Errorf("invalid uint32 set len: %d", len(b))
 	}
-	ret := make([]uint32, 0, len(b)/4)
+	ret := a.uint32Slice(len(b) / 4)
 	for len(b) > 0 {
 		ret = append(ret, binary.BigEndian.Uint32(b))
 		b = b[4:]
@@ -259,11 +268,12 @@ func decodeUint32Set(b []byte) ([]uint32, error) {
 	return ret, nil
 }
 
-func decodeLargeCommunitySet(b []byte) (LargeCommunitiesPathAttr, error) {
+func decodeLargeCommunitySet(b []byte,
+	a *DecodeArena) (LargeCommunitiesPathAttr, error) {
 	if len(b)%12 != 0 {
 		return nil, fmt.Errorf("invalid large community set len: %d", len(b))
 	}
-	ret := make([]LargeCommunity, 0, len(b)/12)
+	ret := a.largeCommunitySlice(len(b) / 12)
 	for len(b) >= 12 {
 		lc := LargeCommunity{
 			GlobalAdmin: binary.BigEndian.Uint32(b[:4]),
@@ -305,6 +315,12 @@ func asPathMalformedErr() error {
 }
 
 func (a *ASPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	return a.DecodeIn(nil, flags, b)
+}
+
+// DecodeIn is Decode allocating from arena.
+func (a *ASPathAttr) DecodeIn(arena *DecodeArena, flags PathAttrFlags,
+	b []byte) error {
 	err := flags.Validate(PATH_ATTR_AS_PATH, b, false, true)
 	if err != nil {
 		return err
@@ -351,12 +367,12 @@ func (a *ASPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 		}
 		set := b[:segLen]
 		if segType == 1 {
-			a.ASSet, err = decodeUint32Set(set)
+			a.ASSet, err = decodeUint32Set(set, arena)
 			if err != nil {
 				return asPathMalformedErr()
 			}
 		} else if segType == 2 {
-			a.ASSequence, err = decodeUint32Set(set)
+			a.ASSequence, err = decodeUint32Set(set, arena)
 			if err != nil {
 				return asPathMalformedErr()
 			}
@@ -391,11 +407,12 @@ func (n *NextHopPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 	return nil
 }
 
-func decodeAddPathPrefixes(b []byte, ipv6 bool) ([]AddPathPrefix, error) {
+func decodeAddPathPrefixes(b []byte, ipv6 bool,
+	arena *DecodeArena) ([]AddPathPrefix, error) {
 	if len(b) < 1 {
 		return nil, nil
 	}
-	prefixes := make([]AddPathPrefix, 0)
+	prefixes := arena.addPathPrefixSlice(b)
 	for len(b) > 0 {
 		if len(b) < 5 {
 			return nil, fmt.Errorf("invalid octets: %d for add path prefix ipv6: %v", len(b), ipv6)
@@ -453,11 +470,12 @@ func decodePrefix(b []byte, ipv6 bool) (netip.Prefix, []byte, error) {
 	return netip.PrefixFrom(addr, int(bl)), b[octets:], nil
 }
 
-func decodePrefixes(b []byte, ipv6 bool) ([]netip.Prefix, error) {
+func decodePrefixes(b []byte, ipv6 bool,
+	a *DecodeArena) ([]netip.Prefix, error) {
 	if len(b) < 1 {
 		return nil, nil
 	}
-	prefixes := make([]netip.Prefix, 0)
+	prefixes := a.prefixSlice(b)
 	for len(b) > 0 {
 		var (
 			p   netip.Prefix
@@ -589,6 +607,12 @@ type LargeCommunity struct {
 type LargeCommunitiesPathAttr []LargeCommunity
 
 func (l *LargeCommunitiesPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	return l.DecodeIn(nil, flags, b)
+}
+
+// DecodeIn is Decode allocating from a.
+func (l *LargeCommunitiesPathAttr) DecodeIn(a *DecodeArena,
+	flags PathAttrFlags, b []byte) error {
 	err := flags.Validate(PATH_ATTR_LARGE_COMMUNITY, b, true, true)
 	if err != nil {
 		return err
@@ -612,7 +636,7 @@ func (l *LargeCommunitiesPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 			Notification: attrLenBadForCodeErr(PATH_ATTR_LARGE_COMMUNITY, b),
 		}
 	}
-	s, err := decodeLargeCommunitySet(b)
+	s, err := decodeLargeCommunitySet(b, a)
 	if err != nil {
 		return &TreatAsWithdrawUpdateErr{
 			Code:         PATH_ATTR_LARGE_COMMUNITY,
@@ -627,6 +651,12 @@ func (l *LargeCommunitiesPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 type CommunitiesPathAttr []uint32
 
 func (c *CommunitiesPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	return c.DecodeIn(nil, flags, b)
+}
+
+// DecodeIn is Decode allocating from a.
+func (c *CommunitiesPathAttr) DecodeIn(a *DecodeArena, flags PathAttrFlags,
+	b []byte) error {
 	err := flags.Validate(PATH_ATTR_COMMUNITY, b, true, true)
 	if err != nil {
 		return err
@@ -645,7 +675,7 @@ func (c *CommunitiesPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 			Notification: attrLenBadForCodeErr(PATH_ATTR_COMMUNITY, b),
 		}
 	}
-	s, _ := decodeUint32Set(b)
+	s, _ := decodeUint32Set(b, a)
 	*c = s
 	return nil
 }
@@ -682,6 +712,12 @@ func (o *OriginatorIDPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 type ClusterListPathAttr []netip.Addr
 
 func (c *ClusterListPathAttr) Decode(flags PathAttrFlags, b []byte) error {
+	return c.DecodeIn(nil, flags, b)
+}
+
+// DecodeIn is Decode allocating from a.
+func (c *ClusterListPathAttr) DecodeIn(a *DecodeArena, flags PathAttrFlags,
+	b []byte) error {
 	err := flags.Validate(PATH_ATTR_CLUSTER_LIST, b, true, false)
 	if err != nil {
 		return err
@@ -703,7 +739,7 @@ func (c *ClusterListPathAttr) Decode(flags PathAttrFlags, b []byte) error {
 			Notification: attrLenBadForCodeErr(PATH_ATTR_CLUSTER_LIST, b),
 		}
 	}
-	addrs := make([]netip.Addr, 0, len(b)/4)
+	addrs := a.addrSlice(len(b) / 4)
 	for len(b) > 0 {
 		addr, _ := netip.AddrFromSlice(b[:4])
 		addrs = append(addrs, addr)
@@ -741,7 +777,7 @@ type DecodeFn[T any] func(t T, b []byte) error
 // The closure fn will be passed type T and a slice of AddPathPrefix.
 func NewNLRIAddPathDecodeFn[T any](fn func(t T, a []AddPathPrefix) error) DecodeFn[T] {
 	return func(t T, b []byte) error {
-		prefixes, err := decodeAddPathPrefixes(b, false)
+		prefixes, err := decodeAddPathPrefixes(b, false, arenaOf(t))
 		if err != nil {
 			// https://www.rfc-editor.org/rfc/rfc4271#page-34
 			// The NLRI field in the UPDATE message is checked for syntactic
@@ -761,7 +797,7 @@ func NewNLRIAddPathDecodeFn[T any](fn func(t T, a []AddPathPrefix) error) Decode
 // type T and a slice of netip.Prefix.
 func NewNLRIDecodeFn[T any](fn func(t T, p []netip.Prefix) error) DecodeFn[T] {
 	return func(t T, b []byte) error {
-		prefixes, err := decodePrefixes(b, false)
+		prefixes, err := decodePrefixes(b, false, arenaOf(t))
 		if err != nil {
 			// https://www.rfc-editor.org/rfc/rfc4271#page-34
 			// The NLRI field in the UPDATE message is checked for syntactic
@@ -779,6 +815,11 @@ func NewNLRIDecodeFn[T any](fn func(t T, p []netip.Prefix) error) DecodeFn[T] {
 // DecodeMPReachIPv6NextHops decodes one or two (RFC2545) IPv6 next hops
 // contained in nh. Error handling is consistent with RFC7606.
 func DecodeMPReachIPv6NextHops(nh []byte) ([]netip.Addr, error) {
+	return decodeMPReachIPv6NextHops(nh, nil)
+}
+
+func decodeMPReachIPv6NextHops(nh []byte, a *DecodeArena) ([]netip.Addr,
+	error) {
 	if len(nh) != 16 && len(nh) != 32 {
 		// https://datatracker.ietf.org/doc/html/rfc2545#section-3
 		// The value of the Length of Next Hop Network Address field on a
@@ -797,7 +838,7 @@ func DecodeMPReachIPv6NextHops(nh []byte) ([]netip.Addr, error) {
 			Code: NOTIF_CODE_UPDATE_MESSAGE_ERR,
 		}
 	}
-	nhs := make([]netip.Addr, 0, len(nh)/16)
+	nhs := a.addrSlice(len(nh) / 16)
 	for len(nh) > 0 {
 		addr, _ := netip.AddrFromSlice(nh[:16])
 		nhs = append(nhs, addr)
@@ -806,10 +847,42 @@ func DecodeMPReachIPv6NextHops(nh []byte) ([]netip.Addr, error) {
 	return nhs, nil
 }
 
+// EncodeMPReachIPv6NextHops encodes a global and an optional link-local
+// (RFC2545) IPv6 next hop for use in the next hop field of a MP_REACH_NLRI
+// path attribute. If linkLocal is the zero value only global is encoded.
+// Zones are not encoded.
+func EncodeMPReachIPv6NextHops(global, linkLocal netip.Addr) ([]byte, error) {
+	if !global.Is6() || global.Is4In6() {
+		return nil, errors.New("global next hop must be an IPv6 address")
+	}
+	nh := make([]byte, 0, 32)
+	g := global.As16()
+	nh = append(nh, g[:]...)
+	if linkLocal.IsValid() {
+		// https://datatracker.ietf.org/doc/html/rfc2545#section-3
+		// The link-local address shall be included in the Next Hop field if
+		// and only if the BGP speaker shares a common subnet with the entity
+		// identified by the global IPv6 address carried in the Network
+		// Address of Next Hop field and the peer the route is being
+		// advertised to.
+		if !linkLocal.Is6() || !linkLocal.IsLinkLocalUnicast() {
+			return nil, errors.New("invalid link-local next hop")
+		}
+		ll := linkLocal.As16()
+		nh = append(nh, ll[:]...)
+	}
+	return nh, nil
+}
+
 // DecodeMPIPv6AddPathPrefixes decodes IPv6 add-path prefixes in b with
 // multiprotocol error handling consistent with RFC7606.
 func DecodeMPIPv6AddPathPrefixes(b []byte) ([]AddPathPrefix, error) {
-	prefixes, err := decodeAddPathPrefixes(b, true)
+	return decodeMPIPv6AddPathPrefixes(b, nil)
+}
+
+func decodeMPIPv6AddPathPrefixes(b []byte, a *DecodeArena) ([]AddPathPrefix,
+	error) {
+	prefixes, err := decodeAddPathPrefixes(b, true, a)
 	if err != nil {
 		// https://www.rfc-editor.org/rfc/rfc7606#page-7
 		// Finally, we observe that in order to use the approach of "treat-
@@ -829,7 +902,11 @@ func DecodeMPIPv6AddPathPrefixes(b []byte) ([]AddPathPrefix, error) {
 // DecodeMPIPv6Prefixes decodes IPv6 prefixes in b with multiprotocol error
 // handling consistent with RFC7606.
 func DecodeMPIPv6Prefixes(b []byte) ([]netip.Prefix, error) {
-	prefixes, err := decodePrefixes(b, true)
+	return decodeMPIPv6Prefixes(b, nil)
+}
+
+func decodeMPIPv6Prefixes(b []byte, a *DecodeArena) ([]netip.Prefix, error) {
+	prefixes, err := decodePrefixes(b, true, a)
 	if err != nil {
 		// https://www.rfc-editor.org/rfc/rfc7606#page-7
 		// Finally, we observe that in order to use the approach of "treat-
@@ -852,7 +929,7 @@ func DecodeMPIPv6Prefixes(b []byte) ([]netip.Prefix, error) {
 // a slice of AddPathPrefix.
 func NewWithdrawnAddPathRoutesDecodeFn[T any](fn func(t T, a []AddPathPrefix) error) DecodeFn[T] {
 	return func(t T, b []byte) error {
-		prefixes, err := decodeAddPathPrefixes(b, false)
+		prefixes, err := decodeAddPathPrefixes(b, false, arenaOf(t))
 		if err != nil {
 			// Neither RFC4271 or RFC7606 define specific error handling for
 			// this case.
@@ -869,7 +946,7 @@ func NewWithdrawnAddPathRoutesDecodeFn[T any](fn func(t T, a []AddPathPrefix) er
 // will be passed type T and a slice of netip.Prefix.
 func NewWithdrawnRoutesDecodeFn[T any](fn func(t T, p []netip.Prefix) error) DecodeFn[T] {
 	return func(t T, b []byte) error {
-		prefixes, err := decodePrefixes(b, false)
+		prefixes, err := decodePrefixes(b, false, arenaOf(t))
 		if err != nil {
 			// Neither RFC4271 or RFC7606 define specific error handling for
 			// this case.
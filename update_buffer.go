@@ -0,0 +1,74 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"sync"
+)
+
+// pooledBufferLen is the length of a pooled UPDATE buffer, a message body of
+// up to maxMessageLength followed by pooledBufferToken.
+const pooledBufferLen = maxMessageLength + 8
+
+// pooledBufferToken marks the trailing octets of a pooled UPDATE buffer, so
+// that ReleaseUpdateBuffer can distinguish pooled buffers from other slices.
+var pooledBufferToken = func() (token [8]byte) {
+	binary.BigEndian.PutUint64(token[:], maphash.Bytes(maphash.MakeSeed(), nil))
+	return token
+}()
+
+var updateBufferPool = sync.Pool{
+	New: func() any {
+		return new([pooledBufferLen]byte)
+	},
+}
+
+// getUpdateBuffer returns a pooled buffer of length n. Buffers larger than
+// maxMessageLength, i.e. BGP Extended Messages, are not pooled.
+func getUpdateBuffer(n int) []byte {
+	if n > maxMessageLength {
+		return make([]byte, n)
+	}
+	buf := updateBufferPool.Get().(*[pooledBufferLen]byte)
+	copy(buf[maxMessageLength:], pooledBufferToken[:])
+	return buf[:n]
+}
+
+// ReleaseUpdateBuffer returns the UPDATE message b, as passed to an
+// UpdateMessageHandler, to the pool of buffers used by peers with
+// WithPooledUpdateBuffers. b must not be used after it is released. Calling
+// ReleaseUpdateBuffer with a slice that was not read into a pooled buffer, or
+// that was already released, is a no-op.
+func ReleaseUpdateBuffer(b []byte) {
+	buf, ok := pooledBuffer(b)
+	if !ok {
+		return
+	}
+	clear(buf[maxMessageLength:])
+	updateBufferPool.Put(buf)
+}
+
+// pooledBuffer returns the pooled buffer b was returned from getUpdateBuffer
+// as, if any.
+func pooledBuffer(b []byte) (*[pooledBufferLen]byte, bool) {
+	if cap(b) != pooledBufferLen {
+		return nil, false
+	}
+	buf := (*[pooledBufferLen]byte)(b[:pooledBufferLen])
+	return buf, [8]byte(buf[maxMessageLength:]) == pooledBufferToken
+}
+
+// WithPooledUpdateBuffers returns a PeerOption that enables reading UPDATE
+// messages from a peer into pooled buffers. By default each UPDATE message is
+// read into a newly allocated slice that may be retained by the
+// UpdateMessageHandler indefinitely. With pooled buffers the
+// UpdateMessageHandler owns the slice it is passed and should release it via
+// ReleaseUpdateBuffer once finished with it, including any sub-slices, e.g.
+// those referencing path attributes or NLRI. A handler may retain the slice by
+// not releasing it, in which case it is garbage collected as usual. This
+// reduces GC pressure when ingesting full tables.
+func WithPooledUpdateBuffers() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.pooledUpdates = true
+	})
+}
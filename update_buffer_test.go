@@ -0,0 +1,76 @@
+package corebgp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMessage_Pooled(t *testing.T) {
+	update := make([]byte, 1024)
+	for i := range update {
+		update[i] = byte(i)
+	}
+	b := prependHeader(update, updateMessageType)
+	b = append(b, prependHeader(nil, keepAliveMessageType)...)
+	r := bytes.NewReader(b)
+
+	m, err := readMessage(r, defaultLengthLimits, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	u, ok := m.(updateMessage)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, update, []byte(u))
+	_, ok = pooledBuffer(u)
+	assert.True(t, ok)
+	ReleaseUpdateBuffer(u)
+	_, ok = pooledBuffer(u)
+	assert.False(t, ok, "released buffers are no longer pooled")
+
+	m, err = readMessage(r, defaultLengthLimits, true)
+	assert.NoError(t, err)
+	assert.IsType(t, &keepAliveMessage{}, m)
+
+	// non-pooled slices are ignored, regardless of their capacity
+	for _, n := range []int{10, maxMessageLength, pooledBufferLen} {
+		_, ok = pooledBuffer(make([]byte, n))
+		assert.False(t, ok)
+	}
+	m, err = readMessage(bytes.NewReader(b), defaultLengthLimits, false)
+	if assert.NoError(t, err) {
+		_, ok = pooledBuffer(m.(updateMessage))
+		assert.False(t, ok)
+	}
+	ReleaseUpdateBuffer(make([]byte, 10))
+}
+
+func benchmarkReadMessage(b *testing.B, pooled bool) {
+	// a full size UPDATE message
+	msg := prependHeader(make([]byte, maxMessageLength-headerLength),
+		updateMessageType)
+	r := bytes.NewReader(msg)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(msg)
+		m, err := readMessage(r, defaultLengthLimits, pooled)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ReleaseUpdateBuffer(m.(updateMessage))
+	}
+}
+
+func BenchmarkReadMessage(b *testing.B) {
+	b.Run("alloc", func(b *testing.B) {
+		benchmarkReadMessage(b, false)
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkReadMessage(b, true)
+	})
+}
@@ -0,0 +1,179 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// UpdateIndex is a lightweight index of an UPDATE message. It records which
+// path attributes are present and where their data is located without
+// decoding them, allowing a handler that is only interested in a few
+// attributes, e.g. COMMUNITY, to skip the work of a full decode.
+//
+// As with UpdateDecoder, only the first occurrence of a path attribute is
+// indexed.
+//
+// https://www.rfc-editor.org/rfc/rfc7606#section-3
+// If any other attribute (whether recognized or unrecognized) appears more
+// than once in an UPDATE message, then all the occurrences of the attribute
+// other than the first one SHALL be discarded and the UPDATE message will
+// continue to be processed.
+type UpdateIndex struct {
+	msg       []byte
+	withdrawn span
+	nlri      span
+	present   attrsBitmap
+	attrs     []indexedAttr
+	attrsBuf  [8]indexedAttr
+}
+
+type span struct {
+	off, len uint16
+}
+
+type indexedAttr struct {
+	code  uint8
+	flags PathAttrFlags
+	data  span
+}
+
+var errMalformedUpdate = errors.New("malformed update message")
+
+// NewUpdateIndex returns an UpdateIndex for the UPDATE message b, which must
+// not include the message header. An error is returned if the withdrawn
+// routes, path attributes, or NLRI cannot be located. The UpdateIndex refers
+// to b, which must not be modified while the UpdateIndex is in use.
+func NewUpdateIndex(b []byte) (*UpdateIndex, error) {
+	x := &UpdateIndex{}
+	err := x.Reset(b)
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Reset re-initializes x for the UPDATE message b, see NewUpdateIndex. It
+// allows an UpdateIndex to be reused across messages without allocating.
+func (x *UpdateIndex) Reset(b []byte) error {
+	*x = UpdateIndex{}
+	x.attrs = x.attrsBuf[:0]
+	if len(b) < 4 || len(b) > math.MaxUint16 {
+		return errMalformedUpdate
+	}
+	wrl := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+wrl+2 {
+		return errMalformedUpdate
+	}
+	tpal := int(binary.BigEndian.Uint16(b[2+wrl:]))
+	if len(b) < 4+wrl+tpal {
+		return errMalformedUpdate
+	}
+	x.withdrawn = span{2, uint16(wrl)}
+	x.nlri = span{uint16(4 + wrl + tpal), uint16(len(b) - 4 - wrl - tpal)}
+	off := 4 + wrl
+	end := off + tpal
+	for off < end {
+		if end-off < 3 {
+			return errMalformedUpdate
+		}
+		flags, code := PathAttrFlags(b[off]), b[off+1]
+		var attrLen int
+		if flags.ExtendedLen() {
+			if end-off < 4 {
+				return errMalformedUpdate
+			}
+			attrLen = int(binary.BigEndian.Uint16(b[off+2:]))
+			off += 4
+		} else {
+			attrLen = int(b[off+2])
+			off += 3
+		}
+		if end-off < attrLen {
+			return errMalformedUpdate
+		}
+		if !x.present.isSet(code) {
+			x.present.set(code)
+			x.attrs = append(x.attrs, indexedAttr{
+				code:  code,
+				flags: flags,
+				data:  span{uint16(off), uint16(attrLen)},
+			})
+		}
+		off += attrLen
+	}
+	x.msg = b
+	return nil
+}
+
+func (x *UpdateIndex) bytes(s span) []byte {
+	return x.msg[s.off : s.off+s.len]
+}
+
+// Has returns true if the path attribute with code is present.
+func (x *UpdateIndex) Has(code uint8) bool {
+	return x.present.isSet(code)
+}
+
+// Attr returns the flags and data of the path attribute with code, and true
+// if it is present. The data refers to the indexed message.
+func (x *UpdateIndex) Attr(code uint8) (PathAttrFlags, []byte, bool) {
+	if !x.present.isSet(code) {
+		return 0, nil, false
+	}
+	for _, a := range x.attrs {
+		if a.code == code {
+			return a.flags, x.bytes(a.data), true
+		}
+	}
+	return 0, nil, false
+}
+
+// NumAttrs returns the number of distinct path attributes present.
+func (x *UpdateIndex) NumAttrs() int {
+	return len(x.attrs)
+}
+
+// Withdrawn returns the Withdrawn Routes field of the indexed message.
+func (x *UpdateIndex) Withdrawn() []byte {
+	return x.bytes(x.withdrawn)
+}
+
+// NLRI returns the Network Layer Reachability Information field of the
+// indexed message.
+func (x *UpdateIndex) NLRI() []byte {
+	return x.bytes(x.nlri)
+}
+
+// IndexedUpdateMessageHandler handles an UPDATE message along with its
+// UpdateIndex, see NewIndexedUpdateHandler. index is only valid for the
+// duration of the call.
+type IndexedUpdateMessageHandler func(peer PeerConfig, updateMessage []byte,
+	index *UpdateIndex) *Notification
+
+var updateIndexPool = sync.Pool{
+	New: func() any {
+		return &UpdateIndex{}
+	},
+}
+
+// NewIndexedUpdateHandler returns an UpdateMessageHandler that indexes each
+// UPDATE message before passing it to handler. UpdateIndex values are pooled,
+// so indexing does not allocate. If a message cannot be indexed index is nil,
+// in which case handler should fall back to an UpdateDecoder, which applies
+// the error handling of RFC7606.
+func NewIndexedUpdateHandler(
+	handler IndexedUpdateMessageHandler) UpdateMessageHandler {
+	return func(peer PeerConfig, updateMessage []byte) *Notification {
+		x := updateIndexPool.Get().(*UpdateIndex)
+		defer func() {
+			*x = UpdateIndex{}
+			updateIndexPool.Put(x)
+		}()
+		if x.Reset(updateMessage) != nil {
+			return handler(peer, updateMessage, nil)
+		}
+		return handler(peer, updateMessage, x)
+	}
+}
@@ -0,0 +1,173 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newIndexTestUpdate returns an UPDATE message resembling one received from a
+// transit provider, with numCommunities communities and numPrefixes NLRI.
+func newIndexTestUpdate(numCommunities, numPrefixes int) []byte {
+	var attrs []byte
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_ORIGIN, []byte{0})
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_AS_PATH, ASPath{{
+		Type: ASPathSegmentTypeSequence,
+		ASNs: []uint32{64512, 64513, 64514, 64515},
+	}}.Encode())
+	attrs = AppendPathAttr(attrs, 0x40, PATH_ATTR_NEXT_HOP,
+		[]byte{192, 0, 2, 1})
+	attrs = AppendPathAttr(attrs, 0x80, PATH_ATTR_MED, []byte{0, 0, 0, 10})
+	communities := make([]byte, 0, numCommunities*4)
+	for i := 0; i < numCommunities; i++ {
+		communities = binary.BigEndian.AppendUint32(communities,
+			uint32(64512)<<16|uint32(i))
+	}
+	attrs = AppendPathAttr(attrs, 0xc0, PATH_ATTR_COMMUNITY, communities)
+	attrs = AppendPathAttr(attrs, 0xc0, PATH_ATTR_LARGE_COMMUNITY,
+		make([]byte, 12))
+	b := []byte{0, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	b = append(b, attrs...)
+	for i := 0; i < numPrefixes; i++ {
+		b = append(b, 24, 198, 51, uint8(i))
+	}
+	return b
+}
+
+func TestUpdateIndex(t *testing.T) {
+	b := newIndexTestUpdate(2, 2)
+	// append a duplicate ORIGIN, which must not be indexed
+	dup := AppendPathAttr(nil, 0x40, PATH_ATTR_ORIGIN, []byte{2})
+	tpal := binary.BigEndian.Uint16(b[2:])
+	withDup := append([]byte{}, b[:4+tpal]...)
+	withDup = append(withDup, dup...)
+	withDup = append(withDup, b[4+tpal:]...)
+	binary.BigEndian.PutUint16(withDup[2:], tpal+uint16(len(dup)))
+
+	x, err := NewUpdateIndex(withDup)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 6, x.NumAttrs())
+	assert.True(t, x.Has(PATH_ATTR_COMMUNITY))
+	assert.False(t, x.Has(PATH_ATTR_LOCAL_PREF))
+	flags, data, ok := x.Attr(PATH_ATTR_ORIGIN)
+	assert.True(t, ok)
+	assert.Equal(t, PathAttrFlags(0x40), flags)
+	assert.Equal(t, []byte{0}, data)
+	_, data, ok = x.Attr(PATH_ATTR_COMMUNITY)
+	assert.True(t, ok)
+	var c CommunitiesPathAttr
+	assert.NoError(t, c.Decode(0xc0, data))
+	assert.Equal(t, CommunitiesPathAttr{64512 << 16, 64512<<16 | 1}, c)
+	_, _, ok = x.Attr(PATH_ATTR_LOCAL_PREF)
+	assert.False(t, ok)
+	assert.Empty(t, x.Withdrawn())
+	prefixes, err := decodePrefixes(x.NLRI(), false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("198.51.0.0/24"),
+		netip.MustParsePrefix("198.51.1.0/24"),
+	}, prefixes)
+
+	for _, bad := range [][]byte{
+		{0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 0, 4, 0x40, 1, 2, 0},
+		{0, 0, 0, 3, 0x50, 1, 0},
+	} {
+		_, err = NewUpdateIndex(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNewIndexedUpdateHandler(t *testing.T) {
+	var gotIndex *UpdateIndex
+	h := NewIndexedUpdateHandler(func(peer PeerConfig, updateMessage []byte,
+		index *UpdateIndex) *Notification {
+		gotIndex = index
+		if index != nil {
+			assert.True(t, index.Has(PATH_ATTR_COMMUNITY))
+		}
+		return nil
+	})
+	assert.Nil(t, h(PeerConfig{}, newIndexTestUpdate(1, 1)))
+	assert.NotNil(t, gotIndex)
+	assert.Nil(t, h(PeerConfig{}, []byte{0}))
+	assert.Nil(t, gotIndex)
+}
+
+// benchmarkUpdate is an UPDATE message used to compare indexing to a full
+// decode when a handler is only interested in the COMMUNITY attribute.
+var benchmarkUpdate = newIndexTestUpdate(20, 50)
+
+type benchmarkDecoded struct {
+	origin      OriginPathAttr
+	asPath      ASPathAttr
+	nextHop     NextHopPathAttr
+	med         MEDPathAttr
+	communities CommunitiesPathAttr
+	large       LargeCommunitiesPathAttr
+	nlri        []netip.Prefix
+}
+
+func BenchmarkUpdateCommunities(b *testing.B) {
+	b.Run("full decode", func(b *testing.B) {
+		d := NewUpdateDecoder[*benchmarkDecoded](
+			NewWithdrawnRoutesDecodeFn(func(u *benchmarkDecoded,
+				p []netip.Prefix) error {
+				return nil
+			}),
+			func(u *benchmarkDecoded, code uint8, flags PathAttrFlags,
+				b []byte) error {
+				switch code {
+				case PATH_ATTR_ORIGIN:
+					return u.origin.Decode(flags, b)
+				case PATH_ATTR_AS_PATH:
+					return u.asPath.Decode(flags, b)
+				case PATH_ATTR_NEXT_HOP:
+					return u.nextHop.Decode(flags, b)
+				case PATH_ATTR_MED:
+					return u.med.Decode(flags, b)
+				case PATH_ATTR_COMMUNITY:
+					return u.communities.Decode(flags, b)
+				case PATH_ATTR_LARGE_COMMUNITY:
+					return u.large.Decode(flags, b)
+				}
+				return nil
+			},
+			NewNLRIDecodeFn(func(u *benchmarkDecoded,
+				p []netip.Prefix) error {
+				u.nlri = p
+				return nil
+			}),
+		)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var u benchmarkDecoded
+			err := d.Decode(&u, benchmarkUpdate)
+			if err != nil || len(u.communities) != 20 {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		h := NewIndexedUpdateHandler(func(peer PeerConfig,
+			updateMessage []byte, index *UpdateIndex) *Notification {
+			var c CommunitiesPathAttr
+			flags, data, _ := index.Attr(PATH_ATTR_COMMUNITY)
+			err := c.Decode(flags, data)
+			if err != nil || len(c) != 20 {
+				b.Fatal(err)
+			}
+			return nil
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h(PeerConfig{}, benchmarkUpdate)
+		}
+	})
+}
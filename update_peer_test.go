@@ -0,0 +1,106 @@
+package corebgp_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/corebgptest"
+	"github.com/stretchr/testify/assert"
+)
+
+// funcUpdatePlugin passes UPDATE messages to onUpdate.
+type funcUpdatePlugin struct {
+	livenessTestPlugin
+	onUpdate func()
+}
+
+func (f *funcUpdatePlugin) OnEstablished(corebgp.PeerConfig,
+	corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	return func(corebgp.PeerConfig, []byte) *corebgp.Notification {
+		f.onUpdate()
+		return nil
+	}
+}
+
+// TestServer_UpdatePeer_reset verifies that the Plugin of a peer whose session
+// is reset by UpdatePeer may call Server methods while the session closes.
+func TestServer_UpdatePeer_reset(t *testing.T) {
+	n := corebgptest.NewNetwork()
+	addrA := netip.MustParseAddr("192.0.2.1")
+	addrB := netip.MustParseAddr("192.0.2.2")
+	server, err := corebgp.NewServer(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	blockedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	groupErrCh := make(chan error, 1)
+	plugin := &funcUpdatePlugin{
+		onUpdate: func() {
+			close(blockedCh)
+			<-releaseCh
+			_, err := server.ListPeerGroupMembers("group")
+			groupErrCh <- err
+		},
+	}
+	config := corebgp.PeerConfig{
+		RemoteAddress: addrB,
+		LocalAS:       64512,
+		RemoteAS:      64512,
+	}
+	err = server.AddPeer(config, plugin, corebgp.WithPassive())
+	if !assert.NoError(t, err) {
+		return
+	}
+	l, err := n.Listen(addrA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	go server.Serve([]net.Listener{l})
+	t.Cleanup(server.Close)
+
+	conn, err := n.Dialer(addrB)(context.Background(), "tcp", "192.0.2.1:179")
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := corebgptest.NewChaosPeer(conn, corebgptest.ChaosPeerConfig{
+		AS:       64512,
+		RouterID: addrB,
+		HoldTime: 90,
+	})
+	defer c.Close()
+	_, err = c.Establish()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c.SendUpdate([]byte{0, 0, 0, 0}))
+	<-blockedCh
+
+	actionCh := make(chan corebgp.PeerUpdateAction)
+	go func() {
+		config.RemoteAS = 64513
+		action, err := server.UpdatePeer(config, corebgp.WithPassive())
+		assert.NoError(t, err)
+		actionCh <- action
+	}()
+	// let UpdatePeer reset the session before the handler calls the Server
+	time.Sleep(time.Millisecond * 100)
+	close(releaseCh)
+	select {
+	case action := <-actionCh:
+		assert.Equal(t, corebgp.PeerUpdateReset, action)
+	case <-time.After(time.Second * 5):
+		t.Fatal("UpdatePeer did not return")
+	}
+	assert.True(t, errors.Is(<-groupErrCh, corebgp.ErrPeerGroupNotExist))
+	notif, err := c.ReadNotification(time.Second * 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, corebgp.NOTIF_CODE_CEASE, notif.Code)
+		assert.Equal(t, corebgp.NOTIF_SUBCODE_ADMIN_RESET, notif.Subcode)
+	}
+}
@@ -605,3 +605,25 @@ func TestUpdateNotificationFromErr(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeMPReachIPv6NextHops(t *testing.T) {
+	global := netip.MustParseAddr("2001:db8::2")
+	linkLocal := netip.MustParseAddr("fe80::42:c0ff:fe00:202%eth0")
+
+	nh, err := EncodeMPReachIPv6NextHops(global, netip.Addr{})
+	assert.NoError(t, err)
+	got, err := DecodeMPReachIPv6NextHops(nh)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{global}, got)
+
+	nh, err = EncodeMPReachIPv6NextHops(global, linkLocal)
+	assert.NoError(t, err)
+	got, err = DecodeMPReachIPv6NextHops(nh)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Addr{global, linkLocal.WithZone("")}, got)
+
+	_, err = EncodeMPReachIPv6NextHops(netip.MustParseAddr("192.0.2.1"), netip.Addr{})
+	assert.Error(t, err)
+	_, err = EncodeMPReachIPv6NextHops(global, global)
+	assert.Error(t, err)
+}
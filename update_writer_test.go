@@ -0,0 +1,129 @@
+package corebgp
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingConn records the calls to Write.
+type recordingConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (r *recordingConn) Write(b []byte) (int, error) {
+	r.writes = append(r.writes, append([]byte{}, b...))
+	return len(b), nil
+}
+
+func (r *recordingConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func newTestUpdateMessageWriter(conn net.Conn,
+	bufSize int) *updateMessageWriter {
+	return &updateMessageWriter{
+		conn:           conn,
+		resetKATimerCh: make(chan struct{}, 16),
+		notifCloseCh:   make(chan *Notification, 1),
+		closeCh:        make(chan struct{}),
+		bufSize:        bufSize,
+		p:              newPeer(PeerConfig{}, 0, nil, defaultPeerOptions()),
+	}
+}
+
+func TestUpdateMessageWriter_WriteUpdates(t *testing.T) {
+	conn := &recordingConn{}
+	w := newTestUpdateMessageWriter(conn, 0)
+	updates := [][]byte{{0, 0, 0, 0}, NewEndOfRIB(AFI_IPV6, SAFI_UNICAST)}
+	assert.NoError(t, w.WriteUpdates(updates))
+	if assert.Len(t, conn.writes, 1) {
+		want := append(prependHeader(updates[0], updateMessageType),
+			prependHeader(updates[1], updateMessageType)...)
+		assert.Equal(t, want, conn.writes[0])
+	}
+	assert.Len(t, w.resetKATimerCh, 1)
+
+	assert.NoError(t, w.WriteUpdate(updates[0]))
+	assert.Len(t, conn.writes, 2)
+}
+
+func TestUpdateMessageWriter_Buffered(t *testing.T) {
+	conn := &recordingConn{}
+	w := newTestUpdateMessageWriter(conn, 64)
+	update := []byte{0, 0, 0, 0}
+	msgLen := headerLength + len(update)
+
+	assert.NoError(t, w.WriteUpdate(update))
+	assert.NoError(t, w.WriteUpdate(update))
+	assert.Empty(t, conn.writes)
+	assert.NoError(t, w.Flush())
+	if assert.Len(t, conn.writes, 1) {
+		assert.Len(t, conn.writes[0], msgLen*2)
+	}
+
+	// exceeding the buffer size writes
+	assert.NoError(t, w.WriteUpdates([][]byte{update, update, update, update}))
+	if assert.Len(t, conn.writes, 2) {
+		assert.Len(t, conn.writes[1], msgLen*4)
+	}
+
+	// Notifications are written after buffered update messages
+	assert.NoError(t, w.WriteUpdate(update))
+	n := newNotification(NOTIF_CODE_CEASE, 0, nil)
+	assert.NoError(t, w.WriteNotification(n, false))
+	if assert.Len(t, conn.writes, 3) {
+		b, _ := n.encode()
+		assert.Equal(t, append(prependHeader(update, updateMessageType), b...),
+			conn.writes[2])
+	}
+
+	// empty Flush does not write
+	assert.NoError(t, w.Flush())
+	assert.Len(t, conn.writes, 3)
+
+	close(w.closeCh)
+	assert.ErrorIs(t, w.Flush(), io.ErrClosedPipe)
+	assert.ErrorIs(t, w.WriteUpdates([][]byte{update}), io.ErrClosedPipe)
+}
+
+func TestUpdateMessageWriter_CloseSession(t *testing.T) {
+	conn := &recordingConn{}
+	w := newTestUpdateMessageWriter(conn, 4096)
+	update := []byte{0, 0, 0, 0}
+	assert.NoError(t, w.WriteUpdates([][]byte{update, update}))
+	assert.Empty(t, conn.writes)
+
+	n := NewAdminShutdownNotification("")
+	assert.NoError(t, w.WriteNotification(n, true))
+	// the buffered update messages are written before the FSM is signaled to
+	// send the Notification
+	if assert.Len(t, conn.writes, 1) {
+		header := prependHeader(update, updateMessageType)
+		assert.Equal(t, append(header, header...), conn.writes[0])
+	}
+	assert.Equal(t, n, <-w.notifCloseCh)
+}
+
+func TestUpdateMessageWriter_OutboundPolicy(t *testing.T) {
+	conn := &recordingConn{}
+	w := newTestUpdateMessageWriter(conn, 0)
+	w.policy = Policy{SetMED(10)}
+	update := newIndexTestUpdate(0, 1)
+	want, err := w.policy.applyUpdate(update)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.WriteUpdate(update))
+	if assert.Len(t, conn.writes, 1) {
+		assert.Equal(t, prependHeader(want, updateMessageType), conn.writes[0])
+	}
+
+	// nothing is written if the policy cannot be applied
+	assert.Error(t, w.WriteUpdates([][]byte{update, {0, 4}}))
+	assert.Len(t, conn.writes, 1)
+}